@@ -31,9 +31,12 @@ import (
 )
 
 var (
-	fileStats, mode, pageSelection string
-	upw, opw, key, perm            string
-	verbose                        bool
+	fileStats, mode, pageSelection  string
+	upw, opw, key, perm, cert       string
+	keypw, rect, images             string
+	verbose, incremental, linearize bool
+	jsonOut, bookmarks              bool
+	revision                        int
 
 	needStackTrace = true
 )
@@ -44,13 +47,18 @@ func init() {
 	flag.StringVar(&fileStats, "stats", "", statsUsage)
 	flag.StringVar(&fileStats, "s", "", statsUsage)
 
-	modeUsage := "validate: strict|relaxed; extract: image|font|content|page; encrypt: rc4|aes"
+	modeUsage := "validate: strict|relaxed; extract: image|font|content|text|page; encrypt: rc4|aes"
 	flag.StringVar(&mode, "mode", "", modeUsage)
 	flag.StringVar(&mode, "m", "", modeUsage)
 
-	keyUsage := "encrypt: 40|128"
-	flag.StringVar(&key, "key", "128", keyUsage)
-	flag.StringVar(&key, "k", "128", keyUsage)
+	keyUsage := "encrypt: 40|128|256; decrypt, sign: private key file"
+	flag.StringVar(&key, "key", "", keyUsage)
+	flag.StringVar(&key, "k", "", keyUsage)
+
+	flag.StringVar(&cert, "cert", "", "encrypt: recipient certificate files; decrypt, sign: certificate file; signatures verify: trusted certificate files")
+
+	flag.StringVar(&keypw, "keypw", "", "sign: password of PKCS#12 key file")
+	flag.StringVar(&rect, "rect", "", "sign: rectangle of visible signature; redact: comma separated list of rectangles")
 
 	permUsage := "encrypt, perm set: none|all"
 	flag.StringVar(&perm, "perm", "none", permUsage)
@@ -62,6 +70,18 @@ func init() {
 	flag.BoolVar(&verbose, "verbose", false, "")
 	flag.BoolVar(&verbose, "v", false, "")
 
+	flag.BoolVar(&incremental, "incremental", false, "attach add, perm add, stamp, watermark: append changes as incremental update")
+
+	flag.BoolVar(&linearize, "linearize", false, "optimize: write linearized file (Fast Web View)")
+
+	flag.StringVar(&images, "images", "", "optimize: downsample and reencode images, eg. dpi=150,quality=75")
+
+	flag.BoolVar(&jsonOut, "json", false, "extract text: write JSON including position, font and size of text runs")
+
+	flag.BoolVar(&bookmarks, "bookmarks", false, "merge: nest bookmarks below a bookmark for each file")
+
+	flag.IntVar(&revision, "rev", 0, "revisions extract: revision number")
+
 	flag.StringVar(&upw, "upw", "", "user password")
 	flag.StringVar(&opw, "opw", "", "owner password")
 
@@ -76,6 +96,9 @@ func main() {
 	config := pdfcpu.NewDefaultConfiguration()
 	config.UserPW = upw
 	config.OwnerPW = opw
+	config.WriteIncrement = incremental
+	config.Linearize = linearize
+	config.MergeBookmarks = bookmarks
 
 	var cmd *api.Command
 
@@ -87,28 +110,34 @@ func main() {
 	}
 
 	for k, v := range map[string]func(config *pdfcpu.Configuration) *api.Command{
-		"validate":  prepareValidateCommand,
-		"optimize":  prepareOptimizeCommand,
-		"o":         prepareOptimizeCommand,
-		"split":     prepareSplitCommand,
-		"s":         prepareSplitCommand,
-		"merge":     prepareMergeCommand,
-		"m":         prepareMergeCommand,
-		"extract":   prepareExtractCommand,
-		"ext":       prepareExtractCommand,
-		"trim":      prepareTrimCommand,
-		"t":         prepareTrimCommand,
-		"attach":    prepareAttachmentCommand,
-		"decrypt":   prepareDecryptCommand,
-		"d":         prepareDecryptCommand,
-		"dec":       prepareDecryptCommand,
-		"encrypt":   prepareEncryptCommand,
-		"enc":       prepareEncryptCommand,
-		"changeupw": prepareChangeUserPasswordCommand,
-		"changeopw": prepareChangeOwnerPasswordCommand,
-		"perm":      preparePermissionsCommand,
-		"stamp":     prepareAddStampsCommand,
-		"watermark": prepareAddWatermarksCommand,
+		"validate":   prepareValidateCommand,
+		"optimize":   prepareOptimizeCommand,
+		"o":          prepareOptimizeCommand,
+		"split":      prepareSplitCommand,
+		"s":          prepareSplitCommand,
+		"merge":      prepareMergeCommand,
+		"m":          prepareMergeCommand,
+		"extract":    prepareExtractCommand,
+		"ext":        prepareExtractCommand,
+		"trim":       prepareTrimCommand,
+		"t":          prepareTrimCommand,
+		"attach":     prepareAttachmentCommand,
+		"decrypt":    prepareDecryptCommand,
+		"d":          prepareDecryptCommand,
+		"dec":        prepareDecryptCommand,
+		"encrypt":    prepareEncryptCommand,
+		"enc":        prepareEncryptCommand,
+		"changeupw":  prepareChangeUserPasswordCommand,
+		"changeopw":  prepareChangeOwnerPasswordCommand,
+		"perm":       preparePermissionsCommand,
+		"stamp":      prepareAddStampsCommand,
+		"watermark":  prepareAddWatermarksCommand,
+		"redact":     prepareRedactCommand,
+		"sign":       prepareSignCommand,
+		"signatures": prepareSignaturesCommand,
+		"revisions":  prepareRevisionsCommand,
+		"bookmarks":  prepareBookmarksCommand,
+		"form":       prepareFormCommand,
 	} {
 		if command == k {
 			cmd = v(config)
@@ -149,21 +178,27 @@ func helpString(topic string) string {
 		usageShort, usageLong string
 		usagePageSelection    bool
 	}{
-		"validate":  {usageValidate, usageLongValidate, false},
-		"optimize":  {usageOptimize, usageLongOptimize, false},
-		"split":     {usageSplit, usageLongSplit, false},
-		"merge":     {usageMerge, usageLongMerge, false},
-		"extract":   {usageValidate, usageLongValidate, false},
-		"trim":      {usageTrim, usageLongTrim, true},
-		"attach":    {usageAttach, usageLongAttach, false},
-		"perm":      {usagePerm, usageLongPerm, false},
-		"encrypt":   {usageEncrypt, usageLongEncrypt, false},
-		"decrypt":   {usageDecrypt, usageLongDecrypt, false},
-		"changeupw": {usageChangeUserPW, usageLongChangeUserPW, false},
-		"changeopw": {usageChangeOwnerPW, usageLongChangeOwnerPW, false},
-		"stamp":     {usageStamp, usageLongStamp, true},
-		"watermark": {usageWatermark, usageLongWatermark, true},
-		"version":   {usageVersion, usageLongVersion, false},
+		"validate":   {usageValidate, usageLongValidate, false},
+		"optimize":   {usageOptimize, usageLongOptimize, false},
+		"split":      {usageSplit, usageLongSplit, false},
+		"merge":      {usageMerge, usageLongMerge, false},
+		"extract":    {usageValidate, usageLongValidate, false},
+		"trim":       {usageTrim, usageLongTrim, true},
+		"attach":     {usageAttach, usageLongAttach, false},
+		"perm":       {usagePerm, usageLongPerm, false},
+		"encrypt":    {usageEncrypt, usageLongEncrypt, false},
+		"decrypt":    {usageDecrypt, usageLongDecrypt, false},
+		"changeupw":  {usageChangeUserPW, usageLongChangeUserPW, false},
+		"changeopw":  {usageChangeOwnerPW, usageLongChangeOwnerPW, false},
+		"stamp":      {usageStamp, usageLongStamp, true},
+		"watermark":  {usageWatermark, usageLongWatermark, true},
+		"redact":     {usageRedact, usageLongRedact, true},
+		"sign":       {usageSign, usageLongSign, false},
+		"signatures": {usageSignatures, usageLongSignatures, false},
+		"revisions":  {usageRevisions, usageLongRevisions, false},
+		"bookmarks":  {usageBookmarks, usageLongBookmarks, false},
+		"form":       {usageForm, usageLongForm, false},
+		"version":    {usageVersion, usageLongVersion, false},
 	} {
 		if topic == k {
 			if v.usagePageSelection {
@@ -237,6 +272,42 @@ func parseFlagsAndGetCommand() (command string) {
 		i = 3
 	}
 
+	// The signatures command uses a subcommand and is therefore a special case => start flag processing after 3rd argument.
+	if command == "signatures" {
+		if len(os.Args) == 2 {
+			fmt.Fprintln(os.Stderr, usageSignatures)
+			os.Exit(1)
+		}
+		i = 3
+	}
+
+	// The revisions command uses a subcommand and is therefore a special case => start flag processing after 3rd argument.
+	if command == "revisions" {
+		if len(os.Args) == 2 {
+			fmt.Fprintln(os.Stderr, usageRevisions)
+			os.Exit(1)
+		}
+		i = 3
+	}
+
+	// The bookmarks command uses a subcommand and is therefore a special case => start flag processing after 3rd argument.
+	if command == "bookmarks" {
+		if len(os.Args) == 2 {
+			fmt.Fprintln(os.Stderr, usageBookmarks)
+			os.Exit(1)
+		}
+		i = 3
+	}
+
+	// The form command uses a subcommand and is therefore a special case => start flag processing after 3rd argument.
+	if command == "form" {
+		if len(os.Args) == 2 {
+			fmt.Fprintln(os.Stderr, usageForm)
+			os.Exit(1)
+		}
+		i = 3
+	}
+
 	// Parse commandline flags.
 	err := flag.CommandLine.Parse(os.Args[i:])
 	if err != nil {
@@ -17,13 +17,18 @@ limitations under the License.
 package main
 
 import (
+	"crypto"
 	"flag"
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 
 	"github.com/hhrutter/pdfcpu/pkg/api"
 	"github.com/hhrutter/pdfcpu/pkg/pdfcpu"
+	"github.com/hhrutter/pdfcpu/pkg/types"
 )
 
 func prepareValidateCommand(config *pdfcpu.Configuration) *api.Command {
@@ -72,6 +77,13 @@ func prepareOptimizeCommand(config *pdfcpu.Configuration) *api.Command {
 		fmt.Fprintf(os.Stdout, "stats will be appended to %s\n", fileStats)
 	}
 
+	if images != "" {
+		err := pdfcpu.ParseImageResampling(images, config)
+		if err != nil {
+			log.Fatalf("optimize: problem with flag images: %v", err)
+		}
+	}
+
 	return api.OptimizeCommand(filenameIn, filenameOut, config)
 }
 
@@ -115,8 +127,8 @@ func prepareMergeCommand(config *pdfcpu.Configuration) *api.Command {
 func prepareExtractCommand(config *pdfcpu.Configuration) *api.Command {
 
 	if len(flag.Args()) != 2 || mode == "" ||
-		(mode != "image" && mode != "font" && mode != "page" && mode != "content") &&
-			(mode != "i" && mode != "p" && mode != "c") {
+		(mode != "image" && mode != "font" && mode != "page" && mode != "content" && mode != "text") &&
+			(mode != "i" && mode != "p" && mode != "c" && mode != "t") {
 		fmt.Fprintf(os.Stderr, "%s\n\n", usageExtract)
 		os.Exit(1)
 	}
@@ -146,6 +158,9 @@ func prepareExtractCommand(config *pdfcpu.Configuration) *api.Command {
 
 	case "content", "c":
 		cmd = api.ExtractContentCommand(filenameIn, dirnameOut, pages, config)
+
+	case "text", "t":
+		cmd = api.ExtractTextCommand(filenameIn, dirnameOut, pages, jsonOut, config)
 	}
 
 	return cmd
@@ -352,11 +367,15 @@ func preparePermissionsCommand(config *pdfcpu.Configuration) *api.Command {
 
 func prepareDecryptCommand(config *pdfcpu.Configuration) *api.Command {
 
-	if len(flag.Args()) == 0 || len(flag.Args()) > 2 || pageSelection != "" {
+	if len(flag.Args()) == 0 || len(flag.Args()) > 2 || pageSelection != "" || (cert != "" && key == "") {
 		fmt.Fprintf(os.Stderr, "%s\n\n", usageDecrypt)
 		os.Exit(1)
 	}
 
+	if key != "" {
+		setupRecipient(config)
+	}
+
 	filenameIn := flag.Arg(0)
 	ensurePdfExtension(filenameIn)
 
@@ -369,10 +388,45 @@ func prepareDecryptCommand(config *pdfcpu.Configuration) *api.Command {
 	return api.DecryptCommand(filenameIn, filenameOut, config)
 }
 
+// setupRecipient loads the private key and certificate needed for public-key decryption.
+// The certificate defaults to the one contained in the key file.
+func setupRecipient(config *pdfcpu.Configuration) {
+
+	k, err := pdfcpu.ReadPrivateKey(key)
+	if err != nil {
+		log.Fatalf("decrypt: problem with flag key: %v", err)
+	}
+
+	fileName := cert
+	if fileName == "" {
+		fileName = key
+	}
+
+	certs, err := pdfcpu.ReadCertificates(fileName)
+	if err != nil {
+		log.Fatalf("decrypt: problem with flag cert: %v", err)
+	}
+
+	config.DecryptKey = k
+	config.DecryptCert = certs[0]
+}
+
+// setupRecipients loads the recipient certificates for public-key encryption.
+func setupRecipients(config *pdfcpu.Configuration) {
+
+	for _, fileName := range strings.Split(cert, ",") {
+		certs, err := pdfcpu.ReadCertificates(fileName)
+		if err != nil {
+			log.Fatalf("encrypt: problem with flag cert: %v", err)
+		}
+		config.EncryptRecipients = append(config.EncryptRecipients, certs...)
+	}
+}
+
 func validEncryptOptions() bool {
 	return pageSelection == "" &&
 		(mode == "" || mode == "rc4" || mode == "aes") &&
-		(key == "" || key == "40" || key == "128") &&
+		(key == "" || key == "40" || key == "128" || key == "256") &&
 		(perm == "" || perm == "none" || perm == "all")
 }
 
@@ -384,17 +438,28 @@ func prepareEncryptCommand(config *pdfcpu.Configuration) *api.Command {
 	}
 
 	if mode == "rc4" {
+		if key == "256" {
+			fmt.Fprintf(os.Stderr, "%s\n\n", usageEncrypt)
+			os.Exit(1)
+		}
 		config.EncryptUsingAES = false
 	}
 
-	if key == "40" {
-		config.EncryptUsing128BitKey = false
+	switch key {
+	case "40":
+		config.EncryptKeyLength = 40
+	case "256":
+		config.EncryptKeyLength = 256
 	}
 
 	if perm == "all" {
 		config.UserAccessPermissions = pdfcpu.PermissionsAll
 	}
 
+	if cert != "" {
+		setupRecipients(config)
+	}
+
 	filenameIn := flag.Arg(0)
 	ensurePdfExtension(filenameIn)
 	filenameOut := filenameIn
@@ -496,3 +561,396 @@ func prepareAddStampsCommand(config *pdfcpu.Configuration) *api.Command {
 func prepareAddWatermarksCommand(config *pdfcpu.Configuration) *api.Command {
 	return prepareWatermarksCommand(config, false)
 }
+
+func prepareRedactCommand(config *pdfcpu.Configuration) *api.Command {
+
+	if len(flag.Args()) == 0 || len(flag.Args()) > 2 {
+		fmt.Fprintf(os.Stderr, "%s\n\n", usageRedact)
+		os.Exit(1)
+	}
+
+	pages, err := api.ParsePageSelection(pageSelection)
+	if err != nil {
+		log.Fatalf("problem with flag pageSelection: %v", err)
+	}
+
+	var rects []types.Rectangle
+	if rect != "" {
+		for _, s := range strings.Split(rect, ",") {
+			r, err := parseRect(s)
+			if err != nil {
+				log.Fatalf("redact: problem with flag rect: %v", err)
+			}
+			rects = append(rects, r)
+		}
+	}
+
+	filenameIn := flag.Arg(0)
+	ensurePdfExtension(filenameIn)
+
+	filenameOut := defaultFilenameOut(filenameIn)
+	if len(flag.Args()) == 2 {
+		filenameOut = flag.Arg(1)
+		ensurePdfExtension(filenameOut)
+	}
+
+	return api.RedactCommand(filenameIn, filenameOut, pages, rects, config)
+}
+
+// setupSigner loads the private key and the certificate chain of the signer.
+func setupSigner(sig *pdfcpu.Signature) {
+
+	ext := strings.ToLower(filepath.Ext(key))
+
+	if ext == ".p12" || ext == ".pfx" {
+		k, certs, err := pdfcpu.ReadPKCS12(key, keypw)
+		if err != nil {
+			log.Fatalf("sign: problem with flag key: %v", err)
+		}
+		sig.Key, sig.Certs = k, certs
+		if cert == "" {
+			return
+		}
+	} else {
+		k, err := pdfcpu.ReadPrivateKey(key)
+		if err != nil {
+			log.Fatalf("sign: problem with flag key: %v", err)
+		}
+		sig.Key = k.(crypto.Signer)
+	}
+
+	certFile := cert
+	if certFile == "" {
+		certFile = key
+	}
+
+	certs, err := pdfcpu.ReadCertificates(certFile)
+	if err != nil {
+		log.Fatalf("sign: problem with flag cert: %v", err)
+	}
+
+	// The signer certificate comes first.
+	sig.Certs = append(sig.Certs, certs...)
+}
+
+func parseRect(s string) (types.Rectangle, error) {
+
+	var f [4]float64
+
+	ss := strings.Fields(s)
+	if len(ss) != 4 {
+		return types.Rectangle{}, fmt.Errorf("invalid rectangle: %s", s)
+	}
+
+	for i, v := range ss {
+		var err error
+		if f[i], err = strconv.ParseFloat(v, 64); err != nil {
+			return types.Rectangle{}, fmt.Errorf("invalid rectangle: %s", s)
+		}
+	}
+
+	return types.NewRectangle(f[0], f[1], f[2], f[3]), nil
+}
+
+func prepareSignCommand(config *pdfcpu.Configuration) *api.Command {
+
+	if len(flag.Args()) == 0 || len(flag.Args()) > 2 || key == "" || mode != "" {
+		fmt.Fprintf(os.Stderr, "%s\n\n", usageSign)
+		os.Exit(1)
+	}
+
+	sig := &pdfcpu.Signature{}
+
+	if pageSelection != "" {
+		pageNr, err := strconv.Atoi(pageSelection)
+		if err != nil || pageNr < 1 {
+			log.Fatalf("sign: problem with flag pages: %s", pageSelection)
+		}
+		sig.PageNr = pageNr
+	}
+
+	if rect != "" {
+		r, err := parseRect(rect)
+		if err != nil {
+			log.Fatalf("sign: problem with flag rect: %v", err)
+		}
+		sig.Rect = r
+	}
+
+	setupSigner(sig)
+
+	filenameIn := flag.Arg(0)
+	ensurePdfExtension(filenameIn)
+	filenameOut := filenameIn
+	if len(flag.Args()) == 2 {
+		filenameOut = flag.Arg(1)
+		ensurePdfExtension(filenameOut)
+	}
+
+	return api.SignCommand(filenameIn, filenameOut, sig, config)
+}
+
+// setupTrustStore loads the trusted certificates for signature verification.
+func setupTrustStore(config *pdfcpu.Configuration) {
+
+	for _, fileName := range strings.Split(cert, ",") {
+		certs, err := pdfcpu.ReadCertificates(fileName)
+		if err != nil {
+			log.Fatalf("signatures: problem with flag cert: %v", err)
+		}
+		config.TrustedCerts = append(config.TrustedCerts, certs...)
+	}
+}
+
+func prepareVerifySignaturesCommand(config *pdfcpu.Configuration) *api.Command {
+
+	if len(flag.Args()) != 1 || pageSelection != "" || key != "" {
+		fmt.Fprintf(os.Stderr, "usage: %s\n", usageSignaturesVerify)
+		os.Exit(1)
+	}
+
+	if cert != "" {
+		setupTrustStore(config)
+	}
+
+	filenameIn := flag.Arg(0)
+	ensurePdfExtension(filenameIn)
+
+	return api.VerifySignaturesCommand(filenameIn, config)
+}
+
+func prepareSignaturesCommand(config *pdfcpu.Configuration) *api.Command {
+
+	if len(os.Args) == 2 {
+		fmt.Fprintln(os.Stderr, usageSignatures)
+		os.Exit(1)
+	}
+
+	var cmd *api.Command
+
+	subCmd := os.Args[2]
+
+	switch subCmd {
+
+	case "verify":
+		cmd = prepareVerifySignaturesCommand(config)
+
+	default:
+		fmt.Fprintln(os.Stderr, usageSignatures)
+		os.Exit(1)
+	}
+
+	return cmd
+}
+
+func prepareListRevisionsCommand(config *pdfcpu.Configuration) *api.Command {
+
+	if len(flag.Args()) != 1 || pageSelection != "" || revision != 0 {
+		fmt.Fprintf(os.Stderr, "usage: %s\n", usageRevisionsList)
+		os.Exit(1)
+	}
+
+	filenameIn := flag.Arg(0)
+	ensurePdfExtension(filenameIn)
+
+	return api.ListRevisionsCommand(filenameIn, config)
+}
+
+func prepareExtractRevisionCommand(config *pdfcpu.Configuration) *api.Command {
+
+	if len(flag.Args()) != 2 || pageSelection != "" || revision < 1 {
+		fmt.Fprintf(os.Stderr, "usage: %s\n", usageRevisionsExtract)
+		os.Exit(1)
+	}
+
+	filenameIn := flag.Arg(0)
+	ensurePdfExtension(filenameIn)
+
+	filenameOut := flag.Arg(1)
+	ensurePdfExtension(filenameOut)
+
+	return api.ExtractRevisionCommand(filenameIn, filenameOut, revision, config)
+}
+
+func prepareRevisionsCommand(config *pdfcpu.Configuration) *api.Command {
+
+	if len(os.Args) == 2 {
+		fmt.Fprintln(os.Stderr, usageRevisions)
+		os.Exit(1)
+	}
+
+	var cmd *api.Command
+
+	subCmd := os.Args[2]
+
+	switch subCmd {
+
+	case "list":
+		cmd = prepareListRevisionsCommand(config)
+
+	case "extract":
+		cmd = prepareExtractRevisionCommand(config)
+
+	default:
+		fmt.Fprintln(os.Stderr, usageRevisions)
+		os.Exit(1)
+	}
+
+	return cmd
+}
+
+func prepareListBookmarksCommand(config *pdfcpu.Configuration) *api.Command {
+
+	if len(flag.Args()) != 1 || pageSelection != "" {
+		fmt.Fprintf(os.Stderr, "usage: %s\n", usageBookmarksList)
+		os.Exit(1)
+	}
+
+	filenameIn := flag.Arg(0)
+	ensurePdfExtension(filenameIn)
+
+	return api.ListBookmarksCommand(filenameIn, config)
+}
+
+func prepareExportBookmarksCommand(config *pdfcpu.Configuration) *api.Command {
+
+	if len(flag.Args()) != 2 || pageSelection != "" {
+		fmt.Fprintf(os.Stderr, "usage: %s\n", usageBookmarksExport)
+		os.Exit(1)
+	}
+
+	filenameIn := flag.Arg(0)
+	ensurePdfExtension(filenameIn)
+
+	return api.ExportBookmarksCommand(filenameIn, flag.Arg(1), config)
+}
+
+func prepareImportBookmarksCommand(config *pdfcpu.Configuration) *api.Command {
+
+	if len(flag.Args()) < 2 || len(flag.Args()) > 3 || pageSelection != "" {
+		fmt.Fprintf(os.Stderr, "usage: %s\n", usageBookmarksImport)
+		os.Exit(1)
+	}
+
+	filenameIn := flag.Arg(0)
+	ensurePdfExtension(filenameIn)
+
+	filenameOut := filenameIn
+	if len(flag.Args()) == 3 {
+		filenameOut = flag.Arg(2)
+		ensurePdfExtension(filenameOut)
+	}
+
+	return api.ImportBookmarksCommand(filenameIn, flag.Arg(1), filenameOut, config)
+}
+
+func prepareRemoveBookmarksCommand(config *pdfcpu.Configuration) *api.Command {
+
+	if len(flag.Args()) < 1 || len(flag.Args()) > 2 || pageSelection != "" {
+		fmt.Fprintf(os.Stderr, "usage: %s\n", usageBookmarksRemove)
+		os.Exit(1)
+	}
+
+	filenameIn := flag.Arg(0)
+	ensurePdfExtension(filenameIn)
+
+	filenameOut := filenameIn
+	if len(flag.Args()) == 2 {
+		filenameOut = flag.Arg(1)
+		ensurePdfExtension(filenameOut)
+	}
+
+	return api.RemoveBookmarksCommand(filenameIn, filenameOut, config)
+}
+
+func prepareBookmarksCommand(config *pdfcpu.Configuration) *api.Command {
+
+	if len(os.Args) == 2 {
+		fmt.Fprintln(os.Stderr, usageBookmarks)
+		os.Exit(1)
+	}
+
+	var cmd *api.Command
+
+	subCmd := os.Args[2]
+
+	switch subCmd {
+
+	case "list":
+		cmd = prepareListBookmarksCommand(config)
+
+	case "export":
+		cmd = prepareExportBookmarksCommand(config)
+
+	case "import":
+		cmd = prepareImportBookmarksCommand(config)
+
+	case "remove":
+		cmd = prepareRemoveBookmarksCommand(config)
+
+	default:
+		fmt.Fprintln(os.Stderr, usageBookmarks)
+		os.Exit(1)
+	}
+
+	return cmd
+}
+
+func prepareListFormFieldsCommand(config *pdfcpu.Configuration) *api.Command {
+
+	if len(flag.Args()) != 1 || pageSelection != "" {
+		fmt.Fprintf(os.Stderr, "usage: %s\n", usageFormList)
+		os.Exit(1)
+	}
+
+	filenameIn := flag.Arg(0)
+	ensurePdfExtension(filenameIn)
+
+	return api.ListFormFieldsCommand(filenameIn, config)
+}
+
+func prepareFillFormCommand(config *pdfcpu.Configuration) *api.Command {
+
+	if len(flag.Args()) < 2 || len(flag.Args()) > 3 || pageSelection != "" {
+		fmt.Fprintf(os.Stderr, "usage: %s\n", usageFormFill)
+		os.Exit(1)
+	}
+
+	filenameIn := flag.Arg(0)
+	ensurePdfExtension(filenameIn)
+
+	filenameOut := filenameIn
+	if len(flag.Args()) == 3 {
+		filenameOut = flag.Arg(2)
+		ensurePdfExtension(filenameOut)
+	}
+
+	return api.FillFormCommand(filenameIn, flag.Arg(1), filenameOut, config)
+}
+
+func prepareFormCommand(config *pdfcpu.Configuration) *api.Command {
+
+	if len(os.Args) == 2 {
+		fmt.Fprintln(os.Stderr, usageForm)
+		os.Exit(1)
+	}
+
+	var cmd *api.Command
+
+	subCmd := os.Args[2]
+
+	switch subCmd {
+
+	case "list":
+		cmd = prepareListFormFieldsCommand(config)
+
+	case "fill":
+		cmd = prepareFillFormCommand(config)
+
+	default:
+		fmt.Fprintln(os.Stderr, usageForm)
+		os.Exit(1)
+	}
+
+	return cmd
+}
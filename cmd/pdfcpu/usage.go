@@ -29,7 +29,7 @@ The commands are:
 	optimize	optimize PDF by getting rid of redundant page resources
 	split		split multi-page PDF into several single-page PDFs
 	merge		concatenate 2 or more PDFs
-	extract		extract images, fonts, content or pages
+	extract		extract images, fonts, content, text or pages
 	trim		create trimmed version
 	attach		list, add, remove, extract embedded file attachments
 	perm		list, add user access permissions
@@ -39,6 +39,12 @@ The commands are:
 	changeopw	change owner password
 	stamp		add stamps
 	watermark	add watermarks
+	redact		remove content from page areas
+	sign		add digital signature
+	signatures	verify digital signatures
+	revisions	list revisions, extract a prior revision
+	bookmarks	list, export, import, remove bookmarks
+	form		list, fill form fields
 	version		print version
    
 	Single-letter Unix-style supported for commands and flags.
@@ -56,19 +62,26 @@ verbose ... extensive log output
 		
 The validation modes are:
 
- strict ... (default) validates against PDF 32000-1:2008 (PDF 1.7)
+ strict ... (default) validates against PDF 32000-1:2008 (PDF 1.7) including page content streams
 relaxed ... like strict but doesn't complain about common seen spec violations.`
 
-	usageOptimize     = "usage: pdfcpu optimize [-verbose] [-stats csvFile] [-upw userpw] [-opw ownerpw] inFile [outFile]"
+	usageOptimize     = "usage: pdfcpu optimize [-verbose] [-stats csvFile] [-linearize] [-images dpi=150,quality=75] [-upw userpw] [-opw ownerpw] inFile [outFile]"
 	usageLongOptimize = `Optimize reads inFile, removes redundant page resources like embedded fonts and images and writes the result to outFile.
-
-verbose ... extensive log output
-  stats ... appends a stats line to a csv file with information about the usage of root and page entries.
-            useful for batch optimization and debugging PDFs.
-    upw ... user password
-    opw ... owner password
- inFile ... input pdf file
-outFile ... output pdf file (default: inFile-new.pdf)`
+Black and white images are reencoded using CCITT Group 4 compression if this reduces their size.
+Fully embedded TrueType and CFF fonts are subset to the glyphs used.
+Resources not referenced by page content or nested forms are removed.
+
+  verbose ... extensive log output
+    stats ... appends a stats line to a csv file with information about the usage of root and page entries.
+              useful for batch optimization and debugging PDFs.
+linearize ... write a linearized file for fast web view
+   images ... downsample images whose effective resolution on the page exceeds 1.5 times dpi (default: 150) to dpi.
+              Photos are reencoded as JPEG using quality (1..100, default: 75), other images using Flate.
+              Images are only replaced if this saves space, the bytes saved per image are part of the stats log output.
+      upw ... user password
+      opw ... owner password
+   inFile ... input pdf file
+  outFile ... output pdf file (default: inFile-new.pdf)`
 
 	usageSplit     = "usage: pdfcpu split [-verbose] [-upw userpw] [-opw ownerpw] inFile outDir"
 	usageLongSplit = `Split generates a set of single page PDFs for the input file in outDir.
@@ -79,18 +92,23 @@ verbose ... extensive log output
  inFile ... input pdf file
  outDir ... output directory`
 
-	usageMerge     = "usage: pdfcpu merge [-verbose] outFile inFile..."
+	usageMerge     = "usage: pdfcpu merge [-verbose] [-bookmarks] outFile inFile..."
 	usageLongMerge = `Merge concatenates a sequence of PDFs/inFiles to outFile.
+The bookmarks (outlines), links, named destinations and form fields of all inFiles are retained,
+clashing destination names get prefixed by the name of the inFile they come from,
+clashing form fields get nested below a new field named after the inFile they come from.
 
-verbose ... extensive log output
-outFile	... output pdf file
-inFiles ... a list of at least 2 pdf files subject to concatenation.`
+  verbose ... extensive log output
+bookmarks ... nest the bookmarks of each inFile below a new bookmark named after the file
+  outFile ... output pdf file
+  inFiles ... a list of at least 2 pdf files subject to concatenation.`
 
-	usageExtract     = "usage: pdfcpu extract [-verbose] -mode image|font|content|page [-pages pageSelection] [-upw userpw] [-opw ownerpw] inFile outDir"
-	usageLongExtract = `Extract exports inFile's images, fonts, content or pages into outDir.
+	usageExtract     = "usage: pdfcpu extract [-verbose] -mode image|font|content|text|page [-json] [-pages pageSelection] [-upw userpw] [-opw ownerpw] inFile outDir"
+	usageLongExtract = `Extract exports inFile's images, fonts, content, text or pages into outDir.
 
 verbose ... extensive log output
    mode ... extraction mode
+   json ... text mode only: write text runs including position, font and size as JSON
   pages ... page selection
     upw ... user password
     opw ... owner password
@@ -102,6 +120,7 @@ verbose ... extensive log output
   image ... extract images (supported PDF filters: Flate, DCTDecode, JPXDecode)
    font ... extract font files (supported font types: TrueType)
 content ... extract raw page content
+   text ... extract page text in reading order
    page ... extract single page PDFs`
 
 	usageTrim     = "usage: pdfcpu trim [-verbose] -pages pageSelection [-upw userpw] [-opw ownerpw] inFile [outFile]"
@@ -132,7 +151,7 @@ outFile ... output pdf file (default: inFile-new.pdf)`
 e.g. -3,5,7- or 4-7,!6 or 1-,!5 or odd,n1`
 
 	usageAttachList    = "pdfcpu attach list [-verbose] [-upw userpw] [-opw ownerpw] inFile"
-	usageAttachAdd     = "pdfcpu attach add [-verbose] [-upw userpw] [-opw ownerpw] inFile file..."
+	usageAttachAdd     = "pdfcpu attach add [-verbose] [-incremental] [-upw userpw] [-opw ownerpw] inFile file..."
 	usageAttachRemove  = "pdfcpu attach remove [-verbose] [-upw userpw] [-opw ownerpw] inFile [file...]"
 	usageAttachExtract = "pdfcpu attach extract [-verbose] [-upw userpw] [-opw ownerpw] inFile outDir [file...]"
 
@@ -143,45 +162,51 @@ e.g. -3,5,7- or 4-7,!6 or 1-,!5 or odd,n1`
 
 	usageLongAttach = `Attach manages embedded file attachments.
 	
-verbose ... extensive log output
-   perm ... user access permissions
-    upw ... user password
-    opw ... owner password
- inFile ... input pdf file
- outDir ... output directory`
+    verbose ... extensive log output
+incremental ... append the attachments as incremental update instead of rewriting inFile
+       perm ... user access permissions
+        upw ... user password
+        opw ... owner password
+     inFile ... input pdf file
+     outDir ... output directory`
 
 	usagePermList = "pdfcpu perm list [-verbose] [-upw userpw] [-opw ownerpw] inFile"
-	usagePermAdd  = "pdfcpu perm add [-verbose] [-perm none|all] [-upw userpw] -opw ownerpw inFile"
+	usagePermAdd  = "pdfcpu perm add [-verbose] [-incremental] [-perm none|all] [-upw userpw] -opw ownerpw inFile"
 
 	usagePerm = "usage: " + usagePermList +
 		"\n       " + usagePermAdd
 
 	usageLongPerm = `Perm manages user access permissions.
 	
-verbose ... extensive log output
-   perm ... user access permissions
-    upw ... user password
-    opw ... owner password
- inFile ... input pdf file`
-
-	usageEncrypt     = "usage: pdfcpu encrypt [-verbose] [-mode rc4|aes] [-key 40|128] [perm none|all] [-upw userpw] [-opw ownerpw] inFile [outFile]"
+    verbose ... extensive log output
+incremental ... append the permissions as incremental update instead of rewriting inFile,
+                requires AES-256 encryption
+       perm ... user access permissions
+        upw ... user password
+        opw ... owner password
+     inFile ... input pdf file`
+
+	usageEncrypt     = "usage: pdfcpu encrypt [-verbose] [-mode rc4|aes] [-key 40|128|256] [perm none|all] [-upw userpw] [-opw ownerpw] [-cert certFile,...] inFile [outFile]"
 	usageLongEncrypt = `Encrypt sets a password protection based on user and owner password.
 
 verbose ... extensive log output
    mode ... algorithm (default=aes)
-    key ... key length in bits (default=128)
+    key ... key length in bits (default=128), 256 requires aes
    perm ... user access permissions
     upw ... user password
     opw ... owner password
+   cert ... PEM encoded recipient certificates for public-key encryption, replaces passwords
  inFile ... input pdf file
 outFile ... output pdf file`
 
-	usageDecrypt     = "usage: pdfcpu decrypt [-verbose] [-upw userpw] [-opw ownerpw] inFile [outFile]"
+	usageDecrypt     = "usage: pdfcpu decrypt [-verbose] [-upw userpw] [-opw ownerpw] [-key keyFile [-cert certFile]] inFile [outFile]"
 	usageLongDecrypt = `Decrypt removes a password protection.
 
 verbose ... extensive log output
     upw ... user password
     opw ... owner password
+    key ... PEM encoded RSA private key of a recipient for public-key encryption
+   cert ... PEM encoded certificate of this recipient (default: contained in keyFile)
  inFile ... input pdf file
 outFile ... output pdf file`
 
@@ -211,7 +236,8 @@ verbose ... extensive log output
 	
          (defaults: 'f:Helvetica, p:24, s:0.5 rel, c:0.5 0.5 0.5, d:1, o:1, m:0')
 	
-      f: fontname, a basefont, supported are: Helvetica, Times-Roman, Courier
+      f: fontname, one of the standard 14 fonts, eg. Helvetica, Times-Bold, Courier-Oblique, Symbol
+         or a TrueType font file with extension ttf or otf (TrueType outlines only) to be embedded
       p: fontsize in points
       s: scale factor, 0.0 <= x <= 1.0 followed by optional 'abs|rel'
       c: color: 3 fill color intensities, where 0.0 < i < 1.0, eg 1.0, 0.0 0.0 = red (default:0.5 0.5 0.5 = gray)
@@ -227,12 +253,14 @@ verbose ... extensive log output
 e.g. 'Draft'                                                  'logo.png'
      'Draft, d:2'                                             'logo.png, o:0,5, s:0.5 abs, r:0'
      'Intentionally left blank, p:48'
-     'Confidental, f:Courier, s:0.75, c: 0.5 0.0 0.0, r:20'`
+     'Confidental, f:Courier, s:0.75, c: 0.5 0.0 0.0, r:20'
+     'Vertraulich, f:/usr/share/fonts/DejaVuSans.ttf'`
 
-	usageStamp     = "usage: pdfcpu stamp [-verbose] -pages pageSelection description inFile [outFile]"
+	usageStamp     = "usage: pdfcpu stamp [-verbose] [-incremental] -pages pageSelection description inFile [outFile]"
 	usageLongStamp = `Stamp adds stamps for selected pages. 
 
     verbose ... extensive log output
+incremental ... append the stamps as incremental update to the original revision of inFile
       pages ... page selection
 description ... font, text, color, rotation
      inFile ... input pdf file
@@ -240,10 +268,11 @@ description ... font, text, color, rotation
 
 ` + usageWMDescription
 
-	usageWatermark     = "usage: pdfcpu watermark [-verbose] -pages pageSelection description inFile [outFile]"
+	usageWatermark     = "usage: pdfcpu watermark [-verbose] [-incremental] -pages pageSelection description inFile [outFile]"
 	usageLongWatermark = `Watermark adds watermarks for selected pages. 
 
     verbose ... extensive log output
+incremental ... append the watermarks as incremental update to the original revision of inFile
       pages ... page selection
 description ... font, text, color, rotation
      inFile ... input pdf file
@@ -251,6 +280,132 @@ description ... font, text, color, rotation
 
 ` + usageWMDescription
 
+	usageRedact     = "usage: pdfcpu redact [-verbose] [-pages pageSelection] [-rect 'llx lly urx ury,...'] [-upw userpw] [-opw ownerpw] inFile [outFile]"
+	usageLongRedact = `Redact removes text, vector graphics and image pixels within rectangles
+and within the areas of Redact annotations from selected pages.
+
+Removed text cannot be recovered by text extraction.
+Rectangles are painted black, Redact annotations are painted using their interior color and get removed.
+The result is always written as a complete file, -incremental is not supported.
+
+verbose ... extensive log output
+  pages ... page selection (default: all pages)
+   rect ... rectangles in user space units of the unrotated page, omit for applying Redact annotations only
+    upw ... user password
+    opw ... owner password
+ inFile ... input pdf file
+outFile ... output pdf file (default: inFile-new.pdf)`
+
+	usageSign     = "usage: pdfcpu sign [-verbose] [-upw userpw] [-opw ownerpw] -key keyFile [-keypw password] [-cert certFile] [-pages pageNr -rect 'llx lly urx ury'] inFile [outFile]"
+	usageLongSign = `Sign adds a digital signature (adbe.pkcs7.detached) by appending an incremental update to inFile.
+
+verbose ... extensive log output
+    upw ... user password
+    opw ... owner password
+    key ... private key of the signer, either PEM encoded or a PKCS#12 file (.p12, .pfx)
+  keypw ... password of the PKCS#12 file
+   cert ... PEM encoded certificate chain, for PEM keys starting with the signer certificate
+            (default: contained in keyFile)
+  pages ... page holding the signature field (default: 1)
+   rect ... rectangle of a visible signature in user space units, omit for an invisible signature
+ inFile ... input pdf file
+outFile ... output pdf file`
+
+	usageSignaturesVerify = "pdfcpu signatures verify [-verbose] [-upw userpw] [-opw ownerpw] [-cert certFile,...] inFile"
+
+	usageSignatures = "usage: " + usageSignaturesVerify
+
+	usageLongSignatures = `Signatures verifies digital signatures.
+
+For each signature the integrity of the signed bytes, the signature value
+and the certificate chain of the signer are checked and the covered part
+of inFile is reported, either the whole document or a prior revision.
+
+verbose ... extensive log output
+    upw ... user password
+    opw ... owner password
+   cert ... PEM encoded trusted certificates
+ inFile ... input pdf file`
+
+	usageRevisionsList    = "pdfcpu revisions list [-verbose] inFile"
+	usageRevisionsExtract = "pdfcpu revisions extract [-verbose] [-upw userpw] [-opw ownerpw] -rev revision inFile outFile"
+
+	usageRevisions = "usage: " + usageRevisionsList +
+		"\n       " + usageRevisionsExtract
+
+	usageLongRevisions = `Revisions inspects the revision history of incrementally updated files.
+
+Revision 1 is the original document, every incremental update adds another revision.
+list shows the cross reference section and trailer of each revision
+along with the objects it introduces, changes and frees.
+extract writes inFile as it existed at a prior revision,
+eg. to compare against the revision covered by a digital signature.
+
+verbose ... extensive log output
+    upw ... user password
+    opw ... owner password
+    rev ... revision number
+ inFile ... input pdf file
+outFile ... output pdf file`
+
+	usageBookmarksList   = "pdfcpu bookmarks list [-verbose] [-upw userpw] [-opw ownerpw] inFile"
+	usageBookmarksExport = "pdfcpu bookmarks export [-verbose] [-upw userpw] [-opw ownerpw] inFile jsonFile"
+	usageBookmarksImport = "pdfcpu bookmarks import [-verbose] [-upw userpw] [-opw ownerpw] inFile jsonFile [outFile]"
+	usageBookmarksRemove = "pdfcpu bookmarks remove [-verbose] [-upw userpw] [-opw ownerpw] inFile [outFile]"
+
+	usageBookmarks = "usage: " + usageBookmarksList +
+		"\n       " + usageBookmarksExport +
+		"\n       " + usageBookmarksImport +
+		"\n       " + usageBookmarksRemove
+
+	usageLongBookmarks = `Bookmarks manages the outline tree (bookmarks) of inFile.
+
+export writes the outline tree to jsonFile, import replaces the outline tree by the one in jsonFile.
+Each bookmark has a title and optionally:
+
+    page ... the page number of the destination
+    view ... the destination type: XYZ, Fit, FitH, FitV, FitR, FitB, FitBH or FitBV (default Fit)
+  params ... the parameters of the view, eg. [left, top, zoom] for XYZ, null leaves a value unchanged
+   color ... the RGB text color, eg. [1, 0, 0]
+    bold ... true for bold text
+  italic ... true for italic text
+    open ... true if the kids are visible
+    kids ... the nested bookmarks
+
+eg. [{"title": "Chapter 1", "page": 1, "open": true, "kids": [{"title": "1.1 Intro", "page": 2, "view": "XYZ", "params": [0, 792, null]}]}]
+
+ verbose ... extensive log output
+     upw ... user password
+     opw ... owner password
+  inFile ... input pdf file
+jsonFile ... JSON file
+ outFile ... output pdf file, defaults to inFile`
+
+	usageFormList = "pdfcpu form list [-verbose] [-upw userpw] [-opw ownerpw] inFile"
+	usageFormFill = "pdfcpu form fill [-verbose] [-upw userpw] [-opw ownerpw] inFile jsonFile [outFile]"
+
+	usageForm = "usage: " + usageFormList +
+		"\n       " + usageFormFill
+
+	usageLongForm = `Form manages the fields of the interactive form (AcroForm) of inFile.
+
+list prints the fully qualified name, type, flags, page, rectangle, value and options of each field.
+fill sets the field values defined in jsonFile and regenerates the field appearances:
+
+  text fields ... a string
+  check boxes ... true, false or the name of the on state
+radio buttons ... the name of an option
+choice fields ... the export value of an option, a list of export values for multiple selection
+
+eg. {"name": "John", "subscribe": true, "payment": "card2", "languages": ["Go", "C"]}
+
+ verbose ... extensive log output
+     upw ... user password
+     opw ... owner password
+  inFile ... input pdf file
+jsonFile ... JSON file
+ outFile ... output pdf file, defaults to inFile`
+
 	usageVersion     = "usage: pdfcpu version"
 	usageLongVersion = "Version prints the pdfcpu version"
 )
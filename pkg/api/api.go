@@ -18,7 +18,10 @@ limitations under the License.
 package api
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -28,6 +31,7 @@ import (
 
 	"github.com/hhrutter/pdfcpu/pkg/log"
 	"github.com/hhrutter/pdfcpu/pkg/pdfcpu"
+	"github.com/hhrutter/pdfcpu/pkg/types"
 
 	"github.com/pkg/errors"
 )
@@ -60,6 +64,22 @@ func Read(fileIn string, config *pdfcpu.Configuration) (*pdfcpu.PDFContext, erro
 	return ctx, nil
 }
 
+// ReadContext reads in a PDF of fileSize bytes from rs and builds an internal structure holding its cross reference table aka the PDFContext.
+func ReadContext(rs io.ReadSeeker, fileSize int64, config *pdfcpu.Configuration) (*pdfcpu.PDFContext, error) {
+
+	ctx, err := pdfcpu.ReadPDF(rs, fileSize, config)
+	if err != nil {
+		return nil, errors.Wrap(err, "Read failed.")
+	}
+
+	return ctx, nil
+}
+
+// ValidateContext validates the cross reference table of a PDFContext against ISO-32000-1:2008.
+func ValidateContext(ctx *pdfcpu.PDFContext) error {
+	return pdfcpu.ValidateXRefTable(ctx.XRefTable)
+}
+
 // Validate validates a PDF file against ISO-32000-1:2008.
 func Validate(cmd *Command) ([]string, error) {
 
@@ -80,7 +100,10 @@ func Validate(cmd *Command) ([]string, error) {
 
 	from2 := time.Now()
 
-	err = pdfcpu.ValidateXRefTable(ctx.XRefTable)
+	err = ValidateContext(ctx)
+	if err == nil {
+		err = pdfcpu.ValidateLinearization(ctx)
+	}
 	if err != nil {
 		err = errors.Wrap(err, "validation error (try -mode=relaxed)")
 	} else {
@@ -123,6 +146,24 @@ func Write(ctx *pdfcpu.PDFContext) error {
 	return nil
 }
 
+// WriteContext generates a PDF for a given PDFContext and writes it to w.
+func WriteContext(ctx *pdfcpu.PDFContext, w io.Writer) error {
+
+	err := pdfcpu.WritePDF(ctx, w)
+	if err != nil {
+		return errors.Wrap(err, "Write failed.")
+	}
+
+	if ctx.StatsFileName != "" {
+		err = pdfcpu.AppendStatsFile(ctx)
+		if err != nil {
+			return errors.Wrap(err, "Write stats failed.")
+		}
+	}
+
+	return nil
+}
+
 // singlePageFileName generates a filename for a PDFContext and a specific page number.
 func singlePageFileName(ctx *pdfcpu.PDFContext, pageNr int) string {
 
@@ -172,7 +213,7 @@ func readAndValidate(fileIn string, config *pdfcpu.Configuration, from1 time.Tim
 	from2 := time.Now()
 	//fmt.Printf("validating %s ...\n", fileIn)
 	//logInfoAPI.Printf("validating %s..\n", fileIn)
-	err = pdfcpu.ValidateXRefTable(ctx.XRefTable)
+	err = ValidateContext(ctx)
 	if err != nil {
 		return nil, 0, 0, err
 	}
@@ -188,9 +229,14 @@ func readValidateAndOptimize(fileIn string, config *pdfcpu.Configuration, from1
 		return nil, 0, 0, 0, err
 	}
 
+	// Optimization touches objects all over the place which defeats an incremental update.
+	if config.IncrementalUpdate() {
+		return ctx, dur1, dur2, 0, nil
+	}
+
 	from3 := time.Now()
 	//fmt.Printf("optimizing %s ...\n", fileIn)
-	err = pdfcpu.OptimizeXRefTable(ctx)
+	err = OptimizeContext(ctx)
 	if err != nil {
 		return nil, 0, 0, 0, err
 	}
@@ -199,6 +245,11 @@ func readValidateAndOptimize(fileIn string, config *pdfcpu.Configuration, from1
 	return ctx, dur1, dur2, dur3, nil
 }
 
+// OptimizeContext gets rid of redundant resources like duplicate fonts and images of a PDFContext.
+func OptimizeContext(ctx *pdfcpu.PDFContext) error {
+	return pdfcpu.OptimizeXRefTable(ctx)
+}
+
 // Optimize reads in fileIn, does validation, optimization and writes the result to fileOut.
 func Optimize(cmd *Command) ([]string, error) {
 
@@ -281,6 +332,27 @@ func Split(cmd *Command) ([]string, error) {
 	return nil, nil
 }
 
+// MergeContext appends the page tree of ctxSource to ctxDest's page tree.
+// ctxDest may be merged with further sources and should be optimized and validated before it gets written.
+func MergeContext(ctxSource, ctxDest *pdfcpu.PDFContext) error {
+
+	if ctxDest.XRefTable.Version() < pdfcpu.V15 {
+		v, _ := pdfcpu.Version("1.5")
+		ctxDest.XRefTable.RootVersion = &v
+		log.Stats.Println("Ensure V1.5 for writing object & xref streams")
+	}
+
+	ctxDest.Write.Command = "Merge"
+
+	return pdfcpu.MergeXRefTables(ctxSource, ctxDest)
+}
+
+// bookmarkTitle returns the title of the outline item representing fileIn in a merged file.
+func bookmarkTitle(fileIn string) string {
+	fileName := filepath.Base(fileIn)
+	return strings.TrimSuffix(fileName, filepath.Ext(fileName))
+}
+
 // appendTo appends fileIn to ctxDest's page tree.
 func appendTo(fileIn string, ctxDest *pdfcpu.PDFContext) error {
 
@@ -292,9 +364,16 @@ func appendTo(fileIn string, ctxDest *pdfcpu.PDFContext) error {
 		return err
 	}
 
+	if ctxDest.MergeBookmarks {
+		err = pdfcpu.NestOutlines(ctxSource, bookmarkTitle(fileIn))
+		if err != nil {
+			return err
+		}
+	}
+
 	// Merge the source context into the dest context.
 	fmt.Printf("merging in %s ...\n", fileIn)
-	return pdfcpu.MergeXRefTables(ctxSource, ctxDest)
+	return MergeContext(ctxSource, ctxDest)
 }
 
 // Merge some PDF files together and write the result to fileOut.
@@ -314,10 +393,11 @@ func Merge(cmd *Command) ([]string, error) {
 		return nil, err
 	}
 
-	if ctxDest.XRefTable.Version() < pdfcpu.V15 {
-		v, _ := pdfcpu.Version("1.5")
-		ctxDest.XRefTable.RootVersion = &v
-		log.Stats.Println("Ensure V1.5 for writing object & xref streams")
+	if config.MergeBookmarks {
+		err = pdfcpu.NestOutlines(ctxDest, bookmarkTitle(filesIn[0]))
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	// Repeatedly merge files into fileDest's xref table.
@@ -328,12 +408,12 @@ func Merge(cmd *Command) ([]string, error) {
 		}
 	}
 
-	err = pdfcpu.OptimizeXRefTable(ctxDest)
+	err = OptimizeContext(ctxDest)
 	if err != nil {
 		return nil, err
 	}
 
-	err = pdfcpu.ValidateXRefTable(ctxDest.XRefTable)
+	err = ValidateContext(ctxDest)
 	if err != nil {
 		return nil, err
 	}
@@ -770,6 +850,104 @@ func ExtractContent(cmd *Command) ([]string, error) {
 	return nil, nil
 }
 
+func doExtractText(ctx *pdfcpu.PDFContext, selectedPages pdfcpu.IntSet, asJSON bool) error {
+
+	for p, v := range selectedPages {
+
+		if !v {
+			continue
+		}
+
+		log.Info.Printf("writing text for page %d\n", p)
+
+		pt, err := pdfcpu.ExtractPageText(ctx, p)
+		if err != nil {
+			return err
+		}
+
+		b := []byte(pt.Text())
+		ext := "txt"
+
+		if asJSON {
+			b, err = json.MarshalIndent(pt, "", "  ")
+			if err != nil {
+				return err
+			}
+			ext = "json"
+		}
+
+		fileName := fmt.Sprintf("%s/%d.%s", ctx.Write.DirName, p, ext)
+
+		err = ioutil.WriteFile(fileName, b, os.ModePerm)
+		if err != nil {
+			return err
+		}
+
+	}
+
+	return nil
+}
+
+// ExtractText dumps the text of selected pages into dirOut, one file per page.
+func ExtractText(cmd *Command) ([]string, error) {
+
+	fileIn := *cmd.InFile
+	dirOut := *cmd.OutDir
+	pageSelection := cmd.PageSelection
+	config := cmd.Config
+
+	fromStart := time.Now()
+
+	fmt.Printf("extracting text from %s into %s ...\n", fileIn, dirOut)
+
+	ctx, durRead, durVal, durOpt, err := readValidateAndOptimize(fileIn, config, fromStart)
+	if err != nil {
+		return nil, err
+	}
+
+	fromWrite := time.Now()
+
+	pages, err := pagesForPageSelection(ctx.PageCount, pageSelection)
+	if err != nil {
+		return nil, err
+	}
+
+	ensureSelectedPages(ctx, &pages)
+
+	ctx.Write.DirName = dirOut
+	err = doExtractText(ctx, pages, cmd.JSON)
+	if err != nil {
+		return nil, err
+	}
+
+	durWrite := time.Since(fromWrite).Seconds()
+	durTotal := time.Since(fromStart).Seconds()
+
+	log.Stats.Printf("XRefTable:\n%s\n", ctx)
+	log.Stats.Println("Timing:")
+	log.Stats.Printf("read                 : %6.3fs  %4.1f%%\n", durRead, durRead/durTotal*100)
+	log.Stats.Printf("validate             : %6.3fs  %4.1f%%\n", durVal, durVal/durTotal*100)
+	log.Stats.Printf("optimize             : %6.3fs  %4.1f%%\n", durOpt, durOpt/durTotal*100)
+	log.Stats.Printf("write text           : %6.3fs  %4.1f%%\n", durWrite, durWrite/durTotal*100)
+	log.Stats.Printf("total processing time: %6.3fs\n\n", durTotal)
+
+	return nil, nil
+}
+
+// TrimContext prepares ctx for writing a trimmed version containing all pages selected.
+func TrimContext(ctx *pdfcpu.PDFContext, pageSelection []string) error {
+
+	pages, err := pagesForPageSelection(ctx.PageCount, pageSelection)
+	if err != nil {
+		return err
+	}
+
+	ctx.Write.Command = "Trim"
+	ctx.Write.ExtractPages = pages
+
+	return nil
+}
+
 // Trim generates a trimmed version of fileIn containing all pages selected.
 func Trim(cmd *Command) ([]string, error) {
 
@@ -791,14 +969,11 @@ func Trim(cmd *Command) ([]string, error) {
 
 	fromWrite := time.Now()
 
-	pages, err := pagesForPageSelection(ctx.PageCount, pageSelection)
+	err = TrimContext(ctx, pageSelection)
 	if err != nil {
 		return nil, err
 	}
 
-	ctx.Write.Command = "Trim"
-	ctx.Write.ExtractPages = pages
-
 	dirName, fileName := filepath.Split(fileOut)
 	ctx.Write.DirName = dirName
 	ctx.Write.FileName = fileName
@@ -1098,6 +1273,19 @@ func AddPermissions(fileIn string, config *pdfcpu.Configuration) error {
 	return nil
 }
 
+// AddWatermarksContext adds watermarks to all pages selected of a PDFContext.
+func AddWatermarksContext(ctx *pdfcpu.PDFContext, pageSelection []string, wm *pdfcpu.Watermark) error {
+
+	pages, err := pagesForPageSelection(ctx.PageCount, pageSelection)
+	if err != nil {
+		return err
+	}
+
+	ensureSelectedPages(ctx, &pages)
+
+	return pdfcpu.AddWatermarks(ctx.XRefTable, pages, wm)
+}
+
 // AddWatermarks adds watermarks to all pages selected.
 func AddWatermarks(cmd *Command) ([]string, error) {
 
@@ -1118,19 +1306,79 @@ func AddWatermarks(cmd *Command) ([]string, error) {
 
 	from := time.Now()
 
-	pages, err := pagesForPageSelection(ctx.PageCount, pageSelection)
+	err = AddWatermarksContext(ctx, pageSelection, wm)
+	if err != nil {
+		return nil, err
+	}
+
+	durStamp := time.Since(from).Seconds()
+
+	fromWrite := time.Now()
+
+	dirName, fileName := filepath.Split(fileOut)
+	ctx.Write.DirName = dirName
+	ctx.Write.FileName = fileName
+
+	err = Write(ctx)
 	if err != nil {
 		return nil, err
 	}
 
+	durWrite := time.Since(fromWrite).Seconds()
+	durTotal := time.Since(fromStart).Seconds()
+
+	log.Stats.Printf("XRefTable:\n%s\n", ctx)
+	log.Stats.Println("Timing:")
+	log.Stats.Printf("read                 : %6.3fs  %4.1f%%\n", durRead, durRead/durTotal*100)
+	log.Stats.Printf("validate             : %6.3fs  %4.1f%%\n", durVal, durVal/durTotal*100)
+	log.Stats.Printf("optimize             : %6.3fs  %4.1f%%\n", durOpt, durOpt/durTotal*100)
+	log.Stats.Printf("watermark            : %6.3fs  %4.1f%%\n", durStamp, durStamp/durTotal*100)
+	log.Stats.Printf("write                : %6.3fs  %4.1f%%\n", durWrite, durWrite/durTotal*100)
+	log.Stats.Printf("total processing time: %6.3fs\n\n", durTotal)
+	ctx.Read.LogStats(ctx.Optimized)
+	ctx.Write.LogStats()
+
+	return nil, nil
+}
+
+// RedactContext removes the content within rects and within Redact annotations from the selected pages of ctx.
+func RedactContext(ctx *pdfcpu.PDFContext, pageSelection []string, rects []types.Rectangle) error {
+
+	pages, err := pagesForPageSelection(ctx.PageCount, pageSelection)
+	if err != nil {
+		return err
+	}
+
 	ensureSelectedPages(ctx, &pages)
 
-	err = pdfcpu.AddWatermarks(ctx.XRefTable, pages, wm)
+	return pdfcpu.Redact(ctx.XRefTable, pages, rects)
+}
+
+// Redact removes the content within rects and within Redact annotations from all pages selected.
+// The result is always written as a complete file since an incremental update would preserve the removed content.
+func Redact(cmd *Command) ([]string, error) {
+
+	fileIn := *cmd.InFile
+	fileOut := *cmd.OutFile
+	config := cmd.Config
+
+	fromStart := time.Now()
+
+	ctx, durRead, durVal, durOpt, err := readValidateAndOptimize(fileIn, config, fromStart)
 	if err != nil {
 		return nil, err
 	}
 
-	durStamp := time.Since(from).Seconds()
+	fmt.Printf("redacting %s ...\n", fileIn)
+
+	from := time.Now()
+
+	err = RedactContext(ctx, cmd.PageSelection, cmd.Rects)
+	if err != nil {
+		return nil, err
+	}
+
+	durRedact := time.Since(from).Seconds()
 
 	fromWrite := time.Now()
 
@@ -1151,7 +1399,7 @@ func AddWatermarks(cmd *Command) ([]string, error) {
 	log.Stats.Printf("read                 : %6.3fs  %4.1f%%\n", durRead, durRead/durTotal*100)
 	log.Stats.Printf("validate             : %6.3fs  %4.1f%%\n", durVal, durVal/durTotal*100)
 	log.Stats.Printf("optimize             : %6.3fs  %4.1f%%\n", durOpt, durOpt/durTotal*100)
-	log.Stats.Printf("watermark            : %6.3fs  %4.1f%%\n", durStamp, durStamp/durTotal*100)
+	log.Stats.Printf("redact               : %6.3fs  %4.1f%%\n", durRedact, durRedact/durTotal*100)
 	log.Stats.Printf("write                : %6.3fs  %4.1f%%\n", durWrite, durWrite/durTotal*100)
 	log.Stats.Printf("total processing time: %6.3fs\n\n", durTotal)
 	ctx.Read.LogStats(ctx.Optimized)
@@ -1159,3 +1407,513 @@ func AddWatermarks(cmd *Command) ([]string, error) {
 
 	return nil, nil
 }
+
+// SignContext digitally signs the PDF contained in ctx and writes the signed PDF to w.
+// ctx needs to be read by ReadContext and must not have been optimized.
+func SignContext(ctx *pdfcpu.PDFContext, sig *pdfcpu.Signature, w io.Writer) error {
+
+	err := pdfcpu.Sign(ctx, sig, w)
+	if err != nil {
+		return errors.Wrap(err, "Sign failed.")
+	}
+
+	return nil
+}
+
+// Sign digitally signs fileIn and writes the result to fileOut.
+func Sign(cmd *Command) ([]string, error) {
+
+	fileIn := *cmd.InFile
+	fileOut := *cmd.OutFile
+	config := cmd.Config
+
+	fromStart := time.Now()
+
+	// The signature gets appended to the original file which therefore stays in memory.
+	buf, err := ioutil.ReadFile(fileIn)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, err := ReadContext(bytes.NewReader(buf), int64(len(buf)), config)
+	if err != nil {
+		return nil, err
+	}
+	ctx.Read.FileName = fileIn
+
+	durRead := time.Since(fromStart).Seconds()
+
+	fromVal := time.Now()
+
+	err = ValidateContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	durVal := time.Since(fromVal).Seconds()
+
+	fmt.Printf("signing %s ...\n", fileIn)
+
+	from := time.Now()
+
+	// fileOut may be fileIn in which case the signature gets appended in place.
+	err = pdfcpu.SignFile(ctx, cmd.Signature, fileOut)
+	if err != nil {
+		return nil, errors.Wrap(err, "Sign failed.")
+	}
+
+	durSign := time.Since(from).Seconds()
+	durTotal := time.Since(fromStart).Seconds()
+
+	log.Stats.Printf("XRefTable:\n%s\n", ctx)
+	log.Stats.Println("Timing:")
+	log.Stats.Printf("read                 : %6.3fs  %4.1f%%\n", durRead, durRead/durTotal*100)
+	log.Stats.Printf("validate             : %6.3fs  %4.1f%%\n", durVal, durVal/durTotal*100)
+	log.Stats.Printf("sign & write         : %6.3fs  %4.1f%%\n", durSign, durSign/durTotal*100)
+	log.Stats.Printf("total processing time: %6.3fs\n\n", durTotal)
+
+	return nil, nil
+}
+
+// VerifySignatures verifies the digital signatures of fileIn against config.TrustedCerts
+// and returns a report for each signature.
+func VerifySignatures(fileIn string, config *pdfcpu.Configuration) ([]string, error) {
+
+	fromStart := time.Now()
+
+	// The signed byte ranges refer to the original file which therefore stays in memory.
+	buf, err := ioutil.ReadFile(fileIn)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, err := ReadContext(bytes.NewReader(buf), int64(len(buf)), config)
+	if err != nil {
+		return nil, err
+	}
+	ctx.Read.FileName = fileIn
+
+	durRead := time.Since(fromStart).Seconds()
+
+	fromVal := time.Now()
+
+	err = ValidateContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	durVal := time.Since(fromVal).Seconds()
+
+	fromVerify := time.Now()
+
+	results, err := pdfcpu.VerifySignatures(ctx, config.TrustedCerts)
+	if err != nil {
+		return nil, errors.Wrap(err, "VerifySignatures failed.")
+	}
+
+	var list []string
+	for _, sr := range results {
+		list = append(list, sr.String())
+	}
+
+	if len(list) == 0 {
+		list = append(list, "no signatures found")
+	}
+
+	durVerify := time.Since(fromVerify).Seconds()
+	durTotal := time.Since(fromStart).Seconds()
+
+	log.Stats.Printf("XRefTable:\n%s\n", ctx)
+	log.Stats.Println("Timing:")
+	log.Stats.Printf("read                 : %6.3fs  %4.1f%%\n", durRead, durRead/durTotal*100)
+	log.Stats.Printf("validate             : %6.3fs  %4.1f%%\n", durVal, durVal/durTotal*100)
+	log.Stats.Printf("verify signatures    : %6.3fs  %4.1f%%\n", durVerify, durVerify/durTotal*100)
+	log.Stats.Printf("total processing time: %6.3fs\n\n", durTotal)
+
+	return list, nil
+}
+
+func revisions(f *os.File, config *pdfcpu.Configuration) ([]*pdfcpu.Revision, error) {
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	return pdfcpu.Revisions(f, fi.Size(), config)
+}
+
+// ListRevisions returns the revision history of a PDF file, one entry per incremental update.
+func ListRevisions(fileIn string, config *pdfcpu.Configuration) ([]string, error) {
+
+	fromStart := time.Now()
+
+	f, err := os.Open(fileIn)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	revs, err := revisions(f, config)
+	if err != nil {
+		return nil, errors.Wrap(err, "ListRevisions failed.")
+	}
+
+	var list []string
+	for _, r := range revs {
+		list = append(list, r.String())
+	}
+
+	durTotal := time.Since(fromStart).Seconds()
+	log.Stats.Printf("total processing time: %6.3fs\n\n", durTotal)
+
+	return list, nil
+}
+
+// ExtractRevision writes fileIn as it existed at a specific revision to fileOut.
+func ExtractRevision(fileIn, fileOut string, revision int, config *pdfcpu.Configuration) error {
+
+	fromStart := time.Now()
+
+	if fileIn == fileOut {
+		return errors.New("ExtractRevision: inFile and outFile must differ")
+	}
+
+	f, err := os.Open(fileIn)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	revs, err := revisions(f, config)
+	if err != nil {
+		return errors.Wrap(err, "ExtractRevision failed.")
+	}
+
+	if revision < 1 || revision > len(revs) {
+		return errors.Errorf("ExtractRevision: %s has %d revisions", fileIn, len(revs))
+	}
+
+	fmt.Printf("writing revision %d of %s to %s ...\n", revision, fileIn, fileOut)
+
+	fromWrite := time.Now()
+
+	out, err := os.Create(fileOut)
+	if err != nil {
+		return err
+	}
+
+	err = pdfcpu.WriteRevision(f, revs[revision-1], out)
+	if err != nil {
+		out.Close()
+		return errors.Wrap(err, "ExtractRevision failed.")
+	}
+
+	err = out.Close()
+	if err != nil {
+		return err
+	}
+
+	durWrite := time.Since(fromWrite).Seconds()
+
+	fromVal := time.Now()
+
+	// A revision is a prefix of the file which needs to be a complete document on its own.
+	ctx, err := Read(fileOut, config)
+	if err == nil {
+		err = ValidateContext(ctx)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "ExtractRevision: revision %d is not a valid document", revision)
+	}
+
+	durVal := time.Since(fromVal).Seconds()
+	durTotal := time.Since(fromStart).Seconds()
+
+	log.Stats.Println("Timing:")
+	log.Stats.Printf("write                : %6.3fs  %4.1f%%\n", durWrite, durWrite/durTotal*100)
+	log.Stats.Printf("validate             : %6.3fs  %4.1f%%\n", durVal, durVal/durTotal*100)
+	log.Stats.Printf("total processing time: %6.3fs\n\n", durTotal)
+
+	return nil
+}
+
+// ListBookmarks returns the outline tree of fileIn, one outline item per entry indented by nesting level.
+func ListBookmarks(fileIn string, config *pdfcpu.Configuration) ([]string, error) {
+
+	fromStart := time.Now()
+
+	ctx, durRead, durVal, durOpt, err := readValidateAndOptimize(fileIn, config, fromStart)
+	if err != nil {
+		return nil, err
+	}
+
+	fromList := time.Now()
+
+	list, err := pdfcpu.BookmarkList(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	durList := time.Since(fromList).Seconds()
+	durTotal := time.Since(fromStart).Seconds()
+
+	log.Stats.Printf("XRefTable:\n%s\n", ctx)
+	log.Stats.Println("Timing:")
+	log.Stats.Printf("read                 : %6.3fs  %4.1f%%\n", durRead, durRead/durTotal*100)
+	log.Stats.Printf("validate             : %6.3fs  %4.1f%%\n", durVal, durVal/durTotal*100)
+	log.Stats.Printf("optimize             : %6.3fs  %4.1f%%\n", durOpt, durOpt/durTotal*100)
+	log.Stats.Printf("list bookmarks       : %6.3fs  %4.1f%%\n", durList, durList/durTotal*100)
+	log.Stats.Printf("total processing time: %6.3fs\n\n", durTotal)
+
+	return list, nil
+}
+
+// ExportBookmarks writes the outline tree of fileIn as JSON to fileJSON.
+func ExportBookmarks(fileIn, fileJSON string, config *pdfcpu.Configuration) error {
+
+	fromStart := time.Now()
+
+	ctx, durRead, durVal, durOpt, err := readValidateAndOptimize(fileIn, config, fromStart)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("writing %s ...\n", fileJSON)
+
+	fromWrite := time.Now()
+
+	bms, err := pdfcpu.Bookmarks(ctx)
+	if err != nil {
+		return err
+	}
+
+	b, err := json.MarshalIndent(bms, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	err = ioutil.WriteFile(fileJSON, b, os.ModePerm)
+	if err != nil {
+		return err
+	}
+
+	durWrite := time.Since(fromWrite).Seconds()
+	durTotal := time.Since(fromStart).Seconds()
+
+	log.Stats.Printf("XRefTable:\n%s\n", ctx)
+	log.Stats.Println("Timing:")
+	log.Stats.Printf("read                 : %6.3fs  %4.1f%%\n", durRead, durRead/durTotal*100)
+	log.Stats.Printf("validate             : %6.3fs  %4.1f%%\n", durVal, durVal/durTotal*100)
+	log.Stats.Printf("optimize             : %6.3fs  %4.1f%%\n", durOpt, durOpt/durTotal*100)
+	log.Stats.Printf("export bookmarks     : %6.3fs  %4.1f%%\n", durWrite, durWrite/durTotal*100)
+	log.Stats.Printf("total processing time: %6.3fs\n\n", durTotal)
+
+	return nil
+}
+
+// ImportBookmarks replaces the outline tree of fileIn by the outline tree defined in fileJSON and writes the result to fileOut.
+func ImportBookmarks(fileIn, fileJSON, fileOut string, config *pdfcpu.Configuration) error {
+
+	fromStart := time.Now()
+
+	b, err := ioutil.ReadFile(fileJSON)
+	if err != nil {
+		return err
+	}
+
+	var bms []pdfcpu.Bookmark
+
+	err = json.Unmarshal(b, &bms)
+	if err != nil {
+		return errors.Wrapf(err, "ImportBookmarks: %s", fileJSON)
+	}
+
+	ctx, durRead, durVal, durOpt, err := readValidateAndOptimize(fileIn, config, fromStart)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("importing bookmarks into %s ...\n", fileIn)
+
+	from := time.Now()
+
+	err = pdfcpu.AddBookmarks(ctx, bms)
+	if err != nil {
+		return err
+	}
+
+	durImport := time.Since(from).Seconds()
+
+	fromWrite := time.Now()
+
+	dirName, fileName := filepath.Split(fileOut)
+	ctx.Write.DirName = dirName
+	ctx.Write.FileName = fileName
+
+	err = Write(ctx)
+	if err != nil {
+		return err
+	}
+
+	durWrite := time.Since(fromWrite).Seconds()
+	durTotal := time.Since(fromStart).Seconds()
+
+	log.Stats.Printf("XRefTable:\n%s\n", ctx)
+	log.Stats.Println("Timing:")
+	log.Stats.Printf("read                 : %6.3fs  %4.1f%%\n", durRead, durRead/durTotal*100)
+	log.Stats.Printf("validate             : %6.3fs  %4.1f%%\n", durVal, durVal/durTotal*100)
+	log.Stats.Printf("optimize             : %6.3fs  %4.1f%%\n", durOpt, durOpt/durTotal*100)
+	log.Stats.Printf("import bookmarks     : %6.3fs  %4.1f%%\n", durImport, durImport/durTotal*100)
+	log.Stats.Printf("write                : %6.3fs  %4.1f%%\n", durWrite, durWrite/durTotal*100)
+	log.Stats.Printf("total processing time: %6.3fs\n\n", durTotal)
+	ctx.Read.LogStats(ctx.Optimized)
+	ctx.Write.LogStats()
+
+	return nil
+}
+
+// RemoveBookmarks deletes the outline tree of fileIn and writes the result to fileOut.
+func RemoveBookmarks(fileIn, fileOut string, config *pdfcpu.Configuration) error {
+
+	fromStart := time.Now()
+
+	ctx, durRead, durVal, durOpt, err := readValidateAndOptimize(fileIn, config, fromStart)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("removing bookmarks from %s ...\n", fileIn)
+
+	from := time.Now()
+
+	ok, err := pdfcpu.RemoveBookmarks(ctx)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		fmt.Println("no bookmarks removed.")
+		return nil
+	}
+
+	durRemove := time.Since(from).Seconds()
+
+	fromWrite := time.Now()
+
+	dirName, fileName := filepath.Split(fileOut)
+	ctx.Write.DirName = dirName
+	ctx.Write.FileName = fileName
+
+	err = Write(ctx)
+	if err != nil {
+		return err
+	}
+
+	durWrite := time.Since(fromWrite).Seconds()
+	durTotal := time.Since(fromStart).Seconds()
+
+	log.Stats.Printf("XRefTable:\n%s\n", ctx)
+	log.Stats.Println("Timing:")
+	log.Stats.Printf("read                 : %6.3fs  %4.1f%%\n", durRead, durRead/durTotal*100)
+	log.Stats.Printf("validate             : %6.3fs  %4.1f%%\n", durVal, durVal/durTotal*100)
+	log.Stats.Printf("optimize             : %6.3fs  %4.1f%%\n", durOpt, durOpt/durTotal*100)
+	log.Stats.Printf("remove bookmarks     : %6.3fs  %4.1f%%\n", durRemove, durRemove/durTotal*100)
+	log.Stats.Printf("write                : %6.3fs  %4.1f%%\n", durWrite, durWrite/durTotal*100)
+	log.Stats.Printf("total processing time: %6.3fs\n\n", durTotal)
+	ctx.Read.LogStats(ctx.Optimized)
+	ctx.Write.LogStats()
+
+	return nil
+}
+
+// ListFormFields returns the fields of the interactive form of fileIn, one terminal field per entry.
+func ListFormFields(fileIn string, config *pdfcpu.Configuration) ([]string, error) {
+
+	fromStart := time.Now()
+
+	ctx, durRead, durVal, durOpt, err := readValidateAndOptimize(fileIn, config, fromStart)
+	if err != nil {
+		return nil, err
+	}
+
+	fromList := time.Now()
+
+	list, err := pdfcpu.FormFieldList(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	durList := time.Since(fromList).Seconds()
+	durTotal := time.Since(fromStart).Seconds()
+
+	log.Stats.Printf("XRefTable:\n%s\n", ctx)
+	log.Stats.Println("Timing:")
+	log.Stats.Printf("read                 : %6.3fs  %4.1f%%\n", durRead, durRead/durTotal*100)
+	log.Stats.Printf("validate             : %6.3fs  %4.1f%%\n", durVal, durVal/durTotal*100)
+	log.Stats.Printf("optimize             : %6.3fs  %4.1f%%\n", durOpt, durOpt/durTotal*100)
+	log.Stats.Printf("list form fields     : %6.3fs  %4.1f%%\n", durList, durList/durTotal*100)
+	log.Stats.Printf("total processing time: %6.3fs\n\n", durTotal)
+
+	return list, nil
+}
+
+// FillForm sets the form field values defined in fileJSON, regenerates the field appearances and writes the result to fileOut.
+func FillForm(fileIn, fileJSON, fileOut string, config *pdfcpu.Configuration) error {
+
+	fromStart := time.Now()
+
+	b, err := ioutil.ReadFile(fileJSON)
+	if err != nil {
+		return err
+	}
+
+	var values map[string]interface{}
+
+	err = json.Unmarshal(b, &values)
+	if err != nil {
+		return errors.Wrapf(err, "FillForm: %s", fileJSON)
+	}
+
+	ctx, durRead, durVal, durOpt, err := readValidateAndOptimize(fileIn, config, fromStart)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("filling form fields of %s ...\n", fileIn)
+
+	from := time.Now()
+
+	err = pdfcpu.FillForm(ctx, values)
+	if err != nil {
+		return err
+	}
+
+	durFill := time.Since(from).Seconds()
+
+	fromWrite := time.Now()
+
+	dirName, fileName := filepath.Split(fileOut)
+	ctx.Write.DirName = dirName
+	ctx.Write.FileName = fileName
+
+	err = Write(ctx)
+	if err != nil {
+		return err
+	}
+
+	durWrite := time.Since(fromWrite).Seconds()
+	durTotal := time.Since(fromStart).Seconds()
+
+	log.Stats.Printf("XRefTable:\n%s\n", ctx)
+	log.Stats.Println("Timing:")
+	log.Stats.Printf("read                 : %6.3fs  %4.1f%%\n", durRead, durRead/durTotal*100)
+	log.Stats.Printf("validate             : %6.3fs  %4.1f%%\n", durVal, durVal/durTotal*100)
+	log.Stats.Printf("optimize             : %6.3fs  %4.1f%%\n", durOpt, durOpt/durTotal*100)
+	log.Stats.Printf("fill form            : %6.3fs  %4.1f%%\n", durFill, durFill/durTotal*100)
+	log.Stats.Printf("write                : %6.3fs  %4.1f%%\n", durWrite, durWrite/durTotal*100)
+	log.Stats.Printf("total processing time: %6.3fs\n\n", durTotal)
+	ctx.Read.LogStats(ctx.Optimized)
+	ctx.Write.LogStats()
+
+	return nil
+}
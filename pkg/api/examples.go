@@ -17,9 +17,11 @@ limitations under the License.
 package api
 
 import (
+	"bytes"
 	"fmt"
 
 	"github.com/hhrutter/pdfcpu/pkg/pdfcpu"
+	"github.com/hhrutter/pdfcpu/pkg/types"
 )
 
 func exampleProcessValidate() {
@@ -348,3 +350,63 @@ func exampleProcessWatermark() {
 	}
 
 }
+
+func exampleProcessSign() {
+
+	key, certs, err := pdfcpu.ReadPKCS12("signer.p12", "password")
+	if err != nil {
+		return
+	}
+
+	// Sign with a visible signature on page 1.
+	sig := &pdfcpu.Signature{
+		Key:    key,
+		Certs:  certs,
+		Reason: "Approved",
+		PageNr: 1,
+		Rect:   types.NewRectangle(400, 50, 550, 100),
+	}
+
+	config := pdfcpu.NewDefaultConfiguration()
+
+	_, err = Process(SignCommand("in.pdf", "out.pdf", sig, config))
+	if err != nil {
+		return
+	}
+
+}
+
+func exampleOptimizeInMemory(buf []byte) ([]byte, error) {
+
+	config := pdfcpu.NewDefaultConfiguration()
+
+	// Set optional password(s).
+	//config.UserPW = "upw"
+	//config.OwnerPW = "opw"
+
+	// For encryption set config.Mode = pdfcpu.ENCRYPT before reading.
+
+	ctx, err := ReadContext(bytes.NewReader(buf), int64(len(buf)), config)
+	if err != nil {
+		return nil, err
+	}
+
+	err = ValidateContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	err = OptimizeContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+
+	err = WriteContext(ctx, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return out.Bytes(), nil
+}
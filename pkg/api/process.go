@@ -18,6 +18,7 @@ package api
 
 import (
 	"github.com/hhrutter/pdfcpu/pkg/pdfcpu"
+	"github.com/hhrutter/pdfcpu/pkg/types"
 	"github.com/pkg/errors"
 )
 
@@ -34,6 +35,11 @@ type Command struct {
 	PWOld         *string               //    -         -        -      -       -      -      -       -       -      -       -        -         *          *       -     -       -
 	PWNew         *string               //    -         -        -      -       -      -      -       -       -      -       -        -         *          *       -     -       -
 	Watermark     *pdfcpu.Watermark     //    -         -        -      -       -      -      -       -       -      -       -        -         -          -       -     -       -
+	Signature     *pdfcpu.Signature     // SIGN only
+	Revision      int                   // EXTRACTREVISION only
+	JSON          bool                  // EXTRACTTEXT only
+	Rects         []types.Rectangle     // REDACT only
+	JSONFile      *string               // EXPORTBOOKMARKS, IMPORTBOOKMARKS, FILLFORMFIELDS only
 }
 
 // Process executes a pdfcpu command.
@@ -57,8 +63,10 @@ func Process(cmd *Command) (out []string, err error) {
 		pdfcpu.EXTRACTFONTS:       ExtractFonts,
 		pdfcpu.EXTRACTPAGES:       ExtractPages,
 		pdfcpu.EXTRACTCONTENT:     ExtractContent,
+		pdfcpu.EXTRACTTEXT:        ExtractText,
 		pdfcpu.TRIM:               Trim,
 		pdfcpu.ADDWATERMARKS:      AddWatermarks,
+		pdfcpu.REDACT:             Redact,
 		pdfcpu.LISTATTACHMENTS:    processAttachments,
 		pdfcpu.ADDATTACHMENTS:     processAttachments,
 		pdfcpu.REMOVEATTACHMENTS:  processAttachments,
@@ -69,6 +77,16 @@ func Process(cmd *Command) (out []string, err error) {
 		pdfcpu.CHANGEOPW:          processEncryption,
 		pdfcpu.LISTPERMISSIONS:    processPermissions,
 		pdfcpu.ADDPERMISSIONS:     processPermissions,
+		pdfcpu.SIGN:               Sign,
+		pdfcpu.VERIFYSIGNATURES:   processSignatures,
+		pdfcpu.LISTREVISIONS:      processRevisions,
+		pdfcpu.EXTRACTREVISION:    processRevisions,
+		pdfcpu.LISTBOOKMARKS:      processBookmarks,
+		pdfcpu.EXPORTBOOKMARKS:    processBookmarks,
+		pdfcpu.IMPORTBOOKMARKS:    processBookmarks,
+		pdfcpu.REMOVEBOOKMARKS:    processBookmarks,
+		pdfcpu.LISTFORMFIELDS:     processForm,
+		pdfcpu.FILLFORMFIELDS:     processForm,
 	} {
 		if cmd.Mode == k {
 			return v(cmd)
@@ -156,6 +174,17 @@ func ExtractContentCommand(pdfFileNameIn, dirNameOut string, pageSelection []str
 		Config:        config}
 }
 
+// ExtractTextCommand creates a new command to extract page text as plain text or JSON.
+func ExtractTextCommand(pdfFileNameIn, dirNameOut string, pageSelection []string, json bool, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:          pdfcpu.EXTRACTTEXT,
+		InFile:        &pdfFileNameIn,
+		OutDir:        &dirNameOut,
+		PageSelection: pageSelection,
+		JSON:          json,
+		Config:        config}
+}
+
 // TrimCommand creates a new command to trim the pages of a file.
 func TrimCommand(pdfFileNameIn, pdfFileNameOut string, pageSelection []string, config *pdfcpu.Configuration) *Command {
 	// A slice parameter may be called with nil => empty slice.
@@ -324,3 +353,165 @@ func AddWatermarksCommand(pdfFileNameIn, pdfFileNameOut string, pageSelection []
 		Watermark:     wm,
 		Config:        config}
 }
+
+// RedactCommand creates a new command to remove the content within rects and within Redact annotations from selected pages.
+func RedactCommand(pdfFileNameIn, pdfFileNameOut string, pageSelection []string, rects []types.Rectangle, config *pdfcpu.Configuration) *Command {
+
+	return &Command{
+		Mode:          pdfcpu.REDACT,
+		InFile:        &pdfFileNameIn,
+		OutFile:       &pdfFileNameOut,
+		PageSelection: pageSelection,
+		Rects:         rects,
+		Config:        config}
+}
+
+// SignCommand creates a new command to digitally sign a file.
+func SignCommand(pdfFileNameIn, pdfFileNameOut string, sig *pdfcpu.Signature, config *pdfcpu.Configuration) *Command {
+
+	return &Command{
+		Mode:      pdfcpu.SIGN,
+		InFile:    &pdfFileNameIn,
+		OutFile:   &pdfFileNameOut,
+		Signature: sig,
+		Config:    config}
+}
+
+// VerifySignaturesCommand creates a new command to verify the digital signatures of a file.
+func VerifySignaturesCommand(pdfFileNameIn string, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:   pdfcpu.VERIFYSIGNATURES,
+		InFile: &pdfFileNameIn,
+		Config: config}
+}
+
+func processSignatures(cmd *Command) (out []string, err error) {
+
+	switch cmd.Mode {
+
+	case pdfcpu.VERIFYSIGNATURES:
+		out, err = VerifySignatures(*cmd.InFile, cmd.Config)
+	}
+
+	return out, err
+}
+
+// ListRevisionsCommand creates a new command to list the revisions of a file.
+func ListRevisionsCommand(pdfFileNameIn string, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:   pdfcpu.LISTREVISIONS,
+		InFile: &pdfFileNameIn,
+		Config: config}
+}
+
+// ExtractRevisionCommand creates a new command to extract a file as it existed at a specific revision.
+func ExtractRevisionCommand(pdfFileNameIn, pdfFileNameOut string, revision int, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:     pdfcpu.EXTRACTREVISION,
+		InFile:   &pdfFileNameIn,
+		OutFile:  &pdfFileNameOut,
+		Revision: revision,
+		Config:   config}
+}
+
+func processRevisions(cmd *Command) (out []string, err error) {
+
+	switch cmd.Mode {
+
+	case pdfcpu.LISTREVISIONS:
+		out, err = ListRevisions(*cmd.InFile, cmd.Config)
+
+	case pdfcpu.EXTRACTREVISION:
+		err = ExtractRevision(*cmd.InFile, *cmd.OutFile, cmd.Revision, cmd.Config)
+	}
+
+	return out, err
+}
+
+// ListBookmarksCommand creates a new command to list the outline tree of a file.
+func ListBookmarksCommand(pdfFileNameIn string, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:   pdfcpu.LISTBOOKMARKS,
+		InFile: &pdfFileNameIn,
+		Config: config}
+}
+
+// ExportBookmarksCommand creates a new command to export the outline tree of a file as JSON.
+func ExportBookmarksCommand(pdfFileNameIn, jsonFileNameOut string, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:     pdfcpu.EXPORTBOOKMARKS,
+		InFile:   &pdfFileNameIn,
+		JSONFile: &jsonFileNameOut,
+		Config:   config}
+}
+
+// ImportBookmarksCommand creates a new command to replace the outline tree of a file by an outline tree read from JSON.
+func ImportBookmarksCommand(pdfFileNameIn, jsonFileNameIn, pdfFileNameOut string, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:     pdfcpu.IMPORTBOOKMARKS,
+		InFile:   &pdfFileNameIn,
+		JSONFile: &jsonFileNameIn,
+		OutFile:  &pdfFileNameOut,
+		Config:   config}
+}
+
+// RemoveBookmarksCommand creates a new command to remove the outline tree of a file.
+func RemoveBookmarksCommand(pdfFileNameIn, pdfFileNameOut string, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:    pdfcpu.REMOVEBOOKMARKS,
+		InFile:  &pdfFileNameIn,
+		OutFile: &pdfFileNameOut,
+		Config:  config}
+}
+
+func processBookmarks(cmd *Command) (out []string, err error) {
+
+	switch cmd.Mode {
+
+	case pdfcpu.LISTBOOKMARKS:
+		out, err = ListBookmarks(*cmd.InFile, cmd.Config)
+
+	case pdfcpu.EXPORTBOOKMARKS:
+		err = ExportBookmarks(*cmd.InFile, *cmd.JSONFile, cmd.Config)
+
+	case pdfcpu.IMPORTBOOKMARKS:
+		err = ImportBookmarks(*cmd.InFile, *cmd.JSONFile, *cmd.OutFile, cmd.Config)
+
+	case pdfcpu.REMOVEBOOKMARKS:
+		err = RemoveBookmarks(*cmd.InFile, *cmd.OutFile, cmd.Config)
+	}
+
+	return out, err
+}
+
+// ListFormFieldsCommand creates a new command to list the fields of the interactive form of a file.
+func ListFormFieldsCommand(pdfFileNameIn string, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:   pdfcpu.LISTFORMFIELDS,
+		InFile: &pdfFileNameIn,
+		Config: config}
+}
+
+// FillFormCommand creates a new command to fill the fields of the interactive form of a file with values read from JSON.
+func FillFormCommand(pdfFileNameIn, jsonFileNameIn, pdfFileNameOut string, config *pdfcpu.Configuration) *Command {
+	return &Command{
+		Mode:     pdfcpu.FILLFORMFIELDS,
+		InFile:   &pdfFileNameIn,
+		JSONFile: &jsonFileNameIn,
+		OutFile:  &pdfFileNameOut,
+		Config:   config}
+}
+
+func processForm(cmd *Command) (out []string, err error) {
+
+	switch cmd.Mode {
+
+	case pdfcpu.LISTFORMFIELDS:
+		out, err = ListFormFields(*cmd.InFile, cmd.Config)
+
+	case pdfcpu.FILLFORMFIELDS:
+		err = FillForm(*cmd.InFile, *cmd.JSONFile, *cmd.OutFile, cmd.Config)
+	}
+
+	return out, err
+}
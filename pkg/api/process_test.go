@@ -17,15 +17,25 @@ limitations under the License.
 package api
 
 import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"math/big"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/hhrutter/pdfcpu/pkg/pdfcpu"
+	"github.com/hhrutter/pdfcpu/pkg/types"
 )
 
 var inDir, outDir string
@@ -243,6 +253,81 @@ func TestOptimizeCommandWithCRLF(t *testing.T) {
 
 }
 
+func validateLinearization(fileName string, config *pdfcpu.Configuration, t *testing.T) {
+
+	_, err := Process(ValidateCommand(fileName, config))
+	if err != nil {
+		t.Fatalf("validateLinearization %s: %v\n", fileName, err)
+	}
+
+	ctx := readContextFromFile(fileName, config, t)
+	if !ctx.Read.Linearized {
+		t.Fatalf("validateLinearization %s: not linearized\n", fileName)
+	}
+
+	// Hint tables have to be accurate.
+	ctx.XRefTable.ValidationMode = pdfcpu.ValidationStrict
+
+	err = pdfcpu.ValidateLinearization(ctx)
+	if err != nil {
+		t.Fatalf("validateLinearization %s: %v\n", fileName, err)
+	}
+}
+
+// Linearize all PDFs in testdata and validate the result including hint tables.
+func TestLinearize(t *testing.T) {
+
+	files, err := ioutil.ReadDir(inDir)
+	if err != nil {
+		t.Fatalf("TestLinearize: %v\n", err)
+	}
+
+	config := pdfcpu.NewDefaultConfiguration()
+	config.Linearize = true
+
+	outFile := filepath.Join(outDir, "testLinearized.pdf")
+
+	for _, file := range files {
+		if strings.HasSuffix(file.Name(), "pdf") {
+
+			inFile := filepath.Join(inDir, file.Name())
+
+			_, err = Process(OptimizeCommand(inFile, outFile, config))
+			if err != nil {
+				t.Fatalf("TestLinearize %s: %v\n", file.Name(), err)
+			}
+
+			validateLinearization(outFile, pdfcpu.NewDefaultConfiguration(), t)
+		}
+	}
+
+	// Linearize an encrypted file.
+	encFile := filepath.Join(outDir, "testLinearizedEnc.pdf")
+	outFile = filepath.Join(outDir, "testLinearizedEnc2.pdf")
+
+	config = pdfcpu.NewDefaultConfiguration()
+	config.UserPW = "upw"
+	config.OwnerPW = "opw"
+
+	_, err = Process(EncryptCommand(filepath.Join(inDir, "go.pdf"), encFile, config))
+	if err != nil {
+		t.Fatalf("TestLinearize: %v\n", err)
+	}
+
+	config.Linearize = true
+
+	_, err = Process(OptimizeCommand(encFile, outFile, config))
+	if err != nil {
+		t.Fatalf("TestLinearize: %v\n", err)
+	}
+
+	config = pdfcpu.NewDefaultConfiguration()
+	config.UserPW = "upw"
+	config.OwnerPW = "opw"
+
+	validateLinearization(outFile, config, t)
+}
+
 // Split a test PDF file up into single page PDFs.
 func TestSplitCommand(t *testing.T) {
 
@@ -289,6 +374,363 @@ func TestTrimCommand(t *testing.T) {
 
 }
 
+// outline returns the titles and page numbers of the outline items of a PDF file in tree order.
+// Nested titles are indented by one blank per level.
+func outline(fileName string, t *testing.T) (titles []string, pages []int) {
+
+	ctx, err := Read(fileName, pdfcpu.NewDefaultConfiguration())
+	if err != nil {
+		t.Fatalf("outline: %v\n", err)
+	}
+
+	if err = ValidateContext(ctx); err != nil {
+		t.Fatalf("outline: %v\n", err)
+	}
+
+	pageNrs := map[int]int{}
+	for i := 1; i <= ctx.PageCount; i++ {
+		indRef, err := ctx.PageDictIndRef(i)
+		if err != nil {
+			t.Fatalf("outline: %v\n", err)
+		}
+		pageNrs[indRef.ObjectNumber.Value()] = i
+	}
+
+	rootDict, err := ctx.Catalog()
+	if err != nil {
+		t.Fatalf("outline: %v\n", err)
+	}
+
+	d, err := ctx.DereferenceDict(rootDict.Dict["Outlines"])
+	if err != nil || d == nil {
+		return nil, nil
+	}
+
+	var walk func(indRef *pdfcpu.PDFIndirectRef, indent string)
+
+	walk = func(indRef *pdfcpu.PDFIndirectRef, indent string) {
+		for ; indRef != nil; indRef = d.IndirectRefEntry("Next") {
+			d, err = ctx.DereferenceDict(*indRef)
+			if err != nil {
+				t.Fatalf("outline: %v\n", err)
+			}
+			title, _ := ctx.Dereference(d.Dict["Title"])
+			dest, _ := ctx.DereferenceArray(d.Dict["Dest"])
+			page := 0
+			if dest != nil {
+				page = pageNrs[(*dest)[0].(pdfcpu.PDFIndirectRef).ObjectNumber.Value()]
+			}
+			titles = append(titles, indent+title.(pdfcpu.PDFStringLiteral).Value())
+			pages = append(pages, page)
+			walk(d.IndirectRefEntry("First"), indent+" ")
+			d, _ = ctx.DereferenceDict(*indRef)
+		}
+	}
+
+	walk(d.IndirectRefEntry("First"), "")
+
+	return titles, pages
+}
+
+func TestOutlinesTrimAndMerge(t *testing.T) {
+
+	inFile := filepath.Join(inDir, "T4.pdf")
+	trimFile := filepath.Join(outDir, "T4_trimmed.pdf")
+	outFile := filepath.Join(outDir, "T4_merged.pdf")
+
+	// Section 4 starts on page 11, section 4.2 on page 16.
+	_, err := Process(TrimCommand(inFile, trimFile, []string{"12-16"}, pdfcpu.NewDefaultConfiguration()))
+	if err != nil {
+		t.Fatalf("TestOutlinesTrimAndMerge: %v\n", err)
+	}
+
+	titles, pages := outline(trimFile, t)
+	if len(titles) != 2 || titles[0] != "4 Coding scheme" || titles[1] != " 4.2 Two-dimensional coding scheme" ||
+		pages[0] != 5 || pages[1] != 5 {
+		t.Fatalf("TestOutlinesTrimAndMerge: unexpected trimmed outline %v %v\n", titles, pages)
+	}
+
+	config := pdfcpu.NewDefaultConfiguration()
+	config.MergeBookmarks = true
+
+	_, err = Process(MergeCommand([]string{trimFile, trimFile}, outFile, config))
+	if err != nil {
+		t.Fatalf("TestOutlinesTrimAndMerge: %v\n", err)
+	}
+
+	titles, pages = outline(outFile, t)
+	if len(titles) != 6 || titles[0] != "T4_trimmed" || titles[3] != "T4_trimmed" || titles[5] != "  4.2 Two-dimensional coding scheme" ||
+		pages[0] != 1 || pages[3] != 6 || pages[5] != 10 {
+		t.Fatalf("TestOutlinesTrimAndMerge: unexpected merged outline %v %v\n", titles, pages)
+	}
+}
+
+// linkTargets returns the number of link annotations whose named destination
+// points to a page on the same side of pageNr as the link itself.
+func linkTargets(fileName string, pageNr int, t *testing.T) (ok, total, pageCount int) {
+
+	ctx, err := Read(fileName, pdfcpu.NewDefaultConfiguration())
+	if err != nil {
+		t.Fatalf("linkTargets: %v\n", err)
+	}
+
+	if err = ValidateContext(ctx); err != nil {
+		t.Fatalf("linkTargets: %v\n", err)
+	}
+
+	pageNrs := map[int]int{}
+	for i := 1; i <= ctx.PageCount; i++ {
+		indRef, _ := ctx.PageDictIndRef(i)
+		pageNrs[indRef.ObjectNumber.Value()] = i
+	}
+
+	for i := 1; i <= ctx.PageCount; i++ {
+
+		d, _, _ := ctx.PageDict(i)
+
+		annots, _ := ctx.DereferenceArray(d.Dict["Annots"])
+		if annots == nil {
+			continue
+		}
+
+		for _, o := range *annots {
+
+			annot, _ := ctx.DereferenceDict(o)
+			dest := annot.Dict["Dest"]
+			if a, _ := ctx.DereferenceDict(annot.Dict["A"]); a != nil {
+				dest = a.Dict["D"]
+			}
+
+			var k string
+			switch s := dest.(type) {
+			case pdfcpu.PDFStringLiteral:
+				k = s.Value()
+			case pdfcpu.PDFHexLiteral:
+				b, _ := hex.DecodeString(s.Value())
+				k = string(b)
+			default:
+				continue
+			}
+			total++
+
+			v, found := ctx.Names["Dests"].Value(k)
+			if !found {
+				continue
+			}
+
+			v, _ = ctx.Dereference(v)
+			if d, isDict := v.(pdfcpu.PDFDict); isDict {
+				v, _ = ctx.Dereference(d.Dict["D"])
+			}
+
+			if a, isArray := v.(pdfcpu.PDFArray); isArray {
+				if indRef, isIndRef := a[0].(pdfcpu.PDFIndirectRef); isIndRef {
+					if p := pageNrs[indRef.ObjectNumber.Value()]; p > 0 && (p > pageNr) == (i > pageNr) {
+						ok++
+					}
+				}
+			}
+		}
+	}
+
+	return ok, total, ctx.PageCount
+}
+
+func TestMergeNamedDestinations(t *testing.T) {
+
+	inFile := filepath.Join(inDir, "go-lecture.pdf")
+	outFile := filepath.Join(outDir, "go-lecture_merged.pdf")
+
+	ok, total, pageCount := linkTargets(inFile, 0, t)
+	if total == 0 || ok != total {
+		t.Fatalf("TestMergeNamedDestinations: %d of %d links resolved before merge\n", ok, total)
+	}
+
+	// All destination names collide.
+	_, err := Process(MergeCommand([]string{inFile, inFile}, outFile, pdfcpu.NewDefaultConfiguration()))
+	if err != nil {
+		t.Fatalf("TestMergeNamedDestinations: %v\n", err)
+	}
+
+	ok, total2, _ := linkTargets(outFile, pageCount, t)
+	if total2 != 2*total || ok != total2 {
+		t.Fatalf("TestMergeNamedDestinations: %d of %d links resolved after merge\n", ok, total2)
+	}
+}
+
+// hexDestNames turns the keys of the Dests name tree and every other named destination of a link into hex literals.
+func hexDestNames(ctx *pdfcpu.PDFContext, t *testing.T) {
+
+	toHex := func(o pdfcpu.PDFObject) pdfcpu.PDFObject {
+		if s, ok := o.(pdfcpu.PDFStringLiteral); ok {
+			return pdfcpu.PDFHexLiteral(hex.EncodeToString([]byte(s.Value())))
+		}
+		return o
+	}
+
+	var walk func(o pdfcpu.PDFObject)
+
+	walk = func(o pdfcpu.PDFObject) {
+		d, err := ctx.DereferenceDict(o)
+		if err != nil || d == nil {
+			t.Fatalf("hexDestNames: corrupt name tree %v\n", err)
+		}
+		if kids, _ := ctx.DereferenceArray(d.Dict["Kids"]); kids != nil {
+			for _, kid := range *kids {
+				walk(kid)
+			}
+			return
+		}
+		names, _ := ctx.DereferenceArray(d.Dict["Names"])
+		for i := 0; names != nil && i < len(*names); i += 2 {
+			(*names)[i] = toHex((*names)[i])
+		}
+		d.Delete("Limits")
+		if names != nil {
+			d.Update("Names", *names)
+		}
+	}
+
+	rootDict, _ := ctx.Catalog()
+	namesDict, _ := ctx.DereferenceDict(rootDict.Dict["Names"])
+	walk(namesDict.Dict["Dests"])
+
+	n := 0
+
+	for i := 1; i <= ctx.PageCount; i++ {
+
+		d, _, _ := ctx.PageDict(i)
+
+		annots, _ := ctx.DereferenceArray(d.Dict["Annots"])
+		if annots == nil {
+			continue
+		}
+
+		for _, o := range *annots {
+			annot, _ := ctx.DereferenceDict(o)
+			if n++; n%2 == 0 {
+				continue
+			}
+			if _, found := annot.Find("Dest"); found {
+				annot.Update("Dest", toHex(annot.Dict["Dest"]))
+			}
+			if a, _ := ctx.DereferenceDict(annot.Dict["A"]); a != nil {
+				a.Update("D", toHex(a.Dict["D"]))
+			}
+		}
+	}
+}
+
+func TestMergeHexNamedDestinations(t *testing.T) {
+
+	inFile := filepath.Join(outDir, "go-lecture_hex.pdf")
+	outFile := filepath.Join(outDir, "go-lecture_hex_merged.pdf")
+
+	config := pdfcpu.NewDefaultConfiguration()
+
+	ctx := readContextFromFile(filepath.Join(inDir, "go-lecture.pdf"), config, t)
+	hexDestNames(ctx, t)
+
+	ctx.Write.DirName = outDir + "/"
+	ctx.Write.FileName = filepath.Base(inFile)
+	if err := Write(ctx); err != nil {
+		t.Fatalf("TestMergeHexNamedDestinations: %v\n", err)
+	}
+
+	ok, total, pageCount := linkTargets(inFile, 0, t)
+	if total == 0 || ok != total {
+		t.Fatalf("TestMergeHexNamedDestinations: %d of %d links resolved before merge\n", ok, total)
+	}
+
+	// No destination names collide.
+	otherFile := filepath.Join(inDir, "5116.DCT_Filter.pdf")
+	_, otherTotal, otherPageCount := linkTargets(otherFile, 0, t)
+
+	_, err := Process(MergeCommand([]string{otherFile, inFile}, outFile, pdfcpu.NewDefaultConfiguration()))
+	if err != nil {
+		t.Fatalf("TestMergeHexNamedDestinations: %v\n", err)
+	}
+
+	ok, total2, _ := linkTargets(outFile, otherPageCount, t)
+	if total2 != otherTotal+total || ok != total2 {
+		t.Fatalf("TestMergeHexNamedDestinations: %d of %d links resolved after merge\n", ok, total2)
+	}
+
+	// All destination names collide.
+	_, err = Process(MergeCommand([]string{inFile, inFile}, outFile, pdfcpu.NewDefaultConfiguration()))
+	if err != nil {
+		t.Fatalf("TestMergeHexNamedDestinations: %v\n", err)
+	}
+
+	ok, total2, _ = linkTargets(outFile, pageCount, t)
+	if total2 != 2*total || ok != total2 {
+		t.Fatalf("TestMergeHexNamedDestinations: %d of %d links resolved after merge\n", ok, total2)
+	}
+}
+
+func TestBookmarks(t *testing.T) {
+
+	inFile := filepath.Join(inDir, "T4.pdf")
+	jsonFile := filepath.Join(outDir, "bookmarks.json")
+	outFile := filepath.Join(outDir, "T4_bookmarks.pdf")
+
+	bms := `[{"title": "Kapitel 1 Übersicht", "page": 1, "open": true, "color": [1, 0, 0], "bold": true, "kids": [
+		{"title": "1.1 (Scope)", "page": 3, "view": "XYZ", "params": [0, 792, null], "italic": true}]},
+		{"title": "Kapitel 2", "page": 10, "kids": [{"title": "2.1", "page": 11, "view": "FitH", "params": [500]}]}]`
+
+	err := ioutil.WriteFile(jsonFile, []byte(bms), os.ModePerm)
+	if err != nil {
+		t.Fatalf("TestBookmarks: %v\n", err)
+	}
+
+	config := pdfcpu.NewDefaultConfiguration()
+	config.ValidationMode = pdfcpu.ValidationStrict
+
+	_, err = Process(ImportBookmarksCommand(inFile, jsonFile, outFile, config))
+	if err != nil {
+		t.Fatalf("TestBookmarks: %v\n", err)
+	}
+
+	list, err := Process(ListBookmarksCommand(outFile, config))
+	if err != nil {
+		t.Fatalf("TestBookmarks: %v\n", err)
+	}
+
+	want := []string{"Kapitel 1 Übersicht (page 1)", "  1.1 (Scope) (page 3)", "Kapitel 2 (page 10)", "  2.1 (page 11)"}
+	if strings.Join(list, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("TestBookmarks: unexpected bookmarks %v\n", list)
+	}
+
+	_, err = Process(ExportBookmarksCommand(outFile, jsonFile, config))
+	if err != nil {
+		t.Fatalf("TestBookmarks: %v\n", err)
+	}
+
+	b, err := ioutil.ReadFile(jsonFile)
+	if err != nil {
+		t.Fatalf("TestBookmarks: %v\n", err)
+	}
+
+	var got []pdfcpu.Bookmark
+	if err = json.Unmarshal(b, &got); err != nil {
+		t.Fatalf("TestBookmarks: %v\n", err)
+	}
+
+	if len(got) != 2 || !got[0].Open || !got[0].Bold || got[0].Color[0] != 1 || got[1].Open ||
+		got[0].Kids[0].View != "XYZ" || *got[0].Kids[0].Params[1] != 792 || got[0].Kids[0].Params[2] != nil || !got[0].Kids[0].Italic {
+		t.Fatalf("TestBookmarks: unexpected export %s\n", b)
+	}
+
+	_, err = Process(RemoveBookmarksCommand(outFile, outFile, config))
+	if err != nil {
+		t.Fatalf("TestBookmarks: %v\n", err)
+	}
+
+	if list, err = Process(ListBookmarksCommand(outFile, config)); err != nil || len(list) > 0 {
+		t.Fatalf("TestBookmarks: bookmarks not removed %v %v\n", list, err)
+	}
+}
+
 func TestWatermark(t *testing.T) {
 
 	inFile := filepath.Join(inDir, "Acroforms2.pdf")
@@ -381,608 +823,1890 @@ func TestExtractImagesCommand(t *testing.T) {
 
 }
 
-func TestExtractFontsCommand(t *testing.T) {
+func TestExtractCCITTImages(t *testing.T) {
 
-	cmd := ExtractFontsCommand("", outDir, nil, pdfcpu.NewDefaultConfiguration())
+	inFile := filepath.Join(inDir, "T6.pdf")
+	dir := filepath.Join(outDir, "T6")
 
-	for _, fn := range []string{"5116.DCT_Filter.pdf", "testImage.pdf", "go.pdf"} {
+	err := os.MkdirAll(dir, os.ModePerm)
+	if err != nil {
+		t.Fatalf("TestExtractCCITTImages: %v\n", err)
+	}
 
-		fn = filepath.Join(inDir, fn)
-		cmd.InFile = &fn
+	_, err = Process(ExtractImagesCommand(inFile, dir, nil, pdfcpu.NewDefaultConfiguration()))
+	if err != nil {
+		t.Fatalf("TestExtractCCITTImages: %v\n", err)
+	}
 
-		_, err := Process(cmd)
-		if err != nil {
-			t.Fatalf("TestExtractFontsCommand: %v\n", err)
-		}
+	// All Group 4 images get decoded and written as PNG.
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("TestExtractCCITTImages: %v\n", err)
+	}
 
+	if len(files) == 0 {
+		t.Fatal("TestExtractCCITTImages: no images extracted")
 	}
 
-	inFile := filepath.Join(inDir, "go.pdf")
-	_, err := Process(ExtractFontsCommand(inFile, outDir, []string{"1-3"}, pdfcpu.NewDefaultConfiguration()))
-	if err != nil {
-		t.Fatalf("TestExtractFontsCommand: %v\n", err)
+	for _, f := range files {
+		if filepath.Ext(f.Name()) != ".png" {
+			t.Fatalf("TestExtractCCITTImages: unexpected file %s\n", f.Name())
+		}
 	}
 
 }
 
-func TestExtractContentCommand(t *testing.T) {
+// bilevelImages returns the number of 1 bit per pixel images using filterName.
+func bilevelImages(ctx *pdfcpu.PDFContext, filterName string) int {
 
-	inFile := filepath.Join(inDir, "5116.DCT_Filter.pdf")
+	n := 0
 
-	_, err := Process(ExtractContentCommand(inFile, outDir, nil, pdfcpu.NewDefaultConfiguration()))
-	if err != nil {
-		t.Fatalf("TestExtractContentCommand: %v\n", err)
+	for _, entry := range ctx.Table {
+		sd, ok := entry.Object.(pdfcpu.PDFStreamDict)
+		if !ok || sd.Subtype() == nil || *sd.Subtype() != "Image" {
+			continue
+		}
+		if bpc := sd.IntEntry("BitsPerComponent"); bpc != nil && *bpc == 1 && sd.NameEntry("Filter") != nil && *sd.NameEntry("Filter") == filterName {
+			n++
+		}
 	}
 
+	return n
 }
 
-func TestExtractPagesCommand(t *testing.T) {
+func TestOptimizeBilevelImages(t *testing.T) {
 
-	inFile := filepath.Join(inDir, "TheGoProgrammingLanguageCh1.pdf")
+	inFile := filepath.Join(inDir, "T6.pdf")
+	redactedFile := filepath.Join(outDir, "T6_redacted.pdf")
+	outFile := filepath.Join(outDir, "T6_optimized.pdf")
+	config := pdfcpu.NewDefaultConfiguration()
 
-	_, err := Process(ExtractPagesCommand(inFile, outDir, []string{"1"}, pdfcpu.NewDefaultConfiguration()))
+	// Redaction leaves Flate encoded bilevel images behind.
+	rects := []types.Rectangle{types.NewRectangle(0, 0, 300, 300)}
+
+	_, err := Process(RedactCommand(inFile, redactedFile, nil, rects, config))
 	if err != nil {
-		t.Fatalf("TestExtractPagesCommand: %v\n", err)
+		t.Fatalf("TestOptimizeBilevelImages: %v\n", err)
 	}
 
-}
+	ctx := readContextFromFile(redactedFile, config, t)
+	if bilevelImages(ctx, "FlateDecode") == 0 {
+		t.Fatal("TestOptimizeBilevelImages: no Flate encoded bilevel images")
+	}
 
-func TestEncryptUPWOnly(t *testing.T) {
+	config = pdfcpu.NewDefaultConfiguration()
 
-	// Test for setting only the user password.
+	_, err = Process(OptimizeCommand(redactedFile, outFile, config))
+	if err != nil {
+		t.Fatalf("TestOptimizeBilevelImages: %v\n", err)
+	}
 
-	t.Log("running TestEncryptUPWOnly..")
+	ctx = readContextFromFile(outFile, config, t)
+	if n := bilevelImages(ctx, "FlateDecode"); n > 0 {
+		t.Fatalf("TestOptimizeBilevelImages: %d Flate encoded bilevel images left\n", n)
+	}
 
-	inFile := filepath.Join(inDir, "5116.DCT_Filter.pdf")
-	outFile := filepath.Join(outDir, "test.pdf")
+}
 
-	// Encrypt upw only
-	t.Log("Encrypt upw only")
-	config := pdfcpu.NewDefaultConfiguration()
-	config.UserPW = "upw"
-	_, err := Process(EncryptCommand(inFile, outFile, config))
+// pageImageFilters returns the filters of the image XObjects of a page.
+func pageImageFilters(ctx *pdfcpu.PDFContext, pageNr int, t *testing.T) []string {
+
+	pageDict, _, err := ctx.PageDict(pageNr)
 	if err != nil {
-		t.Fatalf("TestEncryptUPWOnly - encrypt with upw only to %s: %v\n", outFile, err)
+		t.Fatalf("pageImageFilters: %v\n", err)
 	}
 
-	// Validate wrong upw
-	t.Log("Validate wrong upw fails")
-	config = pdfcpu.NewDefaultConfiguration()
-	config.UserPW = "upwWrong"
-	_, err = Process(ValidateCommand(outFile, config))
-	if err == nil {
-		t.Fatalf("TestEncryptUPWOnly - validate %s using wrong upw should fail!\n", outFile)
+	resources, err := ctx.DereferenceDict(pageDict.Dict["Resources"])
+	if err != nil || resources == nil {
+		return nil
 	}
 
-	// Validate wrong opw
-	t.Log("Validate wrong opw fails")
-	config = pdfcpu.NewDefaultConfiguration()
-	config.OwnerPW = "opwWrong"
-	_, err = Process(ValidateCommand(outFile, config))
-	if err == nil {
-		t.Fatalf("TestEncryptUPWOnly - validate %s using wrong opw should fail!\n", outFile)
+	xobjs, err := ctx.DereferenceDict(resources.Dict["XObject"])
+	if err != nil || xobjs == nil {
+		return nil
 	}
 
-	// Validate default opw=upw (if there is no ownerpw set)
-	t.Log("Validate default opw")
-	config = pdfcpu.NewDefaultConfiguration()
-	config.OwnerPW = "upw"
-	_, err = Process(ValidateCommand(outFile, config))
-	if err != nil {
-		t.Fatalf("TestEncryptUPWOnly - validate %s using default opw: %s!\n", outFile, err)
+	var filters []string
+
+	for _, o := range xobjs.Dict {
+		sd, err := ctx.DereferenceStreamDict(o)
+		if err != nil || sd == nil || sd.Subtype() == nil || *sd.Subtype() != "Image" {
+			continue
+		}
+		if f := sd.NameEntry("Filter"); f != nil {
+			filters = append(filters, *f)
+		}
 	}
 
-	// Validate upw
-	t.Log("Validate upw")
-	config = pdfcpu.NewDefaultConfiguration()
-	config.UserPW = "upw"
-	_, err = Process(ValidateCommand(outFile, config))
-	if err != nil {
-		t.Fatalf("TestEncryptUPWOnly - validate %s using upw: %v\n", outFile, err)
+	return filters
+}
+
+func TestRedactDCTImages(t *testing.T) {
+
+	// Page 4 of RA_CI.pdf contains CMYK JPEG images.
+	inFile := filepath.Join(inDir, "RA_CI.pdf")
+	outFile := filepath.Join(outDir, "RA_CI_redacted.pdf")
+	config := pdfcpu.NewDefaultConfiguration()
+
+	ctx := readContextFromFile(inFile, config, t)
+	before := pageImageFilters(ctx, 4, t)
+	if !strings.Contains(strings.Join(before, ","), "DCTDecode") {
+		t.Fatalf("TestRedactDCTImages: no DCT encoded images: %v\n", before)
 	}
 
-	// Optimize wrong opw
-	t.Log("Optimize wrong opw fails")
-	config = pdfcpu.NewDefaultConfiguration()
-	config.OwnerPW = "opwWrong"
-	_, err = Process(OptimizeCommand(outFile, outFile, config))
-	if err == nil {
-		t.Fatalf("TestEncryptUPWOnly - optimize %s using wrong opw should fail!\n", outFile)
+	// Redact the whole page.
+	rects := []types.Rectangle{types.NewRectangle(0, 0, 2000, 2000)}
+
+	_, err := Process(RedactCommand(inFile, outFile, []string{"4"}, rects, config))
+	if err != nil {
+		t.Fatalf("TestRedactDCTImages: %v\n", err)
 	}
 
-	// Optimize empty opw
-	t.Log("Optimize empty opw fails")
-	config = pdfcpu.NewDefaultConfiguration()
-	config.OwnerPW = ""
-	_, err = Process(OptimizeCommand(outFile, outFile, config))
-	if err == nil {
-		t.Fatalf("TestEncryptUPWOnly - optimize %s using empty opw should fail!\n", outFile)
+	// The redacted images are decoded and written as Flate encoded copies.
+	ctx = readContextFromFile(outFile, config, t)
+	after := pageImageFilters(ctx, 4, t)
+	if len(after) != len(before) || strings.Contains(strings.Join(after, ","), "DCTDecode") {
+		t.Fatalf("TestRedactDCTImages: before: %v after: %v\n", before, after)
 	}
 
-	// Optimize wrong upw
-	t.Log("Optimize wrong upw fails")
-	config = pdfcpu.NewDefaultConfiguration()
-	config.UserPW = "upwWrong"
-	_, err = Process(OptimizeCommand(outFile, outFile, config))
-	if err == nil {
-		t.Fatalf("TestEncryptUPWOnly - optimize %s using wrong upw should fail!\n", outFile)
+}
+
+// imagePixels returns the total number of pixels of all images.
+func imagePixels(ctx *pdfcpu.PDFContext) int {
+
+	n := 0
+
+	for _, entry := range ctx.Table {
+		sd, ok := entry.Object.(pdfcpu.PDFStreamDict)
+		if !ok || sd.Subtype() == nil || *sd.Subtype() != "Image" {
+			continue
+		}
+		if w, h := sd.IntEntry("Width"), sd.IntEntry("Height"); w != nil && h != nil {
+			n += *w * *h
+		}
 	}
 
-	// Optimize upw
-	t.Log("Optimize upw")
-	config = pdfcpu.NewDefaultConfiguration()
-	config.UserPW = "upw"
-	_, err = Process(OptimizeCommand(outFile, outFile, config))
+	return n
+}
+
+func TestOptimizeResampleImages(t *testing.T) {
+
+	inFile := filepath.Join(inDir, "testImage.pdf")
+	outFile := filepath.Join(outDir, "testImage_optimized.pdf")
+	resampledFile := filepath.Join(outDir, "testImage_resampled.pdf")
+	config := pdfcpu.NewDefaultConfiguration()
+
+	_, err := Process(OptimizeCommand(inFile, outFile, config))
 	if err != nil {
-		t.Fatalf("TestEncryptUPWOnly - optimize %s using upw: %v\n", outFile, err)
+		t.Fatalf("TestOptimizeResampleImages: %v\n", err)
 	}
 
-	//Change upw wrong upwOld
-	t.Log("ChangeUserPW wrong upwOld fails")
-	config = pdfcpu.NewDefaultConfiguration()
-	pwOld := "upwWrong"
-	pwNew := "upwNew"
-	_, err = Process(ChangeUserPWCommand(outFile, outFile, config, &pwOld, &pwNew))
-	if err == nil {
-		t.Fatalf("TestEncryptUPWOnly - %s change userPW using wrong upwOld should fail\n", outFile)
+	err = pdfcpu.ParseImageResampling("dpi=72,quality=60", config)
+	if err != nil {
+		t.Fatalf("TestOptimizeResampleImages: %v\n", err)
 	}
 
-	// Change upw
-	t.Log("ChangeUserPW")
-	config = pdfcpu.NewDefaultConfiguration()
-	pwOld = "upw"
-	pwNew = "upwNew"
-	_, err = Process(ChangeUserPWCommand(outFile, outFile, config, &pwOld, &pwNew))
+	_, err = Process(OptimizeCommand(inFile, resampledFile, config))
 	if err != nil {
-		t.Fatalf("TestEncryptUPWOnly - %s change userPW: %v\n", outFile, err)
+		t.Fatalf("TestOptimizeResampleImages: %v\n", err)
 	}
 
-	// Decrypt wrong opw
-	t.Log("Decrypt wrong opw fails")
 	config = pdfcpu.NewDefaultConfiguration()
-	config.OwnerPW = "opwWrong"
-	_, err = Process(DecryptCommand(outFile, outFile, config))
-	if err == nil {
-		t.Fatalf("TestEncryptUPWOnly - %s decrypt using wrong opw should fail\n", outFile)
+	ctx1 := readContextFromFile(outFile, config, t)
+	ctx2 := readContextFromFile(resampledFile, config, t)
+
+	if p1, p2 := imagePixels(ctx1), imagePixels(ctx2); p2 >= p1 {
+		t.Fatalf("TestOptimizeResampleImages: no images resampled: %d -> %d pixels\n", p1, p2)
 	}
 
-	// Decrypt wrong upw
-	t.Log("Decrypt wrong upw fails")
-	config = pdfcpu.NewDefaultConfiguration()
-	config.UserPW = "upw"
-	_, err = Process(DecryptCommand(outFile, outFile, config))
-	if err == nil {
-		t.Fatalf("TestEncryptUPWOnly - %s decrypt using wrong upw should fail\n", outFile)
+	if ctx2.Read.FileSize >= ctx1.Read.FileSize {
+		t.Fatalf("TestOptimizeResampleImages: %d -> %d bytes\n", ctx1.Read.FileSize, ctx2.Read.FileSize)
 	}
 
-	// Decrypt upw
-	t.Log("Decrypt upw")
+	for _, s := range []string{"dpi", "dpi=0", "quality=101", "ppi=72"} {
+		if err := pdfcpu.ParseImageResampling(s, config); err == nil {
+			t.Fatalf("TestOptimizeResampleImages: %s should fail\n", s)
+		}
+	}
+
+	// A configuration not setting the image quality falls back to the default quality.
 	config = pdfcpu.NewDefaultConfiguration()
-	config.UserPW = "upwNew"
-	_, err = Process(DecryptCommand(outFile, outFile, config))
+	config.ImageDPI = 72
+	config.ImageQuality = 0
+
+	_, err = Process(OptimizeCommand(inFile, resampledFile, config))
 	if err != nil {
-		t.Fatalf("TestEncryptUPWOnly - %s decrypt using upw: %v\n", outFile, err)
+		t.Fatalf("TestOptimizeResampleImages: %v\n", err)
 	}
 
+	config = pdfcpu.NewDefaultConfiguration()
+	if p1, p2 := imagePixels(ctx1), imagePixels(readContextFromFile(resampledFile, config, t)); p2 >= p1 {
+		t.Fatalf("TestOptimizeResampleImages: no images resampled using quality 0: %d -> %d pixels\n", p1, p2)
+	}
 }
 
-func TestEncryptOPWOnly(t *testing.T) {
+func TestOptimizeResampleImagesInAppearances(t *testing.T) {
 
-	// Test for setting only the owner password.
+	inFile := filepath.Join(inDir, "testImage.pdf")
+	annotFile := filepath.Join(outDir, "testImage_annotated.pdf")
+	outFile := filepath.Join(outDir, "testImage_annotated_optimized.pdf")
+	resampledFile := filepath.Join(outDir, "testImage_annotated_resampled.pdf")
 
-	t.Log("running TestEncryptOPWOnly..")
+	config := pdfcpu.NewDefaultConfiguration()
+	ctx := readContextFromFile(inFile, config, t)
 
-	inFile := filepath.Join(inDir, "5116.DCT_Filter.pdf")
-	outFile := filepath.Join(outDir, "test.pdf")
+	// Paint all images from the appearance stream of an annotation.
+	xObjects := pdfcpu.NewPDFDict()
+	for objNr, entry := range ctx.Table {
+		if sd, ok := entry.Object.(pdfcpu.PDFStreamDict); ok && sd.Subtype() != nil && *sd.Subtype() == "Image" {
+			xObjects.Insert(fmt.Sprintf("Im%d", objNr), *pdfcpu.NewPDFIndirectRef(objNr, 0))
+		}
+	}
 
-	// Encrypt opw only
-	t.Log("Encrypt opw only")
-	config := pdfcpu.NewDefaultConfiguration()
-	config.OwnerPW = "opw"
-	_, err := Process(EncryptCommand(inFile, outFile, config))
+	content := []byte("q 10 0 0 10 0 0 cm /Im0 Do Q")
+	l := int64(len(content))
+
+	d := pdfcpu.NewPDFDict()
+	d.InsertName("Type", "XObject")
+	d.InsertName("Subtype", "Form")
+	d.Insert("BBox", pdfcpu.NewRectangle(0, 0, 10, 10))
+	d.Insert("Resources", pdfcpu.PDFDict{Dict: map[string]pdfcpu.PDFObject{"XObject": xObjects}})
+	d.Insert("Length", pdfcpu.PDFInteger(l))
+	sd := pdfcpu.NewPDFStreamDict(d, 0, &l, nil, nil)
+	sd.Raw = content
+
+	apRef, err := ctx.IndRefForNewObject(sd)
 	if err != nil {
-		t.Fatalf("TestEncryptOPWOnly - encrypt with opw only to %s: %v\n", outFile, err)
+		t.Fatalf("TestOptimizeResampleImagesInAppearances: %v\n", err)
 	}
 
-	// Validate wrong opw succeeds with fallback to empty upw
-	t.Log("Validate wrong opw succeeds with fallback to empty upw")
-	config = pdfcpu.NewDefaultConfiguration()
-	config.OwnerPW = "opwWrong"
-	_, err = Process(ValidateCommand(outFile, config))
+	annot := pdfcpu.NewPDFDict()
+	annot.InsertName("Type", "Annot")
+	annot.InsertName("Subtype", "Stamp")
+	annot.Insert("Rect", pdfcpu.NewRectangle(0, 0, 10, 10))
+	annot.Insert("AP", pdfcpu.PDFDict{Dict: map[string]pdfcpu.PDFObject{"N": *apRef}})
+
+	annotRef, err := ctx.IndRefForNewObject(annot)
 	if err != nil {
-		t.Fatalf("TestEncryptOPWOnly - validate %s using wrong opw succeeds falling back to empty upw!: %v\n", outFile, err)
+		t.Fatalf("TestOptimizeResampleImagesInAppearances: %v\n", err)
 	}
 
-	// Validate opw
-	t.Log("Validate opw")
-	config = pdfcpu.NewDefaultConfiguration()
-	config.OwnerPW = "opw"
-	_, err = Process(ValidateCommand(outFile, config))
+	pageDict, _, err := ctx.PageDict(1)
 	if err != nil {
-		t.Fatalf("TestEncryptOPWOnly - validate %s using opw: %v\n", outFile, err)
+		t.Fatalf("TestOptimizeResampleImagesInAppearances: %v\n", err)
 	}
+	pageDict.Insert("Annots", pdfcpu.PDFArray{*annotRef})
 
-	// Validate wrong upw
-	t.Log("Validate wrong upw fails")
-	config = pdfcpu.NewDefaultConfiguration()
-	config.UserPW = "upwWrong"
-	_, err = Process(ValidateCommand(outFile, config))
-	if err == nil {
-		t.Fatalf("TestEncryptOPWOnly - validate %s using wrong upw should fail!\n", outFile)
+	ctx.Write.DirName = outDir + "/"
+	ctx.Write.FileName = filepath.Base(annotFile)
+	if err = Write(ctx); err != nil {
+		t.Fatalf("TestOptimizeResampleImagesInAppearances: %v\n", err)
 	}
 
-	// Validate no pw using empty upw
-	t.Log("Validate no pw using empty upw")
-	config = pdfcpu.NewDefaultConfiguration()
-	_, err = Process(ValidateCommand(outFile, config))
+	_, err = Process(OptimizeCommand(annotFile, outFile, config))
 	if err != nil {
-		t.Fatalf("TestEncryptOPWOnly - validate %s no pw using empty upw: %v\n", outFile, err)
+		t.Fatalf("TestOptimizeResampleImagesInAppearances: %v\n", err)
 	}
 
-	// Optimize wrong opw, succeeds with fallback to empty upw
-	t.Log("Optimize wrong opw succeeds with fallback to empty upw")
 	config = pdfcpu.NewDefaultConfiguration()
-	config.OwnerPW = "opwWrong"
-	_, err = Process(OptimizeCommand(outFile, outFile, config))
-	if err != nil {
-		t.Fatalf("TestEncryptOPWOnly - optimize %s using wrong opw succeeds falling back to empty upw: %v\n", outFile, err)
+	if err = pdfcpu.ParseImageResampling("dpi=72", config); err != nil {
+		t.Fatalf("TestOptimizeResampleImagesInAppearances: %v\n", err)
 	}
 
-	// Optimize opw
-	t.Log("Optimize opw")
-	config = pdfcpu.NewDefaultConfiguration()
-	config.OwnerPW = "opw"
-	_, err = Process(OptimizeCommand(outFile, outFile, config))
+	_, err = Process(OptimizeCommand(annotFile, resampledFile, config))
 	if err != nil {
-		t.Fatalf("TestEncryptOPWOnly - optimize %s using opw: %v\n", outFile, err)
+		t.Fatalf("TestOptimizeResampleImagesInAppearances: %v\n", err)
 	}
 
-	// Optimize wrong upw
-	t.Log("Optimize wrong upw fails")
+	// The displayed size of the images within the annotation is unknown.
 	config = pdfcpu.NewDefaultConfiguration()
-	config.UserPW = "upwWrong"
-	_, err = Process(OptimizeCommand(outFile, outFile, config))
-	if err == nil {
-		t.Fatalf("TestEncryptOPWOnly - optimize %s using wrong upw should fail!\n", outFile)
+	if p1, p2 := imagePixels(readContextFromFile(outFile, config, t)), imagePixels(readContextFromFile(resampledFile, config, t)); p1 != p2 {
+		t.Fatalf("TestOptimizeResampleImagesInAppearances: images resampled: %d -> %d pixels\n", p1, p2)
 	}
+}
 
-	// Optimize empty upw
-	t.Log("Optimize empty upw")
-	config = pdfcpu.NewDefaultConfiguration()
-	config.UserPW = ""
-	_, err = Process(OptimizeCommand(outFile, outFile, config))
-	if err != nil {
-		t.Fatalf("TestEncryptOPWOnly - optimize %s using upw: %v\n", outFile, err)
-	}
+// documentText returns the text of all pages without whitespace.
+func documentText(ctx *pdfcpu.PDFContext, t *testing.T) string {
 
-	// Change opw wrong upw
-	t.Log("ChangeOwnerPW wrong upw fails")
-	config = pdfcpu.NewDefaultConfiguration()
-	config.UserPW = "upw"
-	pwOld := "opw"
-	pwNew := "opwNew"
-	_, err = Process(ChangeOwnerPWCommand(outFile, outFile, config, &pwOld, &pwNew))
-	if err == nil {
-		t.Fatalf("TestEncryptOPWOnly - %s change opw using wrong upw should fail\n", outFile)
-	}
+	var sb strings.Builder
 
-	// Change opw wrong opwOld
-	t.Log("ChangeOwnerPW wrong opwOld fails")
-	config = pdfcpu.NewDefaultConfiguration()
-	config.UserPW = ""
-	pwOld = "opwOldWrong"
-	pwNew = "opwNew"
-	_, err = Process(ChangeOwnerPWCommand(outFile, outFile, config, &pwOld, &pwNew))
-	if err == nil {
-		t.Fatalf("TestEncryptOPWOnly - %s change opw using wrong opwOld should fail\n", outFile)
+	for p := 1; p <= ctx.PageCount; p++ {
+		pt, err := pdfcpu.ExtractPageText(ctx, p)
+		if err != nil {
+			t.Fatalf("documentText page %d: %v\n", p, err)
+		}
+		// Renamed fonts may split text runs and change the spacing.
+		sb.WriteString(strings.Join(strings.Fields(pt.Text()), ""))
 	}
 
-	// Change opw
-	t.Log("ChangeOwnerPW")
-	config = pdfcpu.NewDefaultConfiguration()
-	config.UserPW = ""
-	pwOld = "opw"
-	pwNew = "opwNew"
-	_, err = Process(ChangeOwnerPWCommand(outFile, outFile, config, &pwOld, &pwNew))
-	if err != nil {
-		t.Fatalf("TestEncryptOPWOnly - %s change opw: %v\n", outFile, err)
-	}
+	return sb.String()
+}
 
-	// Decrypt wrong upw
-	t.Log("Decrypt wrong upw fails")
-	config = pdfcpu.NewDefaultConfiguration()
-	config.UserPW = "upwWrong"
-	_, err = Process(DecryptCommand(outFile, outFile, config))
-	if err == nil {
-		t.Fatalf("TestEncryptOPWOnly - %s decrypt using wrong upw should fail \n", outFile)
-	}
+// stripSubsetTags renames subset fonts as if they were fully embedded.
+func stripSubsetTags(ctx *pdfcpu.PDFContext) {
 
-	// Decrypt wrong opw succeeds because of fallback to empty upw.
-	t.Log("Decrypt wrong opw succeeds because of fallback to empty upw")
-	config = pdfcpu.NewDefaultConfiguration()
-	config.OwnerPW = "opw"
-	_, err = Process(DecryptCommand(outFile, outFile, config))
-	if err != nil {
-		t.Fatalf("TestEncryptOPWOnly - %s decrypt using opw: %v\n", outFile, err)
+	for _, entry := range ctx.Table {
+		d, ok := entry.Object.(pdfcpu.PDFDict)
+		if !ok {
+			continue
+		}
+		for _, k := range []string{"BaseFont", "FontName"} {
+			if n := d.NameEntry(k); n != nil && len(*n) > 7 && (*n)[6] == '+' {
+				d.Update(k, pdfcpu.PDFName((*n)[7:]))
+			}
+		}
 	}
-
 }
 
-func TestEncrypt(t *testing.T) {
+func TestOptimizeSubsetFonts(t *testing.T) {
 
-	t.Log("running TestEncrypt..")
+	// go.pdf: TrueType, BuildingWebappsWithGo.pdf: CIDFontType2, RA_CI.pdf: Type1C
+	for _, fn := range []string{"go.pdf", "BuildingWebappsWithGo.pdf", "RA_CI.pdf"} {
 
-	inFile := filepath.Join(inDir, "5116.DCT_Filter.pdf")
-	outFile := filepath.Join(outDir, "test.pdf")
+		config := pdfcpu.NewDefaultConfiguration()
+		config.Mode = pdfcpu.OPTIMIZE
 
-	// Encrypt opw and upw
-	t.Log("Encrypt")
-	config := pdfcpu.NewDefaultConfiguration()
-	config.UserPW = "upw"
-	config.OwnerPW = "opw"
-	_, err := Process(EncryptCommand(inFile, outFile, config))
-	if err != nil {
+		ctx := readContextFromFile(filepath.Join(inDir, fn), config, t)
+		stripSubsetTags(ctx)
+		ctx = writeAndReadBack(ctx, config, t)
+
+		text := documentText(ctx, t)
+
+		err := OptimizeContext(ctx)
+		if err != nil {
+			t.Fatalf("TestOptimizeSubsetFonts %s: %v\n", fn, err)
+		}
+
+		if len(ctx.Optimize.SubsetFonts) == 0 {
+			t.Fatalf("TestOptimizeSubsetFonts %s: no fonts subset\n", fn)
+		}
+
+		for objNr, sf := range ctx.Optimize.SubsetFonts {
+			if sf.Saved() <= 0 || len(sf.Name) < 7 || sf.Name[6] != '+' {
+				t.Fatalf("TestOptimizeSubsetFonts %s: obj#%d: %v\n", fn, objNr, sf)
+			}
+		}
+
+		ctx = writeAndReadBack(ctx, config, t)
+
+		if s := documentText(ctx, t); s != text {
+			t.Fatalf("TestOptimizeSubsetFonts %s: text changed\n", fn)
+		}
+	}
+
+}
+
+// pageFonts returns the font resources in effect for a page.
+func pageFonts(ctx *pdfcpu.PDFContext, page int, t *testing.T) *pdfcpu.PDFDict {
+
+	d, _, err := ctx.PageDict(page)
+	if err != nil {
+		t.Fatalf("pageFonts: %v\n", err)
+	}
+
+	for d != nil {
+
+		if o, found := d.Find("Resources"); found {
+			resources, err := ctx.DereferenceDict(o)
+			if err != nil || resources == nil {
+				t.Fatalf("pageFonts: corrupt resources\n")
+			}
+			fonts, err := ctx.DereferenceDict(resources.Dict["Font"])
+			if err != nil {
+				t.Fatalf("pageFonts: %v\n", err)
+			}
+			return fonts
+		}
+
+		d, err = ctx.DereferenceDict(d.Dict["Parent"])
+		if err != nil {
+			t.Fatalf("pageFonts: %v\n", err)
+		}
+	}
+
+	return nil
+}
+
+func testOptimizePruneResources(mode pdfcpu.CommandMode, pruned bool, t *testing.T) {
+
+	config := pdfcpu.NewDefaultConfiguration()
+	config.Mode = mode
+
+	ctx := readContextFromFile(filepath.Join(inDir, "go.pdf"), config, t)
+
+	text := documentText(ctx, t)
+
+	// Attach an unused font to the resources of the first page.
+	fonts := pageFonts(ctx, 1, t)
+	if fonts == nil {
+		t.Fatalf("TestOptimizePruneResources: missing font resources\n")
+	}
+
+	for _, o := range fonts.Dict {
+		fonts.Insert("Unused", o)
+		break
+	}
+
+	err := OptimizeContext(ctx)
+	if err != nil {
+		t.Fatalf("TestOptimizePruneResources: %v\n", err)
+	}
+
+	ctx = writeAndReadBack(ctx, config, t)
+
+	if !pruned {
+		if _, found := pageFonts(ctx, 1, t).Find("Unused"); !found {
+			t.Fatalf("TestOptimizePruneResources: mode %d: unused font removed\n", mode)
+		}
+		return
+	}
+
+	for p := 1; p <= ctx.PageCount; p++ {
+
+		fonts := pageFonts(ctx, p, t)
+		if fonts == nil {
+			continue
+		}
+
+		if _, found := fonts.Find("Unused"); found {
+			t.Fatalf("TestOptimizePruneResources: mode %d: page %d: unused font not removed\n", mode, p)
+		}
+	}
+
+	if s := documentText(ctx, t); s != text {
+		t.Fatalf("TestOptimizePruneResources: mode %d: text changed\n", mode)
+	}
+
+}
+
+func TestOptimizePruneResources(t *testing.T) {
+
+	for _, mode := range []pdfcpu.CommandMode{pdfcpu.OPTIMIZE, pdfcpu.SPLIT, pdfcpu.TRIM} {
+		testOptimizePruneResources(mode, true, t)
+	}
+
+	// Resources are left untouched by any other command.
+	for _, mode := range []pdfcpu.CommandMode{pdfcpu.VALIDATE, pdfcpu.MERGE, pdfcpu.ADDWATERMARKS} {
+		testOptimizePruneResources(mode, false, t)
+	}
+}
+
+func TestExtractFontsCommand(t *testing.T) {
+
+	cmd := ExtractFontsCommand("", outDir, nil, pdfcpu.NewDefaultConfiguration())
+
+	for _, fn := range []string{"5116.DCT_Filter.pdf", "testImage.pdf", "go.pdf"} {
+
+		fn = filepath.Join(inDir, fn)
+		cmd.InFile = &fn
+
+		_, err := Process(cmd)
+		if err != nil {
+			t.Fatalf("TestExtractFontsCommand: %v\n", err)
+		}
+
+	}
+
+	inFile := filepath.Join(inDir, "go.pdf")
+	_, err := Process(ExtractFontsCommand(inFile, outDir, []string{"1-3"}, pdfcpu.NewDefaultConfiguration()))
+	if err != nil {
+		t.Fatalf("TestExtractFontsCommand: %v\n", err)
+	}
+
+}
+
+func TestExtractContentCommand(t *testing.T) {
+
+	inFile := filepath.Join(inDir, "5116.DCT_Filter.pdf")
+
+	_, err := Process(ExtractContentCommand(inFile, outDir, nil, pdfcpu.NewDefaultConfiguration()))
+	if err != nil {
+		t.Fatalf("TestExtractContentCommand: %v\n", err)
+	}
+
+}
+
+func TestExtractTextCommand(t *testing.T) {
+
+	inFile := filepath.Join(inDir, "go.pdf")
+
+	_, err := Process(ExtractTextCommand(inFile, outDir, []string{"1"}, false, pdfcpu.NewDefaultConfiguration()))
+	if err != nil {
+		t.Fatalf("TestExtractTextCommand: %v\n", err)
+	}
+
+	b, err := ioutil.ReadFile(filepath.Join(outDir, "1.txt"))
+	if err != nil {
+		t.Fatalf("TestExtractTextCommand: %v\n", err)
+	}
+
+	if !strings.HasPrefix(string(b), "Google's Go Programming Language\n") {
+		t.Fatalf("TestExtractTextCommand: unexpected text:\n%s\n", b)
+	}
+
+	_, err = Process(ExtractTextCommand(inFile, outDir, []string{"1"}, true, pdfcpu.NewDefaultConfiguration()))
+	if err != nil {
+		t.Fatalf("TestExtractTextCommand json: %v\n", err)
+	}
+
+	b, err = ioutil.ReadFile(filepath.Join(outDir, "1.json"))
+	if err != nil {
+		t.Fatalf("TestExtractTextCommand json: %v\n", err)
+	}
+
+	var pt pdfcpu.PageText
+
+	err = json.Unmarshal(b, &pt)
+	if err != nil {
+		t.Fatalf("TestExtractTextCommand json: %v\n", err)
+	}
+
+	if len(pt.Runs) == 0 || pt.Runs[0].Font != "Arial,Bold" || pt.Runs[0].Size < 39 || pt.Runs[0].Size > 41 {
+		t.Fatalf("TestExtractTextCommand json: unexpected runs: %v\n", pt.Runs)
+	}
+
+}
+
+func TestExtractText(t *testing.T) {
+
+	for _, f := range []string{"adobe_errata.pdf", "networkProgr.pdf", "Paclitaxel.PDF", "hoare_1978.pdf"} {
+
+		ctx := readContextFromFile(filepath.Join(inDir, f), pdfcpu.NewDefaultConfiguration(), t)
+
+		for p := 1; p <= ctx.PageCount; p++ {
+			_, err := pdfcpu.ExtractPageText(ctx, p)
+			if err != nil {
+				t.Fatalf("TestExtractText %s page %d: %v\n", f, p, err)
+			}
+		}
+	}
+
+}
+
+func TestRedactCommand(t *testing.T) {
+
+	inFile := filepath.Join(inDir, "go.pdf")
+	outFile := filepath.Join(outDir, "redacted.pdf")
+	config := pdfcpu.NewDefaultConfiguration()
+
+	// Redact "Yossi" of "Yossi Gil" on page 1.
+	rects := []types.Rectangle{types.NewRectangle(300, 250, 420, 300)}
+
+	_, err := Process(RedactCommand(inFile, outFile, []string{"1"}, rects, config))
+	if err != nil {
+		t.Fatalf("TestRedactCommand: %v\n", err)
+	}
+
+	ctx := readContextFromFile(outFile, config, t)
+
+	pt, err := pdfcpu.ExtractPageText(ctx, 1)
+	if err != nil {
+		t.Fatalf("TestRedactCommand: %v\n", err)
+	}
+
+	s := pt.Text()
+	if strings.Contains(s, "Yossi") || !strings.Contains(s, "Gil") || !strings.HasPrefix(s, "Google's Go Programming Language\n") {
+		t.Fatalf("TestRedactCommand: unexpected text:\n%s\n", s)
+	}
+
+	// The remaining text keeps its position.
+	for _, r := range pt.Runs {
+		if r.Text == "Gil" && (r.X < 429 || r.X > 429.1) {
+			t.Fatalf("TestRedactCommand: unexpected position of %v\n", r)
+		}
+	}
+
+}
+
+func TestRedactAnnotations(t *testing.T) {
+
+	xRefTable, err := pdfcpu.CreateAnnotationDemoXRef()
+	if err != nil {
+		t.Fatalf("TestRedactAnnotations: %v\n", err)
+	}
+
+	err = pdfcpu.CreatePDF(xRefTable, outDir+"/", "redactDemo.pdf")
+	if err != nil {
+		t.Fatalf("TestRedactAnnotations: %v\n", err)
+	}
+
+	inFile := filepath.Join(outDir, "redactDemo.pdf")
+	outFile := filepath.Join(outDir, "redactDemo_new.pdf")
+	config := pdfcpu.NewDefaultConfiguration()
+
+	// Apply the Redact annotations of all pages.
+	_, err = Process(RedactCommand(inFile, outFile, nil, nil, config))
+	if err != nil {
+		t.Fatalf("TestRedactAnnotations: %v\n", err)
+	}
+
+	ctx := readContextFromFile(outFile, config, t)
+
+	for p := 1; p <= ctx.PageCount; p++ {
+
+		pageDict, _, err := ctx.PageDict(p)
+		if err != nil {
+			t.Fatalf("TestRedactAnnotations: %v\n", err)
+		}
+
+		annots, err := ctx.DereferenceArray(pageDict.Dict["Annots"])
+		if err != nil || annots == nil {
+			continue
+		}
+
+		for _, o := range *annots {
+			d, err := ctx.DereferenceDict(o)
+			if err == nil && d != nil && d.Subtype() != nil && *d.Subtype() == "Redact" {
+				t.Fatalf("TestRedactAnnotations: page %d: Redact annotation not applied\n", p)
+			}
+		}
+	}
+
+}
+
+func TestExtractPagesCommand(t *testing.T) {
+
+	inFile := filepath.Join(inDir, "TheGoProgrammingLanguageCh1.pdf")
+
+	_, err := Process(ExtractPagesCommand(inFile, outDir, []string{"1"}, pdfcpu.NewDefaultConfiguration()))
+	if err != nil {
+		t.Fatalf("TestExtractPagesCommand: %v\n", err)
+	}
+
+}
+
+func TestEncryptUPWOnly(t *testing.T) {
+
+	// Test for setting only the user password.
+
+	t.Log("running TestEncryptUPWOnly..")
+
+	inFile := filepath.Join(inDir, "5116.DCT_Filter.pdf")
+	outFile := filepath.Join(outDir, "test.pdf")
+
+	// Encrypt upw only
+	t.Log("Encrypt upw only")
+	config := pdfcpu.NewDefaultConfiguration()
+	config.UserPW = "upw"
+	_, err := Process(EncryptCommand(inFile, outFile, config))
+	if err != nil {
+		t.Fatalf("TestEncryptUPWOnly - encrypt with upw only to %s: %v\n", outFile, err)
+	}
+
+	// Validate wrong upw
+	t.Log("Validate wrong upw fails")
+	config = pdfcpu.NewDefaultConfiguration()
+	config.UserPW = "upwWrong"
+	_, err = Process(ValidateCommand(outFile, config))
+	if err == nil {
+		t.Fatalf("TestEncryptUPWOnly - validate %s using wrong upw should fail!\n", outFile)
+	}
+
+	// Validate wrong opw
+	t.Log("Validate wrong opw fails")
+	config = pdfcpu.NewDefaultConfiguration()
+	config.OwnerPW = "opwWrong"
+	_, err = Process(ValidateCommand(outFile, config))
+	if err == nil {
+		t.Fatalf("TestEncryptUPWOnly - validate %s using wrong opw should fail!\n", outFile)
+	}
+
+	// Validate default opw=upw (if there is no ownerpw set)
+	t.Log("Validate default opw")
+	config = pdfcpu.NewDefaultConfiguration()
+	config.OwnerPW = "upw"
+	_, err = Process(ValidateCommand(outFile, config))
+	if err != nil {
+		t.Fatalf("TestEncryptUPWOnly - validate %s using default opw: %s!\n", outFile, err)
+	}
+
+	// Validate upw
+	t.Log("Validate upw")
+	config = pdfcpu.NewDefaultConfiguration()
+	config.UserPW = "upw"
+	_, err = Process(ValidateCommand(outFile, config))
+	if err != nil {
+		t.Fatalf("TestEncryptUPWOnly - validate %s using upw: %v\n", outFile, err)
+	}
+
+	// Optimize wrong opw
+	t.Log("Optimize wrong opw fails")
+	config = pdfcpu.NewDefaultConfiguration()
+	config.OwnerPW = "opwWrong"
+	_, err = Process(OptimizeCommand(outFile, outFile, config))
+	if err == nil {
+		t.Fatalf("TestEncryptUPWOnly - optimize %s using wrong opw should fail!\n", outFile)
+	}
+
+	// Optimize empty opw
+	t.Log("Optimize empty opw fails")
+	config = pdfcpu.NewDefaultConfiguration()
+	config.OwnerPW = ""
+	_, err = Process(OptimizeCommand(outFile, outFile, config))
+	if err == nil {
+		t.Fatalf("TestEncryptUPWOnly - optimize %s using empty opw should fail!\n", outFile)
+	}
+
+	// Optimize wrong upw
+	t.Log("Optimize wrong upw fails")
+	config = pdfcpu.NewDefaultConfiguration()
+	config.UserPW = "upwWrong"
+	_, err = Process(OptimizeCommand(outFile, outFile, config))
+	if err == nil {
+		t.Fatalf("TestEncryptUPWOnly - optimize %s using wrong upw should fail!\n", outFile)
+	}
+
+	// Optimize upw
+	t.Log("Optimize upw")
+	config = pdfcpu.NewDefaultConfiguration()
+	config.UserPW = "upw"
+	_, err = Process(OptimizeCommand(outFile, outFile, config))
+	if err != nil {
+		t.Fatalf("TestEncryptUPWOnly - optimize %s using upw: %v\n", outFile, err)
+	}
+
+	//Change upw wrong upwOld
+	t.Log("ChangeUserPW wrong upwOld fails")
+	config = pdfcpu.NewDefaultConfiguration()
+	pwOld := "upwWrong"
+	pwNew := "upwNew"
+	_, err = Process(ChangeUserPWCommand(outFile, outFile, config, &pwOld, &pwNew))
+	if err == nil {
+		t.Fatalf("TestEncryptUPWOnly - %s change userPW using wrong upwOld should fail\n", outFile)
+	}
+
+	// Change upw
+	t.Log("ChangeUserPW")
+	config = pdfcpu.NewDefaultConfiguration()
+	pwOld = "upw"
+	pwNew = "upwNew"
+	_, err = Process(ChangeUserPWCommand(outFile, outFile, config, &pwOld, &pwNew))
+	if err != nil {
+		t.Fatalf("TestEncryptUPWOnly - %s change userPW: %v\n", outFile, err)
+	}
+
+	// Decrypt wrong opw
+	t.Log("Decrypt wrong opw fails")
+	config = pdfcpu.NewDefaultConfiguration()
+	config.OwnerPW = "opwWrong"
+	_, err = Process(DecryptCommand(outFile, outFile, config))
+	if err == nil {
+		t.Fatalf("TestEncryptUPWOnly - %s decrypt using wrong opw should fail\n", outFile)
+	}
+
+	// Decrypt wrong upw
+	t.Log("Decrypt wrong upw fails")
+	config = pdfcpu.NewDefaultConfiguration()
+	config.UserPW = "upw"
+	_, err = Process(DecryptCommand(outFile, outFile, config))
+	if err == nil {
+		t.Fatalf("TestEncryptUPWOnly - %s decrypt using wrong upw should fail\n", outFile)
+	}
+
+	// Decrypt upw
+	t.Log("Decrypt upw")
+	config = pdfcpu.NewDefaultConfiguration()
+	config.UserPW = "upwNew"
+	_, err = Process(DecryptCommand(outFile, outFile, config))
+	if err != nil {
+		t.Fatalf("TestEncryptUPWOnly - %s decrypt using upw: %v\n", outFile, err)
+	}
+
+}
+
+func TestEncryptOPWOnly(t *testing.T) {
+
+	// Test for setting only the owner password.
+
+	t.Log("running TestEncryptOPWOnly..")
+
+	inFile := filepath.Join(inDir, "5116.DCT_Filter.pdf")
+	outFile := filepath.Join(outDir, "test.pdf")
+
+	// Encrypt opw only
+	t.Log("Encrypt opw only")
+	config := pdfcpu.NewDefaultConfiguration()
+	config.OwnerPW = "opw"
+	_, err := Process(EncryptCommand(inFile, outFile, config))
+	if err != nil {
+		t.Fatalf("TestEncryptOPWOnly - encrypt with opw only to %s: %v\n", outFile, err)
+	}
+
+	// Validate wrong opw succeeds with fallback to empty upw
+	t.Log("Validate wrong opw succeeds with fallback to empty upw")
+	config = pdfcpu.NewDefaultConfiguration()
+	config.OwnerPW = "opwWrong"
+	_, err = Process(ValidateCommand(outFile, config))
+	if err != nil {
+		t.Fatalf("TestEncryptOPWOnly - validate %s using wrong opw succeeds falling back to empty upw!: %v\n", outFile, err)
+	}
+
+	// Validate opw
+	t.Log("Validate opw")
+	config = pdfcpu.NewDefaultConfiguration()
+	config.OwnerPW = "opw"
+	_, err = Process(ValidateCommand(outFile, config))
+	if err != nil {
+		t.Fatalf("TestEncryptOPWOnly - validate %s using opw: %v\n", outFile, err)
+	}
+
+	// Validate wrong upw
+	t.Log("Validate wrong upw fails")
+	config = pdfcpu.NewDefaultConfiguration()
+	config.UserPW = "upwWrong"
+	_, err = Process(ValidateCommand(outFile, config))
+	if err == nil {
+		t.Fatalf("TestEncryptOPWOnly - validate %s using wrong upw should fail!\n", outFile)
+	}
+
+	// Validate no pw using empty upw
+	t.Log("Validate no pw using empty upw")
+	config = pdfcpu.NewDefaultConfiguration()
+	_, err = Process(ValidateCommand(outFile, config))
+	if err != nil {
+		t.Fatalf("TestEncryptOPWOnly - validate %s no pw using empty upw: %v\n", outFile, err)
+	}
+
+	// Optimize wrong opw, succeeds with fallback to empty upw
+	t.Log("Optimize wrong opw succeeds with fallback to empty upw")
+	config = pdfcpu.NewDefaultConfiguration()
+	config.OwnerPW = "opwWrong"
+	_, err = Process(OptimizeCommand(outFile, outFile, config))
+	if err != nil {
+		t.Fatalf("TestEncryptOPWOnly - optimize %s using wrong opw succeeds falling back to empty upw: %v\n", outFile, err)
+	}
+
+	// Optimize opw
+	t.Log("Optimize opw")
+	config = pdfcpu.NewDefaultConfiguration()
+	config.OwnerPW = "opw"
+	_, err = Process(OptimizeCommand(outFile, outFile, config))
+	if err != nil {
+		t.Fatalf("TestEncryptOPWOnly - optimize %s using opw: %v\n", outFile, err)
+	}
+
+	// Optimize wrong upw
+	t.Log("Optimize wrong upw fails")
+	config = pdfcpu.NewDefaultConfiguration()
+	config.UserPW = "upwWrong"
+	_, err = Process(OptimizeCommand(outFile, outFile, config))
+	if err == nil {
+		t.Fatalf("TestEncryptOPWOnly - optimize %s using wrong upw should fail!\n", outFile)
+	}
+
+	// Optimize empty upw
+	t.Log("Optimize empty upw")
+	config = pdfcpu.NewDefaultConfiguration()
+	config.UserPW = ""
+	_, err = Process(OptimizeCommand(outFile, outFile, config))
+	if err != nil {
+		t.Fatalf("TestEncryptOPWOnly - optimize %s using upw: %v\n", outFile, err)
+	}
+
+	// Change opw wrong upw
+	t.Log("ChangeOwnerPW wrong upw fails")
+	config = pdfcpu.NewDefaultConfiguration()
+	config.UserPW = "upw"
+	pwOld := "opw"
+	pwNew := "opwNew"
+	_, err = Process(ChangeOwnerPWCommand(outFile, outFile, config, &pwOld, &pwNew))
+	if err == nil {
+		t.Fatalf("TestEncryptOPWOnly - %s change opw using wrong upw should fail\n", outFile)
+	}
+
+	// Change opw wrong opwOld
+	t.Log("ChangeOwnerPW wrong opwOld fails")
+	config = pdfcpu.NewDefaultConfiguration()
+	config.UserPW = ""
+	pwOld = "opwOldWrong"
+	pwNew = "opwNew"
+	_, err = Process(ChangeOwnerPWCommand(outFile, outFile, config, &pwOld, &pwNew))
+	if err == nil {
+		t.Fatalf("TestEncryptOPWOnly - %s change opw using wrong opwOld should fail\n", outFile)
+	}
+
+	// Change opw
+	t.Log("ChangeOwnerPW")
+	config = pdfcpu.NewDefaultConfiguration()
+	config.UserPW = ""
+	pwOld = "opw"
+	pwNew = "opwNew"
+	_, err = Process(ChangeOwnerPWCommand(outFile, outFile, config, &pwOld, &pwNew))
+	if err != nil {
+		t.Fatalf("TestEncryptOPWOnly - %s change opw: %v\n", outFile, err)
+	}
+
+	// Decrypt wrong upw
+	t.Log("Decrypt wrong upw fails")
+	config = pdfcpu.NewDefaultConfiguration()
+	config.UserPW = "upwWrong"
+	_, err = Process(DecryptCommand(outFile, outFile, config))
+	if err == nil {
+		t.Fatalf("TestEncryptOPWOnly - %s decrypt using wrong upw should fail \n", outFile)
+	}
+
+	// Decrypt wrong opw succeeds because of fallback to empty upw.
+	t.Log("Decrypt wrong opw succeeds because of fallback to empty upw")
+	config = pdfcpu.NewDefaultConfiguration()
+	config.OwnerPW = "opw"
+	_, err = Process(DecryptCommand(outFile, outFile, config))
+	if err != nil {
+		t.Fatalf("TestEncryptOPWOnly - %s decrypt using opw: %v\n", outFile, err)
+	}
+
+}
+
+func TestEncrypt(t *testing.T) {
+
+	t.Log("running TestEncrypt..")
+
+	inFile := filepath.Join(inDir, "5116.DCT_Filter.pdf")
+	outFile := filepath.Join(outDir, "test.pdf")
+
+	// Encrypt opw and upw
+	t.Log("Encrypt")
+	config := pdfcpu.NewDefaultConfiguration()
+	config.UserPW = "upw"
+	config.OwnerPW = "opw"
+	_, err := Process(EncryptCommand(inFile, outFile, config))
+	if err != nil {
 		t.Fatalf("TestEncrypt - encrypt to %s: %v\n", outFile, err)
 	}
 
-	// Validate wrong opw
-	t.Log("Validate wrong opw fails")
+	// Validate wrong opw
+	t.Log("Validate wrong opw fails")
+	config = pdfcpu.NewDefaultConfiguration()
+	config.OwnerPW = "opwWrong"
+	_, err = Process(ValidateCommand(outFile, config))
+	if err == nil {
+		t.Fatalf("TestEncrypt - validate %s using wrong opw should fail!\n", outFile)
+	}
+
+	// Validate opw
+	t.Log("Validate opw")
+	config = pdfcpu.NewDefaultConfiguration()
+	config.OwnerPW = "opw"
+	_, err = Process(ValidateCommand(outFile, config))
+	if err != nil {
+		t.Fatalf("TestEncrypt - validate %s using opw: %v\n", outFile, err)
+	}
+
+	// Validate wrong upw
+	t.Log("Validate wrong upw fails")
+	config = pdfcpu.NewDefaultConfiguration()
+	config.UserPW = "upwWrong"
+	_, err = Process(ValidateCommand(outFile, config))
+	if err == nil {
+		t.Fatalf("TestEncrypt - validate %s using wrong upw should fail!\n", outFile)
+	}
+
+	// Validate upw
+	t.Log("Validate upw")
+	config = pdfcpu.NewDefaultConfiguration()
+	config.UserPW = "upw"
+	_, err = Process(ValidateCommand(outFile, config))
+	if err != nil {
+		t.Fatalf("TestEncrypt - validate %s using upw: %v\n", outFile, err)
+	}
+
+	// Change upw to "" = remove document open password.
+	t.Log("ChangeUserPW to \"\"")
+	config = pdfcpu.NewDefaultConfiguration()
+	config.OwnerPW = "opw"
+	pwOld := "upw"
+	pwNew := ""
+	_, err = Process(ChangeUserPWCommand(outFile, outFile, config, &pwOld, &pwNew))
+	if err != nil {
+		t.Fatalf("TestEncrypt - %s change userPW to \"\": %v\n", outFile, err)
+	}
+
+	// Validate upw
+	t.Log("Validate upw")
+	config = pdfcpu.NewDefaultConfiguration()
+	config.UserPW = ""
+	_, err = Process(ValidateCommand(outFile, config))
+	if err != nil {
+		t.Fatalf("TestEncrypt - validate %s using upw: %v\n", outFile, err)
+	}
+
+	// Validate no pw
+	t.Log("Validate upw")
+	config = pdfcpu.NewDefaultConfiguration()
+	_, err = Process(ValidateCommand(outFile, config))
+	if err != nil {
+		t.Fatalf("TestEncrypt - validate %s: %v\n", outFile, err)
+	}
+
+	// Change opw
+	t.Log("ChangeOwnerPW")
+	config = pdfcpu.NewDefaultConfiguration()
+	config.UserPW = ""
+	pwOld = "opw"
+	pwNew = "opwNew"
+	_, err = Process(ChangeOwnerPWCommand(outFile, outFile, config, &pwOld, &pwNew))
+	if err != nil {
+		t.Fatalf("TestEncrypt - %s change opw: %v\n", outFile, err)
+	}
+
+	// Decrypt wrong upw
+	t.Log("Decrypt wrong upw fails")
+	config = pdfcpu.NewDefaultConfiguration()
+	config.UserPW = "upwWrong"
+	_, err = Process(DecryptCommand(outFile, outFile, config))
+	if err == nil {
+		t.Fatalf("TestEncrypt - %s decrypt using wrong upw should fail\n", outFile)
+	}
+
+	// Decrypt wrong opw succeeds on empty upw
+	t.Log("Decrypt wrong opw succeeds on empty upw")
+	config = pdfcpu.NewDefaultConfiguration()
+	config.OwnerPW = "opwWrong"
+	_, err = Process(DecryptCommand(outFile, outFile, config))
+	if err != nil {
+		t.Fatalf("TestEncrypt - %s decrypt wrong opw, empty upw: %v\n", outFile, err)
+	}
+}
+
+func encryptDecrypt(fileName string, config *pdfcpu.Configuration, t *testing.T) {
+
+	inFile := filepath.Join(inDir, fileName)
+	outFile := filepath.Join(outDir, "test.pdf")
+
+	t.Log(inFile)
+
+	// Encrypt
+	t.Log("Encrypt")
+	_, err := Process(EncryptCommand(inFile, outFile, config))
+	if err != nil {
+		t.Fatalf("TestEncryptDecrypt - encrypt %s: %v\n", outFile, err)
+	}
+
+	// Encrypt already encrypted
+	t.Log("Encrypt already encrypted")
+	config = pdfcpu.NewDefaultConfiguration()
+	config.UserPW = "upw"
+	config.OwnerPW = "opw"
+	_, err = Process(EncryptCommand(outFile, outFile, config))
+	if err == nil {
+		t.Fatalf("TestEncryptDecrypt - encrypt encrypted %s\n", outFile)
+	}
+
+	// Validate using wrong owner pw
+	t.Log("Validate wrong ownerPW")
+	config = pdfcpu.NewDefaultConfiguration()
+	config.UserPW = "upw"
+	config.OwnerPW = "opwWrong"
+	_, err = Process(ValidateCommand(outFile, config))
+	if err != nil {
+		t.Fatalf("TestEncryptDecrypt - validate %s using wrong ownerPW: %v\n", outFile, err)
+	}
+
+	// Optimize using wrong owner pw
+	t.Log("Optimize wrong ownerPW")
+	config = pdfcpu.NewDefaultConfiguration()
+	config.UserPW = "upw"
+	config.OwnerPW = "opwWrong"
+	_, err = Process(OptimizeCommand(outFile, outFile, config))
+	if err != nil {
+		t.Fatalf("TestEncryptDecrypt - optimize %s using wrong ownerPW: %v\n", outFile, err)
+	}
+
+	// Trim using wrong owner pw, falls back to upw and fails with insufficient permissions.
+	t.Log("Trim wrong ownerPW, fallback to upw and fail with insufficient permissions.")
 	config = pdfcpu.NewDefaultConfiguration()
+	config.UserPW = "upw"
 	config.OwnerPW = "opwWrong"
-	_, err = Process(ValidateCommand(outFile, config))
+	// pageSelection = nil, writes w/o trimming anything, but sufficient for testing.
+	_, err = Process(TrimCommand(outFile, outFile, nil, config))
 	if err == nil {
-		t.Fatalf("TestEncrypt - validate %s using wrong opw should fail!\n", outFile)
+		t.Fatalf("TestEncryptDecrypt - trim %s using wrong ownerPW should fail: \n", outFile)
 	}
 
-	// Validate opw
-	t.Log("Validate opw")
+	// Split using wrong owner pw, falls back to upw and fails with insufficient permissions.
+	t.Log("Split wrong ownerPW, fallback to upw and fail with insufficient permissions.")
+	config = pdfcpu.NewDefaultConfiguration()
+	config.UserPW = "upw"
+	config.OwnerPW = "opwWrong"
+	_, err = Process(SplitCommand(outFile, outDir, config))
+	if err == nil {
+		t.Fatalf("TestEncryptDecrypt - split %s using wrong ownerPW should fail: \n", outFile)
+	}
+
+	// Add permissions
+	t.Log("Add user access permissions")
+	config = pdfcpu.NewDefaultConfiguration()
+	config.UserPW = "upw"
+	config.OwnerPW = "opw"
+	config.UserAccessPermissions = pdfcpu.PermissionsAll
+	_, err = Process(AddPermissionsCommand(outFile, config))
+	if err != nil {
+		t.Fatalf("TestEncryptDecrypt - %s add permissions: %v\n", outFile, err)
+	}
+
+	// Split using wrong owner pw, falls back to upw
+	t.Log("Split wrong ownerPW")
+	config = pdfcpu.NewDefaultConfiguration()
+	config.UserPW = "upw"
+	config.OwnerPW = "opwWrong"
+	_, err = Process(SplitCommand(outFile, outDir, config))
+	if err != nil {
+		t.Fatalf("TestEncryptDecrypt - split %s using wrong ownerPW: %v\n", outFile, err)
+	}
+
+	// Validate
+	t.Log("Validate")
 	config = pdfcpu.NewDefaultConfiguration()
+	config.UserPW = "upw"
 	config.OwnerPW = "opw"
 	_, err = Process(ValidateCommand(outFile, config))
 	if err != nil {
-		t.Fatalf("TestEncrypt - validate %s using opw: %v\n", outFile, err)
+		t.Fatalf("TestEncryptDecrypt - validate %s: %v\n", outFile, err)
 	}
 
-	// Validate wrong upw
-	t.Log("Validate wrong upw fails")
+	// ChangeUserPW using wrong userpw
+	t.Log("ChangeUserPW wrong userpw")
+	config = pdfcpu.NewDefaultConfiguration()
+	config.OwnerPW = "opw"
+	pwOld := "upwWrong"
+	pwNew := "upwNew"
+	_, err = Process(ChangeUserPWCommand(outFile, outFile, config, &pwOld, &pwNew))
+	if err == nil {
+		t.Fatalf("TestEncryption - %s change userPW using wrong userPW should fail:\n", outFile)
+	}
+
+	// ChangeUserPW
+	t.Log("ChangeUserPW")
+	config = pdfcpu.NewDefaultConfiguration()
+	config.OwnerPW = "opw"
+	pwOld = "upw"
+	pwNew = "upwNew"
+	_, err = Process(ChangeUserPWCommand(outFile, outFile, config, &pwOld, &pwNew))
+	if err != nil {
+		t.Fatalf("TestEncryption - change userPW %s: %v\n", outFile, err)
+	}
+
+	// ChangeOwnerPW
+	t.Log("ChangeOwnerPW")
+	config = pdfcpu.NewDefaultConfiguration()
+	config.UserPW = "upwNew"
+	pwOld = "opw"
+	pwNew = "opwNew"
+	_, err = Process(ChangeOwnerPWCommand(outFile, outFile, config, &pwOld, &pwNew))
+	if err != nil {
+		t.Fatalf("TestEncryption - change ownerPW %s: %v\n", outFile, err)
+	}
+
+	// Decrypt using wrong pw
+	t.Log("\nDecrypt using wrong pw")
 	config = pdfcpu.NewDefaultConfiguration()
 	config.UserPW = "upwWrong"
-	_, err = Process(ValidateCommand(outFile, config))
+	config.OwnerPW = "opwWrong"
+	_, err = Process(DecryptCommand(outFile, outFile, config))
 	if err == nil {
-		t.Fatalf("TestEncrypt - validate %s using wrong upw should fail!\n", outFile)
+		t.Fatalf("TestEncryptDecrypt - decrypt using wrong pw %s\n", outFile)
 	}
 
-	// Validate upw
-	t.Log("Validate upw")
+	// Decrypt
+	t.Log("\nDecrypt")
+	config = pdfcpu.NewDefaultConfiguration()
+	config.UserPW = "upwNew"
+	config.OwnerPW = "opwNew"
+	_, err = Process(DecryptCommand(outFile, outFile, config))
+	if err != nil {
+		t.Fatalf("TestEncryptDecrypt - decrypt %s: %v\n", outFile, err)
+	}
+
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+
+	config := pdfcpu.NewDefaultConfiguration()
+	config.UserPW = "upw"
+	config.OwnerPW = "opw"
+	encryptDecrypt("5116.DCT_Filter.pdf", config, t)
+
 	config = pdfcpu.NewDefaultConfiguration()
 	config.UserPW = "upw"
-	_, err = Process(ValidateCommand(outFile, config))
+	config.OwnerPW = "opw"
+	config.EncryptUsingAES = false
+	config.EncryptKeyLength = 40
+	encryptDecrypt("networkProgr.pdf", config, t)
+
+	config = pdfcpu.NewDefaultConfiguration()
+	config.UserPW = "upw"
+	config.OwnerPW = "opw"
+	config.EncryptKeyLength = 256
+	encryptDecrypt("5116.DCT_Filter.pdf", config, t)
+
+	// The deprecated EncryptUsing128BitKey still selects 128 bit keys.
+	config = pdfcpu.NewDefaultConfiguration()
+	config.UserPW = "upw"
+	config.OwnerPW = "opw"
+	config.EncryptKeyLength = 0
+	config.EncryptUsing128BitKey = true
+	outFile := filepath.Join(outDir, "test128.pdf")
+	_, err := Process(EncryptCommand(filepath.Join(inDir, "networkProgr.pdf"), outFile, config))
 	if err != nil {
-		t.Fatalf("TestEncrypt - validate %s using upw: %v\n", outFile, err)
+		t.Fatalf("TestEncryptDecrypt - encrypt %s: %v\n", outFile, err)
+	}
+
+	config = pdfcpu.NewDefaultConfiguration()
+	config.UserPW = "upw"
+	config.OwnerPW = "opw"
+	ctx, err := Read(outFile, config)
+	if err != nil {
+		t.Fatalf("TestEncryptDecrypt - read %s: %v\n", outFile, err)
+	}
+
+	if ctx.E == nil || ctx.E.L != 128 || ctx.E.R != 4 {
+		t.Fatalf("TestEncryptDecrypt - %s: expected 128 bit key, got %+v\n", outFile, ctx.E)
+	}
+
+	// Existing configurations turning off EncryptUsing128BitKey still get 40 bit RC4 keys.
+	config = pdfcpu.NewDefaultConfiguration()
+	config.UserPW = "upw"
+	config.OwnerPW = "opw"
+	config.EncryptUsingAES = false
+	config.EncryptUsing128BitKey = false
+	outFile = filepath.Join(outDir, "test40.pdf")
+	_, err = Process(EncryptCommand(filepath.Join(inDir, "networkProgr.pdf"), outFile, config))
+	if err != nil {
+		t.Fatalf("TestEncryptDecrypt - encrypt %s: %v\n", outFile, err)
+	}
+
+	config = pdfcpu.NewDefaultConfiguration()
+	config.UserPW = "upw"
+	config.OwnerPW = "opw"
+	ctx, err = Read(outFile, config)
+	if err != nil {
+		t.Fatalf("TestEncryptDecrypt - read %s: %v\n", outFile, err)
+	}
+
+	if ctx.E == nil || ctx.E.L != 40 || ctx.E.R != 2 {
+		t.Fatalf("TestEncryptDecrypt - %s: expected 40 bit key, got %+v\n", outFile, ctx.E)
+	}
+}
+
+func testRecipient(cn string, t *testing.T) (*x509.Certificate, *rsa.PrivateKey) {
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("testRecipient: %v\n", err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("testRecipient: %v\n", err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("testRecipient: %v\n", err)
+	}
+
+	return cert, key
+}
+
+func TestEncryptDecryptPubSec(t *testing.T) {
+
+	cert1, key1 := testRecipient("recipient1", t)
+	cert2, key2 := testRecipient("recipient2", t)
+	cert3, key3 := testRecipient("stranger", t)
+
+	inFile := filepath.Join(inDir, "5116.DCT_Filter.pdf")
+	outFile := filepath.Join(outDir, "test.pdf")
+
+	for _, keyLength := range []int{128, 256} {
+
+		// Encrypt for recipient1 and recipient2
+		config := pdfcpu.NewDefaultConfiguration()
+		config.EncryptKeyLength = keyLength
+		config.EncryptRecipients = []*x509.Certificate{cert1, cert2}
+		_, err := Process(EncryptCommand(inFile, outFile, config))
+		if err != nil {
+			t.Fatalf("TestEncryptDecryptPubSec - encrypt %s: %v\n", outFile, err)
+		}
+
+		// Validate without recipient
+		config = pdfcpu.NewDefaultConfiguration()
+		_, err = Process(ValidateCommand(outFile, config))
+		if err == nil {
+			t.Fatalf("TestEncryptDecryptPubSec - validate %s without recipient should fail\n", outFile)
+		}
+
+		// Validate using the wrong recipient
+		config = pdfcpu.NewDefaultConfiguration()
+		config.DecryptCert, config.DecryptKey = cert3, key3
+		_, err = Process(ValidateCommand(outFile, config))
+		if err == nil {
+			t.Fatalf("TestEncryptDecryptPubSec - validate %s using wrong recipient should fail\n", outFile)
+		}
+
+		// Validate as recipient2
+		config = pdfcpu.NewDefaultConfiguration()
+		config.DecryptCert, config.DecryptKey = cert2, key2
+		_, err = Process(ValidateCommand(outFile, config))
+		if err != nil {
+			t.Fatalf("TestEncryptDecryptPubSec - validate %s: %v\n", outFile, err)
+		}
+
+		// Trim fails with insufficient permissions.
+		config = pdfcpu.NewDefaultConfiguration()
+		config.DecryptCert, config.DecryptKey = cert2, key2
+		_, err = Process(TrimCommand(outFile, outFile, nil, config))
+		if err == nil {
+			t.Fatalf("TestEncryptDecryptPubSec - trim %s should fail\n", outFile)
+		}
+
+		// Decrypt as recipient1
+		config = pdfcpu.NewDefaultConfiguration()
+		config.DecryptCert, config.DecryptKey = cert1, key1
+		_, err = Process(DecryptCommand(outFile, outFile, config))
+		if err != nil {
+			t.Fatalf("TestEncryptDecryptPubSec - decrypt %s: %v\n", outFile, err)
+		}
+
+		// Validate decrypted file
+		config = pdfcpu.NewDefaultConfiguration()
+		_, err = Process(ValidateCommand(outFile, config))
+		if err != nil {
+			t.Fatalf("TestEncryptDecryptPubSec - validate decrypted %s: %v\n", outFile, err)
+		}
+	}
+}
+
+func TestDecryptPubSec(t *testing.T) {
+
+	// The recipient envelopes of these files were created by OpenSSL:
+	// s4.pdf: adbe.pkcs7.s4, RC4 40 bit, RC2 40 bit envelope, recipient identified by issuer and serial number.
+	// s5.pdf: adbe.pkcs7.s5, AESV2, Triple DES envelope, recipient identified by subject key identifier.
+
+	dir := filepath.Join(inDir, "pubsec")
+	recipientFile := filepath.Join(dir, "recipient.pem")
+
+	key, err := pdfcpu.ReadPrivateKey(recipientFile)
+	if err != nil {
+		t.Fatalf("TestDecryptPubSec: %v\n", err)
+	}
+
+	certs, err := pdfcpu.ReadCertificates(recipientFile)
+	if err != nil {
+		t.Fatalf("TestDecryptPubSec: %v\n", err)
+	}
+
+	stranger, strangerKey := testRecipient("stranger", t)
+
+	for _, fileName := range []string{"s4.pdf", "s5.pdf"} {
+
+		inFile := filepath.Join(dir, fileName)
+		outFile := filepath.Join(outDir, "pubsec_"+fileName)
+
+		// Validate using the wrong recipient
+		config := pdfcpu.NewDefaultConfiguration()
+		config.DecryptCert, config.DecryptKey = stranger, strangerKey
+		_, err = Process(ValidateCommand(inFile, config))
+		if err == nil {
+			t.Fatalf("TestDecryptPubSec - validate %s using wrong recipient should fail\n", inFile)
+		}
+
+		// Decrypt
+		config = pdfcpu.NewDefaultConfiguration()
+		config.DecryptCert, config.DecryptKey = certs[0], key
+		_, err = Process(DecryptCommand(inFile, outFile, config))
+		if err != nil {
+			t.Fatalf("TestDecryptPubSec - decrypt %s: %v\n", inFile, err)
+		}
+
+		// Validate decrypted file
+		config = pdfcpu.NewDefaultConfiguration()
+		_, err = Process(ValidateCommand(outFile, config))
+		if err != nil {
+			t.Fatalf("TestDecryptPubSec - validate decrypted %s: %v\n", outFile, err)
+		}
+	}
+}
+
+func TestVerifySignaturesClosedFile(t *testing.T) {
+
+	inFile := filepath.Join(inDir, "5116.DCT_Filter.pdf")
+
+	// Read closes the file after reading.
+	ctx, err := Read(inFile, pdfcpu.NewDefaultConfiguration())
+	if err != nil {
+		t.Fatalf("TestVerifySignaturesClosedFile: %v\n", err)
+	}
+
+	_, err = pdfcpu.VerifySignatures(ctx, nil)
+	if err == nil || !strings.Contains(err.Error(), "original file not readable") {
+		t.Fatalf("TestVerifySignaturesClosedFile: unexpected error for closed file: %v\n", err)
+	}
+
+	ctx.Read.ReadSeeker = nil
+
+	_, err = pdfcpu.VerifySignatures(ctx, nil)
+	if err == nil || !strings.Contains(err.Error(), "missing reader") {
+		t.Fatalf("TestVerifySignaturesClosedFile: unexpected error for missing reader: %v\n", err)
+	}
+}
+
+func signFile(fileIn, fileOut string, sig *pdfcpu.Signature, config *pdfcpu.Configuration, t *testing.T) {
+
+	_, err := Process(SignCommand(fileIn, fileOut, sig, config))
+	if err != nil {
+		t.Fatalf("TestSign - sign %s: %v\n", fileIn, err)
+	}
+
+	// The original file needs to be a prefix of the signed file.
+	b1, err := ioutil.ReadFile(fileIn)
+	if err != nil {
+		t.Fatalf("TestSign: %v\n", err)
+	}
+
+	b2, err := ioutil.ReadFile(fileOut)
+	if err != nil {
+		t.Fatalf("TestSign: %v\n", err)
+	}
+
+	if !bytes.HasPrefix(b2, b1) {
+		t.Fatalf("TestSign - %s: original revision modified\n", fileOut)
+	}
+
+	_, err = Process(ValidateCommand(fileOut, config))
+	if err != nil {
+		t.Fatalf("TestSign - validate %s: %v\n", fileOut, err)
+	}
+}
+
+func TestSign(t *testing.T) {
+
+	cert, key := testRecipient("signer", t)
+
+	for _, fileName := range []string{
+		"5116.DCT_Filter.pdf", // xref table
+		"Acroforms2.pdf",      // existing form fields
+		"empty.pdf",           // xref stream
+	} {
+
+		inFile := filepath.Join(inDir, fileName)
+		outFile1 := filepath.Join(outDir, "signed1.pdf")
+		outFile2 := filepath.Join(outDir, "signed2.pdf")
+
+		config := pdfcpu.NewDefaultConfiguration()
+
+		// Invisible signature.
+		sig := &pdfcpu.Signature{Key: key, Certs: []*x509.Certificate{cert}, Reason: "Test"}
+		signFile(inFile, outFile1, sig, config, t)
+
+		// Visible countersignature.
+		sig = &pdfcpu.Signature{Key: key, Certs: []*x509.Certificate{cert}, Rect: types.NewRectangle(50, 50, 250, 100)}
+		signFile(outFile1, outFile2, sig, config, t)
 	}
 
-	// Change upw to "" = remove document open password.
-	t.Log("ChangeUserPW to \"\"")
-	config = pdfcpu.NewDefaultConfiguration()
+	// Sign an encrypted file.
+	inFile := filepath.Join(inDir, "5116.DCT_Filter.pdf")
+	outFile1 := filepath.Join(outDir, "encrypted.pdf")
+	outFile2 := filepath.Join(outDir, "signed.pdf")
+
+	config := pdfcpu.NewDefaultConfiguration()
+	config.UserPW = "upw"
 	config.OwnerPW = "opw"
-	pwOld := "upw"
-	pwNew := ""
-	_, err = Process(ChangeUserPWCommand(outFile, outFile, config, &pwOld, &pwNew))
+
+	_, err := Process(EncryptCommand(inFile, outFile1, config))
 	if err != nil {
-		t.Fatalf("TestEncrypt - %s change userPW to \"\": %v\n", outFile, err)
+		t.Fatalf("TestSign - encrypt %s: %v\n", inFile, err)
 	}
 
-	// Validate upw
-	t.Log("Validate upw")
-	config = pdfcpu.NewDefaultConfiguration()
-	config.UserPW = ""
-	_, err = Process(ValidateCommand(outFile, config))
+	sig := &pdfcpu.Signature{Key: key, Certs: []*x509.Certificate{cert}, Rect: types.NewRectangle(50, 50, 250, 100)}
+	signFile(outFile1, outFile2, sig, config, t)
+
+	// Sign in place.
+	inFile = filepath.Join(outDir, "signInPlace.pdf")
+	err = copyFile(filepath.Join(inDir, "5116.DCT_Filter.pdf"), inFile)
 	if err != nil {
-		t.Fatalf("TestEncrypt - validate %s using upw: %v\n", outFile, err)
+		t.Fatalf("TestSign: %v\n", err)
 	}
 
-	// Validate no pw
-	t.Log("Validate upw")
-	config = pdfcpu.NewDefaultConfiguration()
-	_, err = Process(ValidateCommand(outFile, config))
+	b, err := ioutil.ReadFile(inFile)
 	if err != nil {
-		t.Fatalf("TestEncrypt - validate %s: %v\n", outFile, err)
+		t.Fatalf("TestSign: %v\n", err)
 	}
 
-	// Change opw
-	t.Log("ChangeOwnerPW")
-	config = pdfcpu.NewDefaultConfiguration()
-	config.UserPW = ""
-	pwOld = "opw"
-	pwNew = "opwNew"
-	_, err = Process(ChangeOwnerPWCommand(outFile, outFile, config, &pwOld, &pwNew))
+	sig = &pdfcpu.Signature{Key: key, Certs: []*x509.Certificate{cert}}
+	_, err = Process(SignCommand(inFile, inFile, sig, pdfcpu.NewDefaultConfiguration()))
 	if err != nil {
-		t.Fatalf("TestEncrypt - %s change opw: %v\n", outFile, err)
+		t.Fatalf("TestSign - sign %s in place: %v\n", inFile, err)
 	}
 
-	// Decrypt wrong upw
-	t.Log("Decrypt wrong upw fails")
-	config = pdfcpu.NewDefaultConfiguration()
-	config.UserPW = "upwWrong"
-	_, err = Process(DecryptCommand(outFile, outFile, config))
+	checkIncrement(b, inFile, pdfcpu.NewDefaultConfiguration(), t)
+
+	ctx := readContextFromFile(inFile, pdfcpu.NewDefaultConfiguration(), t)
+
+	results, err := pdfcpu.VerifySignatures(ctx, []*x509.Certificate{cert})
+	if err != nil || len(results) != 1 || !results[0].Verified() || !results[0].WholeDocument {
+		t.Fatalf("TestSign - %s: %v %v\n", inFile, err, results)
+	}
+
+	// Signing with a key not matching the certificate fails.
+	_, key2 := testRecipient("stranger", t)
+	sig = &pdfcpu.Signature{Key: key2, Certs: []*x509.Certificate{cert}}
+	_, err = Process(SignCommand(inFile, outFile1, sig, pdfcpu.NewDefaultConfiguration()))
 	if err == nil {
-		t.Fatalf("TestEncrypt - %s decrypt using wrong upw should fail\n", outFile)
+		t.Fatalf("TestSign - sign %s using the wrong key should fail\n", inFile)
 	}
+}
 
-	// Decrypt wrong opw succeeds on empty upw
-	t.Log("Decrypt wrong opw succeeds on empty upw")
-	config = pdfcpu.NewDefaultConfiguration()
-	config.OwnerPW = "opwWrong"
-	_, err = Process(DecryptCommand(outFile, outFile, config))
+func verifySignatures(fileName string, trusted []*x509.Certificate, t *testing.T) []pdfcpu.SignatureResult {
+
+	ctx := readContextFromFile(fileName, pdfcpu.NewDefaultConfiguration(), t)
+
+	results, err := pdfcpu.VerifySignatures(ctx, trusted)
 	if err != nil {
-		t.Fatalf("TestEncrypt - %s decrypt wrong opw, empty upw: %v\n", outFile, err)
+		t.Fatalf("TestVerifySignatures - verify %s: %v\n", fileName, err)
+	}
+
+	if len(results) != 2 {
+		t.Fatalf("TestVerifySignatures - verify %s: want 2 signatures, got %d\n", fileName, len(results))
 	}
+
+	return results
 }
 
-func encryptDecrypt(fileName string, config *pdfcpu.Configuration, t *testing.T) {
+func TestVerifySignatures(t *testing.T) {
 
-	inFile := filepath.Join(inDir, fileName)
-	outFile := filepath.Join(outDir, "test.pdf")
+	cert, key := testRecipient("signer", t)
+	trusted := []*x509.Certificate{cert}
 
-	t.Log(inFile)
+	inFile := filepath.Join(inDir, "5116.DCT_Filter.pdf")
+	outFile1 := filepath.Join(outDir, "signed1.pdf")
+	outFile2 := filepath.Join(outDir, "signed2.pdf")
 
-	// Encrypt
-	t.Log("Encrypt")
-	_, err := Process(EncryptCommand(inFile, outFile, config))
+	config := pdfcpu.NewDefaultConfiguration()
+
+	sig := &pdfcpu.Signature{Key: key, Certs: trusted, Reason: "Test"}
+	signFile(inFile, outFile1, sig, config, t)
+
+	sig = &pdfcpu.Signature{Key: key, Certs: trusted}
+	signFile(outFile1, outFile2, sig, config, t)
+
+	results := verifySignatures(outFile2, trusted, t)
+	for _, sr := range results {
+		if !sr.Verified() {
+			t.Fatalf("TestVerifySignatures - %s: %s\n", outFile2, sr)
+		}
+		// Without timestamp token the signing time is claimed by the signer.
+		if sr.Timestamped || !strings.Contains(sr.String(), "(claimed by signer)") {
+			t.Fatalf("TestVerifySignatures - %s: signing time not reported as claimed by signer\n%s\n", outFile2, sr)
+		}
+	}
+
+	// The first signature covers a prior revision only.
+	if results[0].WholeDocument || !results[1].WholeDocument || results[0].Revision+1 != results[1].Revision {
+		t.Fatalf("TestVerifySignatures - %s: wrong coverage\n%s\n%s\n", outFile2, results[0], results[1])
+	}
+
+	// Without trust store.
+	for _, sr := range verifySignatures(outFile2, nil, t) {
+		if !sr.Intact || !sr.Valid || sr.Trusted {
+			t.Fatalf("TestVerifySignatures - %s: %s\n", outFile2, sr)
+		}
+	}
+
+	// Tamper with the signed bytes.
+	b, err := ioutil.ReadFile(outFile2)
 	if err != nil {
-		t.Fatalf("TestEncryptDecrypt - encrypt %s: %v\n", outFile, err)
+		t.Fatalf("TestVerifySignatures: %v\n", err)
 	}
 
-	// Encrypt already encrypted
-	t.Log("Encrypt already encrypted")
-	config = pdfcpu.NewDefaultConfiguration()
-	config.UserPW = "upw"
-	config.OwnerPW = "opw"
-	_, err = Process(EncryptCommand(outFile, outFile, config))
-	if err == nil {
-		t.Fatalf("TestEncryptDecrypt - encrypt encrypted %s\n", outFile)
+	i := bytes.Index(b, []byte("(Test)"))
+	if i < 0 {
+		t.Fatalf("TestVerifySignatures - %s: missing Reason\n", outFile2)
 	}
+	b[i+2] = 'o'
 
-	// Validate using wrong owner pw
-	t.Log("Validate wrong ownerPW")
-	config = pdfcpu.NewDefaultConfiguration()
-	config.UserPW = "upw"
-	config.OwnerPW = "opwWrong"
-	_, err = Process(ValidateCommand(outFile, config))
+	err = ioutil.WriteFile(outFile2, b, os.ModePerm)
 	if err != nil {
-		t.Fatalf("TestEncryptDecrypt - validate %s using wrong ownerPW: %v\n", outFile, err)
+		t.Fatalf("TestVerifySignatures: %v\n", err)
 	}
 
-	// Optimize using wrong owner pw
-	t.Log("Optimize wrong ownerPW")
-	config = pdfcpu.NewDefaultConfiguration()
-	config.UserPW = "upw"
-	config.OwnerPW = "opwWrong"
-	_, err = Process(OptimizeCommand(outFile, outFile, config))
+	for _, sr := range verifySignatures(outFile2, trusted, t) {
+		if sr.Intact || sr.Verified() {
+			t.Fatalf("TestVerifySignatures - %s: modification not detected\n", outFile2)
+		}
+	}
+
+	config.TrustedCerts = trusted
+	list, err := Process(VerifySignaturesCommand(outFile1, config))
 	if err != nil {
-		t.Fatalf("TestEncryptDecrypt - optimize %s using wrong ownerPW: %v\n", outFile, err)
+		t.Fatalf("TestVerifySignatures - verify %s: %v\n", outFile1, err)
+	}
+	for _, s := range list {
+		t.Log(s)
 	}
+}
 
-	// Trim using wrong owner pw, falls back to upw and fails with insufficient permissions.
-	t.Log("Trim wrong ownerPW, fallback to upw and fail with insufficient permissions.")
-	config = pdfcpu.NewDefaultConfiguration()
-	config.UserPW = "upw"
-	config.OwnerPW = "opwWrong"
-	// pageSelection = nil, writes w/o trimming anything, but sufficient for testing.
-	_, err = Process(TrimCommand(outFile, outFile, nil, config))
-	if err == nil {
-		t.Fatalf("TestEncryptDecrypt - trim %s using wrong ownerPW should fail: \n", outFile)
+// checkIncrement ensures fileName is the original revision b followed by a valid incremental update.
+func checkIncrement(b []byte, fileName string, config *pdfcpu.Configuration, t *testing.T) {
+
+	b2, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		t.Fatalf("TestIncrementalUpdate: %v\n", err)
 	}
 
-	// Split using wrong owner pw, falls back to upw and fails with insufficient permissions.
-	t.Log("Split wrong ownerPW, fallback to upw and fail with insufficient permissions.")
-	config = pdfcpu.NewDefaultConfiguration()
-	config.UserPW = "upw"
-	config.OwnerPW = "opwWrong"
-	_, err = Process(SplitCommand(outFile, outDir, config))
-	if err == nil {
-		t.Fatalf("TestEncryptDecrypt - split %s using wrong ownerPW should fail: \n", outFile)
+	if len(b2) <= len(b) || !bytes.HasPrefix(b2, b) {
+		t.Fatalf("TestIncrementalUpdate - %s: original revision modified\n", fileName)
 	}
 
-	// Add permissions
-	t.Log("Add user access permissions")
-	config = pdfcpu.NewDefaultConfiguration()
-	config.UserPW = "upw"
-	config.OwnerPW = "opw"
-	config.UserAccessPermissions = pdfcpu.PermissionsAll
-	_, err = Process(AddPermissionsCommand(outFile, config))
+	_, err = Process(ValidateCommand(fileName, config))
 	if err != nil {
-		t.Fatalf("TestEncryptDecrypt - %s add permissions: %v\n", outFile, err)
+		t.Fatalf("TestIncrementalUpdate - validate %s: %v\n", fileName, err)
 	}
+}
 
-	// Split using wrong owner pw, falls back to upw
-	t.Log("Split wrong ownerPW")
-	config = pdfcpu.NewDefaultConfiguration()
-	config.UserPW = "upw"
-	config.OwnerPW = "opwWrong"
-	_, err = Process(SplitCommand(outFile, outDir, config))
+func TestIncrementalUpdate(t *testing.T) {
+
+	// A watermark caches objects of the PDF it has been applied to.
+	newWatermark := func() *pdfcpu.Watermark {
+		wm, err := pdfcpu.ParseWatermarkDetails("Draft, d:2", true)
+		if err != nil {
+			t.Fatalf("TestIncrementalUpdate: %v\n", err)
+		}
+		return wm
+	}
+
+	for _, fileName := range []string{
+		"5116.DCT_Filter.pdf", // xref table
+		"empty.pdf",           // xref stream
+	} {
+
+		inFile := filepath.Join(outDir, "incr_"+fileName)
+		outFile := filepath.Join(outDir, "incrStamped_"+fileName)
+
+		err := copyFile(filepath.Join(inDir, fileName), inFile)
+		if err != nil {
+			t.Fatalf("TestIncrementalUpdate: %v\n", err)
+		}
+
+		b, err := ioutil.ReadFile(inFile)
+		if err != nil {
+			t.Fatalf("TestIncrementalUpdate: %v\n", err)
+		}
+
+		config := pdfcpu.NewDefaultConfiguration()
+		config.WriteIncrement = true
+
+		// Append in place.
+		_, err = Process(AddAttachmentsCommand(inFile, []string{filepath.Join(inDir, "test.wav")}, config))
+		if err != nil {
+			t.Fatalf("TestIncrementalUpdate - add attachment to %s: %v\n", fileName, err)
+		}
+		checkIncrement(b, inFile, config, t)
+
+		list, err := Process(ListAttachmentsCommand(inFile, config))
+		if err != nil || len(list) != 1 {
+			t.Fatalf("TestIncrementalUpdate - list attachments of %s: %v %v\n", fileName, list, err)
+		}
+
+		b, err = ioutil.ReadFile(inFile)
+		if err != nil {
+			t.Fatalf("TestIncrementalUpdate: %v\n", err)
+		}
+
+		_, err = Process(AddWatermarksCommand(inFile, outFile, []string{"1-"}, newWatermark(), config))
+		if err != nil {
+			t.Fatalf("TestIncrementalUpdate - watermark %s: %v\n", fileName, err)
+		}
+		checkIncrement(b, outFile, config, t)
+	}
+
+	// An incremental update not using new features leaves the catalog of a PDF 1.4 file alone.
+	cert, key := testRecipient("signer", t)
+	trusted := []*x509.Certificate{cert}
+
+	// The first signature adds an interactive form to the catalog.
+	inFile := filepath.Join(outDir, "incrSigned_Acroforms2.pdf")
+	outFile := filepath.Join(outDir, "incrSignedTwice_Acroforms2.pdf")
+
+	config := pdfcpu.NewDefaultConfiguration()
+	signFile(filepath.Join(inDir, "Acroforms2.pdf"), inFile, &pdfcpu.Signature{Key: key, Certs: trusted}, config, t)
+
+	rootObjNr := readContextFromFile(inFile, config, t).Root.ObjectNumber.Value()
+
+	b, err := ioutil.ReadFile(inFile)
 	if err != nil {
-		t.Fatalf("TestEncryptDecrypt - split %s using wrong ownerPW: %v\n", outFile, err)
+		t.Fatalf("TestIncrementalUpdate: %v\n", err)
 	}
 
-	// Validate
-	t.Log("Validate")
-	config = pdfcpu.NewDefaultConfiguration()
-	config.UserPW = "upw"
-	config.OwnerPW = "opw"
-	_, err = Process(ValidateCommand(outFile, config))
+	signFile(inFile, outFile, &pdfcpu.Signature{Key: key, Certs: trusted}, config, t)
+
+	b2, err := ioutil.ReadFile(outFile)
 	if err != nil {
-		t.Fatalf("TestEncryptDecrypt - validate %s: %v\n", outFile, err)
+		t.Fatalf("TestIncrementalUpdate: %v\n", err)
 	}
 
-	// ChangeUserPW using wrong userpw
-	t.Log("ChangeUserPW wrong userpw")
-	config = pdfcpu.NewDefaultConfiguration()
-	config.OwnerPW = "opw"
-	pwOld := "upwWrong"
-	pwNew := "upwNew"
-	_, err = Process(ChangeUserPWCommand(outFile, outFile, config, &pwOld, &pwNew))
-	if err == nil {
-		t.Fatalf("TestEncryption - %s change userPW using wrong userPW should fail:\n", outFile)
+	if bytes.Contains(b2[len(b):], []byte(fmt.Sprintf("\n%d 0 obj", rootObjNr))) {
+		t.Fatalf("TestIncrementalUpdate - %s: catalog rewritten\n", outFile)
 	}
 
-	// ChangeUserPW
-	t.Log("ChangeUserPW")
+	if v := readContextFromFile(outFile, config, t).Version(); v != pdfcpu.V14 {
+		t.Fatalf("TestIncrementalUpdate - %s: version %s, expected 1.4\n", outFile, pdfcpu.VersionString(v))
+	}
+
+	// An incremental update keeps existing signatures intact.
+	inFile = filepath.Join(inDir, "5116.DCT_Filter.pdf")
+	signedFile := filepath.Join(outDir, "signed.pdf")
+	outFile = filepath.Join(outDir, "signedStamped.pdf")
+
 	config = pdfcpu.NewDefaultConfiguration()
-	config.OwnerPW = "opw"
-	pwOld = "upw"
-	pwNew = "upwNew"
-	_, err = Process(ChangeUserPWCommand(outFile, outFile, config, &pwOld, &pwNew))
+	signFile(inFile, signedFile, &pdfcpu.Signature{Key: key, Certs: trusted}, config, t)
+
+	config.WriteIncrement = true
+	_, err = Process(AddWatermarksCommand(signedFile, outFile, []string{"1-"}, newWatermark(), config))
 	if err != nil {
-		t.Fatalf("TestEncryption - change userPW %s: %v\n", outFile, err)
+		t.Fatalf("TestIncrementalUpdate - watermark %s: %v\n", signedFile, err)
 	}
 
-	// ChangeOwnerPW
-	t.Log("ChangeOwnerPW")
-	config = pdfcpu.NewDefaultConfiguration()
-	config.UserPW = "upwNew"
-	pwOld = "opw"
-	pwNew = "opwNew"
-	_, err = Process(ChangeOwnerPWCommand(outFile, outFile, config, &pwOld, &pwNew))
+	results, err := pdfcpu.VerifySignatures(readContextFromFile(outFile, config, t), trusted)
+	if err != nil || len(results) != 1 {
+		t.Fatalf("TestIncrementalUpdate - verify %s: %v\n", outFile, err)
+	}
+	if sr := results[0]; !sr.Verified() || sr.WholeDocument {
+		t.Fatalf("TestIncrementalUpdate - %s: %s\n", outFile, sr)
+	}
+
+	// Changing permissions incrementally requires AES-256.
+	for _, keyLength := range []int{256, 128} {
+
+		encFile := filepath.Join(outDir, "incrEnc.pdf")
+
+		config = pdfcpu.NewDefaultConfiguration()
+		config.UserPW = "upw"
+		config.OwnerPW = "opw"
+		config.EncryptKeyLength = keyLength
+
+		_, err = Process(EncryptCommand(inFile, encFile, config))
+		if err != nil {
+			t.Fatalf("TestIncrementalUpdate - encrypt %s: %v\n", inFile, err)
+		}
+
+		b, err := ioutil.ReadFile(encFile)
+		if err != nil {
+			t.Fatalf("TestIncrementalUpdate: %v\n", err)
+		}
+
+		config = pdfcpu.NewDefaultConfiguration()
+		config.UserPW = "upw"
+		config.OwnerPW = "opw"
+		config.UserAccessPermissions = pdfcpu.PermissionsAll
+		config.WriteIncrement = true
+
+		_, err = Process(AddPermissionsCommand(encFile, config))
+		if keyLength != 256 {
+			if err == nil {
+				t.Fatalf("TestIncrementalUpdate - add permissions using %d bit key should fail\n", keyLength)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("TestIncrementalUpdate - add permissions to %s: %v\n", encFile, err)
+		}
+
+		config = pdfcpu.NewDefaultConfiguration()
+		config.UserPW = "upw"
+		checkIncrement(b, encFile, config, t)
+
+		list, err := Process(ListPermissionsCommand(encFile, config))
+		if err != nil {
+			t.Fatalf("TestIncrementalUpdate - list permissions of %s: %v\n", encFile, err)
+		}
+		for _, s := range list {
+			t.Log(s)
+		}
+	}
+}
+
+func TestRevisions(t *testing.T) {
+
+	cert, key := testRecipient("signer", t)
+	trusted := []*x509.Certificate{cert}
+
+	wm, err := pdfcpu.ParseWatermarkDetails("Draft", true)
 	if err != nil {
-		t.Fatalf("TestEncryption - change ownerPW %s: %v\n", outFile, err)
+		t.Fatalf("TestRevisions: %v\n", err)
 	}
 
-	// Decrypt using wrong pw
-	t.Log("\nDecrypt using wrong pw")
-	config = pdfcpu.NewDefaultConfiguration()
-	config.UserPW = "upwWrong"
-	config.OwnerPW = "opwWrong"
-	_, err = Process(DecryptCommand(outFile, outFile, config))
-	if err == nil {
-		t.Fatalf("TestEncryptDecrypt - decrypt using wrong pw %s\n", outFile)
+	inFile := filepath.Join(inDir, "5116.DCT_Filter.pdf")
+	signedFile := filepath.Join(outDir, "revSigned.pdf")
+	stampedFile := filepath.Join(outDir, "revSignedStamped.pdf")
+
+	config := pdfcpu.NewDefaultConfiguration()
+	signFile(inFile, signedFile, &pdfcpu.Signature{Key: key, Certs: trusted}, config, t)
+
+	config.WriteIncrement = true
+	_, err = Process(AddWatermarksCommand(signedFile, stampedFile, []string{"1-"}, wm, config))
+	if err != nil {
+		t.Fatalf("TestRevisions - stamp %s: %v\n", signedFile, err)
 	}
 
-	// Decrypt
-	t.Log("\nDecrypt")
 	config = pdfcpu.NewDefaultConfiguration()
-	config.UserPW = "upwNew"
-	config.OwnerPW = "opwNew"
-	_, err = Process(DecryptCommand(outFile, outFile, config))
+
+	list, err := Process(ListRevisionsCommand(stampedFile, config))
 	if err != nil {
-		t.Fatalf("TestEncryptDecrypt - decrypt %s: %v\n", outFile, err)
+		t.Fatalf("TestRevisions - list revisions of %s: %v\n", stampedFile, err)
+	}
+	if len(list) != 3 {
+		t.Fatalf("TestRevisions - %s: want 3 revisions, got %d\n", stampedFile, len(list))
+	}
+	for _, s := range list {
+		t.Log(s)
 	}
 
-}
+	// Extracting a revision restores the file as it existed back then.
+	for rev, fileName := range map[int]string{1: inFile, 2: signedFile, 3: stampedFile} {
 
-func TestEncryptDecrypt(t *testing.T) {
+		outFile := filepath.Join(outDir, fmt.Sprintf("rev%d.pdf", rev))
 
-	config := pdfcpu.NewDefaultConfiguration()
-	config.UserPW = "upw"
-	config.OwnerPW = "opw"
-	encryptDecrypt("5116.DCT_Filter.pdf", config, t)
+		_, err = Process(ExtractRevisionCommand(stampedFile, outFile, rev, config))
+		if err != nil {
+			t.Fatalf("TestRevisions - extract revision %d: %v\n", rev, err)
+		}
 
-	config = pdfcpu.NewDefaultConfiguration()
-	config.UserPW = "upw"
-	config.OwnerPW = "opw"
-	config.EncryptUsingAES = false
-	config.EncryptUsing128BitKey = false
-	encryptDecrypt("networkProgr.pdf", config, t)
+		b1, err := ioutil.ReadFile(fileName)
+		if err != nil {
+			t.Fatalf("TestRevisions: %v\n", err)
+		}
+
+		b2, err := ioutil.ReadFile(outFile)
+		if err != nil {
+			t.Fatalf("TestRevisions: %v\n", err)
+		}
+
+		if !bytes.Equal(b1, b2) {
+			t.Fatalf("TestRevisions - revision %d does not match %s\n", rev, fileName)
+		}
+	}
+
+	// The signature covers the whole document of the signed revision.
+	results, err := pdfcpu.VerifySignatures(readContextFromFile(filepath.Join(outDir, "rev2.pdf"), config, t), trusted)
+	if err != nil || len(results) != 1 {
+		t.Fatalf("TestRevisions - verify revision 2: %v\n", err)
+	}
+	if sr := results[0]; !sr.Verified() || !sr.WholeDocument {
+		t.Fatalf("TestRevisions - revision 2: %s\n", sr)
+	}
+
+	for _, rev := range []int{0, 4} {
+		_, err = Process(ExtractRevisionCommand(stampedFile, filepath.Join(outDir, "revX.pdf"), rev, config))
+		if err == nil {
+			t.Fatalf("TestRevisions - extract revision %d should fail\n", rev)
+		}
+	}
 }
 
 func copyFile(srcFileName, destFileName string) (err error) {
@@ -1144,6 +2868,142 @@ func TestListPermissionsCommand(t *testing.T) {
 
 }
 
+func readContextFromFile(fileName string, config *pdfcpu.Configuration, t *testing.T) *pdfcpu.PDFContext {
+
+	buf, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		t.Fatalf("readContextFromFile %s: %v\n", fileName, err)
+	}
+
+	ctx, err := ReadContext(bytes.NewReader(buf), int64(len(buf)), config)
+	if err != nil {
+		t.Fatalf("readContextFromFile %s: %v\n", fileName, err)
+	}
+
+	err = ValidateContext(ctx)
+	if err != nil {
+		t.Fatalf("readContextFromFile %s: %v\n", fileName, err)
+	}
+
+	return ctx
+}
+
+func writeAndReadBack(ctx *pdfcpu.PDFContext, config *pdfcpu.Configuration, t *testing.T) *pdfcpu.PDFContext {
+
+	var buf bytes.Buffer
+
+	err := WriteContext(ctx, &buf)
+	if err != nil {
+		t.Fatalf("writeAndReadBack: %v\n", err)
+	}
+
+	if ctx.Write.FileSize != int64(buf.Len()) {
+		t.Fatalf("writeAndReadBack: fileSize should be %d but is %d\n", buf.Len(), ctx.Write.FileSize)
+	}
+
+	ctx, err = ReadContext(bytes.NewReader(buf.Bytes()), int64(buf.Len()), config)
+	if err != nil {
+		t.Fatalf("writeAndReadBack: %v\n", err)
+	}
+
+	err = ValidateContext(ctx)
+	if err != nil {
+		t.Fatalf("writeAndReadBack: %v\n", err)
+	}
+
+	return ctx
+}
+
+// Process PDFs without touching the file system.
+func TestInMemoryProcessing(t *testing.T) {
+
+	config := pdfcpu.NewDefaultConfiguration()
+
+	// Optimize
+	ctx := readContextFromFile(filepath.Join(inDir, "CenterOfWhy.pdf"), config, t)
+	err := OptimizeContext(ctx)
+	if err != nil {
+		t.Fatalf("TestInMemoryProcessing optimize: %v\n", err)
+	}
+	ctx = writeAndReadBack(ctx, config, t)
+	if ctx.PageCount != 25 {
+		t.Fatalf("TestInMemoryProcessing optimize: pageCount should be %d but is %d\n", 25, ctx.PageCount)
+	}
+
+	// Trim
+	err = OptimizeContext(ctx)
+	if err != nil {
+		t.Fatalf("TestInMemoryProcessing trim: %v\n", err)
+	}
+	err = TrimContext(ctx, []string{"-2"})
+	if err != nil {
+		t.Fatalf("TestInMemoryProcessing trim: %v\n", err)
+	}
+	ctx = writeAndReadBack(ctx, config, t)
+	if ctx.PageCount != 2 {
+		t.Fatalf("TestInMemoryProcessing trim: pageCount should be %d but is %d\n", 2, ctx.PageCount)
+	}
+
+	// Merge
+	ctxSource := readContextFromFile(filepath.Join(inDir, "Acroforms2.pdf"), config, t)
+	pageCount := ctx.PageCount + ctxSource.PageCount
+	err = MergeContext(ctxSource, ctx)
+	if err != nil {
+		t.Fatalf("TestInMemoryProcessing merge: %v\n", err)
+	}
+	err = OptimizeContext(ctx)
+	if err != nil {
+		t.Fatalf("TestInMemoryProcessing merge: %v\n", err)
+	}
+	ctx = writeAndReadBack(ctx, config, t)
+	if ctx.PageCount != pageCount {
+		t.Fatalf("TestInMemoryProcessing merge: pageCount should be %d but is %d\n", pageCount, ctx.PageCount)
+	}
+
+	// Watermark
+	wm, err := pdfcpu.ParseWatermarkDetails("Draft, s:0.7, r:20", false)
+	if err != nil {
+		t.Fatalf("TestInMemoryProcessing watermark: %v\n", err)
+	}
+	err = OptimizeContext(ctx)
+	if err != nil {
+		t.Fatalf("TestInMemoryProcessing watermark: %v\n", err)
+	}
+	err = AddWatermarksContext(ctx, nil, wm)
+	if err != nil {
+		t.Fatalf("TestInMemoryProcessing watermark: %v\n", err)
+	}
+	var buf bytes.Buffer
+	err = WriteContext(ctx, &buf)
+	if err != nil {
+		t.Fatalf("TestInMemoryProcessing watermark: %v\n", err)
+	}
+
+	// Encrypt
+	config = pdfcpu.NewDefaultConfiguration()
+	config.UserPW = "upw"
+	config.OwnerPW = "opw"
+	config.Mode = pdfcpu.ENCRYPT
+	ctx, err = ReadContext(bytes.NewReader(buf.Bytes()), int64(buf.Len()), config)
+	if err != nil {
+		t.Fatalf("TestInMemoryProcessing encrypt: %v\n", err)
+	}
+	err = OptimizeContext(ctx)
+	if err != nil {
+		t.Fatalf("TestInMemoryProcessing encrypt: %v\n", err)
+	}
+
+	// Decrypt
+	config = pdfcpu.NewDefaultConfiguration()
+	config.UserPW = "upw"
+	config.OwnerPW = "opw"
+	config.Mode = pdfcpu.DECRYPT
+	ctx = writeAndReadBack(ctx, config, t)
+	if ctx.PageCount != pageCount {
+		t.Fatalf("TestInMemoryProcessing decrypt: pageCount should be %d but is %d\n", pageCount, ctx.PageCount)
+	}
+}
+
 func TestUnknownCommand(t *testing.T) {
 
 	config := pdfcpu.NewDefaultConfiguration()
@@ -1248,3 +3108,337 @@ func TestAcroformDemoPDF(t *testing.T) {
 	}
 
 }
+
+// acroFormDemo writes the AcroForm demo using baseFont for the form's default font Helv.
+func acroFormDemo(fileName, baseFont string, t *testing.T) {
+
+	xRefTable, err := pdfcpu.CreateAcroFormDemoXRef()
+	if err != nil {
+		t.Fatalf("acroFormDemo %v\n", err)
+	}
+
+	fontDict := pdfcpu.NewPDFDict()
+	fontDict.InsertName("Type", "Font")
+	fontDict.InsertName("Subtype", "Type1")
+	fontDict.InsertName("BaseFont", baseFont)
+
+	indRef, err := xRefTable.IndRefForNewObject(fontDict)
+	if err != nil {
+		t.Fatalf("acroFormDemo %v\n", err)
+	}
+
+	rootDict, _ := xRefTable.Catalog()
+	acroForm, _ := xRefTable.DereferenceDict(rootDict.Dict["AcroForm"])
+	acroForm.Insert("DA", pdfcpu.PDFStringLiteral("/Helv 0 Tf 0 g"))
+	acroForm.Insert("DR", pdfcpu.PDFDict{Dict: map[string]pdfcpu.PDFObject{
+		"Font": pdfcpu.PDFDict{Dict: map[string]pdfcpu.PDFObject{"Helv": *indRef}},
+	}})
+
+	err = pdfcpu.CreatePDF(xRefTable, outDir+"/", fileName)
+	if err != nil {
+		t.Fatalf("acroFormDemo %v\n", err)
+	}
+}
+
+// formFields returns the field dicts of the interactive form by fully qualified field name.
+func formFields(ctx *pdfcpu.PDFContext, t *testing.T) (*pdfcpu.PDFDict, map[string]*pdfcpu.PDFDict) {
+
+	rootDict, _ := ctx.Catalog()
+	acroForm, err := ctx.DereferenceDict(rootDict.Dict["AcroForm"])
+	if err != nil || acroForm == nil {
+		t.Fatalf("formFields: missing AcroForm %v\n", err)
+	}
+
+	m := map[string]*pdfcpu.PDFDict{}
+
+	var walk func(o pdfcpu.PDFObject, prefix string)
+
+	walk = func(o pdfcpu.PDFObject, prefix string) {
+		a, _ := ctx.DereferenceArray(o)
+		if a == nil {
+			return
+		}
+		for _, o := range *a {
+			d, _ := ctx.DereferenceDict(o)
+			name := prefix
+			if s, ok := d.Dict["T"].(pdfcpu.PDFStringLiteral); ok {
+				name += s.Value()
+				m[name] = d
+				name += "."
+			}
+			walk(d.Dict["Kids"], name)
+		}
+	}
+
+	walk(acroForm.Dict["Fields"], "")
+
+	return acroForm, m
+}
+
+func TestMergeAcroForms(t *testing.T) {
+
+	fileName1 := filepath.Join(outDir, "acroFormHelvetica.pdf")
+	fileName2 := filepath.Join(outDir, "acroFormTimes.pdf")
+	outFile := filepath.Join(outDir, "acroFormMerged.pdf")
+
+	acroFormDemo("acroFormHelvetica.pdf", "Helvetica", t)
+	acroFormDemo("acroFormTimes.pdf", "Times-Roman", t)
+
+	config := pdfcpu.NewDefaultConfiguration()
+
+	_, err := Process(MergeCommand([]string{fileName1, fileName2}, outFile, config))
+	if err != nil {
+		t.Fatalf("TestMergeAcroForms: %v\n", err)
+	}
+
+	ctx, err := Read(outFile, config)
+	if err != nil {
+		t.Fatalf("TestMergeAcroForms: %v\n", err)
+	}
+
+	if err = ValidateContext(ctx); err != nil {
+		t.Fatalf("TestMergeAcroForms: %v\n", err)
+	}
+
+	acroForm, fields := formFields(ctx, t)
+
+	// All top level field names of the second file clash.
+	for _, fn := range []string{"inputField", "CheckBox", "Credit card.Radio2", "acroFormTimes.inputField", "acroFormTimes.Credit card.Radio2"} {
+		if fields[fn] == nil {
+			t.Fatalf("TestMergeAcroForms: missing field %s in %v\n", fn, fields)
+		}
+	}
+
+	if len(fields) != 15 {
+		t.Fatalf("TestMergeAcroForms: %d fields, expected 15\n", len(fields))
+	}
+
+	// Fields of the second file keep using Times-Roman.
+	dr, _ := ctx.DereferenceDict(acroForm.Dict["DR"])
+	fonts, _ := ctx.DereferenceDict(dr.Dict["Font"])
+	if fonts.Dict["Helv"] == nil || fonts.Dict["Helv_1"] == nil {
+		t.Fatalf("TestMergeAcroForms: unexpected default resources %v\n", dr)
+	}
+
+	if da := fields["acroFormTimes.CheckBox"].Dict["DA"]; da != pdfcpu.PDFStringLiteral("/Helv_1 0 Tf 0 g") {
+		t.Fatalf("TestMergeAcroForms: unexpected DA %v\n", da)
+	}
+
+	if da := fields["CheckBox"].Dict["DA"]; da != nil {
+		t.Fatalf("TestMergeAcroForms: unexpected DA %v\n", da)
+	}
+}
+
+func TestFillForm(t *testing.T) {
+
+	fileName := filepath.Join(outDir, "acroFormFill.pdf")
+	fileJSON := filepath.Join(outDir, "acroFormFill.json")
+	outFile := filepath.Join(outDir, "acroFormFilled.pdf")
+
+	acroFormDemo("acroFormFill.pdf", "Helvetica", t)
+
+	config := pdfcpu.NewDefaultConfiguration()
+
+	list, err := Process(ListFormFieldsCommand(fileName, config))
+	if err != nil {
+		t.Fatalf("TestFillForm: %v\n", err)
+	}
+
+	if len(list) != 5 || !strings.HasPrefix(list[2], "Credit card (Btn)") {
+		t.Fatalf("TestFillForm: unexpected form fields %v\n", list)
+	}
+
+	values := `{"inputField": "Hello World", "CheckBox": false, "Credit card": "card2"}`
+
+	err = ioutil.WriteFile(fileJSON, []byte(values), os.ModePerm)
+	if err != nil {
+		t.Fatalf("TestFillForm: %v\n", err)
+	}
+
+	_, err = Process(FillFormCommand(fileName, fileJSON, outFile, config))
+	if err != nil {
+		t.Fatalf("TestFillForm: %v\n", err)
+	}
+
+	ctx, err := Read(outFile, config)
+	if err != nil {
+		t.Fatalf("TestFillForm: %v\n", err)
+	}
+
+	if err = ValidateContext(ctx); err != nil {
+		t.Fatalf("TestFillForm: %v\n", err)
+	}
+
+	ff, err := pdfcpu.FormFields(ctx)
+	if err != nil {
+		t.Fatalf("TestFillForm: %v\n", err)
+	}
+
+	for i, v := range []string{"Hello World", "Off", "card2"} {
+		if ff[i].Value != v {
+			t.Fatalf("TestFillForm: field %s has value %q, expected %q\n", ff[i].Name, ff[i].Value, v)
+		}
+	}
+
+	_, fields := formFields(ctx, t)
+
+	for fn, as := range map[string]string{"CheckBox": "Off", "Credit card.Radio1": "Off", "Credit card.Radio2": "card2"} {
+		if n := fields[fn].NameEntry("AS"); n == nil || *n != as {
+			t.Fatalf("TestFillForm: field %s: unexpected appearance state %v\n", fn, n)
+		}
+	}
+
+	// The text field got a new appearance stream showing the value.
+	ap, _ := ctx.DereferenceDict(fields["inputField"].Dict["AP"])
+	if ap == nil {
+		t.Fatalf("TestFillForm: missing appearance dict\n")
+	}
+
+	indRef := ap.IndirectRefEntry("N")
+	if indRef == nil {
+		t.Fatalf("TestFillForm: missing normal appearance\n")
+	}
+
+	b, err := pdfcpu.ExtractContentData(ctx, indRef.ObjectNumber.Value())
+	if err != nil {
+		t.Fatalf("TestFillForm: %v\n", err)
+	}
+
+	if !bytes.Contains(b, []byte("<48656C6C6F20576F726C64> Tj")) {
+		t.Fatalf("TestFillForm: unexpected appearance stream %s\n", b)
+	}
+
+	// Radio buttons need one of their options.
+	err = ioutil.WriteFile(fileJSON, []byte(`{"Credit card": "card3"}`), os.ModePerm)
+	if err != nil {
+		t.Fatalf("TestFillForm: %v\n", err)
+	}
+
+	if _, err = Process(FillFormCommand(fileName, fileJSON, outFile, config)); err == nil {
+		t.Fatalf("TestFillForm: expected error for unknown option\n")
+	}
+}
+
+func TestFillFormChoice(t *testing.T) {
+
+	fileName := filepath.Join(outDir, "acroFormChoice.pdf")
+	fileJSON := filepath.Join(outDir, "acroFormChoice.json")
+	outFile := filepath.Join(outDir, "acroFormChoiceFilled.pdf")
+
+	acroFormDemo("acroFormChoice.pdf", "Helvetica", t)
+
+	config := pdfcpu.NewDefaultConfiguration()
+
+	ctx := readContextFromFile(fileName, config, t)
+
+	pageDict, _, err := ctx.PageDict(1)
+	if err != nil {
+		t.Fatalf("TestFillFormChoice: %v\n", err)
+	}
+
+	pageIndRef, err := ctx.PageDictIndRef(1)
+	if err != nil {
+		t.Fatalf("TestFillFormChoice: %v\n", err)
+	}
+
+	// A combo box and a list box inheriting their field type from a common parent field.
+	parent := pdfcpu.PDFDict{Dict: map[string]pdfcpu.PDFObject{
+		"FT": pdfcpu.PDFName("Ch"),
+		"T":  pdfcpu.PDFStringLiteral("Choice"),
+	}}
+
+	parentIndRef, err := ctx.IndRefForNewObject(parent)
+	if err != nil {
+		t.Fatalf("TestFillFormChoice: %v\n", err)
+	}
+
+	kids := pdfcpu.PDFArray{}
+
+	for i, kid := range []struct {
+		name string
+		ff   int
+		opts pdfcpu.PDFArray
+	}{
+		{"Combo", 1 << 17, pdfcpu.PDFArray{pdfcpu.PDFStringLiteral("a"), pdfcpu.PDFStringLiteral("b"), pdfcpu.PDFStringLiteral("c")}},
+		{"List", 0, pdfcpu.PDFArray{
+			pdfcpu.PDFArray{pdfcpu.PDFStringLiteral("x"), pdfcpu.PDFStringLiteral("Option X")},
+			pdfcpu.PDFStringLiteral("y"),
+			pdfcpu.PDFStringLiteral("z"),
+		}},
+	} {
+		d := pdfcpu.PDFDict{Dict: map[string]pdfcpu.PDFObject{
+			"Type":    pdfcpu.PDFName("Annot"),
+			"Subtype": pdfcpu.PDFName("Widget"),
+			"Rect":    pdfcpu.NewRectangle(100, float64(500-100*i), 250, float64(560-100*i)),
+			"P":       *pageIndRef,
+			"Parent":  *parentIndRef,
+			"T":       pdfcpu.PDFStringLiteral(kid.name),
+			"Ff":      pdfcpu.PDFInteger(kid.ff),
+			"Opt":     kid.opts,
+		}}
+		indRef, err := ctx.IndRefForNewObject(d)
+		if err != nil {
+			t.Fatalf("TestFillFormChoice: %v\n", err)
+		}
+		kids = append(kids, *indRef)
+	}
+
+	d, _ := ctx.DereferenceDict(*parentIndRef)
+	d.Insert("Kids", kids)
+
+	annots, _ := ctx.DereferenceArray(pageDict.Dict["Annots"])
+	if annots == nil {
+		annots = &pdfcpu.PDFArray{}
+	}
+	pageDict.Update("Annots", append(*annots, kids...))
+
+	acroForm, _ := formFields(ctx, t)
+	fields, _ := ctx.DereferenceArray(acroForm.Dict["Fields"])
+	acroForm.Update("Fields", append(*fields, *parentIndRef))
+
+	ctx.Write.DirName = outDir + "/"
+	ctx.Write.FileName = filepath.Base(fileName)
+	if err = Write(ctx); err != nil {
+		t.Fatalf("TestFillFormChoice: %v\n", err)
+	}
+
+	values := `{"Choice.Combo": "b", "Choice.List": "x"}`
+
+	err = ioutil.WriteFile(fileJSON, []byte(values), os.ModePerm)
+	if err != nil {
+		t.Fatalf("TestFillFormChoice: %v\n", err)
+	}
+
+	_, err = Process(FillFormCommand(fileName, fileJSON, outFile, config))
+	if err != nil {
+		t.Fatalf("TestFillFormChoice: %v\n", err)
+	}
+
+	ctx = readContextFromFile(outFile, config, t)
+
+	_, m := formFields(ctx, t)
+
+	for fn, v := range map[string]string{"Choice.Combo": "b", "Choice.List": "x"} {
+		if s, ok := m[fn].Dict["V"].(pdfcpu.PDFStringLiteral); !ok || s.Value() != v {
+			t.Fatalf("TestFillFormChoice: field %s: unexpected value %v\n", fn, m[fn].Dict["V"])
+		}
+	}
+
+	// The list box also records the index of the selected option.
+	if i := m["Choice.List"].PDFArrayEntry("I"); i == nil || len(*i) != 1 || (*i)[0] != pdfcpu.PDFInteger(0) {
+		t.Fatalf("TestFillFormChoice: unexpected selection %v\n", i)
+	}
+
+	// Values need to be one of the options.
+	for _, values := range []string{`{"Choice.Combo": "d"}`, `{"Choice.List": "Option X"}`} {
+
+		err = ioutil.WriteFile(fileJSON, []byte(values), os.ModePerm)
+		if err != nil {
+			t.Fatalf("TestFillFormChoice: %v\n", err)
+		}
+
+		if _, err = Process(FillFormCommand(fileName, fileJSON, outFile, config)); err == nil {
+			t.Fatalf("TestFillFormChoice: expected error for %s\n", values)
+		}
+	}
+}
@@ -0,0 +1,462 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package filter
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+
+	"github.com/hhrutter/pdfcpu/pkg/log"
+	"github.com/pkg/errors"
+)
+
+// CCITTFaxDecode decodes image data encoded using Group 3 (ITU-T T.4) or Group 4 (ITU-T T.6) facsimile coding.
+// The result is a bilevel image with one bit per pixel where each row starts on a byte boundary.
+
+type ccittFaxDecode struct {
+	baseFilter
+}
+
+// The maximum number of pixels of a decoded image.
+const maxCCITTPixels = 1 << 28
+
+// Pixel colors of a row.
+const (
+	white = 0
+	black = 1
+)
+
+// Encode implements encoding for a CCITTFaxDecode filter.
+// Only Group 4 encoding (K < 0) is supported.
+func (f ccittFaxDecode) Encode(r io.Reader) (*bytes.Buffer, error) {
+
+	log.Debug.Println("EncodeCCITTFax begin")
+
+	if f.parm("K", 0) >= 0 {
+		return nil, errors.New("EncodeCCITTFax: only Group 4 encoding supported")
+	}
+
+	src, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	e := newCCITTEncoder(f.parms)
+
+	b := e.encode(src)
+
+	log.Debug.Printf("EncodeCCITTFax end: %d bytes written\n", b.Len())
+
+	return b, nil
+}
+
+// Decode implements decoding for a CCITTFaxDecode filter.
+func (f ccittFaxDecode) Decode(r io.Reader) (*bytes.Buffer, error) {
+
+	log.Debug.Println("DecodeCCITTFax begin")
+
+	src, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	d, err := newCCITTDecoder(src, f.parms)
+	if err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+
+	err = d.decode(&b)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Debug.Printf("DecodeCCITTFax: decoded %d rows.\n", d.rowCount)
+
+	return &b, nil
+}
+
+// ccittDecoder decodes one CCITTFaxDecode stream.
+type ccittDecoder struct {
+	src       []byte
+	pos       int  // the current bit position.
+	k         int  // < 0: Group 4, 0: Group 3 1D, > 0: Group 3 mixed 1D/2D.
+	columns   int  // the number of pixels per row.
+	rows      int  // the number of rows, 0 if unknown.
+	byteAlign bool // each encoded row begins on a byte boundary.
+	eob       bool // the data is terminated by RTC or EOFB.
+	blackIs1  bool // black pixels are represented by 1 bits.
+	ref, cur  []byte
+	rowCount  int
+}
+
+func newCCITTDecoder(src []byte, parms map[string]int) (*ccittDecoder, error) {
+
+	f := baseFilter{parms}
+
+	columns := f.parm("Columns", 1728)
+	if columns <= 0 {
+		columns = 1728
+	}
+
+	rows := f.parm("Rows", 0)
+
+	if columns > maxCCITTPixels || rows < 0 || rows > maxCCITTPixels/columns {
+		return nil, errors.Errorf("DecodeCCITTFax: invalid image size %dx%d", columns, rows)
+	}
+
+	return &ccittDecoder{
+		src:       src,
+		k:         f.parm("K", 0),
+		columns:   columns,
+		rows:      rows,
+		byteAlign: f.parm("EncodedByteAlign", 0) == 1,
+		eob:       f.parm("EndOfBlock", 1) == 1,
+		blackIs1:  f.parm("BlackIs1", 0) == 1,
+		ref:       make([]byte, columns),
+		cur:       make([]byte, columns),
+	}, nil
+}
+
+func (d *ccittDecoder) eod() bool {
+	return d.pos >= len(d.src)*8
+}
+
+// bit returns the next bit.
+func (d *ccittDecoder) bit() (uint32, error) {
+
+	if d.eod() {
+		return 0, errors.Errorf("DecodeCCITTFax: unexpected end of data in row %d", d.rowCount+1)
+	}
+
+	b := d.src[d.pos/8] >> uint(7-d.pos%8) & 1
+	d.pos++
+
+	return uint32(b), nil
+}
+
+// zeros returns true if the next n bits are 0 or if there are no more bits.
+func (d *ccittDecoder) zeros(n int) bool {
+
+	for i := d.pos; i < d.pos+n && i < len(d.src)*8; i++ {
+		if d.src[i/8]>>uint(7-i%8)&1 == 1 {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (d *ccittDecoder) align() {
+	d.pos = (d.pos + 7) / 8 * 8
+}
+
+// code reads the next code of table.
+func (d *ccittDecoder) code(table map[uint32]int) (int, error) {
+
+	var v uint32
+
+	for n := 1; n <= maxCodeLength; n++ {
+
+		b, err := d.bit()
+		if err != nil {
+			return 0, err
+		}
+
+		v = v<<1 | b
+
+		if c, ok := table[codeKey(n, v)]; ok {
+			return c, nil
+		}
+	}
+
+	return 0, errors.Errorf("DecodeCCITTFax: invalid code in row %d", d.rowCount+1)
+}
+
+// run reads the length of a run of color consisting of makeup codes followed by a terminating code.
+func (d *ccittDecoder) run(color byte) (int, error) {
+
+	table := whiteTable
+	if color == black {
+		table = blackTable
+	}
+
+	run := 0
+
+	for {
+		v, err := d.code(table)
+		if err != nil {
+			return 0, err
+		}
+		run += v
+		if v < 64 {
+			return run, nil
+		}
+	}
+}
+
+// eol consumes an optional EOL code including preceding fill bits.
+func (d *ccittDecoder) eol() (found bool) {
+
+	// No code other than EOL starts with 11 zero bits.
+	if !d.zeros(11) {
+		return false
+	}
+
+	for !d.eod() {
+		b, _ := d.bit()
+		if b == 1 {
+			return true
+		}
+	}
+
+	// Trailing fill bits.
+	return false
+}
+
+// startRow processes everything preceding the data of the next row.
+// It returns false at the end of the data.
+func (d *ccittDecoder) startRow() (twoD bool, more bool) {
+
+	if d.rows > 0 && d.rowCount == d.rows {
+		return false, false
+	}
+
+	// Group 4 rows begin on a byte boundary, for Group 3 this applies to EOLs if present.
+	if d.byteAlign && d.k < 0 {
+		d.align()
+	}
+
+	if d.eod() || d.zeros(len(d.src)*8-d.pos) {
+		return false, false
+	}
+
+	found := d.eol()
+	if !found && d.byteAlign && d.k >= 0 {
+		d.align()
+		found = d.eol()
+	}
+
+	if found {
+
+		// Group 4 data is terminated by EOFB, Group 3 data by RTC.
+		if d.k < 0 {
+			return false, false
+		}
+
+		if d.eob && d.k == 0 && d.eol() {
+			return false, false
+		}
+	}
+
+	twoD = d.k < 0
+
+	if d.k > 0 {
+
+		// A tag bit following the EOL determines the coding of the row.
+		b, err := d.bit()
+		if err != nil {
+			return false, false
+		}
+
+		twoD = b == 0
+
+		if d.eob && d.eol() {
+			return false, false
+		}
+	}
+
+	return twoD, !d.eod()
+}
+
+// fill sets the pixels of the current row from x0 up to x1 to color.
+func (d *ccittDecoder) fill(x0, x1 int, color byte) {
+
+	for x := x0; x < x1 && x < d.columns; x++ {
+		d.cur[x] = color
+	}
+}
+
+func (d *ccittDecoder) decodeRow1D() error {
+
+	color := byte(white)
+
+	for a0 := 0; a0 < d.columns; {
+
+		run, err := d.run(color)
+		if err != nil {
+			return err
+		}
+
+		d.fill(a0, a0+run, color)
+		a0 += run
+		color ^= 1
+	}
+
+	return nil
+}
+
+// changingElements returns b1, the first changing element on the reference row to the right of a0
+// and of the opposite color of a0, and b2, the next changing element to the right of b1.
+func changingElements(ref []byte, a0 int, color byte) (int, int) {
+
+	x := a0 + 1
+	if x < 0 {
+		x = 0
+	}
+
+	prev := byte(white)
+	if x > 0 {
+		prev = ref[x-1]
+	}
+
+	for ; x < len(ref); x++ {
+		c := ref[x]
+		if c != prev && c != color {
+			break
+		}
+		prev = c
+	}
+
+	b1 := x
+
+	if b1 == len(ref) {
+		return b1, b1
+	}
+
+	for x++; x < len(ref) && ref[x] == ref[b1]; x++ {
+	}
+
+	return b1, x
+}
+
+func (d *ccittDecoder) decodeRow2D() error {
+
+	color := byte(white)
+
+	// a0 is located on an imaginary white pixel before the first pixel of a row.
+	for a0 := -1; a0 < d.columns; {
+
+		mode, err := d.code(modeTable)
+		if err != nil {
+			return err
+		}
+
+		start := a0
+		if start < 0 {
+			start = 0
+		}
+
+		switch mode {
+
+		case modePass:
+			_, b2 := changingElements(d.ref, a0, color)
+			d.fill(start, b2, color)
+			a0 = b2
+
+		case modeHorizontal:
+			r1, err := d.run(color)
+			if err != nil {
+				return err
+			}
+			r2, err := d.run(color ^ 1)
+			if err != nil {
+				return err
+			}
+			d.fill(start, start+r1, color)
+			d.fill(start+r1, start+r1+r2, color^1)
+			a0 = start + r1 + r2
+
+		case modeExtension:
+			return errors.Errorf("DecodeCCITTFax: unsupported uncompressed mode in row %d", d.rowCount+1)
+
+		default:
+			b1, _ := changingElements(d.ref, a0, color)
+			a1 := b1 + verticalDelta[mode]
+			if a1 < start {
+				a1 = start
+			}
+			if a1 > d.columns {
+				a1 = d.columns
+			}
+			d.fill(start, a1, color)
+			a0 = a1
+			color ^= 1
+		}
+	}
+
+	return nil
+}
+
+// writeRow packs the pixels of the current row into w.
+func (d *ccittDecoder) writeRow(w io.ByteWriter) {
+
+	var c byte
+
+	for x, p := range d.cur {
+
+		// Unless BlackIs1, 0 bits represent black pixels.
+		if (p == black) == d.blackIs1 {
+			c |= 0x80 >> uint(x%8)
+		}
+
+		if x%8 == 7 || x == d.columns-1 {
+			w.WriteByte(c)
+			c = 0
+		}
+	}
+}
+
+func (d *ccittDecoder) decode(w io.ByteWriter) error {
+
+	for {
+
+		twoD, more := d.startRow()
+		if !more {
+			break
+		}
+
+		// The number of rows may be unknown.
+		if d.rowCount+1 > maxCCITTPixels/d.columns {
+			return errors.Errorf("DecodeCCITTFax: image size exceeds %d pixels", maxCCITTPixels)
+		}
+
+		var err error
+		if twoD {
+			err = d.decodeRow2D()
+		} else {
+			err = d.decodeRow1D()
+		}
+
+		if err != nil {
+			if d.rows == 0 && d.rowCount > 0 && d.eod() {
+				// Truncated data with an unknown number of rows.
+				break
+			}
+			return err
+		}
+
+		d.writeRow(w)
+		d.rowCount++
+
+		d.ref, d.cur = d.cur, d.ref
+	}
+
+	return nil
+}
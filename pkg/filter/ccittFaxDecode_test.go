@@ -0,0 +1,226 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package filter
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// bits packs a string of '0' and '1' into bytes padded with 0 bits, blanks are ignored.
+func bits(s string) []byte {
+
+	s = strings.Replace(s, " ", "", -1)
+
+	b := make([]byte, (len(s)+7)/8)
+	for i, c := range s {
+		if c == '1' {
+			b[i/8] |= 0x80 >> uint(i%8)
+		}
+	}
+
+	return b
+}
+
+func TestCCITTCodeTables(t *testing.T) {
+
+	// Each code table has to be prefix free.
+	for _, codes := range [][][]ccittCode{
+		{whiteTermCodes, whiteMakeupCodes, extMakeupCodes},
+		{blackTermCodes, blackMakeupCodes, extMakeupCodes},
+		{modeCodes},
+	} {
+		var all []string
+		for _, cc := range codes {
+			for _, c := range cc {
+				all = append(all, c.code)
+			}
+		}
+		for i, c1 := range all {
+			for j, c2 := range all {
+				if i != j && strings.HasPrefix(c2, c1) {
+					t.Fatalf("code %s is a prefix of %s\n", c1, c2)
+				}
+			}
+		}
+	}
+
+}
+
+func TestCCITTFaxDecode(t *testing.T) {
+
+	// Two rows of 8 pixels: 2 white, 4 black, 2 white.
+	// Row 1: horizontal mode (white 2, black 4) followed by V0.
+	// Row 2: V0, V0, V0.
+	const (
+		eol  = "000000000001"
+		row1 = "001 0111 011 1"
+		row2 = "111"
+		mh   = "0111 011 0111" // 1D coding of a row.
+	)
+
+	for _, tt := range []struct {
+		msg   string
+		src   string
+		parms map[string]int
+		want  []byte
+	}{
+		{"G4",
+			row1 + row2 + eol + eol,
+			map[string]int{"K": -1, "Columns": 8},
+			[]byte{0xC3, 0xC3}},
+		{"G4 without EOFB",
+			row1 + row2,
+			map[string]int{"K": -1, "Columns": 8, "Rows": 2, "EndOfBlock": 0},
+			[]byte{0xC3, 0xC3}},
+		{"G4 BlackIs1",
+			row1 + row2 + eol + eol,
+			map[string]int{"K": -1, "Columns": 8, "BlackIs1": 1},
+			[]byte{0x3C, 0x3C}},
+		{"G4 EncodedByteAlign",
+			row1 + "00000" + row2 + "00000" + eol + eol,
+			map[string]int{"K": -1, "Columns": 8, "EncodedByteAlign": 1},
+			[]byte{0xC3, 0xC3}},
+		{"G3 1D",
+			eol + mh + eol + mh + strings.Repeat(eol, 6),
+			map[string]int{"Columns": 8},
+			[]byte{0xC3, 0xC3}},
+		{"G3 1D without EOL",
+			mh + mh,
+			map[string]int{"Columns": 8, "Rows": 2},
+			[]byte{0xC3, 0xC3}},
+		{"G3 1D EncodedByteAlign",
+			// Fill bits so that each EOL ends on a byte boundary.
+			"0000" + eol + mh + "0" + eol + mh,
+			map[string]int{"Columns": 8, "EncodedByteAlign": 1},
+			[]byte{0xC3, 0xC3}},
+		{"G3 2D",
+			eol + "1" + mh + eol + "0" + row2 + strings.Repeat(eol+"1", 6),
+			map[string]int{"K": 2, "Columns": 8},
+			[]byte{0xC3, 0xC3}},
+		{"G4 pass mode",
+			// Row 2: P passes the black run of row 1, H(white 0, black 2) paints the last 2 pixels.
+			row1 + "0001 001 00110101 11" + eol + eol,
+			map[string]int{"K": -1, "Columns": 8},
+			[]byte{0xC3, 0xFC}},
+	} {
+		f := ccittFaxDecode{baseFilter{tt.parms}}
+
+		b, err := f.Decode(bytes.NewReader(bits(tt.src)))
+		if err != nil {
+			t.Fatalf("%s: %v\n", tt.msg, err)
+		}
+
+		compare(t, b.Bytes(), tt.want)
+	}
+
+}
+
+func TestCCITTFaxEncode(t *testing.T) {
+
+	// See TestCCITTFaxDecode.
+	parms := map[string]int{"K": -1, "Columns": 8}
+	want := bits("001 0111 011 1" + "111" + "000000000001000000000001")
+
+	f := ccittFaxDecode{baseFilter{parms}}
+
+	b, err := f.Encode(bytes.NewReader([]byte{0xC3, 0xC3}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	compare(t, b.Bytes(), want)
+
+	_, err = ccittFaxDecode{baseFilter{map[string]int{"K": 0}}}.Encode(bytes.NewReader([]byte{0}))
+	if err == nil {
+		t.Fatal("Group 3 encoding should fail")
+	}
+
+}
+
+func TestCCITTFaxRoundtrip(t *testing.T) {
+
+	// A pattern of runs of various lengths including runs longer than 2560 pixels.
+	const w, h = 3000, 40
+
+	rowLen := (w + 7) / 8
+	raw := make([]byte, rowLen*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if (x*x/(y+1)+y*7)%13 < 6 || x > 2900 || (y%10 == 0 && x < 2700) {
+				raw[y*rowLen+x/8] |= 0x80 >> uint(x%8)
+			}
+		}
+	}
+
+	for _, parms := range []map[string]int{
+		{"K": -1, "Columns": w},
+		{"K": -1, "Columns": w, "Rows": h, "BlackIs1": 1},
+		{"K": -1, "Columns": w, "EncodedByteAlign": 1},
+		{"K": -1, "Columns": w, "Rows": h, "EndOfBlock": 0},
+	} {
+		f := ccittFaxDecode{baseFilter{parms}}
+
+		enc, err := f.Encode(bytes.NewReader(raw))
+		if err != nil {
+			t.Fatalf("%v: %v\n", parms, err)
+		}
+
+		dec, err := f.Decode(enc)
+		if err != nil {
+			t.Fatalf("%v: %v\n", parms, err)
+		}
+
+		if !bytes.Equal(dec.Bytes(), raw) {
+			t.Fatalf("%v: roundtrip mismatch\n", parms)
+		}
+	}
+
+}
+
+func TestCCITTFaxDecodeImageSize(t *testing.T) {
+
+	// Each 1 bit is a vertical mode code completing a white row.
+	src := bytes.Repeat([]byte{0xFF}, 64)
+
+	for _, parms := range []map[string]int{
+		{"K": -1, "Columns": 1 << 30, "Rows": 1 << 30},
+		{"K": -1, "Columns": 1 << 29},
+		{"K": -1, "Columns": 1 << 20, "Rows": 1 << 9},
+		{"K": -1, "Columns": 1 << 20, "Rows": -1},
+		{"K": -1, "Columns": 1 << 20}, // unknown number of rows.
+	} {
+		f := ccittFaxDecode{baseFilter{parms}}
+
+		if _, err := f.Decode(bytes.NewReader(src)); err == nil {
+			t.Fatalf("%v: oversized image should fail\n", parms)
+		}
+	}
+
+	f := ccittFaxDecode{baseFilter{map[string]int{"K": -1, "Columns": 1 << 20}}}
+
+	b, err := f.Decode(bytes.NewReader(src[:16]))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if b.Len() != 128*(1<<17) {
+		t.Fatalf("unexpected image size %d\n", b.Len())
+	}
+
+}
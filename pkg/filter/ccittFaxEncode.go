@@ -0,0 +1,230 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package filter
+
+import (
+	"bytes"
+)
+
+// The code tables by value.
+var (
+	whiteTermEnc   = codeMap(whiteTermCodes)
+	whiteMakeupEnc = codeMap(whiteMakeupCodes, extMakeupCodes)
+	blackTermEnc   = codeMap(blackTermCodes)
+	blackMakeupEnc = codeMap(blackMakeupCodes, extMakeupCodes)
+	modeEnc        = codeMap(modeCodes)
+)
+
+// The vertical modes by offset of a1 relative to b1.
+var verticalModes = func() map[int]int {
+
+	m := map[int]int{}
+
+	for mode, delta := range verticalDelta {
+		m[delta] = mode
+	}
+
+	return m
+}()
+
+func codeMap(codes ...[]ccittCode) map[int]string {
+
+	m := map[int]string{}
+
+	for _, cc := range codes {
+		for _, c := range cc {
+			m[c.v] = c.code
+		}
+	}
+
+	return m
+}
+
+// The largest run length covered by a single makeup code.
+const maxMakeupRun = 2560
+
+// ccittEncoder encodes a bilevel image using Group 4 (ITU-T T.6) facsimile coding.
+type ccittEncoder struct {
+	buf       bytes.Buffer
+	c         byte // the pending bits.
+	n         uint // the number of pending bits.
+	columns   int
+	rows      int
+	byteAlign bool
+	eob       bool
+	blackIs1  bool
+}
+
+func newCCITTEncoder(parms map[string]int) *ccittEncoder {
+
+	f := baseFilter{parms}
+
+	columns := f.parm("Columns", 1728)
+	if columns <= 0 {
+		columns = 1728
+	}
+
+	return &ccittEncoder{
+		columns:   columns,
+		rows:      f.parm("Rows", 0),
+		byteAlign: f.parm("EncodedByteAlign", 0) == 1,
+		eob:       f.parm("EndOfBlock", 1) == 1,
+		blackIs1:  f.parm("BlackIs1", 0) == 1,
+	}
+}
+
+// write appends the bits of code.
+func (e *ccittEncoder) write(code string) {
+
+	for _, b := range code {
+		e.c = e.c<<1 | byte(b-'0')
+		e.n++
+		if e.n == 8 {
+			e.buf.WriteByte(e.c)
+			e.c, e.n = 0, 0
+		}
+	}
+}
+
+// align pads the pending bits with 0 bits up to the next byte boundary.
+func (e *ccittEncoder) align() {
+
+	if e.n > 0 {
+		e.buf.WriteByte(e.c << (8 - e.n))
+		e.c, e.n = 0, 0
+	}
+}
+
+// writeRun appends the makeup codes and the terminating code for a run of color.
+func (e *ccittEncoder) writeRun(run int, color byte) {
+
+	term, makeup := whiteTermEnc, whiteMakeupEnc
+	if color == black {
+		term, makeup = blackTermEnc, blackMakeupEnc
+	}
+
+	for run > maxMakeupRun {
+		e.write(makeup[maxMakeupRun])
+		run -= maxMakeupRun
+	}
+
+	if run >= 64 {
+		e.write(makeup[run/64*64])
+		run %= 64
+	}
+
+	e.write(term[run])
+}
+
+// nextChange returns the position of the first pixel to the right of x whose color is not color.
+func nextChange(row []byte, x int, color byte) int {
+
+	for x++; x < len(row); x++ {
+		if row[x] != color {
+			break
+		}
+	}
+
+	return x
+}
+
+func (e *ccittEncoder) encodeRow(cur, ref []byte) {
+
+	color := byte(white)
+
+	for a0 := -1; a0 < e.columns; {
+
+		a1 := nextChange(cur, a0, color)
+		b1, b2 := changingElements(ref, a0, color)
+
+		if b2 < a1 {
+			e.write(modeEnc[modePass])
+			a0 = b2
+			continue
+		}
+
+		if mode, ok := verticalModes[a1-b1]; ok {
+			e.write(modeEnc[mode])
+			a0 = a1
+			color ^= 1
+			continue
+		}
+
+		start := a0
+		if start < 0 {
+			start = 0
+		}
+
+		a2 := e.columns
+		if a1 < e.columns {
+			a2 = nextChange(cur, a1, color^1)
+		}
+
+		e.write(modeEnc[modeHorizontal])
+		e.writeRun(a1-start, color)
+		e.writeRun(a2-a1, color^1)
+		a0 = a2
+	}
+}
+
+// encode encodes rows of packed pixels where each row starts on a byte boundary.
+func (e *ccittEncoder) encode(src []byte) *bytes.Buffer {
+
+	rowLen := (e.columns + 7) / 8
+
+	rows := len(src) / rowLen
+	if e.rows > 0 && e.rows < rows {
+		rows = e.rows
+	}
+
+	ref := make([]byte, e.columns)
+	cur := make([]byte, e.columns)
+
+	for y := 0; y < rows; y++ {
+
+		row := src[y*rowLen:]
+
+		for x := range cur {
+			// Unless BlackIs1, 0 bits represent black pixels.
+			bit := row[x/8]>>uint(7-x%8)&1 == 1
+			cur[x] = white
+			if bit == e.blackIs1 {
+				cur[x] = black
+			}
+		}
+
+		if e.byteAlign {
+			e.align()
+		}
+
+		e.encodeRow(cur, ref)
+
+		ref, cur = cur, ref
+	}
+
+	if e.eob {
+		if e.byteAlign {
+			e.align()
+		}
+		// EOFB
+		e.write("000000000001000000000001")
+	}
+
+	e.align()
+
+	return &e.buf
+}
@@ -0,0 +1,250 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package filter
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io"
+	"io/ioutil"
+
+	"github.com/hhrutter/pdfcpu/pkg/log"
+	"github.com/pkg/errors"
+)
+
+// DCTDecode decodes JPEG baseline or progressive data into 8 bit samples
+// with 1 (gray), 3 (RGB) or 4 (CMYK) interleaved components per pixel.
+//
+// The decode parameter ColorTransform (default 1 for 3 components, 0 for 4 components)
+// controls the YCbCr (YCCK) color transform unless the data contains an Adobe APP14 marker.
+//
+// CMYK samples are returned as stored, Adobe CMYK data is inverted
+// and relies on the image's Decode array for correct interpretation.
+//
+// Encoding uses the parameters Columns, Rows, Colors (1 or 3) and Quality (1..100, default 75).
+// 3 color components are always encoded using the YCbCr color transform.
+
+// DefaultJPEGQuality is the quality used for DCT encoding unless specified.
+const DefaultJPEGQuality = 75
+
+type dctDecode struct {
+	baseFilter
+}
+
+// Encode implements encoding for a DCTDecode filter.
+func (f dctDecode) Encode(r io.Reader) (*bytes.Buffer, error) {
+
+	log.Debug.Println("EncodeDCT begin")
+
+	src, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	w, h := f.parm("Columns", 0), f.parm("Rows", 0)
+	colors := f.parm("Colors", 1)
+
+	if w <= 0 || h <= 0 || len(src) < w*h*colors {
+		return nil, errors.Errorf("EncodeDCT: invalid image size %dx%d for %d bytes", w, h, len(src))
+	}
+
+	var img image.Image
+
+	switch colors {
+
+	case 1:
+		img = &image.Gray{Pix: src, Stride: w, Rect: image.Rect(0, 0, w, h)}
+
+	case 3:
+		rgba := image.NewRGBA(image.Rect(0, 0, w, h))
+		for i, j := 0, 0; i < w*h*3; i, j = i+3, j+4 {
+			copy(rgba.Pix[j:j+3], src[i:i+3])
+			rgba.Pix[j+3] = 0xFF
+		}
+		img = rgba
+
+	default:
+		return nil, errors.Errorf("EncodeDCT: %d color components not supported", colors)
+	}
+
+	quality := f.parm("Quality", DefaultJPEGQuality)
+	if quality < 1 || quality > 100 {
+		return nil, errors.Errorf("EncodeDCT: invalid quality %d", quality)
+	}
+
+	var b bytes.Buffer
+
+	err = jpeg.Encode(&b, img, &jpeg.Options{Quality: quality})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Debug.Printf("EncodeDCT end: %d bytes written\n", b.Len())
+
+	return &b, nil
+}
+
+// Decode implements decoding for a DCTDecode filter.
+func (f dctDecode) Decode(r io.Reader) (*bytes.Buffer, error) {
+
+	log.Debug.Println("DecodeDCT begin")
+
+	src, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Skip any garbage preceding the SOI marker.
+	if i := bytes.Index(src, []byte{0xFF, 0xD8, 0xFF}); i > 0 {
+		src = src[i:]
+	}
+
+	comps, adobe := jpegInfo(src)
+
+	if comps == 4 && !adobe {
+		// image/jpeg relies on the Adobe marker for 4 components.
+		transform := byte(0)
+		if f.parm("ColorTransform", 0) == 1 {
+			transform = 2
+		}
+		src = insertAdobeMarker(src, transform)
+	}
+
+	img, err := jpeg.Decode(bytes.NewReader(src))
+	if err != nil {
+		return nil, errors.Errorf("DecodeDCT: %v", err)
+	}
+
+	var b []byte
+
+	switch img := img.(type) {
+
+	case *image.Gray:
+		b = samples(img.Rect, 1, func(x, y int, s []byte) {
+			s[0] = img.GrayAt(x, y).Y
+		})
+
+	case *image.YCbCr:
+		// Without an Adobe marker the ColorTransform parameter decides whether these are RGB values.
+		transform := adobe || f.parm("ColorTransform", 1) == 1
+		b = samples(img.Rect, 3, func(x, y int, s []byte) {
+			c := img.YCbCrAt(x, y)
+			if transform {
+				s[0], s[1], s[2] = color.YCbCrToRGB(c.Y, c.Cb, c.Cr)
+				return
+			}
+			s[0], s[1], s[2] = c.Y, c.Cb, c.Cr
+		})
+
+	case *image.RGBA:
+		b = samples(img.Rect, 3, func(x, y int, s []byte) {
+			copy(s, img.Pix[img.PixOffset(x, y):])
+		})
+
+	case *image.CMYK:
+		// image/jpeg reverts the inversion of Adobe CMYK, undo this to return the stored values.
+		b = samples(img.Rect, 4, func(x, y int, s []byte) {
+			for i, v := range img.Pix[img.PixOffset(x, y) : img.PixOffset(x, y)+4] {
+				s[i] = 255 - v
+			}
+		})
+
+	default:
+		return nil, errors.Errorf("DecodeDCT: unsupported color model %T", img)
+	}
+
+	log.Debug.Printf("DecodeDCT end: %d bytes\n", len(b))
+
+	return bytes.NewBuffer(b), nil
+}
+
+// samples returns the interleaved samples of the pixels of r using f to set the n samples of a pixel.
+func samples(r image.Rectangle, n int, f func(x, y int, s []byte)) []byte {
+
+	b := make([]byte, r.Dx()*r.Dy()*n)
+
+	i := 0
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			f(x, y, b[i:i+n])
+			i += n
+		}
+	}
+
+	return b
+}
+
+// jpegInfo returns the number of color components of JPEG data and whether it contains an Adobe APP14 marker.
+func jpegInfo(data []byte) (comps int, adobe bool) {
+
+	for i := 2; i+4 <= len(data); {
+
+		if data[i] != 0xFF {
+			return comps, adobe
+		}
+
+		m := data[i+1]
+
+		switch {
+
+		case m == 0xFF:
+			// Fill byte
+			i++
+			continue
+
+		case m == 0x01 || m >= 0xD0 && m <= 0xD7:
+			// Markers without length.
+			i += 2
+			continue
+
+		case m == 0xDA:
+			// Start of scan
+			return comps, adobe
+		}
+
+		l := int(binary.BigEndian.Uint16(data[i+2:]))
+
+		switch {
+
+		case m == 0xEE && i+9 <= len(data) && string(data[i+4:i+9]) == "Adobe":
+			adobe = true
+
+		case m >= 0xC0 && m <= 0xCF && m != 0xC4 && m != 0xC8 && m != 0xCC && i+9 < len(data):
+			// Start of frame
+			comps = int(data[i+9])
+		}
+
+		i += 2 + l
+	}
+
+	return comps, adobe
+}
+
+// insertAdobeMarker returns a copy of the JPEG data with an Adobe APP14 marker using transform following the SOI marker.
+func insertAdobeMarker(data []byte, transform byte) []byte {
+
+	marker := []byte{0xFF, 0xEE, 0x00, 0x0E, 'A', 'd', 'o', 'b', 'e', 0x00, 0x64, 0x00, 0x00, 0x00, 0x00, transform}
+
+	b := make([]byte, 0, len(data)+len(marker))
+	b = append(b, data[:2]...)
+	b = append(b, marker...)
+
+	return append(b, data[2:]...)
+}
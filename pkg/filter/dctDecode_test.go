@@ -0,0 +1,154 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package filter
+
+import (
+	"bytes"
+	"image/color"
+	"testing"
+)
+
+// gradient returns w x h pixels of n smoothly varying samples.
+func gradient(w, h, n int) []byte {
+
+	b := make([]byte, 0, w*h*n)
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			for i := 0; i < n; i++ {
+				b = append(b, byte((x*(i+1)+y*(n-i))*255/((w+h)*n)))
+			}
+		}
+	}
+
+	return b
+}
+
+func dctEncode(t *testing.T, src []byte, parms map[string]int) []byte {
+
+	t.Helper()
+
+	f, err := NewFilter(DCT, parms)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := f.Encode(bytes.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return b.Bytes()
+}
+
+func dctDecodeBytes(t *testing.T, src []byte, parms map[string]int) []byte {
+
+	t.Helper()
+
+	f, err := NewFilter(DCT, parms)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := f.Decode(bytes.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return b.Bytes()
+}
+
+// similar checks that all samples differ by at most tolerance.
+func similar(t *testing.T, got, want []byte, tolerance int) {
+
+	t.Helper()
+
+	if len(got) != len(want) {
+		t.Fatalf("length mismatch: got %d want %d\n", len(got), len(want))
+	}
+
+	for i := range got {
+		if d := int(got[i]) - int(want[i]); d > tolerance || d < -tolerance {
+			t.Fatalf("sample %d: got %d want %d\n", i, got[i], want[i])
+		}
+	}
+}
+
+func TestDCTRoundtrip(t *testing.T) {
+
+	w, h := 40, 24
+
+	for _, n := range []int{1, 3} {
+
+		src := gradient(w, h, n)
+
+		enc := dctEncode(t, src, map[string]int{"Columns": w, "Rows": h, "Colors": n, "Quality": 95})
+
+		if comps, _ := jpegInfo(enc); comps != n {
+			t.Fatalf("%d colors: got %d components\n", n, comps)
+		}
+
+		similar(t, dctDecodeBytes(t, enc, nil), src, 8)
+	}
+}
+
+func TestDCTQuality(t *testing.T) {
+
+	w, h := 64, 64
+	src := gradient(w, h, 3)
+
+	lo := dctEncode(t, src, map[string]int{"Columns": w, "Rows": h, "Colors": 3, "Quality": 10})
+	hi := dctEncode(t, src, map[string]int{"Columns": w, "Rows": h, "Colors": 3, "Quality": 100})
+
+	if len(lo) >= len(hi) {
+		t.Fatalf("quality 10: %d bytes, quality 100: %d bytes\n", len(lo), len(hi))
+	}
+
+	f, _ := NewFilter(DCT, map[string]int{"Columns": w, "Rows": h, "Colors": 4})
+	if _, err := f.Encode(bytes.NewReader(make([]byte, w*h*4))); err == nil {
+		t.Fatal("expected error for 4 color components")
+	}
+}
+
+func TestDCTColorTransform(t *testing.T) {
+
+	w, h := 16, 16
+	src := gradient(w, h, 3)
+
+	enc := dctEncode(t, src, map[string]int{"Columns": w, "Rows": h, "Colors": 3, "Quality": 100})
+
+	// Without color transform the YCbCr samples are returned.
+	raw := dctDecodeBytes(t, enc, map[string]int{"ColorTransform": 0})
+
+	want := make([]byte, len(src))
+	for i := 0; i < len(src); i += 3 {
+		want[i], want[i+1], want[i+2] = color.RGBToYCbCr(src[i], src[i+1], src[i+2])
+	}
+
+	similar(t, raw, want, 8)
+
+	// An Adobe marker takes precedence over ColorTransform.
+	adobe := insertAdobeMarker(enc, 0)
+
+	if _, ok := jpegInfo(adobe); !ok {
+		t.Fatal("missing Adobe marker")
+	}
+
+	if got := dctDecodeBytes(t, adobe, map[string]int{"ColorTransform": 1}); !bytes.Equal(got, raw) {
+		t.Fatal("Adobe marker ignored")
+	}
+}
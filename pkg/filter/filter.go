@@ -72,9 +72,15 @@ func NewFilter(filterName string, parms map[string]int) (filter Filter, err erro
 	case Flate:
 		filter = flate{baseFilter{parms}}
 
-	// CCITTFax
-	// JBIG2
-	// DCT
+	case CCITTFax:
+		filter = ccittFaxDecode{baseFilter{parms}}
+
+	case JBIG2:
+		filter = jbig2Decode{baseFilter{parms}}
+
+	case DCT:
+		filter = dctDecode{baseFilter{parms}}
+
 	// JPX
 
 	default:
@@ -93,3 +99,13 @@ func List() []string {
 type baseFilter struct {
 	parms map[string]int
 }
+
+// parm returns the value of a decode parameter or its default value.
+func (f baseFilter) parm(name string, def int) int {
+
+	if v, ok := f.parms[name]; ok {
+		return v
+	}
+
+	return def
+}
@@ -0,0 +1,257 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package filter
+
+// The MQ arithmetic decoder of ITU-T T.88 Annex E and the arithmetic integer decoding procedures of Annex A.
+
+// qeEntry is a row of the probability estimation table.
+type qeEntry struct {
+	qe         uint32
+	nmps, nlps byte
+	switchMPS  bool
+}
+
+var qeTable = [47]qeEntry{
+	{0x5601, 1, 1, true},
+	{0x3401, 2, 6, false},
+	{0x1801, 3, 9, false},
+	{0x0AC1, 4, 12, false},
+	{0x0521, 5, 29, false},
+	{0x0221, 38, 33, false},
+	{0x5601, 7, 6, true},
+	{0x5401, 8, 14, false},
+	{0x4801, 9, 14, false},
+	{0x3801, 10, 14, false},
+	{0x3001, 11, 17, false},
+	{0x2401, 12, 18, false},
+	{0x1C01, 13, 20, false},
+	{0x1601, 29, 21, false},
+	{0x5601, 15, 14, true},
+	{0x5401, 16, 14, false},
+	{0x5101, 17, 15, false},
+	{0x4801, 18, 16, false},
+	{0x3801, 19, 17, false},
+	{0x3401, 20, 18, false},
+	{0x3001, 21, 19, false},
+	{0x2801, 22, 19, false},
+	{0x2401, 23, 20, false},
+	{0x2201, 24, 21, false},
+	{0x1C01, 25, 22, false},
+	{0x1801, 26, 23, false},
+	{0x1601, 27, 24, false},
+	{0x1401, 28, 25, false},
+	{0x1201, 29, 26, false},
+	{0x1101, 30, 27, false},
+	{0x0AC1, 31, 28, false},
+	{0x09C1, 32, 29, false},
+	{0x08A1, 33, 30, false},
+	{0x0521, 34, 31, false},
+	{0x0441, 35, 32, false},
+	{0x02A1, 36, 33, false},
+	{0x0221, 37, 34, false},
+	{0x0141, 38, 35, false},
+	{0x0111, 39, 36, false},
+	{0x0085, 40, 37, false},
+	{0x0049, 41, 38, false},
+	{0x0025, 42, 39, false},
+	{0x0015, 43, 40, false},
+	{0x0009, 44, 41, false},
+	{0x0005, 45, 42, false},
+	{0x0001, 45, 43, false},
+	{0x5601, 46, 46, false},
+}
+
+// mqDecoder decodes binary decisions.
+// A context is a byte holding the index into qeTable shifted left by 1 and the MPS value in bit 0.
+type mqDecoder struct {
+	data        []byte
+	bp          int
+	chigh, clow uint32
+	ct          int
+	a           uint32
+}
+
+func newMQDecoder(data []byte) *mqDecoder {
+
+	d := &mqDecoder{data: data}
+
+	d.chigh = d.byteAt(0)
+	d.byteIn()
+	d.chigh = d.chigh<<7&0xFFFF | d.clow>>9&0x7F
+	d.clow = d.clow << 7 & 0xFFFF
+	d.ct -= 7
+	d.a = 0x8000
+
+	return d
+}
+
+// byteAt returns the byte at position i, beyond the end of the data 0xFF is assumed.
+func (d *mqDecoder) byteAt(i int) uint32 {
+
+	if i < len(d.data) {
+		return uint32(d.data[i])
+	}
+
+	return 0xFF
+}
+
+func (d *mqDecoder) byteIn() {
+
+	if d.byteAt(d.bp) == 0xFF {
+		if d.byteAt(d.bp+1) > 0x8F {
+			// A marker code, feed 1 bits.
+			d.clow += 0xFF00
+			d.ct = 8
+		} else {
+			d.bp++
+			d.clow += d.byteAt(d.bp) << 9
+			d.ct = 7
+		}
+	} else {
+		d.bp++
+		d.clow += d.byteAt(d.bp) << 8
+		d.ct = 8
+	}
+
+	if d.clow > 0xFFFF {
+		d.chigh += d.clow >> 16
+		d.clow &= 0xFFFF
+	}
+}
+
+// decode returns the next decision using context cx[i].
+func (d *mqDecoder) decode(cx []byte, i int) int {
+
+	index, mps := cx[i]>>1, int(cx[i]&1)
+	e := &qeTable[index]
+
+	var bit int
+	a := d.a - e.qe
+
+	if d.chigh < e.qe {
+		// LPS sub interval with conditional exchange.
+		if a < e.qe {
+			bit = mps
+			index = e.nmps
+		} else {
+			bit = 1 ^ mps
+			if e.switchMPS {
+				mps = bit
+			}
+			index = e.nlps
+		}
+		a = e.qe
+	} else {
+		d.chigh -= e.qe
+		if a&0x8000 != 0 {
+			d.a = a
+			return mps
+		}
+		// MPS sub interval with conditional exchange.
+		if a < e.qe {
+			bit = 1 ^ mps
+			if e.switchMPS {
+				mps = bit
+			}
+			index = e.nlps
+		} else {
+			bit = mps
+			index = e.nmps
+		}
+	}
+
+	// Renormalization.
+	for {
+		if d.ct == 0 {
+			d.byteIn()
+		}
+		a <<= 1
+		d.chigh = d.chigh<<1&0xFFFF | d.clow>>15&1
+		d.clow = d.clow << 1 & 0xFFFF
+		d.ct--
+		if a&0x8000 != 0 {
+			break
+		}
+	}
+
+	d.a = a
+	cx[i] = index<<1 | byte(mps)
+
+	return bit
+}
+
+// decodeInt decodes an integer using the contexts cx of an integer arithmetic decoding procedure like IADH.
+// It returns false for OOB.
+func (d *mqDecoder) decodeInt(cx []byte) (int, bool) {
+
+	prev := 1
+
+	bits := func(n int) int {
+		v := 0
+		for i := 0; i < n; i++ {
+			b := d.decode(cx, prev)
+			if prev < 256 {
+				prev = prev<<1 | b
+			} else {
+				prev = (prev<<1|b)&511 | 256
+			}
+			v = v<<1 | b
+		}
+		return v
+	}
+
+	sign := bits(1)
+
+	var v int
+
+	switch {
+	case bits(1) == 0:
+		v = bits(2)
+	case bits(1) == 0:
+		v = bits(4) + 4
+	case bits(1) == 0:
+		v = bits(6) + 20
+	case bits(1) == 0:
+		v = bits(8) + 84
+	case bits(1) == 0:
+		v = bits(12) + 340
+	default:
+		v = bits(32) + 4436
+	}
+
+	if sign == 0 {
+		return v, true
+	}
+
+	if v == 0 {
+		return 0, false
+	}
+
+	return -v, true
+}
+
+// decodeID decodes a symbol ID of n bits using the IAID contexts cx which hold 1<<(n+1) entries.
+func (d *mqDecoder) decodeID(cx []byte, n int) int {
+
+	prev := 1
+
+	for i := 0; i < n; i++ {
+		prev = prev<<1 | d.decode(cx, prev)
+	}
+
+	return prev - 1<<uint(n)
+}
@@ -0,0 +1,370 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package filter
+
+import (
+	"bytes"
+
+	"github.com/pkg/errors"
+)
+
+// The maximum number of pixels of a JBIG2 bitmap.
+const maxJBIG2Pixels = 1 << 28
+
+// jbig2Bitmap is a bilevel image using one byte per pixel, 1 represents black.
+type jbig2Bitmap struct {
+	w, h int
+	pix  []byte
+}
+
+func newJBIG2Bitmap(w, h int) (*jbig2Bitmap, error) {
+
+	if w < 0 || h < 0 || w > 0 && h > maxJBIG2Pixels/w {
+		return nil, errors.Errorf("DecodeJBIG2: invalid bitmap size %dx%d", w, h)
+	}
+
+	return &jbig2Bitmap{w: w, h: h, pix: make([]byte, w*h)}, nil
+}
+
+// get returns the pixel at x,y, pixels outside the bitmap are 0.
+func (b *jbig2Bitmap) get(x, y int) byte {
+
+	if x < 0 || y < 0 || x >= b.w || y >= b.h {
+		return 0
+	}
+
+	return b.pix[y*b.w+x]
+}
+
+func (b *jbig2Bitmap) fill(v byte) {
+
+	for i := range b.pix {
+		b.pix[i] = v
+	}
+}
+
+// grow extends the bitmap to h rows filled with v.
+func (b *jbig2Bitmap) grow(h int, v byte) error {
+
+	if h <= b.h {
+		return nil
+	}
+
+	if b.w > 0 && h > maxJBIG2Pixels/b.w {
+		return errors.Errorf("DecodeJBIG2: invalid bitmap size %dx%d", b.w, h)
+	}
+
+	n := len(b.pix)
+	b.pix = append(b.pix, make([]byte, (h-b.h)*b.w)...)
+	for i := n; i < len(b.pix); i++ {
+		b.pix[i] = v
+	}
+	b.h = h
+
+	return nil
+}
+
+// subBitmap returns a copy of the w x h area at x,y.
+func (b *jbig2Bitmap) subBitmap(x, y, w, h int) (*jbig2Bitmap, error) {
+
+	s, err := newJBIG2Bitmap(w, h)
+	if err != nil {
+		return nil, err
+	}
+
+	for j := 0; j < h; j++ {
+		for i := 0; i < w; i++ {
+			s.pix[j*w+i] = b.get(x+i, y+j)
+		}
+	}
+
+	return s, nil
+}
+
+// Combination operators.
+const (
+	combOr = iota
+	combAnd
+	combXor
+	combXnor
+	combReplace
+)
+
+// compose combines src into b at x,y using the combination operator op.
+func (b *jbig2Bitmap) compose(src *jbig2Bitmap, x, y, op int) {
+
+	for j := 0; j < src.h; j++ {
+
+		if y+j < 0 || y+j >= b.h {
+			continue
+		}
+
+		for i := 0; i < src.w; i++ {
+
+			if x+i < 0 || x+i >= b.w {
+				continue
+			}
+
+			d := &b.pix[(y+j)*b.w+x+i]
+			s := src.pix[j*src.w+i]
+
+			switch op {
+			case combOr:
+				*d |= s
+			case combAnd:
+				*d &= s
+			case combXor:
+				*d ^= s
+			case combXnor:
+				*d = 1 ^ *d ^ s
+			default:
+				*d = s
+			}
+		}
+	}
+}
+
+// bytes packs the rows of the bitmap using 1 bit per pixel where each row starts on a byte boundary.
+// 1 bits represent white pixels.
+func (b *jbig2Bitmap) bytes() []byte {
+
+	var buf bytes.Buffer
+
+	rowLen := (b.w + 7) / 8
+
+	for y := 0; y < b.h; y++ {
+		row := make([]byte, rowLen)
+		for x := 0; x < b.w; x++ {
+			if b.pix[y*b.w+x] == 0 {
+				row[x/8] |= 0x80 >> uint(x%8)
+			}
+		}
+		buf.Write(row)
+	}
+
+	return buf.Bytes()
+}
+
+// point is the offset of a template pixel.
+type point struct {
+	x, y int
+}
+
+// templatePixel is either located at a fixed offset or at the position of an adaptive template pixel.
+type templatePixel struct {
+	x, y int
+	at   int // the 1-based index of the adaptive template pixel or 0.
+}
+
+// The generic region templates ordered from the most significant to the least significant bit of a context.
+var genericTemplates = [4][]templatePixel{
+	{{0, 0, 4}, {-1, -2, 0}, {0, -2, 0}, {1, -2, 0}, {0, 0, 3},
+		{0, 0, 2}, {-2, -1, 0}, {-1, -1, 0}, {0, -1, 0}, {1, -1, 0}, {2, -1, 0}, {0, 0, 1},
+		{-4, 0, 0}, {-3, 0, 0}, {-2, 0, 0}, {-1, 0, 0}},
+	{{-1, -2, 0}, {0, -2, 0}, {1, -2, 0}, {2, -2, 0},
+		{-2, -1, 0}, {-1, -1, 0}, {0, -1, 0}, {1, -1, 0}, {2, -1, 0}, {0, 0, 1},
+		{-3, 0, 0}, {-2, 0, 0}, {-1, 0, 0}},
+	{{-1, -2, 0}, {0, -2, 0}, {1, -2, 0},
+		{-2, -1, 0}, {-1, -1, 0}, {0, -1, 0}, {1, -1, 0}, {0, 0, 1},
+		{-2, 0, 0}, {-1, 0, 0}},
+	{{-3, -1, 0}, {-2, -1, 0}, {-1, -1, 0}, {0, -1, 0}, {1, -1, 0}, {0, 0, 1},
+		{-4, 0, 0}, {-3, 0, 0}, {-2, 0, 0}, {-1, 0, 0}},
+}
+
+// The contexts used for decoding SLTP, see 6.2.5.7.
+var genericSLTPContexts = [4]int{0x9B25, 0x0795, 0x00E5, 0x0195}
+
+// resolveTemplate returns the offsets of the template pixels using the adaptive template pixels at.
+func resolveTemplate(t []templatePixel, at []point) ([]point, error) {
+
+	pp := make([]point, len(t))
+
+	for i, tp := range t {
+		if tp.at == 0 {
+			pp[i] = point{tp.x, tp.y}
+			continue
+		}
+		if tp.at > len(at) {
+			return nil, errors.New("DecodeJBIG2: missing adaptive template pixel")
+		}
+		pp[i] = at[tp.at-1]
+	}
+
+	return pp, nil
+}
+
+// decodeGenericRegion decodes a generic region using arithmetic decoding, see 6.2.5.
+// cx holds the GB contexts which are shared by all generic regions of a segment.
+func decodeGenericRegion(d *mqDecoder, cx []byte, w, h, template int, tpgdon bool, at []point) (*jbig2Bitmap, error) {
+
+	b, err := newJBIG2Bitmap(w, h)
+	if err != nil {
+		return nil, err
+	}
+
+	pp, err := resolveTemplate(genericTemplates[template], at)
+	if err != nil {
+		return nil, err
+	}
+
+	ltp := 0
+
+	for y := 0; y < h; y++ {
+
+		if tpgdon {
+			ltp ^= d.decode(cx, genericSLTPContexts[template])
+			if ltp == 1 {
+				// The row is a copy of the previous row.
+				if y > 0 {
+					copy(b.pix[y*w:(y+1)*w], b.pix[(y-1)*w:y*w])
+				}
+				continue
+			}
+		}
+
+		for x := 0; x < w; x++ {
+			c := 0
+			for _, p := range pp {
+				c = c<<1 | int(b.get(x+p.x, y+p.y))
+			}
+			b.pix[y*w+x] = byte(d.decode(cx, c))
+		}
+	}
+
+	return b, nil
+}
+
+// decodeGenericRegionMMR decodes a generic region using MMR (Group 4) decoding.
+func decodeGenericRegionMMR(data []byte, w, h int) (*jbig2Bitmap, error) {
+
+	b, err := newJBIG2Bitmap(w, h)
+	if err != nil {
+		return nil, err
+	}
+
+	if w == 0 || h == 0 {
+		return b, nil
+	}
+
+	d, err := newCCITTDecoder(data, map[string]int{"K": -1, "Columns": w, "Rows": h, "BlackIs1": 1})
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+
+	err = d.decode(&buf)
+	if err != nil {
+		return nil, err
+	}
+
+	rowLen := (w + 7) / 8
+	p := buf.Bytes()
+
+	for y := 0; y < h && (y+1)*rowLen <= len(p); y++ {
+		for x := 0; x < w; x++ {
+			b.pix[y*w+x] = p[y*rowLen+x/8] >> uint(7-x%8) & 1
+		}
+	}
+
+	return b, nil
+}
+
+// The generic refinement templates ordered from the most significant to the least significant bit of a context.
+// The pixels of the reference bitmap precede the pixels of the bitmap being decoded.
+var refinementTemplates = [2]struct {
+	reference, coding []templatePixel
+}{
+	{
+		reference: []templatePixel{{0, 0, 2}, {0, -1, 0}, {1, -1, 0}, {-1, 0, 0}, {0, 0, 0}, {1, 0, 0}, {-1, 1, 0}, {0, 1, 0}, {1, 1, 0}},
+		coding:    []templatePixel{{0, 0, 1}, {0, -1, 0}, {1, -1, 0}, {-1, 0, 0}},
+	},
+	{
+		reference: []templatePixel{{0, -1, 0}, {-1, 0, 0}, {0, 0, 0}, {1, 0, 0}, {0, 1, 0}, {1, 1, 0}},
+		coding:    []templatePixel{{-1, -1, 0}, {0, -1, 0}, {1, -1, 0}, {-1, 0, 0}},
+	},
+}
+
+// The contexts used for decoding SLTP, see 6.3.5.6.
+var refinementSLTPContexts = [2]int{0x0100, 0x0080}
+
+// decodeRefinementRegion decodes a generic refinement region, see 6.3.5.
+// cx holds the GR contexts which are shared by all refinement regions of a segment.
+func decodeRefinementRegion(d *mqDecoder, cx []byte, w, h, template int, ref *jbig2Bitmap, dx, dy int, tpgron bool, at []point) (*jbig2Bitmap, error) {
+
+	b, err := newJBIG2Bitmap(w, h)
+	if err != nil {
+		return nil, err
+	}
+
+	t := refinementTemplates[template]
+
+	rp, err := resolveTemplate(t.reference, at)
+	if err != nil {
+		return nil, err
+	}
+
+	cp, err := resolveTemplate(t.coding, at)
+	if err != nil {
+		return nil, err
+	}
+
+	context := func(x, y int) int {
+		c := 0
+		for _, p := range rp {
+			c = c<<1 | int(ref.get(x-dx+p.x, y-dy+p.y))
+		}
+		for _, p := range cp {
+			c = c<<1 | int(b.get(x+p.x, y+p.y))
+		}
+		return c
+	}
+
+	// typical returns the value of the reference pixel corresponding to x,y if its 3x3 neighbourhood is uniform.
+	typical := func(x, y int) (byte, bool) {
+		v := ref.get(x-dx, y-dy)
+		for j := -1; j <= 1; j++ {
+			for i := -1; i <= 1; i++ {
+				if ref.get(x-dx+i, y-dy+j) != v {
+					return 0, false
+				}
+			}
+		}
+		return v, true
+	}
+
+	ltp := 0
+
+	for y := 0; y < h; y++ {
+
+		if tpgron {
+			ltp ^= d.decode(cx, refinementSLTPContexts[template])
+		}
+
+		for x := 0; x < w; x++ {
+			if ltp == 1 {
+				if v, ok := typical(x, y); ok {
+					b.pix[y*w+x] = v
+					continue
+				}
+			}
+			b.pix[y*w+x] = byte(d.decode(cx, context(x, y)))
+		}
+	}
+
+	return b, nil
+}
@@ -0,0 +1,518 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package filter
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+
+	"github.com/hhrutter/pdfcpu/pkg/log"
+	"github.com/pkg/errors"
+)
+
+// JBIG2Decode decodes bilevel image data encoded using JBIG2 (ITU-T T.88) in the embedded stream format of PDF.
+// Any segments of a JBIG2Globals stream have to precede the segments of the image.
+// The result is a bilevel image with one bit per pixel where each row starts on a byte boundary
+// and 0 bits represent black pixels.
+//
+// Supported are generic regions (MMR and arithmetic), generic refinement regions,
+// symbol dictionaries, text regions and custom Huffman tables.
+
+type jbig2Decode struct {
+	baseFilter
+}
+
+// Encode implements encoding for a JBIG2Decode filter.
+func (f jbig2Decode) Encode(r io.Reader) (*bytes.Buffer, error) {
+	return nil, errors.New("EncodeJBIG2: not supported")
+}
+
+// Decode implements decoding for a JBIG2Decode filter.
+func (f jbig2Decode) Decode(r io.Reader) (*bytes.Buffer, error) {
+
+	log.Debug.Println("DecodeJBIG2 begin")
+
+	src, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &jbig2Decoder{segments: map[int]*jbig2Segment{}}
+
+	err = d.decode(src)
+	if err != nil {
+		return nil, err
+	}
+
+	if d.page == nil {
+		return nil, errors.New("DecodeJBIG2: missing page information")
+	}
+
+	log.Debug.Printf("DecodeJBIG2 end: %dx%d pixels\n", d.page.w, d.page.h)
+
+	return bytes.NewBuffer(d.page.bytes()), nil
+}
+
+// Segment types, see 7.3.
+const (
+	segSymbolDictionary                  = 0
+	segIntermediateTextRegion            = 4
+	segImmediateTextRegion               = 6
+	segImmediateLosslessTextRegion       = 7
+	segPatternDictionary                 = 16
+	segIntermediateHalftoneRegion        = 20
+	segImmediateHalftoneRegion           = 22
+	segImmediateLosslessHalftoneRegion   = 23
+	segIntermediateGenericRegion         = 36
+	segImmediateGenericRegion            = 38
+	segImmediateLosslessGenericRegion    = 39
+	segIntermediateRefinementRegion      = 40
+	segImmediateRefinementRegion         = 42
+	segImmediateLosslessRefinementRegion = 43
+	segPageInformation                   = 48
+	segEndOfPage                         = 49
+	segEndOfStripe                       = 50
+	segEndOfFile                         = 51
+	segTables                            = 53
+)
+
+// jbig2Segment is a segment and the results of decoding it that may be referred to by other segments.
+type jbig2Segment struct {
+	number int
+	typ    int
+	refs   []int
+	page   int
+	data   []byte
+
+	symbols []*jbig2Bitmap // the exported symbols of a symbol dictionary.
+	table   huffmanTable   // the table of a tables segment.
+	bitmap  *jbig2Bitmap   // the result of an intermediate region.
+}
+
+// jbig2Decoder decodes the page of an embedded stream.
+type jbig2Decoder struct {
+	segments    map[int]*jbig2Segment
+	page        *jbig2Bitmap
+	pageNr      int
+	pageDefault byte
+	pageStriped bool // the page height is unknown in advance.
+}
+
+func be32(b []byte) uint32 {
+	return binary.BigEndian.Uint32(b)
+}
+
+// regionInfo is the region segment information field, see 7.4.1.
+type regionInfo struct {
+	w, h, x, y int
+	op         int
+}
+
+func parseRegionInfo(data []byte) (regionInfo, error) {
+
+	if len(data) < 17 {
+		return regionInfo{}, errors.New("DecodeJBIG2: corrupt region segment information")
+	}
+
+	return regionInfo{
+		w:  int(be32(data)),
+		h:  int(be32(data[4:])),
+		x:  int(be32(data[8:])),
+		y:  int(be32(data[12:])),
+		op: int(data[16] & 7),
+	}, nil
+}
+
+// readAT reads n adaptive template pixels.
+func readAT(data []byte, pos, n int) ([]point, int, error) {
+
+	if len(data) < pos+2*n {
+		return nil, 0, errors.New("DecodeJBIG2: corrupt adaptive template pixels")
+	}
+
+	at := make([]point, n)
+	for i := range at {
+		at[i] = point{int(int8(data[pos])), int(int8(data[pos+1]))}
+		pos += 2
+	}
+
+	return at, pos, nil
+}
+
+// parseSegmentHeader parses the segment header at the beginning of data, see 7.2.
+// It returns the segment and the length of its header.
+func parseSegmentHeader(data []byte) (*jbig2Segment, int, error) {
+
+	errCorrupt := errors.New("DecodeJBIG2: corrupt segment header")
+
+	if len(data) < 6 {
+		return nil, 0, errCorrupt
+	}
+
+	s := &jbig2Segment{number: int(be32(data))}
+
+	flags := data[4]
+	s.typ = int(flags & 0x3F)
+	pageAssociationSize := 1
+	if flags&0x40 != 0 {
+		pageAssociationSize = 4
+	}
+
+	pos := 5
+
+	refCount := int(data[pos] >> 5)
+	if refCount == 7 {
+		if len(data) < pos+4 {
+			return nil, 0, errCorrupt
+		}
+		refCount = int(be32(data[pos:]) & 0x1FFFFFFF)
+		pos += 4 + (refCount+8)/8
+	} else {
+		pos++
+	}
+
+	refSize := 1
+	if s.number > 65536 {
+		refSize = 4
+	} else if s.number > 256 {
+		refSize = 2
+	}
+
+	if refCount < 0 || len(data) < pos+refCount*refSize+pageAssociationSize+4 {
+		return nil, 0, errCorrupt
+	}
+
+	for i := 0; i < refCount; i++ {
+		var n int
+		switch refSize {
+		case 1:
+			n = int(data[pos])
+		case 2:
+			n = int(binary.BigEndian.Uint16(data[pos:]))
+		default:
+			n = int(be32(data[pos:]))
+		}
+		s.refs = append(s.refs, n)
+		pos += refSize
+	}
+
+	if pageAssociationSize == 1 {
+		s.page = int(data[pos])
+	} else {
+		s.page = int(be32(data[pos:]))
+	}
+	pos += pageAssociationSize
+
+	dataLen := be32(data[pos:])
+	pos += 4
+
+	if dataLen == 0xFFFFFFFF {
+		n, err := unknownSegmentLength(s, data[pos:])
+		if err != nil {
+			return nil, 0, err
+		}
+		dataLen = uint32(n)
+	}
+
+	if uint32(len(data)-pos) < dataLen {
+		return nil, 0, errors.Errorf("DecodeJBIG2: segment %d: unexpected end of data", s.number)
+	}
+
+	s.data = data[pos : pos+int(dataLen)]
+
+	return s, pos, nil
+}
+
+// unknownSegmentLength determines the length of an immediate generic region segment
+// by searching for the end marker followed by the row count, see 7.2.7.
+func unknownSegmentLength(s *jbig2Segment, data []byte) (int, error) {
+
+	if s.typ != segImmediateGenericRegion || len(data) < 18 {
+		return 0, errors.Errorf("DecodeJBIG2: segment %d: unknown segment length", s.number)
+	}
+
+	marker := []byte{0xFF, 0xAC}
+	if data[17]&1 != 0 {
+		// MMR
+		marker = []byte{0x00, 0x00}
+	}
+	pattern := append(marker, data[4:8]...)
+
+	i := bytes.Index(data[18:], pattern)
+	if i < 0 {
+		return 0, errors.Errorf("DecodeJBIG2: segment %d: missing end of data marker", s.number)
+	}
+
+	return 18 + i + len(pattern), nil
+}
+
+func (d *jbig2Decoder) decode(data []byte) error {
+
+	for len(data) > 0 {
+
+		s, n, err := parseSegmentHeader(data)
+		if err != nil {
+			return err
+		}
+
+		data = data[n+len(s.data):]
+
+		log.Debug.Printf("DecodeJBIG2: segment %d type %d page %d refs %v length %d\n", s.number, s.typ, s.page, s.refs, len(s.data))
+
+		if s.page != 0 && d.pageNr != 0 && s.page != d.pageNr {
+			// Embedded streams are supposed to hold a single page.
+			continue
+		}
+
+		d.segments[s.number] = s
+
+		if s.typ == segEndOfPage || s.typ == segEndOfFile {
+			break
+		}
+
+		err = d.decodeSegment(s)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (d *jbig2Decoder) decodeSegment(s *jbig2Segment) (err error) {
+
+	switch s.typ {
+
+	case segSymbolDictionary:
+		err = d.symbolDictionary(s)
+
+	case segIntermediateTextRegion, segImmediateTextRegion, segImmediateLosslessTextRegion:
+		err = d.textRegion(s)
+
+	case segIntermediateGenericRegion, segImmediateGenericRegion, segImmediateLosslessGenericRegion:
+		err = d.genericRegion(s)
+
+	case segIntermediateRefinementRegion, segImmediateRefinementRegion, segImmediateLosslessRefinementRegion:
+		err = d.refinementRegion(s)
+
+	case segPatternDictionary, segIntermediateHalftoneRegion, segImmediateHalftoneRegion, segImmediateLosslessHalftoneRegion:
+		err = errors.Errorf("DecodeJBIG2: segment %d: halftone regions not supported", s.number)
+
+	case segPageInformation:
+		err = d.pageInformation(s)
+
+	case segEndOfStripe:
+		err = d.endOfStripe(s)
+
+	case segTables:
+		s.table, err = parseHuffmanTable(s.data)
+
+	default:
+		log.Debug.Printf("DecodeJBIG2: ignoring segment %d type %d\n", s.number, s.typ)
+	}
+
+	return err
+}
+
+// pageInformation creates the page bitmap, see 7.4.8.
+func (d *jbig2Decoder) pageInformation(s *jbig2Segment) error {
+
+	if d.page != nil {
+		return nil
+	}
+
+	if len(s.data) < 19 {
+		return errors.New("DecodeJBIG2: corrupt page information")
+	}
+
+	w, h := be32(s.data), be32(s.data[4:])
+
+	flags := s.data[16]
+	if flags&4 != 0 {
+		d.pageDefault = 1
+	}
+
+	if h == 0xFFFFFFFF {
+		d.pageStriped = true
+		h = 0
+	}
+
+	page, err := newJBIG2Bitmap(int(w), int(h))
+	if err != nil {
+		return err
+	}
+
+	page.fill(d.pageDefault)
+
+	d.page = page
+	d.pageNr = s.page
+
+	return nil
+}
+
+// endOfStripe extends a page of unknown height, see 7.4.10.
+func (d *jbig2Decoder) endOfStripe(s *jbig2Segment) error {
+
+	if d.page == nil || !d.pageStriped {
+		return nil
+	}
+
+	if len(s.data) < 4 {
+		return errors.New("DecodeJBIG2: corrupt end of stripe")
+	}
+
+	return d.page.grow(int(be32(s.data))+1, d.pageDefault)
+}
+
+// intermediate returns true for intermediate region segments whose result is used by another region.
+func intermediate(s *jbig2Segment) bool {
+	return s.typ == segIntermediateTextRegion || s.typ == segIntermediateGenericRegion || s.typ == segIntermediateRefinementRegion
+}
+
+// placeRegion combines the bitmap of an immediate region with the page or retains the bitmap of an intermediate region.
+func (d *jbig2Decoder) placeRegion(s *jbig2Segment, ri regionInfo, b *jbig2Bitmap) error {
+
+	if intermediate(s) {
+		s.bitmap = b
+		return nil
+	}
+
+	if d.page == nil {
+		return errors.Errorf("DecodeJBIG2: segment %d: missing page information", s.number)
+	}
+
+	if d.pageStriped {
+		err := d.page.grow(ri.y+b.h, d.pageDefault)
+		if err != nil {
+			return err
+		}
+	}
+
+	d.page.compose(b, ri.x, ri.y, ri.op)
+
+	return nil
+}
+
+// genericRegion decodes a generic region segment, see 7.4.6.
+func (d *jbig2Decoder) genericRegion(s *jbig2Segment) error {
+
+	ri, err := parseRegionInfo(s.data)
+	if err != nil {
+		return err
+	}
+
+	if len(s.data) < 18 {
+		return errors.New("DecodeJBIG2: corrupt generic region")
+	}
+
+	flags := s.data[17]
+	mmr := flags&1 != 0
+	template := int(flags >> 1 & 3)
+	tpgdon := flags&8 != 0
+
+	if flags&0x10 != 0 {
+		return errors.Errorf("DecodeJBIG2: segment %d: extended templates not supported", s.number)
+	}
+
+	pos := 18
+
+	var b *jbig2Bitmap
+
+	if mmr {
+		b, err = decodeGenericRegionMMR(s.data[pos:], ri.w, ri.h)
+	} else {
+		n := 1
+		if template == 0 {
+			n = 4
+		}
+		var at []point
+		at, pos, err = readAT(s.data, pos, n)
+		if err != nil {
+			return err
+		}
+		cx := make([]byte, 1<<uint(len(genericTemplates[template])))
+		b, err = decodeGenericRegion(newMQDecoder(s.data[pos:]), cx, ri.w, ri.h, template, tpgdon, at)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return d.placeRegion(s, ri, b)
+}
+
+// refinementRegion decodes a generic refinement region segment, see 7.4.7.
+func (d *jbig2Decoder) refinementRegion(s *jbig2Segment) error {
+
+	ri, err := parseRegionInfo(s.data)
+	if err != nil {
+		return err
+	}
+
+	if len(s.data) < 18 {
+		return errors.New("DecodeJBIG2: corrupt refinement region")
+	}
+
+	flags := s.data[17]
+	template := int(flags & 1)
+	tpgron := flags&2 != 0
+
+	pos := 18
+
+	var at []point
+	if template == 0 {
+		at, pos, err = readAT(s.data, pos, 2)
+		if err != nil {
+			return err
+		}
+	}
+
+	// The reference is either an intermediate region or the corresponding area of the page.
+	var ref *jbig2Bitmap
+
+	for _, n := range s.refs {
+		if rs, ok := d.segments[n]; ok && rs.bitmap != nil {
+			ref = rs.bitmap
+		}
+	}
+
+	if ref == nil {
+		if d.page == nil {
+			return errors.Errorf("DecodeJBIG2: segment %d: missing page information", s.number)
+		}
+		ref, err = d.page.subBitmap(ri.x, ri.y, ri.w, ri.h)
+		if err != nil {
+			return err
+		}
+	}
+
+	cx := make([]byte, 1<<uint(len(refinementTemplates[template].reference)+len(refinementTemplates[template].coding)))
+
+	b, err := decodeRefinementRegion(newMQDecoder(s.data[pos:]), cx, ri.w, ri.h, template, ref, 0, 0, tpgron, at)
+	if err != nil {
+		return err
+	}
+
+	if !intermediate(s) && len(s.refs) == 0 {
+		// The refined area replaces the corresponding area of the page.
+		ri.op = combReplace
+	}
+
+	return d.placeRegion(s, ri, b)
+}
@@ -0,0 +1,875 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package filter
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+)
+
+// The test vector of T.88 Annex H.2.
+var (
+	mqTestData    = []byte{0x00, 0x02, 0x00, 0x51, 0x00, 0x00, 0x00, 0xC0, 0x03, 0x52, 0x87, 0x2A, 0xAA, 0xAA, 0xAA, 0xAA, 0x82, 0xC0, 0x20, 0x00, 0xFC, 0xD7, 0x9E, 0xF6, 0xBF, 0x7F, 0xED, 0x90, 0x4F, 0x46, 0xA3, 0xBF}
+	mqTestEncoded = []byte{0x84, 0xC7, 0x3B, 0xFC, 0xE1, 0xA1, 0x43, 0x04, 0x02, 0x20, 0x00, 0x00, 0x41, 0x0D, 0xBB, 0x86, 0xF4, 0x31, 0x7F, 0xFF, 0x88, 0xFF, 0x37, 0x47, 0x1A, 0xDB, 0x6A, 0xDF, 0xFF, 0xAC}
+)
+
+// mqEncoder is the MQ encoder of T.88 Annex E used to create test data.
+type mqEncoder struct {
+	a, c uint32
+	ct   int
+	out  []byte // the bytes written preceded by a dummy byte.
+}
+
+func newMQEncoder() *mqEncoder {
+	return &mqEncoder{a: 0x8000, ct: 12, out: []byte{0}}
+}
+
+func (e *mqEncoder) emit(shift uint) {
+	e.out = append(e.out, byte(e.c>>shift))
+	e.c &= 1<<shift - 1
+	e.ct = 8
+	if shift == 20 {
+		e.ct = 7
+	}
+}
+
+func (e *mqEncoder) byteOut() {
+
+	b := &e.out[len(e.out)-1]
+
+	switch {
+	case *b == 0xFF:
+		e.emit(20)
+	case e.c < 0x8000000:
+		e.emit(19)
+	default:
+		*b++
+		if *b == 0xFF {
+			e.c &= 0x7FFFFFF
+			e.emit(20)
+			return
+		}
+		e.emit(19)
+	}
+}
+
+func (e *mqEncoder) renorm() {
+	for {
+		e.a <<= 1
+		e.c <<= 1
+		e.ct--
+		if e.ct == 0 {
+			e.byteOut()
+		}
+		if e.a&0x8000 != 0 {
+			return
+		}
+	}
+}
+
+func (e *mqEncoder) encode(cx []byte, i, d int) {
+
+	index, mps := cx[i]>>1, int(cx[i]&1)
+	q := qeTable[index]
+
+	e.a -= q.qe
+
+	if d == mps {
+		if e.a&0x8000 != 0 {
+			e.c += q.qe
+			return
+		}
+		if e.a < q.qe {
+			e.a = q.qe
+		} else {
+			e.c += q.qe
+		}
+		index = q.nmps
+	} else {
+		if e.a < q.qe {
+			e.c += q.qe
+		} else {
+			e.a = q.qe
+		}
+		if q.switchMPS {
+			mps = 1 - mps
+		}
+		index = q.nlps
+	}
+
+	cx[i] = index<<1 | byte(mps)
+	e.renorm()
+}
+
+// flush terminates the encoded data with the marker FFAC.
+func (e *mqEncoder) flush() []byte {
+
+	t := e.c + e.a
+	e.c |= 0xFFFF
+	if e.c >= t {
+		e.c -= 0x8000
+	}
+
+	e.c <<= uint(e.ct)
+	e.byteOut()
+	e.c <<= uint(e.ct)
+	e.byteOut()
+
+	b := e.out[1:]
+	if b[len(b)-1] != 0xFF {
+		b = append(b, 0xFF)
+	}
+
+	return append(b, 0xAC)
+}
+
+func (e *mqEncoder) encodeInt(cx []byte, v int, oob bool) {
+
+	prev := 1
+
+	write := func(v, n int) {
+		for i := n - 1; i >= 0; i-- {
+			b := v >> uint(i) & 1
+			e.encode(cx, prev, b)
+			if prev < 256 {
+				prev = prev<<1 | b
+			} else {
+				prev = (prev<<1|b)&511 | 256
+			}
+		}
+	}
+
+	if oob {
+		write(8, 4)
+		return
+	}
+
+	if v < 0 {
+		write(1, 1)
+		v = -v
+	} else {
+		write(0, 1)
+	}
+
+	switch {
+	case v < 4:
+		write(0, 1)
+		write(v, 2)
+	case v < 20:
+		write(2, 2)
+		write(v-4, 4)
+	case v < 84:
+		write(6, 3)
+		write(v-20, 6)
+	case v < 340:
+		write(14, 4)
+		write(v-84, 8)
+	case v < 4436:
+		write(30, 5)
+		write(v-340, 12)
+	default:
+		write(31, 5)
+		write(v-4436, 32)
+	}
+}
+
+func (e *mqEncoder) encodeID(cx []byte, id, n int) {
+
+	prev := 1
+
+	for i := n - 1; i >= 0; i-- {
+		b := id >> uint(i) & 1
+		e.encode(cx, prev, b)
+		prev = prev<<1 | b
+	}
+}
+
+func (e *mqEncoder) encodeGenericRegion(cx []byte, b *jbig2Bitmap, template int, tpgdon bool, at []point) {
+
+	pp, _ := resolveTemplate(genericTemplates[template], at)
+
+	ltp := 0
+
+	for y := 0; y < b.h; y++ {
+
+		if tpgdon {
+			typical := 1
+			for x := 0; x < b.w; x++ {
+				if b.get(x, y) != b.get(x, y-1) {
+					typical = 0
+					break
+				}
+			}
+			e.encode(cx, genericSLTPContexts[template], typical^ltp)
+			ltp = typical
+			if ltp == 1 {
+				continue
+			}
+		}
+
+		for x := 0; x < b.w; x++ {
+			c := 0
+			for _, p := range pp {
+				c = c<<1 | int(b.get(x+p.x, y+p.y))
+			}
+			e.encode(cx, c, int(b.get(x, y)))
+		}
+	}
+}
+
+func (e *mqEncoder) encodeRefinementRegion(cx []byte, b *jbig2Bitmap, template int, ref *jbig2Bitmap, dx, dy int, at []point) {
+
+	t := refinementTemplates[template]
+	rp, _ := resolveTemplate(t.reference, at)
+	cp, _ := resolveTemplate(t.coding, at)
+
+	for y := 0; y < b.h; y++ {
+		for x := 0; x < b.w; x++ {
+			c := 0
+			for _, p := range rp {
+				c = c<<1 | int(ref.get(x-dx+p.x, y-dy+p.y))
+			}
+			for _, p := range cp {
+				c = c<<1 | int(b.get(x+p.x, y+p.y))
+			}
+			e.encode(cx, c, int(b.get(x, y)))
+		}
+	}
+}
+
+// huffmanWriter writes Huffman coded data as a string of '0' and '1'.
+type huffmanWriter struct {
+	s strings.Builder
+}
+
+func (w *huffmanWriter) write(v, n int) {
+	for i := n - 1; i >= 0; i-- {
+		w.s.WriteByte(byte('0' + v>>uint(i)&1))
+	}
+}
+
+func (w *huffmanWriter) align() {
+	for w.s.Len()%8 != 0 {
+		w.s.WriteByte('0')
+	}
+}
+
+func (w *huffmanWriter) encode(t huffmanTable, v int, oob bool) {
+
+	var key *huffmanKey
+
+	for k, l := range t {
+		k := k
+		switch {
+		case oob && l.kind == lineOOB:
+			w.write(int(k.code), k.n)
+			return
+		case !oob && l.kind == lineNormal && v >= l.rangeLow && v < l.rangeLow+1<<uint(l.rangeLen):
+			w.write(int(k.code), k.n)
+			w.write(v-l.rangeLow, l.rangeLen)
+			return
+		case !oob && (l.kind == lineLower && v <= l.rangeLow || l.kind == lineUpper && v >= l.rangeLow):
+			key = &k
+		}
+	}
+
+	if key == nil {
+		panic("huffmanWriter: value not encodable")
+	}
+
+	l := t[*key]
+	w.write(int(key.code), key.n)
+	if l.kind == lineLower {
+		w.write(l.rangeLow-v, 32)
+	} else {
+		w.write(v-l.rangeLow, 32)
+	}
+}
+
+func (w *huffmanWriter) bytes() []byte {
+	w.align()
+	return bits(w.s.String())
+}
+
+// jbig2TestEncoder writes arithmetic or Huffman coded integers.
+type jbig2TestEncoder struct {
+	mq *mqEncoder
+	cx map[string][]byte
+	hw *huffmanWriter
+}
+
+func newJBIG2TestEncoder(huff bool) *jbig2TestEncoder {
+
+	if huff {
+		return &jbig2TestEncoder{hw: &huffmanWriter{}}
+	}
+
+	return &jbig2TestEncoder{mq: newMQEncoder(), cx: map[string][]byte{}}
+}
+
+func (e *jbig2TestEncoder) contexts(name string, n int) []byte {
+
+	if _, ok := e.cx[name]; !ok {
+		e.cx[name] = make([]byte, n)
+	}
+
+	return e.cx[name]
+}
+
+func (e *jbig2TestEncoder) integer(name string, t int, v int) {
+
+	if e.hw != nil {
+		e.hw.encode(standardTable(t), v, false)
+		return
+	}
+
+	e.mq.encodeInt(e.contexts(name, 512), v, false)
+}
+
+func (e *jbig2TestEncoder) oob(name string, t int) {
+
+	if e.hw != nil {
+		e.hw.encode(standardTable(t), 0, true)
+		return
+	}
+
+	e.mq.encodeInt(e.contexts(name, 512), 0, true)
+}
+
+func (e *jbig2TestEncoder) bytes() []byte {
+
+	if e.hw != nil {
+		return e.hw.bytes()
+	}
+
+	return e.mq.flush()
+}
+
+func be32Bytes(vv ...int) []byte {
+
+	var b []byte
+
+	for _, v := range vv {
+		b = append(b, 0, 0, 0, 0)
+		binary.BigEndian.PutUint32(b[len(b)-4:], uint32(v))
+	}
+
+	return b
+}
+
+func segment(nr, typ int, refs []int, page int, data []byte) []byte {
+
+	b := be32Bytes(nr)
+	b = append(b, byte(typ), byte(len(refs)<<5))
+	for _, r := range refs {
+		b = append(b, byte(r))
+	}
+	b = append(b, byte(page))
+	b = append(b, be32Bytes(len(data))...)
+
+	return append(b, data...)
+}
+
+func pageInformation(w, h int) []byte {
+	return segment(0, segPageInformation, nil, 1, append(be32Bytes(w, h, 0, 0), 0, 0, 0))
+}
+
+func regionInformation(w, h, x, y, op int) []byte {
+	return append(be32Bytes(w, h, x, y), byte(op))
+}
+
+// testBitmap returns a bitmap with a few shapes and repeated rows.
+func testBitmap(w, h, k int) *jbig2Bitmap {
+
+	b, _ := newJBIG2Bitmap(w, h)
+
+	for y := 0; y < h; y++ {
+		yy := y
+		if y%4 == 3 {
+			// Repeat rows for typical prediction.
+			yy--
+		}
+		for x := 0; x < w; x++ {
+			dx, dy := x-w/2, yy-h/2
+			if dx*dx+dy*dy < w*h/(k+3) || (x*k+yy*(k+1))%7 < 2 {
+				b.pix[y*w+x] = 1
+			}
+		}
+	}
+
+	return b
+}
+
+// packRows packs the pixels of b where 1 bits represent black pixels.
+func packRows(b *jbig2Bitmap) []byte {
+
+	rowLen := (b.w + 7) / 8
+	p := make([]byte, rowLen*b.h)
+
+	for y := 0; y < b.h; y++ {
+		for x := 0; x < b.w; x++ {
+			p[y*rowLen+x/8] |= b.pix[y*b.w+x] << uint(7-x%8)
+		}
+	}
+
+	return p
+}
+
+func decodeJBIG2(t *testing.T, data []byte) []byte {
+
+	t.Helper()
+
+	f, err := NewFilter(JBIG2, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf, err := f.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestJBIG2MQDecoder(t *testing.T) {
+
+	d := newMQDecoder(mqTestEncoded)
+	cx := make([]byte, 1)
+
+	got := make([]byte, len(mqTestData))
+	for i := range got {
+		for j := 0; j < 8; j++ {
+			got[i] = got[i]<<1 | byte(d.decode(cx, 0))
+		}
+	}
+
+	if !bytes.Equal(got, mqTestData) {
+		t.Fatalf("got\n% X\nwant\n% X\n", got, mqTestData)
+	}
+
+	// Verify the encoder used for creating test data.
+	e := newMQEncoder()
+	cx = make([]byte, 1)
+	for _, b := range mqTestData {
+		for j := 7; j >= 0; j-- {
+			e.encode(cx, 0, int(b>>uint(j)&1))
+		}
+	}
+
+	if got := e.flush(); !bytes.Equal(got, mqTestEncoded) {
+		t.Fatalf("encoder: got\n% X\nwant\n% X\n", got, mqTestEncoded)
+	}
+}
+
+func TestJBIG2ArithmeticIntegers(t *testing.T) {
+
+	vv := []int{0, 1, -1, 3, 4, -19, 20, 83, 84, 339, -340, 4435, 4436, 100000, -7}
+
+	e := newMQEncoder()
+	cx := make([]byte, 512)
+	for _, v := range vv {
+		e.encodeInt(cx, v, false)
+	}
+	e.encodeInt(cx, 0, true)
+
+	d := newMQDecoder(e.flush())
+	cx = make([]byte, 512)
+
+	for _, want := range vv {
+		if got, ok := d.decodeInt(cx); !ok || got != want {
+			t.Fatalf("got %d %t, want %d\n", got, ok, want)
+		}
+	}
+
+	if _, ok := d.decodeInt(cx); ok {
+		t.Fatal("missing OOB")
+	}
+}
+
+func TestJBIG2HuffmanTables(t *testing.T) {
+
+	for _, tt := range []struct {
+		table int
+		code  string
+		want  int
+		oob   bool
+	}{
+		{1, "0 0101", 5, false},
+		{1, "10 00000001", 17, false},
+		{8, "01", 0, true},
+		{8, "100 0011", 7, false},
+		{8, "1010", -1, false},
+		{8, "111111110 00000000000000000000000000000101", -21, false},
+		{8, "111111111 00000000000000000000000000000000", 1670, false},
+		{11, "0", 1, false},
+		{15, "0", 0, false},
+	} {
+		r := &jbig2BitReader{data: bits(tt.code)}
+		got, ok, err := standardTable(tt.table).decode(r)
+		if err != nil {
+			t.Fatalf("B.%d %s: %v\n", tt.table, tt.code, err)
+		}
+		if ok == tt.oob || got != tt.want {
+			t.Fatalf("B.%d %s: got %d %t, want %d\n", tt.table, tt.code, got, !ok, tt.want)
+		}
+	}
+}
+
+func TestJBIG2GenericRegion(t *testing.T) {
+
+	w, h := 61, 37
+
+	for template, at := range [][]point{
+		{{3, -1}, {-3, -1}, {2, -2}, {-2, -2}},
+		{{3, -1}},
+		{{2, -1}},
+		{{2, -1}},
+	} {
+		for _, tpgdon := range []bool{false, true} {
+
+			b := testBitmap(w, h, template+1)
+
+			e := newMQEncoder()
+			e.encodeGenericRegion(make([]byte, 1<<uint(len(genericTemplates[template]))), b, template, tpgdon, at)
+
+			flags := template << 1
+			if tpgdon {
+				flags |= 8
+			}
+
+			data := append(regionInformation(w, h, 0, 0, combOr), byte(flags))
+			for _, p := range at {
+				data = append(data, byte(p.x), byte(p.y))
+			}
+			data = append(data, e.flush()...)
+
+			src := append(pageInformation(w, h), segment(1, segImmediateGenericRegion, nil, 1, data)...)
+			src = append(src, segment(2, segEndOfPage, nil, 1, nil)...)
+
+			if got, want := decodeJBIG2(t, src), b.bytes(); !bytes.Equal(got, want) {
+				t.Fatalf("template %d tpgdon %t: got\n% X\nwant\n% X\n", template, tpgdon, got, want)
+			}
+		}
+	}
+}
+
+func TestJBIG2GenericRegionUnknownLength(t *testing.T) {
+
+	w, h := 40, 20
+	b := testBitmap(w, h, 2)
+
+	e := newMQEncoder()
+	e.encodeGenericRegion(make([]byte, 1<<16), b, 0, false, []point{{3, -1}, {-3, -1}, {2, -2}, {-2, -2}})
+
+	data := append(regionInformation(w, h, 0, 0, combOr), 0, 3, 0xFF, 0xFD, 0xFF, 2, 0xFE, 0xFE, 0xFE)
+	data = append(data, e.flush()...)
+	data = append(data, be32Bytes(h)...)
+
+	seg := segment(1, segImmediateGenericRegion, nil, 1, data)
+	copy(seg[7:], []byte{0xFF, 0xFF, 0xFF, 0xFF})
+
+	src := append(pageInformation(w, h), seg...)
+	src = append(src, segment(2, segEndOfPage, nil, 1, nil)...)
+
+	if got, want := decodeJBIG2(t, src), b.bytes(); !bytes.Equal(got, want) {
+		t.Fatalf("got\n% X\nwant\n% X\n", got, want)
+	}
+}
+
+func TestJBIG2GenericRegionMMR(t *testing.T) {
+
+	w, h := 75, 30
+	b := testBitmap(w, h, 1)
+
+	enc := newCCITTEncoder(map[string]int{"K": -1, "Columns": w, "Rows": h, "BlackIs1": 1})
+
+	data := append(regionInformation(w, h, 5, 3, combOr), 1)
+	data = append(data, enc.encode(packRows(b)).Bytes()...)
+
+	src := append(pageInformation(w+10, h+6), segment(1, segImmediateGenericRegion, nil, 1, data)...)
+
+	page, _ := newJBIG2Bitmap(w+10, h+6)
+	page.compose(b, 5, 3, combOr)
+
+	if got, want := decodeJBIG2(t, src), page.bytes(); !bytes.Equal(got, want) {
+		t.Fatalf("got\n% X\nwant\n% X\n", got, want)
+	}
+}
+
+// glyph returns a symbol bitmap.
+func glyph(w, h, k int) *jbig2Bitmap {
+
+	b, _ := newJBIG2Bitmap(w, h)
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if x == 0 || y == k%h || (x*k+y)%5 == 0 {
+				b.pix[y*w+x] = 1
+			}
+		}
+	}
+
+	return b
+}
+
+// symbolDictionary returns the data of a symbol dictionary segment exporting all symbols of the height classes hc.
+func symbolDictionary(huff bool, hc [][]*jbig2Bitmap) []byte {
+
+	n := 0
+	for _, symbols := range hc {
+		n += len(symbols)
+	}
+
+	at := []point{{3, -1}, {-3, -1}, {2, -2}, {-2, -2}}
+
+	var data []byte
+
+	if huff {
+		data = []byte{0, 1}
+	} else {
+		data = []byte{0, 0}
+		for _, p := range at {
+			data = append(data, byte(p.x), byte(p.y))
+		}
+	}
+
+	data = append(data, be32Bytes(n, n)...)
+
+	e := newJBIG2TestEncoder(huff)
+
+	h := 0
+
+	for _, symbols := range hc {
+
+		e.integer("IADH", 4, symbols[0].h-h)
+		h = symbols[0].h
+
+		w, totWidth := 0, 0
+
+		for _, s := range symbols {
+			e.integer("IADW", 2, s.w-w)
+			w = s.w
+			totWidth += w
+			if !huff {
+				e.mq.encodeGenericRegion(e.contexts("GB", 1<<16), s, 0, false, at)
+			}
+		}
+
+		e.oob("IADW", 2)
+
+		if huff {
+			// Uncompressed collective bitmap.
+			e.integer("", 1, 0)
+			e.hw.align()
+			for y := 0; y < h; y++ {
+				for _, s := range symbols {
+					for x := 0; x < s.w; x++ {
+						e.hw.write(int(s.pix[y*s.w+x]), 1)
+					}
+				}
+				e.hw.align()
+			}
+		}
+	}
+
+	// Export all symbols.
+	e.integer("IAEX", 1, 0)
+	e.integer("IAEX", 1, n)
+
+	return append(data, e.bytes()...)
+}
+
+// symbolInstance is a symbol placed in a text region at its top left corner s,t.
+type symbolInstance struct {
+	id, s, t int
+	refined  *jbig2Bitmap
+}
+
+// textRegion returns the data of a text region segment with reference corner TOPLEFT, instances are grouped by strips.
+func textRegion(huff bool, w, h int, symbols []*jbig2Bitmap, strips [][]symbolInstance) []byte {
+
+	refine := false
+	n := 0
+	for _, ii := range strips {
+		for _, i := range ii {
+			n++
+			refine = refine || i.refined != nil
+		}
+	}
+
+	flags := cornerTopLeft << 4
+	if huff {
+		flags |= 1
+	}
+	if refine {
+		flags |= 2
+	}
+
+	data := append(regionInformation(w, h, 0, 0, combOr), byte(flags>>8), byte(flags))
+
+	if huff {
+		// Standard tables only
+		data = append(data, 0, 0)
+	}
+
+	rat := []point{{-1, -1}, {-1, -1}}
+
+	if refine {
+		for _, p := range rat {
+			data = append(data, byte(p.x), byte(p.y))
+		}
+	}
+
+	data = append(data, be32Bytes(n)...)
+
+	e := newJBIG2TestEncoder(huff)
+
+	symCodeLen := symbolCodeLength(len(symbols))
+	var symCodes huffmanTable
+
+	stripT := 0
+
+	if huff {
+		// All symbol codes have length symCodeLen coded by run code symCodeLen using prefix 0.
+		for i := 0; i < 35; i++ {
+			if i == symCodeLen {
+				e.hw.write(1, 4)
+			} else {
+				e.hw.write(0, 4)
+			}
+		}
+		var lines []huffmanLine
+		for i := range symbols {
+			e.hw.write(0, 1)
+			lines = append(lines, huffmanLine{symCodeLen, 0, i, lineNormal})
+		}
+		e.hw.align()
+		symCodes = newHuffmanTable(lines)
+
+		// B.11 does not cover 0.
+		e.integer("IADT", 11, 1)
+		stripT = -1
+	} else {
+		e.integer("IADT", 11, 0)
+	}
+
+	firstS := 0
+
+	for _, ii := range strips {
+
+		e.integer("IADT", 11, ii[0].t-stripT)
+		stripT = ii[0].t
+
+		e.integer("IAFS", 6, ii[0].s-firstS)
+		firstS = ii[0].s
+
+		for j, i := range ii {
+
+			if huff {
+				e.hw.encode(symCodes, i.id, false)
+			} else {
+				e.mq.encodeID(e.contexts("IAID", 1<<uint(symCodeLen+1)), i.id, symCodeLen)
+			}
+
+			b := symbols[i.id]
+
+			if refine {
+				if i.refined == nil {
+					e.integer("IARI", 0, 0)
+				} else {
+					e.integer("IARI", 0, 1)
+					rdw, rdh := i.refined.w-b.w, i.refined.h-b.h
+					for _, name := range []string{"IARDW", "IARDH", "IARDX", "IARDY"} {
+						v := 0
+						if name == "IARDW" {
+							v = rdw
+						}
+						if name == "IARDH" {
+							v = rdh
+						}
+						e.integer(name, 0, v)
+					}
+					e.mq.encodeRefinementRegion(e.contexts("GR", 1<<13), i.refined, 0, b, rdw>>1, rdh>>1, rat)
+					b = i.refined
+				}
+			}
+
+			if j == len(ii)-1 {
+				e.oob("IADS", 8)
+				continue
+			}
+
+			e.integer("IADS", 8, ii[j+1].s-(i.s+b.w-1))
+		}
+	}
+
+	return append(data, e.bytes()...)
+}
+
+func TestJBIG2TextRegion(t *testing.T) {
+
+	a, b, c := glyph(6, 8, 1), glyph(7, 8, 2), glyph(5, 10, 3)
+	symbols := []*jbig2Bitmap{a, b, c}
+
+	refined := glyph(8, 8, 4)
+
+	for _, tt := range []struct {
+		huff   bool
+		refine bool
+	}{
+		{false, false},
+		{true, false},
+		{false, true},
+	} {
+
+		strips := [][]symbolInstance{
+			{{0, 1, 2, nil}, {1, 10, 2, nil}, {2, 20, 2, nil}},
+			{{2, 3, 15, nil}, {0, 12, 15, nil}},
+		}
+
+		if tt.refine {
+			strips[1][1].refined = refined
+		}
+
+		w, h := 40, 30
+
+		page, _ := newJBIG2Bitmap(w, h)
+		for _, ii := range strips {
+			for _, i := range ii {
+				s := symbols[i.id]
+				if i.refined != nil {
+					s = i.refined
+				}
+				page.compose(s, i.s, i.t, combOr)
+			}
+		}
+
+		// The symbol dictionary is part of the globals.
+		globals := segment(1, segSymbolDictionary, nil, 0, symbolDictionary(tt.huff, [][]*jbig2Bitmap{{a, b}, {c}}))
+
+		src := append(globals, pageInformation(w, h)...)
+		src = append(src, segment(2, segImmediateTextRegion, []int{1}, 1, textRegion(tt.huff, w, h, symbols, strips))...)
+		src = append(src, segment(3, segEndOfPage, nil, 1, nil)...)
+
+		if got, want := decodeJBIG2(t, src), page.bytes(); !bytes.Equal(got, want) {
+			t.Fatalf("huff %t refine %t: got\n% X\nwant\n% X\n", tt.huff, tt.refine, got, want)
+		}
+	}
+}
@@ -0,0 +1,292 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package filter
+
+import (
+	"github.com/pkg/errors"
+)
+
+// Huffman decoding for JBIG2 as defined in ITU-T T.88 Annex B.
+
+// jbig2BitReader reads bits starting with the most significant bit of each byte.
+type jbig2BitReader struct {
+	data []byte
+	pos  int // the current bit position.
+}
+
+func (r *jbig2BitReader) bit() (int, error) {
+
+	if r.pos >= len(r.data)*8 {
+		return 0, errors.New("DecodeJBIG2: unexpected end of data")
+	}
+
+	b := r.data[r.pos/8] >> uint(7-r.pos%8) & 1
+	r.pos++
+
+	return int(b), nil
+}
+
+// bits reads an unsigned integer of n <= 32 bits.
+func (r *jbig2BitReader) bits(n int) (int, error) {
+
+	var v uint32
+
+	for i := 0; i < n; i++ {
+		b, err := r.bit()
+		if err != nil {
+			return 0, err
+		}
+		v = v<<1 | uint32(b)
+	}
+
+	return int(v), nil
+}
+
+// align skips to the next byte boundary.
+func (r *jbig2BitReader) align() {
+	r.pos = (r.pos + 7) / 8 * 8
+}
+
+// Kinds of table lines.
+const (
+	lineNormal = iota
+	lineLower  // the lower range line covering all values less than rangeLow.
+	lineUpper  // the upper range line covering all values greater than or equal rangeLow.
+	lineOOB
+)
+
+// huffmanLine is a line of a Huffman table.
+type huffmanLine struct {
+	prefLen, rangeLen, rangeLow int
+	kind                        int
+}
+
+type huffmanKey struct {
+	n    int
+	code uint32
+}
+
+// huffmanTable maps prefix codes to table lines.
+type huffmanTable map[huffmanKey]huffmanLine
+
+// newHuffmanTable assigns prefix codes to the lines of a table using the procedure of B.3.
+func newHuffmanTable(lines []huffmanLine) huffmanTable {
+
+	maxLen := 0
+	for _, l := range lines {
+		if l.prefLen > maxLen {
+			maxLen = l.prefLen
+		}
+	}
+
+	count := make([]int, maxLen+1)
+	for _, l := range lines {
+		count[l.prefLen]++
+	}
+	count[0] = 0
+
+	t := huffmanTable{}
+
+	var firstCode uint32
+
+	for n := 1; n <= maxLen; n++ {
+		firstCode = (firstCode + uint32(count[n-1])) << 1
+		code := firstCode
+		for _, l := range lines {
+			if l.prefLen == n {
+				t[huffmanKey{n, code}] = l
+				code++
+			}
+		}
+	}
+
+	return t
+}
+
+// decode returns the next value, false for OOB.
+func (t huffmanTable) decode(r *jbig2BitReader) (int, bool, error) {
+
+	var code uint32
+
+	for n := 1; n <= 32; n++ {
+
+		b, err := r.bit()
+		if err != nil {
+			return 0, false, err
+		}
+
+		code = code<<1 | uint32(b)
+
+		l, ok := t[huffmanKey{n, code}]
+		if !ok {
+			continue
+		}
+
+		switch l.kind {
+
+		case lineOOB:
+			return 0, false, nil
+
+		case lineLower:
+			v, err := r.bits(32)
+			return l.rangeLow - v, true, err
+
+		case lineUpper:
+			v, err := r.bits(32)
+			return l.rangeLow + v, true, err
+		}
+
+		v, err := r.bits(l.rangeLen)
+		return l.rangeLow + v, true, err
+	}
+
+	return 0, false, errors.New("DecodeJBIG2: invalid Huffman code")
+}
+
+// standardTable returns table B.n.
+func standardTable(n int) huffmanTable {
+	return standardTables[n-1]
+}
+
+// The standard Huffman tables B.1 to B.15.
+var standardTables = func() []huffmanTable {
+
+	type l = huffmanLine
+
+	lower := func(prefLen, rangeLow int) l { return l{prefLen, 32, rangeLow, lineLower} }
+	upper := func(prefLen, rangeLow int) l { return l{prefLen, 32, rangeLow, lineUpper} }
+	oob := func(prefLen int) l { return l{prefLen, 0, 0, lineOOB} }
+
+	var tt []huffmanTable
+
+	for _, lines := range [][]huffmanLine{
+		// B.1
+		{{1, 4, 0, 0}, {2, 8, 16, 0}, {3, 16, 272, 0}, upper(3, 65808)},
+		// B.2
+		{{1, 0, 0, 0}, {2, 0, 1, 0}, {3, 0, 2, 0}, {4, 3, 3, 0}, {5, 6, 11, 0}, upper(6, 75), oob(6)},
+		// B.3
+		{{8, 8, -256, 0}, {1, 0, 0, 0}, {2, 0, 1, 0}, {3, 0, 2, 0}, {4, 3, 3, 0}, {5, 6, 11, 0},
+			lower(8, -257), upper(7, 75), oob(6)},
+		// B.4
+		{{1, 0, 1, 0}, {2, 0, 2, 0}, {3, 0, 3, 0}, {4, 3, 4, 0}, {5, 6, 12, 0}, upper(5, 76)},
+		// B.5
+		{{7, 8, -255, 0}, {1, 0, 1, 0}, {2, 0, 2, 0}, {3, 0, 3, 0}, {4, 3, 4, 0}, {5, 6, 12, 0},
+			lower(7, -256), upper(6, 76)},
+		// B.6
+		{{5, 10, -2048, 0}, {4, 9, -1024, 0}, {4, 8, -512, 0}, {4, 7, -256, 0}, {5, 6, -128, 0}, {5, 5, -64, 0},
+			{4, 5, -32, 0}, {2, 7, 0, 0}, {3, 7, 128, 0}, {3, 8, 256, 0}, {4, 9, 512, 0}, {4, 10, 1024, 0},
+			lower(6, -2049), upper(6, 2048)},
+		// B.7
+		{{4, 9, -1024, 0}, {3, 8, -512, 0}, {4, 7, -256, 0}, {5, 6, -128, 0}, {5, 5, -64, 0}, {4, 5, -32, 0},
+			{4, 5, 0, 0}, {5, 5, 32, 0}, {5, 6, 64, 0}, {4, 7, 128, 0}, {3, 8, 256, 0}, {3, 9, 512, 0},
+			{3, 10, 1024, 0}, lower(5, -1025), upper(5, 2048)},
+		// B.8
+		{{8, 3, -15, 0}, {9, 1, -7, 0}, {8, 1, -5, 0}, {9, 0, -3, 0}, {7, 0, -2, 0}, {4, 0, -1, 0},
+			{2, 1, 0, 0}, {5, 0, 2, 0}, {6, 0, 3, 0}, {3, 4, 4, 0}, {6, 1, 20, 0}, {4, 4, 22, 0},
+			{4, 5, 38, 0}, {5, 6, 70, 0}, {5, 7, 134, 0}, {6, 7, 262, 0}, {7, 8, 390, 0}, {6, 10, 646, 0},
+			lower(9, -16), upper(9, 1670), oob(2)},
+		// B.9
+		{{8, 4, -31, 0}, {9, 2, -15, 0}, {8, 2, -11, 0}, {9, 1, -7, 0}, {7, 1, -5, 0}, {4, 1, -3, 0},
+			{3, 1, -1, 0}, {3, 1, 1, 0}, {5, 1, 3, 0}, {6, 1, 5, 0}, {3, 5, 7, 0}, {6, 2, 39, 0},
+			{4, 5, 43, 0}, {4, 6, 75, 0}, {5, 7, 139, 0}, {5, 8, 267, 0}, {6, 8, 523, 0}, {7, 9, 779, 0},
+			{6, 11, 1291, 0}, lower(9, -32), upper(9, 3339), oob(2)},
+		// B.10
+		{{7, 4, -21, 0}, {8, 0, -5, 0}, {7, 0, -4, 0}, {5, 0, -3, 0}, {2, 2, -2, 0}, {5, 0, 2, 0},
+			{6, 0, 3, 0}, {7, 0, 4, 0}, {8, 0, 5, 0}, {2, 6, 6, 0}, {5, 5, 70, 0}, {6, 5, 102, 0},
+			{6, 6, 134, 0}, {6, 7, 198, 0}, {6, 8, 326, 0}, {6, 9, 582, 0}, {6, 10, 1094, 0}, {7, 11, 2118, 0},
+			lower(8, -22), upper(8, 4166), oob(2)},
+		// B.11
+		{{1, 0, 1, 0}, {2, 1, 2, 0}, {4, 0, 4, 0}, {4, 1, 5, 0}, {5, 1, 7, 0}, {5, 2, 9, 0},
+			{6, 2, 13, 0}, {7, 2, 17, 0}, {7, 3, 21, 0}, {7, 4, 29, 0}, {7, 5, 45, 0}, {7, 6, 77, 0},
+			upper(7, 141)},
+		// B.12
+		{{1, 0, 1, 0}, {2, 0, 2, 0}, {3, 1, 3, 0}, {5, 0, 5, 0}, {5, 1, 6, 0}, {6, 1, 8, 0},
+			{7, 0, 10, 0}, {7, 1, 11, 0}, {7, 2, 13, 0}, {7, 3, 17, 0}, {7, 4, 25, 0}, {8, 5, 41, 0},
+			upper(8, 73)},
+		// B.13
+		{{1, 0, 1, 0}, {3, 0, 2, 0}, {4, 0, 3, 0}, {5, 0, 4, 0}, {4, 1, 5, 0}, {3, 3, 7, 0},
+			{6, 1, 15, 0}, {6, 2, 17, 0}, {6, 3, 21, 0}, {6, 4, 29, 0}, {6, 5, 45, 0}, {7, 6, 77, 0},
+			upper(7, 141)},
+		// B.14
+		{{3, 0, -2, 0}, {3, 0, -1, 0}, {1, 0, 0, 0}, {3, 0, 1, 0}, {3, 0, 2, 0}},
+		// B.15
+		{{7, 4, -24, 0}, {6, 2, -8, 0}, {5, 1, -4, 0}, {4, 0, -2, 0}, {3, 0, -1, 0}, {1, 0, 0, 0},
+			{3, 0, 1, 0}, {4, 0, 2, 0}, {5, 1, 3, 0}, {6, 2, 5, 0}, {7, 4, 9, 0},
+			lower(7, -25), upper(7, 25)},
+	} {
+		tt = append(tt, newHuffmanTable(lines))
+	}
+
+	return tt
+}()
+
+// parseHuffmanTable parses the data of a tables segment, see 7.4.13 and B.2.
+func parseHuffmanTable(data []byte) (huffmanTable, error) {
+
+	if len(data) < 9 {
+		return nil, errors.New("DecodeJBIG2: corrupt tables segment")
+	}
+
+	flags := data[0]
+	htoob := flags&1 != 0
+	htps := int(flags>>1&7) + 1
+	htrs := int(flags>>4&7) + 1
+	low := int(int32(be32(data[1:])))
+	high := int(int32(be32(data[5:])))
+
+	r := &jbig2BitReader{data: data[9:]}
+
+	var lines []huffmanLine
+
+	for cur := low; cur < high; {
+
+		prefLen, err := r.bits(htps)
+		if err != nil {
+			return nil, err
+		}
+
+		rangeLen, err := r.bits(htrs)
+		if err != nil {
+			return nil, err
+		}
+
+		lines = append(lines, huffmanLine{prefLen, rangeLen, cur, lineNormal})
+		cur += 1 << uint(rangeLen)
+	}
+
+	prefLen, err := r.bits(htps)
+	if err != nil {
+		return nil, err
+	}
+	lines = append(lines, huffmanLine{prefLen, 32, low - 1, lineLower})
+
+	prefLen, err = r.bits(htps)
+	if err != nil {
+		return nil, err
+	}
+	lines = append(lines, huffmanLine{prefLen, 32, high, lineUpper})
+
+	if htoob {
+		prefLen, err = r.bits(htps)
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, huffmanLine{prefLen, 0, 0, lineOOB})
+	}
+
+	return newHuffmanTable(lines), nil
+}
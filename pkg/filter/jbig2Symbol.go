@@ -0,0 +1,751 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package filter
+
+import (
+	"encoding/binary"
+
+	"github.com/pkg/errors"
+)
+
+// Symbol dictionary and text region decoding, see 6.4, 6.5, 7.4.2 and 7.4.3.
+
+// jbig2Coder holds the state of either arithmetic or Huffman decoding of a segment.
+type jbig2Coder struct {
+	mq *mqDecoder
+	cx map[string][]byte // the arithmetic decoding contexts by procedure name.
+	br *jbig2BitReader
+}
+
+func newJBIG2Coder(data []byte, huff bool) *jbig2Coder {
+
+	if huff {
+		return &jbig2Coder{br: &jbig2BitReader{data: data}}
+	}
+
+	return &jbig2Coder{mq: newMQDecoder(data), cx: map[string][]byte{}}
+}
+
+// contexts returns the n contexts of procedure name.
+func (c *jbig2Coder) contexts(name string, n int) []byte {
+
+	cx, ok := c.cx[name]
+	if !ok || len(cx) < n {
+		cx = make([]byte, n)
+		c.cx[name] = cx
+	}
+
+	return cx
+}
+
+// integer decodes an integer using either the arithmetic integer decoding procedure name or Huffman table t.
+// It returns false for OOB.
+func (c *jbig2Coder) integer(name string, t huffmanTable) (int, bool, error) {
+
+	if c.br != nil {
+		return t.decode(c.br)
+	}
+
+	v, ok := c.mq.decodeInt(c.contexts(name, 512))
+
+	return v, ok, nil
+}
+
+// value decodes an integer that must not be OOB.
+func (c *jbig2Coder) value(name string, t huffmanTable) (int, error) {
+
+	v, ok, err := c.integer(name, t)
+	if err == nil && !ok {
+		err = errors.Errorf("DecodeJBIG2: unexpected OOB decoding %s", name)
+	}
+
+	return v, err
+}
+
+// symbolID decodes a symbol ID of n bits using arithmetic decoding or the Huffman table t.
+func (c *jbig2Coder) symbolID(n int, t huffmanTable) (int, error) {
+
+	if c.br != nil {
+		v, _, err := t.decode(c.br)
+		return v, err
+	}
+
+	return c.mq.decodeID(c.contexts("IAID", 1<<uint(n+1)), n), nil
+}
+
+// refine decodes a refinement of the symbol ref, see 6.4.11.
+func (c *jbig2Coder) refine(ref *jbig2Bitmap, template int, at []point) (*jbig2Bitmap, error) {
+
+	if c.br != nil {
+		return nil, errors.New("DecodeJBIG2: Huffman coded refinement not supported")
+	}
+
+	var rd [4]int
+
+	for i, name := range []string{"IARDW", "IARDH", "IARDX", "IARDY"} {
+		v, err := c.value(name, nil)
+		if err != nil {
+			return nil, err
+		}
+		rd[i] = v
+	}
+
+	rdw, rdh, rdx, rdy := rd[0], rd[1], rd[2], rd[3]
+
+	cx := c.contexts("GR", 1<<13)
+
+	return decodeRefinementRegion(c.mq, cx, ref.w+rdw, ref.h+rdh, template, ref, rdw>>1+rdx, rdh>>1+rdy, false, at)
+}
+
+// symbolCodeLength returns the number of bits needed for n symbol IDs.
+func symbolCodeLength(n int) int {
+
+	l := 0
+	for 1<<uint(l) < n {
+		l++
+	}
+
+	return l
+}
+
+// referredSymbolsAndTables returns the symbols exported by the symbol dictionaries and the tables of the segments referred to by s.
+func (d *jbig2Decoder) referredSymbolsAndTables(s *jbig2Segment) ([]*jbig2Bitmap, []huffmanTable) {
+
+	var symbols []*jbig2Bitmap
+	var tables []huffmanTable
+
+	for _, n := range s.refs {
+		rs, ok := d.segments[n]
+		if !ok {
+			continue
+		}
+		switch rs.typ {
+		case segSymbolDictionary:
+			symbols = append(symbols, rs.symbols...)
+		case segTables:
+			tables = append(tables, rs.table)
+		}
+	}
+
+	return symbols, tables
+}
+
+// tableSelector returns the standard or custom Huffman tables selected by the flags of a segment.
+type tableSelector struct {
+	custom []huffmanTable
+	err    error
+}
+
+// table returns the standard table B.n or for n = 0 the next custom table.
+func (ts *tableSelector) table(n int) huffmanTable {
+
+	if n > 0 {
+		return standardTable(n)
+	}
+
+	if len(ts.custom) == 0 {
+		ts.err = errors.New("DecodeJBIG2: missing custom Huffman table")
+		return nil
+	}
+
+	t := ts.custom[0]
+	ts.custom = ts.custom[1:]
+
+	return t
+}
+
+// symbolDictionary decodes a symbol dictionary segment, see 6.5 and 7.4.2.
+func (d *jbig2Decoder) symbolDictionary(s *jbig2Segment) error {
+
+	data := s.data
+
+	if len(data) < 2 {
+		return errors.New("DecodeJBIG2: corrupt symbol dictionary")
+	}
+
+	flags := int(binary.BigEndian.Uint16(data))
+	huff := flags&1 != 0
+	refAgg := flags&2 != 0
+	template := flags >> 10 & 3
+	rTemplate := flags >> 12 & 1
+
+	pos := 2
+
+	var at, rat []point
+	var err error
+
+	if !huff {
+		n := 1
+		if template == 0 {
+			n = 4
+		}
+		at, pos, err = readAT(data, pos, n)
+		if err != nil {
+			return err
+		}
+	}
+
+	if refAgg && rTemplate == 0 {
+		rat, pos, err = readAT(data, pos, 2)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(data) < pos+8 {
+		return errors.New("DecodeJBIG2: corrupt symbol dictionary")
+	}
+
+	numEx := int(be32(data[pos:]))
+	numNew := int(be32(data[pos+4:]))
+	pos += 8
+
+	in, tables := d.referredSymbolsAndTables(s)
+
+	if numNew < 0 || numNew > maxJBIG2Pixels || numEx < 0 || numEx > len(in)+numNew {
+		return errors.New("DecodeJBIG2: corrupt symbol dictionary")
+	}
+
+	var tDH, tDW, tBMSize huffmanTable
+
+	if huff {
+		if refAgg {
+			return errors.Errorf("DecodeJBIG2: segment %d: Huffman coded refinement not supported", s.number)
+		}
+		ts := &tableSelector{custom: tables}
+		tDH = ts.table([]int{4, 5, 0, 0}[flags>>2&3])
+		tDW = ts.table([]int{2, 3, 0, 0}[flags>>4&3])
+		tBMSize = ts.table([]int{1, 0}[flags>>6&1])
+		if ts.err != nil {
+			return ts.err
+		}
+	}
+
+	c := newJBIG2Coder(data[pos:], huff)
+
+	symCodeLen := symbolCodeLength(len(in) + numNew)
+
+	newSymbols := make([]*jbig2Bitmap, 0, numNew)
+	var widths []int
+
+	for hcHeight := 0; len(newSymbols) < numNew; {
+
+		dh, err := c.value("IADH", tDH)
+		if err != nil {
+			return err
+		}
+		hcHeight += dh
+
+		symWidth, totWidth, hcFirst := 0, 0, len(newSymbols)
+
+		for {
+
+			dw, ok, err := c.integer("IADW", tDW)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				break
+			}
+
+			if len(newSymbols) == numNew {
+				return errors.New("DecodeJBIG2: too many symbols")
+			}
+
+			symWidth += dw
+			totWidth += symWidth
+
+			if huff {
+				// The bitmaps of the height class are decoded collectively.
+				newSymbols = append(newSymbols, nil)
+				widths = append(widths, symWidth)
+				continue
+			}
+
+			var b *jbig2Bitmap
+
+			if refAgg {
+				b, err = d.refinementAggregate(c, symWidth, hcHeight, append(in, newSymbols...), symCodeLen, rTemplate, rat)
+			} else {
+				cx := c.contexts("GB", 1<<uint(len(genericTemplates[template])))
+				b, err = decodeGenericRegion(c.mq, cx, symWidth, hcHeight, template, false, at)
+			}
+
+			if err != nil {
+				return err
+			}
+
+			newSymbols = append(newSymbols, b)
+		}
+
+		if huff {
+			err = collectiveBitmap(c.br, tBMSize, newSymbols[hcFirst:], widths[hcFirst:], totWidth, hcHeight)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	// Determine the exported symbols, see 6.5.10.
+	all := append(in, newSymbols...)
+	export := false
+
+	for i, n := 0, 0; i < len(all); n++ {
+
+		if n > 2*len(all)+2 {
+			return errors.New("DecodeJBIG2: corrupt export flags")
+		}
+
+		run, err := c.value("IAEX", standardTable(1))
+		if err != nil {
+			return err
+		}
+
+		if run < 0 || i+run > len(all) {
+			return errors.New("DecodeJBIG2: corrupt export flags")
+		}
+
+		if export {
+			s.symbols = append(s.symbols, all[i:i+run]...)
+		}
+
+		i += run
+		export = !export
+	}
+
+	return nil
+}
+
+// collectiveBitmap decodes the collective bitmap of a height class and assigns the symbol bitmaps, see 6.5.9.
+func collectiveBitmap(r *jbig2BitReader, tBMSize huffmanTable, symbols []*jbig2Bitmap, widths []int, totWidth, h int) error {
+
+	bmSize, err := (&jbig2Coder{br: r}).value("", tBMSize)
+	if err != nil {
+		return err
+	}
+
+	r.align()
+
+	start := r.pos / 8
+
+	var b *jbig2Bitmap
+
+	if bmSize == 0 {
+		// Uncompressed
+		rowLen := (totWidth + 7) / 8
+		if totWidth < 0 || start+rowLen*h > len(r.data) {
+			return errors.New("DecodeJBIG2: corrupt collective bitmap")
+		}
+		b, err = newJBIG2Bitmap(totWidth, h)
+		if err != nil {
+			return err
+		}
+		for y := 0; y < h; y++ {
+			for x := 0; x < totWidth; x++ {
+				b.pix[y*totWidth+x] = r.data[start+y*rowLen+x/8] >> uint(7-x%8) & 1
+			}
+		}
+		bmSize = rowLen * h
+	} else {
+		if bmSize < 0 || start+bmSize > len(r.data) {
+			return errors.New("DecodeJBIG2: corrupt collective bitmap")
+		}
+		b, err = decodeGenericRegionMMR(r.data[start:start+bmSize], totWidth, h)
+		if err != nil {
+			return err
+		}
+	}
+
+	r.pos = (start + bmSize) * 8
+
+	x := 0
+	for i, w := range widths {
+		symbols[i], err = b.subBitmap(x, 0, w, h)
+		if err != nil {
+			return err
+		}
+		x += w
+	}
+
+	return nil
+}
+
+// refinementAggregate decodes a symbol bitmap using refinement and aggregate coding, see 6.5.8.2.
+func (d *jbig2Decoder) refinementAggregate(c *jbig2Coder, w, h int, symbols []*jbig2Bitmap, symCodeLen, rTemplate int, rat []point) (*jbig2Bitmap, error) {
+
+	n, err := c.value("IAAI", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if n == 1 {
+		id, err := c.symbolID(symCodeLen, nil)
+		if err != nil {
+			return nil, err
+		}
+		if id >= len(symbols) {
+			return nil, errors.New("DecodeJBIG2: invalid symbol ID")
+		}
+
+		var rd [2]int
+		for i, name := range []string{"IARDX", "IARDY"} {
+			rd[i], err = c.value(name, nil)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		cx := c.contexts("GR", 1<<13)
+
+		return decodeRefinementRegion(c.mq, cx, w, h, rTemplate, symbols[id], rd[0], rd[1], false, rat)
+	}
+
+	p := &textRegionParams{
+		w:            w,
+		h:            h,
+		numInstances: n,
+		symbols:      symbols,
+		symCodeLen:   symCodeLen,
+		refine:       true,
+		refCorner:    cornerTopLeft,
+		rTemplate:    rTemplate,
+		rAT:          rat,
+	}
+
+	return decodeTextRegion(c, p)
+}
+
+// Reference corners of symbol instances.
+const (
+	cornerBottomLeft = iota
+	cornerTopLeft
+	cornerBottomRight
+	cornerTopRight
+)
+
+// textRegionParams are the parameters of the text region decoding procedure, see Table 9.
+type textRegionParams struct {
+	w, h         int
+	numInstances int
+	logStrips    int
+	symbols      []*jbig2Bitmap
+	symCodeLen   int
+	symCodes     huffmanTable
+	refine       bool
+	defPixel     byte
+	combOp       int
+	transposed   bool
+	refCorner    int
+	dsOffset     int
+	rTemplate    int
+	rAT          []point
+
+	// Huffman tables
+	tFS, tDS, tDT huffmanTable
+}
+
+// decodeTextRegion decodes a text region, see 6.4.5.
+func decodeTextRegion(c *jbig2Coder, p *textRegionParams) (*jbig2Bitmap, error) {
+
+	b, err := newJBIG2Bitmap(p.w, p.h)
+	if err != nil {
+		return nil, err
+	}
+
+	b.fill(p.defPixel)
+
+	strips := 1 << uint(p.logStrips)
+
+	stripT, err := c.value("IADT", p.tDT)
+	if err != nil {
+		return nil, err
+	}
+	stripT *= -strips
+
+	firstS := 0
+
+	for n := 0; n < p.numInstances; {
+
+		dt, err := c.value("IADT", p.tDT)
+		if err != nil {
+			return nil, err
+		}
+		stripT += dt * strips
+
+		dfs, err := c.value("IAFS", p.tFS)
+		if err != nil {
+			return nil, err
+		}
+		firstS += dfs
+		curS := firstS
+
+		for {
+
+			curT := 0
+			if strips > 1 {
+				if c.br != nil {
+					curT, err = c.br.bits(p.logStrips)
+				} else {
+					curT, err = c.value("IAIT", nil)
+				}
+				if err != nil {
+					return nil, err
+				}
+			}
+
+			t := stripT + curT
+
+			id, err := c.symbolID(p.symCodeLen, p.symCodes)
+			if err != nil {
+				return nil, err
+			}
+			if id < 0 || id >= len(p.symbols) {
+				return nil, errors.New("DecodeJBIG2: invalid symbol ID")
+			}
+
+			ib := p.symbols[id]
+
+			if p.refine {
+				var ri int
+				if c.br != nil {
+					ri, err = c.br.bit()
+				} else {
+					ri, err = c.value("IARI", nil)
+				}
+				if err != nil {
+					return nil, err
+				}
+				if ri != 0 {
+					ib, err = c.refine(ib, p.rTemplate, p.rAT)
+					if err != nil {
+						return nil, err
+					}
+				}
+			}
+
+			curS = placeSymbol(b, ib, p, curS, t)
+			n++
+
+			ds, ok, err := c.integer("IADS", p.tDS)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				// End of strip
+				break
+			}
+
+			curS += ds + p.dsOffset
+		}
+	}
+
+	return b, nil
+}
+
+// placeSymbol draws the symbol instance ib at s,t and returns the updated current s, see 6.4.5 3c.
+func placeSymbol(b, ib *jbig2Bitmap, p *textRegionParams, s, t int) int {
+
+	right := p.refCorner == cornerTopRight || p.refCorner == cornerBottomRight
+	bottom := p.refCorner == cornerBottomLeft || p.refCorner == cornerBottomRight
+
+	// The extent of the symbol in s direction.
+	ext := ib.w
+	if p.transposed {
+		ext = ib.h
+	}
+
+	if !p.transposed && right || p.transposed && bottom {
+		s += ext - 1
+	}
+
+	x, y := s, t
+	if p.transposed {
+		x, y = t, s
+	}
+
+	if right {
+		x -= ib.w - 1
+	}
+
+	if bottom {
+		y -= ib.h - 1
+	}
+
+	b.compose(ib, x, y, p.combOp)
+
+	if !p.transposed && !right || p.transposed && !bottom {
+		s += ext - 1
+	}
+
+	return s
+}
+
+// textRegion decodes a text region segment, see 7.4.3.
+func (d *jbig2Decoder) textRegion(s *jbig2Segment) error {
+
+	data := s.data
+
+	ri, err := parseRegionInfo(data)
+	if err != nil {
+		return err
+	}
+
+	if len(data) < 19 {
+		return errors.New("DecodeJBIG2: corrupt text region")
+	}
+
+	flags := int(binary.BigEndian.Uint16(data[17:]))
+	pos := 19
+
+	huff := flags&1 != 0
+
+	p := &textRegionParams{
+		w:          ri.w,
+		h:          ri.h,
+		refine:     flags&2 != 0,
+		logStrips:  flags >> 2 & 3,
+		refCorner:  flags >> 4 & 3,
+		transposed: flags&0x40 != 0,
+		combOp:     flags >> 7 & 3,
+		defPixel:   byte(flags >> 9 & 1),
+		dsOffset:   flags >> 10 & 0x1F,
+		rTemplate:  flags >> 15 & 1,
+	}
+
+	if p.dsOffset > 15 {
+		p.dsOffset -= 32
+	}
+
+	var hFlags int
+	if huff {
+		if len(data) < pos+2 {
+			return errors.New("DecodeJBIG2: corrupt text region")
+		}
+		hFlags = int(binary.BigEndian.Uint16(data[pos:]))
+		pos += 2
+	}
+
+	if p.refine && p.rTemplate == 0 {
+		p.rAT, pos, err = readAT(data, pos, 2)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(data) < pos+4 {
+		return errors.New("DecodeJBIG2: corrupt text region")
+	}
+
+	p.numInstances = int(be32(data[pos:]))
+	pos += 4
+
+	var tables []huffmanTable
+	p.symbols, tables = d.referredSymbolsAndTables(s)
+
+	if huff {
+		if p.refine {
+			return errors.Errorf("DecodeJBIG2: segment %d: Huffman coded refinement not supported", s.number)
+		}
+		ts := &tableSelector{custom: tables}
+		p.tFS = ts.table([]int{6, 7, 0, 0}[hFlags&3])
+		p.tDS = ts.table([]int{8, 9, 10, 0}[hFlags>>2&3])
+		p.tDT = ts.table([]int{11, 12, 13, 0}[hFlags>>4&3])
+		if ts.err != nil {
+			return ts.err
+		}
+
+		r := &jbig2BitReader{data: data[pos:]}
+		p.symCodes, err = symbolIDTable(r, len(p.symbols))
+		if err != nil {
+			return err
+		}
+
+		pos += r.pos / 8
+	}
+
+	p.symCodeLen = symbolCodeLength(len(p.symbols))
+
+	b, err := decodeTextRegion(newJBIG2Coder(data[pos:], huff), p)
+	if err != nil {
+		return err
+	}
+
+	return d.placeRegion(s, ri, b)
+}
+
+// symbolIDTable decodes the Huffman table for the symbol IDs of a text region, see 7.4.3.1.7.
+func symbolIDTable(r *jbig2BitReader, numSymbols int) (huffmanTable, error) {
+
+	var lines []huffmanLine
+
+	for i := 0; i < 35; i++ {
+		n, err := r.bits(4)
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, huffmanLine{n, 0, i, lineNormal})
+	}
+
+	runCodes := newHuffmanTable(lines)
+
+	lens := make([]int, 0, numSymbols)
+
+	for len(lens) < numSymbols {
+
+		code, _, err := runCodes.decode(r)
+		if err != nil {
+			return nil, err
+		}
+
+		v, n := code, 1
+
+		switch code {
+		case 32:
+			if len(lens) == 0 {
+				return nil, errors.New("DecodeJBIG2: corrupt symbol ID table")
+			}
+			v = lens[len(lens)-1]
+			n, err = r.bits(2)
+			n += 3
+		case 33:
+			v = 0
+			n, err = r.bits(3)
+			n += 3
+		case 34:
+			v = 0
+			n, err = r.bits(7)
+			n += 11
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		for i := 0; i < n && len(lens) < numSymbols; i++ {
+			lens = append(lens, v)
+		}
+	}
+
+	r.align()
+
+	lines = lines[:0]
+	for i, n := range lens {
+		lines = append(lines, huffmanLine{n, 0, i, lineNormal})
+	}
+
+	return newHuffmanTable(lines), nil
+}
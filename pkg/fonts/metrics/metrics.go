@@ -33,7 +33,7 @@ import (
 // Courier-Bold,
 // ZapfDingbats,
 // Times-Italic,
-// Helvetica- Oblique,
+// Helvetica-Oblique,
 // Courier-Oblique,
 // Times-BoldItalic,
 // Helvetica-BoldOblique,
@@ -83,25 +83,34 @@ func fontScalingFactor(glyphSpaceUnits, userSpaceUnits float64) int {
 	return int(userSpaceUnits / glyphSpaceUnits * 1000)
 }
 
-// TextWidth represents the width in user space units for a given text string, font name and font size.
-func TextWidth(text, fontName string, fontSize int) float64 {
-	var width float64
+// Kerning returns the kerning adjustment for a pair of chars and font in glyph space units.
+func Kerning(fontName string, c1, c2 int) int {
+	return standardFonts[fontName].kerning[c1][c2]
+}
+
+// glyphSpaceWidth returns the width of a text string including kerning in glyph space units.
+func glyphSpaceWidth(text, fontName string) int {
+	var i int
+	prev := -1
 	for _, r := range text {
-		w := CharWidth(fontName, int(r))
-		//fmt.Printf("%d %c w=%d\n", r, r, w)
-		width += userSpaceUnits(float64(w), fontSize)
+		i += CharWidth(fontName, int(r))
+		if prev >= 0 {
+			i += Kerning(fontName, prev, int(r))
+		}
+		prev = int(r)
 	}
-	return width
+	return i
+}
+
+// TextWidth represents the width in user space units for a given text string, font name and font size.
+func TextWidth(text, fontName string, fontSize int) float64 {
+	return userSpaceUnits(float64(glyphSpaceWidth(text, fontName)), fontSize)
 }
 
 // FontSize returns the needed font size (aka. font scaling factor) in points
 // for rendering a given text string using a given font name with a given user space width.
 func FontSize(text, fontName string, width float64) int {
-	var i int
-	for _, r := range text {
-		i += CharWidth(fontName, int(r))
-	}
-	return fontScalingFactor(float64(i), width)
+	return fontScalingFactor(float64(glyphSpaceWidth(text, fontName)), width)
 }
 
 // UserSpaceFontBBox returns the font box for given font name and font size in user space coordinates.
@@ -128,12 +137,25 @@ func FontNames() []string {
 	return ss
 }
 
+// Oblique fonts share the metrics of their upright counterparts, Courier is fixed pitch and has no kerning.
 var standardFonts = map[string]struct {
 	charWidths   map[int]int
 	averageWidth int
 	bbox         types.Rectangle
+	kerning      map[int]map[int]int
 }{
-	"Helvetica":   {standard.FontWidthHelvetica, 0, types.NewRectangle(-166, -225, 1000, 931)},
-	"Times-Roman": {standard.FontWidthTimesRoman, 0, types.NewRectangle(-168, -218, 1000, 898)},
-	"Courier":     {map[int]int{}, 600, types.NewRectangle(-23, -250, 715, 805)},
+	"Helvetica":             {standard.FontWidthHelvetica, 0, types.NewRectangle(-166, -225, 1000, 931), standard.KerningHelvetica},
+	"Helvetica-Bold":        {standard.FontWidthHelveticaBold, 0, types.NewRectangle(-170, -228, 1003, 962), standard.KerningHelveticaBold},
+	"Helvetica-Oblique":     {standard.FontWidthHelvetica, 0, types.NewRectangle(-170, -225, 1116, 931), standard.KerningHelvetica},
+	"Helvetica-BoldOblique": {standard.FontWidthHelveticaBold, 0, types.NewRectangle(-174, -228, 1114, 962), standard.KerningHelveticaBold},
+	"Times-Roman":           {standard.FontWidthTimesRoman, 0, types.NewRectangle(-168, -218, 1000, 898), standard.KerningTimesRoman},
+	"Times-Bold":            {standard.FontWidthTimesBold, 0, types.NewRectangle(-168, -218, 1000, 935), standard.KerningTimesBold},
+	"Times-Italic":          {standard.FontWidthTimesItalic, 0, types.NewRectangle(-169, -217, 1010, 883), standard.KerningTimesItalic},
+	"Times-BoldItalic":      {standard.FontWidthTimesBoldItalic, 0, types.NewRectangle(-200, -218, 996, 921), standard.KerningTimesBoldItalic},
+	"Courier":               {map[int]int{}, 600, types.NewRectangle(-23, -250, 715, 805), nil},
+	"Courier-Bold":          {map[int]int{}, 600, types.NewRectangle(-113, -250, 749, 801), nil},
+	"Courier-Oblique":       {map[int]int{}, 600, types.NewRectangle(-27, -250, 849, 805), nil},
+	"Courier-BoldOblique":   {map[int]int{}, 600, types.NewRectangle(-57, -250, 869, 801), nil},
+	"Symbol":                {standard.FontWidthSymbol, 0, types.NewRectangle(-180, -293, 1090, 1010), nil},
+	"ZapfDingbats":          {standard.FontWidthZapfDingbats, 0, types.NewRectangle(-1, -143, 981, 820), nil},
 }
@@ -0,0 +1,56 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import "testing"
+
+func TestStandardFonts(t *testing.T) {
+
+	if n := len(FontNames()); n != 14 {
+		t.Fatalf("TestStandardFonts: %d fonts, expected 14\n", n)
+	}
+
+	for _, fn := range FontNames() {
+		if w := TextWidth("Hello", fn, 12); w <= 0 {
+			t.Fatalf("TestStandardFonts: %s: text width %f\n", fn, w)
+		}
+	}
+}
+
+func TestTextWidthKerning(t *testing.T) {
+
+	for _, tc := range []struct {
+		fontName string
+		text     string
+		width    float64
+	}{
+		// A=667 V=667 kerned -70
+		{"Helvetica", "AV", 1264},
+		{"Helvetica-Oblique", "AV", 1264},
+		// T=611 o=611 kerned -80
+		{"Helvetica-Bold", "To", 1142},
+		// Courier is not kerned.
+		{"Courier", "AV", 1200},
+	} {
+		if w := TextWidth(tc.text, tc.fontName, 1000); w != tc.width {
+			t.Fatalf("TestTextWidthKerning: %s %s: width %f, expected %f\n", tc.fontName, tc.text, w, tc.width)
+		}
+		if fs := FontSize(tc.text, tc.fontName, tc.width); fs != 1000 {
+			t.Fatalf("TestTextWidthKerning: %s %s: font size %d, expected 1000\n", tc.fontName, tc.text, fs)
+		}
+	}
+}
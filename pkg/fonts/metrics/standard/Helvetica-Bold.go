@@ -0,0 +1,236 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package standard
+
+// StartFontMetrics 4.1
+// FontName Helvetica-Bold
+// FullName Helvetica Bold
+// FamilyName Helvetica
+// Weight Bold
+// ItalicAngle 0
+// IsFixedPitch false
+// CharacterSet ExtendedRoman
+// FontBBox -170 -228 1003 962
+// EncodingScheme AdobeStandardEncoding
+// CapHeight 718
+// XHeight 532
+// Ascender 718
+// Descender -207
+// StdHW 118
+// StdVW 140
+
+// FontWidthHelveticaBold represents the char widths for this font.
+var FontWidthHelveticaBold = map[int]int{
+	32:  278,
+	33:  333,
+	34:  474,
+	35:  556,
+	36:  556,
+	37:  889,
+	38:  722,
+	39:  278,
+	40:  333,
+	41:  333,
+	42:  389,
+	43:  584,
+	44:  278,
+	45:  333,
+	46:  278,
+	47:  278,
+	48:  556,
+	49:  556,
+	50:  556,
+	51:  556,
+	52:  556,
+	53:  556,
+	54:  556,
+	55:  556,
+	56:  556,
+	57:  556,
+	58:  333,
+	59:  333,
+	60:  584,
+	61:  584,
+	62:  584,
+	63:  611,
+	64:  975,
+	65:  722,
+	66:  722,
+	67:  722,
+	68:  722,
+	69:  667,
+	70:  611,
+	71:  778,
+	72:  722,
+	73:  278,
+	74:  556,
+	75:  722,
+	76:  611,
+	77:  833,
+	78:  722,
+	79:  778,
+	80:  667,
+	81:  778,
+	82:  722,
+	83:  667,
+	84:  611,
+	85:  722,
+	86:  667,
+	87:  944,
+	88:  667,
+	89:  667,
+	90:  611,
+	91:  333,
+	92:  278,
+	93:  333,
+	94:  584,
+	95:  556,
+	96:  278,
+	97:  556,
+	98:  611,
+	99:  556,
+	100: 611,
+	101: 556,
+	102: 333,
+	103: 611,
+	104: 611,
+	105: 278,
+	106: 278,
+	107: 556,
+	108: 278,
+	109: 889,
+	110: 611,
+	111: 611,
+	112: 611,
+	113: 611,
+	114: 389,
+	115: 556,
+	116: 333,
+	117: 611,
+	118: 556,
+	119: 778,
+	120: 556,
+	121: 556,
+	122: 500,
+	123: 389,
+	124: 280,
+	125: 389,
+	126: 584,
+	161: 333,
+	162: 556,
+	163: 556,
+	164: 167,
+	165: 556,
+	166: 556,
+	167: 556,
+	168: 556,
+	169: 238,
+	170: 500,
+	171: 556,
+	172: 333,
+	173: 333,
+	174: 611,
+	175: 611,
+	177: 556,
+	178: 556,
+	179: 556,
+	180: 278,
+	182: 556,
+	183: 350,
+	184: 278,
+	185: 500,
+	186: 500,
+	187: 556,
+	188: 1000,
+	189: 1000,
+	191: 611,
+	193: 333,
+	194: 333,
+	195: 333,
+	196: 333,
+	197: 333,
+	198: 333,
+	199: 333,
+	200: 333,
+	202: 333,
+	203: 333,
+	205: 333,
+	206: 333,
+	207: 333,
+	208: 1000,
+	225: 1000,
+	227: 370,
+	232: 611,
+	233: 778,
+	234: 1000,
+	235: 365,
+	241: 889,
+	245: 278,
+	248: 278,
+	249: 611,
+	250: 944,
+	251: 611,
+}
+
+// KerningHelveticaBold represents the kerning pairs for this font.
+var KerningHelveticaBold = map[int]map[int]int{
+	32:  {84: -100, 86: -80, 87: -80, 89: -120, 96: -60, 170: -80},                                                                             // space
+	39:  {32: -80, 39: -46, 100: -80, 108: -20, 114: -40, 115: -60},                                                                            // quoteright
+	44:  {32: -40, 39: -120, 186: -120},                                                                                                        // comma
+	46:  {32: -40, 39: -120, 186: -120},                                                                                                        // period
+	58:  {32: -40},                                                                                                                             // colon
+	59:  {32: -40},                                                                                                                             // semicolon
+	65:  {67: -40, 71: -50, 79: -40, 81: -40, 84: -90, 85: -50, 86: -80, 87: -60, 89: -110, 117: -30, 118: -40, 119: -30, 121: -30},            // A
+	66:  {65: -30, 85: -10},                                                                                                                    // B
+	68:  {44: -30, 46: -30, 65: -40, 86: -40, 87: -40, 89: -70},                                                                                // D
+	70:  {44: -100, 46: -100, 65: -80, 97: -20},                                                                                                // F
+	74:  {44: -20, 46: -20, 65: -20, 117: -20},                                                                                                 // J
+	75:  {79: -30, 101: -15, 111: -35, 117: -30, 121: -40},                                                                                     // K
+	76:  {39: -140, 84: -90, 86: -110, 87: -80, 89: -120, 121: -30, 186: -140},                                                                 // L
+	79:  {44: -40, 46: -40, 65: -50, 84: -40, 86: -50, 87: -50, 88: -50, 89: -70},                                                              // O
+	80:  {44: -120, 46: -120, 65: -100, 97: -30, 101: -30, 111: -40},                                                                           // P
+	81:  {44: 20, 46: 20, 85: -10},                                                                                                             // Q
+	82:  {79: -20, 84: -20, 85: -20, 86: -50, 87: -40, 89: -50},                                                                                // R
+	84:  {44: -80, 45: -120, 46: -80, 58: -40, 59: -40, 65: -90, 79: -40, 97: -80, 101: -60, 111: -80, 114: -80, 117: -90, 119: -60, 121: -60}, // T
+	85:  {44: -30, 46: -30, 65: -50},                                                                                                           // U
+	86:  {44: -120, 45: -80, 46: -120, 58: -40, 59: -40, 65: -80, 71: -50, 79: -50, 97: -60, 101: -50, 111: -90, 117: -60},                     // V
+	87:  {44: -80, 45: -40, 46: -80, 58: -10, 59: -10, 65: -60, 79: -20, 97: -40, 101: -35, 111: -60, 117: -45, 121: -20},                      // W
+	89:  {44: -100, 46: -100, 58: -50, 59: -50, 65: -110, 79: -70, 97: -90, 101: -80, 111: -100, 117: -100},                                    // Y
+	96:  {96: -46},                                                                                                                             // quoteleft
+	97:  {103: -10, 118: -15, 119: -15, 121: -20},                                                                                              // a
+	98:  {108: -10, 117: -20, 118: -20, 121: -20},                                                                                              // b
+	99:  {104: -10, 107: -20, 108: -20, 121: -10},                                                                                              // c
+	100: {100: -10, 118: -15, 119: -15, 121: -15},                                                                                              // d
+	101: {44: 10, 46: 20, 118: -15, 119: -15, 120: -15, 121: -15},                                                                              // e
+	102: {39: 30, 44: -10, 46: -10, 101: -10, 111: -20, 186: 30},                                                                               // f
+	103: {101: 10, 103: -10},                                                                                                                   // g
+	104: {121: -20},                                                                                                                            // h
+	107: {111: -15},                                                                                                                            // k
+	108: {119: -15, 121: -15},                                                                                                                  // l
+	109: {117: -20, 121: -30},                                                                                                                  // m
+	110: {117: -10, 118: -40, 121: -20},                                                                                                        // n
+	111: {118: -20, 119: -15, 120: -30, 121: -20},                                                                                              // o
+	112: {121: -15},                                                                                                                            // p
+	114: {44: -60, 45: -20, 46: -60, 99: -20, 100: -20, 103: -15, 111: -20, 113: -20, 115: -15, 116: 20, 118: 10, 121: 10},                     // r
+	115: {119: -15},                                                                                                                            // s
+	118: {44: -80, 46: -80, 97: -20, 111: -30},                                                                                                 // v
+	119: {44: -40, 46: -40, 111: -20},                                                                                                          // w
+	120: {101: -10},                                                                                                                            // x
+	121: {44: -80, 46: -80, 97: -30, 101: -10, 111: -25},                                                                                       // y
+	122: {101: 10},                                                                                                                             // z
+	186: {32: -80},                                                                                                                             // quotedblright
+}
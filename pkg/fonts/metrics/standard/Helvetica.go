@@ -194,3 +194,52 @@ var FontWidthHelvetica = map[int]int{
 	250: 944,
 	251: 611,
 }
+
+// KerningHelvetica represents the kerning pairs for this font.
+var KerningHelvetica = map[int]map[int]int{
+	32:  {84: -50, 86: -50, 87: -40, 89: -90, 96: -60, 170: -30},                                                                                         // space
+	39:  {32: -70, 39: -57, 100: -50, 114: -50, 115: -50},                                                                                                // quoteright
+	44:  {39: -100, 186: -100},                                                                                                                           // comma
+	46:  {32: -60, 39: -100, 186: -100},                                                                                                                  // period
+	58:  {32: -50},                                                                                                                                       // colon
+	59:  {32: -50},                                                                                                                                       // semicolon
+	65:  {67: -30, 71: -30, 79: -30, 81: -30, 84: -120, 85: -50, 86: -70, 87: -50, 89: -100, 117: -30, 118: -40, 119: -40, 121: -40},                     // A
+	66:  {44: -20, 46: -20, 85: -10},                                                                                                                     // B
+	67:  {44: -30, 46: -30},                                                                                                                              // C
+	68:  {44: -70, 46: -70, 65: -40, 86: -70, 87: -40, 89: -90},                                                                                          // D
+	70:  {44: -150, 46: -150, 65: -80, 97: -50, 101: -30, 111: -30, 114: -45},                                                                            // F
+	74:  {44: -30, 46: -30, 65: -20, 97: -20, 117: -20},                                                                                                  // J
+	75:  {79: -50, 101: -40, 111: -40, 117: -30, 121: -50},                                                                                               // K
+	76:  {39: -160, 84: -110, 86: -110, 87: -70, 89: -140, 121: -30, 186: -140},                                                                          // L
+	79:  {44: -40, 46: -40, 65: -20, 84: -40, 86: -50, 87: -30, 88: -60, 89: -70},                                                                        // O
+	80:  {44: -180, 46: -180, 65: -120, 97: -40, 101: -50, 111: -50},                                                                                     // P
+	81:  {85: -10},                                                                                                                                       // Q
+	82:  {79: -20, 84: -30, 85: -40, 86: -50, 87: -30, 89: -50},                                                                                          // R
+	83:  {44: -20, 46: -20},                                                                                                                              // S
+	84:  {44: -120, 45: -140, 46: -120, 58: -20, 59: -20, 65: -120, 79: -40, 97: -120, 101: -120, 111: -120, 114: -120, 117: -120, 119: -120, 121: -120}, // T
+	85:  {44: -40, 46: -40, 65: -40},                                                                                                                     // U
+	86:  {44: -125, 45: -80, 46: -125, 58: -40, 59: -40, 65: -80, 71: -40, 79: -40, 97: -70, 101: -80, 111: -80, 117: -70},                               // V
+	87:  {44: -80, 45: -40, 46: -80, 65: -50, 79: -20, 97: -40, 101: -30, 111: -30, 117: -30, 121: -20},                                                  // W
+	89:  {44: -140, 45: -140, 46: -140, 58: -60, 59: -60, 65: -110, 79: -85, 97: -140, 101: -140, 105: -20, 111: -140, 117: -110, 118: -110},             // Y
+	96:  {96: -57},                                                                                                                                       // quoteleft
+	97:  {118: -20, 119: -20, 121: -30},                                                                                                                  // a
+	98:  {44: -40, 46: -40, 98: -10, 108: -20, 117: -20, 118: -20, 121: -20},                                                                             // b
+	99:  {44: -15, 107: -20},                                                                                                                             // c
+	101: {44: -15, 46: -15, 118: -30, 119: -20, 120: -30, 121: -20},                                                                                      // e
+	102: {39: 50, 44: -30, 46: -30, 97: -30, 101: -30, 111: -30, 186: 60, 245: -28},                                                                      // f
+	103: {114: -10},                                                                                                                                      // g
+	104: {121: -30},                                                                                                                                      // h
+	107: {101: -20, 111: -20},                                                                                                                            // k
+	109: {117: -10, 121: -15},                                                                                                                            // m
+	110: {117: -10, 118: -20, 121: -15},                                                                                                                  // n
+	111: {44: -40, 46: -40, 118: -15, 119: -15, 120: -30, 121: -30},                                                                                      // o
+	112: {44: -35, 46: -35, 121: -30},                                                                                                                    // p
+	114: {44: -50, 45: -20, 46: -50, 58: 30, 59: 30, 97: -10},                                                                                            // r
+	115: {44: -15, 46: -15, 119: -30},                                                                                                                    // s
+	118: {44: -80, 46: -80, 97: -25, 101: -25, 111: -25},                                                                                                 // v
+	119: {44: -60, 46: -60, 97: -15, 101: -10, 111: -10},                                                                                                 // w
+	120: {101: -30},                                                                                                                                      // x
+	121: {44: -100, 46: -100, 97: -20, 101: -20, 111: -20},                                                                                               // y
+	122: {101: -15, 111: -15},                                                                                                                            // z
+	186: {32: -40},                                                                                                                                       // quotedblright
+}
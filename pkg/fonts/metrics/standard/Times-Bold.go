@@ -0,0 +1,229 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package standard
+
+// StartFontMetrics 4.1
+// FontName Times-Bold
+// FullName Times Bold
+// FamilyName Times
+// Weight Bold
+// ItalicAngle 0
+// IsFixedPitch false
+// CharacterSet ExtendedRoman
+// FontBBox -168 -218 1000 935
+// EncodingScheme AdobeStandardEncoding
+// CapHeight 676
+// XHeight 461
+// Ascender 683
+// Descender -217
+// StdHW 44
+// StdVW 139
+
+// FontWidthTimesBold represents the char widths for this font.
+var FontWidthTimesBold = map[int]int{
+	32:  250,
+	33:  333,
+	34:  555,
+	35:  500,
+	36:  500,
+	37:  1000,
+	38:  833,
+	39:  333,
+	40:  333,
+	41:  333,
+	42:  500,
+	43:  570,
+	44:  250,
+	45:  333,
+	46:  250,
+	47:  278,
+	48:  500,
+	49:  500,
+	50:  500,
+	51:  500,
+	52:  500,
+	53:  500,
+	54:  500,
+	55:  500,
+	56:  500,
+	57:  500,
+	58:  333,
+	59:  333,
+	60:  570,
+	61:  570,
+	62:  570,
+	63:  500,
+	64:  930,
+	65:  722,
+	66:  667,
+	67:  722,
+	68:  722,
+	69:  667,
+	70:  611,
+	71:  778,
+	72:  778,
+	73:  389,
+	74:  500,
+	75:  778,
+	76:  667,
+	77:  944,
+	78:  722,
+	79:  778,
+	80:  611,
+	81:  778,
+	82:  722,
+	83:  556,
+	84:  667,
+	85:  722,
+	86:  722,
+	87:  1000,
+	88:  722,
+	89:  722,
+	90:  667,
+	91:  333,
+	92:  278,
+	93:  333,
+	94:  581,
+	95:  500,
+	96:  333,
+	97:  500,
+	98:  556,
+	99:  444,
+	100: 556,
+	101: 444,
+	102: 333,
+	103: 500,
+	104: 556,
+	105: 278,
+	106: 333,
+	107: 556,
+	108: 278,
+	109: 833,
+	110: 556,
+	111: 500,
+	112: 556,
+	113: 556,
+	114: 444,
+	115: 389,
+	116: 333,
+	117: 556,
+	118: 500,
+	119: 722,
+	120: 500,
+	121: 500,
+	122: 444,
+	123: 394,
+	124: 220,
+	125: 394,
+	126: 520,
+	161: 333,
+	162: 500,
+	163: 500,
+	164: 167,
+	165: 500,
+	166: 500,
+	167: 500,
+	168: 500,
+	169: 278,
+	170: 500,
+	171: 500,
+	172: 333,
+	173: 333,
+	174: 556,
+	175: 556,
+	177: 500,
+	178: 500,
+	179: 500,
+	180: 250,
+	182: 540,
+	183: 350,
+	184: 333,
+	185: 500,
+	186: 500,
+	187: 500,
+	188: 1000,
+	189: 1000,
+	191: 500,
+	193: 333,
+	194: 333,
+	195: 333,
+	196: 333,
+	197: 333,
+	198: 333,
+	199: 333,
+	200: 333,
+	202: 333,
+	203: 333,
+	205: 333,
+	206: 333,
+	207: 333,
+	208: 1000,
+	225: 1000,
+	227: 300,
+	232: 667,
+	233: 778,
+	234: 1000,
+	235: 330,
+	241: 722,
+	245: 278,
+	248: 278,
+	249: 500,
+	250: 722,
+	251: 556,
+}
+
+// KerningTimesBold represents the kerning pairs for this font.
+var KerningTimesBold = map[int]map[int]int{
+	32:  {65: -55, 84: -30, 86: -45, 87: -30, 89: -55},                                                                                                    // space
+	39:  {32: -74, 39: -63, 100: -20, 114: -20, 115: -37, 118: -20},                                                                                       // quoteright
+	44:  {39: -55, 186: -45},                                                                                                                              // comma
+	46:  {39: -55, 186: -55},                                                                                                                              // period
+	65:  {39: -74, 67: -55, 71: -55, 79: -45, 81: -45, 84: -95, 85: -50, 86: -145, 87: -130, 89: -100, 112: -25, 117: -50, 118: -100, 119: -90, 121: -74}, // A
+	66:  {65: -30, 85: -10},                                                                                                                               // B
+	68:  {46: -20, 65: -35, 86: -40, 87: -40, 89: -40},                                                                                                    // D
+	70:  {44: -92, 46: -110, 65: -90, 97: -25, 101: -25, 111: -25},                                                                                        // F
+	74:  {46: -20, 65: -30, 97: -15, 101: -15, 111: -15, 117: -15},                                                                                        // J
+	75:  {79: -30, 101: -25, 111: -25, 117: -15, 121: -45},                                                                                                // K
+	76:  {39: -110, 84: -92, 86: -92, 87: -92, 89: -92, 121: -55, 186: -20},                                                                               // L
+	78:  {65: -20},                                                                                                                                        // N
+	79:  {65: -40, 84: -40, 86: -50, 87: -50, 88: -40, 89: -50},                                                                                           // O
+	80:  {44: -92, 46: -110, 65: -74, 97: -10, 101: -20, 111: -20},                                                                                        // P
+	81:  {46: -20, 85: -10},                                                                                                                               // Q
+	82:  {79: -30, 84: -40, 85: -30, 86: -55, 87: -35, 89: -35},                                                                                           // R
+	84:  {44: -74, 45: -92, 46: -90, 58: -74, 59: -74, 65: -90, 79: -18, 97: -92, 101: -92, 105: -18, 111: -92, 114: -74, 117: -92, 119: -74, 121: -34},   // T
+	85:  {44: -50, 46: -50, 65: -60},                                                                                                                      // U
+	86:  {44: -129, 45: -74, 46: -145, 58: -92, 59: -92, 65: -135, 71: -30, 79: -45, 97: -92, 101: -100, 105: -37, 111: -100, 117: -92},                   // V
+	87:  {44: -92, 45: -37, 46: -92, 58: -55, 59: -55, 65: -120, 79: -10, 97: -65, 101: -65, 105: -18, 111: -75, 117: -50, 121: -60},                      // W
+	89:  {44: -92, 45: -92, 46: -92, 58: -92, 59: -92, 65: -110, 79: -35, 97: -85, 101: -111, 105: -37, 111: -111, 117: -92},                              // Y
+	96:  {65: -10, 96: -63},                                                                                                                               // quoteleft
+	97:  {118: -25},                                                                                                                                       // a
+	98:  {46: -40, 98: -10, 117: -20, 118: -15},                                                                                                           // b
+	100: {119: -15},                                                                                                                                       // d
+	101: {118: -15},                                                                                                                                       // e
+	102: {39: 55, 44: -15, 46: -15, 105: -25, 111: -25, 186: 50, 245: -35},                                                                                // f
+	103: {46: -15},                                                                                                                                        // g
+	104: {121: -15},                                                                                                                                       // h
+	105: {118: -10},                                                                                                                                       // i
+	107: {101: -10, 111: -15, 121: -15},                                                                                                                   // k
+	110: {118: -40},                                                                                                                                       // n
+	111: {118: -10, 119: -10},                                                                                                                             // o
+	114: {44: -92, 45: -37, 46: -100, 99: -18, 101: -18, 103: -10, 110: -15, 111: -18, 112: -10, 113: -18, 118: -10},                                      // r
+	118: {44: -55, 46: -70, 97: -10, 101: -10, 111: -10},                                                                                                  // v
+	119: {44: -55, 46: -70, 111: -10},                                                                                                                     // w
+	121: {44: -55, 46: -70, 101: -10, 111: -25},                                                                                                           // y
+	170: {65: -10},                                                                                                                                        // quotedblleft
+}
@@ -0,0 +1,225 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package standard
+
+// StartFontMetrics 4.1
+// FontName Times-BoldItalic
+// FullName Times Bold Italic
+// FamilyName Times
+// Weight Bold
+// ItalicAngle -15
+// IsFixedPitch false
+// CharacterSet ExtendedRoman
+// FontBBox -200 -218 996 921
+// EncodingScheme AdobeStandardEncoding
+// CapHeight 669
+// XHeight 462
+// Ascender 683
+// Descender -217
+// StdHW 42
+// StdVW 121
+
+// FontWidthTimesBoldItalic represents the char widths for this font.
+var FontWidthTimesBoldItalic = map[int]int{
+	32:  250,
+	33:  389,
+	34:  555,
+	35:  500,
+	36:  500,
+	37:  833,
+	38:  778,
+	39:  333,
+	40:  333,
+	41:  333,
+	42:  500,
+	43:  570,
+	44:  250,
+	45:  333,
+	46:  250,
+	47:  278,
+	48:  500,
+	49:  500,
+	50:  500,
+	51:  500,
+	52:  500,
+	53:  500,
+	54:  500,
+	55:  500,
+	56:  500,
+	57:  500,
+	58:  333,
+	59:  333,
+	60:  570,
+	61:  570,
+	62:  570,
+	63:  500,
+	64:  832,
+	65:  667,
+	66:  667,
+	67:  667,
+	68:  722,
+	69:  667,
+	70:  667,
+	71:  722,
+	72:  778,
+	73:  389,
+	74:  500,
+	75:  667,
+	76:  611,
+	77:  889,
+	78:  722,
+	79:  722,
+	80:  611,
+	81:  722,
+	82:  667,
+	83:  556,
+	84:  611,
+	85:  722,
+	86:  667,
+	87:  889,
+	88:  667,
+	89:  611,
+	90:  611,
+	91:  333,
+	92:  278,
+	93:  333,
+	94:  570,
+	95:  500,
+	96:  333,
+	97:  500,
+	98:  500,
+	99:  444,
+	100: 500,
+	101: 444,
+	102: 333,
+	103: 500,
+	104: 556,
+	105: 278,
+	106: 278,
+	107: 500,
+	108: 278,
+	109: 778,
+	110: 556,
+	111: 500,
+	112: 500,
+	113: 500,
+	114: 389,
+	115: 389,
+	116: 278,
+	117: 556,
+	118: 444,
+	119: 667,
+	120: 500,
+	121: 444,
+	122: 389,
+	123: 348,
+	124: 220,
+	125: 348,
+	126: 570,
+	161: 389,
+	162: 500,
+	163: 500,
+	164: 167,
+	165: 500,
+	166: 500,
+	167: 500,
+	168: 500,
+	169: 278,
+	170: 500,
+	171: 500,
+	172: 333,
+	173: 333,
+	174: 556,
+	175: 556,
+	177: 500,
+	178: 500,
+	179: 500,
+	180: 250,
+	182: 500,
+	183: 350,
+	184: 333,
+	185: 500,
+	186: 500,
+	187: 500,
+	188: 1000,
+	189: 1000,
+	191: 500,
+	193: 333,
+	194: 333,
+	195: 333,
+	196: 333,
+	197: 333,
+	198: 333,
+	199: 333,
+	200: 333,
+	202: 333,
+	203: 333,
+	205: 333,
+	206: 333,
+	207: 333,
+	208: 1000,
+	225: 944,
+	227: 266,
+	232: 611,
+	233: 722,
+	234: 944,
+	235: 300,
+	241: 722,
+	245: 278,
+	248: 278,
+	249: 500,
+	250: 722,
+	251: 500,
+}
+
+// KerningTimesBoldItalic represents the kerning pairs for this font.
+var KerningTimesBoldItalic = map[int]map[int]int{
+	32:  {65: -37, 86: -70, 87: -70, 89: -70},                                                                                                           // space
+	39:  {32: -74, 39: -74, 100: -15, 114: -15, 115: -74, 116: -37, 118: -15},                                                                           // quoteright
+	44:  {39: -95, 186: -95},                                                                                                                            // comma
+	46:  {39: -95, 186: -95},                                                                                                                            // period
+	65:  {39: -74, 67: -65, 71: -60, 79: -50, 81: -55, 84: -55, 85: -50, 86: -95, 87: -100, 89: -70, 117: -30, 118: -74, 119: -74, 121: -74},            // A
+	66:  {65: -25, 85: -10},                                                                                                                             // B
+	68:  {65: -25, 86: -50, 87: -40, 89: -50},                                                                                                           // D
+	70:  {44: -129, 46: -129, 65: -100, 97: -95, 101: -100, 105: -40, 111: -70, 114: -50},                                                               // F
+	74:  {44: -10, 46: -10, 65: -25, 97: -40, 101: -40, 111: -40, 117: -40},                                                                             // J
+	75:  {79: -30, 101: -25, 111: -25, 117: -20, 121: -20},                                                                                              // K
+	76:  {39: -55, 84: -18, 86: -37, 87: -37, 89: -37, 121: -37},                                                                                        // L
+	78:  {65: -30},                                                                                                                                      // N
+	79:  {65: -40, 84: -40, 86: -50, 87: -50, 88: -40, 89: -50},                                                                                         // O
+	80:  {44: -129, 46: -129, 65: -85, 97: -40, 101: -50, 111: -55},                                                                                     // P
+	81:  {85: -10},                                                                                                                                      // Q
+	82:  {79: -40, 84: -30, 85: -40, 86: -18, 87: -18, 89: -18},                                                                                         // R
+	84:  {44: -92, 45: -92, 46: -92, 58: -74, 59: -74, 65: -55, 79: -18, 97: -92, 101: -92, 105: -37, 111: -95, 114: -37, 117: -37, 119: -37, 121: -37}, // T
+	85:  {65: -45},                                                                                                                                      // U
+	86:  {44: -129, 45: -70, 46: -129, 58: -74, 59: -74, 65: -70, 71: -10, 79: -30, 97: -111, 101: -111, 105: -55, 111: -111, 117: -55},                 // V
+	87:  {44: -74, 45: -50, 46: -74, 58: -55, 59: -55, 65: -70, 79: -15, 97: -85, 101: -90, 105: -37, 111: -80, 117: -55, 121: -55},                     // W
+	89:  {44: -92, 45: -92, 46: -74, 58: -92, 59: -92, 65: -70, 79: -25, 97: -111, 101: -111, 105: -55, 111: -111, 117: -92},                            // Y
+	96:  {96: -74},                                                                                                                                      // quoteleft
+	98:  {46: -40, 98: -10, 117: -20},                                                                                                                   // b
+	99:  {104: -10, 107: -10},                                                                                                                           // c
+	101: {98: -10},                                                                                                                                      // e
+	102: {39: 55, 44: -10, 46: -10, 101: -10, 102: -18, 111: -10, 245: -30},                                                                             // f
+	107: {101: -30, 111: -10},                                                                                                                           // k
+	110: {118: -40},                                                                                                                                     // n
+	111: {118: -15, 119: -25, 120: -10, 121: -10},                                                                                                       // o
+	114: {44: -65, 46: -65},                                                                                                                             // r
+	118: {44: -37, 46: -37, 101: -15, 111: -15},                                                                                                         // v
+	119: {44: -37, 46: -37, 97: -10, 101: -10, 111: -15},                                                                                                // w
+	120: {101: -10},                                                                                                                                     // x
+	121: {44: -37, 46: -37},                                                                                                                             // y
+}
@@ -0,0 +1,226 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package standard
+
+// StartFontMetrics 4.1
+// FontName Times-Italic
+// FullName Times Italic
+// FamilyName Times
+// Weight Medium
+// ItalicAngle -15.5
+// IsFixedPitch false
+// CharacterSet ExtendedRoman
+// FontBBox -169 -217 1010 883
+// EncodingScheme AdobeStandardEncoding
+// CapHeight 653
+// XHeight 441
+// Ascender 683
+// Descender -217
+// StdHW 32
+// StdVW 76
+
+// FontWidthTimesItalic represents the char widths for this font.
+var FontWidthTimesItalic = map[int]int{
+	32:  250,
+	33:  333,
+	34:  420,
+	35:  500,
+	36:  500,
+	37:  833,
+	38:  778,
+	39:  333,
+	40:  333,
+	41:  333,
+	42:  500,
+	43:  675,
+	44:  250,
+	45:  333,
+	46:  250,
+	47:  278,
+	48:  500,
+	49:  500,
+	50:  500,
+	51:  500,
+	52:  500,
+	53:  500,
+	54:  500,
+	55:  500,
+	56:  500,
+	57:  500,
+	58:  333,
+	59:  333,
+	60:  675,
+	61:  675,
+	62:  675,
+	63:  500,
+	64:  920,
+	65:  611,
+	66:  611,
+	67:  667,
+	68:  722,
+	69:  611,
+	70:  611,
+	71:  722,
+	72:  722,
+	73:  333,
+	74:  444,
+	75:  667,
+	76:  556,
+	77:  833,
+	78:  667,
+	79:  722,
+	80:  611,
+	81:  722,
+	82:  611,
+	83:  500,
+	84:  556,
+	85:  722,
+	86:  611,
+	87:  833,
+	88:  611,
+	89:  556,
+	90:  556,
+	91:  389,
+	92:  278,
+	93:  389,
+	94:  422,
+	95:  500,
+	96:  333,
+	97:  500,
+	98:  500,
+	99:  444,
+	100: 500,
+	101: 444,
+	102: 278,
+	103: 500,
+	104: 500,
+	105: 278,
+	106: 278,
+	107: 444,
+	108: 278,
+	109: 722,
+	110: 500,
+	111: 500,
+	112: 500,
+	113: 500,
+	114: 389,
+	115: 389,
+	116: 278,
+	117: 500,
+	118: 444,
+	119: 667,
+	120: 444,
+	121: 444,
+	122: 389,
+	123: 400,
+	124: 275,
+	125: 400,
+	126: 541,
+	161: 389,
+	162: 500,
+	163: 500,
+	164: 167,
+	165: 500,
+	166: 500,
+	167: 500,
+	168: 500,
+	169: 214,
+	170: 556,
+	171: 500,
+	172: 333,
+	173: 333,
+	174: 500,
+	175: 500,
+	177: 500,
+	178: 500,
+	179: 500,
+	180: 250,
+	182: 523,
+	183: 350,
+	184: 333,
+	185: 556,
+	186: 556,
+	187: 500,
+	188: 889,
+	189: 1000,
+	191: 500,
+	193: 333,
+	194: 333,
+	195: 333,
+	196: 333,
+	197: 333,
+	198: 333,
+	199: 333,
+	200: 333,
+	202: 333,
+	203: 333,
+	205: 333,
+	206: 333,
+	207: 333,
+	208: 889,
+	225: 889,
+	227: 276,
+	232: 556,
+	233: 722,
+	234: 944,
+	235: 310,
+	241: 667,
+	245: 278,
+	248: 278,
+	249: 500,
+	250: 667,
+	251: 500,
+}
+
+// KerningTimesItalic represents the kerning pairs for this font.
+var KerningTimesItalic = map[int]map[int]int{
+	32:  {65: -18, 84: -18, 86: -35, 87: -40, 89: -75},                                                                                                  // space
+	39:  {32: -111, 39: -111, 100: -25, 114: -25, 115: -40, 116: -30, 118: -10},                                                                         // quoteright
+	44:  {39: -140, 186: -140},                                                                                                                          // comma
+	46:  {39: -140, 186: -140},                                                                                                                          // period
+	65:  {39: -37, 67: -30, 71: -35, 79: -40, 81: -40, 84: -37, 85: -50, 86: -105, 87: -95, 89: -55, 117: -20, 118: -55, 119: -55, 121: -55},            // A
+	66:  {65: -25, 85: -10},                                                                                                                             // B
+	68:  {65: -35, 86: -40, 87: -40, 89: -40},                                                                                                           // D
+	70:  {44: -135, 46: -135, 65: -115, 97: -75, 101: -75, 105: -45, 111: -105, 114: -55},                                                               // F
+	74:  {44: -25, 46: -25, 65: -40, 97: -35, 101: -25, 111: -25, 117: -35},                                                                             // J
+	75:  {79: -50, 101: -35, 111: -40, 117: -40, 121: -40},                                                                                              // K
+	76:  {39: -37, 84: -20, 86: -55, 87: -55, 89: -20, 121: -30},                                                                                        // L
+	78:  {65: -27},                                                                                                                                      // N
+	79:  {65: -55, 84: -40, 86: -50, 87: -50, 88: -40, 89: -50},                                                                                         // O
+	80:  {44: -135, 46: -135, 65: -90, 97: -80, 101: -80, 111: -80},                                                                                     // P
+	81:  {85: -10},                                                                                                                                      // Q
+	82:  {79: -40, 85: -40, 86: -18, 87: -18, 89: -18},                                                                                                  // R
+	84:  {44: -74, 45: -74, 46: -74, 58: -55, 59: -65, 65: -50, 79: -18, 97: -92, 101: -92, 105: -55, 111: -92, 114: -55, 117: -55, 119: -74, 121: -74}, // T
+	85:  {44: -25, 46: -25, 65: -40},                                                                                                                    // U
+	86:  {44: -129, 45: -55, 46: -129, 58: -65, 59: -74, 65: -60, 79: -30, 97: -111, 101: -111, 105: -74, 111: -111, 117: -74},                          // V
+	87:  {44: -92, 45: -37, 46: -92, 58: -65, 59: -65, 65: -60, 79: -25, 97: -92, 101: -92, 105: -55, 111: -92, 117: -55, 121: -70},                     // W
+	89:  {44: -92, 45: -74, 46: -92, 58: -65, 59: -65, 65: -50, 79: -15, 97: -92, 101: -92, 105: -74, 111: -92, 117: -92},                               // Y
+	96:  {96: -111},                                                                                                                                     // quoteleft
+	97:  {103: -10},                                                                                                                                     // a
+	98:  {46: -40, 117: -20},                                                                                                                            // b
+	99:  {104: -15, 107: -20},                                                                                                                           // c
+	101: {44: -10, 46: -15, 103: -40, 118: -15, 119: -15, 120: -20, 121: -30},                                                                           // e
+	102: {39: 92, 44: -10, 46: -15, 102: -18, 105: -20, 245: -60},                                                                                       // f
+	103: {44: -10, 46: -15, 101: -10, 103: -10},                                                                                                         // g
+	107: {101: -10, 111: -10, 121: -10},                                                                                                                 // k
+	110: {118: -40},                                                                                                                                     // n
+	111: {118: -10},                                                                                                                                     // o
+	114: {44: -111, 45: -20, 46: -111, 97: -15, 99: -37, 100: -37, 101: -37, 103: -37, 111: -45, 113: -37, 115: -10},                                    // r
+	118: {44: -74, 46: -74},                                                                                                                             // v
+	119: {44: -74, 46: -74},                                                                                                                             // w
+	121: {44: -55, 46: -55},                                                                                                                             // y
+}
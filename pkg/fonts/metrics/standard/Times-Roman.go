@@ -193,3 +193,48 @@ var FontWidthTimesRoman = map[int]int{
 	250: 722,
 	251: 500,
 }
+
+// KerningTimesRoman represents the kerning pairs for this font.
+var KerningTimesRoman = map[int]map[int]int{
+	32:  {65: -55, 84: -18, 86: -50, 87: -30, 89: -90},                                                                                                  // space
+	39:  {32: -74, 39: -74, 100: -50, 108: -10, 114: -50, 115: -55, 116: -18, 118: -50},                                                                 // quoteright
+	44:  {39: -70, 186: -70},                                                                                                                            // comma
+	46:  {39: -70, 186: -70},                                                                                                                            // period
+	65:  {39: -111, 67: -40, 71: -40, 79: -55, 81: -55, 84: -111, 85: -55, 86: -135, 87: -90, 89: -105, 118: -74, 119: -92, 121: -92},                   // A
+	66:  {65: -35, 85: -10},                                                                                                                             // B
+	68:  {65: -40, 86: -40, 87: -30, 89: -55},                                                                                                           // D
+	70:  {44: -80, 46: -80, 65: -74, 97: -15, 111: -15},                                                                                                 // F
+	74:  {65: -60},                                                                                                                                      // J
+	75:  {79: -30, 101: -25, 111: -35, 117: -15, 121: -25},                                                                                              // K
+	76:  {39: -92, 84: -92, 86: -100, 87: -74, 89: -100, 121: -55},                                                                                      // L
+	78:  {65: -35},                                                                                                                                      // N
+	79:  {65: -35, 84: -40, 86: -50, 87: -35, 88: -40, 89: -50},                                                                                         // O
+	80:  {44: -111, 46: -111, 65: -92, 97: -15},                                                                                                         // P
+	81:  {85: -10},                                                                                                                                      // Q
+	82:  {79: -40, 84: -60, 85: -40, 86: -80, 87: -55, 89: -65},                                                                                         // R
+	84:  {44: -74, 45: -92, 46: -74, 58: -50, 59: -55, 65: -93, 79: -18, 97: -80, 101: -70, 105: -35, 111: -80, 114: -35, 117: -45, 119: -80, 121: -80}, // T
+	85:  {65: -40},                                                                                                                                      // U
+	86:  {44: -129, 45: -100, 46: -129, 58: -74, 59: -74, 65: -135, 71: -15, 79: -40, 97: -111, 101: -111, 105: -60, 111: -129, 117: -75},               // V
+	87:  {44: -92, 45: -65, 46: -92, 58: -37, 59: -37, 65: -120, 79: -10, 97: -80, 101: -80, 105: -40, 111: -80, 117: -50, 121: -73},                    // W
+	89:  {44: -129, 45: -111, 46: -129, 58: -92, 59: -92, 65: -120, 79: -30, 97: -100, 101: -100, 105: -55, 111: -110, 117: -111},                       // Y
+	96:  {65: -80, 96: -74},                                                                                                                             // quoteleft
+	97:  {118: -20, 119: -15},                                                                                                                           // a
+	98:  {46: -40, 117: -20, 118: -15},                                                                                                                  // b
+	99:  {121: -15},                                                                                                                                     // c
+	101: {103: -15, 118: -25, 119: -25, 120: -15, 121: -15},                                                                                             // e
+	102: {39: 55, 97: -10, 102: -25, 105: -20, 245: -50},                                                                                                // f
+	103: {97: -5},                                                                                                                                       // g
+	104: {121: -5},                                                                                                                                      // h
+	105: {118: -25},                                                                                                                                     // i
+	107: {101: -10, 111: -10, 121: -15},                                                                                                                 // k
+	108: {119: -10},                                                                                                                                     // l
+	110: {118: -40, 121: -15},                                                                                                                           // n
+	111: {118: -15, 119: -25, 121: -10},                                                                                                                 // o
+	112: {121: -10},                                                                                                                                     // p
+	114: {44: -40, 45: -20, 46: -55, 103: -18},                                                                                                          // r
+	118: {44: -65, 46: -65, 97: -25, 101: -15, 111: -20},                                                                                                // v
+	119: {44: -65, 46: -65, 97: -10, 111: -10},                                                                                                          // w
+	120: {101: -15},                                                                                                                                     // x
+	121: {44: -65, 46: -65},                                                                                                                             // y
+	170: {65: -80},                                                                                                                                      // quotedblleft
+}
@@ -0,0 +1,405 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hhrutter/pdfcpu/pkg/log"
+	"github.com/pkg/errors"
+)
+
+// The number of parameters for the destination types, see 12.3.2.2
+var viewParams = map[string]int{
+	"XYZ": 3, "Fit": 0, "FitH": 1, "FitV": 1, "FitR": 4, "FitB": 0, "FitBH": 1, "FitBV": 1,
+}
+
+// Bookmark represents an outline item along with its children.
+type Bookmark struct {
+	Title  string     `json:"title"`
+	PageNr int        `json:"page,omitempty"`   // 0 for outline items not pointing to a page of this document.
+	View   string     `json:"view,omitempty"`   // the destination type, eg. XYZ, Fit, FitH
+	Params []*float64 `json:"params,omitempty"` // the destination parameters, null leaves the current value unchanged.
+	Color  []float64  `json:"color,omitempty"`  // RGB
+	Bold   bool       `json:"bold,omitempty"`
+	Italic bool       `json:"italic,omitempty"`
+	Open   bool       `json:"open,omitempty"` // Kids are visible.
+	Kids   []Bookmark `json:"kids,omitempty"`
+}
+
+// pageNumbers returns the page numbers by page dict object number.
+func pageNumbers(ctx *PDFContext) (map[int]int, error) {
+
+	m := map[int]int{}
+
+	for i := 1; i <= ctx.PageCount; i++ {
+		indRef, err := ctx.PageDictIndRef(i)
+		if err != nil {
+			return nil, err
+		}
+		m[indRef.ObjectNumber.Value()] = i
+	}
+
+	return m, nil
+}
+
+func bookmark(ctx *PDFContext, d *PDFDict, pageNrs map[int]int) (*Bookmark, error) {
+
+	title, err := textString(ctx, d.Dict["Title"])
+	if err != nil {
+		return nil, err
+	}
+
+	bm := &Bookmark{Title: title}
+
+	dest, _, err := outlineItemDestination(ctx.XRefTable, d)
+	if err != nil {
+		return nil, err
+	}
+
+	if bm.PageNr = pageNrs[destinationPage(dest)]; bm.PageNr > 0 && len(dest) > 1 {
+
+		if n, ok := dest[1].(PDFName); ok {
+			bm.View = n.Value()
+		}
+
+		for _, o := range dest[2:] {
+			var p *float64
+			if o, _ := ctx.Dereference(o); o != nil {
+				f := ctx.DereferenceNumber(o)
+				p = &f
+			}
+			bm.Params = append(bm.Params, p)
+		}
+	}
+
+	if a, _ := ctx.DereferenceArray(d.Dict["C"]); a != nil && len(*a) == 3 {
+		for _, o := range *a {
+			bm.Color = append(bm.Color, ctx.DereferenceNumber(o))
+		}
+	}
+
+	if f := d.IntEntry("F"); f != nil {
+		bm.Italic = *f&1 > 0
+		bm.Bold = *f&2 > 0
+	}
+
+	if c := d.IntEntry("Count"); c != nil && *c > 0 {
+		bm.Open = true
+	}
+
+	return bm, nil
+}
+
+func bookmarks(ctx *PDFContext, first *PDFIndirectRef, pageNrs map[int]int) ([]Bookmark, error) {
+
+	items, err := outlineItems(ctx.XRefTable, first)
+	if err != nil {
+		return nil, err
+	}
+
+	bms := []Bookmark{}
+
+	for _, item := range items {
+
+		bm, err := bookmark(ctx, item.dict, pageNrs)
+		if err != nil {
+			return nil, err
+		}
+
+		if first := item.dict.IndirectRefEntry("First"); first != nil {
+			bm.Kids, err = bookmarks(ctx, first, pageNrs)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		bms = append(bms, *bm)
+	}
+
+	return bms, nil
+}
+
+// Bookmarks returns the outline tree of ctx.
+func Bookmarks(ctx *PDFContext) ([]Bookmark, error) {
+
+	rootDict, err := ctx.Catalog()
+	if err != nil {
+		return nil, err
+	}
+
+	d, err := ctx.DereferenceDict(rootDict.Dict["Outlines"])
+	if err != nil || d == nil {
+		return []Bookmark{}, err
+	}
+
+	pageNrs, err := pageNumbers(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return bookmarks(ctx, d.IndirectRefEntry("First"), pageNrs)
+}
+
+func listBookmarks(bms []Bookmark, indent string, list []string) []string {
+
+	for _, bm := range bms {
+
+		s := indent + bm.Title
+		if bm.PageNr > 0 {
+			s += fmt.Sprintf(" (page %d)", bm.PageNr)
+		}
+
+		list = append(list, s)
+		list = listBookmarks(bm.Kids, indent+"  ", list)
+	}
+
+	return list
+}
+
+// BookmarkList returns a list of the outline items of ctx indented by nesting level.
+func BookmarkList(ctx *PDFContext) ([]string, error) {
+
+	bms, err := Bookmarks(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return listBookmarks(bms, "", nil), nil
+}
+
+// deleteOutlineItems frees the outline items of the linked list starting at first along with their descendants.
+// The objects referenced by outline items are left alone.
+func deleteOutlineItems(xRefTable *XRefTable, first *PDFIndirectRef) error {
+
+	items, err := outlineItems(xRefTable, first)
+	if err != nil {
+		return err
+	}
+
+	for _, item := range items {
+
+		err = deleteOutlineItems(xRefTable, item.dict.IndirectRefEntry("First"))
+		if err != nil {
+			return err
+		}
+
+		err = xRefTable.DeleteObject(item.indRef.ObjectNumber.Value())
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RemoveBookmarks deletes the outline tree of ctx.
+func RemoveBookmarks(ctx *PDFContext) (ok bool, err error) {
+
+	rootDict, err := ctx.Catalog()
+	if err != nil {
+		return false, err
+	}
+
+	indRef := rootDict.IndirectRefEntry("Outlines")
+	if indRef == nil {
+		return false, nil
+	}
+
+	d, err := ctx.DereferenceDict(*indRef)
+	if err != nil {
+		return false, err
+	}
+
+	if d != nil {
+		err = deleteOutlineItems(ctx.XRefTable, d.IndirectRefEntry("First"))
+		if err != nil {
+			return false, err
+		}
+	}
+
+	err = ctx.DeleteObject(indRef.ObjectNumber.Value())
+	if err != nil {
+		return false, err
+	}
+
+	rootDict.Delete("Outlines")
+
+	if pm := rootDict.NameEntry("PageMode"); pm != nil && *pm == "UseOutlines" {
+		rootDict.Delete("PageMode")
+	}
+
+	return true, nil
+}
+
+func (bm Bookmark) destination(ctx *PDFContext) (PDFArray, error) {
+
+	indRef, err := ctx.PageDictIndRef(bm.PageNr)
+	if err != nil {
+		return nil, err
+	}
+
+	view := bm.View
+	if view == "" {
+		view = "Fit"
+	}
+
+	n, ok := viewParams[view]
+	if !ok {
+		return nil, errors.Errorf("bookmark %q: unknown view %s", bm.Title, view)
+	}
+
+	if len(bm.Params) != n {
+		return nil, errors.Errorf("bookmark %q: view %s needs %d parameters", bm.Title, view, n)
+	}
+
+	dest := PDFArray{*indRef, PDFName(view)}
+
+	for _, p := range bm.Params {
+		if p == nil {
+			dest = append(dest, nil)
+			continue
+		}
+		dest = append(dest, PDFFloat(*p))
+	}
+
+	return dest, nil
+}
+
+func (bm Bookmark) outlineItemDict(ctx *PDFContext) (*PDFDict, error) {
+
+	title, err := NewTextString(bm.Title)
+	if err != nil {
+		return nil, err
+	}
+
+	d := NewPDFDict()
+	d.Insert("Title", title)
+
+	if bm.PageNr > 0 {
+		dest, err := bm.destination(ctx)
+		if err != nil {
+			return nil, err
+		}
+		d.Insert("Dest", dest)
+	}
+
+	if bm.Color != nil {
+		if len(bm.Color) != 3 {
+			return nil, errors.Errorf("bookmark %q: color needs 3 RGB components", bm.Title)
+		}
+		a := PDFArray{}
+		for _, c := range bm.Color {
+			if c < 0 || c > 1 {
+				return nil, errors.Errorf("bookmark %q: color components range from 0 to 1", bm.Title)
+			}
+			a = append(a, PDFFloat(c))
+		}
+		d.Insert("C", a)
+	}
+
+	f := 0
+	if bm.Italic {
+		f |= 1
+	}
+	if bm.Bold {
+		f |= 2
+	}
+	if f > 0 {
+		d.InsertInt("F", f)
+	}
+
+	// linkOutlineItems takes care of the magnitude.
+	if bm.Open {
+		d.InsertInt("Count", 1)
+	} else {
+		d.InsertInt("Count", -1)
+	}
+
+	return &d, nil
+}
+
+func addBookmarks(ctx *PDFContext, bms []Bookmark, parent *PDFDict, parentIndRef PDFIndirectRef) error {
+
+	var items []outlineItem
+
+	for _, bm := range bms {
+
+		if strings.TrimSpace(bm.Title) == "" {
+			return errors.New("bookmark: missing title")
+		}
+
+		d, err := bm.outlineItemDict(ctx)
+		if err != nil {
+			return err
+		}
+
+		indRef, err := ctx.IndRefForNewObject(*d)
+		if err != nil {
+			return err
+		}
+
+		err = addBookmarks(ctx, bm.Kids, d, *indRef)
+		if err != nil {
+			return err
+		}
+
+		items = append(items, outlineItem{indRef: *indRef, dict: d})
+	}
+
+	linkOutlineItems(parent, parentIndRef, items)
+
+	return nil
+}
+
+// AddBookmarks replaces the outline tree of ctx by bms.
+func AddBookmarks(ctx *PDFContext, bms []Bookmark) error {
+
+	log.Debug.Printf("AddBookmarks: %d top level items\n", len(bms))
+
+	_, err := RemoveBookmarks(ctx)
+	if err != nil {
+		return err
+	}
+
+	if len(bms) == 0 {
+		return nil
+	}
+
+	d := NewPDFDict()
+	d.InsertName("Type", "Outlines")
+
+	indRef, err := ctx.IndRefForNewObject(d)
+	if err != nil {
+		return err
+	}
+
+	err = addBookmarks(ctx, bms, &d, *indRef)
+	if err != nil {
+		return err
+	}
+
+	rootDict, err := ctx.Catalog()
+	if err != nil {
+		return err
+	}
+
+	rootDict.Insert("Outlines", *indRef)
+
+	return nil
+}
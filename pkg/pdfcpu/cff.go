@@ -0,0 +1,910 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"encoding/binary"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// See Adobe Technical Note #5176 The Compact Font Format Specification
+// and Adobe Technical Note #5177 The Type 2 Charstring Format.
+
+// CFF DICT operators referring to offsets, escaped operators are 12<<8 | op.
+const (
+	cffCharset        = 15
+	cffEncoding       = 16
+	cffCharStrings    = 17
+	cffPrivate        = 18
+	cffSubrs          = 19
+	cffCharstringType = 12<<8 | 6
+	cffROS            = 12<<8 | 30
+	cffFDArray        = 12<<8 | 36
+	cffFDSelect       = 12<<8 | 37
+)
+
+// The CFF standard strings, see Appendix A.
+var cffStandardStrings = strings.Fields(`.notdef space exclam quotedbl numbersign dollar percent ampersand quoteright
+parenleft parenright asterisk plus comma hyphen period slash zero one two three four five six seven eight nine
+colon semicolon less equal greater question at A B C D E F G H I J K L M N O P Q R S T U V W X Y Z
+bracketleft backslash bracketright asciicircum underscore quoteleft a b c d e f g h i j k l m n o p q r s t u v w x y z
+braceleft bar braceright asciitilde exclamdown cent sterling fraction yen florin section currency quotesingle
+quotedblleft guillemotleft guilsinglleft guilsinglright fi fl endash dagger daggerdbl periodcentered paragraph
+bullet quotesinglbase quotedblbase quotedblright guillemotright ellipsis perthousand questiondown grave acute
+circumflex tilde macron breve dotaccent dieresis ring cedilla hungarumlaut ogonek caron emdash AE ordfeminine
+Lslash Oslash OE ordmasculine ae dotlessi lslash oslash oe germandbls onesuperior logicalnot mu trademark Eth
+onehalf plusminus Thorn onequarter divide brokenbar degree thorn threequarters twosuperior registered minus eth
+multiply threesuperior copyright Aacute Acircumflex Adieresis Agrave Aring Atilde Ccedilla Eacute Ecircumflex
+Edieresis Egrave Iacute Icircumflex Idieresis Igrave Ntilde Oacute Ocircumflex Odieresis Ograve Otilde Scaron
+Uacute Ucircumflex Udieresis Ugrave Yacute Ydieresis Zcaron aacute acircumflex adieresis agrave aring atilde
+ccedilla eacute ecircumflex edieresis egrave iacute icircumflex idieresis igrave ntilde oacute ocircumflex
+odieresis ograve otilde scaron uacute ucircumflex udieresis ugrave yacute ydieresis zcaron exclamsmall
+Hungarumlautsmall dollaroldstyle dollarsuperior ampersandsmall Acutesmall parenleftsuperior parenrightsuperior
+twodotenleader onedotenleader zerooldstyle oneoldstyle twooldstyle threeoldstyle fouroldstyle fiveoldstyle
+sixoldstyle sevenoldstyle eightoldstyle nineoldstyle commasuperior threequartersemdash periodsuperior
+questionsmall asuperior bsuperior centsuperior dsuperior esuperior isuperior lsuperior msuperior nsuperior
+osuperior rsuperior ssuperior tsuperior ff ffi ffl parenleftinferior parenrightinferior Circumflexsmall
+hyphensuperior Gravesmall Asmall Bsmall Csmall Dsmall Esmall Fsmall Gsmall Hsmall Ismall Jsmall Ksmall Lsmall
+Msmall Nsmall Osmall Psmall Qsmall Rsmall Ssmall Tsmall Usmall Vsmall Wsmall Xsmall Ysmall Zsmall colonmonetary
+onefitted rupiah Tildesmall exclamdownsmall centoldstyle Lslashsmall Scaronsmall Zcaronsmall Dieresissmall
+Brevesmall Caronsmall Dotaccentsmall Macronsmall figuredash hypheninferior Ogoneksmall Ringsmall Cedillasmall
+questiondownsmall oneeighth threeeighths fiveeighths seveneighths onethird twothirds zerosuperior foursuperior
+fivesuperior sixsuperior sevensuperior eightsuperior ninesuperior zeroinferior oneinferior twoinferior
+threeinferior fourinferior fiveinferior sixinferior seveninferior eightinferior nineinferior centinferior
+dollarinferior periodinferior commainferior Agravesmall Aacutesmall Acircumflexsmall Atildesmall Adieresissmall
+Aringsmall AEsmall Ccedillasmall Egravesmall Eacutesmall Ecircumflexsmall Edieresissmall Igravesmall Iacutesmall
+Icircumflexsmall Idieresissmall Ethsmall Ntildesmall Ogravesmall Oacutesmall Ocircumflexsmall Otildesmall
+Odieresissmall OEsmall Oslashsmall Ugravesmall Uacutesmall Ucircumflexsmall Udieresissmall Yacutesmall Thornsmall
+Ydieresissmall 001.000 001.001 001.002 001.003 Black Bold Book Light Medium Regular Roman Semibold`)
+
+// cffDictEntry represents an operator of a CFF DICT and its operands.
+type cffDictEntry struct {
+	op       int
+	operands [][]byte // the encoded operands.
+	values   []int    // the values of integer operands, 0 for reals.
+}
+
+// cffPrivateDict represents a Private DICT and its local subroutines.
+type cffPrivateDict struct {
+	dict  []cffDictEntry
+	subrs [][]byte
+	raw   []byte // the encoded Subrs INDEX.
+}
+
+// cffFont represents the parts of a CFF font program needed for subsetting.
+type cffFont struct {
+	header      []byte
+	names       []byte // the encoded Name INDEX.
+	strings     [][]byte
+	rawStrings  []byte // the encoded String INDEX.
+	gsubrs      [][]byte
+	rawGsubrs   []byte // the encoded Global Subr INDEX.
+	top         []cffDictEntry
+	charStrings [][]byte
+	charset     []byte // custom charset data.
+	sids        []int  // the SIDs or CIDs of all glyphs.
+	encoding    []byte // custom encoding data.
+	codes       map[int]int
+	fdSelect    []byte
+	fds         [][]cffDictEntry
+	privates    []*cffPrivateDict // one per FD for CID-keyed fonts.
+	cid         bool
+}
+
+func cffIndex(b []byte, off int) ([][]byte, int, error) {
+
+	if off+2 > len(b) {
+		return nil, 0, errors.New("cffIndex: corrupt INDEX")
+	}
+
+	count := int(binary.BigEndian.Uint16(b[off:]))
+	if count == 0 {
+		return nil, off + 2, nil
+	}
+
+	if off+3 > len(b) {
+		return nil, 0, errors.New("cffIndex: corrupt INDEX")
+	}
+
+	offSize := int(b[off+2])
+	if offSize < 1 || offSize > 4 || off+3+(count+1)*offSize > len(b) {
+		return nil, 0, errors.New("cffIndex: corrupt INDEX")
+	}
+
+	offset := func(i int) int {
+		v := 0
+		for _, c := range b[off+3+i*offSize : off+3+(i+1)*offSize] {
+			v = v<<8 | int(c)
+		}
+		return v
+	}
+
+	base := off + 3 + (count+1)*offSize - 1
+
+	items := make([][]byte, count)
+
+	for i := range items {
+		from, to := base+offset(i), base+offset(i+1)
+		if from < base+1 || from > to || to > len(b) {
+			return nil, 0, errors.New("cffIndex: corrupt INDEX offsets")
+		}
+		items[i] = b[from:to]
+	}
+
+	return items, base + offset(count), nil
+}
+
+func encodeCFFIndex(items [][]byte) []byte {
+
+	if len(items) == 0 {
+		return []byte{0, 0}
+	}
+
+	l := 1
+	for _, item := range items {
+		l += len(item)
+	}
+
+	offSize := 1
+	for l >= 1<<uint(8*offSize) {
+		offSize++
+	}
+
+	b := []byte{byte(len(items) >> 8), byte(len(items)), byte(offSize)}
+
+	putOffset := func(v int) {
+		for i := offSize - 1; i >= 0; i-- {
+			b = append(b, byte(v>>uint(8*i)))
+		}
+	}
+
+	o := 1
+	putOffset(o)
+	for _, item := range items {
+		o += len(item)
+		putOffset(o)
+	}
+
+	for _, item := range items {
+		b = append(b, item...)
+	}
+
+	return b
+}
+
+func parseCFFDict(b []byte) ([]cffDictEntry, error) {
+
+	var (
+		dict     []cffDictEntry
+		operands [][]byte
+		values   []int
+	)
+
+	for i := 0; i < len(b); {
+
+		b0 := int(b[i])
+		n, v := 0, 0
+
+		switch {
+
+		case b0 <= 21:
+			op := b0
+			i++
+			if b0 == 12 {
+				if i >= len(b) {
+					return nil, errors.New("parseCFFDict: corrupt DICT")
+				}
+				op = 12<<8 | int(b[i])
+				i++
+			}
+			dict = append(dict, cffDictEntry{op, operands, values})
+			operands, values = nil, nil
+			continue
+
+		case b0 == 28 && i+3 <= len(b):
+			n, v = 3, int(int16(binary.BigEndian.Uint16(b[i+1:])))
+
+		case b0 == 29 && i+5 <= len(b):
+			n, v = 5, int(int32(binary.BigEndian.Uint32(b[i+1:])))
+
+		case b0 == 30:
+			// Real number terminated by nibble 0xf.
+			for n = 1; i+n < len(b); n++ {
+				if b[i+n]&0x0F == 0x0F || b[i+n]>>4 == 0x0F {
+					break
+				}
+			}
+			n++
+
+		case b0 >= 32 && b0 <= 246:
+			n, v = 1, b0-139
+
+		case b0 >= 247 && b0 <= 250 && i+2 <= len(b):
+			n, v = 2, (b0-247)*256+int(b[i+1])+108
+
+		case b0 >= 251 && b0 <= 254 && i+2 <= len(b):
+			n, v = 2, -(b0-251)*256-int(b[i+1])-108
+
+		default:
+			return nil, errors.Errorf("parseCFFDict: corrupt DICT operand %d", b0)
+		}
+
+		if i+n > len(b) {
+			return nil, errors.New("parseCFFDict: corrupt DICT")
+		}
+
+		operands = append(operands, b[i:i+n])
+		values = append(values, v)
+		i += n
+	}
+
+	return dict, nil
+}
+
+// encodeCFFDict encodes a DICT using offsets as the 5 byte integer operands of the respective operators.
+func encodeCFFDict(dict []cffDictEntry, offsets map[int][]int) []byte {
+
+	var b []byte
+
+	for _, e := range dict {
+
+		if vv, ok := offsets[e.op]; ok {
+			for _, v := range vv {
+				b = append(b, 29, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+			}
+		} else {
+			for _, o := range e.operands {
+				b = append(b, o...)
+			}
+		}
+
+		if e.op > 0xFF {
+			b = append(b, 12)
+		}
+		b = append(b, byte(e.op))
+	}
+
+	return b
+}
+
+func cffDictValues(dict []cffDictEntry, op int) []int {
+
+	for _, e := range dict {
+		if e.op == op {
+			return e.values
+		}
+	}
+
+	return nil
+}
+
+func (cf *cffFont) parsePrivate(b []byte, vv []int) (*cffPrivateDict, error) {
+
+	if len(vv) != 2 || vv[0] < 0 || vv[1] < 0 || vv[0]+vv[1] > len(b) {
+		return nil, errors.New("parseCFF: corrupt Private DICT")
+	}
+
+	dict, err := parseCFFDict(b[vv[1] : vv[1]+vv[0]])
+	if err != nil {
+		return nil, err
+	}
+
+	p := &cffPrivateDict{dict: dict}
+
+	if subrs := cffDictValues(dict, cffSubrs); len(subrs) == 1 {
+		off := vv[1] + subrs[0]
+		var end int
+		p.subrs, end, err = cffIndex(b, off)
+		if err != nil {
+			return nil, err
+		}
+		p.raw = b[off:end]
+	}
+
+	return p, nil
+}
+
+// parseCharset parses the charset of a font with n glyphs.
+func (cf *cffFont) parseCharset(b []byte, id, n int) error {
+
+	cf.sids = make([]int, n)
+
+	if id <= 2 {
+		// Predefined charsets, only ISOAdobe is supported for lookups.
+		if id == 0 {
+			for i := range cf.sids {
+				cf.sids[i] = i
+			}
+		} else {
+			cf.sids = nil
+		}
+		return nil
+	}
+
+	off := id
+
+	u16 := func(i int) int {
+		if i+2 > len(b) {
+			return 0
+		}
+		return int(binary.BigEndian.Uint16(b[i:]))
+	}
+
+	if off >= len(b) {
+		return errors.New("parseCFF: corrupt charset")
+	}
+
+	i := off + 1
+	gid := 1
+
+	switch b[off] {
+
+	case 0:
+		for ; gid < n; gid++ {
+			cf.sids[gid] = u16(i)
+			i += 2
+		}
+
+	case 1, 2:
+		for gid < n {
+			first, nLeft := u16(i), 0
+			if b[off] == 1 {
+				if i+2 < len(b) {
+					nLeft = int(b[i+2])
+				}
+				i += 3
+			} else {
+				nLeft = u16(i + 2)
+				i += 4
+			}
+			for j := 0; j <= nLeft && gid < n; j++ {
+				cf.sids[gid] = first + j
+				gid++
+			}
+		}
+
+	default:
+		return errors.Errorf("parseCFF: unsupported charset format %d", b[off])
+	}
+
+	if i > len(b) {
+		return errors.New("parseCFF: corrupt charset")
+	}
+
+	cf.charset = b[off:i]
+
+	return nil
+}
+
+// parseEncoding parses the built-in encoding of a font.
+func (cf *cffFont) parseEncoding(b []byte, id int) error {
+
+	cf.codes = map[int]int{}
+
+	if id <= 1 {
+		// Predefined encodings, only Standard is supported for lookups.
+		if id == 0 {
+			for c, n := range standardEncoding {
+				if gid, ok := cf.gidForName(n); ok && n != "" {
+					cf.codes[c] = gid
+				}
+			}
+		}
+		return nil
+	}
+
+	off := id
+	if off+2 > len(b) {
+		return errors.New("parseCFF: corrupt encoding")
+	}
+
+	format := b[off]
+	i := off + 1
+
+	switch format & 0x7F {
+
+	case 0:
+		n := int(b[i])
+		i++
+		for gid := 1; gid <= n && i < len(b); gid++ {
+			cf.codes[int(b[i])] = gid
+			i++
+		}
+
+	case 1:
+		n := int(b[i])
+		i++
+		gid := 1
+		for j := 0; j < n && i+2 <= len(b); j++ {
+			first, nLeft := int(b[i]), int(b[i+1])
+			for c := first; c <= first+nLeft; c++ {
+				cf.codes[c] = gid
+				gid++
+			}
+			i += 2
+		}
+
+	default:
+		return errors.Errorf("parseCFF: unsupported encoding format %d", format)
+	}
+
+	if format&0x80 != 0 && i < len(b) {
+		// Supplements
+		n := int(b[i])
+		i++
+		for j := 0; j < n && i+3 <= len(b); j++ {
+			sid := int(binary.BigEndian.Uint16(b[i+1:]))
+			for gid, s := range cf.sids {
+				if s == sid {
+					cf.codes[int(b[i])] = gid
+					break
+				}
+			}
+			i += 3
+		}
+	}
+
+	if i > len(b) {
+		return errors.New("parseCFF: corrupt encoding")
+	}
+
+	cf.encoding = b[off:i]
+
+	return nil
+}
+
+func (cf *cffFont) parseFDSelect(b []byte, off, n int) error {
+
+	if off >= len(b) {
+		return errors.New("parseCFF: corrupt FDSelect")
+	}
+
+	end := off
+
+	switch b[off] {
+	case 0:
+		end = off + 1 + n
+	case 3:
+		if off+3 <= len(b) {
+			end = off + 3 + 3*int(binary.BigEndian.Uint16(b[off+1:])) + 2
+		}
+	default:
+		return errors.Errorf("parseCFF: unsupported FDSelect format %d", b[off])
+	}
+
+	if end > len(b) || end == off {
+		return errors.New("parseCFF: corrupt FDSelect")
+	}
+
+	cf.fdSelect = b[off:end]
+
+	return nil
+}
+
+// parseCFF parses a bare CFF font program as embedded using FontFile3.
+func parseCFF(b []byte) (*cffFont, error) {
+
+	if len(b) < 4 || b[0] != 1 {
+		return nil, errors.New("parseCFF: unsupported CFF version")
+	}
+
+	hdrSize := int(b[2])
+	if hdrSize < 4 || hdrSize > len(b) {
+		return nil, errors.New("parseCFF: corrupt header")
+	}
+
+	cf := &cffFont{header: b[:hdrSize]}
+
+	names, off, err := cffIndex(b, hdrSize)
+	if err != nil {
+		return nil, err
+	}
+	if len(names) != 1 {
+		return nil, errors.Errorf("parseCFF: %d fonts, expected 1", len(names))
+	}
+	cf.names = b[hdrSize:off]
+
+	tops, off, err := cffIndex(b, off)
+	if err != nil {
+		return nil, err
+	}
+	if len(tops) != 1 {
+		return nil, errors.New("parseCFF: corrupt Top DICT INDEX")
+	}
+
+	cf.top, err = parseCFFDict(tops[0])
+	if err != nil {
+		return nil, err
+	}
+
+	start := off
+	cf.strings, off, err = cffIndex(b, off)
+	if err != nil {
+		return nil, err
+	}
+	cf.rawStrings = b[start:off]
+
+	start = off
+	cf.gsubrs, off, err = cffIndex(b, off)
+	if err != nil {
+		return nil, err
+	}
+	cf.rawGsubrs = b[start:off]
+
+	if t := cffDictValues(cf.top, cffCharstringType); len(t) == 1 && t[0] != 2 {
+		return nil, errors.Errorf("parseCFF: unsupported charstring type %d", t[0])
+	}
+
+	cs := cffDictValues(cf.top, cffCharStrings)
+	if len(cs) != 1 {
+		return nil, errors.New("parseCFF: missing CharStrings")
+	}
+
+	cf.charStrings, _, err = cffIndex(b, cs[0])
+	if err != nil {
+		return nil, err
+	}
+
+	n := len(cf.charStrings)
+
+	charset := 0
+	if vv := cffDictValues(cf.top, cffCharset); len(vv) == 1 {
+		charset = vv[0]
+	}
+
+	if err = cf.parseCharset(b, charset, n); err != nil {
+		return nil, err
+	}
+
+	cf.cid = cffDictValues(cf.top, cffROS) != nil
+
+	if !cf.cid {
+
+		encoding := 0
+		if vv := cffDictValues(cf.top, cffEncoding); len(vv) == 1 {
+			encoding = vv[0]
+		}
+
+		if err = cf.parseEncoding(b, encoding); err != nil {
+			return nil, err
+		}
+
+		p, err := cf.parsePrivate(b, cffDictValues(cf.top, cffPrivate))
+		if err != nil {
+			return nil, err
+		}
+		cf.privates = []*cffPrivateDict{p}
+
+		return cf, nil
+	}
+
+	fdArray, fdSelect := cffDictValues(cf.top, cffFDArray), cffDictValues(cf.top, cffFDSelect)
+	if len(fdArray) != 1 || len(fdSelect) != 1 {
+		return nil, errors.New("parseCFF: missing FDArray or FDSelect")
+	}
+
+	if err = cf.parseFDSelect(b, fdSelect[0], n); err != nil {
+		return nil, err
+	}
+
+	fds, _, err := cffIndex(b, fdArray[0])
+	if err != nil {
+		return nil, err
+	}
+
+	for _, fd := range fds {
+
+		dict, err := parseCFFDict(fd)
+		if err != nil {
+			return nil, err
+		}
+
+		p, err := cf.parsePrivate(b, cffDictValues(dict, cffPrivate))
+		if err != nil {
+			return nil, err
+		}
+
+		cf.fds = append(cf.fds, dict)
+		cf.privates = append(cf.privates, p)
+	}
+
+	return cf, nil
+}
+
+func (cf *cffFont) glyphName(sid int) string {
+
+	if sid < len(cffStandardStrings) {
+		return cffStandardStrings[sid]
+	}
+
+	if i := sid - len(cffStandardStrings); i < len(cf.strings) {
+		return string(cf.strings[i])
+	}
+
+	return ""
+}
+
+// gidForName returns the glyph index for a glyph name of a font that is not CID-keyed.
+func (cf *cffFont) gidForName(name string) (int, bool) {
+
+	if cf.cid || name == "" {
+		return 0, false
+	}
+
+	for gid, sid := range cf.sids {
+		if cf.glyphName(sid) == name {
+			return gid, true
+		}
+	}
+
+	return 0, false
+}
+
+// gidForCID returns the glyph index for a CID of a CID-keyed font.
+func (cf *cffFont) gidForCID(cid int) (int, bool) {
+
+	if !cf.cid {
+		return 0, false
+	}
+
+	for gid, c := range cf.sids {
+		if c == cid {
+			return gid, true
+		}
+	}
+
+	return 0, false
+}
+
+func subrBias(subrs [][]byte) int {
+
+	switch n := len(subrs); {
+	case n < 1240:
+		return 107
+	case n < 33900:
+		return 1131
+	}
+
+	return 32768
+}
+
+// accentedChar returns the standard encoding codes of the base and accent
+// character of a glyph using the seac like variant of endchar.
+func (cf *cffFont) accentedChar(gid int) (bchar, achar int, ok bool) {
+
+	if cf.cid || gid >= len(cf.charStrings) {
+		return 0, 0, false
+	}
+
+	p := cf.privates[0]
+
+	var (
+		stack []int
+		stems int
+		depth int
+		done  bool
+	)
+
+	var run func(cs []byte)
+
+	run = func(cs []byte) {
+
+		depth++
+		defer func() { depth-- }()
+
+		if depth > 10 {
+			done = true
+			return
+		}
+
+		for i := 0; i < len(cs) && !done; {
+
+			b0 := int(cs[i])
+
+			switch {
+
+			case b0 == 28 && i+3 <= len(cs):
+				stack = append(stack, int(int16(binary.BigEndian.Uint16(cs[i+1:]))))
+				i += 3
+
+			case b0 >= 32 && b0 <= 246:
+				stack = append(stack, b0-139)
+				i++
+
+			case b0 >= 247 && b0 <= 250 && i+2 <= len(cs):
+				stack = append(stack, (b0-247)*256+int(cs[i+1])+108)
+				i += 2
+
+			case b0 >= 251 && b0 <= 254 && i+2 <= len(cs):
+				stack = append(stack, -(b0-251)*256-int(cs[i+1])-108)
+				i += 2
+
+			case b0 == 255 && i+5 <= len(cs):
+				stack = append(stack, int(int32(binary.BigEndian.Uint32(cs[i+1:])))>>16)
+				i += 5
+
+			case b0 == 1 || b0 == 3 || b0 == 18 || b0 == 23:
+				// hstem, vstem, hstemhm, vstemhm
+				stems += len(stack) / 2
+				stack = stack[:0]
+				i++
+
+			case b0 == 19 || b0 == 20:
+				// hintmask, cntrmask with implicit vstem
+				stems += len(stack) / 2
+				stack = stack[:0]
+				i += 1 + (stems+7)/8
+
+			case b0 == 10 || b0 == 29:
+				// callsubr, callgsubr
+				if len(stack) == 0 {
+					done = true
+					return
+				}
+				subrs := p.subrs
+				if b0 == 29 {
+					subrs = cf.gsubrs
+				}
+				j := stack[len(stack)-1] + subrBias(subrs)
+				stack = stack[:len(stack)-1]
+				if j < 0 || j >= len(subrs) {
+					done = true
+					return
+				}
+				run(subrs[j])
+				i++
+
+			case b0 == 11:
+				// return
+				return
+
+			case b0 == 14:
+				// endchar
+				if len(stack) >= 4 {
+					bchar, achar, ok = stack[len(stack)-2], stack[len(stack)-1], true
+				}
+				done = true
+				return
+
+			case b0 == 12:
+				stack = stack[:0]
+				i += 2
+
+			default:
+				stack = stack[:0]
+				i++
+			}
+		}
+	}
+
+	run(cf.charStrings[gid])
+
+	return bchar, achar, ok
+}
+
+// subset returns a font program keeping the glyph indices where all glyphs except gids,
+// the components of accented characters and .notdef are replaced by empty glyphs.
+func (cf *cffFont) subset(gids IntSet) []byte {
+
+	gids[0] = true
+
+	for gid := range gids {
+		if bchar, achar, ok := cf.accentedChar(gid); ok {
+			for _, c := range []int{bchar, achar} {
+				if c >= 0 && c < 256 {
+					if g, ok := cf.gidForName(standardEncoding[c]); ok {
+						gids[g] = true
+					}
+				}
+			}
+		}
+	}
+
+	charStrings := make([][]byte, len(cf.charStrings))
+	for gid, cs := range cf.charStrings {
+		if gids[gid] {
+			charStrings[gid] = cs
+		} else {
+			// endchar
+			charStrings[gid] = []byte{14}
+		}
+	}
+
+	encodedPrivate := func(p *cffPrivateDict) []byte {
+		offsets := map[int][]int{}
+		if p.subrs != nil {
+			// The Subrs INDEX follows the Private DICT.
+			offsets[cffSubrs] = []int{len(encodeCFFDict(p.dict, map[int][]int{cffSubrs: {0}}))}
+		}
+		return append(encodeCFFDict(p.dict, offsets), p.raw...)
+	}
+
+	offsets := map[int][]int{cffCharStrings: {0}}
+	if cf.charset != nil {
+		offsets[cffCharset] = []int{0}
+	}
+	if cf.encoding != nil {
+		offsets[cffEncoding] = []int{0}
+	}
+	if cf.cid {
+		offsets[cffFDSelect] = []int{0}
+		offsets[cffFDArray] = []int{0}
+	} else {
+		offsets[cffPrivate] = []int{0, 0}
+	}
+
+	// All offsets are encoded using 5 bytes so the size of the Top DICT is known in advance.
+	off := len(cf.header) + len(cf.names) + len(encodeCFFIndex([][]byte{encodeCFFDict(cf.top, offsets)})) + len(cf.rawStrings) + len(cf.rawGsubrs)
+
+	var data []byte
+
+	if cf.charset != nil {
+		offsets[cffCharset] = []int{off + len(data)}
+		data = append(data, cf.charset...)
+	}
+
+	if cf.encoding != nil {
+		offsets[cffEncoding] = []int{off + len(data)}
+		data = append(data, cf.encoding...)
+	}
+
+	if cf.cid {
+		offsets[cffFDSelect] = []int{off + len(data)}
+		data = append(data, cf.fdSelect...)
+	}
+
+	offsets[cffCharStrings] = []int{off + len(data)}
+	data = append(data, encodeCFFIndex(charStrings)...)
+
+	if !cf.cid {
+		p := encodedPrivate(cf.privates[0])
+		offsets[cffPrivate] = []int{len(p) - len(cf.privates[0].raw), off + len(data)}
+		data = append(data, p...)
+	} else {
+		// The FDArray INDEX is followed by the Private DICTs.
+		fdOffsets := make([]map[int][]int, len(cf.fds))
+		var fds [][]byte
+		for i, fd := range cf.fds {
+			fdOffsets[i] = map[int][]int{cffPrivate: {0, 0}}
+			fds = append(fds, encodeCFFDict(fd, fdOffsets[i]))
+		}
+
+		pOff := off + len(data) + len(encodeCFFIndex(fds))
+
+		var privates []byte
+		for i, fd := range cf.fds {
+			p := encodedPrivate(cf.privates[i])
+			fdOffsets[i][cffPrivate] = []int{len(p) - len(cf.privates[i].raw), pOff + len(privates)}
+			fds[i] = encodeCFFDict(fd, fdOffsets[i])
+			privates = append(privates, p...)
+		}
+
+		offsets[cffFDArray] = []int{off + len(data)}
+		data = append(data, encodeCFFIndex(fds)...)
+		data = append(data, privates...)
+	}
+
+	b := append([]byte(nil), cf.header...)
+	b = append(b, cf.names...)
+	b = append(b, encodeCFFIndex([][]byte{encodeCFFDict(cf.top, offsets)})...)
+	b = append(b, cf.rawStrings...)
+	b = append(b, cf.rawGsubrs...)
+
+	return append(b, data...)
+}
@@ -0,0 +1,288 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"unicode/utf16"
+
+	"github.com/hhrutter/pdfcpu/pkg/log"
+	"github.com/hhrutter/pdfcpu/pkg/pdfcpu/content"
+)
+
+// cmap represents the parts of an embedded CMap or a ToUnicode CMap
+// needed for text extraction.
+type cmap struct {
+	codespace []cmapRange
+	chars     map[string]cmapValue // bfchar, cidchar
+	ranges    []cmapRange          // bfrange, cidrange
+}
+
+type cmapRange struct {
+	lo, hi []byte
+	dst    []byte   // the UTF-16BE start value of a bfrange.
+	dsts   []string // the destination array of a bfrange.
+	cid    int      // the start CID of a cidrange.
+}
+
+type cmapValue struct {
+	text string
+	cid  int
+}
+
+func bytesValue(b []byte) int {
+
+	v := 0
+	for _, c := range b {
+		v = v<<8 | int(c)
+	}
+
+	return v
+}
+
+func (r cmapRange) contains(code []byte) bool {
+
+	if len(code) != len(r.lo) {
+		return false
+	}
+
+	// The range is defined bytewise.
+	for i, c := range code {
+		if c < r.lo[i] || c > r.hi[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// utf16Text decodes UTF-16BE as used for ToUnicode destinations.
+func utf16Text(b []byte) string {
+
+	if len(b)%2 == 1 {
+		b = append(b, 0)
+	}
+
+	u := make([]uint16, len(b)/2)
+	for i := range u {
+		u[i] = uint16(b[2*i])<<8 | uint16(b[2*i+1])
+	}
+
+	return string(utf16.Decode(u))
+}
+
+// codeLength returns the number of bytes for the code at the beginning of b.
+func (cm *cmap) codeLength(b []byte) int {
+
+	for n := 1; n <= 4 && n <= len(b); n++ {
+		for _, r := range cm.codespace {
+			if r.contains(b[:n]) {
+				return n
+			}
+		}
+	}
+
+	// Skip a single byte of unmatched code.
+	return 1
+}
+
+// text returns the Unicode text for a code of a ToUnicode CMap.
+func (cm *cmap) text(code []byte) (string, bool) {
+
+	if v, ok := cm.chars[string(code)]; ok {
+		return v.text, true
+	}
+
+	for _, r := range cm.ranges {
+
+		if !r.contains(code) {
+			continue
+		}
+
+		i := bytesValue(code) - bytesValue(r.lo)
+
+		if r.dsts != nil {
+			if i < len(r.dsts) {
+				return r.dsts[i], true
+			}
+			return "", false
+		}
+
+		// Increment the last byte of the destination.
+		dst := make([]byte, len(r.dst))
+		copy(dst, r.dst)
+		if len(dst) > 0 {
+			v := bytesValue(dst[len(dst)-2:]) + i
+			dst[len(dst)-2], dst[len(dst)-1] = byte(v>>8), byte(v)
+		}
+
+		return utf16Text(dst), true
+	}
+
+	return "", false
+}
+
+// cid returns the CID for a code of an embedded CMap.
+func (cm *cmap) cid(code []byte) int {
+
+	if v, ok := cm.chars[string(code)]; ok {
+		return v.cid
+	}
+
+	for _, r := range cm.ranges {
+		if r.contains(code) {
+			return r.cid + bytesValue(code) - bytesValue(r.lo)
+		}
+	}
+
+	return 0
+}
+
+func (cm *cmap) parseCodespaceRanges(operands []content.Object) {
+
+	for i := 0; i+1 < len(operands); i += 2 {
+
+		lo, hi, ok := cmapBytePair(operands[i], operands[i+1])
+		if !ok {
+			return
+		}
+
+		cm.codespace = append(cm.codespace, cmapRange{lo: lo, hi: hi})
+	}
+}
+
+func cmapBytePair(o1, o2 content.Object) (lo, hi []byte, ok bool) {
+
+	lo, ok = content.StringBytes(o1)
+	if !ok {
+		return nil, nil, false
+	}
+
+	hi, ok = content.StringBytes(o2)
+	if !ok || len(lo) != len(hi) {
+		return nil, nil, false
+	}
+
+	return lo, hi, true
+}
+
+func (cm *cmap) parseChars(operands []content.Object, cid bool) {
+
+	for i := 0; i+1 < len(operands); i += 2 {
+
+		code, ok := content.StringBytes(operands[i])
+		if !ok {
+			return
+		}
+
+		if cid {
+			v, _ := content.Number(operands[i+1])
+			cm.chars[string(code)] = cmapValue{cid: int(v)}
+			continue
+		}
+
+		if n, ok := operands[i+1].(content.Name); ok {
+			// Some producers use glyph names as destinations.
+			s, _ := glyphText(string(n))
+			cm.chars[string(code)] = cmapValue{text: s}
+			continue
+		}
+
+		dst, ok := content.StringBytes(operands[i+1])
+		if !ok {
+			return
+		}
+
+		cm.chars[string(code)] = cmapValue{text: utf16Text(dst)}
+	}
+}
+
+func (cm *cmap) parseRanges(operands []content.Object, cid bool) {
+
+	for i := 0; i+2 < len(operands); i += 3 {
+
+		lo, hi, ok := cmapBytePair(operands[i], operands[i+1])
+		if !ok {
+			return
+		}
+
+		r := cmapRange{lo: lo, hi: hi}
+
+		if cid {
+			v, _ := content.Number(operands[i+2])
+			r.cid = int(v)
+			cm.ranges = append(cm.ranges, r)
+			continue
+		}
+
+		if a, ok := operands[i+2].(content.Array); ok {
+			r.dsts = []string{}
+			for _, o := range a {
+				b, _ := content.StringBytes(o)
+				r.dsts = append(r.dsts, utf16Text(b))
+			}
+			cm.ranges = append(cm.ranges, r)
+			continue
+		}
+
+		r.dst, ok = content.StringBytes(operands[i+2])
+		if !ok {
+			return
+		}
+
+		if len(r.dst) < 2 {
+			r.dst = append([]byte{0}, r.dst...)
+		}
+
+		cm.ranges = append(cm.ranges, r)
+	}
+}
+
+// parseCMap parses an embedded CMap or a ToUnicode CMap.
+func parseCMap(b []byte) *cmap {
+
+	cm := &cmap{chars: map[string]cmapValue{}}
+
+	// The entries of a mapping section are the operands of its end operator.
+	c, err := content.Parse(b)
+	if err != nil {
+		log.Debug.Printf("parseCMap: %v\n", err)
+	}
+
+	for _, op := range c.Operations {
+
+		switch op.Operator {
+
+		case "endcodespacerange":
+			cm.parseCodespaceRanges(op.Operands)
+
+		case "endbfchar":
+			cm.parseChars(op.Operands, false)
+
+		case "endcidchar":
+			cm.parseChars(op.Operands, true)
+
+		case "endbfrange":
+			cm.parseRanges(op.Operands, false)
+
+		case "endcidrange":
+			cm.parseRanges(op.Operands, true)
+
+		}
+	}
+
+	return cm
+}
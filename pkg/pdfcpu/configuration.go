@@ -16,6 +16,13 @@ limitations under the License.
 
 package pdfcpu
 
+import (
+	"crypto"
+	"crypto/x509"
+
+	"github.com/hhrutter/pdfcpu/pkg/filter"
+)
+
 const (
 
 	// ValidationStrict ensures 100% compliance with the spec (PDF 32000-1:2008).
@@ -61,6 +68,18 @@ const (
 	CHANGEOPW
 	STAMP
 	ADDWATERMARKS
+	SIGN
+	VERIFYSIGNATURES
+	LISTREVISIONS
+	EXTRACTREVISION
+	EXTRACTTEXT
+	REDACT
+	LISTBOOKMARKS
+	EXPORTBOOKMARKS
+	IMPORTBOOKMARKS
+	REMOVEBOOKMARKS
+	LISTFORMFIELDS
+	FILLFORMFIELDS
 )
 
 // Configuration of a PDFContext.
@@ -87,12 +106,32 @@ type Configuration struct {
 	// Switches between xRefSection (<=V1.4) and objectStream/xRefStream (>=V1.5) writing.
 	WriteXRefStream bool
 
+	// Appends new and modified objects as an incremental update instead of rewriting the whole file.
+	// Supported for adding attachments, permissions and watermarks.
+	WriteIncrement bool
+
+	// Writes a linearized file optimized for incremental access aka "Fast Web View".
+	// Takes precedence over WriteObjectStream and WriteXRefStream.
+	Linearize bool
+
 	// Turns on stats collection.
 	CollectStats bool
 
 	// A CSV-filename holding the statistics.
 	StatsFileName string
 
+	// Optimize downsamples images exceeding this resolution in dpi, 0 turns off resampling.
+	ImageDPI int
+
+	// The JPEG quality (1..100) for reencoding resampled images.
+	ImageQuality int
+
+	// Optimize subsets fully embedded TrueType and CFF fonts to the glyphs used.
+	SubsetFonts bool
+
+	// Merge nests the outlines of each file below a new top level outline item named after the file.
+	MergeBookmarks bool
+
 	// Supplied user password
 	UserPW    string
 	UserPWNew *string
@@ -106,11 +145,29 @@ type Configuration struct {
 	// false: RC4 encryption.
 	EncryptUsingAES bool
 
-	// EncryptUsing128BitKey ensures 128 bit key length.
+	// EncryptKeyLength is the key length in bits: 40, 128 or 256.
+	// 256 bit keys need AES encryption.
+	// 0 selects the key length according to EncryptUsing128BitKey.
+	EncryptKeyLength int
+
+	// EncryptUsing128BitKey selects the key length if EncryptKeyLength is 0.
 	// true: use 128 bit key
 	// false: use 40 bit key
+	//
+	// Deprecated: Use EncryptKeyLength instead.
 	EncryptUsing128BitKey bool
 
+	// EncryptRecipients are the recipient certificates for public-key encryption (Adobe.PubSec).
+	// If set, user and owner password are ignored.
+	EncryptRecipients []*x509.Certificate
+
+	// DecryptCert and DecryptKey identify the recipient for reading public-key encrypted files.
+	DecryptCert *x509.Certificate
+	DecryptKey  crypto.PrivateKey
+
+	// TrustedCerts is the trust store for signature verification.
+	TrustedCerts []*x509.Certificate
+
 	// Supplied user access permissions, see Table 22
 	UserAccessPermissions int16
 
@@ -129,12 +186,28 @@ func NewDefaultConfiguration() *Configuration {
 		WriteObjectStream:     true,
 		WriteXRefStream:       true,
 		CollectStats:          true,
+		ImageQuality:          filter.DefaultJPEGQuality,
+		SubsetFonts:           true,
 		EncryptUsingAES:       true,
 		EncryptUsing128BitKey: true,
 		UserAccessPermissions: PermissionsNone,
 	}
 }
 
+// encryptKeyLength returns the key length in bits taking into account the deprecated EncryptUsing128BitKey.
+func (c *Configuration) encryptKeyLength() int {
+
+	if c.EncryptKeyLength != 0 {
+		return c.EncryptKeyLength
+	}
+
+	if c.EncryptUsing128BitKey {
+		return 128
+	}
+
+	return 40
+}
+
 // ValidationModeString returns a string rep for the validation mode in effect.
 func (c *Configuration) ValidationModeString() string {
 
@@ -148,3 +221,18 @@ func (c *Configuration) ValidationModeString() string {
 
 	return ""
 }
+
+// IncrementalUpdate returns true if the command in effect writes an incremental update.
+func (c *Configuration) IncrementalUpdate() bool {
+
+	if !c.WriteIncrement {
+		return false
+	}
+
+	switch c.Mode {
+	case ADDATTACHMENTS, ADDPERMISSIONS, STAMP, ADDWATERMARKS:
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,185 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package content parses, writes and validates PDF content streams.
+//
+// A content stream is parsed into a sequence of operations, each one an operator together with its operands.
+// Operations keep their source bytes so that writing back an unmodified content stream reproduces it byte by byte.
+package content
+
+import (
+	"bytes"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// InlineImage represents the parameters and the data of an inline image: BI ... ID ... EI.
+type InlineImage struct {
+	Dict Dict
+	Data []byte // the image data following ID up to EI.
+}
+
+// Operation represents an operator together with its operands.
+type Operation struct {
+	Operator string
+	Operands []Object
+	Image    *InlineImage // the inline image for operator BI.
+	src      []byte       // the source bytes including preceding whitespace and comments.
+	canon    string       // the canonical form as parsed.
+}
+
+// NewOperation returns a new operation for an operator and its operands.
+func NewOperation(operator string, operands ...Object) *Operation {
+	return &Operation{Operator: operator, Operands: operands}
+}
+
+// String returns the canonical PDF syntax of an operation.
+func (op Operation) String() string {
+
+	var sb strings.Builder
+
+	for _, o := range op.Operands {
+		sb.WriteString(o.PDFString())
+		sb.WriteString(" ")
+	}
+
+	if op.Operator != "BI" || op.Image == nil {
+		sb.WriteString(op.Operator)
+		return sb.String()
+	}
+
+	sb.WriteString("BI")
+
+	keys := make([]string, 0, len(op.Image.Dict))
+	for k := range op.Image.Dict {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		sb.WriteString(" " + Name(k).PDFString() + " " + op.Image.Dict[k].PDFString())
+	}
+
+	sb.WriteString(" ID ")
+	sb.Write(op.Image.Data)
+
+	if len(op.Image.Data) == 0 || !isWhitespace(op.Image.Data[len(op.Image.Data)-1]) {
+		sb.WriteString("\n")
+	}
+
+	sb.WriteString("EI")
+
+	return sb.String()
+}
+
+// Content represents a parsed content stream.
+type Content struct {
+	Operations []*Operation
+	trailer    []byte // whitespace and comments following the last operation.
+}
+
+// Parse parses a content stream.
+// In case of an error the operations parsed so far are returned along with the error.
+func Parse(b []byte) (*Content, error) {
+
+	c := &Content{}
+	s := newScanner(b)
+
+	start := 0
+
+	var operands []Object
+
+	for {
+
+		t, err := s.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return c, err
+		}
+
+		if t.op == "" {
+			operands = append(operands, t.obj)
+			continue
+		}
+
+		op := &Operation{Operator: t.op, Operands: operands, Image: t.image, src: b[start:s.pos]}
+		op.canon = op.String()
+		c.Operations = append(c.Operations, op)
+
+		operands = nil
+		start = s.pos
+	}
+
+	if len(operands) > 0 {
+		return c, errors.Errorf("content: missing operator after %d operands", len(operands))
+	}
+
+	c.trailer = b[start:]
+
+	return c, nil
+}
+
+// separate writes a line feed if the next token would otherwise run into the preceding one.
+func separate(buf *bytes.Buffer, next []byte) {
+
+	b := buf.Bytes()
+	if len(b) == 0 || len(next) == 0 {
+		return
+	}
+
+	last, first := b[len(b)-1], next[0]
+	if isWhitespace(last) || isDelimiter(last) || isWhitespace(first) || isDelimiter(first) {
+		return
+	}
+
+	buf.WriteString("\n")
+}
+
+// Bytes returns the serialized content stream.
+// Operations unchanged since parsing are written as found in the source.
+func (c *Content) Bytes() []byte {
+
+	var buf bytes.Buffer
+
+	for _, op := range c.Operations {
+
+		if op.src != nil && op.String() == op.canon {
+			// Operations might have been removed or inserted.
+			separate(&buf, op.src)
+			buf.Write(op.src)
+			continue
+		}
+
+		if buf.Len() > 0 {
+			buf.WriteString("\n")
+		}
+
+		buf.WriteString(op.String())
+	}
+
+	if c.trailer != nil {
+		buf.Write(c.trailer)
+	} else if buf.Len() > 0 {
+		buf.WriteString("\n")
+	}
+
+	return buf.Bytes()
+}
@@ -0,0 +1,211 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package content
+
+import (
+	"testing"
+)
+
+type testResources map[string][]string
+
+func (r testResources) Has(category, name string) bool {
+	for _, n := range r[category] {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+var resources = testResources{
+	"Font":       {"F1"},
+	"XObject":    {"Im1", "Fm1"},
+	"ExtGState":  {"GS1"},
+	"ColorSpace": {"CS0"},
+	"Pattern":    {"P1"},
+	"Properties": {"MC0"},
+}
+
+func doTestParseOK(s string, t *testing.T) *Content {
+
+	c, err := Parse([]byte(s))
+	if err != nil {
+		t.Fatalf("Parse failed: <%v> <%s>\n", err, s)
+	}
+
+	if string(c.Bytes()) != s {
+		t.Fatalf("Parse: roundtrip mismatch:\n<%s>\n<%s>\n", s, c.Bytes())
+	}
+
+	return c
+}
+
+func doTestValidateOK(s string, t *testing.T) {
+
+	c := doTestParseOK(s, t)
+
+	err := c.Validate(resources)
+	if err != nil {
+		t.Errorf("Validate failed: <%v> <%s>\n", err, s)
+	}
+}
+
+func doTestValidateFail(s string, t *testing.T) {
+
+	c, err := Parse([]byte(s))
+	if err != nil {
+		return
+	}
+
+	err = c.Validate(resources)
+	if err == nil {
+		t.Errorf("Validate should have returned an error for <%s>\n", s)
+	}
+}
+
+func TestParse(t *testing.T) {
+
+	c := doTestParseOK("q 1 0 0 1 72.5 -.5 cm /Im1 Do Q\n", t)
+	if len(c.Operations) != 4 {
+		t.Fatalf("Parse: expected 4 operations, got %d\n", len(c.Operations))
+	}
+
+	op := c.Operations[1]
+	if op.Operator != "cm" || len(op.Operands) != 6 || op.Operands[4] != Real(72.5) || op.Operands[5] != Real(-0.5) {
+		t.Fatalf("Parse: unexpected operation: %s\n", op)
+	}
+
+	c = doTestParseOK("BT/F1 12 Tf(Hello \\(World\\)\\051)Tj[<48656c6c6f> -250 (x)]TJ ET % comment\n", t)
+	if b, _ := StringBytes(c.Operations[2].Operands[0]); string(b) != "Hello (World))" {
+		t.Fatalf("Parse: unexpected string: %s\n", b)
+	}
+	if b, _ := StringBytes(c.Operations[3].Operands[0].(Array)[0]); string(b) != "Hello" {
+		t.Fatalf("Parse: unexpected hex string: %s\n", b)
+	}
+
+	c = doTestParseOK("/Span<</ActualText(fi)/MCID 0>>BDC /A#20B BMC EMC EMC", t)
+	if c.Operations[1].Operands[0] != Name("A B") {
+		t.Fatalf("Parse: unexpected name: %s\n", c.Operations[1].Operands[0].PDFString())
+	}
+
+	// Inline image data may contain anything but whitespace followed by EI.
+	c = doTestParseOK("q BI /W 2 /H 1 /BPC 8 /CS /G ID \x00EI\xff\nEI Q", t)
+	img := c.Operations[1].Image
+	if img == nil || string(img.Data) != "\x00EI\xff\n" || img.Dict["W"] != Integer(2) {
+		t.Fatalf("Parse: unexpected inline image: %v\n", img)
+	}
+
+	for _, s := range []string{"1 0 0", "(unterminated Tj", "[1 2 re", "BI /W 1 ID 0000"} {
+		_, err := Parse([]byte(s))
+		if err == nil {
+			t.Errorf("Parse should have returned an error for <%s>\n", s)
+		}
+	}
+}
+
+func TestWrite(t *testing.T) {
+
+	c := doTestParseOK("q\n  0 0 1 rg\n  10 10 100 100 re f\nQ\n", t)
+
+	// Modified operations are written in canonical form, the rest is preserved.
+	c.Operations[1].Operands[2] = Real(0.5)
+	ops := c.Operations
+	c.Operations = []*Operation{ops[0], ops[1], ops[2], ops[3], NewOperation("S"), ops[4]}
+
+	want := "q\n0 0 0.5 rg\n  10 10 100 100 re f\nS\nQ\n"
+	if got := string(c.Bytes()); got != want {
+		t.Fatalf("Write: got\n<%s>\nwant\n<%s>\n", got, want)
+	}
+
+	// Inserted operations are separated from the source of the following operation.
+	c = doTestParseOK("q BT ET Q", t)
+	c.Operations = append([]*Operation{NewOperation("q")}, append(c.Operations, NewOperation("Q"))...)
+
+	want = "q\nq BT ET Q\nQ"
+	if got := string(c.Bytes()); got != want {
+		t.Fatalf("Write: got\n<%s>\nwant\n<%s>\n", got, want)
+	}
+
+	c = &Content{Operations: []*Operation{
+		NewOperation("BT"),
+		NewOperation("Tf", Name("F1"), Integer(12)),
+		NewOperation("Tj", LiteralString("a\\)b")),
+		NewOperation("ET"),
+	}}
+
+	want = "BT\n/F1 12 Tf\n(a\\)b) Tj\nET\n"
+	if got := string(c.Bytes()); got != want {
+		t.Fatalf("Write: got\n<%s>\nwant\n<%s>\n", got, want)
+	}
+}
+
+func TestValidate(t *testing.T) {
+
+	doTestValidateOK("q /GS1 gs 0.5 g 0 0 m 10 10 l S Q", t)
+	doTestValidateOK("BT /F1 12 Tf 10 10 Td (a) Tj [(b) -10 (c)] TJ 1 2 (d) \" ET", t)
+	doTestValidateOK("/CS0 cs 0.1 0.2 sc /Pattern CS /P1 SCN /DeviceRGB cs 1 0 0 sc", t)
+	doTestValidateOK("/OC /MC0 BDC /Fm1 Do EMC /Span <</MCID 1>> BDC EMC", t)
+	doTestValidateOK("BX /foo bar EX", t)
+	doTestValidateOK("BI /W 1 /H 1 /CS /RGB /BPC 8 ID abc EI", t)
+
+	doTestValidateFail("q Q Q", t)
+	doTestValidateFail("q", t)
+	doTestValidateFail("BT BT ET ET", t)
+	doTestValidateFail("BT", t)
+	doTestValidateFail("10 10 Td", t)
+	doTestValidateFail("(a) Tj", t)
+	doTestValidateFail("EMC", t)
+	doTestValidateFail("/X BMC", t)
+	doTestValidateFail("foo", t)
+	doTestValidateFail("1 2 3 cm", t)
+	doTestValidateFail("/F1 /F1 Tf", t)
+	doTestValidateFail("BT /F2 12 Tf ET", t)
+	doTestValidateFail("/Im2 Do", t)
+	doTestValidateFail("/GS2 gs", t)
+	doTestValidateFail("/CS1 cs", t)
+	doTestValidateFail("/P2 scn", t)
+	doTestValidateFail("/OC /MC1 BDC EMC", t)
+	doTestValidateFail("BT [(a) /x] TJ ET", t)
+}
+
+func TestResourceNames(t *testing.T) {
+
+	c, err := Parse([]byte("q /GS1 gs /CS0 cs /DeviceRGB CS /P1 scn BT /F1 12 Tf ET /Im1 Do /Sh1 sh BI /W 1 /H 1 /CS /CS2 /BPC 8 ID abc EI /Fm1 Q"))
+	if err != nil {
+		t.Fatalf("TestResourceNames: %v\n", err)
+	}
+
+	m := c.ResourceNames()
+
+	for category, names := range map[string][]string{
+		"ExtGState":  {"GS1"},
+		"ColorSpace": {"CS0", "CS2"},
+		"Pattern":    {"P1"},
+		"Font":       {"F1"},
+		"XObject":    {"Im1"},
+		"Shading":    {"Sh1"},
+	} {
+		if len(m[category]) != len(names) {
+			t.Fatalf("TestResourceNames: %s: %v\n", category, m[category])
+		}
+		for _, n := range names {
+			if !m[category][n] {
+				t.Fatalf("TestResourceNames: %s: missing %s\n", category, n)
+			}
+		}
+	}
+}
@@ -0,0 +1,252 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package content
+
+import (
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Object represents an operand.
+type Object interface {
+	PDFString() string
+}
+
+// Null represents the null object.
+type Null struct{}
+
+// Boolean represents a boolean operand.
+type Boolean bool
+
+// Integer represents an integer operand.
+type Integer int
+
+// Real represents a real operand.
+type Real float64
+
+// Name represents a name operand without the leading slash and with #xx escapes resolved.
+type Name string
+
+// LiteralString represents a string operand in its escaped form without the enclosing parentheses.
+type LiteralString string
+
+// HexString represents a hexadecimal string operand without the enclosing angle brackets.
+type HexString string
+
+// Array represents an array operand.
+type Array []Object
+
+// Dict represents a dict operand.
+type Dict map[string]Object
+
+// PDFString returns the PDF syntax.
+func (Null) PDFString() string {
+	return "null"
+}
+
+// PDFString returns the PDF syntax.
+func (b Boolean) PDFString() string {
+	return strconv.FormatBool(bool(b))
+}
+
+// PDFString returns the PDF syntax.
+func (i Integer) PDFString() string {
+	return strconv.Itoa(int(i))
+}
+
+// PDFString returns the PDF syntax.
+func (r Real) PDFString() string {
+	return strconv.FormatFloat(float64(r), 'f', -1, 64)
+}
+
+// PDFString returns the PDF syntax.
+func (n Name) PDFString() string {
+
+	var sb strings.Builder
+
+	sb.WriteString("/")
+
+	for i := 0; i < len(n); i++ {
+		c := n[i]
+		if c < 0x21 || c > 0x7E || c == '#' || isDelimiter(c) {
+			sb.WriteString(fmt.Sprintf("#%02X", c))
+			continue
+		}
+		sb.WriteByte(c)
+	}
+
+	return sb.String()
+}
+
+// PDFString returns the PDF syntax.
+func (s LiteralString) PDFString() string {
+	return "(" + string(s) + ")"
+}
+
+// PDFString returns the PDF syntax.
+func (s HexString) PDFString() string {
+	return "<" + string(s) + ">"
+}
+
+// PDFString returns the PDF syntax.
+func (a Array) PDFString() string {
+
+	ss := make([]string, len(a))
+	for i, o := range a {
+		ss[i] = o.PDFString()
+	}
+
+	return "[" + strings.Join(ss, " ") + "]"
+}
+
+// PDFString returns the PDF syntax.
+func (d Dict) PDFString() string {
+
+	keys := make([]string, 0, len(d))
+	for k := range d {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	ss := make([]string, len(keys))
+	for i, k := range keys {
+		ss[i] = Name(k).PDFString() + " " + d[k].PDFString()
+	}
+
+	return "<<" + strings.Join(ss, " ") + ">>"
+}
+
+// Bytes returns the unescaped bytes of a literal string.
+func (s LiteralString) Bytes() []byte {
+
+	var b []byte
+
+	for i := 0; i < len(s); i++ {
+
+		c := s[i]
+
+		if c != '\\' {
+			b = append(b, c)
+			continue
+		}
+
+		i++
+		if i == len(s) {
+			break
+		}
+
+		c = s[i]
+
+		switch c {
+
+		case 'n':
+			b = append(b, '\n')
+		case 'r':
+			b = append(b, '\r')
+		case 't':
+			b = append(b, '\t')
+		case 'b':
+			b = append(b, '\b')
+		case 'f':
+			b = append(b, '\f')
+
+		case '\r':
+			// Line continuation.
+			if i+1 < len(s) && s[i+1] == '\n' {
+				i++
+			}
+
+		case '\n':
+			// Line continuation.
+
+		default:
+			if c < '0' || c > '7' {
+				b = append(b, c)
+				continue
+			}
+			// Up to 3 octal digits.
+			v := 0
+			for j := 0; j < 3 && i < len(s) && s[i] >= '0' && s[i] <= '7'; j++ {
+				v = v*8 + int(s[i]-'0')
+				i++
+			}
+			i--
+			b = append(b, byte(v))
+		}
+	}
+
+	return b
+}
+
+// Bytes returns the decoded bytes of a hex string.
+func (s HexString) Bytes() []byte {
+
+	var sb strings.Builder
+
+	for i := 0; i < len(s); i++ {
+		if !isWhitespace(s[i]) {
+			sb.WriteByte(s[i])
+		}
+	}
+
+	h := sb.String()
+	if len(h)%2 == 1 {
+		h += "0"
+	}
+
+	b, err := hex.DecodeString(h)
+	if err != nil {
+		return nil
+	}
+
+	return b
+}
+
+// StringBytes returns the bytes of a string operand.
+func StringBytes(o Object) ([]byte, bool) {
+
+	switch o := o.(type) {
+
+	case LiteralString:
+		return o.Bytes(), true
+
+	case HexString:
+		return o.Bytes(), true
+
+	}
+
+	return nil, false
+}
+
+// Number returns the value of a numeric operand.
+func Number(o Object) (float64, bool) {
+
+	switch o := o.(type) {
+
+	case Integer:
+		return float64(o), true
+
+	case Real:
+		return float64(o), true
+
+	}
+
+	return 0, false
+}
@@ -0,0 +1,333 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package content
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// scanner splits a content stream into operands and operators.
+type scanner struct {
+	b   []byte
+	pos int
+}
+
+// token is either an operand or an operator.
+type token struct {
+	op    string       // the operator, "" for operands.
+	obj   Object       // the operand.
+	image *InlineImage // the inline image for operator BI.
+}
+
+func newScanner(b []byte) *scanner {
+	return &scanner{b: b}
+}
+
+func isWhitespace(c byte) bool {
+	return c == 0x00 || c == 0x09 || c == 0x0A || c == 0x0C || c == 0x0D || c == 0x20
+}
+
+func isDelimiter(c byte) bool {
+	return bytes.IndexByte([]byte("()<>[]{}/%"), c) >= 0
+}
+
+func (s *scanner) skipWhitespaceAndComments() {
+
+	for s.pos < len(s.b) {
+
+		c := s.b[s.pos]
+
+		if c == '%' {
+			for s.pos < len(s.b) && s.b[s.pos] != 0x0A && s.b[s.pos] != 0x0D {
+				s.pos++
+			}
+			continue
+		}
+
+		if !isWhitespace(c) {
+			return
+		}
+
+		s.pos++
+	}
+}
+
+// regular returns the next sequence of regular characters.
+func (s *scanner) regular() string {
+
+	start := s.pos
+
+	for s.pos < len(s.b) && !isWhitespace(s.b[s.pos]) && !isDelimiter(s.b[s.pos]) {
+		s.pos++
+	}
+
+	return string(s.b[start:s.pos])
+}
+
+func (s *scanner) literalString() (Object, error) {
+
+	// Skip '('
+	s.pos++
+	start := s.pos
+
+	depth := 1
+
+	for s.pos < len(s.b) {
+
+		switch s.b[s.pos] {
+
+		case '\\':
+			s.pos++
+
+		case '(':
+			depth++
+
+		case ')':
+			depth--
+			if depth == 0 {
+				ls := LiteralString(s.b[start:s.pos])
+				s.pos++
+				return ls, nil
+			}
+		}
+
+		s.pos++
+	}
+
+	return nil, errors.New("content: unterminated string literal")
+}
+
+func (s *scanner) hexString() (Object, error) {
+
+	// Skip '<'
+	s.pos++
+
+	i := bytes.IndexByte(s.b[s.pos:], '>')
+	if i < 0 {
+		return nil, errors.New("content: unterminated hex string")
+	}
+
+	hs := HexString(s.b[s.pos : s.pos+i])
+	s.pos += i + 1
+
+	return hs, nil
+}
+
+func (s *scanner) name() Object {
+
+	// Skip '/'
+	s.pos++
+
+	n := s.regular()
+
+	if bytes.IndexByte([]byte(n), '#') < 0 {
+		return Name(n)
+	}
+
+	// Resolve #xx escape sequences.
+	var b []byte
+	for i := 0; i < len(n); i++ {
+		if n[i] == '#' && i+2 < len(n) {
+			if bb, err := hex.DecodeString(n[i+1 : i+3]); err == nil {
+				b = append(b, bb[0])
+				i += 2
+				continue
+			}
+		}
+		b = append(b, n[i])
+	}
+
+	return Name(b)
+}
+
+func (s *scanner) array() (Object, error) {
+
+	// Skip '['
+	s.pos++
+
+	a := Array{}
+
+	for {
+
+		s.skipWhitespaceAndComments()
+
+		if s.pos >= len(s.b) {
+			return nil, errors.New("content: unterminated array")
+		}
+
+		if s.b[s.pos] == ']' {
+			s.pos++
+			return a, nil
+		}
+
+		t, err := s.next()
+		if err != nil {
+			return nil, err
+		}
+
+		if t.op != "" {
+			return nil, errors.Errorf("content: unexpected operator %s in array", t.op)
+		}
+
+		a = append(a, t.obj)
+	}
+}
+
+func (s *scanner) dict(end string) (Dict, error) {
+
+	d := Dict{}
+
+	for {
+
+		s.skipWhitespaceAndComments()
+
+		if s.pos >= len(s.b) {
+			return nil, errors.New("content: unterminated dict")
+		}
+
+		if bytes.HasPrefix(s.b[s.pos:], []byte(end)) {
+			s.pos += len(end)
+			return d, nil
+		}
+
+		t, err := s.next()
+		if err != nil {
+			return nil, err
+		}
+
+		k, ok := t.obj.(Name)
+		if !ok {
+			return nil, errors.New("content: corrupt dict key")
+		}
+
+		t, err = s.next()
+		if err != nil {
+			return nil, err
+		}
+
+		if t.op != "" {
+			return nil, errors.Errorf("content: unexpected operator %s in dict", t.op)
+		}
+
+		d[string(k)] = t.obj
+	}
+}
+
+// inlineImage parses the parameters and the data of an inline image following BI.
+func (s *scanner) inlineImage() (*InlineImage, error) {
+
+	d, err := s.dict("ID")
+	if err != nil {
+		return nil, err
+	}
+
+	// Skip the single white-space character following ID.
+	s.pos++
+
+	start := s.pos
+
+	// The image data is terminated by EI surrounded by whitespace.
+	for i := start; i+1 < len(s.b); i++ {
+		if s.b[i] == 'E' && s.b[i+1] == 'I' && i > start && isWhitespace(s.b[i-1]) &&
+			(i+2 == len(s.b) || isWhitespace(s.b[i+2]) || isDelimiter(s.b[i+2])) {
+			s.pos = i + 2
+			return &InlineImage{Dict: d, Data: s.b[start:i]}, nil
+		}
+	}
+
+	return nil, errors.New("content: unterminated inline image")
+}
+
+func number(s string) (Object, bool) {
+
+	if i, err := strconv.Atoi(s); err == nil {
+		return Integer(i), true
+	}
+
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return Real(f), true
+	}
+
+	return nil, false
+}
+
+// next returns the next token and io.EOF at the end of the content.
+func (s *scanner) next() (token, error) {
+
+	s.skipWhitespaceAndComments()
+
+	if s.pos >= len(s.b) {
+		return token{}, io.EOF
+	}
+
+	var (
+		o   Object
+		err error
+	)
+
+	switch c := s.b[s.pos]; c {
+
+	case '(':
+		o, err = s.literalString()
+
+	case '<':
+		if s.pos+1 < len(s.b) && s.b[s.pos+1] == '<' {
+			s.pos += 2
+			o, err = s.dict(">>")
+		} else {
+			o, err = s.hexString()
+		}
+
+	case '[':
+		o, err = s.array()
+
+	case '/':
+		o = s.name()
+
+	case ')', '>', ']', '{', '}':
+		// Unbalanced delimiters and PostScript procedures are passed on as operators.
+		s.pos++
+		return token{op: string(c)}, nil
+
+	default:
+		r := s.regular()
+
+		switch r {
+		case "true":
+			o = Boolean(true)
+		case "false":
+			o = Boolean(false)
+		case "null":
+			o = Null{}
+		case "BI":
+			img, err := s.inlineImage()
+			return token{op: r, image: img}, err
+		default:
+			var ok bool
+			if o, ok = number(r); !ok {
+				return token{op: r}, nil
+			}
+		}
+	}
+
+	return token{obj: o}, err
+}
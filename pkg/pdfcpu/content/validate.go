@@ -0,0 +1,436 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package content
+
+import (
+	"github.com/pkg/errors"
+)
+
+// Resources provides the named resources available to a content stream.
+type Resources interface {
+	// Has returns true if the resource category (eg. "Font", "XObject") contains name.
+	Has(category, name string) bool
+}
+
+// Operand signatures:
+//
+// n ... number
+// N ... name
+// s ... string
+// a ... array
+// p ... name or dict (property list)
+// * ... color components: numbers optionally followed by a pattern name
+type operatorSpec struct {
+	operands string
+	text     bool   // only allowed within a text object.
+	resource string // the resource category for a name operand.
+}
+
+// The content stream operators as listed in Annex A of the PDF spec.
+var operators = map[string]operatorSpec{
+
+	// General graphics state
+	"w":  {operands: "n"},
+	"J":  {operands: "n"},
+	"j":  {operands: "n"},
+	"M":  {operands: "n"},
+	"d":  {operands: "an"},
+	"ri": {operands: "N"},
+	"i":  {operands: "n"},
+	"gs": {operands: "N", resource: "ExtGState"},
+
+	// Special graphics state
+	"q":  {},
+	"Q":  {},
+	"cm": {operands: "nnnnnn"},
+
+	// Path construction
+	"m":  {operands: "nn"},
+	"l":  {operands: "nn"},
+	"c":  {operands: "nnnnnn"},
+	"v":  {operands: "nnnn"},
+	"y":  {operands: "nnnn"},
+	"h":  {},
+	"re": {operands: "nnnn"},
+
+	// Path painting
+	"S":  {},
+	"s":  {},
+	"f":  {},
+	"F":  {},
+	"f*": {},
+	"B":  {},
+	"B*": {},
+	"b":  {},
+	"b*": {},
+	"n":  {},
+
+	// Clipping paths
+	"W":  {},
+	"W*": {},
+
+	// Text objects
+	"BT": {},
+	"ET": {},
+
+	// Text state
+	"Tc": {operands: "n"},
+	"Tw": {operands: "n"},
+	"Tz": {operands: "n"},
+	"TL": {operands: "n"},
+	"Tf": {operands: "Nn", resource: "Font"},
+	"Tr": {operands: "n"},
+	"Ts": {operands: "n"},
+
+	// Text positioning
+	"Td": {operands: "nn", text: true},
+	"TD": {operands: "nn", text: true},
+	"Tm": {operands: "nnnnnn", text: true},
+	"T*": {text: true},
+
+	// Text showing
+	"Tj": {operands: "s", text: true},
+	"TJ": {operands: "a", text: true},
+	"'":  {operands: "s", text: true},
+	"\"": {operands: "nns", text: true},
+
+	// Type 3 fonts
+	"d0": {operands: "nn"},
+	"d1": {operands: "nnnnnn"},
+
+	// Color
+	"CS":  {operands: "N", resource: "ColorSpace"},
+	"cs":  {operands: "N", resource: "ColorSpace"},
+	"SC":  {operands: "*"},
+	"SCN": {operands: "*", resource: "Pattern"},
+	"sc":  {operands: "*"},
+	"scn": {operands: "*", resource: "Pattern"},
+	"G":   {operands: "n"},
+	"g":   {operands: "n"},
+	"RG":  {operands: "nnn"},
+	"rg":  {operands: "nnn"},
+	"K":   {operands: "nnnn"},
+	"k":   {operands: "nnnn"},
+
+	// Shading patterns
+	"sh": {operands: "N", resource: "Shading"},
+
+	// Inline images
+	"BI": {},
+
+	// XObjects
+	"Do": {operands: "N", resource: "XObject"},
+
+	// Marked content
+	"MP":  {operands: "N"},
+	"DP":  {operands: "Np", resource: "Properties"},
+	"BMC": {operands: "N"},
+	"BDC": {operands: "Np", resource: "Properties"},
+	"EMC": {},
+
+	// Compatibility
+	"BX": {},
+	"EX": {},
+}
+
+// Color spaces that need no resource entry.
+var deviceColorSpaces = map[string]bool{
+	"DeviceGray": true, "DeviceRGB": true, "DeviceCMYK": true, "Pattern": true,
+	// Abbreviations used in inline images.
+	"G": true, "RGB": true, "CMYK": true,
+}
+
+func matchOperand(o Object, c byte) bool {
+
+	switch c {
+
+	case 'n':
+		_, ok := Number(o)
+		return ok
+
+	case 'N':
+		_, ok := o.(Name)
+		return ok
+
+	case 's':
+		_, ok := StringBytes(o)
+		return ok
+
+	case 'a':
+		_, ok := o.(Array)
+		return ok
+
+	case 'p':
+		switch o.(type) {
+		case Name, Dict:
+			return true
+		}
+
+	}
+
+	return false
+}
+
+// validateOperands checks number and types of the operands of op.
+func validateOperands(op *Operation, spec operatorSpec) error {
+
+	if spec.operands == "*" {
+		// Color components, the last one may be a pattern name.
+		for i, o := range op.Operands {
+			if _, ok := o.(Name); ok && i == len(op.Operands)-1 && op.Operator != "SC" && op.Operator != "sc" {
+				continue
+			}
+			if !matchOperand(o, 'n') {
+				return errors.Errorf("content: %s: invalid operand %s", op.Operator, o.PDFString())
+			}
+		}
+		if len(op.Operands) == 0 || len(op.Operands) > 33 {
+			return errors.Errorf("content: %s: invalid number of operands: %d", op.Operator, len(op.Operands))
+		}
+		return nil
+	}
+
+	if len(op.Operands) != len(spec.operands) {
+		return errors.Errorf("content: %s: expected %d operands, got %d", op.Operator, len(spec.operands), len(op.Operands))
+	}
+
+	for i, o := range op.Operands {
+		if !matchOperand(o, spec.operands[i]) {
+			return errors.Errorf("content: %s: invalid operand %s", op.Operator, o.PDFString())
+		}
+	}
+
+	if op.Operator == "TJ" {
+		for _, o := range op.Operands[0].(Array) {
+			if !matchOperand(o, 'n') && !matchOperand(o, 's') {
+				return errors.Errorf("content: TJ: invalid array element %s", o.PDFString())
+			}
+		}
+	}
+
+	return nil
+}
+
+// resourceName returns the name operand referring to a resource of op.
+func resourceName(op *Operation, spec operatorSpec) (string, bool) {
+
+	if spec.resource == "" || len(op.Operands) == 0 {
+		return "", false
+	}
+
+	var o Object
+
+	switch op.Operator {
+
+	case "DP", "BDC":
+		o = op.Operands[1]
+
+	case "SCN", "scn":
+		o = op.Operands[len(op.Operands)-1]
+
+	default:
+		o = op.Operands[0]
+
+	}
+
+	n, ok := o.(Name)
+	if !ok {
+		return "", false
+	}
+
+	if spec.resource == "ColorSpace" && deviceColorSpaces[string(n)] {
+		return "", false
+	}
+
+	return string(n), true
+}
+
+// inlineImageColorSpace returns the name of the color space resource used by an inline image.
+func inlineImageColorSpace(img *InlineImage) (string, bool) {
+
+	o, found := img.Dict["CS"]
+	if !found {
+		o = img.Dict["ColorSpace"]
+	}
+
+	// The base of an indexed color space may be a resource too.
+	if a, ok := o.(Array); ok && len(a) > 1 {
+		o = a[1]
+	}
+
+	n, ok := o.(Name)
+	if !ok || deviceColorSpaces[string(n)] {
+		return "", false
+	}
+
+	return string(n), true
+}
+
+// ResourceNames returns the names of all resources used by c by resource category (eg. "Font", "XObject").
+// Operators with invalid operands are ignored.
+func (c *Content) ResourceNames() map[string]map[string]bool {
+
+	m := map[string]map[string]bool{}
+
+	add := func(category, name string) {
+		if m[category] == nil {
+			m[category] = map[string]bool{}
+		}
+		m[category][name] = true
+	}
+
+	for _, op := range c.Operations {
+
+		if op.Operator == "BI" && op.Image != nil {
+			if n, ok := inlineImageColorSpace(op.Image); ok {
+				add("ColorSpace", n)
+			}
+			continue
+		}
+
+		spec, ok := operators[op.Operator]
+		if !ok || validateOperands(op, spec) != nil {
+			continue
+		}
+
+		if n, ok := resourceName(op, spec); ok {
+			add(spec.resource, n)
+		}
+	}
+
+	return m
+}
+
+// validator keeps track of the nesting of operators.
+type validator struct {
+	res        Resources
+	inText     bool // within BT/ET.
+	saveLevel  int  // the nesting level of q/Q.
+	markLevel  int  // the nesting level of BMC/BDC and EMC.
+	compatible int  // the nesting level of BX/EX.
+}
+
+func (v *validator) validateNesting(op *Operation) error {
+
+	switch op.Operator {
+
+	case "BT":
+		if v.inText {
+			return errors.New("content: BT: nested text object")
+		}
+		v.inText = true
+
+	case "ET":
+		if !v.inText {
+			return errors.New("content: ET: missing BT")
+		}
+		v.inText = false
+
+	case "q":
+		v.saveLevel++
+
+	case "Q":
+		if v.saveLevel == 0 {
+			return errors.New("content: Q: missing q")
+		}
+		v.saveLevel--
+
+	case "BMC", "BDC":
+		v.markLevel++
+
+	case "EMC":
+		if v.markLevel == 0 {
+			return errors.New("content: EMC: missing BMC/BDC")
+		}
+		v.markLevel--
+
+	case "BX":
+		v.compatible++
+
+	case "EX":
+		if v.compatible == 0 {
+			return errors.New("content: EX: missing BX")
+		}
+		v.compatible--
+
+	}
+
+	return nil
+}
+
+func (v *validator) validateOperation(op *Operation) error {
+
+	spec, ok := operators[op.Operator]
+	if !ok {
+		if v.compatible > 0 {
+			// Unknown operators are ignored within a compatibility section.
+			return nil
+		}
+		return errors.Errorf("content: unknown operator %s", op.Operator)
+	}
+
+	err := validateOperands(op, spec)
+	if err != nil {
+		return err
+	}
+
+	if spec.text && !v.inText {
+		return errors.Errorf("content: %s: outside of text object", op.Operator)
+	}
+
+	err = v.validateNesting(op)
+	if err != nil {
+		return err
+	}
+
+	if n, ok := resourceName(op, spec); ok && (v.res == nil || !v.res.Has(spec.resource, n)) {
+		return errors.Errorf("content: %s: missing resource %s/%s", op.Operator, spec.resource, n)
+	}
+
+	if op.Operator == "BI" && op.Image == nil {
+		return errors.New("content: BI: missing inline image")
+	}
+
+	return nil
+}
+
+// Validate checks operators, their operands and their nesting and ensures that all named resources are available.
+func (c *Content) Validate(res Resources) error {
+
+	v := &validator{res: res}
+
+	for _, op := range c.Operations {
+		err := v.validateOperation(op)
+		if err != nil {
+			return err
+		}
+	}
+
+	if v.inText {
+		return errors.New("content: missing ET")
+	}
+
+	if v.saveLevel > 0 {
+		return errors.New("content: unbalanced q/Q")
+	}
+
+	if v.markLevel > 0 {
+		return errors.New("content: missing EMC")
+	}
+
+	return nil
+}
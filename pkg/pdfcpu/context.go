@@ -19,11 +19,13 @@ package pdfcpu
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"os"
 	"sort"
 	"strings"
 
 	"github.com/hhrutter/pdfcpu/pkg/log"
+	"github.com/pkg/errors"
 )
 
 // PDFContext represents the context for processing PDF files.
@@ -38,19 +40,30 @@ type PDFContext struct {
 // NewPDFContext initializes a new PDFContext.
 func NewPDFContext(fileName string, file *os.File, config *Configuration) (*PDFContext, error) {
 
-	if config == nil {
-		config = NewDefaultConfiguration()
-	}
-
 	fileInfo, err := file.Stat()
 	if err != nil {
 		return nil, err
 	}
 
+	return NewPDFContextFromReadSeeker(file, fileName, fileInfo.Size(), config)
+}
+
+// NewPDFContextFromReadSeeker initializes a new PDFContext for a PDF of fileSize bytes read from rs.
+// fileName is optional and only used for logging and stats.
+func NewPDFContextFromReadSeeker(rs io.ReadSeeker, fileName string, fileSize int64, config *Configuration) (*PDFContext, error) {
+
+	if rs == nil {
+		return nil, errors.New("NewPDFContextFromReadSeeker: missing io.ReadSeeker")
+	}
+
+	if config == nil {
+		config = NewDefaultConfiguration()
+	}
+
 	ctx := &PDFContext{
 		config,
 		newXRefTable(config.ValidationMode),
-		newReadContext(fileName, file, fileInfo.Size()),
+		newReadContext(rs, fileName, fileSize),
 		newOptimizationContext(),
 		NewWriteContext(config.Eol),
 	}
@@ -155,10 +168,12 @@ func (ctx *PDFContext) String() string {
 // ReadContext represents the context for reading a PDF file.
 type ReadContext struct {
 
-	// The PDF-File which gets processed.
-	FileName string
-	File     *os.File
-	FileSize int64
+	// The PDF which gets processed.
+	FileName   string        // optional, may be empty for PDFs not read from a file.
+	ReadSeeker io.ReadSeeker // the PDF source.
+	FileSize   int64
+	XRefOffset int64 // offset of the last xref section, see startxref.
+	EolAtEOF   bool  // the file ends with an end-of-line marker.
 
 	BinaryTotalSize     int64 // total stream data
 	BinaryImageSize     int64 // total image stream data
@@ -176,10 +191,10 @@ type ReadContext struct {
 	XRefStreams      IntSet // All object numbers of any xref streams found.
 }
 
-func newReadContext(fileName string, file *os.File, fileSize int64) *ReadContext {
+func newReadContext(rs io.ReadSeeker, fileName string, fileSize int64) *ReadContext {
 	return &ReadContext{
 		FileName:      fileName,
-		File:          file,
+		ReadSeeker:    rs,
 		FileSize:      fileSize,
 		ObjectStreams: IntSet{},
 		XRefStreams:   IntSet{},
@@ -275,12 +290,14 @@ type OptimizationContext struct {
 	Fonts             map[string][]int
 	DuplicateFontObjs IntSet
 	DuplicateFonts    map[int]*PDFDict
+	SubsetFonts       map[int]*SubsetFont // by object number of the font program.
 
 	// Image section
 	PageImages         []IntSet
 	ImageObjects       map[int]*ImageObject
 	DuplicateImageObjs IntSet
 	DuplicateImages    map[int]*PDFStreamDict
+	ResampledImages    map[int]*ResampledImage
 
 	DuplicateInfoObjects IntSet // Possible result of manual info dict modification.
 
@@ -293,9 +310,11 @@ func newOptimizationContext() *OptimizationContext {
 		Fonts:                map[string][]int{},
 		DuplicateFontObjs:    IntSet{},
 		DuplicateFonts:       map[int]*PDFDict{},
+		SubsetFonts:          map[int]*SubsetFont{},
 		ImageObjects:         map[int]*ImageObject{},
 		DuplicateImageObjs:   IntSet{},
 		DuplicateImages:      map[int]*PDFStreamDict{},
+		ResampledImages:      map[int]*ResampledImage{},
 		DuplicateInfoObjects: IntSet{},
 	}
 }
@@ -467,6 +486,29 @@ func (oc *OptimizationContext) collectFontInfo(logStr []string) []string {
 		logStr = append(logStr, strings.Join(f, ","))
 	}
 
+	// Log any subset fonts.
+	if len(oc.SubsetFonts) > 0 {
+
+		logStr = append(logStr, fmt.Sprintf("\n\nSubset Fonts:\n"))
+		logStr = append(logStr, "obj     Fontname                               glyphs bytes saved\n")
+
+		var objectNumbers []int
+		for k := range oc.SubsetFonts {
+			objectNumbers = append(objectNumbers, k)
+		}
+		sort.Ints(objectNumbers)
+
+		var saved int64
+
+		for _, i := range objectNumbers {
+			sf := oc.SubsetFonts[i]
+			logStr = append(logStr, fmt.Sprintf("#%-6d %-38s %-6d %d\n", i, sf.Name, sf.Glyphs, sf.Saved()))
+			saved += sf.Saved()
+		}
+
+		logStr = append(logStr, fmt.Sprintf("%d bytes saved\n", saved))
+	}
+
 	return append(logStr, "\n")
 }
 
@@ -537,6 +579,30 @@ func (oc *OptimizationContext) collectImageInfo(logStr []string) []string {
 		logStr = append(logStr, strings.Join(f, ","))
 	}
 
+	// Log any resampled images.
+	if len(oc.ResampledImages) > 0 {
+
+		logStr = append(logStr, fmt.Sprintf("\n\nResampled Images:\n"))
+		logStr = append(logStr, "obj     dpi   pixels      resampled   filter      bytes saved\n")
+
+		var objectNumbers []int
+		for k := range oc.ResampledImages {
+			objectNumbers = append(objectNumbers, k)
+		}
+		sort.Ints(objectNumbers)
+
+		var saved int64
+
+		for _, i := range objectNumbers {
+			ri := oc.ResampledImages[i]
+			logStr = append(logStr, fmt.Sprintf("#%-6d %-5.0f %-11s %-11s %-11s %d\n",
+				i, ri.DPI, fmt.Sprintf("%dx%d", ri.Width, ri.Height), fmt.Sprintf("%dx%d", ri.NewWidth, ri.NewHeight), ri.Filter, ri.Saved()))
+			saved += ri.Saved()
+		}
+
+		logStr = append(logStr, fmt.Sprintf("%d bytes saved\n", saved))
+	}
+
 	return logStr
 }
 
@@ -544,6 +610,7 @@ func (oc *OptimizationContext) collectImageInfo(logStr []string) []string {
 type WriteContext struct {
 
 	// The PDF-File which gets generated.
+	// DirName and FileName are only used when writing to a file.
 	DirName  string
 	FileName string
 	FileSize int64
@@ -33,6 +33,7 @@ func createXRefTableWithRootDict() (*XRefTable, error) {
 		Table: map[int]*XRefTableEntry{},
 		Names: map[string]*Node{},
 		Stats: NewPDFStats(),
+		Dirty: IntSet{},
 	}
 
 	xRefTable.Table[0] = NewFreeHeadXRefTableEntry()
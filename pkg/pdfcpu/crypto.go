@@ -25,6 +25,8 @@ import (
 	"crypto/md5"
 	"crypto/rand"
 	"crypto/rc4"
+	"crypto/sha256"
+	"crypto/sha512"
 	"encoding/hex"
 	"fmt"
 	"io"
@@ -67,7 +69,7 @@ var (
 )
 
 // NewEncryptDict creates a new EncryptDict using the standard security handler.
-func newEncryptDict(needAES, need128BitKey bool, permissions int16) *PDFDict {
+func newEncryptDict(needAES bool, keyLength int, permissions int16) *PDFDict {
 
 	d := NewPDFDict()
 
@@ -75,11 +77,16 @@ func newEncryptDict(needAES, need128BitKey bool, permissions int16) *PDFDict {
 
 	d.Insert("Filter", PDFName("Standard"))
 
-	if need128BitKey {
+	switch keyLength {
+	case 256:
+		d.Insert("Length", PDFInteger(256))
+		d.Insert("R", PDFInteger(6))
+		d.Insert("V", PDFInteger(5))
+	case 128:
 		d.Insert("Length", PDFInteger(128))
 		d.Insert("R", PDFInteger(4))
 		d.Insert("V", PDFInteger(4))
-	} else {
+	default:
 		d.Insert("R", PDFInteger(2))
 		d.Insert("V", PDFInteger(1))
 	}
@@ -93,15 +100,21 @@ func newEncryptDict(needAES, need128BitKey bool, permissions int16) *PDFDict {
 	d1 := NewPDFDict()
 	d1.Insert("AuthEvent", PDFName("DocOpen"))
 
-	if needAES {
+	switch {
+	case keyLength == 256:
+		d1.Insert("CFM", PDFName("AESV3"))
+	case needAES:
 		d1.Insert("CFM", PDFName("AESV2"))
-	} else {
+	default:
 		d1.Insert("CFM", PDFName("V2"))
 	}
 
-	if need128BitKey {
+	switch keyLength {
+	case 256:
+		d1.Insert("Length", PDFInteger(32))
+	case 128:
 		d1.Insert("Length", PDFInteger(16))
-	} else {
+	default:
 		d1.Insert("Length", PDFInteger(5))
 	}
 
@@ -110,6 +123,18 @@ func newEncryptDict(needAES, need128BitKey bool, permissions int16) *PDFDict {
 
 	d.Insert("CF", d2)
 
+	if keyLength == 256 {
+		// Placeholders for the entries of security handler revision 6.
+		h := hex.EncodeToString(make([]byte, 48))
+		d.Insert("U", PDFHexLiteral(h))
+		d.Insert("O", PDFHexLiteral(h))
+		h = hex.EncodeToString(make([]byte, 32))
+		d.Insert("UE", PDFHexLiteral(h))
+		d.Insert("OE", PDFHexLiteral(h))
+		d.Insert("Perms", PDFHexLiteral(hex.EncodeToString(make([]byte, 16))))
+		return &d
+	}
+
 	h := "0000000000000000000000000000000000000000000000000000000000000000"
 	d.Insert("U", PDFHexLiteral(h))
 	d.Insert("O", PDFHexLiteral(h))
@@ -171,6 +196,10 @@ func encKey(userpw string, e *Enc) (key []byte) {
 // ValidateUserPassword validates the user password aka document open password.
 func validateUserPassword(ctx *PDFContext) (ok bool, key []byte, err error) {
 
+	if ctx.E.R >= 5 {
+		return validateUserPasswordAES256(ctx)
+	}
+
 	// Alg.4/5 p63
 	// 4a/5a create encryption key using Alg.2 p61
 
@@ -342,6 +371,10 @@ func validateOwnerPassword(ctx *PDFContext) (ok bool, k []byte, err error) {
 
 	e := ctx.E
 
+	if e.R >= 5 {
+		return validateOwnerPasswordAES256(ctx)
+	}
+
 	// 7a: Alg.3 p62 a-d
 	key := key(ownerpw, userpw, e.R, e.L)
 
@@ -391,11 +424,278 @@ func validateOwnerPassword(ctx *PDFContext) (ok bool, k []byte, err error) {
 	return ok, k, err
 }
 
+// passwordAES256 prepares a password for security handler revisions 5 and 6.
+// Passwords are expected to be UTF-8 encoded, SASLprep normalization is not applied.
+func passwordAES256(pw string) []byte {
+
+	b := []byte(pw)
+	if len(b) > 127 {
+		b = b[:127]
+	}
+
+	return b
+}
+
+// hashAES256 computes a password hash using SHA-256 (R5) or Alg.2.B (R6).
+func hashAES256(pw, salt, udata []byte, r int) ([]byte, error) {
+
+	h := sha256.New()
+	h.Write(pw)
+	h.Write(salt)
+	h.Write(udata)
+	k := h.Sum(nil)
+
+	if r < 6 {
+		return k, nil
+	}
+
+	for i := 0; ; i++ {
+
+		// a
+		k1 := make([]byte, 0, 64*(len(pw)+len(k)+len(udata)))
+		for j := 0; j < 64; j++ {
+			k1 = append(k1, pw...)
+			k1 = append(k1, k...)
+			k1 = append(k1, udata...)
+		}
+
+		// b
+		cb, err := aes.NewCipher(k[:16])
+		if err != nil {
+			return nil, err
+		}
+		e := make([]byte, len(k1))
+		cipher.NewCBCEncrypter(cb, k[16:32]).CryptBlocks(e, k1)
+
+		// c
+		var sum int
+		for _, b := range e[:16] {
+			sum += int(b)
+		}
+
+		// d
+		switch sum % 3 {
+		case 0:
+			s := sha256.Sum256(e)
+			k = s[:]
+		case 1:
+			s := sha512.Sum384(e)
+			k = s[:]
+		case 2:
+			s := sha512.Sum512(e)
+			k = s[:]
+		}
+
+		// e
+		if i >= 63 && int(e[len(e)-1]) <= i-31 {
+			break
+		}
+	}
+
+	return k[:32], nil
+}
+
+// applyAES256Key encrypts or decrypts a file encryption key using AES-256 in CBC mode
+// with a zero initialization vector and no padding.
+func applyAES256Key(b, key []byte, encrypt bool) ([]byte, error) {
+
+	cb, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	iv := make([]byte, aes.BlockSize)
+	data := make([]byte, len(b))
+
+	if encrypt {
+		cipher.NewCBCEncrypter(cb, iv).CryptBlocks(data, b)
+	} else {
+		cipher.NewCBCDecrypter(cb, iv).CryptBlocks(data, b)
+	}
+
+	return data, nil
+}
+
+// permsAES256 calculates the encrypted Perms entry (Alg.10).
+func permsAES256(e *Enc, key []byte) ([]byte, error) {
+
+	b := make([]byte, 16)
+
+	q := uint32(e.P)
+	b[0], b[1], b[2], b[3] = byte(q), byte(q>>8), byte(q>>16), byte(q>>24)
+	b[4], b[5], b[6], b[7] = 0xFF, 0xFF, 0xFF, 0xFF
+
+	b[8] = 'F'
+	if e.Emd {
+		b[8] = 'T'
+	}
+
+	copy(b[9:12], "adb")
+
+	_, err := io.ReadFull(rand.Reader, b[12:])
+	if err != nil {
+		return nil, err
+	}
+
+	cb, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	cb.Encrypt(b, b)
+
+	return b, nil
+}
+
+// validatePermsAES256 checks the Perms entry against P (Alg.13).
+func validatePermsAES256(e *Enc, key []byte) error {
+
+	cb, err := aes.NewCipher(key)
+	if err != nil {
+		return err
+	}
+
+	b := make([]byte, 16)
+	cb.Decrypt(b, e.Perms)
+
+	if string(b[9:12]) != "adb" {
+		return errors.New("validatePermsAES256: invalid \"Perms\"")
+	}
+
+	p := int32(uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16 | uint32(b[3])<<24)
+	if int(p) != e.P {
+		return errors.New("validatePermsAES256: \"Perms\" does not match \"P\"")
+	}
+
+	return nil
+}
+
+// validateUserPasswordAES256 validates the user password for security handler revisions 5 and 6 (Alg.11).
+func validateUserPasswordAES256(ctx *PDFContext) (ok bool, key []byte, err error) {
+
+	e := ctx.E
+	pw := passwordAES256(ctx.UserPW)
+
+	h, err := hashAES256(pw, e.U[32:40], nil, e.R)
+	if err != nil {
+		return false, nil, err
+	}
+
+	if !bytes.Equal(h, e.U[:32]) {
+		return false, nil, nil
+	}
+
+	// Alg.2.A e
+	k, err := hashAES256(pw, e.U[40:48], nil, e.R)
+	if err != nil {
+		return false, nil, err
+	}
+
+	key, err = applyAES256Key(e.UE, k, false)
+	if err != nil {
+		return false, nil, err
+	}
+
+	return true, key, validatePermsAES256(e, key)
+}
+
+// validateOwnerPasswordAES256 validates the owner password for security handler revisions 5 and 6 (Alg.12).
+func validateOwnerPasswordAES256(ctx *PDFContext) (ok bool, key []byte, err error) {
+
+	e := ctx.E
+
+	pw := passwordAES256(ctx.OwnerPW)
+	if len(pw) == 0 {
+		pw = passwordAES256(ctx.UserPW)
+	}
+
+	h, err := hashAES256(pw, e.O[32:40], e.U, e.R)
+	if err != nil {
+		return false, nil, err
+	}
+
+	if !bytes.Equal(h, e.O[:32]) {
+		return false, nil, nil
+	}
+
+	// Alg.2.A d
+	k, err := hashAES256(pw, e.O[40:48], e.U, e.R)
+	if err != nil {
+		return false, nil, err
+	}
+
+	key, err = applyAES256Key(e.OE, k, false)
+	if err != nil {
+		return false, nil, err
+	}
+
+	return true, key, validatePermsAES256(e, key)
+}
+
+// setupAES256 calculates U, UE, O, OE and Perms for a given file encryption key (Alg.8, 9, 10).
+func setupAES256(ctx *PDFContext, key []byte) error {
+
+	e := ctx.E
+
+	// validation salt and key salt for U and O.
+	salts := make([]byte, 32)
+	_, err := io.ReadFull(rand.Reader, salts)
+	if err != nil {
+		return err
+	}
+
+	// Alg.8
+	pw := passwordAES256(ctx.UserPW)
+
+	h, err := hashAES256(pw, salts[:8], nil, e.R)
+	if err != nil {
+		return err
+	}
+	e.U = append(h, salts[:16]...)
+
+	k, err := hashAES256(pw, salts[8:16], nil, e.R)
+	if err != nil {
+		return err
+	}
+
+	e.UE, err = applyAES256Key(key, k, true)
+	if err != nil {
+		return err
+	}
+
+	// Alg.9
+	pw = passwordAES256(ctx.OwnerPW)
+	if len(pw) == 0 {
+		pw = passwordAES256(ctx.UserPW)
+	}
+
+	h, err = hashAES256(pw, salts[16:24], e.U, e.R)
+	if err != nil {
+		return err
+	}
+	e.O = append(h, salts[16:]...)
+
+	k, err = hashAES256(pw, salts[24:], e.U, e.R)
+	if err != nil {
+		return err
+	}
+
+	e.OE, err = applyAES256Key(key, k, true)
+	if err != nil {
+		return err
+	}
+
+	// Alg.10
+	e.Perms, err = permsAES256(e, key)
+
+	return err
+}
+
 // SupportedCFEntry returns true if all entries found are supported.
 func supportedCFEntry(d *PDFDict) (bool, error) {
 
 	cfm := d.NameEntry("CFM")
-	if cfm != nil && *cfm != "V2" && *cfm != "AESV2" {
+	if cfm != nil && *cfm != "V2" && *cfm != "AESV2" && *cfm != "AESV3" {
 		return false, errors.New("supportedCFEntry: invalid entry \"CFM\"")
 	}
 
@@ -405,6 +705,15 @@ func supportedCFEntry(d *PDFDict) (bool, error) {
 	}
 
 	l := d.IntEntry("Length")
+
+	if cfm != nil && *cfm == "AESV3" {
+		// Some writers express the key length in bits.
+		if l != nil && *l != 32 && *l != 256 {
+			return false, errors.New("supportedCFEntry: invalid entry \"Length\"")
+		}
+		return true, nil
+	}
+
 	if l != nil && (*l < 8 || *l > 128 || *l%8 > 1) {
 		return false, errors.New("supportedCFEntry: invalid entry \"Length\"")
 	}
@@ -509,8 +818,8 @@ func getV(dict *PDFDict) (*int, error) {
 
 	v := dict.IntEntry("V")
 
-	if v == nil || (*v != 1 && *v != 2 && *v != 4) {
-		return nil, errors.Errorf("getV: \"V\" must be one of 1,2,4")
+	if v == nil || (*v != 1 && *v != 2 && *v != 4 && *v != 5) {
+		return nil, errors.Errorf("getV: \"V\" must be one of 1,2,4,5")
 	}
 
 	return v, nil
@@ -541,8 +850,8 @@ func checkV(ctx *PDFContext, dict *PDFDict) (*int, error) {
 		return nil, err
 	}
 
-	// Right now we support only 4
-	if *v != 4 {
+	// Crypt filters are used for 4 and 5 only.
+	if *v < 4 {
 		return v, nil
 	}
 
@@ -597,6 +906,10 @@ func length(dict *PDFDict) (int, error) {
 		return 40, nil
 	}
 
+	if *l == 256 {
+		return *l, nil
+	}
+
 	if *l < 40 || *l > 128 || *l%8 > 0 {
 		return 0, errors.Errorf("length: \"Length\" %d not supported\n", *l)
 	}
@@ -607,8 +920,8 @@ func length(dict *PDFDict) (int, error) {
 func getR(dict *PDFDict) (int, error) {
 
 	r := dict.IntEntry("R")
-	if r == nil || (*r != 2 && *r != 3 && *r != 4) {
-		return 0, errors.New("getR: \"R\" must be 2,3,4")
+	if r == nil || (*r < 2 || *r > 6) {
+		return 0, errors.New("getR: \"R\" must be 2,3,4,5,6")
 	}
 
 	return *r, nil
@@ -620,6 +933,9 @@ func supportedEncryption(ctx *PDFContext, dict *PDFDict) (*Enc, error) {
 
 	// Filter
 	filter := dict.NameEntry("Filter")
+	if filter != nil && *filter == "Adobe.PubSec" {
+		return supportedPubSecEncryption(ctx, dict)
+	}
 	if filter == nil || *filter != "Standard" {
 		return nil, errors.New("unsupported encryption: filter must be \"Standard\"")
 	}
@@ -647,12 +963,18 @@ func supportedEncryption(ctx *PDFContext, dict *PDFDict) (*Enc, error) {
 		return nil, err
 	}
 
+	// O, U: 32 bytes for R 2,3,4 and 48 bytes for R 5,6.
+	l1 := 32
+	if r >= 5 {
+		l1 = 48
+	}
+
 	// O
 	o, err := dict.StringEntryBytes("O")
 	if err != nil {
 		return nil, err
 	}
-	if o == nil || len(o) != 32 {
+	if o == nil || len(o) < l1 || (r < 5 && len(o) != l1) {
 		return nil, errors.New("unsupported encryption: required entry \"O\" missing or invalid")
 	}
 
@@ -661,7 +983,7 @@ func supportedEncryption(ctx *PDFContext, dict *PDFDict) (*Enc, error) {
 	if err != nil {
 		return nil, err
 	}
-	if u == nil || len(u) != 32 {
+	if u == nil || len(u) < l1 || (r < 5 && len(u) != l1) {
 		return nil, errors.Errorf("unsupported encryption: required entry \"U\" missing or invalid %d", len(u))
 	}
 
@@ -678,13 +1000,44 @@ func supportedEncryption(ctx *PDFContext, dict *PDFDict) (*Enc, error) {
 		encMeta = *emd
 	}
 
-	return &Enc{O: o, U: u, L: l, P: *p, R: r, V: *v, Emd: encMeta}, nil
+	enc := &Enc{O: o[:l1], U: u[:l1], L: l, P: *p, R: r, V: *v, Emd: encMeta}
+
+	if r < 5 {
+		return enc, nil
+	}
+
+	// OE, UE, Perms
+	for _, e := range []struct {
+		k string
+		l int
+		b *[]byte
+	}{
+		{"OE", 32, &enc.OE},
+		{"UE", 32, &enc.UE},
+		{"Perms", 16, &enc.Perms},
+	} {
+		b, err := dict.StringEntryBytes(e.k)
+		if err != nil {
+			return nil, err
+		}
+		if len(b) != e.l {
+			return nil, errors.Errorf("unsupported encryption: required entry \"%s\" missing or invalid", e.k)
+		}
+		*e.b = b
+	}
+
+	return enc, nil
 }
 
 func decryptKey(objNumber, generation int, key []byte, aes bool) []byte {
 
 	log.Debug.Printf("decryptKey: obj:%d gen:%d key:%x aes:%t\n", objNumber, generation, key, aes)
 
+	// AESV3 (security handler revisions 5 and 6) uses the 256 bit file encryption key as is.
+	if len(key) == 32 {
+		return key
+	}
+
 	m := md5.New()
 
 	nr := uint32(objNumber)
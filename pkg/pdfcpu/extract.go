@@ -25,8 +25,8 @@ import (
 )
 
 // ExtractImageData extracts image data for objNr.
-// Supported imgTypes: FlateDecode, DCTDecode, JPXDecode
-// TODO: Implementation and usage of these filters: DCTDecode and JPXDecode.
+// Supported imgTypes: FlateDecode, CCITTFaxDecode, JBIG2Decode, DCTDecode, JPXDecode
+// TODO: Implementation and usage of the JPXDecode filter.
 func ExtractImageData(ctx *PDFContext, objNr int) (*ImageObject, error) {
 
 	imageObj := ctx.Optimize.ImageObjects[objNr]
@@ -50,8 +50,8 @@ func ExtractImageData(ctx *PDFContext, objNr int) (*ImageObject, error) {
 		return nil, nil
 	}
 
-	// Ignore imageMasks.
-	if im := imageDict.BooleanEntry("ImageMask"); im != nil && *im {
+	// Ignore imageMasks except for scanned pages.
+	if im := imageDict.BooleanEntry("ImageMask"); im != nil && *im && fpl[0].Name != filter.CCITTFax && fpl[0].Name != filter.JBIG2 {
 		log.Info.Printf("extractImageData: ignore obj# %d, imageMask\n", objNr)
 		return nil, nil
 	}
@@ -84,8 +84,20 @@ func ExtractImageData(ctx *PDFContext, objNr int) (*ImageObject, error) {
 	case filter.JPX:
 		//imageObj.Extension = "jpx"
 
-	//case filter.CCITTFax:
-	// use 	T6.pdf
+	case filter.CCITTFax:
+		// Write a bilevel PNG if possible, else a CCITT compressed TIFF.
+		err := decodeStream(imageDict)
+		if err != nil {
+			log.Info.Printf("extractImageData: obj# %d: %v\n", objNr, err)
+		}
+
+	case filter.JBIG2:
+		// Write a bilevel PNG.
+		err := decodeImageStream(ctx.XRefTable, imageDict)
+		if err != nil {
+			log.Info.Printf("extractImageData: ignore obj# %d: %v\n", objNr, err)
+			return nil, nil
+		}
 
 	default:
 		log.Debug.Printf("extractImageData: ignore obj# %d filter %s unsupported\n", objNr, filters)
@@ -26,6 +26,7 @@ import (
 
 	"github.com/hhrutter/pdfcpu/pkg/filter"
 	"github.com/hhrutter/pdfcpu/pkg/log"
+	"github.com/pkg/errors"
 )
 
 func parmsForFilter(d *PDFDict) map[string]int {
@@ -38,11 +39,19 @@ func parmsForFilter(d *PDFDict) map[string]int {
 
 	for k, v := range d.Dict {
 
-		i, ok := v.(PDFInteger)
-		if !ok {
-			continue
+		switch o := v.(type) {
+
+		case PDFInteger:
+			m[k] = o.Value()
+
+		case PDFBoolean:
+			// eg. CCITTFaxDecode: BlackIs1, EncodedByteAlign
+			m[k] = 0
+			if o.Value() {
+				m[k] = 1
+			}
+
 		}
-		m[k] = i.Value()
 	}
 
 	return m
@@ -83,6 +92,10 @@ func encodeStream(sd *PDFStreamDict) error {
 		// make parms map[string]int
 		parms := parmsForFilter(f.DecodeParms)
 
+		if f.Name == filter.DCT {
+			dctEncodeParms(sd, f, parms)
+		}
+
 		fi, err := filter.NewFilter(f.Name, parms)
 		if err != nil {
 			return err
@@ -111,6 +124,24 @@ func encodeStream(sd *PDFStreamDict) error {
 	return nil
 }
 
+// dctEncodeParms adds the image dimensions needed for DCT encoding of an image stream dict to parms.
+func dctEncodeParms(sd *PDFStreamDict, f PDFFilter, parms map[string]int) {
+
+	w, h := sd.IntEntry("Width"), sd.IntEntry("Height")
+	if w == nil || h == nil || *w <= 0 || *h <= 0 {
+		return
+	}
+
+	parms["Columns"] = *w
+	parms["Rows"] = *h
+	parms["Colors"] = len(sd.Content) / (*w * *h)
+
+	// 3 color components are always encoded using the YCbCr color transform.
+	if parms["Colors"] == 3 && f.DecodeParms != nil {
+		f.DecodeParms.Delete("ColorTransform")
+	}
+}
+
 // decodeStream decodes streamDict data by applying its filter pipeline.
 func decodeStream(sd *PDFStreamDict) error {
 
@@ -170,3 +201,79 @@ func decodeStream(sd *PDFStreamDict) error {
 
 	return nil
 }
+
+// jbig2Globals returns the decoded JBIG2Globals stream referenced by the decode parms of a JBIG2Decode filter.
+func jbig2Globals(xRefTable *XRefTable, f PDFFilter) ([]byte, error) {
+
+	if f.DecodeParms == nil {
+		return nil, nil
+	}
+
+	o, found := f.DecodeParms.Find("JBIG2Globals")
+	if !found {
+		return nil, nil
+	}
+
+	sd, err := xRefTable.DereferenceStreamDict(o)
+	if err != nil || sd == nil {
+		return nil, errors.New("jbig2Globals: corrupt JBIG2Globals stream")
+	}
+
+	if sd.FilterPipeline == nil {
+		return sd.Raw, nil
+	}
+
+	err = decodeStream(sd)
+	if err != nil {
+		return nil, err
+	}
+
+	return sd.Content, nil
+}
+
+// decodeImageStream decodes image stream dict data by applying its filter pipeline.
+// The segments of a JBIG2Globals stream are prepended to the data of a JBIG2 encoded image.
+func decodeImageStream(xRefTable *XRefTable, sd *PDFStreamDict) error {
+
+	fpl := sd.FilterPipeline
+
+	if sd.Content != nil || len(fpl) == 0 || fpl[len(fpl)-1].Name != filter.JBIG2 {
+		return decodeStream(sd)
+	}
+
+	f := fpl[len(fpl)-1]
+
+	globals, err := jbig2Globals(xRefTable, f)
+	if err != nil {
+		return err
+	}
+
+	data := sd.Raw
+
+	if len(fpl) > 1 {
+		// Apply the preceding filters.
+		sd1 := PDFStreamDict{Raw: sd.Raw, FilterPipeline: fpl[:len(fpl)-1]}
+		err = decodeStream(&sd1)
+		if err != nil {
+			return err
+		}
+		data = sd1.Content
+	}
+
+	fi, err := filter.NewFilter(f.Name, parmsForFilter(f.DecodeParms))
+	if err != nil {
+		return err
+	}
+
+	b := make([]byte, 0, len(globals)+len(data))
+	b = append(append(b, globals...), data...)
+
+	c, err := fi.Decode(bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+
+	sd.Content = c.Bytes()
+
+	return nil
+}
@@ -0,0 +1,925 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"bytes"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/hhrutter/pdfcpu/pkg/filter"
+	"github.com/hhrutter/pdfcpu/pkg/log"
+	"github.com/pkg/errors"
+)
+
+// SubsetFont represents the result of subsetting an embedded font program during optimization.
+type SubsetFont struct {
+	Name          string // the font name including the subset tag.
+	Glyphs        int    // the number of glyphs kept.
+	Size, NewSize int64  // stream length in bytes.
+}
+
+// Saved returns the number of bytes saved by subsetting.
+func (sf SubsetFont) Saved() int64 {
+	return sf.Size - sf.NewSize
+}
+
+// The kinds of embedded font programs eligible for subsetting.
+const (
+	trueTypeProgram = "FontFile2"
+	type1CProgram   = "Type1C"
+	cidCFFProgram   = "CIDFontType0C"
+)
+
+// Unicode ligatures for glyph names decomposed by glyphText.
+var ligatureRunes = map[string]rune{
+	"ff": 0xFB00, "fi": 0xFB01, "fl": 0xFB02, "ffi": 0xFB03, "ffl": 0xFB04,
+}
+
+// fontUse represents a font dict referring to an embedded font program and the char codes shown using it.
+type fontUse struct {
+	objNr   int
+	dict    *PDFDict
+	cidFont *PDFDict // the descendant font of a Type0 font.
+	fd      *PDFDict
+	font    *textFont
+	codes   map[string]bool
+	cids    map[int]int // glyph indices by CID of CID fonts.
+}
+
+// fontProgram represents an embedded font program and all font dicts referring to it.
+type fontProgram struct {
+	kind   string
+	uses   []*fontUse
+	unsafe bool // true if the glyphs needed cannot be determined.
+}
+
+// shownCodes returns the char codes shown by all content streams for each font.
+// ok is false if text is shown using a direct font dict.
+func shownCodes(ctx *PDFContext) (codes map[int]map[string]bool, fonts map[int]*textFont, ok bool, err error) {
+
+	codes = map[int]map[string]bool{}
+	direct := false
+
+	te := newTextExtractor(ctx.XRefTable)
+
+	te.shown = func(f *textFont, code []byte) {
+		if f.objNr == 0 {
+			direct = true
+			return
+		}
+		m, found := codes[f.objNr]
+		if !found {
+			m = map[string]bool{}
+			codes[f.objNr] = m
+		}
+		m[string(code)] = true
+	}
+
+	process := func(b []byte, resources *PDFDict) error {
+		te.ts = textState{ctm: identMatrix, hScale: 1}
+		te.stack, te.glyphs = nil, nil
+		te.tm, te.tlm = identMatrix, identMatrix
+		return te.processContent(b, resources)
+	}
+
+	for p := 1; p <= ctx.PageCount; p++ {
+
+		pageDict, inhPAttrs, err := ctx.PageDict(p)
+		if err != nil {
+			return nil, nil, false, err
+		}
+		if pageDict == nil {
+			continue
+		}
+
+		b, err := pageContent(ctx.XRefTable, pageDict)
+		if err != nil {
+			return nil, nil, false, err
+		}
+
+		if err = process(b, inhPAttrs.resources); err != nil {
+			return nil, nil, false, err
+		}
+	}
+
+	// Form XObjects, patterns and appearance streams not painted by page content
+	// as well as the glyph procedures of Type3 fonts.
+	for objNr, entry := range ctx.Table {
+
+		if entry.Free || entry.Object == nil {
+			continue
+		}
+
+		switch o := entry.Object.(type) {
+
+		case PDFStreamDict:
+			if st := o.Subtype(); st != nil && *st == "Image" {
+				continue
+			}
+			resources, err := ctx.DereferenceDict(o.Dict["Resources"])
+			if err != nil || resources == nil {
+				continue
+			}
+			if b := streamContent(ctx.XRefTable, *NewPDFIndirectRef(objNr, 0)); b != nil {
+				if err = process(b, resources); err != nil {
+					return nil, nil, false, err
+				}
+			}
+
+		case PDFDict:
+			if st := o.Subtype(); st == nil || *st != "Type3" {
+				continue
+			}
+			resources, err := ctx.DereferenceDict(o.Dict["Resources"])
+			if err != nil || resources == nil {
+				continue
+			}
+			charProcs, err := ctx.DereferenceDict(o.Dict["CharProcs"])
+			if err != nil || charProcs == nil {
+				continue
+			}
+			for _, o := range charProcs.Dict {
+				if b := streamContent(ctx.XRefTable, o); b != nil {
+					if err = process(b, resources); err != nil {
+						return nil, nil, false, err
+					}
+				}
+			}
+
+		}
+	}
+
+	return codes, te.fonts, !direct, nil
+}
+
+// formFonts returns the object numbers of the fonts of the AcroForm default resources.
+// These are used for generating field appearances and therefore must not be subset.
+func formFonts(ctx *PDFContext) IntSet {
+
+	m := IntSet{}
+
+	rootDict, err := ctx.Catalog()
+	if err != nil {
+		return m
+	}
+
+	acroForm, err := ctx.DereferenceDict(rootDict.Dict["AcroForm"])
+	if err != nil || acroForm == nil {
+		return m
+	}
+
+	dr, err := ctx.DereferenceDict(acroForm.Dict["DR"])
+	if err != nil || dr == nil {
+		return m
+	}
+
+	fonts, err := ctx.DereferenceDict(dr.Dict["Font"])
+	if err != nil || fonts == nil {
+		return m
+	}
+
+	for _, o := range fonts.Dict {
+		if indRef, ok := o.(PDFIndirectRef); ok {
+			m[indRef.ObjectNumber.Value()] = true
+		}
+	}
+
+	return m
+}
+
+// fontProgramRef returns the embedded font program of a font descriptor and its kind.
+// The kind is empty for font programs not eligible for subsetting.
+func fontProgramRef(xRefTable *XRefTable, fd *PDFDict) (*PDFIndirectRef, string) {
+
+	if indRef := fd.IndirectRefEntry("FontFile2"); indRef != nil {
+		return indRef, trueTypeProgram
+	}
+
+	if indRef := fd.IndirectRefEntry("FontFile3"); indRef != nil {
+		sd, err := xRefTable.DereferenceStreamDict(*indRef)
+		if err != nil || sd == nil {
+			return indRef, ""
+		}
+		if st := sd.Subtype(); st != nil && (*st == type1CProgram || *st == cidCFFProgram) {
+			return indRef, *st
+		}
+		return indRef, ""
+	}
+
+	return fontDescriptorFontFileIndirectObjectRef(fd), ""
+}
+
+// newFontUse returns the font use for a font dict along with its embedded font program.
+// A nil font program means the font is not embedded.
+func newFontUse(xRefTable *XRefTable, objNr int, d *PDFDict) (*fontUse, *PDFIndirectRef, string) {
+
+	fu := &fontUse{objNr: objNr, dict: d}
+
+	subType := d.Subtype()
+	if subType == nil {
+		return nil, nil, ""
+	}
+
+	fdHolder := d
+
+	if *subType == "Type0" {
+		a, err := xRefTable.DereferenceArray(d.Dict["DescendantFonts"])
+		if err != nil || a == nil || len(*a) != 1 {
+			return nil, nil, ""
+		}
+		fu.cidFont, err = xRefTable.DereferenceDict((*a)[0])
+		if err != nil || fu.cidFont == nil {
+			return nil, nil, ""
+		}
+		fdHolder = fu.cidFont
+	}
+
+	fd, err := xRefTable.DereferenceDict(fdHolder.Dict["FontDescriptor"])
+	if err != nil || fd == nil {
+		return nil, nil, ""
+	}
+	fu.fd = fd
+
+	indRef, kind := fontProgramRef(xRefTable, fd)
+	if indRef == nil {
+		return nil, nil, ""
+	}
+
+	// Check the font program matches the font type.
+	switch kind {
+
+	case trueTypeProgram:
+		if *subType == "Type0" {
+			if st := fu.cidFont.Subtype(); st == nil || *st != "CIDFontType2" {
+				kind = ""
+			}
+		} else if *subType != "TrueType" {
+			kind = ""
+		}
+
+	case type1CProgram:
+		if *subType != "Type1" && *subType != "MMType1" {
+			kind = ""
+		}
+
+	case cidCFFProgram:
+		if st := fu.cidFont.Subtype(); *subType != "Type0" || st == nil || *st != "CIDFontType0" {
+			kind = ""
+		}
+	}
+
+	// CIDs must be derived from char codes.
+	if fu.cidFont != nil {
+		o, _ := xRefTable.Dereference(d.Dict["Encoding"])
+		if n, ok := o.(PDFName); ok && n != "Identity-H" && n != "Identity-V" {
+			kind = ""
+		}
+	}
+
+	// Fonts already subset.
+	if n := d.NameEntry("BaseFont"); n == nil || len(*n) > 7 && (*n)[6] == '+' {
+		kind = ""
+	}
+
+	return fu, indRef, kind
+}
+
+// fontPrograms returns all embedded font programs eligible for subsetting by object number.
+func fontPrograms(ctx *PDFContext, codes map[int]map[string]bool, fonts map[int]*textFont) map[int]*fontProgram {
+
+	excluded := formFonts(ctx)
+
+	m := map[int]*fontProgram{}
+
+	for objNr, entry := range ctx.Table {
+
+		if entry.Free || entry.Object == nil {
+			continue
+		}
+
+		d, ok := entry.Object.(PDFDict)
+		if !ok || d.NameEntry("BaseFont") == nil {
+			continue
+		}
+
+		// Descendant fonts are handled along with their Type0 font.
+		st := d.Subtype()
+		if st == nil || *st == "CIDFontType0" || *st == "CIDFontType2" || *st == "Type3" {
+			continue
+		}
+
+		fu, indRef, kind := newFontUse(ctx.XRefTable, objNr, &d)
+		if fu == nil {
+			continue
+		}
+
+		fp, found := m[indRef.ObjectNumber.Value()]
+		if !found {
+			fp = &fontProgram{kind: kind}
+			m[indRef.ObjectNumber.Value()] = fp
+		}
+
+		if kind == "" || kind != fp.kind || excluded[objNr] {
+			fp.unsafe = true
+			continue
+		}
+
+		fu.codes = codes[objNr]
+		fu.font = fonts[objNr]
+		if fu.codes != nil {
+			fp.uses = append(fp.uses, fu)
+		}
+	}
+
+	return m
+}
+
+// cid returns the CID for a char code of a Type0 font.
+func (fu *fontUse) cid(code []byte) int {
+
+	if fu.font.cmap != nil {
+		return fu.font.cmap.cid(code)
+	}
+
+	return bytesValue(code)
+}
+
+// glyphRune returns the Unicode value of a glyph name.
+func glyphRune(name string) (rune, bool) {
+
+	if r, ok := ligatureRunes[name]; ok {
+		return r, true
+	}
+
+	s, ok := glyphText(name)
+	if !ok {
+		return 0, false
+	}
+
+	r := []rune(s)
+	if len(r) != 1 {
+		return 0, false
+	}
+
+	return r[0], true
+}
+
+// trueTypeGlyphs adds the glyph indices used by a simple TrueType font to gids.
+// See 9.6.6.4 Encodings for TrueType Fonts.
+func (fu *fontUse) trueTypeGlyphs(tt *trueTypeFont, gids IntSet) error {
+
+	t30, t10, t31 := tt.cmapSubtable(3, 0), tt.cmapSubtable(1, 0), tt.cmapSubtable(3, 1)
+
+	for code := range fu.codes {
+
+		c := int(code[0])
+		found := false
+
+		lookup := func(t []byte, c int) {
+			if t == nil {
+				return
+			}
+			if gid := cmapLookup(t, c); gid > 0 && gid < tt.numGlyphs {
+				gids[gid] = true
+				found = true
+			}
+		}
+
+		// Viewers differ in the cmap subtable used, so keep all candidates.
+		for _, base := range []int{0, 0xF000, 0xF100, 0xF200} {
+			lookup(t30, base+c)
+		}
+
+		lookup(t10, c)
+
+		if r, ok := glyphRune(fu.font.enc[c]); ok {
+			lookup(t31, int(r))
+		}
+
+		if !found {
+			return errors.Errorf("trueTypeGlyphs: no glyph for code %d", c)
+		}
+	}
+
+	return nil
+}
+
+// cidTrueTypeGlyphs adds the glyph indices used by a CIDFontType2 font to gids.
+func (fu *fontUse) cidTrueTypeGlyphs(xRefTable *XRefTable, tt *trueTypeFont, gids IntSet) error {
+
+	var cidToGIDMap []byte
+
+	o, err := xRefTable.Dereference(fu.cidFont.Dict["CIDToGIDMap"])
+	if err != nil {
+		return err
+	}
+
+	switch o := o.(type) {
+
+	case nil:
+
+	case PDFName:
+		if o != "Identity" {
+			return errors.Errorf("cidTrueTypeGlyphs: invalid CIDToGIDMap %s", o)
+		}
+
+	case PDFStreamDict:
+		cidToGIDMap = streamContent(xRefTable, fu.cidFont.Dict["CIDToGIDMap"])
+		if cidToGIDMap == nil {
+			return errors.New("cidTrueTypeGlyphs: corrupt CIDToGIDMap")
+		}
+
+	default:
+		return errors.Errorf("cidTrueTypeGlyphs: invalid CIDToGIDMap %v", o)
+	}
+
+	fu.cids = map[int]int{}
+
+	for code := range fu.codes {
+
+		cid := fu.cid([]byte(code))
+
+		gid := cid
+		if cidToGIDMap != nil {
+			gid = 0
+			if 2*cid+2 <= len(cidToGIDMap) {
+				gid = int(cidToGIDMap[2*cid])<<8 | int(cidToGIDMap[2*cid+1])
+			}
+		}
+
+		if gid >= tt.numGlyphs {
+			gid = 0
+		}
+
+		fu.cids[cid] = gid
+		gids[gid] = true
+	}
+
+	return nil
+}
+
+// type1CGlyphs adds the glyph indices used by a simple CFF font to gids.
+func (fu *fontUse) type1CGlyphs(cf *cffFont, gids IntSet) error {
+
+	for code := range fu.codes {
+
+		c := int(code[0])
+		found := false
+
+		// Either the font encoding or the built-in encoding applies.
+		if gid, ok := cf.gidForName(fu.font.enc[c]); ok && gid > 0 {
+			gids[gid] = true
+			found = true
+		}
+
+		if gid, ok := cf.codes[c]; ok && gid > 0 {
+			gids[gid] = true
+			found = true
+		}
+
+		if !found {
+			return errors.Errorf("type1CGlyphs: no glyph for code %d", c)
+		}
+	}
+
+	return nil
+}
+
+// cidCFFGlyphs adds the glyph indices used by a CIDFontType0 font to gids.
+func (fu *fontUse) cidCFFGlyphs(cf *cffFont, gids IntSet) {
+
+	for code := range fu.codes {
+		if gid, ok := cf.gidForCID(fu.cid([]byte(code))); ok {
+			gids[gid] = true
+		}
+	}
+}
+
+// subsetTag returns a subset tag of six uppercase letters derived from the glyphs kept.
+func subsetTag(objNr int, gids IntSet) string {
+
+	var ii []int
+	for gid := range gids {
+		ii = append(ii, gid)
+	}
+	sort.Ints(ii)
+
+	h := fnv.New32a()
+	fmt.Fprintf(h, "%d:%v", objNr, ii)
+	v := h.Sum32()
+
+	b := make([]byte, 6)
+	for i := range b {
+		b[i] = byte('A' + v%26)
+		v /= 26
+	}
+
+	return string(b)
+}
+
+// updateWidths restricts the Widths of a simple font to the codes used.
+func (fu *fontUse) updateWidths(xRefTable *XRefTable) {
+
+	a, err := xRefTable.DereferenceArray(fu.dict.Dict["Widths"])
+	if err != nil || a == nil || len(*a) == 0 {
+		return
+	}
+
+	first := int(xRefTable.DereferenceNumber(fu.dict.Dict["FirstChar"]))
+	last := first + len(*a) - 1
+
+	lo, hi := 256, -1
+	for code := range fu.codes {
+		if c := int(code[0]); c >= first && c <= last {
+			if c < lo {
+				lo = c
+			}
+			if c > hi {
+				hi = c
+			}
+		}
+	}
+
+	if hi < 0 {
+		return
+	}
+
+	w := PDFArray{}
+	for c := lo; c <= hi; c++ {
+		if fu.codes[string([]byte{byte(c)})] {
+			w = append(w, (*a)[c-first])
+			continue
+		}
+		w = append(w, PDFInteger(0))
+	}
+
+	fu.dict.Update("FirstChar", PDFInteger(lo))
+	fu.dict.Update("LastChar", PDFInteger(hi))
+	fu.dict.Update("Widths", w)
+}
+
+func widthObject(w float64) PDFObject {
+
+	if w == float64(int(w)) {
+		return PDFInteger(int(w))
+	}
+
+	return PDFFloat(w)
+}
+
+// updateCIDWidths restricts the W array of a CID font to the CIDs used.
+func (fu *fontUse) updateCIDWidths() {
+
+	if _, found := fu.cidFont.Find("W"); !found {
+		return
+	}
+
+	var cids []int
+	for code := range fu.codes {
+		cid := fu.cid([]byte(code))
+		if _, ok := fu.font.widths[cid]; ok {
+			cids = append(cids, cid)
+		}
+	}
+	sort.Ints(cids)
+
+	// c [w1 w2 ... wn] for consecutive CIDs.
+	w := PDFArray{}
+	for i := 0; i < len(cids); {
+		ws := PDFArray{}
+		j := i
+		for ; j < len(cids) && cids[j]-cids[i] == j-i; j++ {
+			ws = append(ws, widthObject(fu.font.widths[cids[j]]))
+		}
+		w = append(w, PDFInteger(cids[i]), ws)
+		i = j
+	}
+
+	fu.cidFont.Update("W", w)
+}
+
+// toUnicodeCMap returns a ToUnicode CMap for the char codes used.
+func (fu *fontUse) toUnicodeCMap() []byte {
+
+	codespace := fu.font.toUnicode.codespace
+	if len(codespace) == 0 {
+		codespace = []cmapRange{{lo: []byte{0x00}, hi: []byte{0xFF}}}
+		if fu.cidFont != nil {
+			codespace = []cmapRange{{lo: []byte{0x00, 0x00}, hi: []byte{0xFF, 0xFF}}}
+		}
+	}
+
+	text := map[string]string{}
+	for code := range fu.codes {
+		if s, ok := fu.font.toUnicode.text([]byte(code)); ok && s != "" {
+			text[code] = s
+		}
+	}
+
+	return newToUnicodeCMap(codespace, text)
+}
+
+// newToUnicodeCMap returns a ToUnicode CMap mapping char codes to text.
+func newToUnicodeCMap(codespace []cmapRange, text map[string]string) []byte {
+
+	var b bytes.Buffer
+
+	b.WriteString("/CIDInit /ProcSet findresource begin\n12 dict begin\nbegincmap\n")
+	b.WriteString("/CIDSystemInfo << /Registry (Adobe) /Ordering (UCS) /Supplement 0 >> def\n")
+	b.WriteString("/CMapName /Adobe-Identity-UCS def\n/CMapType 2 def\n")
+
+	fmt.Fprintf(&b, "%d begincodespacerange\n", len(codespace))
+	for _, r := range codespace {
+		fmt.Fprintf(&b, "<%X> <%X>\n", r.lo, r.hi)
+	}
+	b.WriteString("endcodespacerange\n")
+
+	var codes []string
+	for code := range text {
+		codes = append(codes, code)
+	}
+
+	sort.Slice(codes, func(i, j int) bool {
+		if len(codes[i]) != len(codes[j]) {
+			return len(codes[i]) < len(codes[j])
+		}
+		return codes[i] < codes[j]
+	})
+
+	var chars []string
+	for _, code := range codes {
+		var dst strings.Builder
+		for _, u := range utf16.Encode([]rune(text[code])) {
+			fmt.Fprintf(&dst, "%04X", u)
+		}
+		chars = append(chars, fmt.Sprintf("<%X> <%s>\n", code, dst.String()))
+	}
+
+	// At most 100 entries per block.
+	for len(chars) > 0 {
+		n := len(chars)
+		if n > 100 {
+			n = 100
+		}
+		fmt.Fprintf(&b, "%d beginbfchar\n", n)
+		for _, s := range chars[:n] {
+			b.WriteString(s)
+		}
+		b.WriteString("endbfchar\n")
+		chars = chars[n:]
+	}
+
+	b.WriteString("endcmap\nCMapName currentdict /CMap defineresource pop\nend\nend\n")
+
+	return b.Bytes()
+}
+
+// newFlateStream inserts a new Flate encoded stream for b.
+func newFlateStream(xRefTable *XRefTable, b []byte) (*PDFIndirectRef, error) {
+
+	sd := &PDFStreamDict{
+		PDFDict:        NewPDFDict(),
+		Content:        b,
+		FilterPipeline: []PDFFilter{{Name: filter.Flate, DecodeParms: nil}}}
+
+	sd.InsertName("Filter", filter.Flate)
+
+	err := encodeStream(sd)
+	if err != nil {
+		return nil, err
+	}
+
+	return xRefTable.IndRefForNewObject(*sd)
+}
+
+// update rewrites a font dict for a subset font program.
+func (fu *fontUse) update(xRefTable *XRefTable, tag string, newGIDs map[int]int) error {
+
+	if fu.cidFont == nil {
+		fu.updateWidths(xRefTable)
+	} else {
+		fu.updateCIDWidths()
+	}
+
+	if fu.font.toUnicode != nil {
+		indRef, err := newFlateStream(xRefTable, fu.toUnicodeCMap())
+		if err != nil {
+			return err
+		}
+		fu.dict.Update("ToUnicode", *indRef)
+	}
+
+	if newGIDs != nil {
+		maxCID := 0
+		for cid := range fu.cids {
+			if cid > maxCID {
+				maxCID = cid
+			}
+		}
+		b := make([]byte, 2*(maxCID+1))
+		for cid, gid := range fu.cids {
+			gid = newGIDs[gid]
+			b[2*cid], b[2*cid+1] = byte(gid>>8), byte(gid)
+		}
+		indRef, err := newFlateStream(xRefTable, b)
+		if err != nil {
+			return err
+		}
+		fu.cidFont.Update("CIDToGIDMap", *indRef)
+	}
+
+	rename := func(d *PDFDict, key string) {
+		if n := d.NameEntry(key); n != nil && !strings.HasPrefix(*n, tag+"+") {
+			d.Update(key, PDFName(tag+"+"+*n))
+		}
+	}
+
+	rename(fu.dict, "BaseFont")
+	if fu.cidFont != nil {
+		rename(fu.cidFont, "BaseFont")
+	}
+	rename(fu.fd, "FontName")
+
+	return nil
+}
+
+// subsetFontProgram subsets the font program objNr to the glyphs used by all font dicts referring to it.
+func subsetFontProgram(ctx *PDFContext, objNr int, fp *fontProgram) (*SubsetFont, error) {
+
+	indRef := *NewPDFIndirectRef(objNr, 0)
+
+	sd, err := ctx.DereferenceStreamDict(indRef)
+	if err != nil || sd == nil || sd.StreamLength == nil {
+		return nil, errors.Errorf("subsetFontProgram: obj#%d: corrupt font program", objNr)
+	}
+
+	b := streamContent(ctx.XRefTable, indRef)
+	if b == nil {
+		return nil, errors.Errorf("subsetFontProgram: obj#%d: cannot decode font program", objNr)
+	}
+
+	gids := IntSet{}
+
+	var (
+		subset  []byte
+		newGIDs map[int]int
+	)
+
+	if fp.kind == trueTypeProgram {
+
+		tt, err := parseTrueType(b)
+		if err != nil {
+			return nil, err
+		}
+
+		// Glyphs may be renumbered if only referred to by CIDToGIDMaps.
+		compact := true
+
+		for _, fu := range fp.uses {
+			if fu.cidFont == nil {
+				compact = false
+				err = fu.trueTypeGlyphs(tt, gids)
+			} else {
+				err = fu.cidTrueTypeGlyphs(ctx.XRefTable, tt, gids)
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		subset, newGIDs = tt.subset(gids, compact)
+		if !compact {
+			newGIDs = nil
+		}
+
+	} else {
+
+		cf, err := parseCFF(b)
+		if err != nil {
+			return nil, err
+		}
+
+		if cf.cid != (fp.kind == cidCFFProgram) {
+			return nil, errors.Errorf("subsetFontProgram: obj#%d: font program does not match %s", objNr, fp.kind)
+		}
+
+		for _, fu := range fp.uses {
+			if cf.cid {
+				fu.cidCFFGlyphs(cf, gids)
+				continue
+			}
+			if err = fu.type1CGlyphs(cf, gids); err != nil {
+				return nil, err
+			}
+		}
+
+		subset = cf.subset(gids)
+	}
+
+	sd1 := &PDFStreamDict{PDFDict: NewPDFDict(), Content: subset, FilterPipeline: []PDFFilter{{Name: filter.Flate}}}
+
+	err = encodeStream(sd1)
+	if err != nil {
+		return nil, err
+	}
+
+	if *sd1.StreamLength >= *sd.StreamLength {
+		return nil, nil
+	}
+
+	tag := subsetTag(objNr, gids)
+
+	for _, fu := range fp.uses {
+		if err = fu.update(ctx.XRefTable, tag, newGIDs); err != nil {
+			return nil, err
+		}
+	}
+
+	sf := &SubsetFont{
+		Name:    *fp.uses[0].dict.NameEntry("BaseFont"),
+		Glyphs:  len(gids),
+		Size:    *sd.StreamLength,
+		NewSize: *sd1.StreamLength,
+	}
+
+	sd.Delete("Filter")
+	sd.Delete("DecodeParms")
+	sd.InsertName("Filter", filter.Flate)
+	if fp.kind == trueTypeProgram {
+		sd.Update("Length1", PDFInteger(len(subset)))
+	}
+	sd.Update("Length", PDFInteger(sf.NewSize))
+	sd.FilterPipeline = sd1.FilterPipeline
+	sd.Content = subset
+	sd.Raw = sd1.Raw
+	sd.StreamLength = sd1.StreamLength
+
+	entry, found := ctx.FindTableEntry(objNr, 0)
+	if !found {
+		return nil, errors.Errorf("subsetFontProgram: obj#%d not found", objNr)
+	}
+	entry.Object = *sd
+
+	return sf, nil
+}
+
+// subsetFonts subsets fully embedded TrueType and CFF font programs to the glyphs shown by any content stream.
+func subsetFonts(ctx *PDFContext) error {
+
+	log.Debug.Println("subsetFonts begin")
+
+	codes, fonts, ok, err := shownCodes(ctx)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		// We cannot tell which font programs are used by direct font dicts.
+		log.Debug.Println("subsetFonts end: direct font dict")
+		return nil
+	}
+
+	for objNr, fp := range fontPrograms(ctx, codes, fonts) {
+
+		if fp.unsafe || len(fp.uses) == 0 {
+			continue
+		}
+
+		sf, err := subsetFontProgram(ctx, objNr, fp)
+		if err != nil {
+			// Leave fonts alone we cannot parse.
+			log.Debug.Printf("subsetFonts: obj#%d: %v\n", objNr, err)
+			continue
+		}
+
+		if sf == nil {
+			continue
+		}
+
+		log.Debug.Printf("subsetFonts: obj#%d %s %d glyphs, %d -> %d bytes\n", objNr, sf.Name, sf.Glyphs, sf.Size, sf.NewSize)
+
+		ctx.Optimize.SubsetFonts[objNr] = sf
+	}
+
+	log.Debug.Println("subsetFonts end")
+
+	return nil
+}
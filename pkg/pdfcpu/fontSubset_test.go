@@ -0,0 +1,125 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// testTrueType returns a font with 4 glyphs where glyph 3 is a composite of glyph 2.
+func testTrueType() []byte {
+
+	simple := []byte{0, 1, 0, 0, 0, 0, 0, 10, 0, 10, 0, 0}
+
+	composite := []byte{0xFF, 0xFF, 0, 0, 0, 0, 0, 10, 0, 10,
+		0x00, 0x02, 0x00, 0x02, 0x00, 0x00}
+
+	var glyf []byte
+	loca := []byte{0, 0, 0, 0}
+
+	for _, g := range [][]byte{simple, simple, simple, composite} {
+		glyf = append(glyf, g...)
+		loca = append(loca, 0, 0, 0, 0)
+		binary.BigEndian.PutUint32(loca[len(loca)-4:], uint32(len(glyf)))
+	}
+
+	head := make([]byte, 54)
+	binary.BigEndian.PutUint16(head[50:], 1)
+
+	hhea := make([]byte, 36)
+	binary.BigEndian.PutUint16(hhea[34:], 4)
+
+	maxp := []byte{0, 0, 0x50, 0, 0, 4}
+
+	hmtx := []byte{0, 100, 0, 0, 0, 101, 0, 0, 0, 102, 0, 0, 0, 103, 0, 0}
+
+	return writeTrueType(map[string][]byte{"head": head, "hhea": hhea, "maxp": maxp, "loca": loca, "glyf": glyf, "hmtx": hmtx})
+}
+
+func TestSubsetTrueType(t *testing.T) {
+
+	tt, err := parseTrueType(testTrueType())
+	if err != nil {
+		t.Fatalf("TestSubsetTrueType: %v\n", err)
+	}
+
+	if tt.numGlyphs != 4 {
+		t.Fatalf("TestSubsetTrueType: %d glyphs, expected 4\n", tt.numGlyphs)
+	}
+
+	// Keep glyph indices.
+	b, _ := tt.subset(IntSet{3: true}, false)
+
+	tt1, err := parseTrueType(b)
+	if err != nil {
+		t.Fatalf("TestSubsetTrueType: %v\n", err)
+	}
+
+	if tt1.numGlyphs != 4 || len(tt1.glyph(1)) != 0 || len(tt1.glyph(2)) == 0 || len(tt1.glyph(3)) == 0 {
+		t.Fatalf("TestSubsetTrueType: unexpected glyphs %v\n", tt1.loca)
+	}
+
+	// Renumber glyphs.
+	b, newGIDs := tt.subset(IntSet{3: true}, true)
+
+	tt2, err := parseTrueType(b)
+	if err != nil {
+		t.Fatalf("TestSubsetTrueType: %v\n", err)
+	}
+
+	if tt2.numGlyphs != 3 || newGIDs[2] != 1 || newGIDs[3] != 2 {
+		t.Fatalf("TestSubsetTrueType: unexpected glyphs %v\n", newGIDs)
+	}
+
+	// The component refers to the new index of glyph 2.
+	g := tt2.glyph(2)
+	if offs := components(g); len(offs) != 1 || binary.BigEndian.Uint16(g[offs[0]:]) != 1 {
+		t.Fatalf("TestSubsetTrueType: unexpected composite glyph %v\n", g)
+	}
+
+	if advance, _ := tt2.hMetric(1); binary.BigEndian.Uint16(advance) != 102 {
+		t.Fatalf("TestSubsetTrueType: unexpected advance width %v\n", advance)
+	}
+}
+
+func TestCFFIndex(t *testing.T) {
+
+	if n := len(cffStandardStrings); n != 391 {
+		t.Fatalf("TestCFFIndex: %d standard strings, expected 391\n", n)
+	}
+
+	items := [][]byte{[]byte("a"), {}, bytes.Repeat([]byte{'b'}, 300)}
+
+	b := encodeCFFIndex(items)
+
+	items1, end, err := cffIndex(b, 0)
+	if err != nil {
+		t.Fatalf("TestCFFIndex: %v\n", err)
+	}
+
+	if end != len(b) || len(items1) != len(items) {
+		t.Fatalf("TestCFFIndex: unexpected INDEX %v\n", items1)
+	}
+
+	for i := range items {
+		if !bytes.Equal(items[i], items1[i]) {
+			t.Fatalf("TestCFFIndex: item %d: %v != %v\n", i, items1[i], items[i])
+		}
+	}
+}
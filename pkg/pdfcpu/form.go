@@ -0,0 +1,1188 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hhrutter/pdfcpu/pkg/log"
+	"github.com/pkg/errors"
+)
+
+// Field flags, see 12.7.3.1, 12.7.4.2.1, 12.7.4.3 and 12.7.4.4
+const (
+	fieldMultiline   = 1 << 12
+	fieldPassword    = 1 << 13
+	fieldNoToggleOff = 1 << 14
+	fieldRadio       = 1 << 15
+	fieldPushbutton  = 1 << 16
+	fieldCombo       = 1 << 17
+	fieldEdit        = 1 << 18
+	fieldMultiSelect = 1 << 21
+	fieldComb        = 1 << 24
+)
+
+// FormField represents a terminal field of an interactive form.
+type FormField struct {
+	Name    string    // the fully qualified field name.
+	Type    string    // Btn, Tx, Ch or Sig
+	Flags   int       // Ff
+	Value   string    // multiple selected options are separated by commas.
+	Options []string  // the export values of choice fields, the on states of check boxes and radio buttons.
+	Page    int       // the page of the first widget annotation.
+	Rect    []float64 // the rectangle of the first widget annotation.
+}
+
+// fieldAttrs are the inheritable field attributes.
+type fieldAttrs struct {
+	ft     string
+	ff     int
+	v      PDFObject
+	da     string
+	q      int
+	maxLen int
+}
+
+type widget struct {
+	indRef PDFIndirectRef
+	dict   *PDFDict
+}
+
+// formField is a terminal field along with its widget annotations.
+type formField struct {
+	name string
+	dict *PDFDict
+	fieldAttrs
+	dr      *PDFDict // the field's own default resources, a common extension.
+	opts    []string // the export values of a choice field.
+	display []string // the display texts of a choice field.
+	widgets []widget
+}
+
+// fieldText decodes a text string.
+func fieldText(xRefTable *XRefTable, o PDFObject) (string, error) {
+
+	o, err := xRefTable.Dereference(o)
+	if err != nil {
+		return "", err
+	}
+
+	switch o := o.(type) {
+
+	case PDFStringLiteral:
+		return StringLiteralToString(o.Value())
+
+	case PDFHexLiteral:
+		return HexLiteralToString(o.Value())
+
+	}
+
+	return "", errors.Errorf("fieldText: corrupt text string %v", o)
+}
+
+func (attrs *fieldAttrs) update(xRefTable *XRefTable, d *PDFDict) {
+
+	if ft := d.NameEntry("FT"); ft != nil {
+		attrs.ft = *ft
+	}
+
+	if ff := d.IntEntry("Ff"); ff != nil {
+		attrs.ff = *ff
+	}
+
+	if v, found := d.Find("V"); found {
+		attrs.v = v
+	}
+
+	if s, err := fieldText(xRefTable, d.Dict["DA"]); err == nil {
+		attrs.da = s
+	}
+
+	if q := d.IntEntry("Q"); q != nil {
+		attrs.q = *q
+	}
+
+	if n := d.IntEntry("MaxLen"); n != nil {
+		attrs.maxLen = *n
+	}
+}
+
+// collectWidgets returns the widget annotations of a terminal field.
+func collectWidgets(xRefTable *XRefTable, indRef PDFIndirectRef, d *PDFDict, widgets []widget) ([]widget, error) {
+
+	kids, err := xRefTable.DereferenceArray(d.Dict["Kids"])
+	if err != nil {
+		return nil, err
+	}
+
+	if kids == nil {
+		return append(widgets, widget{indRef, d}), nil
+	}
+
+	for _, o := range *kids {
+
+		kidIndRef, ok := o.(PDFIndirectRef)
+		if !ok {
+			return nil, errors.New("collectWidgets: corrupt kids array: entries must be indirect reference")
+		}
+
+		kid, err := xRefTable.DereferenceDict(kidIndRef)
+		if err != nil {
+			return nil, err
+		}
+
+		if kid == nil {
+			continue
+		}
+
+		widgets, err = collectWidgets(xRefTable, kidIndRef, kid, widgets)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return widgets, nil
+}
+
+func (f *formField) loadOptions(xRefTable *XRefTable) error {
+
+	opts, err := xRefTable.DereferenceArray(f.dict.Dict["Opt"])
+	if err != nil || opts == nil {
+		return err
+	}
+
+	for _, o := range *opts {
+
+		o, err = xRefTable.Dereference(o)
+		if err != nil {
+			return err
+		}
+
+		// An option is either a text string or an array of export value and text.
+		export, display := o, o
+		if a, ok := o.(PDFArray); ok && len(a) == 2 {
+			export, display = a[0], a[1]
+		}
+
+		s, err := fieldText(xRefTable, export)
+		if err != nil {
+			return err
+		}
+		f.opts = append(f.opts, s)
+
+		s, err = fieldText(xRefTable, display)
+		if err != nil {
+			return err
+		}
+		f.display = append(f.display, s)
+	}
+
+	return nil
+}
+
+// onStates returns the names of the on states of button widgets.
+func (f *formField) onStates(xRefTable *XRefTable) []string {
+
+	var states []string
+
+	for _, w := range f.widgets {
+		for _, s := range widgetStates(xRefTable, w.dict) {
+			if !memberOf(s, states) {
+				states = append(states, s)
+			}
+		}
+	}
+
+	return states
+}
+
+func widgetStates(xRefTable *XRefTable, w *PDFDict) []string {
+
+	var states []string
+
+	ap, _ := xRefTable.DereferenceDict(w.Dict["AP"])
+	if ap == nil {
+		return nil
+	}
+
+	n, _ := xRefTable.DereferenceDict(ap.Dict["N"])
+	if n == nil {
+		return nil
+	}
+
+	for k := range n.Dict {
+		if k != "Off" {
+			states = append(states, k)
+		}
+	}
+
+	sort.Strings(states)
+
+	return states
+}
+
+// namedKids returns true if any of kids has a partial field name.
+func namedKids(xRefTable *XRefTable, kids *PDFArray) bool {
+
+	for _, o := range *kids {
+		if kid, _ := xRefTable.DereferenceDict(o); kid != nil && kid.Dict["T"] != nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+// collectFields appends the terminal fields of the field tree rooted at indRef to fields.
+func collectFields(xRefTable *XRefTable, indRef PDFIndirectRef, attrs fieldAttrs, fields []*formField) ([]*formField, error) {
+
+	err := walkAcroFields(xRefTable, indRef, "", attrs, func(af *acroField) (bool, error) {
+
+		// Kids with names are fields, other kids are widget annotations.
+		// The kids of radio buttons are always treated as widget annotations.
+		if af.kids != nil && !(af.ft == "Btn" && af.ff&fieldRadio > 0) && namedKids(xRefTable, af.kids) {
+			return true, nil
+		}
+
+		f := &formField{name: af.name, dict: af.dict, fieldAttrs: af.fieldAttrs}
+
+		var err error
+
+		f.dr, err = xRefTable.DereferenceDict(af.dict.Dict["DR"])
+		if err != nil {
+			return false, err
+		}
+
+		f.widgets, err = collectWidgets(xRefTable, af.indRef, af.dict, nil)
+		if err != nil {
+			return false, err
+		}
+
+		if f.ft == "Ch" {
+			err = f.loadOptions(xRefTable)
+			if err != nil {
+				return false, err
+			}
+		}
+
+		fields = append(fields, f)
+
+		return false, nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return fields, nil
+}
+
+// acroForm returns the interactive form dict of ctx.
+func acroForm(ctx *PDFContext) (*PDFDict, error) {
+
+	rootDict, err := ctx.Catalog()
+	if err != nil {
+		return nil, err
+	}
+
+	return ctx.DereferenceDict(rootDict.Dict["AcroForm"])
+}
+
+func formFields(ctx *PDFContext) (*PDFDict, []*formField, error) {
+
+	form, err := acroForm(ctx)
+	if err != nil || form == nil {
+		return nil, nil, err
+	}
+
+	attrs := fieldAttrs{}
+	if s, err := fieldText(ctx.XRefTable, form.Dict["DA"]); err == nil {
+		attrs.da = s
+	}
+	if q := form.IntEntry("Q"); q != nil {
+		attrs.q = *q
+	}
+
+	a, err := ctx.DereferenceArray(form.Dict["Fields"])
+	if err != nil || a == nil {
+		return form, nil, err
+	}
+
+	var fields []*formField
+
+	for _, o := range *a {
+
+		indRef, ok := o.(PDFIndirectRef)
+		if !ok {
+			return nil, nil, errors.New("formFields: corrupt form field array entry")
+		}
+
+		fields, err = collectFields(ctx.XRefTable, indRef, attrs, fields)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return form, fields, nil
+}
+
+// widgetPages returns the page numbers by annotation object number.
+func widgetPages(ctx *PDFContext) (map[int]int, error) {
+
+	m := map[int]int{}
+
+	for i := 1; i <= ctx.PageCount; i++ {
+
+		d, _, err := ctx.PageDict(i)
+		if err != nil {
+			return nil, err
+		}
+
+		if d == nil {
+			continue
+		}
+
+		annots, err := ctx.DereferenceArray(d.Dict["Annots"])
+		if err != nil {
+			return nil, err
+		}
+
+		if annots == nil {
+			continue
+		}
+
+		for _, o := range *annots {
+			if indRef, ok := o.(PDFIndirectRef); ok {
+				m[indRef.ObjectNumber.Value()] = i
+			}
+		}
+	}
+
+	return m, nil
+}
+
+// value returns the current value of a field as string.
+func (f *formField) value(xRefTable *XRefTable) string {
+
+	o, _ := xRefTable.Dereference(f.v)
+
+	switch o := o.(type) {
+
+	case PDFName:
+		return o.Value()
+
+	case PDFStringLiteral, PDFHexLiteral:
+		s, _ := fieldText(xRefTable, o)
+		return s
+
+	case PDFArray:
+		var ss []string
+		for _, o := range o {
+			if s, err := fieldText(xRefTable, o); err == nil {
+				ss = append(ss, s)
+			}
+		}
+		return strings.Join(ss, ",")
+
+	case PDFDict:
+		if f.ft == "Sig" {
+			return "signed"
+		}
+
+	}
+
+	return ""
+}
+
+// FormFields returns the terminal fields of the interactive form of ctx.
+func FormFields(ctx *PDFContext) ([]FormField, error) {
+
+	_, fields, err := formFields(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	pages, err := widgetPages(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ff := []FormField{}
+
+	for _, f := range fields {
+
+		field := FormField{Name: f.name, Type: f.ft, Flags: f.ff, Value: f.value(ctx.XRefTable)}
+
+		switch f.ft {
+		case "Ch":
+			field.Options = f.opts
+		case "Btn":
+			field.Options = f.onStates(ctx.XRefTable)
+		}
+
+		if len(f.widgets) > 0 {
+			w := f.widgets[0]
+			field.Page = pages[w.indRef.ObjectNumber.Value()]
+			if a, _ := ctx.DereferenceArray(w.dict.Dict["Rect"]); a != nil && len(*a) == 4 {
+				r := rect(ctx.XRefTable, *a)
+				field.Rect = []float64{r.LL.X, r.LL.Y, r.UR.X, r.UR.Y}
+			}
+		}
+
+		ff = append(ff, field)
+	}
+
+	return ff, nil
+}
+
+// FormFieldList returns a list of the terminal fields of the interactive form of ctx.
+func FormFieldList(ctx *PDFContext) ([]string, error) {
+
+	fields, err := FormFields(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var list []string
+
+	for _, f := range fields {
+
+		s := fmt.Sprintf("%s (%s) flags=%d", f.Name, f.Type, f.Flags)
+
+		if f.Page > 0 {
+			s += fmt.Sprintf(" page=%d", f.Page)
+		}
+
+		if len(f.Rect) == 4 {
+			s += fmt.Sprintf(" rect=[%.2f %.2f %.2f %.2f]", f.Rect[0], f.Rect[1], f.Rect[2], f.Rect[3])
+		}
+
+		s += fmt.Sprintf(" value=%q", f.Value)
+
+		if len(f.Options) > 0 {
+			s += fmt.Sprintf(" options=%q", f.Options)
+		}
+
+		list = append(list, s)
+	}
+
+	return list, nil
+}
+
+// daFont is the font of a default appearance string.
+type daFont struct {
+	id    string       // the resource name.
+	obj   PDFObject    // the font dict.
+	size  float64      // 0 for auto sized text.
+	ops   string       // the remaining operators of the default appearance string, eg. for setting the text color.
+	tf    *textFont    // encoding and widths.
+	codes map[rune]int // char codes by rune.
+}
+
+// parseDA parses a default appearance string like "/Helv 0 Tf 0 g".
+func parseDA(da string) (id string, size float64, ops string, err error) {
+
+	tt := strings.Fields(da)
+
+	for i, t := range tt {
+
+		if t != "Tf" || i < 2 || !strings.HasPrefix(tt[i-2], "/") {
+			continue
+		}
+
+		size, err = strconv.ParseFloat(tt[i-1], 64)
+		if err != nil {
+			return "", 0, "", errors.Errorf("parseDA: corrupt font size in %q", da)
+		}
+
+		rest := append(append([]string{}, tt[:i-2]...), tt[i+1:]...)
+
+		return tt[i-2][1:], size, strings.Join(rest, " "), nil
+	}
+
+	return "", 0, "", errors.Errorf("parseDA: missing font in %q", da)
+}
+
+// font returns the font selected by the default appearance string of f.
+func (f *formField) font(xRefTable *XRefTable, form *PDFDict) (*daFont, error) {
+
+	id, size, ops, err := parseDA(f.da)
+	if err != nil {
+		return nil, errors.Wrapf(err, "field %s", f.name)
+	}
+
+	var obj PDFObject
+
+	for _, o := range []PDFObject{form.Dict["DR"], f.dict.Dict["DR"]} {
+		dr, err := xRefTable.DereferenceDict(o)
+		if err != nil {
+			return nil, err
+		}
+		if dr == nil {
+			continue
+		}
+		fonts, err := xRefTable.DereferenceDict(dr.Dict["Font"])
+		if err != nil {
+			return nil, err
+		}
+		if fonts != nil && fonts.Dict[id] != nil {
+			obj = fonts.Dict[id]
+			break
+		}
+	}
+
+	if obj == nil {
+		return nil, errors.Errorf("field %s: font %s missing in default resources", f.name, id)
+	}
+
+	tf := loadTextFont(xRefTable, obj)
+	if tf.composite {
+		return nil, errors.Errorf("field %s: composite font %s not supported", f.name, tf.name)
+	}
+
+	fnt := &daFont{id: id, obj: obj, size: size, ops: ops, tf: tf, codes: map[rune]int{}}
+
+	for c := 255; c >= 0; c-- {
+		if r := []rune(tf.text([]byte{byte(c)})); len(r) == 1 {
+			fnt.codes[r[0]] = c
+		}
+	}
+
+	return fnt, nil
+}
+
+// encode returns the char codes for s.
+func (fnt *daFont) encode(s string) ([]byte, error) {
+
+	var b []byte
+
+	for _, r := range s {
+		c, ok := fnt.codes[r]
+		if !ok {
+			return nil, errors.Errorf("font %s can't encode %q", fnt.tf.name, r)
+		}
+		b = append(b, byte(c))
+	}
+
+	return b, nil
+}
+
+// width returns the width of the encoded text b for font size 1.
+func (fnt *daFont) width(b []byte) float64 {
+
+	w := 0.0
+	for _, c := range b {
+		w += fnt.tf.width([]byte{c})
+	}
+
+	return w
+}
+
+// wrap breaks encoded text into lines fitting width w for font size fs.
+func (fnt *daFont) wrap(s string, w, fs float64) ([][]byte, error) {
+
+	var lines [][]byte
+
+	for _, para := range strings.Split(strings.Replace(s, "\r\n", "\n", -1), "\n") {
+
+		var line []byte
+
+		for _, word := range strings.Fields(para) {
+
+			b, err := fnt.encode(word)
+			if err != nil {
+				return nil, err
+			}
+
+			if len(line) == 0 {
+				line = b
+				continue
+			}
+
+			sp, _ := fnt.encode(" ")
+			l := append(append(append([]byte{}, line...), sp...), b...)
+
+			if fnt.width(l)*fs > w {
+				lines = append(lines, line)
+				line = b
+				continue
+			}
+
+			line = l
+		}
+
+		lines = append(lines, line)
+	}
+
+	return lines, nil
+}
+
+// colorOp returns the operator for setting a color given by an array of 1, 3 or 4 components.
+func colorOp(xRefTable *XRefTable, o PDFObject, stroke bool) string {
+
+	a, _ := xRefTable.DereferenceArray(o)
+	if a == nil {
+		return ""
+	}
+
+	var ss []string
+	for _, o := range *a {
+		ss = append(ss, fmt.Sprintf("%.3f", xRefTable.DereferenceNumber(o)))
+	}
+
+	op := map[int]string{1: "g", 3: "rg", 4: "k"}[len(ss)]
+	if op == "" {
+		return ""
+	}
+
+	if stroke {
+		op = strings.ToUpper(op)
+	}
+
+	return strings.Join(ss, " ") + " " + op + " "
+}
+
+// appearanceBox returns the dimensions of the appearance stream of a widget and the matrix for its rotation.
+func appearanceBox(xRefTable *XRefTable, w *PDFDict) (width, height float64, matrix PDFArray) {
+
+	if a, _ := xRefTable.DereferenceArray(w.Dict["Rect"]); a != nil && len(*a) == 4 {
+		r := rect(xRefTable, *a)
+		width, height = math.Abs(r.Width()), math.Abs(r.Height())
+	}
+
+	matrix = NewIntegerArray(1, 0, 0, 1, 0, 0)
+
+	mk, _ := xRefTable.DereferenceDict(w.Dict["MK"])
+	if mk == nil {
+		return width, height, matrix
+	}
+
+	if r := mk.IntEntry("R"); r != nil {
+		switch (*r%360 + 360) % 360 {
+		case 90:
+			return height, width, NewIntegerArray(0, 1, -1, 0, 0, 0)
+		case 180:
+			return width, height, NewIntegerArray(-1, 0, 0, -1, 0, 0)
+		case 270:
+			return height, width, NewIntegerArray(0, -1, 1, 0, 0, 0)
+		}
+	}
+
+	return width, height, matrix
+}
+
+// borderWidth returns the border width of a widget.
+func borderWidth(xRefTable *XRefTable, w *PDFDict) float64 {
+
+	if bs, _ := xRefTable.DereferenceDict(w.Dict["BS"]); bs != nil {
+		if o, found := bs.Find("W"); found {
+			return xRefTable.DereferenceNumber(o)
+		}
+	}
+
+	if a, _ := xRefTable.DereferenceArray(w.Dict["Border"]); a != nil && len(*a) >= 3 {
+		return xRefTable.DereferenceNumber((*a)[2])
+	}
+
+	return 1
+}
+
+// fieldLine is a line of encoded text along with its position.
+type fieldLine struct {
+	x, y float64
+	b    []byte
+}
+
+// appearance creates the normal appearance stream of a text or choice field widget.
+// lines returns the text lines to show for the available width and height and the font size,
+// highlight optionally returns the rectangles (x, y, w, h) of selected list box options.
+func (f *formField) appearance(xRefTable *XRefTable, w widget, fnt *daFont,
+	lines func(w, h float64) ([]fieldLine, float64, error), highlight func(w, h, fs float64) [][4]float64) error {
+
+	width, height, matrix := appearanceBox(xRefTable, w.dict)
+
+	mk, _ := xRefTable.DereferenceDict(w.dict.Dict["MK"])
+
+	var b bytes.Buffer
+
+	b.WriteString("/Tx BMC q ")
+
+	bw := 0.0
+	if mk != nil {
+		if op := colorOp(xRefTable, mk.Dict["BG"], false); op != "" {
+			fmt.Fprintf(&b, "%s0 0 %.2f %.2f re f ", op, width, height)
+		}
+		if op := colorOp(xRefTable, mk.Dict["BC"], true); op != "" {
+			if bw = borderWidth(xRefTable, w.dict); bw > 0 {
+				fmt.Fprintf(&b, "%s%.2f w %.2f %.2f %.2f %.2f re S ", op, bw, bw/2, bw/2, width-bw, height-bw)
+			}
+		}
+	}
+
+	// Clip to the area within the border.
+	pad := bw + 1
+	iw, ih := width-2*pad, height-2*pad
+	fmt.Fprintf(&b, "%.2f %.2f %.2f %.2f re W n ", pad, pad, iw, ih)
+
+	tl, fs, err := lines(iw, ih)
+	if err != nil {
+		return errors.Wrapf(err, "field %s", f.name)
+	}
+
+	if highlight != nil {
+		for _, r := range highlight(iw, ih, fs) {
+			fmt.Fprintf(&b, "0.600 0.757 0.855 rg %.2f %.2f %.2f %.2f re f ", pad+r[0], pad+r[1], r[2], r[3])
+		}
+	}
+
+	fmt.Fprintf(&b, "BT /%s %.2f Tf %s ", fnt.id, fs, fnt.ops)
+	for _, l := range tl {
+		fmt.Fprintf(&b, "1 0 0 1 %.2f %.2f Tm <%X> Tj ", pad+l.x, pad+l.y, l.b)
+	}
+	b.WriteString("ET Q EMC")
+
+	sd := &PDFStreamDict{
+		PDFDict: PDFDict{
+			Dict: map[string]PDFObject{
+				"Type":    PDFName("XObject"),
+				"Subtype": PDFName("Form"),
+				"BBox":    NewRectangle(0, 0, width, height),
+				"Matrix":  matrix,
+				"Resources": PDFDict{
+					Dict: map[string]PDFObject{
+						"Font":    PDFDict{Dict: map[string]PDFObject{fnt.id: fnt.obj}},
+						"ProcSet": NewNameArray("PDF", "Text"),
+					},
+				},
+			},
+		},
+		Content: b.Bytes(),
+	}
+
+	err = encodeStream(sd)
+	if err != nil {
+		return err
+	}
+
+	indRef, err := xRefTable.IndRefForNewObject(*sd)
+	if err != nil {
+		return err
+	}
+
+	w.dict.Update("AP", PDFDict{Dict: map[string]PDFObject{"N": *indRef}})
+
+	return nil
+}
+
+// alignedX returns the horizontal offset of a line of width lw within width w according to the quadding of f.
+func (f *formField) alignedX(w, lw float64) float64 {
+
+	switch f.q {
+	case 1:
+		return (w - lw) / 2
+	case 2:
+		return w - lw - 1
+	}
+
+	return 1
+}
+
+// singleLine lays out one line of text vertically centered, auto sized text fits the available space.
+func (f *formField) singleLine(fnt *daFont, s string) func(w, h float64) ([]fieldLine, float64, error) {
+
+	return func(w, h float64) ([]fieldLine, float64, error) {
+
+		b, err := fnt.encode(s)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		fs := fnt.size
+		if fs == 0 {
+			fs = math.Min(h/1.15, 12)
+			if tw := fnt.width(b) * fs; tw > w-2 {
+				fs = math.Max(fs*(w-2)/tw, 4)
+			}
+		}
+
+		y := (h - 0.7*fs) / 2
+
+		// Comb fields divide the width into MaxLen equally spaced cells.
+		if f.ff&fieldComb > 0 && f.ff&(fieldMultiline|fieldPassword) == 0 && f.maxLen > 0 {
+			cw := (w + 2) / float64(f.maxLen)
+			var tl []fieldLine
+			for i, c := range b {
+				x := float64(i)*cw + (cw-fnt.width([]byte{c})*fs)/2 - 1
+				tl = append(tl, fieldLine{x, y, []byte{c}})
+			}
+			return tl, fs, nil
+		}
+
+		return []fieldLine{{f.alignedX(w, fnt.width(b)*fs), y, b}}, fs, nil
+	}
+}
+
+// multiLine lays out wrapped text starting at the top.
+func (f *formField) multiLine(fnt *daFont, s string) func(w, h float64) ([]fieldLine, float64, error) {
+
+	return func(w, h float64) ([]fieldLine, float64, error) {
+
+		fs := fnt.size
+		if fs == 0 {
+			fs = 12
+		}
+
+		ll, err := fnt.wrap(s, w-2, fs)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		var tl []fieldLine
+		for i, l := range ll {
+			y := h - 0.9*fs - float64(i)*1.15*fs
+			tl = append(tl, fieldLine{f.alignedX(w, fnt.width(l)*fs), y, l})
+		}
+
+		return tl, fs, nil
+	}
+}
+
+func (f *formField) fillText(xRefTable *XRefTable, form *PDFDict, v interface{}) error {
+
+	s, err := textValue(v)
+	if err != nil {
+		return errors.Wrapf(err, "field %s", f.name)
+	}
+
+	if f.maxLen > 0 && len([]rune(s)) > f.maxLen {
+		return errors.Errorf("field %s: value exceeds %d characters", f.name, f.maxLen)
+	}
+
+	t, err := NewTextString(s)
+	if err != nil {
+		return err
+	}
+
+	f.dict.Update("V", t)
+
+	fnt, err := f.font(xRefTable, form)
+	if err != nil {
+		return err
+	}
+
+	if f.ff&fieldPassword > 0 {
+		s = strings.Repeat("*", len([]rune(s)))
+	}
+
+	lines := f.singleLine(fnt, s)
+	if f.ff&fieldMultiline > 0 {
+		lines = f.multiLine(fnt, s)
+	}
+
+	for _, w := range f.widgets {
+		err = f.appearance(xRefTable, w, fnt, lines, nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (f *formField) fillButton(xRefTable *XRefTable, v interface{}) error {
+
+	if f.ff&fieldPushbutton > 0 {
+		return errors.Errorf("field %s: push buttons have no value", f.name)
+	}
+
+	states := f.onStates(xRefTable)
+
+	var state string
+
+	switch v := v.(type) {
+
+	case bool:
+		if f.ff&fieldRadio > 0 {
+			return errors.Errorf("field %s: radio buttons need the name of an option", f.name)
+		}
+		state = "Off"
+		if v && len(states) > 0 {
+			state = states[0]
+		}
+
+	case string:
+		state = v
+
+	default:
+		return errors.Errorf("field %s: unsupported value %v", f.name, v)
+	}
+
+	if state == "Off" && f.ff&(fieldRadio|fieldNoToggleOff) == fieldRadio|fieldNoToggleOff {
+		return errors.Errorf("field %s: needs one of %q", f.name, states)
+	}
+
+	if state != "Off" && !memberOf(state, states) {
+		return errors.Errorf("field %s: %s is not one of %q", f.name, state, states)
+	}
+
+	f.dict.Update("V", PDFName(state))
+
+	// The widget annotations select their appearance accordingly.
+	for _, w := range f.widgets {
+		as := "Off"
+		if memberOf(state, widgetStates(xRefTable, w.dict)) {
+			as = state
+		}
+		w.dict.Update("AS", PDFName(as))
+	}
+
+	return nil
+}
+
+func (f *formField) fillChoice(xRefTable *XRefTable, form *PDFDict, v interface{}) error {
+
+	var values []string
+
+	switch v := v.(type) {
+
+	case []interface{}:
+		for _, o := range v {
+			s, err := textValue(o)
+			if err != nil {
+				return errors.Wrapf(err, "field %s", f.name)
+			}
+			values = append(values, s)
+		}
+
+	case []string:
+		values = v
+
+	default:
+		s, err := textValue(v)
+		if err != nil {
+			return errors.Wrapf(err, "field %s", f.name)
+		}
+		values = []string{s}
+	}
+
+	combo := f.ff&fieldCombo > 0
+
+	if len(values) > 1 && (combo || f.ff&fieldMultiSelect == 0) {
+		return errors.Errorf("field %s: multiple selection not allowed", f.name)
+	}
+
+	var selected []int
+
+	for _, s := range values {
+		i := -1
+		for j, opt := range f.opts {
+			if opt == s {
+				i = j
+				break
+			}
+		}
+		if i < 0 && !(combo && f.ff&fieldEdit > 0) {
+			return errors.Errorf("field %s: %s is not one of %q", f.name, s, f.opts)
+		}
+		if i >= 0 {
+			selected = append(selected, i)
+		}
+	}
+
+	sort.Ints(selected)
+
+	a := PDFArray{}
+	for _, s := range values {
+		t, err := NewTextString(s)
+		if err != nil {
+			return err
+		}
+		a = append(a, t)
+	}
+
+	switch len(a) {
+	case 0:
+		f.dict.Delete("V")
+	case 1:
+		f.dict.Update("V", a[0])
+	default:
+		f.dict.Update("V", a)
+	}
+
+	// I holds the indices of the selected options of list boxes.
+	f.dict.Delete("I")
+	if !combo && len(selected) > 0 {
+		ii := PDFArray{}
+		for _, i := range selected {
+			ii = append(ii, PDFInteger(i))
+		}
+		f.dict.Insert("I", ii)
+	}
+
+	fnt, err := f.font(xRefTable, form)
+	if err != nil {
+		return err
+	}
+
+	if combo {
+		s := ""
+		if len(values) > 0 {
+			s = values[0]
+			if len(selected) > 0 {
+				s = f.display[selected[0]]
+			}
+		}
+		for _, w := range f.widgets {
+			err = f.appearance(xRefTable, w, fnt, f.singleLine(fnt, s), nil)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// List boxes show the options starting at the top index.
+	top := 0
+	if ti := f.dict.IntEntry("TI"); ti != nil && *ti >= 0 && *ti < len(f.opts) {
+		top = *ti
+	}
+
+	fontSize := func(fs float64) float64 {
+		if fs == 0 {
+			return 12
+		}
+		return fs
+	}
+
+	lines := func(w, h float64) ([]fieldLine, float64, error) {
+		fs := fontSize(fnt.size)
+		var tl []fieldLine
+		for i, s := range f.display[top:] {
+			b, err := fnt.encode(s)
+			if err != nil {
+				return nil, 0, err
+			}
+			y := h - 0.9*fs - float64(i)*1.15*fs
+			tl = append(tl, fieldLine{f.alignedX(w, fnt.width(b)*fs), y, b})
+		}
+		return tl, fs, nil
+	}
+
+	highlight := func(w, h, fs float64) [][4]float64 {
+		var rr [][4]float64
+		for _, i := range selected {
+			if i >= top {
+				y := h - float64(i-top+1)*1.15*fs
+				rr = append(rr, [4]float64{0, y, w, 1.15 * fs})
+			}
+		}
+		return rr
+	}
+
+	for _, w := range f.widgets {
+		err = f.appearance(xRefTable, w, fnt, lines, highlight)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// textValue converts a JSON value into a text value.
+func textValue(v interface{}) (string, error) {
+
+	switch v := v.(type) {
+
+	case string:
+		return v, nil
+
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+
+	case int:
+		return strconv.Itoa(v), nil
+
+	}
+
+	return "", errors.Errorf("unsupported value %v", v)
+}
+
+// FillForm sets the values of the fields of the interactive form of ctx and regenerates their appearances.
+// values maps fully qualified field names to strings, booleans for check boxes
+// or string slices for list boxes allowing multiple selection.
+func FillForm(ctx *PDFContext, values map[string]interface{}) error {
+
+	form, fields, err := formFields(ctx)
+	if err != nil {
+		return err
+	}
+
+	if form == nil {
+		return errors.New("FillForm: no interactive form available")
+	}
+
+	m := map[string]*formField{}
+	for _, f := range fields {
+		m[f.name] = f
+	}
+
+	var names []string
+	for k := range values {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+
+		f, ok := m[name]
+		if !ok {
+			return errors.Errorf("FillForm: unknown field %s", name)
+		}
+
+		log.Debug.Printf("FillForm: %s = %v\n", name, values[name])
+
+		v := values[name]
+
+		switch f.ft {
+
+		case "Tx":
+			err = f.fillText(ctx.XRefTable, form, v)
+
+		case "Btn":
+			err = f.fillButton(ctx.XRefTable, v)
+
+		case "Ch":
+			err = f.fillChoice(ctx.XRefTable, form, v)
+
+		default:
+			err = errors.Errorf("field %s: can't fill fields of type %s", name, f.ft)
+
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
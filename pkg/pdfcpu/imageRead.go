@@ -92,6 +92,95 @@ func createImageObject(xRefTable *XRefTable, buf, sm []byte, w, h int, cs string
 	return sd, nil
 }
 
+// createBilevelImageObject creates a 1 bit per pixel DeviceGray image object using Group 4 encoding.
+func createBilevelImageObject(buf []byte, w, h int) (*PDFStreamDict, error) {
+
+	parms := NewPDFDict()
+	parms.Insert("K", PDFInteger(-1))
+	parms.Insert("Columns", PDFInteger(w))
+	parms.Insert("Rows", PDFInteger(h))
+
+	sd := &PDFStreamDict{
+		PDFDict: PDFDict{
+			Dict: map[string]PDFObject{
+				"Type":             PDFName("XObject"),
+				"Subtype":          PDFName("Image"),
+				"Width":            PDFInteger(w),
+				"Height":           PDFInteger(h),
+				"BitsPerComponent": PDFInteger(1),
+				"ColorSpace":       PDFName(DeviceGrayCS),
+				"DecodeParms":      parms,
+			},
+		},
+		Content:        buf,
+		FilterPipeline: []PDFFilter{{Name: filter.CCITTFax, DecodeParms: &parms}}}
+
+	sd.InsertName("Filter", filter.CCITTFax)
+
+	err := encodeStream(sd)
+	if err != nil {
+		return nil, err
+	}
+
+	return sd, nil
+}
+
+// bilevel returns true if img consists of opaque black and white pixels only.
+func bilevel(img image.Image) bool {
+
+	switch img := img.(type) {
+
+	case *image.Gray:
+		for _, y := range img.Pix {
+			if y != 0x00 && y != 0xFF {
+				return false
+			}
+		}
+		return true
+
+	case *image.Paletted:
+		used := make([]bool, len(img.Palette))
+		for _, i := range img.Pix {
+			if int(i) >= len(used) {
+				return false
+			}
+			used[i] = true
+		}
+		for i, c := range img.Palette {
+			if !used[i] {
+				continue
+			}
+			r, g, b, a := c.RGBA()
+			if a != 0xFFFF || r != g || g != b || (r != 0 && r != 0xFFFF) {
+				return false
+			}
+		}
+		return true
+	}
+
+	return false
+}
+
+// writeBilevelImageBuf packs the pixels of a bilevel image using 1 bit per pixel, white pixels are represented by 1 bits.
+func writeBilevelImageBuf(img image.Image) []byte {
+
+	w := img.Bounds().Dx()
+	h := img.Bounds().Dy()
+	min := img.Bounds().Min
+	rowLen := (w + 7) / 8
+	buf := make([]byte, rowLen*h)
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if r, _, _, _ := img.At(min.X+x, min.Y+y).RGBA(); r != 0 {
+				buf[y*rowLen+x/8] |= 0x80 >> uint(x%8)
+			}
+		}
+	}
+
+	return buf
+}
+
 func writeRGBAImageBuf(img image.Image) []byte {
 
 	w := img.Bounds().Dx()
@@ -196,6 +285,11 @@ func imgToImageDict(xRefTable *XRefTable, img image.Image) (*PDFStreamDict, erro
 	w := img.Bounds().Dx()
 	h := img.Bounds().Dy()
 
+	// Scanned documents are usually black and white and compress much better using Group 4 encoding.
+	if bilevel(img) {
+		return createBilevelImageObject(writeBilevelImageBuf(img), w, h)
+	}
+
 	var buf []byte
 	var sm []byte
 	var cs string
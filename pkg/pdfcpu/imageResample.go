@@ -0,0 +1,499 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/hhrutter/pdfcpu/pkg/filter"
+	"github.com/hhrutter/pdfcpu/pkg/log"
+	"github.com/pkg/errors"
+)
+
+// DefaultImageDPI is the target resolution for resampling images unless specified.
+const DefaultImageDPI = 150
+
+// Images are resampled only if their effective resolution exceeds the target resolution by this factor.
+const resampleThreshold = 1.5
+
+// ResampledImage represents the result of resampling an image during optimization.
+type ResampledImage struct {
+	Width, Height       int     // in pixels before resampling.
+	NewWidth, NewHeight int     // in pixels after resampling.
+	DPI                 float64 // effective resolution before resampling.
+	Filter              string  // the filter used for reencoding.
+	Size, NewSize       int64   // stream length in bytes.
+}
+
+// Saved returns the number of bytes saved by resampling.
+func (ri ResampledImage) Saved() int64 {
+	return ri.Size - ri.NewSize
+}
+
+// ParseImageResampling parses an image optimization command string like "dpi=150,quality=75" into c.
+func ParseImageResampling(s string, c *Configuration) error {
+
+	c.ImageDPI = DefaultImageDPI
+	c.ImageQuality = filter.DefaultJPEGQuality
+
+	for _, s := range strings.Split(s, ",") {
+
+		ss := strings.Split(s, "=")
+		if len(ss) != 2 {
+			return errors.Errorf("illegal image optimization: %s, e.g. dpi=150,quality=75", s)
+		}
+
+		k := strings.TrimSpace(ss[0])
+		v, err := strconv.Atoi(strings.TrimSpace(ss[1]))
+		if err != nil {
+			return errors.Errorf("illegal value for %s: %s", k, ss[1])
+		}
+
+		switch k {
+
+		case "dpi":
+			if v <= 0 {
+				return errors.Errorf("illegal dpi: must be > 0, %d", v)
+			}
+			c.ImageDPI = v
+
+		case "quality":
+			if v < 1 || v > 100 {
+				return errors.Errorf("illegal quality: 1 <= quality <= 100, %d", v)
+			}
+			c.ImageQuality = v
+
+		default:
+			return errors.Errorf("illegal image optimization key: %s, use dpi or quality", k)
+		}
+	}
+
+	return nil
+}
+
+// imageResolutions returns the minimum effective horizontal and vertical resolution of each image painted by page content.
+// The displayed size of an image is derived from the CTM in effect for the Do operator.
+func imageResolutions(ctx *PDFContext) (map[int][2]float64, error) {
+
+	m := map[int][2]float64{}
+
+	for p := 1; p <= ctx.PageCount; p++ {
+
+		pageDict, inhPAttrs, err := ctx.PageDict(p)
+		if err != nil {
+			return nil, err
+		}
+		if pageDict == nil {
+			continue
+		}
+
+		b, err := pageContent(ctx.XRefTable, pageDict)
+		if err != nil {
+			return nil, err
+		}
+
+		te := newTextExtractor(ctx.XRefTable)
+
+		te.image = func(objNr int, ctm matrix) {
+
+			io, found := ctx.Optimize.ImageObjects[objNr]
+			if !found {
+				return
+			}
+
+			w, h := io.ImageDict.IntEntry("Width"), io.ImageDict.IntEntry("Height")
+			if w == nil || h == nil {
+				return
+			}
+
+			// The image space unit square is mapped to the displayed image.
+			dw, dh := math.Hypot(ctm[0][0], ctm[0][1]), math.Hypot(ctm[1][0], ctm[1][1])
+			if dw == 0 || dh == 0 {
+				return
+			}
+
+			dpi := [2]float64{float64(*w) * 72 / dw, float64(*h) * 72 / dh}
+
+			if r, found := m[objNr]; found {
+				dpi[0], dpi[1] = math.Min(dpi[0], r[0]), math.Min(dpi[1], r[1])
+			}
+
+			m[objNr] = dpi
+		}
+
+		err = te.processContent(b, inhPAttrs.resources)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return m, nil
+}
+
+// collectImages adds the object numbers of all images reachable from o to m.
+// Parent links are not followed.
+func collectImages(xRefTable *XRefTable, o PDFObject, m, visited map[int]bool) {
+
+	if indRef, ok := o.(PDFIndirectRef); ok {
+
+		objNr := indRef.ObjectNumber.Value()
+		if visited[objNr] {
+			return
+		}
+		visited[objNr] = true
+
+		o, _ = xRefTable.Dereference(indRef)
+
+		if sd, ok := o.(PDFStreamDict); ok {
+			if st := sd.NameEntry("Subtype"); st != nil && *st == "Image" {
+				m[objNr] = true
+			}
+		}
+	}
+
+	var d PDFDict
+
+	switch o := o.(type) {
+
+	case PDFDict:
+		d = o
+
+	case PDFStreamDict:
+		d = o.PDFDict
+
+	case PDFArray:
+		for _, o := range o {
+			collectImages(xRefTable, o, m, visited)
+		}
+		return
+
+	default:
+		return
+	}
+
+	for k, v := range d.Dict {
+		if k != "Parent" && k != "P" {
+			collectImages(xRefTable, v, m, visited)
+		}
+	}
+}
+
+// unwalkedImages returns the object numbers of images painted from places imageResolutions does not look at:
+// annotation appearances, patterns, soft masks and thumbnails.
+func unwalkedImages(ctx *PDFContext) map[int]bool {
+
+	m, visited := map[int]bool{}, map[int]bool{}
+
+	for _, entry := range ctx.Table {
+
+		if entry.Free || entry.Object == nil {
+			continue
+		}
+
+		var d PDFDict
+
+		switch o := entry.Object.(type) {
+		case PDFDict:
+			d = o
+		case PDFStreamDict:
+			d = o.PDFDict
+		default:
+			continue
+		}
+
+		for _, k := range []string{"AP", "Thumb"} {
+			if o, found := d.Find(k); found {
+				collectImages(ctx.XRefTable, o, m, visited)
+			}
+		}
+
+		res, _ := ctx.DereferenceDict(d.Dict["Resources"])
+		if res == nil {
+			continue
+		}
+
+		if o, found := res.Find("Pattern"); found {
+			collectImages(ctx.XRefTable, o, m, visited)
+		}
+
+		gs, _ := ctx.DereferenceDict(res.Dict["ExtGState"])
+		if gs == nil {
+			continue
+		}
+
+		for _, o := range gs.Dict {
+			if d, _ := ctx.DereferenceDict(o); d != nil {
+				if o, found := d.Find("SMask"); found {
+					collectImages(ctx.XRefTable, o, m, visited)
+				}
+			}
+		}
+	}
+
+	return m
+}
+
+// resamplableImage returns the number of color components of sd if it is an 8 bit image eligible for resampling.
+func resamplableImage(xRefTable *XRefTable, sd *PDFStreamDict) int {
+
+	if bpc := sd.IntEntry("BitsPerComponent"); bpc == nil || *bpc != 8 {
+		return 0
+	}
+
+	if imageMask := sd.BooleanEntry("ImageMask"); imageMask != nil && *imageMask {
+		return 0
+	}
+
+	// Averaging breaks color key masking.
+	if o, _ := xRefTable.Dereference(sd.Dict["Mask"]); o != nil {
+		if _, ok := o.(PDFArray); ok {
+			return 0
+		}
+	}
+
+	cs, err := xRefTable.Dereference(sd.Dict["ColorSpace"])
+	if err != nil || cs == nil {
+		return 0
+	}
+
+	// Averaging color table indices does not work.
+	if a, ok := cs.(PDFArray); ok && len(a) > 0 {
+		if n, ok := a[0].(PDFName); ok && (n == "Indexed" || n == "I") {
+			return 0
+		}
+	}
+
+	n := imageComponents(xRefTable, cs)
+	if n < 1 || n > 4 {
+		return 0
+	}
+
+	return n
+}
+
+// resample scales w x h pixels of n interleaved 8 bit components down to nw x nh pixels by averaging.
+func resample(b []byte, w, h, n, nw, nh int) []byte {
+
+	r := make([]byte, nw*nh*n)
+	sum := make([]int, n)
+
+	for y := 0; y < nh; y++ {
+
+		y0, y1 := y*h/nh, (y+1)*h/nh
+		if y1 == y0 {
+			y1++
+		}
+
+		for x := 0; x < nw; x++ {
+
+			x0, x1 := x*w/nw, (x+1)*w/nw
+			if x1 == x0 {
+				x1++
+			}
+
+			for i := range sum {
+				sum[i] = 0
+			}
+
+			for yy := y0; yy < y1; yy++ {
+				for i, v := range b[(yy*w+x0)*n : (yy*w+x1)*n] {
+					sum[i%n] += int(v)
+				}
+			}
+
+			cnt := (y1 - y0) * (x1 - x0)
+			for i, s := range sum {
+				r[(y*nw+x)*n+i] = byte((s + cnt/2) / cnt)
+			}
+		}
+	}
+
+	return r
+}
+
+// photographic returns true if the n component pixels of b use more than 256 distinct colors.
+func photographic(b []byte, n int) bool {
+
+	colors := map[uint32]bool{}
+
+	for i := 0; i+n <= len(b); i += n {
+		var c uint32
+		for _, v := range b[i : i+n] {
+			c = c<<8 | uint32(v)
+		}
+		colors[c] = true
+		if len(colors) > 256 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// imageSamples returns the decoded samples of an image.
+func imageSamples(xRefTable *XRefTable, sd *PDFStreamDict) ([]byte, error) {
+
+	if sd.FilterPipeline == nil {
+		return sd.Raw, nil
+	}
+
+	err := decodeImageStream(xRefTable, sd)
+	if err != nil {
+		return nil, err
+	}
+
+	return sd.Content, nil
+}
+
+// resampleImage downsamples an image stream dict to nw x nh pixels and reencodes it.
+// Photographic content and JPEG images are encoded using DCT, all other content using Flate.
+func resampleImage(ctx *PDFContext, sd *PDFStreamDict, n, nw, nh int) (*PDFStreamDict, error) {
+
+	w, h := *sd.IntEntry("Width"), *sd.IntEntry("Height")
+
+	b, err := imageSamples(ctx.XRefTable, sd)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(b) < w*h*n {
+		return nil, errors.Errorf("resampleImage: image data too short: %d bytes for %dx%dx%d", len(b), w, h, n)
+	}
+
+	b = resample(b, w, h, n, nw, nh)
+
+	fpl := sd.FilterPipeline
+	jpeg := len(fpl) > 0 && fpl[len(fpl)-1].Name == filter.DCT
+
+	sd1 := &PDFStreamDict{PDFDict: NewPDFDict(), Content: b}
+
+	if (n == 1 || n == 3) && (jpeg || photographic(b, n)) {
+		q := ctx.ImageQuality
+		if q <= 0 {
+			q = filter.DefaultJPEGQuality
+		}
+		parms := NewPDFDict()
+		parms.Insert("Quality", PDFInteger(q))
+		sd1.Insert("Width", PDFInteger(nw))
+		sd1.Insert("Height", PDFInteger(nh))
+		sd1.FilterPipeline = []PDFFilter{{Name: filter.DCT, DecodeParms: &parms}}
+	} else {
+		sd1.FilterPipeline = []PDFFilter{{Name: filter.Flate}}
+	}
+
+	err = encodeStream(sd1)
+	if err != nil {
+		return nil, err
+	}
+
+	return sd1, nil
+}
+
+// resampleImages downsamples images whose effective resolution exceeds the configured resolution
+// and reencodes them whenever this saves space.
+func resampleImages(ctx *PDFContext) error {
+
+	log.Debug.Println("resampleImages begin")
+
+	res, err := imageResolutions(ctx)
+	if err != nil {
+		return err
+	}
+
+	// The effective resolution of images also painted elsewhere is unknown.
+	skip := unwalkedImages(ctx)
+
+	target := float64(ctx.ImageDPI)
+
+	for objNr, dpi := range res {
+
+		if dpi[0] <= target*resampleThreshold && dpi[1] <= target*resampleThreshold {
+			continue
+		}
+
+		if skip[objNr] {
+			log.Info.Printf("resampleImages: obj#%d: skipped, also used outside of page content\n", objNr)
+			continue
+		}
+
+		sd := ctx.Optimize.ImageObjects[objNr].ImageDict
+
+		n := resamplableImage(ctx.XRefTable, sd)
+		if n == 0 || sd.StreamLength == nil {
+			continue
+		}
+
+		w, h := *sd.IntEntry("Width"), *sd.IntEntry("Height")
+
+		nw, nh := w, h
+		if dpi[0] > target {
+			nw = int(math.Max(1, math.Round(float64(w)*target/dpi[0])))
+		}
+		if dpi[1] > target {
+			nh = int(math.Max(1, math.Round(float64(h)*target/dpi[1])))
+		}
+
+		sd1, err := resampleImage(ctx, sd, n, nw, nh)
+		if err != nil {
+			// Leave images alone we cannot decode.
+			log.Info.Printf("resampleImages: obj#%d: skipped, %v\n", objNr, err)
+			continue
+		}
+
+		if *sd1.StreamLength >= *sd.StreamLength {
+			continue
+		}
+
+		ri := &ResampledImage{
+			Width:     w,
+			Height:    h,
+			NewWidth:  nw,
+			NewHeight: nh,
+			DPI:       math.Min(dpi[0], dpi[1]),
+			Filter:    sd1.FilterPipeline[0].Name,
+			Size:      *sd.StreamLength,
+			NewSize:   *sd1.StreamLength,
+		}
+
+		log.Debug.Printf("resampleImages: obj#%d %dx%d -> %dx%d, %d -> %d bytes\n", objNr, w, h, nw, nh, ri.Size, ri.NewSize)
+
+		sd.Update("Width", PDFInteger(nw))
+		sd.Update("Height", PDFInteger(nh))
+		sd.Delete("Filter")
+		sd.Delete("DecodeParms")
+		sd.InsertName("Filter", ri.Filter)
+		sd.Update("Length", PDFInteger(ri.NewSize))
+		sd.FilterPipeline = []PDFFilter{{Name: ri.Filter}}
+		sd.Content = sd1.Content
+		sd.Raw = sd1.Raw
+		sd.StreamLength = sd1.StreamLength
+
+		entry, found := ctx.FindTableEntry(objNr, 0)
+		if !found {
+			return errors.Errorf("resampleImages: obj#%d not found", objNr)
+		}
+		entry.Object = *sd
+
+		ctx.Optimize.ResampledImages[objNr] = ri
+	}
+
+	log.Debug.Println("resampleImages end")
+
+	return nil
+}
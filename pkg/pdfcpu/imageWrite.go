@@ -149,18 +149,19 @@ func decodePixelColorValue(p uint8, bpc, c int, decode []colValRange) uint8 {
 	// p ...the color value for this pixel
 	// c ...applicable index of a color component in the decode array for this pixel.
 
+	q := 1
+	for i := 1; i < bpc; i++ {
+		q = 2*q + 1
+	}
+
 	if decode == nil {
-		return p
+		// Scale to 8 bits.
+		return uint8(int(p) * 255 / q)
 	}
 
 	min := decode[c].min
 	max := decode[c].max
 
-	q := 1
-	for i := 1; i < bpc; i++ {
-		q = 2*q + 1
-	}
-
 	v := min + (float64(p) * (max - min) / float64(q))
 
 	if decode[c].inv {
@@ -270,6 +271,94 @@ func writeImgToJPX(filename string, sd *PDFStreamDict) (string, error) {
 	return filename, ioutil.WriteFile(filename, sd.Raw, os.ModePerm)
 }
 
+// writeCCITTToTIFF writes the still compressed data of a CCITTFax encoded image to a TIFF file.
+func writeCCITTToTIFF(filename string, sd *PDFStreamDict, w, h int, inverted bool) (string, error) {
+
+	filename += ".tif"
+
+	parms := parmsForFilter(sd.FilterPipeline[0].DecodeParms)
+
+	opt := tiff.CCITTOptions{
+		K:                parms["K"],
+		EncodedByteAlign: parms["EncodedByteAlign"] == 1,
+		BlackIsZero:      (parms["BlackIs1"] == 1) != inverted,
+	}
+
+	f, err := os.Create(filename)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	return filename, tiff.EncodeCCITT(f, sd.Raw, w, h, opt)
+}
+
+// writeCCITTFaxEncodedImage writes a grayscale PNG file for a decoded CCITTFax encoded image,
+// otherwise a CCITT compressed TIFF file.
+func writeCCITTFaxEncodedImage(filename string, sd *PDFStreamDict, objNr int) (string, error) {
+
+	w := sd.IntEntry("Width")
+	h := sd.IntEntry("Height")
+	if w == nil || h == nil {
+		return "", errors.Errorf("writeCCITTFaxEncodedImage: objNr=%d missing image dimensions", objNr)
+	}
+
+	// Decode [1 0] inverts the image.
+	decode := decodeArr(sd.PDFArrayEntry("Decode"))
+	inverted := len(decode) > 0 && decode[0].inv
+
+	if sd.Content == nil {
+		return writeCCITTToTIFF(filename, sd, *w, *h, inverted)
+	}
+
+	log.Debug.Printf("writeCCITTFaxEncodedImage: objNr=%d w=%d h=%d buflen=%d\n", objNr, *w, *h, len(sd.Content))
+
+	return writeBilevelImageToPNG(filename, sd.Content, *w, *h, inverted)
+}
+
+// writeJBIG2EncodedImage writes a grayscale PNG file for a decoded JBIG2 encoded image.
+func writeJBIG2EncodedImage(filename string, sd *PDFStreamDict, objNr int) (string, error) {
+
+	w := sd.IntEntry("Width")
+	h := sd.IntEntry("Height")
+	if w == nil || h == nil {
+		return "", errors.Errorf("writeJBIG2EncodedImage: objNr=%d missing image dimensions", objNr)
+	}
+
+	if sd.Content == nil {
+		log.Info.Printf("writeJBIG2EncodedImage: objNr=%d unable to decode image\n", objNr)
+		return "", nil
+	}
+
+	decode := decodeArr(sd.PDFArrayEntry("Decode"))
+	inverted := len(decode) > 0 && decode[0].inv
+
+	log.Debug.Printf("writeJBIG2EncodedImage: objNr=%d w=%d h=%d buflen=%d\n", objNr, *w, *h, len(sd.Content))
+
+	return writeBilevelImageToPNG(filename, sd.Content, *w, *h, inverted)
+}
+
+// writeBilevelImageToPNG writes a grayscale PNG file for 1 bit per pixel image data.
+func writeBilevelImageToPNG(filename string, b []byte, w, h int, inverted bool) (string, error) {
+
+	rowLen := (w + 7) / 8
+
+	img := image.NewGray(image.Rect(0, 0, w, h))
+
+	// Both for images and image masks a 0 bit is black unless inverted.
+	for y := 0; y < h && (y+1)*rowLen <= len(b); y++ {
+		for x := 0; x < w; x++ {
+			p := b[y*rowLen+x/8] >> uint(7-x%8) & 1
+			if inverted {
+				p ^= 1
+			}
+			img.Pix[y*img.Stride+x] = p * 0xFF
+		}
+	}
+
+	return writeImgToPNG(filename, img)
+}
+
 func writeImgToTIFF(filename string, img *image.CMYK) (string, error) {
 
 	filename += ".tif"
@@ -737,6 +826,12 @@ func WriteImage(xRefTable *XRefTable, filename string, sd *PDFStreamDict, objNr
 		}
 		return fn, err
 
+	case filter.CCITTFax:
+		return writeCCITTFaxEncodedImage(filename, sd, objNr)
+
+	case filter.JBIG2:
+		return writeJBIG2EncodedImage(filename, sd, objNr)
+
 	case filter.DCT:
 		return writeImgToJPG(filename, sd)
 
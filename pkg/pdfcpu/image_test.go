@@ -17,7 +17,9 @@ limitations under the License.
 package pdfcpu
 
 import (
+	"bytes"
 	"fmt"
+	"image/png"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -415,3 +417,124 @@ func TestReadTIFFWritePNG(t *testing.T) {
 	}
 
 }
+
+func TestReadBilevelPNG(t *testing.T) {
+
+	fileName := filepath.Join(inDir, "DeviceGray.png")
+
+	// DeviceGray.png is black and white only.
+	sd, err := ReadPNGFile(xRefTable, fileName)
+	if err != nil {
+		t.Fatalf("err: %v\n", err)
+	}
+
+	if f := sd.NameEntry("Filter"); f == nil || *f != filter.CCITTFax {
+		t.Fatalf("expected Group 4 encoding: %s\n", sd)
+	}
+
+	f, err := os.Open(fileName)
+	if err != nil {
+		t.Fatalf("err: %v\n", err)
+	}
+	defer f.Close()
+
+	img, err := png.Decode(f)
+	if err != nil {
+		t.Fatalf("err: %v\n", err)
+	}
+
+	sd.Content = nil
+
+	err = decodeStream(sd)
+	if err != nil {
+		t.Fatalf("err: %v\n", err)
+	}
+
+	w := img.Bounds().Dx()
+	rowLen := (w + 7) / 8
+
+	for y := 0; y < img.Bounds().Dy(); y++ {
+		for x := 0; x < w; x++ {
+			r, _, _, _ := img.At(x, y).RGBA()
+			if bit := sd.Content[y*rowLen+x/8] >> uint(7-x%8) & 1; (bit == 1) != (r != 0) {
+				t.Fatalf("pixel mismatch at x=%d y=%d\n", x, y)
+			}
+		}
+	}
+
+}
+
+func TestDecodeJBIG2Globals(t *testing.T) {
+
+	// A 16x4 bilevel image where 1 bits represent black pixels.
+	w, h := 16, 4
+	black := []byte{0xFF, 0x00, 0x0F, 0xF0, 0x3C, 0x3C, 0x00, 0xFF}
+
+	f, err := filter.NewFilter(filter.CCITTFax, map[string]int{"K": -1, "Columns": w, "Rows": h, "BlackIs1": 1})
+	if err != nil {
+		t.Fatalf("err: %v\n", err)
+	}
+
+	mmr, err := f.Encode(bytes.NewReader(black))
+	if err != nil {
+		t.Fatalf("err: %v\n", err)
+	}
+
+	segment := func(nr, typ int, data []byte) []byte {
+		b := []byte{0, 0, 0, byte(nr), byte(typ), 0, 1, 0, 0, 0, byte(len(data))}
+		return append(b, data...)
+	}
+
+	// The page information segment is part of the globals.
+	globals := segment(0, 48, []byte{0, 0, 0, byte(w), 0, 0, 0, byte(h), 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0})
+
+	// An immediate generic region using MMR.
+	region := segment(1, 38, append([]byte{0, 0, 0, byte(w), 0, 0, 0, byte(h), 0, 0, 0, 0, 0, 0, 0, 0, 0, 1}, mmr.Bytes()...))
+
+	indRef, err := xRefTable.IndRefForNewObject(PDFStreamDict{PDFDict: NewPDFDict(), Raw: globals})
+	if err != nil {
+		t.Fatalf("err: %v\n", err)
+	}
+
+	parms := NewPDFDict()
+	parms.Insert("JBIG2Globals", *indRef)
+
+	sd := &PDFStreamDict{
+		PDFDict:        NewPDFDict(),
+		Raw:            region,
+		FilterPipeline: []PDFFilter{{Name: filter.JBIG2, DecodeParms: &parms}}}
+
+	err = decodeImageStream(xRefTable, sd)
+	if err != nil {
+		t.Fatalf("err: %v\n", err)
+	}
+
+	// Decoded 0 bits represent black pixels.
+	for i, b := range black {
+		if sd.Content[i] != ^b {
+			t.Fatalf("got % X, want inverse of % X\n", sd.Content, black)
+		}
+	}
+
+}
+
+func TestResampleImage(t *testing.T) {
+
+	// 4x2 RGB pixels
+	b := []byte{
+		0, 0, 0, 10, 20, 30, 100, 100, 100, 255, 255, 255,
+		2, 4, 6, 12, 20, 30, 100, 100, 100, 200, 200, 200,
+	}
+
+	got := resample(b, 4, 2, 3, 2, 1)
+	want := []byte{6, 11, 17, 164, 164, 164}
+
+	if !bytes.Equal(got, want) {
+		t.Fatalf("got % X, want % X\n", got, want)
+	}
+
+	if photographic(got, 3) {
+		t.Fatal("2 colors are not photographic")
+	}
+
+}
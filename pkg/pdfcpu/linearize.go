@@ -0,0 +1,1223 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+// Linearized PDF files aka "Fast Web View", see Annex F.
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/hhrutter/pdfcpu/pkg/filter"
+	"github.com/hhrutter/pdfcpu/pkg/log"
+	"github.com/pkg/errors"
+)
+
+// maxOffset is a placeholder for offsets and lengths not yet known.
+// Fixed size entries are padded to the width of this value.
+const maxOffset = 9999999999
+
+// bitWriter packs hint table items into a bit stream, see F.4.
+type bitWriter struct {
+	buf bytes.Buffer
+	b   byte
+	n   uint // bits used in b
+}
+
+func (w *bitWriter) writeBits(v, nbits int) {
+
+	for i := nbits - 1; i >= 0; i-- {
+		w.b = w.b<<1 | byte(v>>uint(i)&1)
+		w.n++
+		if w.n == 8 {
+			w.buf.WriteByte(w.b)
+			w.b, w.n = 0, 0
+		}
+	}
+}
+
+// align pads the current byte with zero bits.
+// Each item of a hint table starts at a byte boundary.
+func (w *bitWriter) align() {
+
+	if w.n > 0 {
+		w.buf.WriteByte(w.b << (8 - w.n))
+		w.b, w.n = 0, 0
+	}
+}
+
+func (w *bitWriter) bytes() []byte {
+	w.align()
+	return w.buf.Bytes()
+}
+
+// bitsNeeded returns the number of bits needed to represent v.
+func bitsNeeded(v int) int {
+
+	n := 0
+	for ; v > 0; v >>= 1 {
+		n++
+	}
+
+	return n
+}
+
+func minMax(vals []int) (min, max int) {
+
+	for i, v := range vals {
+		if i == 0 || v < min {
+			min = v
+		}
+		if i == 0 || v > max {
+			max = v
+		}
+	}
+
+	return min, max
+}
+
+// linearization represents the object layout of a linearized file, see F.3.
+// All object numbers refer to the object numbering of the document read.
+type linearization struct {
+	ctx *PDFContext
+
+	pages   []int  // page dict object numbers in page order.
+	isPage  IntSet // all page dicts.
+	encrypt int    // encryption dict, 0 if not written.
+
+	part4 []int   // document catalog and other objects needed for opening the document.
+	part6 []int   // first page section starting with the first page dict.
+	part7 [][]int // remaining pages, each page dict followed by its private objects.
+	part8 []int   // objects shared by pages other than the first page.
+	part9 []int   // all other objects, outlines and document info first.
+
+	outlines int // number of objects of the outline hierarchy at the start of part 9.
+	info     int // number of objects of the document information dict following the outlines.
+
+	assigned IntSet      // objects already assigned to a part.
+	sharedBy map[int]int // number of pages referencing an object.
+	objs     [][]int     // objects referenced by each page including the page dict.
+}
+
+func (l *linearization) assign(objNr int, part *[]int) {
+	*part = append(*part, objNr)
+	l.assigned[objNr] = true
+}
+
+// inlineStreamLength replaces an indirect stream length by its value.
+// This prevents the length object from being written along with the stream dict.
+func inlineStreamLength(sd PDFStreamDict) {
+
+	if sd.IndirectRefEntry("Length") != nil && sd.StreamLength != nil {
+		sd.Update("Length", PDFInteger(*sd.StreamLength))
+	}
+}
+
+// walk traverses all objects reachable from o in depth first order.
+// visit gets called once for every object reached and returns true for objects to be traversed.
+// Dict keys get processed in sorted order for a reproducible layout.
+func (l *linearization) walk(o PDFObject, skipParent bool, visited IntSet, visit func(objNr int) bool) {
+
+	switch o := o.(type) {
+
+	case PDFIndirectRef:
+		objNr := o.ObjectNumber.Value()
+		if visited[objNr] {
+			return
+		}
+		visited[objNr] = true
+		if !visit(objNr) {
+			return
+		}
+		entry, found := l.ctx.FindTableEntryLight(objNr)
+		if !found || entry.Free {
+			return
+		}
+		l.walk(entry.Object, skipParent, visited, visit)
+
+	case PDFDict:
+		l.walkDict(o, skipParent, visited, visit)
+
+	case PDFStreamDict:
+		inlineStreamLength(o)
+		l.walkDict(o.PDFDict, skipParent, visited, visit)
+
+	case PDFArray:
+		for _, v := range o {
+			l.walk(v, skipParent, visited, visit)
+		}
+
+	}
+}
+
+func (l *linearization) walkDict(d PDFDict, skipParent bool, visited IntSet, visit func(objNr int) bool) {
+
+	var keys []string
+	for k := range d.Dict {
+		if skipParent && k == "Parent" {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		l.walk(d.Dict[k], skipParent, visited, visit)
+	}
+}
+
+// pageDictObjNrs returns the object numbers of all page dicts reachable from the page tree node objNr in page order.
+func (l *linearization) pageDictObjNrs(objNr int, visited IntSet) error {
+
+	if visited[objNr] {
+		return errors.Errorf("linearize: page tree cycle at obj #%d", objNr)
+	}
+	visited[objNr] = true
+
+	d, err := l.ctx.DereferenceDict(*NewPDFIndirectRef(objNr, 0))
+	if err != nil {
+		return err
+	}
+	if d == nil {
+		return errors.Errorf("linearize: missing page tree node obj #%d", objNr)
+	}
+
+	if d.Type() != nil && *d.Type() == "Page" {
+		l.pages = append(l.pages, objNr)
+		l.isPage[objNr] = true
+		return nil
+	}
+
+	kids := d.PDFArrayEntry("Kids")
+	if kids == nil {
+		return errors.Errorf("linearize: corrupt page tree node obj #%d", objNr)
+	}
+
+	for _, o := range *kids {
+		indRef, ok := o.(PDFIndirectRef)
+		if !ok {
+			return errors.Errorf("linearize: corrupt page tree node obj #%d", objNr)
+		}
+		err = l.pageDictObjNrs(indRef.ObjectNumber.Value(), visited)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// collectDocumentObjects assigns the document catalog and all objects needed for opening the document to part 4.
+func (l *linearization) collectDocumentObjects(rootDict *PDFDict) {
+
+	l.assign(l.ctx.Root.ObjectNumber.Value(), &l.part4)
+
+	keys := []string{"ViewerPreferences", "PageMode", "Threads", "OpenAction", "AcroForm"}
+
+	if pm := rootDict.NameEntry("PageMode"); pm != nil && *pm == "UseOutlines" {
+		keys = append(keys, "Outlines")
+	}
+
+	visited := IntSet{}
+
+	for _, k := range keys {
+		o, found := rootDict.Find(k)
+		if !found {
+			continue
+		}
+		l.walk(o, true, visited, func(objNr int) bool {
+			if l.isPage[objNr] || l.assigned[objNr] {
+				return false
+			}
+			l.assign(objNr, &l.part4)
+			return true
+		})
+	}
+
+	if l.encrypt > 0 {
+		l.assign(l.encrypt, &l.part4)
+	}
+}
+
+// collectPageObjects assigns all objects used by pages to parts 6, 7 and 8.
+func (l *linearization) collectPageObjects() {
+
+	for _, pageObjNr := range l.pages {
+
+		var objs []int
+
+		l.walk(*NewPDFIndirectRef(pageObjNr, 0), true, IntSet{}, func(objNr int) bool {
+			// Stop at other pages and document level objects.
+			if objNr != pageObjNr && l.isPage[objNr] || l.assigned[objNr] {
+				return false
+			}
+			objs = append(objs, objNr)
+			l.sharedBy[objNr]++
+			return true
+		})
+
+		l.objs = append(l.objs, objs)
+	}
+
+	for i, objs := range l.objs {
+
+		if i == 0 {
+			l.part6 = append(l.part6, objs...)
+			continue
+		}
+
+		var private []int
+		for _, objNr := range objs {
+			if l.sharedBy[objNr] == 1 {
+				private = append(private, objNr)
+			}
+		}
+		l.part7 = append(l.part7, private)
+	}
+
+	for _, objNr := range l.part6 {
+		l.assigned[objNr] = true
+	}
+
+	for _, objs := range l.part7 {
+		for _, objNr := range objs {
+			l.assigned[objNr] = true
+		}
+	}
+
+	for _, objs := range l.objs[1:] {
+		for _, objNr := range objs {
+			if !l.assigned[objNr] {
+				l.assign(objNr, &l.part8)
+			}
+		}
+	}
+}
+
+// collectGroup assigns all unassigned objects reachable from o to part 9 and returns their number.
+func (l *linearization) collectGroup(o PDFObject) int {
+
+	n := len(l.part9)
+
+	l.walk(o, true, IntSet{}, func(objNr int) bool {
+		if l.isPage[objNr] || l.assigned[objNr] {
+			return false
+		}
+		l.assign(objNr, &l.part9)
+		return true
+	})
+
+	return len(l.part9) - n
+}
+
+// collectOtherObjects assigns all remaining objects to part 9.
+func (l *linearization) collectOtherObjects(rootDict *PDFDict) {
+
+	if o, found := rootDict.Find("Outlines"); found {
+		l.outlines = l.collectGroup(o)
+	}
+
+	if l.ctx.Info != nil {
+		l.info = l.collectGroup(*l.ctx.Info)
+	}
+
+	visited := IntSet{}
+
+	visit := func(objNr int) bool {
+		if !l.assigned[objNr] {
+			l.assign(objNr, &l.part9)
+		}
+		return true
+	}
+
+	l.walk(*l.ctx.Root, false, visited, visit)
+
+	if l.ctx.AdditionalStreams != nil {
+		l.walk(*l.ctx.AdditionalStreams, false, visited, visit)
+	}
+}
+
+func newLinearization(ctx *PDFContext) (*linearization, error) {
+
+	l := &linearization{
+		ctx:      ctx,
+		isPage:   IntSet{},
+		assigned: IntSet{},
+		sharedBy: map[int]int{},
+	}
+
+	rootDict, err := ctx.Catalog()
+	if err != nil {
+		return nil, err
+	}
+
+	pages, err := ctx.Pages()
+	if err != nil {
+		return nil, err
+	}
+	if pages == nil {
+		return nil, errors.New("linearize: missing page tree")
+	}
+
+	err = l.pageDictObjNrs(pages.ObjectNumber.Value(), IntSet{})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(l.pages) == 0 {
+		return nil, errors.New("linearize: no pages")
+	}
+
+	if ctx.Encrypt != nil && ctx.EncKey != nil {
+		l.encrypt = ctx.Encrypt.ObjectNumber.Value()
+	}
+
+	l.collectDocumentObjects(rootDict)
+	l.collectPageObjects()
+	l.collectOtherObjects(rootDict)
+
+	return l, nil
+}
+
+// linearizedFile represents a linearized file using the new object numbering.
+type linearizedFile struct {
+	*linearization
+
+	linDictObjNr      int
+	primaryHintObjNr  int
+	overflowHintObjNr int // 0 if there is no overflow hint stream.
+	mainSize          int // size of the main cross reference section.
+
+	// The rendered sections of the file in file order.
+	header, linDict, firstXRef        []byte
+	docObjs, primaryHint, firstPage   []byte
+	otherPages, sharedObjs, otherObjs []byte
+	overflowHint, mainXRef            []byte
+
+	offsets map[int]int64 // object offsets relative to the start of the containing section.
+	lengths map[int]int64 // object lengths.
+}
+
+// layout holds the offsets of all sections of a linearized file.
+type layout struct {
+	docObjs, primaryHint, firstPage   int64
+	otherPages, sharedObjs, otherObjs int64
+	overflowHint, mainXRef, fileSize  int64
+}
+
+func objNrList(parts ...[]int) []int {
+
+	var objNrs []int
+	for _, p := range parts {
+		objNrs = append(objNrs, p...)
+	}
+
+	return objNrs
+}
+
+// pageObjNrs returns the objects written for page i starting with the page dict.
+func (f *linearizedFile) pageObjNrs(i int) []int {
+	if i == 0 {
+		return f.part6
+	}
+	return f.part7[i-1]
+}
+
+// renumber assigns new object numbers in file order.
+// The main cross reference section covers the objects following the first page
+// and the first page cross reference section covers the linearization parameter dict,
+// the document level objects, the objects of the first page and the primary hint stream.
+func (f *linearizedFile) renumber() {
+
+	ctx := f.ctx
+
+	lookup := map[int]int{}
+
+	nr := 1
+	for _, objNr := range objNrList(append(f.part7, f.part8, f.part9)...) {
+		lookup[objNr] = nr
+		nr++
+	}
+
+	if f.outlines > 0 || f.info > 0 {
+		f.overflowHintObjNr = nr
+		nr++
+	}
+
+	f.mainSize = nr
+
+	f.linDictObjNr = nr
+	nr++
+
+	for _, objNr := range objNrList(f.part4, f.part6) {
+		lookup[objNr] = nr
+		nr++
+	}
+
+	f.primaryHintObjNr = nr
+	nr++
+
+	// Build the new xref table.
+	m := map[int]*XRefTableEntry{0: NewFreeHeadXRefTableEntry()}
+
+	for objNr, i := range lookup {
+		entry, found := ctx.FindTableEntryLight(objNr)
+		if !found || entry.Free {
+			// Dangling references resolve to the null object.
+			entry = NewXRefTableEntryGen0(nil)
+		}
+		entry.Compressed = false
+		entry.ObjectStream = nil
+		entry.ObjectStreamInd = nil
+		m[i] = entry
+	}
+
+	for _, entry := range m {
+		if entry.Free || entry.Object == nil {
+			continue
+		}
+		if o := patchObject(entry.Object, lookup); o != nil {
+			entry.Object = o
+		}
+	}
+
+	// Placeholders for the linearization objects.
+	for _, objNr := range []int{f.linDictObjNr, f.primaryHintObjNr, f.overflowHintObjNr} {
+		if objNr > 0 {
+			m[objNr] = NewXRefTableEntryGen0(nil)
+		}
+	}
+
+	patchIndRef(ctx.Root, lookup)
+
+	if ctx.Info != nil {
+		patchIndRef(ctx.Info, lookup)
+	}
+
+	if f.encrypt > 0 {
+		patchIndRef(ctx.Encrypt, lookup)
+		f.encrypt = ctx.Encrypt.ObjectNumber.Value()
+	}
+
+	if ctx.AdditionalStreams != nil {
+		patchArray(ctx.AdditionalStreams, lookup)
+	}
+
+	ctx.Table = m
+	*ctx.Size = nr
+
+	renumber := func(objNrs []int) []int {
+		s := make([]int, len(objNrs))
+		for i, objNr := range objNrs {
+			s[i] = lookup[objNr]
+		}
+		return s
+	}
+
+	for i, objNrs := range f.part7 {
+		f.part7[i] = renumber(objNrs)
+	}
+
+	for i, objNrs := range f.objs {
+		f.objs[i] = renumber(objNrs)
+	}
+
+	f.pages = renumber(f.pages)
+	f.part4 = renumber(f.part4)
+	f.part6 = renumber(f.part6)
+	f.part8 = renumber(f.part8)
+	f.part9 = renumber(f.part9)
+
+	sharedBy := map[int]int{}
+	for objNr, n := range f.sharedBy {
+		sharedBy[lookup[objNr]] = n
+	}
+	f.sharedBy = sharedBy
+}
+
+// render returns the bytes generated by write.
+func (f *linearizedFile) render(write func() error) ([]byte, error) {
+
+	var buf bytes.Buffer
+
+	w := f.ctx.Write
+	w.Writer = bufio.NewWriter(&buf)
+	w.Offset = 0
+
+	err := write()
+	if err != nil {
+		return nil, err
+	}
+
+	err = w.Flush()
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// renderObjects renders a sequence of objects and records their offsets relative to the start of the sequence.
+func (f *linearizedFile) renderObjects(objNrs []int) ([]byte, error) {
+
+	ctx := f.ctx
+
+	bb, err := f.render(func() error {
+
+		for _, objNr := range objNrs {
+
+			var err error
+
+			if objNr == f.encrypt {
+				// The encryption dict itself is never encrypted.
+				err = writeEncryptDict(ctx)
+			} else {
+				err = writeIncrementObject(ctx, objNr)
+			}
+
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	for i, objNr := range objNrs {
+		off := ctx.Write.Table[objNr]
+		end := int64(len(bb))
+		if i < len(objNrs)-1 {
+			end = ctx.Write.Table[objNrs[i+1]]
+		}
+		f.offsets[objNr] = off
+		f.lengths[objNr] = end - off
+	}
+
+	return bb, nil
+}
+
+func (f *linearizedFile) renderObjectSections() (err error) {
+
+	f.docObjs, err = f.renderObjects(f.part4)
+	if err != nil {
+		return err
+	}
+
+	f.firstPage, err = f.renderObjects(f.part6)
+	if err != nil {
+		return err
+	}
+
+	f.otherPages, err = f.renderObjects(objNrList(f.part7...))
+	if err != nil {
+		return err
+	}
+
+	f.sharedObjs, err = f.renderObjects(f.part8)
+	if err != nil {
+		return err
+	}
+
+	f.otherObjs, err = f.renderObjects(f.part9)
+
+	return err
+}
+
+// layout returns the offsets of all sections.
+// Hint tables use offsets as if the primary hint stream were not present, see F.4.
+func (f *linearizedFile) layout(withPrimaryHint bool) layout {
+
+	var l layout
+
+	l.docObjs = int64(len(f.header) + len(f.linDict) + len(f.firstXRef))
+	l.primaryHint = l.docObjs + int64(len(f.docObjs))
+
+	l.firstPage = l.primaryHint
+	if withPrimaryHint {
+		l.firstPage += int64(len(f.primaryHint))
+	}
+
+	l.otherPages = l.firstPage + int64(len(f.firstPage))
+	l.sharedObjs = l.otherPages + int64(len(f.otherPages))
+	l.otherObjs = l.sharedObjs + int64(len(f.sharedObjs))
+	l.overflowHint = l.otherObjs + int64(len(f.otherObjs))
+	l.mainXRef = l.overflowHint + int64(len(f.overflowHint))
+	l.fileSize = l.mainXRef + int64(len(f.mainXRef))
+
+	return l
+}
+
+// pageOffsetHintTable returns the page offset hint table, see Table F.3 and F.4.
+func (f *linearizedFile) pageOffsetHintTable(l layout) []byte {
+
+	n := len(f.pages)
+
+	objCounts := make([]int, n)
+	pageLengths := make([]int, n)
+	sharedIDs := make([][]int, n)
+
+	// Shared object identifiers index into the shared object hint table
+	// listing all objects of the first page followed by the objects shared by the remaining pages.
+	ids := map[int]int{}
+	for i, objNr := range objNrList(f.part6, f.part8) {
+		ids[objNr] = i
+	}
+
+	var maxShared, maxID int
+
+	for i := 0; i < n; i++ {
+
+		objNrs := f.pageObjNrs(i)
+		objCounts[i] = len(objNrs)
+
+		for _, objNr := range objNrs {
+			pageLengths[i] += int(f.lengths[objNr])
+		}
+
+		for _, objNr := range f.objs[i] {
+			if f.sharedBy[objNr] < 2 {
+				continue
+			}
+			id := ids[objNr]
+			sharedIDs[i] = append(sharedIDs[i], id)
+			if id > maxID {
+				maxID = id
+			}
+		}
+
+		if len(sharedIDs[i]) > maxShared {
+			maxShared = len(sharedIDs[i])
+		}
+	}
+
+	minObjs, maxObjs := minMax(objCounts)
+	minLen, maxLen := minMax(pageLengths)
+
+	nbitsObjs := bitsNeeded(maxObjs - minObjs)
+	nbitsLen := bitsNeeded(maxLen - minLen)
+	nbitsShared := bitsNeeded(maxShared)
+	nbitsID := bitsNeeded(maxID)
+
+	w := &bitWriter{}
+
+	// Header
+	w.writeBits(minObjs, 32)
+	w.writeBits(int(l.firstPage), 32)
+	w.writeBits(nbitsObjs, 16)
+	w.writeBits(minLen, 32)
+	w.writeBits(nbitsLen, 16)
+
+	// Content streams are considered to span the entire page.
+	w.writeBits(0, 32)
+	w.writeBits(0, 16)
+	w.writeBits(minLen, 32)
+	w.writeBits(nbitsLen, 16)
+
+	w.writeBits(nbitsShared, 16)
+	w.writeBits(nbitsID, 16)
+
+	// No fractional positions of shared objects.
+	w.writeBits(0, 16)
+	w.writeBits(1, 16)
+
+	// Per page entries, each item for all pages in sequence.
+	for i := 0; i < n; i++ {
+		w.writeBits(objCounts[i]-minObjs, nbitsObjs)
+	}
+	w.align()
+
+	for i := 0; i < n; i++ {
+		w.writeBits(pageLengths[i]-minLen, nbitsLen)
+	}
+	w.align()
+
+	for i := 0; i < n; i++ {
+		w.writeBits(len(sharedIDs[i]), nbitsShared)
+	}
+	w.align()
+
+	for i := 0; i < n; i++ {
+		for _, id := range sharedIDs[i] {
+			w.writeBits(id, nbitsID)
+		}
+	}
+	w.align()
+
+	for i := 0; i < n; i++ {
+		w.writeBits(pageLengths[i]-minLen, nbitsLen)
+	}
+
+	return w.bytes()
+}
+
+// sharedObjectHintTable returns the shared object hint table, see Table F.5 and F.6.
+// Every shared object group consists of a single object.
+func (f *linearizedFile) sharedObjectHintTable(l layout) []byte {
+
+	var groupLengths []int
+	for _, objNr := range objNrList(f.part6, f.part8) {
+		groupLengths = append(groupLengths, int(f.lengths[objNr]))
+	}
+
+	minLen, maxLen := minMax(groupLengths)
+	nbitsLen := bitsNeeded(maxLen - minLen)
+
+	var firstObjNr, firstOffset int
+	if len(f.part8) > 0 {
+		firstObjNr = f.part8[0]
+		firstOffset = int(l.sharedObjs)
+	}
+
+	w := &bitWriter{}
+
+	// Header
+	w.writeBits(firstObjNr, 32)
+	w.writeBits(firstOffset, 32)
+	w.writeBits(len(f.part6), 32)
+	w.writeBits(len(groupLengths), 32)
+	w.writeBits(0, 16)
+	w.writeBits(minLen, 32)
+	w.writeBits(nbitsLen, 16)
+
+	// Per group entries, each item for all groups in sequence.
+	for _, gl := range groupLengths {
+		w.writeBits(gl-minLen, nbitsLen)
+	}
+	w.align()
+
+	// No MD5 signatures.
+	for range groupLengths {
+		w.writeBits(0, 1)
+	}
+
+	return w.bytes()
+}
+
+// genericHintTable returns a generic hint table for a group of objects following the shared objects, see Table F.11.
+func (f *linearizedFile) genericHintTable(l layout, objNrs []int) []byte {
+
+	var length int64
+	for _, objNr := range objNrs {
+		length += f.lengths[objNr]
+	}
+
+	w := &bitWriter{}
+	w.writeBits(objNrs[0], 32)
+	w.writeBits(int(l.otherObjs+f.offsets[objNrs[0]]), 32)
+	w.writeBits(len(objNrs), 32)
+	w.writeBits(int(length), 32)
+
+	return w.bytes()
+}
+
+// newHintStream returns a flate encoded hint stream.
+func newHintStream(content []byte) (*PDFStreamDict, error) {
+
+	sd := &PDFStreamDict{
+		PDFDict:        NewPDFDict(),
+		Content:        content,
+		FilterPipeline: []PDFFilter{{Name: filter.Flate, DecodeParms: nil}}}
+
+	sd.InsertName("Filter", filter.Flate)
+
+	err := encodeStream(sd)
+	if err != nil {
+		return nil, err
+	}
+
+	return sd, nil
+}
+
+func (f *linearizedFile) renderHintStream(objNr int, sd *PDFStreamDict) ([]byte, error) {
+	f.ctx.Table[objNr] = NewXRefTableEntryGen0(*sd)
+	return f.renderObjects([]int{objNr})
+}
+
+// renderHintStreams renders the primary hint stream holding the page offset and shared object hint tables
+// and the overflow hint stream holding the outline and document information hint tables, see F.3.5 and F.4.
+// The data of the overflow hint stream logically continues the data of the primary hint stream.
+func (f *linearizedFile) renderHintStreams() error {
+
+	l := f.layout(false)
+
+	pageTable := f.pageOffsetHintTable(l)
+	data := append(pageTable, f.sharedObjectHintTable(l)...)
+
+	var overflow []byte
+
+	hints := NewPDFDict()
+	hints.Insert("S", PDFInteger(len(pageTable)))
+
+	if f.outlines > 0 {
+		hints.Insert("O", PDFInteger(len(data)+len(overflow)))
+		overflow = append(overflow, f.genericHintTable(l, f.part9[:f.outlines])...)
+	}
+
+	if f.info > 0 {
+		hints.Insert("I", PDFInteger(len(data)+len(overflow)))
+		overflow = append(overflow, f.genericHintTable(l, f.part9[f.outlines:f.outlines+f.info])...)
+	}
+
+	if f.overflowHintObjNr > 0 {
+
+		sd, err := newHintStream(overflow)
+		if err != nil {
+			return err
+		}
+
+		f.overflowHint, err = f.renderHintStream(f.overflowHintObjNr, sd)
+		if err != nil {
+			return err
+		}
+	}
+
+	sd, err := newHintStream(data)
+	if err != nil {
+		return err
+	}
+
+	for k, v := range hints.Dict {
+		sd.Insert(k, v)
+	}
+
+	f.primaryHint, err = f.renderHintStream(f.primaryHintObjNr, sd)
+
+	return err
+}
+
+// padded appends spaces to s up to length n.
+func padded(s string, n int) string {
+	if len(s) >= n {
+		return s
+	}
+	return s + strings.Repeat(" ", n-len(s))
+}
+
+// linDictString returns the linearization parameter dict, see Table F.1.
+// Unless final all offsets and lengths are replaced by placeholders of maximum length.
+func (f *linearizedFile) linDictString(final bool) string {
+
+	val := func(v int64) PDFInteger {
+		if !final {
+			v = maxOffset
+		}
+		return PDFInteger(v)
+	}
+
+	l := f.layout(true)
+
+	h := PDFArray{val(l.primaryHint), val(int64(len(f.primaryHint)))}
+	if f.overflowHintObjNr > 0 {
+		h = append(h, val(l.overflowHint), val(int64(len(f.overflowHint))))
+	}
+
+	// T is the offset of the white space preceding the first entry of the main cross reference table.
+	eol := f.ctx.Write.Eol
+	t := l.mainXRef + int64(len("xref"+eol+fmt.Sprintf("0 %d", f.mainSize)+eol)) - 1
+
+	d := NewPDFDict()
+	d.Insert("Linearized", PDFInteger(1))
+	d.Insert("L", val(l.fileSize))
+	d.Insert("H", h)
+	d.Insert("O", PDFInteger(f.pages[0]))
+	d.Insert("E", val(l.otherPages))
+	d.Insert("N", PDFInteger(len(f.pages)))
+	d.Insert("T", val(t))
+
+	s := d.PDFString()
+
+	if final {
+		s = padded(s, len(f.linDictString(false)))
+	}
+
+	return s
+}
+
+// firstPageTrailerString returns the trailer dict of the first page cross reference section.
+// Unless final the offset of the main cross reference section is replaced by a placeholder of maximum length.
+func (f *linearizedFile) firstPageTrailerString(final bool) string {
+
+	prev := int64(maxOffset)
+	if final {
+		prev = f.layout(true).mainXRef
+	}
+
+	d := newTrailerDict(f.ctx)
+	d.Insert("Prev", PDFInteger(prev))
+
+	s := d.PDFString()
+
+	if final {
+		s = padded(s, len(f.firstPageTrailerString(false)))
+	}
+
+	return s
+}
+
+func (f *linearizedFile) writeLinDict(final bool) error {
+
+	w := f.ctx.Write
+
+	_, err := writeObjectHeader(w, f.linDictObjNr, 0)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.WriteString(f.linDictString(final))
+	if err != nil {
+		return err
+	}
+
+	_, err = writeObjectTrailer(w)
+
+	return err
+}
+
+func (f *linearizedFile) writeFirstPageXRef(final bool) error {
+
+	ctx := f.ctx
+	w := ctx.Write
+
+	_, err := w.WriteString("xref" + w.Eol)
+	if err != nil {
+		return err
+	}
+
+	err = writeXRefSubsection(ctx, f.mainSize, *ctx.Size-f.mainSize)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.WriteString("trailer" + w.Eol + f.firstPageTrailerString(final) + w.Eol)
+	if err != nil {
+		return err
+	}
+
+	// The first page trailer is followed by a dummy startxref, see F.3.4.
+	_, err = w.WriteString("startxref" + w.Eol + "0" + w.Eol + "%%EOF" + w.Eol)
+
+	return err
+}
+
+func (f *linearizedFile) writeMainXRef() error {
+
+	ctx := f.ctx
+	w := ctx.Write
+
+	_, err := w.WriteString("xref" + w.Eol)
+	if err != nil {
+		return err
+	}
+
+	err = writeXRefSubsection(ctx, 0, f.mainSize)
+	if err != nil {
+		return err
+	}
+
+	d := NewPDFDict()
+	d.Insert("Size", PDFInteger(f.mainSize))
+
+	err = writeTrailerDict(ctx, d)
+	if err != nil {
+		return err
+	}
+
+	// startxref points to the first page cross reference section.
+	_, err = w.WriteString(fmt.Sprintf("%sstartxref%s%d%s", w.Eol, w.Eol, len(f.header)+len(f.linDict), w.Eol))
+	if err != nil {
+		return err
+	}
+
+	_, err = writeTrailer(w)
+
+	return err
+}
+
+// setWriteOffsets records the final object offsets for the cross reference sections.
+func (f *linearizedFile) setWriteOffsets() {
+
+	l := f.layout(true)
+
+	t := map[int]int64{
+		f.linDictObjNr:     int64(len(f.header)),
+		f.primaryHintObjNr: l.primaryHint,
+	}
+
+	if f.overflowHintObjNr > 0 {
+		t[f.overflowHintObjNr] = l.overflowHint
+	}
+
+	for _, s := range []struct {
+		objNrs []int
+		offset int64
+	}{
+		{f.part4, l.docObjs},
+		{f.part6, l.firstPage},
+		{objNrList(f.part7...), l.otherPages},
+		{f.part8, l.sharedObjs},
+		{f.part9, l.otherObjs},
+	} {
+		for _, objNr := range s.objNrs {
+			t[objNr] = s.offset + f.offsets[objNr]
+		}
+	}
+
+	f.ctx.Write.Table = t
+}
+
+// renderSections generates all sections of the linearized file.
+func (f *linearizedFile) renderSections() (err error) {
+
+	ctx := f.ctx
+
+	err = f.renderObjectSections()
+	if err != nil {
+		return err
+	}
+
+	f.header, err = f.render(func() error { return writeHeader(ctx.Write, V17) })
+	if err != nil {
+		return err
+	}
+
+	// The sections preceding the document level objects are rendered using placeholders first.
+	// This fixes the layout needed for the hint tables.
+
+	f.linDict, err = f.render(func() error { return f.writeLinDict(false) })
+	if err != nil {
+		return err
+	}
+
+	f.firstXRef, err = f.render(func() error { return f.writeFirstPageXRef(false) })
+	if err != nil {
+		return err
+	}
+
+	err = f.renderHintStreams()
+	if err != nil {
+		return err
+	}
+
+	f.setWriteOffsets()
+
+	f.mainXRef, err = f.render(f.writeMainXRef)
+	if err != nil {
+		return err
+	}
+
+	linDict, err := f.render(func() error { return f.writeLinDict(true) })
+	if err != nil {
+		return err
+	}
+
+	firstXRef, err := f.render(func() error { return f.writeFirstPageXRef(true) })
+	if err != nil {
+		return err
+	}
+
+	if len(linDict) != len(f.linDict) || len(firstXRef) != len(f.firstXRef) {
+		return errors.New("linearize: corrupt layout")
+	}
+
+	f.linDict, f.firstXRef = linDict, firstXRef
+
+	return nil
+}
+
+// writeLinearized generates a linearized PDF for the cross reference table contained in PDFContext and writes it to w.
+// Linearized files are written using cross reference tables and without object streams.
+func writeLinearized(ctx *PDFContext, w io.Writer) error {
+
+	log.Debug.Println("writeLinearized begin")
+
+	if ctx.Write.ReducedFeatureSet() {
+		return errors.New("linearize: not supported for split, trim and merge")
+	}
+
+	err := handleEncryption(ctx)
+	if err != nil {
+		return err
+	}
+
+	ctx.WriteObjectStream = false
+	ctx.WriteXRefStream = false
+
+	// Ensure there is no root version.
+	if ctx.RootVersion != nil {
+		ctx.RootDict.Delete("Version")
+	}
+
+	l, err := newLinearization(ctx)
+	if err != nil {
+		return err
+	}
+
+	f := &linearizedFile{
+		linearization: l,
+		offsets:       map[int]int64{},
+		lengths:       map[int]int64{},
+	}
+
+	f.renumber()
+
+	log.Debug.Printf("writeLinearized: %d pages, first page obj #%d, %d objects\n", len(f.pages), f.pages[0], *ctx.Size)
+
+	err = f.renderSections()
+	if err != nil {
+		return err
+	}
+
+	cw := &countingWriter{w: w}
+	ctx.Write.Writer = bufio.NewWriter(cw)
+
+	for _, bb := range [][]byte{
+		f.header, f.linDict, f.firstXRef,
+		f.docObjs, f.primaryHint, f.firstPage,
+		f.otherPages, f.sharedObjs, f.otherObjs,
+		f.overflowHint, f.mainXRef} {
+
+		_, err = ctx.Write.Write(bb)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = setFileSizeOfWrittenFile(ctx.Write, cw)
+	if err != nil {
+		return err
+	}
+
+	ctx.Write.Offset = ctx.Write.FileSize
+
+	if ctx.Read != nil {
+		ctx.Write.BinaryImageSize = ctx.Read.BinaryImageSize
+		ctx.Write.BinaryFontSize = ctx.Read.BinaryFontSize
+		logWriteStats(ctx)
+	}
+
+	log.Debug.Println("writeLinearized end")
+
+	return nil
+}
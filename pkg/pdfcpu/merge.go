@@ -290,7 +290,8 @@ func mergeDuplicateObjNumberIntSets(ctxSource, ctxDest *PDFContext) {
 	log.Debug.Println("mergeDuplicateObjNumberIntSets end")
 }
 
-// MergeXRefTables merges PDFContext ctxSource into ctxDest by appending its page tree.
+// MergeXRefTables merges PDFContext ctxSource into ctxDest by appending its page tree and outlines
+// and merging its named destinations and form fields.
 func MergeXRefTables(ctxSource, ctxDest *PDFContext) (err error) {
 
 	// Sweep over ctxSource cross ref table and ensure valid object numbers in ctxDest's space.
@@ -307,6 +308,27 @@ func MergeXRefTables(ctxSource, ctxDest *PDFContext) (err error) {
 	log.Debug.Println("appendSourceObjectsToDest")
 	appendSourceObjectsToDest(ctxSource, ctxDest)
 
+	// Merge ctxSource named destinations into ctxDest.
+	log.Debug.Println("mergeDests")
+	err = mergeDests(ctxSource, ctxDest)
+	if err != nil {
+		return err
+	}
+
+	// Merge ctxSource form fields into ctxDest.
+	log.Debug.Println("mergeAcroForms")
+	err = mergeAcroForms(ctxSource, ctxDest)
+	if err != nil {
+		return err
+	}
+
+	// Append ctxSource outlines to ctxDest outlines.
+	log.Debug.Println("appendSourceOutlinesToDestOutlines")
+	err = appendSourceOutlinesToDestOutlines(ctxSource, ctxDest)
+	if err != nil {
+		return err
+	}
+
 	// Mark source's root object as free.
 	err = ctxDest.DeleteObject(int(ctxSource.Root.ObjectNumber))
 	if err != nil {
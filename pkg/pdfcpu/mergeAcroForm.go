@@ -0,0 +1,394 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hhrutter/pdfcpu/pkg/log"
+)
+
+// fieldNames returns the partial names of the fields in a field array.
+func fieldNames(ctx *PDFContext, fields PDFArray) (map[string]bool, error) {
+
+	m := map[string]bool{}
+
+	for _, o := range fields {
+
+		d, err := ctx.DereferenceDict(o)
+		if err != nil {
+			return nil, err
+		}
+
+		if d == nil || d.Dict["T"] == nil {
+			continue
+		}
+
+		s, err := textString(ctx, d.Dict["T"])
+		if err != nil {
+			return nil, err
+		}
+
+		m[s] = true
+	}
+
+	return m, nil
+}
+
+// renameDAFonts renames the fonts used by a default appearance string.
+func renameDAFonts(o PDFObject, fonts map[string]string) (PDFObject, bool) {
+
+	s, ok := o.(PDFStringLiteral)
+	if !ok {
+		return nil, false
+	}
+
+	renamed := false
+
+	a := strings.Fields(s.Value())
+	for i, t := range a {
+		if n, ok := fonts[strings.TrimPrefix(t, "/")]; ok && strings.HasPrefix(t, "/") {
+			a[i] = "/" + n
+			renamed = true
+		}
+	}
+
+	if !renamed {
+		return nil, false
+	}
+
+	return PDFStringLiteral(strings.Join(a, " ")), true
+}
+
+// patchFieldDAs renames the fonts used by the default appearance strings of the field hierarchy fields.
+func patchFieldDAs(ctx *PDFContext, fields PDFArray, fonts map[string]string) error {
+
+	for _, o := range fields {
+
+		d, err := ctx.DereferenceDict(o)
+		if err != nil {
+			return err
+		}
+
+		if d == nil {
+			continue
+		}
+
+		if da, ok := renameDAFonts(d.Dict["DA"], fonts); ok {
+			d.Update("DA", da)
+		}
+
+		kids, err := ctx.DereferenceArray(d.Dict["Kids"])
+		if err != nil {
+			return err
+		}
+
+		if kids != nil {
+			err = patchFieldDAs(ctx, *kids, fonts)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// mergeDR merges the default resources of the source form into the default resources of the dest form.
+// Returns the source fonts that had to be renamed because of a clash with a different dest font.
+func mergeDR(ctx *PDFContext, formSource, formDest *PDFDict) (map[string]string, error) {
+
+	drSource, err := ctx.DereferenceDict(formSource.Dict["DR"])
+	if err != nil || drSource == nil {
+		return nil, err
+	}
+
+	drDest, err := ctx.DereferenceDict(formDest.Dict["DR"])
+	if err != nil {
+		return nil, err
+	}
+
+	if drDest == nil {
+		formDest.Insert("DR", formSource.Dict["DR"])
+		return nil, nil
+	}
+
+	fonts := map[string]string{}
+
+	for cat, o := range drSource.Dict {
+
+		resSource, err := ctx.DereferenceDict(o)
+		if err != nil {
+			return nil, err
+		}
+
+		resDest, err := ctx.DereferenceDict(drDest.Dict[cat])
+		if err != nil {
+			return nil, err
+		}
+
+		if resSource == nil {
+			continue
+		}
+
+		if resDest == nil {
+			drDest.Insert(cat, o)
+			continue
+		}
+
+		for k, v := range resSource.Dict {
+
+			w, found := resDest.Find(k)
+			if !found {
+				resDest.Insert(k, v)
+				continue
+			}
+
+			if cat != "Font" {
+				// Only fonts are referred to by name from default appearance strings.
+				continue
+			}
+
+			ok, err := equalPDFObjects(v, w, ctx.XRefTable)
+			if err != nil {
+				return nil, err
+			}
+
+			if ok {
+				continue
+			}
+
+			var n string
+			for i := 1; ; i++ {
+				n = fmt.Sprintf("%s_%d", k, i)
+				if _, found := resDest.Find(n); !found && resSource.Dict[n] == nil {
+					break
+				}
+			}
+
+			resDest.Insert(n, v)
+			fonts[k] = n
+		}
+	}
+
+	return fonts, nil
+}
+
+// mergeAcroForms merges the interactive form of ctxSource into the interactive form of ctxDest.
+// Top level source fields whose names are already taken in ctxDest are nested below a new field
+// named after the source file, eg. name becomes file2.name.
+func mergeAcroForms(ctxSource, ctxDest *PDFContext) error {
+
+	log.Debug.Println("mergeAcroForms begin")
+
+	rootDictSource, err := ctxSource.Catalog()
+	if err != nil {
+		return err
+	}
+
+	o, found := rootDictSource.Find("AcroForm")
+	if !found {
+		return nil
+	}
+
+	rootDictDest, err := ctxDest.Catalog()
+	if err != nil {
+		return err
+	}
+
+	formDest, err := ctxDest.DereferenceDict(rootDictDest.Dict["AcroForm"])
+	if err != nil {
+		return err
+	}
+
+	if formDest == nil {
+		rootDictDest.Insert("AcroForm", o)
+		return nil
+	}
+
+	// At this point all source objects are part of ctxDest.
+
+	formSource, err := ctxDest.DereferenceDict(o)
+	if err != nil || formSource == nil {
+		return err
+	}
+
+	fieldsSource, err := ctxDest.DereferenceArray(formSource.Dict["Fields"])
+	if err != nil {
+		return err
+	}
+
+	fieldsDest, err := ctxDest.DereferenceArray(formDest.Dict["Fields"])
+	if err != nil {
+		return err
+	}
+
+	if fieldsDest == nil {
+		fieldsDest = &PDFArray{}
+	}
+
+	fonts, err := mergeDR(ctxDest, formSource, formDest)
+	if err != nil {
+		return err
+	}
+
+	if fieldsSource != nil && len(fonts) > 0 {
+		err = patchFieldDAs(ctxDest, *fieldsSource, fonts)
+		if err != nil {
+			return err
+		}
+	}
+
+	// DA and Q are inheritable, source fields keep their defaults if they differ.
+	for _, k := range []string{"DA", "Q"} {
+
+		v, found := formSource.Find(k)
+		if !found {
+			continue
+		}
+
+		if k == "DA" {
+			if da, ok := renameDAFonts(v, fonts); ok {
+				v = da
+			}
+		}
+
+		w, found := formDest.Find(k)
+		if !found {
+			formDest.Insert(k, v)
+			continue
+		}
+
+		if fieldsSource == nil || v.PDFString() == w.PDFString() {
+			continue
+		}
+
+		for _, o := range *fieldsSource {
+			d, err := ctxDest.DereferenceDict(o)
+			if err != nil {
+				return err
+			}
+			if d != nil {
+				d.Insert(k, v)
+			}
+		}
+	}
+
+	if b := formSource.BooleanEntry("NeedAppearances"); b != nil && *b {
+		formDest.Update("NeedAppearances", PDFBoolean(true))
+	}
+
+	if f := formSource.IntEntry("SigFlags"); f != nil {
+		g := formDest.IntEntry("SigFlags")
+		if g == nil {
+			g = new(int)
+		}
+		formDest.Update("SigFlags", PDFInteger(*f|*g))
+	}
+
+	if co, _ := ctxDest.DereferenceArray(formSource.Dict["CO"]); co != nil {
+		a, err := ctxDest.DereferenceArray(formDest.Dict["CO"])
+		if err != nil {
+			return err
+		}
+		if a == nil {
+			a = &PDFArray{}
+		}
+		formDest.Update("CO", append(*a, *co...))
+	}
+
+	if fieldsSource == nil || len(*fieldsSource) == 0 {
+		return nil
+	}
+
+	// The XFA form of ctxDest does not know about the source fields.
+	formDest.Delete("XFA")
+
+	namesDest, err := fieldNames(ctxDest, *fieldsDest)
+	if err != nil {
+		return err
+	}
+
+	namesSource, err := fieldNames(ctxDest, *fieldsSource)
+	if err != nil {
+		return err
+	}
+
+	fields := *fieldsDest
+	var clashes PDFArray
+
+	for _, o := range *fieldsSource {
+
+		d, err := ctxDest.DereferenceDict(o)
+		if err != nil {
+			return err
+		}
+
+		if d != nil && d.Dict["T"] != nil {
+			s, err := textString(ctxDest, d.Dict["T"])
+			if err != nil {
+				return err
+			}
+			if namesDest[s] {
+				clashes = append(clashes, o)
+				continue
+			}
+		}
+
+		fields = append(fields, o)
+	}
+
+	if len(clashes) > 0 {
+
+		prefix := fileNamePrefix(ctxSource.Read.FileName, func(prefix string) bool {
+			return namesDest[prefix] || namesSource[prefix]
+		})
+
+		log.Debug.Printf("mergeAcroForms: nesting %d fields below %s\n", len(clashes), prefix)
+
+		t, err := NewTextString(prefix)
+		if err != nil {
+			return err
+		}
+
+		d := NewPDFDict()
+		d.Insert("T", t)
+		d.Insert("Kids", clashes)
+
+		indRef, err := ctxDest.IndRefForNewObject(d)
+		if err != nil {
+			return err
+		}
+
+		for _, o := range clashes {
+			kid, err := ctxDest.DereferenceDict(o)
+			if err != nil {
+				return err
+			}
+			kid.Insert("Parent", *indRef)
+		}
+
+		fields = append(fields, *indRef)
+	}
+
+	formDest.Update("Fields", fields)
+
+	log.Debug.Println("mergeAcroForms end")
+
+	return nil
+}
@@ -0,0 +1,341 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/hhrutter/pdfcpu/pkg/log"
+)
+
+// nameTreeEntries calls f for each key value pair of the name tree rooted at o.
+// Keys are passed in the form returned by nameTreeKey.
+func nameTreeEntries(xRefTable *XRefTable, o PDFObject, f func(k string, v PDFObject)) error {
+
+	d, err := xRefTable.DereferenceDict(o)
+	if err != nil || d == nil {
+		return err
+	}
+
+	kids, err := xRefTable.DereferenceArray(d.Dict["Kids"])
+	if err != nil {
+		return err
+	}
+
+	if kids != nil {
+		for _, kid := range *kids {
+			err = nameTreeEntries(xRefTable, kid, f)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	names, err := xRefTable.DereferenceArray(d.Dict["Names"])
+	if err != nil || names == nil {
+		return err
+	}
+
+	for i := 0; i+1 < len(*names); i += 2 {
+
+		o, err := xRefTable.Dereference((*names)[i])
+		if err != nil {
+			return err
+		}
+
+		// Hex literal keys and string literal keys may denote the same name.
+		k, err := nameTreeKey(o)
+		if err != nil {
+			return err
+		}
+
+		f(k, (*names)[i+1])
+	}
+
+	return nil
+}
+
+// fileNamePrefix returns a prefix derived from fileName for renaming clashing names.
+// taken reports whether a prefix causes new clashes.
+func fileNamePrefix(fileName string, taken func(prefix string) bool) string {
+
+	s := strings.TrimSuffix(filepath.Base(fileName), filepath.Ext(fileName))
+
+	// Renamed keys may also end up as PDF names.
+	s = strings.Map(func(r rune) rune {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '_') {
+			return r
+		}
+		return '_'
+	}, s)
+
+	if s == "" || s == "_" {
+		s = "doc"
+	}
+
+	for i := 1; ; i++ {
+		prefix := s
+		if i > 1 {
+			prefix = fmt.Sprintf("%s%d", s, i)
+		}
+		if !taken(prefix) {
+			return prefix
+		}
+	}
+}
+
+// renamedDest returns the new name of a named destination referred to by o.
+func renamedDest(o PDFObject, strs, names map[string]string) (PDFObject, bool) {
+
+	if n, ok := o.(PDFName); ok {
+		if s, ok := names[n.Value()]; ok {
+			return PDFName(s), true
+		}
+		return nil, false
+	}
+
+	k, err := nameTreeKey(o)
+	if err != nil {
+		return nil, false
+	}
+
+	s, ok := strs[k]
+	if !ok {
+		return nil, false
+	}
+
+	return PDFStringLiteral(s), true
+}
+
+// patchDestNames renames the named destinations used by outline items, link annotations and GoTo actions.
+func patchDestNames(o PDFObject, strs, names map[string]string) {
+
+	var d PDFDict
+
+	switch obj := o.(type) {
+
+	case PDFDict:
+		d = obj
+
+	case PDFStreamDict:
+		d = obj.PDFDict
+
+	case PDFArray:
+		for _, o := range obj {
+			patchDestNames(o, strs, names)
+		}
+		return
+
+	default:
+		return
+	}
+
+	for k, v := range d.Dict {
+
+		if k == "Dest" || k == "D" && d.NameEntry("S") != nil && *d.NameEntry("S") == "GoTo" {
+			if o, ok := renamedDest(v, strs, names); ok {
+				d.Dict[k] = o
+			}
+			continue
+		}
+
+		patchDestNames(v, strs, names)
+	}
+}
+
+// mergeDests merges the named destinations of ctxSource into ctxDest.
+// Names already taken in ctxDest get a prefix derived from the source file name
+// and all references to them within ctxSource get renamed accordingly.
+func mergeDests(ctxSource, ctxDest *PDFContext) error {
+
+	log.Debug.Println("mergeDests begin")
+
+	rootDictSource, err := ctxSource.Catalog()
+	if err != nil {
+		return err
+	}
+
+	// Destinations by string (PDF 1.2)
+	var entries []entry
+
+	namesDict, err := ctxSource.DereferenceDict(rootDictSource.Dict["Names"])
+	if err != nil {
+		return err
+	}
+
+	if namesDict != nil {
+		err = nameTreeEntries(ctxSource.XRefTable, namesDict.Dict["Dests"], func(k string, v PDFObject) {
+			entries = append(entries, entry{k, v})
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	// Destinations by name (PDF 1.1)
+	destsSource, err := ctxSource.DereferenceDict(rootDictSource.Dict["Dests"])
+	if err != nil {
+		return err
+	}
+
+	if len(entries) == 0 && (destsSource == nil || destsSource.Len() == 0) {
+		return nil
+	}
+
+	rootDictDest, err := ctxDest.Catalog()
+	if err != nil {
+		return err
+	}
+
+	destsDest, err := ctxDest.DereferenceDict(rootDictDest.Dict["Dests"])
+	if err != nil {
+		return err
+	}
+
+	strKeys, nameKeys := map[string]bool{}, map[string]bool{}
+
+	if root := ctxDest.Names["Dests"]; root != nil {
+		keys, err := root.KeyList()
+		if err != nil {
+			return err
+		}
+		for _, k := range keys {
+			// Equivalent escape sequences denote the same name.
+			if k1, err := nameTreeKey(PDFStringLiteral(k)); err == nil {
+				k = k1
+			}
+			strKeys[k] = true
+		}
+	}
+
+	if destsDest != nil {
+		for k := range destsDest.Dict {
+			nameKeys[k] = true
+		}
+	}
+
+	// Collect the colliding keys, the source keys also need to stay unique after renaming.
+	strs, names := map[string]string{}, map[string]string{}
+	srcStrKeys, srcNameKeys := map[string]bool{}, map[string]bool{}
+
+	for _, e := range entries {
+		srcStrKeys[e.k] = true
+		if strKeys[e.k] {
+			strs[e.k] = ""
+		}
+	}
+
+	if destsSource != nil {
+		for k := range destsSource.Dict {
+			srcNameKeys[k] = true
+			if nameKeys[k] {
+				names[k] = ""
+			}
+		}
+	}
+
+	if len(strs)+len(names) > 0 {
+
+		prefix := fileNamePrefix(ctxSource.Read.FileName, func(prefix string) bool {
+			prefix += "."
+			for k := range strs {
+				if strKeys[prefix+k] || srcStrKeys[prefix+k] {
+					return true
+				}
+			}
+			for k := range names {
+				if nameKeys[prefix+k] || srcNameKeys[prefix+k] {
+					return true
+				}
+			}
+			return false
+		})
+
+		for k := range strs {
+			strs[k] = prefix + "." + k
+		}
+
+		for k := range names {
+			names[k] = prefix + "." + k
+		}
+
+		log.Debug.Printf("mergeDests: renaming %d destinations using prefix %s\n", len(strs)+len(names), prefix)
+
+		for _, entry := range ctxSource.Table {
+			if entry.Free || entry.Object == nil {
+				continue
+			}
+			patchDestNames(entry.Object, strs, names)
+		}
+	}
+
+	if len(entries) > 0 {
+
+		if ctxDest.Names["Dests"] == nil {
+			err = ctxDest.LocateNameTree("Dests", true)
+			if err != nil {
+				return err
+			}
+		}
+
+		root := ctxDest.Names["Dests"]
+
+		for _, e := range entries {
+			k := e.k
+			if s, ok := strs[k]; ok {
+				k = s
+			}
+			err = root.Add(ctxDest.XRefTable, k, e.v)
+			if err != nil {
+				return err
+			}
+		}
+
+		err = ctxDest.bindNameTreeNode("Dests", root, true)
+		if err != nil {
+			return err
+		}
+	}
+
+	if destsSource != nil {
+
+		if destsDest == nil {
+			d := NewPDFDict()
+			indRef, err := ctxDest.IndRefForNewObject(d)
+			if err != nil {
+				return err
+			}
+			rootDictDest.Insert("Dests", *indRef)
+			destsDest = &d
+		}
+
+		for k, v := range destsSource.Dict {
+			if s, ok := names[k]; ok {
+				k = s
+			}
+			destsDest.Insert(k, v)
+		}
+	}
+
+	log.Debug.Println("mergeDests end")
+
+	return nil
+}
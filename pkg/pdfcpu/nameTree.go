@@ -17,10 +17,12 @@ limitations under the License.
 package pdfcpu
 
 import (
+	"encoding/hex"
 	"fmt"
 	"strings"
 
 	"github.com/hhrutter/pdfcpu/pkg/log"
+	"github.com/pkg/errors"
 )
 
 const maxEntries = 3
@@ -44,6 +46,39 @@ type entry struct {
 	v PDFObject
 }
 
+// nameTreeKey returns the key o as stored in a Node:
+// the string literal form of the bytes of a string literal or hex literal.
+// Keys are written back as string literals.
+func nameTreeKey(o PDFObject) (string, error) {
+
+	var b []byte
+	var err error
+
+	switch o := o.(type) {
+
+	case PDFStringLiteral:
+		b, err = Unescape(o.Value())
+
+	case PDFHexLiteral:
+		b, err = hex.DecodeString(o.Value())
+
+	default:
+		return "", errors.Errorf("nameTreeKey: corrupt key %v", o)
+
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	s, err := Escape(string(b))
+	if err != nil {
+		return "", err
+	}
+
+	return *s, nil
+}
+
 func (n Node) leaf() bool {
 	return n.Kids == nil
 }
@@ -199,16 +234,25 @@ func (n *Node) Add(xRefTable *XRefTable, k string, v PDFObject) error {
 	}
 
 	// For intermediary nodes we delegate to the corresponding subtree.
+	// Insert k into last (right most) subtree by default.
+	kid := n.Kids[len(n.Kids)-1]
 	for _, a := range n.Kids {
 		if k < a.Kmin || a.withinLimits(k) {
-			return a.Add(xRefTable, k, v)
+			kid = a
+			break
 		}
 	}
 
-	// Insert k into last (right most) subtree.
-	last := n.Kids[len(n.Kids)-1]
+	err := kid.Add(xRefTable, k, v)
+	if err != nil {
+		return err
+	}
 
-	return last.Add(xRefTable, k, v)
+	// Keep the limits of intermediary nodes in sync.
+	n.Kmin = n.Kids[0].Kmin
+	n.Kmax = n.Kids[len(n.Kids)-1].Kmax
+
+	return nil
 }
 
 func (n *Node) removeFromNames(xRefTable *XRefTable, k string) (ok bool, err error) {
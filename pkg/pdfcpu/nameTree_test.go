@@ -201,3 +201,38 @@ func TestNameTree(t *testing.T) {
 	buildNameTree(t, r)
 	destroyNameTreet(t, r)
 }
+
+func checkLimits(t *testing.T, n *Node) {
+
+	if n.leaf() {
+		if len(n.Names) > 0 && (n.Kmin != n.Names[0].k || n.Kmax != n.Names[len(n.Names)-1].k) {
+			t.Fatalf("leaf limits {%s,%s} don't match names %s\n", n.Kmin, n.Kmax, n)
+		}
+		return
+	}
+
+	for _, kid := range n.Kids {
+		checkLimits(t, kid)
+	}
+
+	if n.Kmin != n.Kids[0].Kmin || n.Kmax != n.Kids[len(n.Kids)-1].Kmax {
+		t.Fatalf("intermediary limits {%s,%s} don't match kids %s\n", n.Kmin, n.Kmax, n)
+	}
+}
+
+func TestNameTreeLimits(t *testing.T) {
+
+	r := &Node{}
+
+	// Grow the tree to the right and to the left across several levels.
+	for _, k := range []string{"m", "n", "o", "p", "q", "r", "s", "t", "l", "k", "j", "i", "h", "g"} {
+		if err := r.Add(nil, k, PDFInteger(0)); err != nil {
+			t.Fatalf("Add %s: %v\n", k, err)
+		}
+		checkLimits(t, r)
+	}
+
+	if r.Kmin != "g" || r.Kmax != "t" {
+		t.Fatalf("unexpected root limits {%s,%s}\n", r.Kmin, r.Kmax)
+	}
+}
@@ -21,6 +21,7 @@ import (
 	"sort"
 	"strings"
 
+	"github.com/hhrutter/pdfcpu/pkg/filter"
 	"github.com/hhrutter/pdfcpu/pkg/log"
 	"github.com/pkg/errors"
 )
@@ -711,6 +712,95 @@ func optimizeFontAndImages(ctx *PDFContext) error {
 	return nil
 }
 
+// bilevelFlateImage returns true if sd is a 1 bit per pixel image that is Flate encoded or not encoded at all.
+func bilevelFlateImage(xRefTable *XRefTable, sd *PDFStreamDict) bool {
+
+	if bpc := sd.IntEntry("BitsPerComponent"); bpc == nil || *bpc != 1 {
+		if imageMask := sd.BooleanEntry("ImageMask"); imageMask == nil || !*imageMask {
+			return false
+		}
+	}
+
+	if fp := sd.FilterPipeline; len(fp) > 1 || len(fp) == 1 && (fp[0].Name != filter.Flate || fp[0].DecodeParms != nil) {
+		return false
+	}
+
+	if imageMask := sd.BooleanEntry("ImageMask"); imageMask != nil && *imageMask {
+		return true
+	}
+
+	return imageComponents(xRefTable, sd.Dict["ColorSpace"]) == 1
+}
+
+// optimizeBilevelImages reencodes 1 bit per pixel images using Group 4 encoding whenever this saves space.
+func optimizeBilevelImages(ctx *PDFContext) error {
+
+	log.Debug.Println("optimizeBilevelImages begin")
+
+	for objNr, imageObject := range ctx.Optimize.ImageObjects {
+
+		sd := imageObject.ImageDict
+
+		if !bilevelFlateImage(ctx.XRefTable, sd) {
+			continue
+		}
+
+		w, h := sd.IntEntry("Width"), sd.IntEntry("Height")
+		if w == nil || h == nil || *w <= 0 || *h <= 0 {
+			continue
+		}
+
+		err := decodeStream(sd)
+		if err != nil {
+			return err
+		}
+
+		if len(sd.Content) < (*w+7)/8**h {
+			continue
+		}
+
+		parms := NewPDFDict()
+		parms.Insert("K", PDFInteger(-1))
+		parms.Insert("Columns", PDFInteger(*w))
+		parms.Insert("Rows", PDFInteger(*h))
+
+		sd1 := &PDFStreamDict{
+			PDFDict:        NewPDFDict(),
+			Content:        sd.Content,
+			FilterPipeline: []PDFFilter{{Name: filter.CCITTFax, DecodeParms: &parms}}}
+
+		err = encodeStream(sd1)
+		if err != nil {
+			return err
+		}
+
+		if *sd1.StreamLength >= *sd.StreamLength {
+			continue
+		}
+
+		log.Debug.Printf("optimizeBilevelImages: obj#%d %d -> %d bytes\n", objNr, *sd.StreamLength, *sd1.StreamLength)
+
+		sd.Delete("Filter")
+		sd.Delete("DecodeParms")
+		sd.InsertName("Filter", filter.CCITTFax)
+		sd.Insert("DecodeParms", parms)
+		sd.Update("Length", PDFInteger(*sd1.StreamLength))
+		sd.FilterPipeline = sd1.FilterPipeline
+		sd.Raw = sd1.Raw
+		sd.StreamLength = sd1.StreamLength
+
+		entry, found := ctx.FindTableEntry(objNr, 0)
+		if !found {
+			return errors.Errorf("optimizeBilevelImages: obj#%d not found", objNr)
+		}
+		entry.Object = *sd
+	}
+
+	log.Debug.Println("optimizeBilevelImages end")
+
+	return nil
+}
+
 // Return stream length for font file object.
 func streamLengthFontFile(xRefTable *XRefTable, indirectRef *PDFIndirectRef) (*int64, error) {
 
@@ -1021,12 +1111,46 @@ func OptimizeXRefTable(ctx *PDFContext) error {
 
 	log.Debug.Println("optimizeXRefTable begin")
 
+	var err error
+
+	// Get rid of resources not used by any content.
+	if ctx.Mode == OPTIMIZE || ctx.Mode == SPLIT || ctx.Mode == TRIM {
+		err = pruneResources(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
 	// Get rid of duplicate embedded fonts and images.
-	err := optimizeFontAndImages(ctx)
+	err = optimizeFontAndImages(ctx)
 	if err != nil {
 		return err
 	}
 
+	// Downsample images exceeding the configured resolution.
+	if ctx.Mode == OPTIMIZE && ctx.ImageDPI > 0 {
+		err = resampleImages(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Reduce embedded fonts to the glyphs used.
+	if ctx.Mode == OPTIMIZE && ctx.SubsetFonts {
+		err = subsetFonts(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Use Group 4 encoding for bilevel images.
+	if ctx.Mode == OPTIMIZE {
+		err = optimizeBilevelImages(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
 	// Calculate memory usage of binary content for stats.
 	err = calcBinarySizes(ctx)
 	if err != nil {
@@ -0,0 +1,478 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"github.com/hhrutter/pdfcpu/pkg/log"
+	"github.com/pkg/errors"
+)
+
+// outlineItem is an entry of a linked list of outline items.
+type outlineItem struct {
+	indRef PDFIndirectRef
+	dict   *PDFDict
+	dest   PDFArray // the explicit destination, if any.
+}
+
+// outlineItems returns the linked list of outline items starting at first.
+func outlineItems(xRefTable *XRefTable, first *PDFIndirectRef) ([]outlineItem, error) {
+
+	var items []outlineItem
+
+	for indRef := first; indRef != nil; {
+
+		d, err := xRefTable.DereferenceDict(*indRef)
+		if err != nil {
+			return nil, err
+		}
+
+		if d == nil {
+			return nil, errors.Errorf("outlineItems: object #%d is nil.", indRef.ObjectNumber)
+		}
+
+		items = append(items, outlineItem{indRef: *indRef, dict: d})
+
+		indRef = d.IndirectRefEntry("Next")
+	}
+
+	return items, nil
+}
+
+// visibleOutlineItems returns the number of visible items for items and their open descendants.
+func visibleOutlineItems(items []outlineItem) int {
+
+	n := len(items)
+
+	for _, item := range items {
+		if c := item.dict.IntEntry("Count"); c != nil && *c > 0 {
+			n += *c
+		}
+	}
+
+	return n
+}
+
+// linkOutlineItems makes items the children of parent.
+// The state of parent (open or closed) is preserved.
+func linkOutlineItems(parent *PDFDict, parentIndRef PDFIndirectRef, items []outlineItem) {
+
+	if len(items) == 0 {
+		parent.Delete("First")
+		parent.Delete("Last")
+		parent.Delete("Count")
+		return
+	}
+
+	for i, item := range items {
+
+		item.dict.Update("Parent", parentIndRef)
+
+		item.dict.Delete("Prev")
+		if i > 0 {
+			item.dict.Insert("Prev", items[i-1].indRef)
+		}
+
+		item.dict.Delete("Next")
+		if i < len(items)-1 {
+			item.dict.Insert("Next", items[i+1].indRef)
+		}
+	}
+
+	parent.Update("First", items[0].indRef)
+	parent.Update("Last", items[len(items)-1].indRef)
+
+	count := visibleOutlineItems(items)
+	if c := parent.IntEntry("Count"); c != nil && *c < 0 {
+		count = -count
+	}
+
+	parent.Update("Count", PDFInteger(count))
+}
+
+// namedDestination returns the destination for name as defined in the Dests name tree or the Dests dict of the catalog.
+func namedDestination(xRefTable *XRefTable, name string) (PDFObject, error) {
+
+	if root, ok := xRefTable.Names["Dests"]; ok {
+		if o, found := root.Value(name); found {
+			return o, nil
+		}
+	}
+
+	rootDict, err := xRefTable.Catalog()
+	if err != nil {
+		return nil, err
+	}
+
+	d, err := xRefTable.DereferenceDict(rootDict.Dict["Dests"])
+	if err != nil || d == nil {
+		return nil, err
+	}
+
+	o, _ := d.Find(name)
+
+	return o, nil
+}
+
+// explicitDestination resolves a destination to an explicit destination.
+func explicitDestination(xRefTable *XRefTable, o PDFObject) (PDFArray, error) {
+
+	// Guard against named destinations referring to each other.
+	for i := 0; i < 4; i++ {
+
+		var err error
+
+		o, err = xRefTable.Dereference(o)
+		if err != nil || o == nil {
+			return nil, err
+		}
+
+		switch dest := o.(type) {
+
+		case PDFArray:
+			return dest, nil
+
+		case PDFDict:
+			o = dest.Dict["D"]
+			continue
+
+		case PDFName:
+			o, err = namedDestination(xRefTable, dest.Value())
+
+		case PDFStringLiteral:
+			o, err = namedDestination(xRefTable, dest.Value())
+
+		case PDFHexLiteral:
+			o, err = namedDestination(xRefTable, dest.Value())
+
+		default:
+			return nil, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return nil, nil
+}
+
+// outlineItemDestination returns the destination of an outline item given either by Dest or a GoTo action.
+// ok is false for outline items without a destination within this document.
+func outlineItemDestination(xRefTable *XRefTable, d *PDFDict) (dest PDFArray, ok bool, err error) {
+
+	o, found := d.Find("Dest")
+	if !found {
+
+		a, err := xRefTable.DereferenceDict(d.Dict["A"])
+		if err != nil || a == nil {
+			return nil, false, err
+		}
+
+		if s := a.NameEntry("S"); s == nil || *s != "GoTo" {
+			return nil, false, nil
+		}
+
+		o = a.Dict["D"]
+	}
+
+	dest, err = explicitDestination(xRefTable, o)
+
+	return dest, true, err
+}
+
+type outlineTrimmer struct {
+	xRefTable *XRefTable
+	pages     IntSet // object numbers of the pages written.
+	saved     []savedDict
+}
+
+// savedDict holds the original entries of a dict modified for writing.
+type savedDict struct {
+	dict    *PDFDict
+	entries map[string]PDFObject
+}
+
+func (ot *outlineTrimmer) save(d *PDFDict) {
+
+	m := make(map[string]PDFObject, len(d.Dict))
+	for k, v := range d.Dict {
+		m[k] = v
+	}
+
+	ot.saved = append(ot.saved, savedDict{d, m})
+}
+
+// restore undoes all modifications so ctx may be written again.
+func (ot *outlineTrimmer) restore() {
+
+	for _, s := range ot.saved {
+
+		for k := range s.dict.Dict {
+			delete(s.dict.Dict, k)
+		}
+
+		for k, v := range s.entries {
+			s.dict.Dict[k] = v
+		}
+	}
+}
+
+// destinationPage returns the object number of the page dict of an explicit destination.
+func destinationPage(dest PDFArray) int {
+
+	if len(dest) == 0 {
+		return 0
+	}
+
+	indRef, ok := dest[0].(PDFIndirectRef)
+	if !ok {
+		return 0
+	}
+
+	return indRef.ObjectNumber.Value()
+}
+
+// trimItems removes all outline items of the linked list starting at first whose destination page is not written
+// unless they have remaining children, in which case the destination becomes the destination of the first child.
+func (ot *outlineTrimmer) trimItems(first *PDFIndirectRef) ([]outlineItem, error) {
+
+	items, err := outlineItems(ot.xRefTable, first)
+	if err != nil {
+		return nil, err
+	}
+
+	var trimmed []outlineItem
+
+	for _, item := range items {
+
+		d := item.dict
+		ot.save(d)
+
+		// The structure tree is not written.
+		d.Delete("SE")
+
+		kids, err := ot.trimItems(d.IndirectRefEntry("First"))
+		if err != nil {
+			return nil, err
+		}
+
+		linkOutlineItems(d, item.indRef, kids)
+
+		dest, ok, err := outlineItemDestination(ot.xRefTable, d)
+		if err != nil {
+			return nil, err
+		}
+
+		if !ok {
+			// Not pointing into this document.
+			trimmed = append(trimmed, item)
+			continue
+		}
+
+		if !ot.pages[destinationPage(dest)] {
+
+			if len(kids) == 0 {
+				log.Debug.Printf("trimItems: removing outline item obj#%d\n", item.indRef.ObjectNumber)
+				continue
+			}
+
+			log.Debug.Printf("trimItems: retargeting outline item obj#%d\n", item.indRef.ObjectNumber)
+			dest = kids[0].dest
+		}
+
+		// Named destinations do not survive, so point to the page directly.
+		d.Delete("A")
+		d.Delete("Dest")
+		if dest != nil {
+			d.Insert("Dest", dest)
+		}
+
+		item.dest = dest
+		trimmed = append(trimmed, item)
+	}
+
+	return trimmed, nil
+}
+
+// trimOutlines adjusts the outline tree to the pages being written for Split and Trim.
+// The returned outlineTrimmer restores the original outline tree.
+func trimOutlines(ctx *PDFContext, rootDict *PDFDict) (*outlineTrimmer, error) {
+
+	ot := &outlineTrimmer{xRefTable: ctx.XRefTable, pages: IntSet{}}
+
+	ot.save(rootDict)
+
+	indRef := rootDict.IndirectRefEntry("Outlines")
+	if indRef == nil {
+		return ot, nil
+	}
+
+	pages := ctx.Write.ExtractPages
+	if ctx.Write.ExtractPageNr > 0 {
+		pages = IntSet{ctx.Write.ExtractPageNr: true}
+	}
+
+	if len(pages) == 0 {
+		return ot, nil
+	}
+
+	for i, v := range pages {
+		if !v {
+			continue
+		}
+		pageIndRef, err := ctx.PageDictIndRef(i)
+		if err != nil {
+			return nil, err
+		}
+		ot.pages[pageIndRef.ObjectNumber.Value()] = true
+	}
+
+	d, err := ctx.DereferenceDict(*indRef)
+	if err != nil || d == nil {
+		return nil, errors.New("trimOutlines: corrupt outline dict")
+	}
+
+	ot.save(d)
+
+	items, err := ot.trimItems(d.IndirectRefEntry("First"))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(items) == 0 {
+		rootDict.Delete("Outlines")
+		return ot, nil
+	}
+
+	linkOutlineItems(d, *indRef, items)
+
+	return ot, nil
+}
+
+// appendSourceOutlinesToDestOutlines appends the top level outline items of ctxSource to the outlines of ctxDest.
+// ctxSource's objects have already been appended to ctxDest.
+func appendSourceOutlinesToDestOutlines(ctxSource, ctxDest *PDFContext) error {
+
+	log.Debug.Println("appendSourceOutlinesToDestOutlines begin")
+
+	rootDictSource, err := ctxDest.DereferenceDict(*ctxSource.Root)
+	if err != nil {
+		return err
+	}
+
+	indRefSource := rootDictSource.IndirectRefEntry("Outlines")
+	if indRefSource == nil {
+		return nil
+	}
+
+	outlinesSource, err := ctxDest.DereferenceDict(*indRefSource)
+	if err != nil || outlinesSource == nil {
+		return err
+	}
+
+	itemsSource, err := outlineItems(ctxDest.XRefTable, outlinesSource.IndirectRefEntry("First"))
+	if err != nil || len(itemsSource) == 0 {
+		return err
+	}
+
+	rootDictDest, err := ctxDest.Catalog()
+	if err != nil {
+		return err
+	}
+
+	indRefDest := rootDictDest.IndirectRefEntry("Outlines")
+	if indRefDest == nil {
+		// Take over the outline dict of the source.
+		rootDictDest.Insert("Outlines", *indRefSource)
+		return nil
+	}
+
+	outlinesDest, err := ctxDest.DereferenceDict(*indRefDest)
+	if err != nil || outlinesDest == nil {
+		return errors.New("appendSourceOutlinesToDestOutlines: corrupt outline dict")
+	}
+
+	items, err := outlineItems(ctxDest.XRefTable, outlinesDest.IndirectRefEntry("First"))
+	if err != nil {
+		return err
+	}
+
+	linkOutlineItems(outlinesDest, *indRefDest, append(items, itemsSource...))
+
+	log.Debug.Println("appendSourceOutlinesToDestOutlines end")
+
+	// Mark source's outline dict as free.
+	return ctxDest.DeleteObject(indRefSource.ObjectNumber.Value())
+}
+
+// NestOutlines moves the outline tree of ctx below a new top level outline item
+// with title pointing to the first page.
+func NestOutlines(ctx *PDFContext, title string) error {
+
+	rootDict, err := ctx.Catalog()
+	if err != nil {
+		return err
+	}
+
+	pageIndRef, err := ctx.PageDictIndRef(1)
+	if err != nil {
+		return err
+	}
+
+	indRef := rootDict.IndirectRefEntry("Outlines")
+	if indRef == nil {
+		d := NewPDFDict()
+		d.InsertName("Type", "Outlines")
+		indRef, err = ctx.IndRefForNewObject(d)
+		if err != nil {
+			return err
+		}
+		rootDict.Insert("Outlines", *indRef)
+	}
+
+	outlines, err := ctx.DereferenceDict(*indRef)
+	if err != nil || outlines == nil {
+		return errors.New("NestOutlines: corrupt outline dict")
+	}
+
+	items, err := outlineItems(ctx.XRefTable, outlines.IndirectRefEntry("First"))
+	if err != nil {
+		return err
+	}
+
+	s, err := NewTextString(title)
+	if err != nil {
+		return err
+	}
+
+	d := PDFDict{
+		Dict: map[string]PDFObject{
+			"Title": s,
+			"Dest":  PDFArray{*pageIndRef, PDFName("Fit")},
+		},
+	}
+
+	itemIndRef, err := ctx.IndRefForNewObject(d)
+	if err != nil {
+		return err
+	}
+
+	linkOutlineItems(&d, *itemIndRef, items)
+	linkOutlineItems(outlines, *indRef, []outlineItem{{indRef: *itemIndRef, dict: &d}})
+
+	return nil
+}
@@ -0,0 +1,504 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+// Minimal PKCS#12 support for reading a private key and its certificate chain, see RFC 7292.
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"hash"
+	"io/ioutil"
+	"unicode/utf16"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	oidEncryptedData = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 6}
+
+	oidKeyBag              = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 1}
+	oidPKCS8ShroudedKeyBag = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 2}
+	oidCertBag             = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 3}
+	oidX509Certificate     = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 22, 1}
+
+	oidPBEWithSHAAnd3KeyTripleDESCBC = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 1, 3}
+	oidPBEWithSHAAnd128BitRC2CBC     = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 1, 5}
+	oidPBEWithSHAAnd40BitRC2CBC      = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 1, 6}
+
+	oidPBES2      = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+	oidPBKDF2     = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+	oidHMACSHA1   = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 7}
+	oidHMACSHA256 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 9}
+	oidSHA1       = asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}
+)
+
+type pfx struct {
+	Version  int
+	AuthSafe contentInfo
+	MacData  macData `asn1:"optional"`
+}
+
+type macData struct {
+	Mac        digestInfo
+	MacSalt    []byte
+	Iterations int `asn1:"optional,default:1"`
+}
+
+type digestInfo struct {
+	Algorithm pkix.AlgorithmIdentifier
+	Digest    []byte
+}
+
+type pkcs12EncryptedData struct {
+	Version              int
+	EncryptedContentInfo encryptedContentInfo
+}
+
+type safeBag struct {
+	ID         asn1.ObjectIdentifier
+	Value      asn1.RawValue     `asn1:"tag:0,explicit"`
+	Attributes []pkcs12Attribute `asn1:"set,optional"`
+}
+
+type pkcs12Attribute struct {
+	ID    asn1.ObjectIdentifier
+	Value asn1.RawValue `asn1:"set"`
+}
+
+type certBag struct {
+	ID   asn1.ObjectIdentifier
+	Data []byte `asn1:"tag:0,explicit"`
+}
+
+type encryptedPrivateKeyInfo struct {
+	Algorithm     pkix.AlgorithmIdentifier
+	EncryptedData []byte
+}
+
+type pbeParams struct {
+	Salt       []byte
+	Iterations int
+}
+
+type pbes2Params struct {
+	KeyDerivationFunc pkix.AlgorithmIdentifier
+	EncryptionScheme  pkix.AlgorithmIdentifier
+}
+
+type pbkdf2Params struct {
+	Salt       []byte
+	Iterations int
+	KeyLength  int                      `asn1:"optional"`
+	PRF        pkix.AlgorithmIdentifier `asn1:"optional"`
+}
+
+// bmpString returns the password as null terminated big endian UTF-16 as used by the PKCS#12 key derivation.
+func bmpString(s string) []byte {
+
+	var b []byte
+	for _, r := range utf16.Encode([]rune(s)) {
+		b = append(b, byte(r>>8), byte(r))
+	}
+
+	return append(b, 0, 0)
+}
+
+func fillWithRepeats(b []byte, v int) []byte {
+
+	if len(b) == 0 {
+		return nil
+	}
+
+	out := make([]byte, v*((len(b)+v-1)/v))
+	for i := range out {
+		out[i] = b[i%len(b)]
+	}
+
+	return out
+}
+
+// pkcs12KDF derives size bytes of key material for purpose id, see RFC 7292 Appendix B.2.
+func pkcs12KDF(h func() hash.Hash, password, salt []byte, iterations int, id byte, size int) []byte {
+
+	const v = 64
+
+	d := bytes.Repeat([]byte{id}, v)
+	in := append(fillWithRepeats(salt, v), fillWithRepeats(password, v)...)
+
+	var out []byte
+
+	for len(out) < size {
+
+		hh := h()
+		hh.Write(d)
+		hh.Write(in)
+		a := hh.Sum(nil)
+
+		for i := 1; i < iterations; i++ {
+			hh.Reset()
+			hh.Write(a)
+			a = hh.Sum(a[:0])
+		}
+
+		out = append(out, a...)
+
+		if len(out) >= size {
+			break
+		}
+
+		// Set I_j = (I_j + B + 1) mod 2^v for each v-bit block I_j of I.
+		b := fillWithRepeats(a, v)[:v]
+		for j := 0; j < len(in); j += v {
+			c := 1
+			for k := v - 1; k >= 0; k-- {
+				c += int(in[j+k]) + int(b[k])
+				in[j+k] = byte(c)
+				c >>= 8
+			}
+		}
+	}
+
+	return out[:size]
+}
+
+// pbkdf2 derives a key as specified in RFC 8018 section 5.2.
+func pbkdf2(h func() hash.Hash, password, salt []byte, iterations, keyLen int) []byte {
+
+	prf := hmac.New(h, password)
+
+	var out []byte
+
+	for block := 1; len(out) < keyLen; block++ {
+
+		prf.Reset()
+		prf.Write(salt)
+		prf.Write([]byte{byte(block >> 24), byte(block >> 16), byte(block >> 8), byte(block)})
+		u := prf.Sum(nil)
+
+		t := append([]byte{}, u...)
+
+		for i := 1; i < iterations; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+
+		out = append(out, t...)
+	}
+
+	return out[:keyLen]
+}
+
+func pbes2Cipher(params []byte, password string) (cipher.Block, []byte, error) {
+
+	var p pbes2Params
+	if _, err := asn1.Unmarshal(params, &p); err != nil {
+		return nil, nil, err
+	}
+
+	if !p.KeyDerivationFunc.Algorithm.Equal(oidPBKDF2) {
+		return nil, nil, errors.Errorf("pkcs12: unsupported key derivation function %v", p.KeyDerivationFunc.Algorithm)
+	}
+
+	var kdf pbkdf2Params
+	if _, err := asn1.Unmarshal(p.KeyDerivationFunc.Parameters.FullBytes, &kdf); err != nil {
+		return nil, nil, err
+	}
+
+	h := sha1.New
+	switch {
+	case kdf.PRF.Algorithm == nil, kdf.PRF.Algorithm.Equal(oidHMACSHA1):
+	case kdf.PRF.Algorithm.Equal(oidHMACSHA256):
+		h = sha256.New
+	default:
+		return nil, nil, errors.Errorf("pkcs12: unsupported pseudo random function %v", kdf.PRF.Algorithm)
+	}
+
+	alg := p.EncryptionScheme.Algorithm
+
+	var keyLen int
+	switch {
+	case alg.Equal(oidDESEDE3CBC):
+		keyLen = 24
+	case alg.Equal(oidAES128CBC):
+		keyLen = 16
+	case alg.Equal(oidAES192CBC):
+		keyLen = 24
+	case alg.Equal(oidAES256CBC):
+		keyLen = 32
+	}
+
+	if kdf.KeyLength > 0 {
+		keyLen = kdf.KeyLength
+	}
+
+	return contentCipher(p.EncryptionScheme, pbkdf2(h, []byte(password), kdf.Salt, kdf.Iterations, keyLen))
+}
+
+func pkcs12Decrypt(alg pkix.AlgorithmIdentifier, data []byte, password string) ([]byte, error) {
+
+	var (
+		cb  cipher.Block
+		iv  []byte
+		err error
+	)
+
+	if alg.Algorithm.Equal(oidPBES2) {
+
+		cb, iv, err = pbes2Cipher(alg.Parameters.FullBytes, password)
+		if err != nil {
+			return nil, err
+		}
+
+	} else {
+
+		var p pbeParams
+		if _, err = asn1.Unmarshal(alg.Parameters.FullBytes, &p); err != nil {
+			return nil, err
+		}
+
+		pw := bmpString(password)
+
+		key := func(size int) []byte {
+			return pkcs12KDF(sha1.New, pw, p.Salt, p.Iterations, 1, size)
+		}
+
+		switch {
+		case alg.Algorithm.Equal(oidPBEWithSHAAnd3KeyTripleDESCBC):
+			cb, err = des.NewTripleDESCipher(key(24))
+		case alg.Algorithm.Equal(oidPBEWithSHAAnd128BitRC2CBC):
+			cb, err = newRC2Cipher(key(16), 128)
+		case alg.Algorithm.Equal(oidPBEWithSHAAnd40BitRC2CBC):
+			cb, err = newRC2Cipher(key(5), 40)
+		default:
+			err = errors.Errorf("pkcs12: unsupported encryption algorithm %v", alg.Algorithm)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		iv = pkcs12KDF(sha1.New, pw, p.Salt, p.Iterations, 2, cb.BlockSize())
+	}
+
+	if len(data) == 0 || len(data)%cb.BlockSize() > 0 {
+		return nil, errors.New("pkcs12: invalid encrypted content")
+	}
+
+	b := make([]byte, len(data))
+	cipher.NewCBCDecrypter(cb, iv).CryptBlocks(b, data)
+
+	// Remove padding.
+	p := int(b[len(b)-1])
+	if p == 0 || p > cb.BlockSize() || !bytes.Equal(b[len(b)-p:], bytes.Repeat([]byte{byte(p)}, p)) {
+		return nil, errors.New("pkcs12: decryption failed, wrong password?")
+	}
+
+	return b[:len(b)-p], nil
+}
+
+func verifyPKCS12Mac(md *macData, content []byte, password string) error {
+
+	var h func() hash.Hash
+
+	switch {
+	case md.Mac.Algorithm.Algorithm.Equal(oidSHA1):
+		h = sha1.New
+	case md.Mac.Algorithm.Algorithm.Equal(oidSHA256):
+		h = sha256.New
+	default:
+		return errors.Errorf("pkcs12: unsupported MAC algorithm %v", md.Mac.Algorithm.Algorithm)
+	}
+
+	key := pkcs12KDF(h, bmpString(password), md.MacSalt, md.Iterations, 3, h().Size())
+
+	mac := hmac.New(h, key)
+	mac.Write(content)
+
+	if !hmac.Equal(mac.Sum(nil), md.Mac.Digest) {
+		return errors.New("pkcs12: MAC verification failed, wrong password?")
+	}
+
+	return nil
+}
+
+func parsePKCS8Key(b []byte) (crypto.Signer, error) {
+
+	key, err := x509.ParsePKCS8PrivateKey(b)
+	if err != nil {
+		return nil, err
+	}
+
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return k, nil
+	case *ecdsa.PrivateKey:
+		return k, nil
+	}
+
+	return nil, errors.New("pkcs12: unsupported private key type")
+}
+
+func safeBags(ci contentInfo, password string) ([]safeBag, error) {
+
+	var data []byte
+
+	switch {
+
+	case ci.ContentType.Equal(oidData):
+		if _, err := asn1.Unmarshal(ci.Content.Bytes, &data); err != nil {
+			return nil, err
+		}
+
+	case ci.ContentType.Equal(oidEncryptedData):
+		var ed pkcs12EncryptedData
+		if _, err := asn1.Unmarshal(ci.Content.Bytes, &ed); err != nil {
+			return nil, err
+		}
+		eci := ed.EncryptedContentInfo
+		var err error
+		if data, err = pkcs12Decrypt(eci.ContentEncryptionAlgorithm, eci.content(), password); err != nil {
+			return nil, err
+		}
+
+	default:
+		return nil, errors.Errorf("pkcs12: unsupported content type %v", ci.ContentType)
+
+	}
+
+	var bags []safeBag
+	if _, err := asn1.Unmarshal(data, &bags); err != nil {
+		return nil, err
+	}
+
+	return bags, nil
+}
+
+// ReadPKCS12 returns the private key and the certificate chain contained in a PKCS#12 file.
+// The certificate matching the private key comes first.
+func ReadPKCS12(fileName, password string) (crypto.Signer, []*x509.Certificate, error) {
+
+	b, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var p pfx
+	if _, err = asn1.Unmarshal(b, &p); err != nil {
+		return nil, nil, errors.Wrapf(err, "%s: corrupt PKCS#12 file", fileName)
+	}
+
+	if !p.AuthSafe.ContentType.Equal(oidData) {
+		return nil, nil, errors.Errorf("%s: public-key integrity mode not supported", fileName)
+	}
+
+	var authSafe []byte
+	if _, err = asn1.Unmarshal(p.AuthSafe.Content.Bytes, &authSafe); err != nil {
+		return nil, nil, errors.Wrapf(err, "%s: corrupt PKCS#12 file", fileName)
+	}
+
+	if len(p.MacData.Mac.Digest) > 0 {
+		if err = verifyPKCS12Mac(&p.MacData, authSafe, password); err != nil {
+			return nil, nil, errors.Wrapf(err, "%s", fileName)
+		}
+	}
+
+	var cis []contentInfo
+	if _, err = asn1.Unmarshal(authSafe, &cis); err != nil {
+		return nil, nil, errors.Wrapf(err, "%s: corrupt PKCS#12 file", fileName)
+	}
+
+	var (
+		key   crypto.Signer
+		certs []*x509.Certificate
+	)
+
+	for _, ci := range cis {
+
+		bags, err := safeBags(ci, password)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "%s", fileName)
+		}
+
+		for _, bag := range bags {
+
+			switch {
+
+			case bag.ID.Equal(oidKeyBag):
+				key, err = parsePKCS8Key(bag.Value.Bytes)
+
+			case bag.ID.Equal(oidPKCS8ShroudedKeyBag):
+				var epki encryptedPrivateKeyInfo
+				if _, err = asn1.Unmarshal(bag.Value.Bytes, &epki); err != nil {
+					break
+				}
+				var kb []byte
+				if kb, err = pkcs12Decrypt(epki.Algorithm, epki.EncryptedData, password); err != nil {
+					break
+				}
+				key, err = parsePKCS8Key(kb)
+
+			case bag.ID.Equal(oidCertBag):
+				var cb certBag
+				if _, err = asn1.Unmarshal(bag.Value.Bytes, &cb); err != nil || !cb.ID.Equal(oidX509Certificate) {
+					break
+				}
+				var cert *x509.Certificate
+				if cert, err = x509.ParseCertificate(cb.Data); err != nil {
+					break
+				}
+				certs = append(certs, cert)
+
+			}
+
+			if err != nil {
+				return nil, nil, errors.Wrapf(err, "%s", fileName)
+			}
+		}
+	}
+
+	if key == nil {
+		return nil, nil, errors.Errorf("%s: no private key found", fileName)
+	}
+
+	if len(certs) == 0 {
+		return nil, nil, errors.Errorf("%s: no certificate found", fileName)
+	}
+
+	// Move the signer certificate to the front.
+	for i, cert := range certs {
+		if publicKeyMatches(cert, key) {
+			certs[0], certs[i] = certs[i], certs[0]
+			break
+		}
+	}
+
+	return key, certs, nil
+}
@@ -0,0 +1,83 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"bytes"
+	"encoding/hex"
+	"path/filepath"
+	"testing"
+)
+
+// RC2 test vectors from RFC 2268, section 5.
+func TestRC2(t *testing.T) {
+
+	for _, tt := range []struct {
+		key, plain, cipher string
+		t1                 int
+	}{
+		{"0000000000000000", "0000000000000000", "ebb773f993278eff", 63},
+		{"ffffffffffffffff", "ffffffffffffffff", "278b27e42e2f0d49", 64},
+		{"3000000000000000", "1000000000000001", "30649edf9be7d2c2", 64},
+		{"88", "0000000000000000", "61a8a244adacccf0", 64},
+		{"88bca90e90875a7f0f79c384627bafb2", "0000000000000000", "2269552ab0f85ca6", 128},
+	} {
+		key, _ := hex.DecodeString(tt.key)
+		plain, _ := hex.DecodeString(tt.plain)
+		want, _ := hex.DecodeString(tt.cipher)
+
+		c, err := newRC2Cipher(key, tt.t1)
+		if err != nil {
+			t.Fatalf("TestRC2: key=%s: %v\n", tt.key, err)
+		}
+
+		got := make([]byte, 8)
+		c.Encrypt(got, plain)
+		if !bytes.Equal(got, want) {
+			t.Fatalf("TestRC2: key=%s encrypt: got %x want %x\n", tt.key, got, want)
+		}
+
+		c.Decrypt(got, want)
+		if !bytes.Equal(got, plain) {
+			t.Fatalf("TestRC2: key=%s decrypt: got %x want %x\n", tt.key, got, plain)
+		}
+	}
+}
+
+func TestReadPKCS12(t *testing.T) {
+
+	for _, fileName := range []string{
+		"signer.p12",       // PBES2, AES-256-CBC, HMAC-SHA256
+		"signerLegacy.p12", // RC2-40 certificates, 3DES key
+		"signerEC.p12",     // 3DES, ECDSA key
+	} {
+		fileName = filepath.Join(inDir, fileName)
+
+		key, certs, err := ReadPKCS12(fileName, "test")
+		if err != nil {
+			t.Fatalf("TestReadPKCS12 %s: %v\n", fileName, err)
+		}
+
+		if len(certs) == 0 || !publicKeyMatches(certs[0], key) {
+			t.Fatalf("TestReadPKCS12 %s: missing signer certificate\n", fileName)
+		}
+
+		if _, _, err = ReadPKCS12(fileName, "wrong"); err == nil {
+			t.Fatalf("TestReadPKCS12 %s: should have failed for wrong password\n", fileName)
+		}
+	}
+}
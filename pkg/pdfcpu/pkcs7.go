@@ -0,0 +1,783 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+// Minimal PKCS#7 (CMS) support, see RFC 5652.
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"io"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	oidData          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidSignedData    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidEnvelopedData = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 3}
+
+	oidRSAEncryption   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 1}
+	oidECDSAWithSHA256 = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}
+	oidSHA256          = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidSHA384          = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 2}
+	oidSHA512          = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 3}
+
+	oidAttributeContentType          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 3}
+	oidAttributeMessageDigest        = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+	oidAttributeSigningTime          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 5}
+	oidAttributeSigningCertificateV2 = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 2, 47}
+	oidAttributeTimeStampToken       = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 2, 14}
+
+	oidTSTInfo = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 1, 4}
+
+	oidRC2CBC     = asn1.ObjectIdentifier{1, 2, 840, 113549, 3, 2}
+	oidDESEDE3CBC = asn1.ObjectIdentifier{1, 2, 840, 113549, 3, 7}
+	oidAES128CBC  = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 2}
+	oidAES192CBC  = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 22}
+	oidAES256CBC  = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+
+	errNoRecipient = errors.New("pkcs7: no matching recipient")
+)
+
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"explicit,optional,tag:0"`
+}
+
+type envelopedData struct {
+	Version              int
+	OriginatorInfo       asn1.RawValue   `asn1:"optional,tag:0"`
+	RecipientInfos       []recipientInfo `asn1:"set"`
+	EncryptedContentInfo encryptedContentInfo
+}
+
+type recipientInfo struct {
+	Version                int
+	RID                    asn1.RawValue // IssuerAndSerialNumber or [0] SubjectKeyIdentifier
+	KeyEncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedKey           []byte
+}
+
+type issuerAndSerialNumber struct {
+	Issuer       asn1.RawValue
+	SerialNumber *big.Int
+}
+
+type signedData struct {
+	Version          int
+	DigestAlgorithms []pkix.AlgorithmIdentifier `asn1:"set"`
+	ContentInfo      contentInfo
+	Certificates     asn1.RawValue `asn1:"optional,tag:0"`
+	CRLs             asn1.RawValue `asn1:"optional,tag:1"`
+	SignerInfos      []signerInfo  `asn1:"set"`
+}
+
+type signerInfo struct {
+	Version            int
+	SID                asn1.RawValue // IssuerAndSerialNumber or [0] SubjectKeyIdentifier
+	DigestAlgorithm    pkix.AlgorithmIdentifier
+	SignedAttrs        asn1.RawValue `asn1:"optional,tag:0"`
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          []byte
+	UnsignedAttrs      asn1.RawValue `asn1:"optional,tag:1"`
+}
+
+type attribute struct {
+	Type   asn1.ObjectIdentifier
+	Values asn1.RawValue
+}
+
+type essCertIDv2 struct {
+	CertHash []byte
+}
+
+type signingCertificateV2 struct {
+	Certs []essCertIDv2
+}
+
+// messageImprint and tstInfo represent the content of an RFC 3161 timestamp token.
+type messageImprint struct {
+	HashAlgorithm pkix.AlgorithmIdentifier
+	HashedMessage []byte
+}
+
+type tstInfo struct {
+	Version        int
+	Policy         asn1.ObjectIdentifier
+	MessageImprint messageImprint
+	SerialNumber   *big.Int
+	GenTime        time.Time `asn1:"generalized"`
+	// Optional accuracy, ordering, nonce, tsa and extensions are ignored.
+}
+
+type encryptedContentInfo struct {
+	ContentType                asn1.ObjectIdentifier
+	ContentEncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedContent           asn1.RawValue `asn1:"optional,tag:0"`
+}
+
+// identifies returns true if the recipient or signer identifier id refers to cert.
+func identifies(id asn1.RawValue, cert *x509.Certificate) bool {
+
+	if id.Class == asn1.ClassContextSpecific && id.Tag == 0 {
+		return len(cert.SubjectKeyId) > 0 && bytes.Equal(id.Bytes, cert.SubjectKeyId)
+	}
+
+	var ias issuerAndSerialNumber
+	if _, err := asn1.Unmarshal(id.FullBytes, &ias); err != nil {
+		return false
+	}
+
+	return bytes.Equal(ias.Issuer.FullBytes, cert.RawIssuer) && ias.SerialNumber.Cmp(cert.SerialNumber) == 0
+}
+
+func (ri recipientInfo) isFor(cert *x509.Certificate) bool {
+	return identifies(ri.RID, cert)
+}
+
+func (eci encryptedContentInfo) content() []byte {
+
+	rv := eci.EncryptedContent
+
+	if !rv.IsCompound {
+		return rv.Bytes
+	}
+
+	// Constructed encoding: concatenate the octet string segments.
+	var b []byte
+	rest := rv.Bytes
+	for len(rest) > 0 {
+		var seg []byte
+		var err error
+		rest, err = asn1.Unmarshal(rest, &seg)
+		if err != nil {
+			return nil
+		}
+		b = append(b, seg...)
+	}
+
+	return b
+}
+
+// rc2CBCParameter are the parameters of RC2 in CBC mode, see RFC 2268.
+type rc2CBCParameter struct {
+	Version int
+	IV      []byte
+}
+
+// rc2EffectiveKeyBits returns the effective key bits encoded by a RC2 parameter version.
+func rc2EffectiveKeyBits(version int) (int, error) {
+
+	switch {
+	case version == 160:
+		return 40, nil
+	case version == 120:
+		return 64, nil
+	case version == 58:
+		return 128, nil
+	case version >= 256:
+		return version, nil
+	}
+
+	return 0, errors.Errorf("pkcs7: unsupported RC2 parameter version %d", version)
+}
+
+// rc2CBCCipher returns a RC2 cipher.Block for key and the initialization vector encoded in params.
+func rc2CBCCipher(params asn1.RawValue, key []byte) (cipher.Block, []byte, error) {
+
+	// The parameter version is optional and defaults to 32 effective key bits.
+	var p rc2CBCParameter
+	t1 := 32
+
+	if _, err := asn1.Unmarshal(params.FullBytes, &p); err == nil {
+		if t1, err = rc2EffectiveKeyBits(p.Version); err != nil {
+			return nil, nil, err
+		}
+	} else if _, err = asn1.Unmarshal(params.FullBytes, &p.IV); err != nil {
+		return nil, nil, errors.New("pkcs7: invalid RC2 parameters")
+	}
+
+	cb, err := newRC2Cipher(key, t1)
+
+	return cb, p.IV, err
+}
+
+// contentCipher returns the block cipher and the initialization vector for the content encryption algorithm alg.
+func contentCipher(alg pkix.AlgorithmIdentifier, key []byte) (cipher.Block, []byte, error) {
+
+	var (
+		cb  cipher.Block
+		iv  []byte
+		err error
+	)
+
+	switch {
+
+	case alg.Algorithm.Equal(oidRC2CBC):
+		cb, iv, err = rc2CBCCipher(alg.Parameters, key)
+
+	case alg.Algorithm.Equal(oidDESEDE3CBC):
+		cb, err = des.NewTripleDESCipher(key)
+
+	case alg.Algorithm.Equal(oidAES128CBC), alg.Algorithm.Equal(oidAES192CBC), alg.Algorithm.Equal(oidAES256CBC):
+		cb, err = aes.NewCipher(key)
+
+	default:
+		err = errors.Errorf("pkcs7: unsupported content encryption algorithm %v", alg.Algorithm)
+
+	}
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if iv == nil {
+		if _, err = asn1.Unmarshal(alg.Parameters.FullBytes, &iv); err != nil {
+			return nil, nil, errors.New("pkcs7: invalid initialization vector")
+		}
+	}
+
+	if len(iv) != cb.BlockSize() {
+		return nil, nil, errors.New("pkcs7: invalid initialization vector")
+	}
+
+	return cb, iv, nil
+}
+
+// openEnvelope decrypts the content of a DER encoded PKCS#7 enveloped data object for the recipient identified by cert.
+// The content may be encrypted using RC2, Triple DES or AES in CBC mode and its key using RSA.
+// Returns errNoRecipient if cert is not among the recipients.
+func openEnvelope(b []byte, cert *x509.Certificate, key crypto.PrivateKey) ([]byte, error) {
+
+	var ci contentInfo
+	if _, err := asn1.Unmarshal(b, &ci); err != nil {
+		return nil, errors.Wrap(err, "pkcs7: corrupt content info")
+	}
+
+	if !ci.ContentType.Equal(oidEnvelopedData) {
+		return nil, errors.Errorf("pkcs7: unexpected content type %v", ci.ContentType)
+	}
+
+	var ed envelopedData
+	if _, err := asn1.Unmarshal(ci.Content.Bytes, &ed); err != nil {
+		return nil, errors.Wrap(err, "pkcs7: corrupt enveloped data")
+	}
+
+	var ri *recipientInfo
+	for i := range ed.RecipientInfos {
+		if ed.RecipientInfos[i].isFor(cert) {
+			ri = &ed.RecipientInfos[i]
+			break
+		}
+	}
+
+	if ri == nil {
+		return nil, errNoRecipient
+	}
+
+	if !ri.KeyEncryptionAlgorithm.Algorithm.Equal(oidRSAEncryption) {
+		return nil, errors.Errorf("pkcs7: unsupported key encryption algorithm %v", ri.KeyEncryptionAlgorithm.Algorithm)
+	}
+
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("pkcs7: private key must be a RSA key")
+	}
+
+	ck, err := rsa.DecryptPKCS1v15(rand.Reader, rsaKey, ri.EncryptedKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "pkcs7: unable to decrypt content encryption key")
+	}
+
+	eci := ed.EncryptedContentInfo
+	alg := eci.ContentEncryptionAlgorithm
+
+	cb, iv, err := contentCipher(alg, ck)
+	if err != nil {
+		return nil, err
+	}
+
+	// Don't decrypt in place, b is still needed for the file encryption key.
+	data := append([]byte{}, eci.content()...)
+	if len(data) == 0 || len(data)%cb.BlockSize() > 0 {
+		return nil, errors.New("pkcs7: invalid encrypted content")
+	}
+
+	cipher.NewCBCDecrypter(cb, iv).CryptBlocks(data, data)
+
+	// Remove padding.
+	p := int(data[len(data)-1])
+	if p == 0 || p > cb.BlockSize() {
+		return nil, errors.New("pkcs7: invalid padding")
+	}
+
+	return data[:len(data)-p], nil
+}
+
+// sealEnvelope encrypts content for a list of recipients using AES-256
+// and returns a DER encoded PKCS#7 enveloped data object.
+func sealEnvelope(content []byte, certs []*x509.Certificate) ([]byte, error) {
+
+	ck := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, ck); err != nil {
+		return nil, err
+	}
+
+	iv := make([]byte, aes.BlockSize)
+	if _, err := io.ReadFull(rand.Reader, iv); err != nil {
+		return nil, err
+	}
+
+	cb, err := aes.NewCipher(ck)
+	if err != nil {
+		return nil, err
+	}
+
+	p := aes.BlockSize - len(content)%aes.BlockSize
+	data := append(append([]byte{}, content...), bytes.Repeat([]byte{byte(p)}, p)...)
+	cipher.NewCBCEncrypter(cb, iv).CryptBlocks(data, data)
+
+	var ris []recipientInfo
+
+	for _, cert := range certs {
+
+		pub, ok := cert.PublicKey.(*rsa.PublicKey)
+		if !ok {
+			return nil, errors.Errorf("pkcs7: certificate for %s: public key must be a RSA key", cert.Subject.CommonName)
+		}
+
+		ek, err := rsa.EncryptPKCS1v15(rand.Reader, pub, ck)
+		if err != nil {
+			return nil, err
+		}
+
+		rid, err := asn1.Marshal(issuerAndSerialNumber{Issuer: asn1.RawValue{FullBytes: cert.RawIssuer}, SerialNumber: cert.SerialNumber})
+		if err != nil {
+			return nil, err
+		}
+
+		ris = append(ris, recipientInfo{
+			RID:                    asn1.RawValue{FullBytes: rid},
+			KeyEncryptionAlgorithm: pkix.AlgorithmIdentifier{Algorithm: oidRSAEncryption, Parameters: asn1.NullRawValue},
+			EncryptedKey:           ek,
+		})
+	}
+
+	params, err := asn1.Marshal(iv)
+	if err != nil {
+		return nil, err
+	}
+
+	ed := envelopedData{
+		RecipientInfos: ris,
+		EncryptedContentInfo: encryptedContentInfo{
+			ContentType:                oidData,
+			ContentEncryptionAlgorithm: pkix.AlgorithmIdentifier{Algorithm: oidAES256CBC, Parameters: asn1.RawValue{FullBytes: params}},
+			EncryptedContent:           asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, Bytes: data},
+		},
+	}
+
+	b, err := asn1.Marshal(ed)
+	if err != nil {
+		return nil, err
+	}
+
+	return asn1.Marshal(contentInfo{
+		ContentType: oidEnvelopedData,
+		Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: b},
+	})
+}
+
+func newAttribute(typ asn1.ObjectIdentifier, val interface{}) ([]byte, error) {
+
+	b, err := asn1.Marshal(val)
+	if err != nil {
+		return nil, err
+	}
+
+	return asn1.Marshal(attribute{
+		Type:   typ,
+		Values: asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true, Bytes: b},
+	})
+}
+
+// signedAttributes returns the DER encoded content of the signed attributes set.
+func signedAttributes(digest []byte, cert *x509.Certificate, t time.Time) ([]byte, error) {
+
+	certHash := sha256.Sum256(cert.Raw)
+
+	var attrs [][]byte
+
+	for _, a := range []struct {
+		typ asn1.ObjectIdentifier
+		val interface{}
+	}{
+		{oidAttributeContentType, oidData},
+		{oidAttributeMessageDigest, digest},
+		{oidAttributeSigningTime, t.UTC()},
+		{oidAttributeSigningCertificateV2, signingCertificateV2{Certs: []essCertIDv2{{CertHash: certHash[:]}}}},
+	} {
+		b, err := newAttribute(a.typ, a.val)
+		if err != nil {
+			return nil, err
+		}
+		attrs = append(attrs, b)
+	}
+
+	// DER requires the elements of a set to be sorted.
+	sort.Slice(attrs, func(i, j int) bool { return bytes.Compare(attrs[i], attrs[j]) < 0 })
+
+	return bytes.Join(attrs, nil), nil
+}
+
+// signDetached returns a DER encoded PKCS#7 detached signature for a SHA-256 content digest.
+// certs[0] is the signer certificate and needs to match key.
+func signDetached(digest []byte, certs []*x509.Certificate, key crypto.Signer, t time.Time) ([]byte, error) {
+
+	cert := certs[0]
+
+	attrs, err := signedAttributes(digest, cert, t)
+	if err != nil {
+		return nil, err
+	}
+
+	sid, err := asn1.Marshal(issuerAndSerialNumber{Issuer: asn1.RawValue{FullBytes: cert.RawIssuer}, SerialNumber: cert.SerialNumber})
+	if err != nil {
+		return nil, err
+	}
+
+	// The signature is computed over the DER encoding of the attributes as a SET OF.
+	set, err := asn1.Marshal(asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true, Bytes: attrs})
+	if err != nil {
+		return nil, err
+	}
+
+	h := sha256.Sum256(set)
+
+	var sigAlg pkix.AlgorithmIdentifier
+
+	switch key.(type) {
+	case *rsa.PrivateKey:
+		sigAlg = pkix.AlgorithmIdentifier{Algorithm: oidRSAEncryption, Parameters: asn1.NullRawValue}
+	case *ecdsa.PrivateKey:
+		sigAlg = pkix.AlgorithmIdentifier{Algorithm: oidECDSAWithSHA256}
+	default:
+		return nil, errors.New("pkcs7: unsupported private key type")
+	}
+
+	sig, err := key.Sign(rand.Reader, h[:], crypto.SHA256)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw []byte
+	for _, c := range certs {
+		raw = append(raw, c.Raw...)
+	}
+
+	digestAlg := pkix.AlgorithmIdentifier{Algorithm: oidSHA256, Parameters: asn1.NullRawValue}
+
+	sd := signedData{
+		Version:          1,
+		DigestAlgorithms: []pkix.AlgorithmIdentifier{digestAlg},
+		ContentInfo:      contentInfo{ContentType: oidData},
+		Certificates:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: raw},
+		SignerInfos: []signerInfo{{
+			Version:            1,
+			SID:                asn1.RawValue{FullBytes: sid},
+			DigestAlgorithm:    digestAlg,
+			SignedAttrs:        asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: attrs},
+			SignatureAlgorithm: sigAlg,
+			Signature:          sig,
+		}},
+	}
+
+	b, err := asn1.Marshal(sd)
+	if err != nil {
+		return nil, err
+	}
+
+	return asn1.Marshal(contentInfo{
+		ContentType: oidSignedData,
+		Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: b},
+	})
+}
+
+// signerData is a parsed PKCS#7 signedData structure along with its first signer.
+type signerData struct {
+	sd     signedData
+	si     signerInfo
+	certs  []*x509.Certificate
+	signer *x509.Certificate
+	hash   crypto.Hash
+	attrs  []attribute
+
+	unsignedAttrs []attribute
+}
+
+func digestHash(alg asn1.ObjectIdentifier) (crypto.Hash, error) {
+
+	switch {
+	case alg.Equal(oidSHA1):
+		return crypto.SHA1, nil
+	case alg.Equal(oidSHA256):
+		return crypto.SHA256, nil
+	case alg.Equal(oidSHA384):
+		return crypto.SHA384, nil
+	case alg.Equal(oidSHA512):
+		return crypto.SHA512, nil
+	}
+
+	return 0, errors.Errorf("pkcs7: unsupported digest algorithm %v", alg)
+}
+
+// parseSignedData parses a DER encoded PKCS#7 signedData structure.
+// Trailing bytes, eg. the zero padding of a signature placeholder, are ignored.
+func parseSignedData(b []byte) (*signerData, error) {
+
+	var ci contentInfo
+	if _, err := asn1.Unmarshal(b, &ci); err != nil {
+		return nil, errors.Wrap(err, "pkcs7: corrupt content info")
+	}
+
+	if !ci.ContentType.Equal(oidSignedData) {
+		return nil, errors.Errorf("pkcs7: unexpected content type %v", ci.ContentType)
+	}
+
+	sd := &signerData{}
+
+	if _, err := asn1.Unmarshal(ci.Content.Bytes, &sd.sd); err != nil {
+		return nil, errors.Wrap(err, "pkcs7: corrupt signed data")
+	}
+
+	if len(sd.sd.SignerInfos) == 0 {
+		return nil, errors.New("pkcs7: missing signer info")
+	}
+	sd.si = sd.sd.SignerInfos[0]
+
+	certs, err := x509.ParseCertificates(sd.sd.Certificates.Bytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "pkcs7: corrupt certificates")
+	}
+	sd.certs = certs
+
+	for _, c := range certs {
+		if identifies(sd.si.SID, c) {
+			sd.signer = c
+			break
+		}
+	}
+
+	if sd.signer == nil {
+		return nil, errors.New("pkcs7: missing signer certificate")
+	}
+
+	if sd.hash, err = digestHash(sd.si.DigestAlgorithm.Algorithm); err != nil {
+		return nil, err
+	}
+
+	rest := sd.si.SignedAttrs.Bytes
+	for len(rest) > 0 {
+		var a attribute
+		if rest, err = asn1.Unmarshal(rest, &a); err != nil {
+			return nil, errors.Wrap(err, "pkcs7: corrupt signed attributes")
+		}
+		sd.attrs = append(sd.attrs, a)
+	}
+
+	rest = sd.si.UnsignedAttrs.Bytes
+	for len(rest) > 0 {
+		var a attribute
+		if rest, err = asn1.Unmarshal(rest, &a); err != nil {
+			return nil, errors.Wrap(err, "pkcs7: corrupt unsigned attributes")
+		}
+		sd.unsignedAttrs = append(sd.unsignedAttrs, a)
+	}
+
+	return sd, nil
+}
+
+// attribute returns the first value of the signed attribute typ.
+func (sd signerData) attribute(typ asn1.ObjectIdentifier, val interface{}) bool {
+
+	for _, a := range sd.attrs {
+		if a.Type.Equal(typ) {
+			_, err := asn1.Unmarshal(a.Values.Bytes, val)
+			return err == nil
+		}
+	}
+
+	return false
+}
+
+// content returns the encapsulated content.
+func (sd signerData) content() ([]byte, error) {
+
+	var b []byte
+	if _, err := asn1.Unmarshal(sd.sd.ContentInfo.Content.Bytes, &b); err != nil {
+		return nil, errors.Wrap(err, "pkcs7: corrupt encapsulated content")
+	}
+
+	return b, nil
+}
+
+// signingTime returns the signing time claimed by the signer or the zero time.
+func (sd signerData) signingTime() time.Time {
+
+	var t time.Time
+	sd.attribute(oidAttributeSigningTime, &t)
+
+	return t
+}
+
+// timestampToken returns the RFC 3161 timestamp token embedded as unsigned attribute
+// along with the time it attests for the signature value of sd.
+// The token is nil if there is none.
+// The certificate of the timestamp authority is not checked.
+func (sd signerData) timestampToken() (*signerData, time.Time, error) {
+
+	var t time.Time
+
+	var token asn1.RawValue
+	found := false
+	for _, a := range sd.unsignedAttrs {
+		if a.Type.Equal(oidAttributeTimeStampToken) {
+			if _, err := asn1.Unmarshal(a.Values.Bytes, &token); err != nil {
+				return nil, t, errors.Wrap(err, "pkcs7: corrupt timestamp token")
+			}
+			found = true
+			break
+		}
+	}
+
+	if !found {
+		return nil, t, nil
+	}
+
+	tsd, err := parseSignedData(token.FullBytes)
+	if err != nil {
+		return nil, t, err
+	}
+
+	if !tsd.sd.ContentInfo.ContentType.Equal(oidTSTInfo) {
+		return nil, t, errors.Errorf("pkcs7: unexpected timestamp content type %v", tsd.sd.ContentInfo.ContentType)
+	}
+
+	content, err := tsd.content()
+	if err != nil {
+		return nil, t, err
+	}
+
+	var info tstInfo
+	if _, err = asn1.Unmarshal(content, &info); err != nil {
+		return nil, t, errors.Wrap(err, "pkcs7: corrupt timestamp info")
+	}
+
+	// The token needs to be issued for the signature value.
+	h, err := digestHash(info.MessageImprint.HashAlgorithm.Algorithm)
+	if err != nil {
+		return nil, t, err
+	}
+
+	hh := h.New()
+	hh.Write(sd.si.Signature)
+	if !bytes.Equal(hh.Sum(nil), info.MessageImprint.HashedMessage) {
+		return nil, t, errors.New("pkcs7: timestamp token not issued for this signature")
+	}
+
+	if !tsd.digestMatches(content) {
+		return nil, t, errors.New("pkcs7: timestamp token has been modified")
+	}
+
+	if err = tsd.verify(content); err != nil {
+		return nil, t, err
+	}
+
+	return tsd, info.GenTime, nil
+}
+
+func (sd signerData) digest(b []byte) []byte {
+	h := sd.hash.New()
+	h.Write(b)
+	return h.Sum(nil)
+}
+
+// digestMatches returns true if the signed message digest corresponds to content.
+func (sd signerData) digestMatches(content []byte) bool {
+
+	if len(sd.attrs) == 0 {
+		// The signature itself is computed over the content.
+		return sd.verify(content) == nil
+	}
+
+	var md []byte
+	if !sd.attribute(oidAttributeMessageDigest, &md) {
+		return false
+	}
+
+	return bytes.Equal(md, sd.digest(content))
+}
+
+// verify checks the signature value using the public key of the signer certificate.
+func (sd signerData) verify(content []byte) error {
+
+	msg := content
+
+	if len(sd.attrs) > 0 {
+		// The signature is computed over the DER encoding of the attributes as a SET OF.
+		var err error
+		msg, err = asn1.Marshal(asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true, Bytes: sd.si.SignedAttrs.Bytes})
+		if err != nil {
+			return err
+		}
+	}
+
+	digest := sd.digest(msg)
+
+	switch pub := sd.signer.PublicKey.(type) {
+
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(pub, sd.hash, digest, sd.si.Signature)
+
+	case *ecdsa.PublicKey:
+		var sig struct{ R, S *big.Int }
+		if _, err := asn1.Unmarshal(sd.si.Signature, &sig); err != nil {
+			return errors.Wrap(err, "pkcs7: corrupt ECDSA signature")
+		}
+		if !ecdsa.Verify(pub, digest, sig.R, sig.S) {
+			return errors.New("pkcs7: ECDSA verification failure")
+		}
+		return nil
+
+	}
+
+	return errors.New("pkcs7: unsupported public key type")
+}
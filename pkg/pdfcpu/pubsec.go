@@ -0,0 +1,352 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+// Functions dealing with the public-key security handler (Adobe.PubSec), see 7.6.5.
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"hash"
+	"io"
+	"io/ioutil"
+
+	"github.com/hhrutter/pdfcpu/pkg/log"
+	"github.com/pkg/errors"
+)
+
+// ReadCertificates returns all certificates contained in a PEM encoded file.
+func ReadCertificates(fileName string) ([]*x509.Certificate, error) {
+
+	b, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	var certs []*x509.Certificate
+
+	for {
+		var block *pem.Block
+		block, b = pem.Decode(b)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s", fileName)
+		}
+		certs = append(certs, cert)
+	}
+
+	if len(certs) == 0 {
+		return nil, errors.Errorf("%s: no certificate found", fileName)
+	}
+
+	return certs, nil
+}
+
+// ReadPrivateKey returns the first private key contained in a PEM encoded file.
+func ReadPrivateKey(fileName string) (crypto.PrivateKey, error) {
+
+	b, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		var block *pem.Block
+		block, b = pem.Decode(b)
+		if block == nil {
+			break
+		}
+
+		switch block.Type {
+
+		case "RSA PRIVATE KEY":
+			return x509.ParsePKCS1PrivateKey(block.Bytes)
+
+		case "EC PRIVATE KEY":
+			return x509.ParseECPrivateKey(block.Bytes)
+
+		case "PRIVATE KEY":
+			key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+			if err != nil {
+				return nil, err
+			}
+			switch key.(type) {
+			case *rsa.PrivateKey, *ecdsa.PrivateKey:
+				return key, nil
+			}
+			return nil, errors.Errorf("%s: unsupported private key type", fileName)
+
+		}
+	}
+
+	return nil, errors.Errorf("%s: no private key found", fileName)
+}
+
+func recipients(ctx *PDFContext, d *PDFDict) ([][]byte, error) {
+
+	arr := d.PDFArrayEntry("Recipients")
+	if arr == nil || len(*arr) == 0 {
+		return nil, errors.New("unsupported encryption: required entry \"Recipients\" missing")
+	}
+
+	var rr [][]byte
+
+	for _, o := range *arr {
+
+		o, err := ctx.Dereference(o)
+		if err != nil {
+			return nil, err
+		}
+
+		var b []byte
+
+		switch o := o.(type) {
+
+		case PDFStringLiteral:
+			b, err = Unescape(o.Value())
+
+		case PDFHexLiteral:
+			b, err = o.Bytes()
+
+		default:
+			err = errors.New("unsupported encryption: invalid entry \"Recipients\"")
+
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		rr = append(rr, b)
+	}
+
+	return rr, nil
+}
+
+// supportedPubSecEncryption returns a struct encapsulating the public-key encryption used.
+func supportedPubSecEncryption(ctx *PDFContext, dict *PDFDict) (*Enc, error) {
+
+	sf := dict.NameEntry("SubFilter")
+	if sf == nil {
+		return nil, errors.New("unsupported encryption: required entry \"SubFilter\" missing")
+	}
+
+	// The public-key security handler has no revision.
+	// Permission bits are interpreted like for revision 3 and later.
+	enc := &Enc{R: 4, Emd: true}
+
+	switch *sf {
+
+	case "adbe.pkcs7.s3", "adbe.pkcs7.s4":
+		v, err := getV(dict)
+		if err != nil {
+			return nil, err
+		}
+		if *v > 2 {
+			return nil, errors.Errorf("unsupported encryption: \"V\" %d invalid for %s", *v, *sf)
+		}
+		enc.V = *v
+
+		if enc.L, err = length(dict); err != nil {
+			return nil, err
+		}
+
+		if enc.Recipients, err = recipients(ctx, dict); err != nil {
+			return nil, err
+		}
+
+	case "adbe.pkcs7.s5":
+		v, err := checkV(ctx, dict)
+		if err != nil {
+			return nil, err
+		}
+		if *v < 4 {
+			return nil, errors.Errorf("unsupported encryption: \"V\" %d invalid for %s", *v, *sf)
+		}
+		enc.V = *v
+
+		// The recipients live in the crypt filter used for streams.
+		stmf := dict.NameEntry("StmF")
+		if stmf == nil || *stmf == "Identity" {
+			return nil, errors.New("unsupported encryption: missing crypt filter")
+		}
+		cf := dict.PDFDictEntry("CF").PDFDictEntry(*stmf)
+
+		if enc.Recipients, err = recipients(ctx, cf); err != nil {
+			return nil, err
+		}
+
+		if emd := cf.BooleanEntry("EncryptMetadata"); emd != nil {
+			enc.Emd = *emd
+		}
+
+		enc.L = 128
+		if l := cf.IntEntry("Length"); l != nil {
+			enc.L = *l
+			if enc.L <= 32 {
+				// Length in bytes.
+				enc.L *= 8
+			}
+		}
+		if enc.V == 5 {
+			enc.L = 256
+		}
+		if enc.L < 40 || enc.L > 256 || enc.L%8 > 0 {
+			return nil, errors.Errorf("unsupported encryption: \"Length\" %d not supported", enc.L)
+		}
+
+	default:
+		return nil, errors.Errorf("unsupported encryption: \"SubFilter\" %s not supported", *sf)
+
+	}
+
+	return enc, nil
+}
+
+// pubSecFileKey computes the file encryption key from the seed and the recipients (7.6.5.3).
+func pubSecFileKey(seed []byte, e *Enc) []byte {
+
+	var h hash.Hash = sha1.New()
+	if e.V == 5 {
+		h = sha256.New()
+	}
+
+	h.Write(seed)
+
+	for _, r := range e.Recipients {
+		h.Write(r)
+	}
+
+	if !e.Emd {
+		h.Write([]byte{0xff, 0xff, 0xff, 0xff})
+	}
+
+	key := h.Sum(nil)
+
+	if e.V == 5 {
+		return key
+	}
+
+	return key[:e.L/8]
+}
+
+// validatePubSecRecipient computes the file encryption key for the configured recipient.
+func validatePubSecRecipient(ctx *PDFContext) ([]byte, error) {
+
+	if ctx.DecryptCert == nil || ctx.DecryptKey == nil {
+		return nil, errors.New("public-key encryption: need recipient certificate and private key")
+	}
+
+	e := ctx.E
+
+	for _, r := range e.Recipients {
+
+		b, err := openEnvelope(r, ctx.DecryptCert, ctx.DecryptKey)
+		if err == errNoRecipient {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if len(b) < 24 {
+			return nil, errors.New("public-key encryption: corrupt recipient data")
+		}
+
+		// 20 bytes seed followed by 4 bytes permissions.
+		e.P = int(int32(uint32(b[20])<<24 | uint32(b[21])<<16 | uint32(b[22])<<8 | uint32(b[23])))
+
+		log.Debug.Printf("validatePubSecRecipient: P=%d\n", e.P)
+
+		return pubSecFileKey(b[:20], e), nil
+	}
+
+	return nil, errors.New("public-key encryption: certificate does not match any recipient")
+}
+
+// setupPubSecEncryption encrypts the file for a list of recipients using the public-key security handler.
+func setupPubSecEncryption(ctx *PDFContext) error {
+
+	seed := make([]byte, 24)
+	_, err := io.ReadFull(rand.Reader, seed[:20])
+	if err != nil {
+		return err
+	}
+
+	q := uint32(ctx.UserAccessPermissions)
+	seed[20], seed[21], seed[22], seed[23] = byte(q>>24), byte(q>>16), byte(q>>8), byte(q)
+
+	r, err := sealEnvelope(seed, ctx.EncryptRecipients)
+	if err != nil {
+		return err
+	}
+
+	cf := NewPDFDict()
+	cf.Insert("AuthEvent", PDFName("DocOpen"))
+
+	l := ctx.encryptKeyLength()
+	v := 4
+
+	switch {
+	case l == 256:
+		v = 5
+		cf.Insert("CFM", PDFName("AESV3"))
+	case ctx.EncryptUsingAES:
+		l = 128
+		cf.Insert("CFM", PDFName("AESV2"))
+	default:
+		cf.Insert("CFM", PDFName("V2"))
+	}
+
+	cf.Insert("Length", PDFInteger(l/8))
+	cf.Insert("Recipients", PDFArray{PDFHexLiteral(hex.EncodeToString(r))})
+
+	d1 := NewPDFDict()
+	d1.Insert("DefaultCryptFilter", cf)
+
+	d := NewPDFDict()
+	d.Insert("Filter", PDFName("Adobe.PubSec"))
+	d.Insert("SubFilter", PDFName("adbe.pkcs7.s5"))
+	d.Insert("V", PDFInteger(v))
+	d.Insert("Length", PDFInteger(l))
+	d.Insert("CF", d1)
+	d.Insert("StmF", PDFName("DefaultCryptFilter"))
+	d.Insert("StrF", PDFName("DefaultCryptFilter"))
+
+	ctx.E, err = supportedEncryption(ctx, &d)
+	if err != nil {
+		return err
+	}
+
+	ctx.E.P = int(ctx.UserAccessPermissions)
+	ctx.EncKey = pubSecFileKey(seed[:20], ctx.E)
+
+	return insertEncryptDict(ctx, &d)
+}
@@ -49,7 +49,40 @@ func ReadPDFFile(fileName string, config *Configuration) (*PDFContext, error) {
 		file.Close()
 	}()
 
-	ctx, err := NewPDFContext(fileName, file, config)
+	fileInfo, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, err := readPDF(file, fileName, fileInfo.Size(), config)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Debug.Println("readPDFFile: end")
+
+	return ctx, nil
+}
+
+// ReadPDF reads in a PDF of fileSize bytes from rs and generates a PDFContext,
+// an in-memory representation containing a cross reference table.
+func ReadPDF(rs io.ReadSeeker, fileSize int64, config *Configuration) (*PDFContext, error) {
+
+	log.Debug.Println("readPDF: begin")
+
+	ctx, err := readPDF(rs, "", fileSize, config)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Debug.Println("readPDF: end")
+
+	return ctx, nil
+}
+
+func readPDF(rs io.ReadSeeker, fileName string, fileSize int64, config *Configuration) (*PDFContext, error) {
+
+	ctx, err := NewPDFContextFromReadSeeker(rs, fileName, fileSize, config)
 	if err != nil {
 		return nil, err
 	}
@@ -68,13 +101,11 @@ func ReadPDFFile(fileName string, config *Configuration) (*PDFContext, error) {
 
 	// Make all objects explicitly available (load into memory) in corresponding xRefTable entries.
 	// Also decode any involved object streams.
-	err = dereferenceXRefTable(ctx, config)
+	err = dereferenceXRefTable(ctx, ctx.Configuration)
 	if err != nil {
 		return nil, err
 	}
 
-	log.Debug.Println("readPDFFile: end")
-
 	return ctx, nil
 }
 
@@ -125,6 +156,18 @@ func scanLines(data []byte, atEOF bool) (advance int, token []byte, err error) {
 	return 0, nil, nil
 }
 
+// readAt reads len(buf) bytes from rs starting at offset.
+func readAt(rs io.ReadSeeker, buf []byte, offset int64) error {
+
+	if _, err := rs.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+
+	_, err := io.ReadFull(rs, buf)
+
+	return err
+}
+
 func newPositionedReader(rs io.ReadSeeker, offset *int64) (*bufio.Reader, error) {
 
 	if _, err := rs.Seek(*offset, 0); err != nil {
@@ -138,7 +181,7 @@ func newPositionedReader(rs io.ReadSeeker, offset *int64) (*bufio.Reader, error)
 
 // Get the file offset of the last XRefSection.
 // Go to end of file and search backwards for the first occurrence of startxref {offset} %%EOF
-func offsetLastXRefSection(ra io.ReaderAt, fileSize int64) (*int64, error) {
+func offsetLastXRefSection(rs io.ReadSeeker, fileSize int64) (*int64, error) {
 
 	var bufSize int64 = defaultBufSize
 
@@ -151,7 +194,7 @@ func offsetLastXRefSection(ra io.ReaderAt, fileSize int64) (*int64, error) {
 
 	log.Debug.Printf("offsetLastXRefSection at %d\n", off)
 
-	if _, err := ra.ReadAt(buf, off); err != nil {
+	if err := readAt(rs, buf, off); err != nil {
 		return nil, err
 	}
 
@@ -600,7 +643,7 @@ func parseHybridXRefStream(offset *int64, ctx *PDFContext) error {
 
 	log.Debug.Println("parseHybridXRefStream: begin")
 
-	rd, err := newPositionedReader(ctx.Read.File, offset)
+	rd, err := newPositionedReader(ctx.Read.ReadSeeker, offset)
 	if err != nil {
 		return err
 	}
@@ -874,7 +917,7 @@ func parseXRefSection(s *bufio.Scanner, ctx *PDFContext) (*int64, error) {
 // if present, shall be used instead of the version specified in the Header.
 // Save PDF Version from header to xRefTable.
 // The header version comes as the first line of the file.
-func headerVersion(ra io.ReaderAt) (*PDFVersion, error) {
+func headerVersion(rs io.ReadSeeker) (*PDFVersion, error) {
 
 	log.Debug.Println("headerVersion begin")
 
@@ -882,7 +925,7 @@ func headerVersion(ra io.ReaderAt) (*PDFVersion, error) {
 	// We call this the header version.
 
 	buf := make([]byte, 10)
-	if _, err := ra.ReadAt(buf, 0); err != nil {
+	if err := readAt(rs, buf, 0); err != nil {
 		return nil, err
 	}
 
@@ -911,9 +954,9 @@ func buildXRefTableStartingAt(ctx *PDFContext, offset *int64) error {
 
 	log.Debug.Println("buildXRefTableStartingAt: begin")
 
-	file := ctx.Read.File
+	rs := ctx.Read.ReadSeeker
 
-	hv, err := headerVersion(file)
+	hv, err := headerVersion(rs)
 	if err != nil {
 		return err
 	}
@@ -922,7 +965,7 @@ func buildXRefTableStartingAt(ctx *PDFContext, offset *int64) error {
 
 	for offset != nil {
 
-		rd, err := newPositionedReader(file, offset)
+		rd, err := newPositionedReader(rs, offset)
 		if err != nil {
 			return err
 		}
@@ -941,7 +984,7 @@ func buildXRefTableStartingAt(ctx *PDFContext, offset *int64) error {
 
 			log.Debug.Println("buildXRefTableStartingAt: found xref stream")
 			ctx.Read.UsingXRefStreams = true
-			rd, err = newPositionedReader(file, offset)
+			rd, err = newPositionedReader(rs, offset)
 			if err != nil {
 				return err
 			}
@@ -974,10 +1017,18 @@ func readXRefTable(ctx *PDFContext) (err error) {
 
 	log.Debug.Println("readXRefTable: begin")
 
-	offset, err := offsetLastXRefSection(ctx.Read.File, ctx.Read.FileSize)
+	offset, err := offsetLastXRefSection(ctx.Read.ReadSeeker, ctx.Read.FileSize)
+	if err != nil {
+		return
+	}
+
+	ctx.Read.XRefOffset = *offset
+
+	n, err := eolLength(ctx.Read.ReadSeeker, ctx.Read.FileSize, ctx.Read.FileSize-1)
 	if err != nil {
 		return
 	}
+	ctx.Read.EolAtEOF = n > 0
 
 	err = buildXRefTableStartingAt(ctx, offset)
 	if err == io.EOF {
@@ -1309,7 +1360,7 @@ func dict(ctx *PDFContext, pdfDict PDFDict, objNr, genNr, endInd, streamInd int)
 func object(ctx *PDFContext, offset int64, objNr, genNr int) (o PDFObject, endInd, streamInd int, streamOffset int64, err error) {
 
 	var rd io.Reader
-	rd, err = newPositionedReader(ctx.Read.File, &offset)
+	rd, err = newPositionedReader(ctx.Read.ReadSeeker, &offset)
 	if err != nil {
 		return nil, 0, 0, 0, err
 	}
@@ -1570,7 +1621,7 @@ func loadEncodedStreamContent(ctx *PDFContext, streamDict *PDFStreamDict) ([]byt
 	}
 
 	newOffset := streamDict.StreamOffset
-	rd, err := newPositionedReader(ctx.Read.File, &newOffset)
+	rd, err := newPositionedReader(ctx.Read.ReadSeeker, &newOffset)
 	if err != nil {
 		return nil, err
 	}
@@ -2076,8 +2127,8 @@ func handleUnencryptedFile(ctx *PDFContext) error {
 
 	// Encrypt subcommand found.
 
-	if len(ctx.UserPW) == 0 && len(ctx.OwnerPW) == 0 {
-		return errors.New("encrypt: user or/and owner password missing")
+	if len(ctx.UserPW) == 0 && len(ctx.OwnerPW) == 0 && len(ctx.EncryptRecipients) == 0 {
+		return errors.New("encrypt: user or/and owner password or recipients missing")
 	}
 
 	// Ensure ctx.ID
@@ -2168,6 +2219,10 @@ func setupEncryptionKey(ctx *PDFContext, encryptDictObjNr int) error {
 		return err
 	}
 
+	if enc.Recipients != nil {
+		return setupPubSecEncryptionKey(ctx)
+	}
+
 	var ok bool
 
 	//fmt.Println("checking opw")
@@ -2204,6 +2259,26 @@ func setupEncryptionKey(ctx *PDFContext, encryptDictObjNr int) error {
 	return nil
 }
 
+func setupPubSecEncryptionKey(ctx *PDFContext) error {
+
+	if needsOwnerAndUserPassword(ctx.Mode) {
+		return errors.New("public-key encryption: passwords and permissions cannot be changed")
+	}
+
+	var err error
+
+	ctx.EncKey, err = validatePubSecRecipient(ctx)
+	if err != nil {
+		return err
+	}
+
+	if !hasNeededPermissions(ctx.Mode, ctx.E) {
+		return errors.New("Insufficient access permissions")
+	}
+
+	return nil
+}
+
 func checkForEncryption(ctx *PDFContext) error {
 
 	indRef := ctx.Encrypt
@@ -0,0 +1,1223 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math"
+
+	"github.com/hhrutter/pdfcpu/pkg/filter"
+	"github.com/hhrutter/pdfcpu/pkg/log"
+	"github.com/hhrutter/pdfcpu/pkg/pdfcpu/content"
+	"github.com/hhrutter/pdfcpu/pkg/types"
+	"github.com/pkg/errors"
+)
+
+// The vertical extent of a glyph relative to the font size used for locating text.
+const (
+	glyphDescent = -0.25
+	glyphAscent  = 0.9
+)
+
+// redactArea is a region of a page in default user space whose content gets removed.
+type redactArea struct {
+	rect types.Rectangle
+	fill []float64 // the color components of the overlay, nil for a transparent overlay.
+}
+
+// redactor removes the content within redaction areas from content streams and XObjects.
+type redactor struct {
+	xRefTable *XRefTable
+	te        *textExtractor // keeps track of the graphics and text state.
+	areas     []redactArea
+	path      []*content.Operation // the path under construction.
+	pathBox   *types.Rectangle     // the bounding box of the current path in device space.
+	clip      bool                 // the current path is used for clipping.
+}
+
+// redactedContent is the result of redacting a content stream.
+type redactedContent struct {
+	c         *content.Content
+	resources *PDFDict // the modified resources, nil if unchanged.
+	changed   bool
+}
+
+func normalizedRect(r types.Rectangle) types.Rectangle {
+	return types.NewRectangle(math.Min(r.LL.X, r.UR.X), math.Min(r.LL.Y, r.UR.Y), math.Max(r.LL.X, r.UR.X), math.Max(r.LL.Y, r.UR.Y))
+}
+
+// boundingBox returns the bounding box of the points x0 y0 x1 y1 .. transformed by m.
+func boundingBox(m matrix, coords ...float64) types.Rectangle {
+
+	r := types.NewRectangle(math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1))
+
+	for i := 0; i+1 < len(coords); i += 2 {
+		x, y := m.transform(coords[i], coords[i+1])
+		r.LL.X, r.LL.Y = math.Min(r.LL.X, x), math.Min(r.LL.Y, y)
+		r.UR.X, r.UR.Y = math.Max(r.UR.X, x), math.Max(r.UR.Y, y)
+	}
+
+	return r
+}
+
+func union(r1, r2 types.Rectangle) types.Rectangle {
+	return types.NewRectangle(math.Min(r1.LL.X, r2.LL.X), math.Min(r1.LL.Y, r2.LL.Y), math.Max(r1.UR.X, r2.UR.X), math.Max(r1.UR.Y, r2.UR.Y))
+}
+
+func (m matrix) inverse() (matrix, bool) {
+
+	a, b, c, d, e, f := m[0][0], m[0][1], m[1][0], m[1][1], m[2][0], m[2][1]
+
+	det := a*d - b*c
+	if det == 0 {
+		return identMatrix, false
+	}
+
+	return newMatrix(d/det, -b/det, -c/det, a/det, (c*f-d*e)/det, (b*e-a*f)/det), true
+}
+
+// copyDict returns a shallow copy of d.
+func copyDict(d PDFDict) PDFDict {
+
+	c := NewPDFDict()
+	for k, v := range d.Dict {
+		c.Dict[k] = v
+	}
+
+	return c
+}
+
+// hit returns true if r intersects a redaction area.
+func (rd *redactor) hit(r types.Rectangle) bool {
+
+	for _, a := range rd.areas {
+		if r.LL.X < a.rect.UR.X && r.UR.X > a.rect.LL.X && r.LL.Y < a.rect.UR.Y && r.UR.Y > a.rect.LL.Y {
+			return true
+		}
+	}
+
+	return false
+}
+
+// unitSquareHit returns true if the unit square painted by images intersects a redaction area.
+func (rd *redactor) unitSquareHit() bool {
+	return rd.hit(boundingBox(rd.te.ts.ctm, 0, 0, 1, 0, 1, 1, 0, 1))
+}
+
+func (rd *redactor) addPathOperation(op *content.Operation) {
+
+	var coords []float64
+	for _, o := range op.Operands {
+		v, _ := content.Number(o)
+		coords = append(coords, v)
+	}
+
+	if op.Operator == "re" && len(coords) == 4 {
+		x, y, w, h := coords[0], coords[1], coords[2], coords[3]
+		coords = []float64{x, y, x + w, y, x + w, y + h, x, y + h}
+	}
+
+	rd.path = append(rd.path, op)
+
+	if len(coords) < 2 {
+		return
+	}
+
+	// Control points are included which is good enough for locating curves.
+	r := boundingBox(rd.te.ts.ctm, coords...)
+	if rd.pathBox != nil {
+		r = union(*rd.pathBox, r)
+	}
+	rd.pathBox = &r
+}
+
+// paintPath returns the operations for the current path painted by op.
+// Paths intersecting a redaction area are removed unless they are used for clipping.
+func (rd *redactor) paintPath(op *content.Operation) ([]*content.Operation, bool) {
+
+	ops := append(rd.path, op)
+	hit := rd.pathBox != nil && rd.hit(*rd.pathBox)
+	clip := rd.clip
+
+	rd.path, rd.pathBox, rd.clip = nil, nil, false
+
+	if !hit || op.Operator == "n" {
+		return ops, false
+	}
+
+	if clip {
+		// Keep the clipping path but don't paint it.
+		ops[len(ops)-1] = content.NewOperation("n")
+		return ops, true
+	}
+
+	return nil, true
+}
+
+// glyphBox returns the approximate bounding box in device space of the glyph just rendered by showCode.
+func (rd *redactor) glyphBox(code []byte, trm matrix) types.Rectangle {
+
+	f := rd.te.ts.font
+	w := f.width(code)
+
+	if f.vertical {
+		return boundingBox(trm, -0.5, -w, 0.5, -w, 0.5, 0, -0.5, 0)
+	}
+
+	return boundingBox(trm, 0, glyphDescent, w, glyphDescent, w, glyphAscent, 0, glyphAscent)
+}
+
+// lineHit returns true if a redaction area intersects the text line or, for vertical writing, the text column
+// passing through the current text position.
+func (rd *redactor) lineHit() bool {
+
+	ts := rd.te.ts
+
+	trm := newMatrix(ts.fontSize*ts.hScale, 0, 0, ts.fontSize, 0, ts.rise).multiply(rd.te.tm).multiply(ts.ctm)
+
+	inv, ok := trm.inverse()
+	if !ok {
+		return true
+	}
+
+	for _, a := range rd.areas {
+		r := boundingBox(inv, a.rect.LL.X, a.rect.LL.Y, a.rect.UR.X, a.rect.LL.Y, a.rect.UR.X, a.rect.UR.Y, a.rect.LL.X, a.rect.UR.Y)
+		if r.LL.Y < glyphAscent && r.UR.Y > glyphDescent || r.LL.X < 0.5 && r.UR.X > -0.5 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// adjustment returns the TJ position adjustment equivalent to the displacement of a removed glyph.
+func (rd *redactor) adjustment(tx, ty float64) float64 {
+
+	ts := rd.te.ts
+	if ts.fontSize == 0 || ts.hScale == 0 {
+		return 0
+	}
+
+	v := -tx * 1000 / (ts.fontSize * ts.hScale)
+	if ts.font.vertical {
+		v = -ty * 1000 / ts.fontSize
+	}
+
+	return math.Round(v*1000) / 1000
+}
+
+// showString renders a string operand and appends the kept codes and
+// the position adjustments replacing removed glyphs to the TJ array a.
+func (rd *redactor) showString(o content.Object, a content.Array) (content.Array, bool) {
+
+	te := rd.te
+
+	b, ok := content.StringBytes(o)
+	if !ok {
+		return append(a, o), false
+	}
+
+	if te.ts.font == nil {
+		// Without font metrics the extent of a string is unknown.
+		if rd.lineHit() {
+			return a, true
+		}
+		return append(a, o), false
+	}
+
+	var (
+		kept    []byte
+		v       float64
+		removed bool
+	)
+
+	for _, code := range te.ts.font.codes(b) {
+
+		_, trm, tx, ty := te.showCode(code)
+
+		if rd.hit(rd.glyphBox(code, trm)) {
+			if len(kept) > 0 {
+				a = append(a, content.HexString(hex.EncodeToString(kept)))
+				kept = nil
+			}
+			v += rd.adjustment(tx, ty)
+			removed = true
+			continue
+		}
+
+		if v != 0 {
+			a = append(a, content.Real(v))
+			v = 0
+		}
+		kept = append(kept, code...)
+	}
+
+	if !removed {
+		return append(a, o), false
+	}
+
+	if len(kept) > 0 {
+		a = append(a, content.HexString(hex.EncodeToString(kept)))
+	}
+	if v != 0 {
+		a = append(a, content.Real(v))
+	}
+
+	return a, true
+}
+
+// showText returns the operations replacing a text showing operation.
+// Removed glyphs are replaced by position adjustments so the remaining text keeps its position.
+func (rd *redactor) showText(op *content.Operation) ([]*content.Operation, bool) {
+
+	te := rd.te
+	ts := &te.ts
+
+	if len(op.Operands) == 0 {
+		return []*content.Operation{op}, false
+	}
+
+	var (
+		a       content.Array
+		removed bool
+		ops     []*content.Operation
+	)
+
+	switch op.Operator {
+
+	case "Tj":
+		a, removed = rd.showString(op.Operands[0], nil)
+
+	case "'":
+		te.moveTextLine(0, -ts.leading)
+		ops = append(ops, content.NewOperation("T*"))
+		a, removed = rd.showString(op.Operands[0], nil)
+
+	case "\"":
+		if len(op.Operands) < 3 {
+			return []*content.Operation{op}, false
+		}
+		ts.wordSpacing, _ = content.Number(op.Operands[0])
+		ts.charSpacing, _ = content.Number(op.Operands[1])
+		te.moveTextLine(0, -ts.leading)
+		ops = append(ops,
+			content.NewOperation("Tw", op.Operands[0]),
+			content.NewOperation("Tc", op.Operands[1]),
+			content.NewOperation("T*"))
+		a, removed = rd.showString(op.Operands[2], nil)
+
+	case "TJ":
+		arr, ok := op.Operands[0].(content.Array)
+		if !ok {
+			return []*content.Operation{op}, false
+		}
+		for _, o := range arr {
+			if v, ok := content.Number(o); ok {
+				te.adjust(v)
+				a = append(a, o)
+				continue
+			}
+			var r bool
+			a, r = rd.showString(o, a)
+			removed = removed || r
+		}
+
+	}
+
+	if !removed {
+		return []*content.Operation{op}, false
+	}
+
+	if a == nil {
+		a = content.Array{}
+	}
+
+	return append(ops, content.NewOperation("TJ", a)), true
+}
+
+// imageComponents returns the number of color components of an image color space or 0 if unknown.
+func imageComponents(xRefTable *XRefTable, o PDFObject) int {
+
+	o, err := xRefTable.Dereference(o)
+	if err != nil {
+		return 0
+	}
+
+	switch cs := o.(type) {
+
+	case PDFName:
+		switch cs {
+		case DeviceGrayCS, CalGrayCS:
+			return 1
+		case DeviceRGBCS, CalRGBCS:
+			return 3
+		case DeviceCMYKCS:
+			return 4
+		}
+
+	case PDFArray:
+		if len(cs) < 2 {
+			return 0
+		}
+		o, _ := xRefTable.Dereference(cs[0])
+		switch o {
+		case PDFName(CalGrayCS), PDFName(IndexedCS), PDFName(SeparationCS):
+			return 1
+		case PDFName(CalRGBCS), PDFName(LabCS):
+			return 3
+		case PDFName(ICCBasedCS):
+			sd, _ := xRefTable.DereferenceStreamDict(cs[1])
+			if sd != nil && sd.IntEntry("N") != nil {
+				return *sd.IntEntry("N")
+			}
+		case PDFName(DeviceNCS):
+			a, _ := xRefTable.DereferenceArray(cs[1])
+			if a != nil {
+				return len(*a)
+			}
+		}
+
+	}
+
+	return 0
+}
+
+// clearBits clears the bits from (inclusive) to (exclusive) of b.
+func clearBits(b []byte, from, to int) {
+	for i := from; i < to; i++ {
+		b[i/8] &^= 0x80 >> uint(i%8)
+	}
+}
+
+func clamp(i, min, max int) int {
+
+	if i < min {
+		return min
+	}
+
+	if i > max {
+		return max
+	}
+
+	return i
+}
+
+// redactImage returns a copy of the image XObject o with all pixels within the redaction areas cleared
+// or nil if the image data cannot be decoded.
+func (rd *redactor) redactImage(o PDFObject, sd *PDFStreamDict) (*PDFIndirectRef, error) {
+
+	xRefTable := rd.xRefTable
+
+	// Image space is mapped onto the unit square by the CTM.
+	inv, ok := rd.te.ts.ctm.inverse()
+	if !ok {
+		return nil, nil
+	}
+
+	width, _ := xRefTable.DereferenceInteger(sd.Dict["Width"])
+	height, _ := xRefTable.DereferenceInteger(sd.Dict["Height"])
+	if width == nil || height == nil {
+		return nil, nil
+	}
+	w, h := width.Value(), height.Value()
+
+	bpc, comps := 1, 1
+	if im := sd.BooleanEntry("ImageMask"); im == nil || !*im {
+		i, _ := xRefTable.DereferenceInteger(sd.Dict["BitsPerComponent"])
+		if i == nil {
+			return nil, nil
+		}
+		bpc, comps = i.Value(), imageComponents(xRefTable, sd.Dict["ColorSpace"])
+	}
+
+	b := streamContent(xRefTable, o)
+
+	bpp := bpc * comps
+	rowLen := (w*bpp + 7) / 8
+
+	if w <= 0 || h <= 0 || bpp <= 0 || b == nil || len(b) < rowLen*h {
+		return nil, nil
+	}
+
+	data := make([]byte, rowLen*h)
+	copy(data, b)
+
+	for _, a := range rd.areas {
+
+		r := boundingBox(inv, a.rect.LL.X, a.rect.LL.Y, a.rect.UR.X, a.rect.LL.Y, a.rect.UR.X, a.rect.UR.Y, a.rect.LL.X, a.rect.UR.Y)
+
+		// The first row of samples is the top of the unit square.
+		x0 := clamp(int(math.Floor(r.LL.X*float64(w))), 0, w)
+		x1 := clamp(int(math.Ceil(r.UR.X*float64(w))), 0, w)
+		y0 := clamp(int(math.Floor((1-r.UR.Y)*float64(h))), 0, h)
+		y1 := clamp(int(math.Ceil((1-r.LL.Y)*float64(h))), 0, h)
+
+		for y := y0; y < y1; y++ {
+			clearBits(data[y*rowLen:(y+1)*rowLen], x0*bpp, x1*bpp)
+		}
+	}
+
+	d := copyDict(sd.PDFDict)
+	d.Delete("DecodeParms")
+	d.Delete("Filter")
+	d.InsertName("Filter", filter.Flate)
+
+	nsd := &PDFStreamDict{
+		PDFDict:        d,
+		Content:        data,
+		FilterPipeline: []PDFFilter{{Name: filter.Flate, DecodeParms: nil}}}
+
+	err := encodeStream(nsd)
+	if err != nil {
+		return nil, err
+	}
+
+	return xRefTable.IndRefForNewObject(*nsd)
+}
+
+// redactForm returns a copy of the form XObject o with the content within the redaction areas removed
+// or nil if the form is not affected.
+func (rd *redactor) redactForm(o PDFObject, sd *PDFStreamDict, resources *PDFDict) (*PDFIndirectRef, bool, error) {
+
+	xRefTable := rd.xRefTable
+	te := rd.te
+
+	ctm := te.ts.ctm
+	if a, err := xRefTable.DereferenceArray(sd.Dict["Matrix"]); err == nil && a != nil && len(*a) == 6 {
+		var f [6]float64
+		for i, o := range *a {
+			f[i] = xRefTable.DereferenceNumber(o)
+		}
+		ctm = newMatrix(f[0], f[1], f[2], f[3], f[4], f[5]).multiply(ctm)
+	}
+
+	if a, err := xRefTable.DereferenceArray(sd.Dict["BBox"]); err == nil && a != nil && len(*a) == 4 {
+		r := rect(xRefTable, *a)
+		if !rd.hit(boundingBox(ctm, r.LL.X, r.LL.Y, r.UR.X, r.LL.Y, r.UR.X, r.UR.Y, r.LL.X, r.UR.Y)) {
+			return nil, false, nil
+		}
+	}
+
+	indRef, ok := o.(PDFIndirectRef)
+	if !ok {
+		return nil, false, nil
+	}
+
+	objNr := indRef.ObjectNumber.Value()
+	if te.forms[objNr] {
+		// Recursive form.
+		return nil, false, nil
+	}
+
+	b := streamContent(xRefTable, o)
+	if b == nil {
+		// The form cannot be inspected and therefore is removed.
+		return nil, true, nil
+	}
+
+	formResources, err := xRefTable.DereferenceDict(sd.Dict["Resources"])
+	if err != nil {
+		return nil, false, err
+	}
+	if formResources == nil {
+		formResources = resources
+	}
+
+	saved, stack := te.ts, te.stack
+	tm, tlm := te.tm, te.tlm
+
+	te.ts.ctm, te.stack = ctm, nil
+
+	te.forms[objNr] = true
+	rc, err := rd.redactContent(b, formResources)
+	delete(te.forms, objNr)
+
+	te.ts, te.stack = saved, stack
+	te.tm, te.tlm = tm, tlm
+
+	if err != nil {
+		return nil, false, errors.Wrapf(err, "form XObject %d", objNr)
+	}
+
+	if !rc.changed {
+		return &indRef, false, nil
+	}
+
+	d := copyDict(sd.PDFDict)
+	d.Delete("DecodeParms")
+	d.Delete("Filter")
+	d.InsertName("Filter", filter.Flate)
+	if rc.resources != nil {
+		d.Update("Resources", *rc.resources)
+	}
+
+	nsd := &PDFStreamDict{
+		PDFDict:        d,
+		Content:        rc.c.Bytes(),
+		FilterPipeline: []PDFFilter{{Name: filter.Flate, DecodeParms: nil}}}
+
+	err = encodeStream(nsd)
+	if err != nil {
+		return nil, false, err
+	}
+
+	ir, err := xRefTable.IndRefForNewObject(*nsd)
+
+	return ir, true, err
+}
+
+// redactXObject handles the operation "Do name".
+// It returns the XObject to be used instead of name, nil for removing the operation.
+func (rd *redactor) redactXObject(name string, resources *PDFDict) (o PDFObject, changed bool, err error) {
+
+	if resources == nil {
+		return nil, false, nil
+	}
+
+	xobjs, err := rd.xRefTable.DereferenceDict(resources.Dict["XObject"])
+	if err != nil || xobjs == nil {
+		return nil, false, err
+	}
+
+	o, found := xobjs.Find(name)
+	if !found {
+		return nil, false, nil
+	}
+
+	sd, err := rd.xRefTable.DereferenceStreamDict(o)
+	if err != nil || sd == nil {
+		return nil, false, err
+	}
+
+	st := sd.Subtype()
+	if st == nil {
+		return o, false, nil
+	}
+
+	switch *st {
+
+	case "Image":
+		if !rd.unitSquareHit() {
+			return o, false, nil
+		}
+		indRef, err := rd.redactImage(o, sd)
+		if err != nil || indRef == nil {
+			// Images we cannot decode are removed altogether.
+			return nil, true, err
+		}
+		return *indRef, true, nil
+
+	case "Form":
+		indRef, changed, err := rd.redactForm(o, sd, resources)
+		if err != nil || !changed {
+			return o, false, err
+		}
+		if indRef == nil {
+			return nil, true, nil
+		}
+		return *indRef, true, nil
+
+	}
+
+	return o, false, nil
+}
+
+// uniqueName returns a name for a redacted copy of the XObject name.
+func uniqueName(xobjs *PDFDict, replaced map[string]PDFObject, name string) string {
+
+	for i := 0; ; i++ {
+		n := fmt.Sprintf("%sR%d", name, i)
+		_, found := xobjs.Find(n)
+		if _, ok := replaced[n]; !found && !ok {
+			return n
+		}
+	}
+}
+
+// updateXObjects returns a copy of resources containing the redacted copies of XObjects.
+// XObjects no longer invoked get removed from the copy so their content does not survive.
+func (rd *redactor) updateXObjects(resources *PDFDict, ops []*content.Operation, replaced map[string]PDFObject, touched map[string]bool) (*PDFDict, error) {
+
+	xobjs, err := rd.xRefTable.DereferenceDict(resources.Dict["XObject"])
+	if err != nil || xobjs == nil {
+		return nil, err
+	}
+
+	used := map[string]bool{}
+	for _, op := range ops {
+		if op.Operator == "Do" && len(op.Operands) > 0 {
+			if n, ok := op.Operands[0].(content.Name); ok {
+				used[string(n)] = true
+			}
+		}
+	}
+
+	d := copyDict(*xobjs)
+
+	for name := range touched {
+		if !used[name] {
+			d.Delete(name)
+		}
+	}
+
+	for name, o := range replaced {
+		d.Insert(name, o)
+	}
+
+	res := copyDict(*resources)
+	res.Update("XObject", d)
+
+	return &res, nil
+}
+
+// redactContent removes everything within the redaction areas from the content stream b.
+func (rd *redactor) redactContent(b []byte, resources *PDFDict) (*redactedContent, error) {
+
+	c, err := content.Parse(b)
+	if err != nil {
+		// Content which cannot be parsed cannot be redacted reliably.
+		return nil, err
+	}
+
+	var (
+		ops      []*content.Operation
+		changed  bool
+		marks    []*content.Operation // the open marked content sequences.
+		tainted  = map[*content.Operation]bool{}
+		replaced = map[string]PDFObject{} // redacted XObjects by name.
+		touched  = map[string]bool{}      // XObjects replaced or removed.
+	)
+
+	for _, op := range c.Operations {
+
+		switch op.Operator {
+
+		case "m", "l", "c", "v", "y", "re", "h":
+			rd.addPathOperation(op)
+			continue
+
+		case "W", "W*":
+			rd.path = append(rd.path, op)
+			rd.clip = true
+			continue
+
+		case "S", "s", "f", "F", "f*", "B", "B*", "b", "b*", "n":
+			pathOps, removed := rd.paintPath(op)
+			ops = append(ops, pathOps...)
+			changed = changed || removed
+			continue
+
+		case "Tj", "TJ", "'", "\"":
+			textOps, removed := rd.showText(op)
+			ops = append(ops, textOps...)
+			if removed {
+				changed = true
+				for _, m := range marks {
+					tainted[m] = true
+				}
+			}
+			continue
+
+		case "BI":
+			if rd.unitSquareHit() {
+				changed = true
+				continue
+			}
+
+		case "Do":
+			if len(op.Operands) != 1 {
+				break
+			}
+			n, ok := op.Operands[0].(content.Name)
+			if !ok {
+				break
+			}
+			o, removed, err := rd.redactXObject(string(n), resources)
+			if err != nil {
+				return nil, err
+			}
+			if !removed {
+				ops = append(ops, op)
+				continue
+			}
+			changed = true
+			touched[string(n)] = true
+			if o != nil {
+				name := uniqueName(xObjectDict(rd.xRefTable, resources), replaced, string(n))
+				replaced[name] = o
+				ops = append(ops, content.NewOperation("Do", content.Name(name)))
+			}
+			continue
+
+		case "BMC", "BDC":
+			marks = append(marks, op)
+
+		case "EMC":
+			if len(marks) > 0 {
+				marks = marks[:len(marks)-1]
+			}
+
+		}
+
+		// A path not painted does not show up.
+		ops = append(ops, rd.path...)
+		rd.path, rd.pathBox, rd.clip = nil, nil, false
+
+		err = rd.te.processOperator(op.Operator, op.Operands, resources)
+		if err != nil {
+			return nil, err
+		}
+
+		ops = append(ops, op)
+	}
+
+	ops = append(ops, rd.path...)
+	rd.path, rd.pathBox, rd.clip = nil, nil, false
+
+	c.Operations = ops
+
+	rc := &redactedContent{c: c, changed: changed}
+
+	if !changed {
+		return rc, nil
+	}
+
+	// Replacement text of marked content might reveal removed text.
+	for op := range tainted {
+		if d, ok := op.Operands[len(op.Operands)-1].(content.Dict); ok {
+			delete(d, "ActualText")
+			delete(d, "Alt")
+			delete(d, "E")
+		}
+	}
+
+	if len(touched) > 0 {
+		rc.resources, err = rd.updateXObjects(resources, ops, replaced, touched)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return rc, nil
+}
+
+// xObjectDict returns the XObject dict of resources.
+func xObjectDict(xRefTable *XRefTable, resources *PDFDict) *PDFDict {
+
+	d, _ := xRefTable.DereferenceDict(resources.Dict["XObject"])
+	if d == nil {
+		return &PDFDict{}
+	}
+
+	return d
+}
+
+// overlay returns the operations painting the redaction areas.
+func overlay(areas []redactArea) []*content.Operation {
+
+	var ops []*content.Operation
+
+	for _, a := range areas {
+
+		var color *content.Operation
+
+		switch c := a.fill; len(c) {
+
+		case 1:
+			color = content.NewOperation("g", content.Real(c[0]))
+
+		case 3:
+			color = content.NewOperation("rg", content.Real(c[0]), content.Real(c[1]), content.Real(c[2]))
+
+		case 4:
+			color = content.NewOperation("k", content.Real(c[0]), content.Real(c[1]), content.Real(c[2]), content.Real(c[3]))
+
+		default:
+			continue
+		}
+
+		r := a.rect
+
+		ops = append(ops,
+			content.NewOperation("q"),
+			color,
+			content.NewOperation("re", content.Real(r.LL.X), content.Real(r.LL.Y), content.Real(r.Width()), content.Real(r.Height())),
+			content.NewOperation("f"),
+			content.NewOperation("Q"))
+	}
+
+	return ops
+}
+
+// redactAnnotRects returns the regions covered by a Redact annotation.
+func redactAnnotRects(xRefTable *XRefTable, d *PDFDict) []types.Rectangle {
+
+	var rs []types.Rectangle
+
+	if a, _ := xRefTable.DereferenceArray(d.Dict["QuadPoints"]); a != nil && len(*a) > 0 && len(*a)%8 == 0 {
+		for i := 0; i < len(*a); i += 8 {
+			var f [8]float64
+			for j := range f {
+				f[j] = xRefTable.DereferenceNumber((*a)[i+j])
+			}
+			rs = append(rs, boundingBox(identMatrix, f[:]...))
+		}
+		return rs
+	}
+
+	if a, _ := xRefTable.DereferenceArray(d.Dict["Rect"]); a != nil && len(*a) == 4 {
+		rs = append(rs, normalizedRect(rect(xRefTable, *a)))
+	}
+
+	return rs
+}
+
+// removeRedactAnnots removes the Redact annotations of a page along with their popups
+// and returns the areas to be redacted.
+func removeRedactAnnots(xRefTable *XRefTable, pageDict *PDFDict) ([]redactArea, error) {
+
+	annots, err := xRefTable.DereferenceArray(pageDict.Dict["Annots"])
+	if err != nil || annots == nil {
+		return nil, err
+	}
+
+	var (
+		areas   []redactArea
+		kept    PDFArray
+		removed = IntSet{}
+	)
+
+	for _, o := range *annots {
+
+		d, err := xRefTable.DereferenceDict(o)
+		if err != nil || d == nil || d.Subtype() == nil || *d.Subtype() != "Redact" {
+			kept = append(kept, o)
+			continue
+		}
+
+		// The overlay is transparent unless there is an interior color.
+		var fill []float64
+		if a, _ := xRefTable.DereferenceArray(d.Dict["IC"]); a != nil {
+			for _, o := range *a {
+				fill = append(fill, xRefTable.DereferenceNumber(o))
+			}
+		}
+
+		for _, r := range redactAnnotRects(xRefTable, d) {
+			areas = append(areas, redactArea{rect: r, fill: fill})
+		}
+
+		if indRef := d.IndirectRefEntry("Popup"); indRef != nil {
+			removed[indRef.ObjectNumber.Value()] = true
+		}
+	}
+
+	if len(kept) == len(*annots) {
+		return nil, nil
+	}
+
+	a := PDFArray{}
+	for _, o := range kept {
+		if indRef, ok := o.(PDFIndirectRef); ok && removed[indRef.ObjectNumber.Value()] {
+			continue
+		}
+		a = append(a, o)
+	}
+
+	if len(a) == 0 {
+		pageDict.Delete("Annots")
+	} else {
+		pageDict.Update("Annots", a)
+	}
+
+	return areas, nil
+}
+
+// redactAppearance returns a copy of the appearance stream o of an annotation located at annotRect
+// with the content within the redaction areas removed, nil for removing the appearance.
+func (rd *redactor) redactAppearance(o PDFObject, sd *PDFStreamDict, annotRect types.Rectangle) (PDFObject, bool, error) {
+
+	xRefTable := rd.xRefTable
+
+	a, err := xRefTable.DereferenceArray(sd.Dict["BBox"])
+	if err != nil || a == nil || len(*a) != 4 {
+		return nil, true, err
+	}
+	bbox := rect(xRefTable, *a)
+
+	m := identMatrix
+	if a, err := xRefTable.DereferenceArray(sd.Dict["Matrix"]); err == nil && a != nil && len(*a) == 6 {
+		var f [6]float64
+		for i, o := range *a {
+			f[i] = xRefTable.DereferenceNumber(o)
+		}
+		m = newMatrix(f[0], f[1], f[2], f[3], f[4], f[5])
+	}
+
+	// The transformed bounding box of the form gets mapped onto the annotation rectangle.
+	r := boundingBox(m, bbox.LL.X, bbox.LL.Y, bbox.UR.X, bbox.LL.Y, bbox.UR.X, bbox.UR.Y, bbox.LL.X, bbox.UR.Y)
+	if r.Width() == 0 || r.Height() == 0 {
+		return nil, true, nil
+	}
+
+	sx, sy := annotRect.Width()/r.Width(), annotRect.Height()/r.Height()
+
+	rd.te = newTextExtractor(xRefTable)
+	rd.te.ts.ctm = newMatrix(sx, 0, 0, sy, annotRect.LL.X-r.LL.X*sx, annotRect.LL.Y-r.LL.Y*sy)
+
+	indRef, changed, err := rd.redactForm(o, sd, nil)
+	if err != nil || !changed {
+		return o, false, err
+	}
+
+	if indRef == nil {
+		return nil, true, nil
+	}
+
+	return *indRef, true, nil
+}
+
+// redactAppearances replaces the appearance streams of an annotation intersecting a redaction area by redacted copies.
+func (rd *redactor) redactAppearances(d *PDFDict) error {
+
+	xRefTable := rd.xRefTable
+
+	a, err := xRefTable.DereferenceArray(d.Dict["Rect"])
+	if err != nil || a == nil || len(*a) != 4 {
+		return err
+	}
+
+	r := normalizedRect(rect(xRefTable, *a))
+	if !rd.hit(r) {
+		return nil
+	}
+
+	ap, err := xRefTable.DereferenceDict(d.Dict["AP"])
+	if err != nil || ap == nil {
+		return err
+	}
+
+	// redact returns the redacted copy of the appearance stream o or nil.
+	redact := func(o PDFObject) (PDFObject, bool, error) {
+		sd, err := xRefTable.DereferenceStreamDict(o)
+		if err != nil || sd == nil {
+			return o, false, err
+		}
+		return rd.redactAppearance(o, sd, r)
+	}
+
+	nap := copyDict(*ap)
+	changed := false
+
+	for _, k := range []string{"N", "R", "D"} {
+
+		o, found := ap.Find(k)
+		if !found {
+			continue
+		}
+
+		o1, err := xRefTable.Dereference(o)
+		if err != nil {
+			return err
+		}
+
+		if states, ok := o1.(PDFDict); ok {
+			// An appearance subdictionary of appearance states.
+			ns := copyDict(states)
+			for state, o := range states.Dict {
+				o, ch, err := redact(o)
+				if err != nil {
+					return err
+				}
+				if !ch {
+					continue
+				}
+				changed = true
+				if o == nil {
+					ns.Delete(state)
+					continue
+				}
+				ns.Update(state, o)
+			}
+			nap.Update(k, ns)
+			continue
+		}
+
+		o, ch, err := redact(o)
+		if err != nil {
+			return err
+		}
+		if !ch {
+			continue
+		}
+		changed = true
+		if o == nil {
+			nap.Delete(k)
+			continue
+		}
+		nap.Update(k, o)
+	}
+
+	if !changed {
+		return nil
+	}
+
+	if _, found := nap.Find("N"); !found {
+		d.Delete("AP")
+		return nil
+	}
+
+	d.Update("AP", nap)
+
+	return nil
+}
+
+// redactAnnots redacts the appearance streams of the annotations of a page.
+func (rd *redactor) redactAnnots(pageDict *PDFDict) error {
+
+	annots, err := rd.xRefTable.DereferenceArray(pageDict.Dict["Annots"])
+	if err != nil || annots == nil {
+		return err
+	}
+
+	for _, o := range *annots {
+
+		d, err := rd.xRefTable.DereferenceDict(o)
+		if err != nil || d == nil {
+			continue
+		}
+
+		if err = rd.redactAppearances(d); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func redactPage(xRefTable *XRefTable, pageNr int, areas []redactArea) error {
+
+	pageDict, inhPAttrs, err := xRefTable.PageDict(pageNr)
+	if err != nil {
+		return err
+	}
+
+	if pageDict == nil {
+		return errors.Errorf("redact: page %d not found", pageNr)
+	}
+
+	annotAreas, err := removeRedactAnnots(xRefTable, pageDict)
+	if err != nil {
+		return err
+	}
+
+	areas = append(areas[:len(areas):len(areas)], annotAreas...)
+	if len(areas) == 0 {
+		return nil
+	}
+
+	log.Debug.Printf("redactPage %d: %d areas\n", pageNr, len(areas))
+
+	b, err := pageContent(xRefTable, pageDict)
+	if err != nil {
+		return err
+	}
+
+	rd := &redactor{xRefTable: xRefTable, te: newTextExtractor(xRefTable), areas: areas}
+
+	rc, err := rd.redactContent(b, inhPAttrs.resources)
+	if err != nil {
+		return errors.Wrapf(err, "redact: page %d", pageNr)
+	}
+
+	// Appearance streams of annotations might reveal redacted content as well.
+	stack := len(rd.te.stack)
+	if err = rd.redactAnnots(pageDict); err != nil {
+		return errors.Wrapf(err, "redact: page %d annotations", pageNr)
+	}
+
+	ops := overlay(areas)
+	if !rc.changed && len(ops) == 0 {
+		return nil
+	}
+
+	// Isolate the page content from the overlay.
+	c := rc.c
+	c.Operations = append([]*content.Operation{content.NewOperation("q")}, c.Operations...)
+	for i := 0; i <= stack; i++ {
+		c.Operations = append(c.Operations, content.NewOperation("Q"))
+	}
+	c.Operations = append(c.Operations, ops...)
+
+	sd := &PDFStreamDict{
+		PDFDict:        NewPDFDict(),
+		Content:        c.Bytes(),
+		FilterPipeline: []PDFFilter{{Name: filter.Flate, DecodeParms: nil}}}
+
+	sd.InsertName("Filter", filter.Flate)
+
+	err = encodeStream(sd)
+	if err != nil {
+		return err
+	}
+
+	indRef, err := xRefTable.IndRefForNewObject(*sd)
+	if err != nil {
+		return err
+	}
+
+	// The original content streams are no longer referenced by this page.
+	pageDict.Update("Contents", *indRef)
+
+	if rc.resources != nil {
+		pageDict.Update("Resources", *rc.resources)
+	}
+
+	return nil
+}
+
+// Redact removes the content within rects and within the areas of Redact annotations from the selected pages.
+// Text, vector paths and inline images intersecting these areas are removed from the page content,
+// from form XObjects and from annotation appearance streams, image XObjects get their pixels within the areas cleared.
+// Text shown in a font without usable metrics is removed if its line intersects an area.
+// rects are painted black, Redact annotations are painted using their interior color and are removed.
+func Redact(xRefTable *XRefTable, selectedPages IntSet, rects []types.Rectangle) error {
+
+	log.Debug.Println("Redact begin")
+
+	areas := make([]redactArea, len(rects))
+	for i, r := range rects {
+		areas[i] = redactArea{rect: normalizedRect(r), fill: []float64{0}}
+	}
+
+	for p := 1; p <= xRefTable.PageCount; p++ {
+
+		if !selectedPages[p] {
+			continue
+		}
+
+		err := redactPage(xRefTable, p, areas)
+		if err != nil {
+			return err
+		}
+	}
+
+	log.Debug.Println("Redact end")
+
+	return nil
+}
@@ -0,0 +1,129 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hhrutter/pdfcpu/pkg/types"
+)
+
+func TestRedactTextWithoutFont(t *testing.T) {
+
+	xRefTable, err := createXRefTableWithRootDict()
+	if err != nil {
+		t.Fatalf("TestRedactTextWithoutFont: %v\n", err)
+	}
+
+	// The font F1 cannot be resolved.
+	b := []byte("BT /F1 12 Tf 100 700 Td (secret) Tj 0 -100 Td (public) Tj ET")
+
+	for _, tt := range []struct {
+		r       types.Rectangle
+		removed []string
+		kept    []string
+	}{
+		// Beyond the start of the first line.
+		{types.NewRectangle(200, 695, 250, 710), []string{"secret"}, []string{"public"}},
+		// Before the start of the first line.
+		{types.NewRectangle(10, 695, 50, 710), []string{"secret"}, []string{"public"}},
+		// Between the lines.
+		{types.NewRectangle(200, 620, 250, 680), nil, []string{"secret", "public"}},
+	} {
+
+		rd := &redactor{xRefTable: xRefTable, te: newTextExtractor(xRefTable), areas: []redactArea{{rect: tt.r}}}
+
+		rc, err := rd.redactContent(b, nil)
+		if err != nil {
+			t.Fatalf("TestRedactTextWithoutFont: %v\n", err)
+		}
+
+		s := string(rc.c.Bytes())
+
+		if rc.changed != (len(tt.removed) > 0) {
+			t.Fatalf("TestRedactTextWithoutFont: %v: changed=%t\n%s\n", tt.r, rc.changed, s)
+		}
+
+		for _, w := range tt.removed {
+			if strings.Contains(s, w) {
+				t.Fatalf("TestRedactTextWithoutFont: %v: %s not removed\n%s\n", tt.r, w, s)
+			}
+		}
+
+		for _, w := range tt.kept {
+			if !strings.Contains(s, w) {
+				t.Fatalf("TestRedactTextWithoutFont: %v: %s removed\n%s\n", tt.r, w, s)
+			}
+		}
+	}
+}
+
+func TestRedactAnnotationAppearances(t *testing.T) {
+
+	xRefTable, err := CreateAcroFormDemoXRef()
+	if err != nil {
+		t.Fatalf("TestRedactAnnotationAppearances: %v\n", err)
+	}
+
+	pageDict, _, err := xRefTable.PageDict(1)
+	if err != nil || pageDict == nil {
+		t.Fatalf("TestRedactAnnotationAppearances: %v\n", err)
+	}
+
+	annots, _ := xRefTable.DereferenceArray(pageDict.Dict["Annots"])
+	if annots == nil {
+		t.Fatalf("TestRedactAnnotationAppearances: missing annotations\n")
+	}
+
+	// appearances returns the normal, rollover and down appearance of the text field.
+	appearances := func() []PDFObject {
+		for _, o := range *annots {
+			d, _ := xRefTable.DereferenceDict(o)
+			if d == nil || d.StringEntry("T") == nil || *d.StringEntry("T") != "inputField" {
+				continue
+			}
+			ap, _ := xRefTable.DereferenceDict(d.Dict["AP"])
+			if ap == nil {
+				t.Fatalf("TestRedactAnnotationAppearances: missing appearance\n")
+			}
+			return []PDFObject{ap.Dict["N"], ap.Dict["R"], ap.Dict["D"]}
+		}
+		t.Fatalf("TestRedactAnnotationAppearances: missing text field\n")
+		return nil
+	}
+
+	before := appearances()
+
+	// The text field outline runs through this area.
+	err = redactPage(xRefTable, 1, []redactArea{{rect: types.NewRectangle(90, 310, 110, 315)}})
+	if err != nil {
+		t.Fatalf("TestRedactAnnotationAppearances: %v\n", err)
+	}
+
+	for i, o := range appearances() {
+
+		if o == before[i] {
+			t.Fatalf("TestRedactAnnotationAppearances: appearance %d not redacted\n", i)
+		}
+
+		s := string(streamContent(xRefTable, o))
+		if strings.Contains(s, " l") {
+			t.Fatalf("TestRedactAnnotationAppearances: appearance %d still paints the outline:\n%s\n", i, s)
+		}
+	}
+}
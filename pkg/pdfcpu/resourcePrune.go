@@ -0,0 +1,360 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"github.com/hhrutter/pdfcpu/pkg/log"
+	"github.com/hhrutter/pdfcpu/pkg/pdfcpu/content"
+	"github.com/pkg/errors"
+)
+
+// The resource categories referred to by name from within content streams.
+var prunableResources = map[string]bool{
+	"Font": true, "XObject": true, "ExtGState": true, "ColorSpace": true, "Pattern": true, "Shading": true,
+}
+
+// Default color spaces are used implicitly by device color operators.
+var defaultColorSpaces = map[string]bool{
+	"DefaultGray": true, "DefaultRGB": true, "DefaultCMYK": true,
+}
+
+type resourcePruner struct {
+	xRefTable *XRefTable
+	forms     IntSet // form XObjects with own resources already processed.
+	active    IntSet // form XObjects using the resources of the invoking content being processed.
+	pruned    int    // the number of resource entries removed.
+}
+
+// collect adds the names of the resources used by content b to used.
+// Form XObjects having their own resources are pruned on the fly.
+// ok is false if the content cannot be parsed.
+func (rp *resourcePruner) collect(b []byte, resources *PDFDict, used map[string]map[string]bool) (ok bool, err error) {
+
+	c, err := content.Parse(b)
+	if err != nil {
+		log.Debug.Printf("resourcePruner: %v\n", err)
+		return false, nil
+	}
+
+	names := c.ResourceNames()
+
+	for category, m := range names {
+		if used[category] == nil {
+			used[category] = map[string]bool{}
+		}
+		for n := range m {
+			used[category][n] = true
+		}
+	}
+
+	xobjs, err := rp.xRefTable.DereferenceDict(resources.Dict["XObject"])
+	if err != nil || xobjs == nil {
+		return true, err
+	}
+
+	for n := range names["XObject"] {
+
+		o, _ := xobjs.Find(n)
+
+		indRef, ok := o.(PDFIndirectRef)
+		if !ok {
+			continue
+		}
+
+		sd, err := rp.xRefTable.DereferenceStreamDict(indRef)
+		if err != nil {
+			return false, err
+		}
+
+		if sd == nil || sd.Subtype() == nil || *sd.Subtype() != "Form" {
+			continue
+		}
+
+		objNr := indRef.ObjectNumber.Value()
+
+		if _, found := sd.Find("Resources"); found {
+			if !rp.forms[objNr] {
+				rp.forms[objNr] = true
+				if err = rp.pruneForm(indRef, sd); err != nil {
+					return false, err
+				}
+			}
+			continue
+		}
+
+		// Forms without resources use the resources of the invoking content stream.
+		if rp.active[objNr] {
+			continue
+		}
+
+		fb := streamContent(rp.xRefTable, indRef)
+		if fb == nil {
+			continue
+		}
+
+		rp.active[objNr] = true
+		ok, err = rp.collect(fb, resources, used)
+		delete(rp.active, objNr)
+
+		if !ok || err != nil {
+			return ok, err
+		}
+	}
+
+	return true, nil
+}
+
+// prunedResources returns a copy of resources without all unused entries and the number of entries removed.
+func (rp *resourcePruner) prunedResources(resources *PDFDict, used map[string]map[string]bool) (PDFDict, int) {
+
+	d := NewPDFDict()
+	n := 0
+
+	for k, v := range resources.Dict {
+
+		if !prunableResources[k] {
+			d.Insert(k, v)
+			continue
+		}
+
+		category, err := rp.xRefTable.DereferenceDict(v)
+		if err != nil || category == nil {
+			d.Insert(k, v)
+			continue
+		}
+
+		d1 := NewPDFDict()
+		for name, o := range category.Dict {
+			if used[k][name] || k == "ColorSpace" && defaultColorSpaces[name] {
+				d1.Insert(name, o)
+				continue
+			}
+			n++
+		}
+
+		if d1.Len() > 0 {
+			d.Insert(k, d1)
+		}
+	}
+
+	return d, n
+}
+
+func (rp *resourcePruner) pruneForm(indRef PDFIndirectRef, sd *PDFStreamDict) error {
+
+	resources, err := rp.xRefTable.DereferenceDict(sd.Dict["Resources"])
+	if err != nil || resources == nil {
+		return err
+	}
+
+	b := streamContent(rp.xRefTable, indRef)
+	if b == nil {
+		return nil
+	}
+
+	used := map[string]map[string]bool{}
+
+	ok, err := rp.collect(b, resources, used)
+	if !ok || err != nil {
+		return err
+	}
+
+	d, n := rp.prunedResources(resources, used)
+	if n > 0 {
+		log.Debug.Printf("pruneResources: form obj#%d: %d unused resources\n", indRef.ObjectNumber, n)
+		sd.Update("Resources", d)
+		rp.pruned += n
+	}
+
+	return nil
+}
+
+// collectAppearances adds the resources used by annotation appearance streams
+// without their own resources to used.
+func (rp *resourcePruner) collectAppearances(pageDict *PDFDict, resources *PDFDict, used map[string]map[string]bool) (ok bool, err error) {
+
+	annots, err := rp.xRefTable.DereferenceArray(pageDict.Dict["Annots"])
+	if err != nil || annots == nil {
+		return true, err
+	}
+
+	var streams []PDFObject
+
+	for _, o := range *annots {
+
+		annot, err := rp.xRefTable.DereferenceDict(o)
+		if err != nil || annot == nil {
+			continue
+		}
+
+		ap, err := rp.xRefTable.DereferenceDict(annot.Dict["AP"])
+		if err != nil || ap == nil {
+			continue
+		}
+
+		for _, k := range []string{"N", "R", "D"} {
+
+			o, found := ap.Find(k)
+			if !found {
+				continue
+			}
+
+			// Either a stream or a dict of streams by appearance state.
+			if d, err := rp.xRefTable.DereferenceDict(o); err == nil && d != nil {
+				for _, o := range d.Dict {
+					streams = append(streams, o)
+				}
+				continue
+			}
+
+			streams = append(streams, o)
+		}
+	}
+
+	for _, o := range streams {
+
+		sd, err := rp.xRefTable.DereferenceStreamDict(o)
+		if err != nil || sd == nil {
+			continue
+		}
+
+		if _, found := sd.Find("Resources"); found {
+			continue
+		}
+
+		if b := streamContent(rp.xRefTable, o); b != nil {
+			ok, err := rp.collect(b, resources, used)
+			if !ok || err != nil {
+				return ok, err
+			}
+		}
+	}
+
+	return true, nil
+}
+
+// prunePage sets the resources of a page to the resources used by its content.
+// o are the resources in effect for the page, possibly inherited.
+func (rp *resourcePruner) prunePage(pageDict *PDFDict, o PDFObject) error {
+
+	resources, err := rp.xRefTable.DereferenceDict(o)
+	if err != nil {
+		return err
+	}
+
+	if resources == nil {
+		return nil
+	}
+
+	// Pages own their resources from now on.
+	pageDict.Update("Resources", o)
+
+	b, err := pageContent(rp.xRefTable, pageDict)
+	if err != nil {
+		return err
+	}
+
+	used := map[string]map[string]bool{}
+
+	if b != nil {
+		ok, err := rp.collect(b, resources, used)
+		if !ok || err != nil {
+			return err
+		}
+	}
+
+	ok, err := rp.collectAppearances(pageDict, resources, used)
+	if !ok || err != nil {
+		return err
+	}
+
+	d, n := rp.prunedResources(resources, used)
+	if n > 0 {
+		pageDict.Update("Resources", d)
+		rp.pruned += n
+	}
+
+	return nil
+}
+
+// prunePageTree prunes the resources of all pages of a page tree node.
+// Since all pages end up with their own resources, inherited resources are removed.
+func (rp *resourcePruner) prunePageTree(d *PDFDict, inherited PDFObject) error {
+
+	if o, found := d.Find("Resources"); found {
+		inherited = o
+	}
+
+	o, found := d.Find("Kids")
+	if !found {
+		return rp.prunePage(d, inherited)
+	}
+
+	kids, err := rp.xRefTable.DereferenceArray(o)
+	if err != nil || kids == nil {
+		return errors.New("prunePageTree: corrupt \"Kids\" entry")
+	}
+
+	for _, o := range *kids {
+
+		kid, err := rp.xRefTable.DereferenceDict(o)
+		if err != nil {
+			return err
+		}
+
+		if kid == nil {
+			continue
+		}
+
+		err = rp.prunePageTree(kid, inherited)
+		if err != nil {
+			return err
+		}
+	}
+
+	d.Delete("Resources")
+
+	return nil
+}
+
+// pruneResources removes the font, XObject, ExtGState, ColorSpace, Pattern and Shading resources
+// not used by the content of the pages and their nested form XObjects.
+func pruneResources(ctx *PDFContext) error {
+
+	log.Debug.Println("pruneResources begin")
+
+	rootDict, err := ctx.Catalog()
+	if err != nil {
+		return err
+	}
+
+	pagesDict, err := ctx.DereferenceDict(rootDict.Dict["Pages"])
+	if err != nil || pagesDict == nil {
+		return errors.New("pruneResources: missing page tree")
+	}
+
+	rp := &resourcePruner{xRefTable: ctx.XRefTable, forms: IntSet{}, active: IntSet{}}
+
+	err = rp.prunePageTree(pagesDict, nil)
+	if err != nil {
+		return err
+	}
+
+	log.Debug.Printf("pruneResources end: %d unused resources removed\n", rp.pruned)
+
+	return nil
+}
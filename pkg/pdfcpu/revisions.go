@@ -0,0 +1,397 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+// Revision history of incrementally updated PDF files, see 7.5.6.
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hhrutter/pdfcpu/pkg/log"
+	"github.com/pkg/errors"
+)
+
+// Revision represents the original document or one of its incremental updates.
+type Revision struct {
+	Nr         int   // 1 for the original document.
+	Offset     int64 // offset of the cross reference section, -1 if missing.
+	XRefStream bool  // the cross reference section is an xref stream.
+	FileSize   int64 // the file size up to and including this revision.
+
+	// Trailer info
+	Size    int
+	Root    *PDFIndirectRef
+	Info    *PDFIndirectRef
+	Encrypt *PDFIndirectRef
+
+	Objects int   // number of cross reference entries.
+	New     []int // objects introduced by this revision.
+	Changed []int // objects of prior revisions updated by this revision.
+	Freed   []int // objects of prior revisions deleted by this revision.
+}
+
+// objNrRanges returns a compact string representation for a sorted list of object numbers.
+func objNrRanges(objNrs []int) string {
+
+	if len(objNrs) == 0 {
+		return "-"
+	}
+
+	var ss []string
+
+	for i := 0; i < len(objNrs); {
+		j := i
+		for j+1 < len(objNrs) && objNrs[j+1] == objNrs[j]+1 {
+			j++
+		}
+		if j == i {
+			ss = append(ss, strconv.Itoa(objNrs[i]))
+		} else {
+			ss = append(ss, fmt.Sprintf("%d-%d", objNrs[i], objNrs[j]))
+		}
+		i = j + 1
+	}
+
+	return strings.Join(ss, ",")
+}
+
+func (r Revision) String() string {
+
+	var sb strings.Builder
+
+	fmt.Fprintf(&sb, "revision %d: ", r.Nr)
+
+	if r.Offset < 0 {
+		sb.WriteString("no cross reference section")
+	} else {
+		kind := "xref table"
+		if r.XRefStream {
+			kind = "xref stream"
+		}
+		fmt.Fprintf(&sb, "%s at offset %d", kind, r.Offset)
+	}
+
+	fmt.Fprintf(&sb, ", %d bytes, %d entries", r.FileSize, r.Objects)
+
+	if r.Root != nil {
+		fmt.Fprintf(&sb, "\n  trailer: Size %d, Root %s", r.Size, *r.Root)
+		if r.Info != nil {
+			fmt.Fprintf(&sb, ", Info %s", *r.Info)
+		}
+		if r.Encrypt != nil {
+			fmt.Fprintf(&sb, ", Encrypt %s", *r.Encrypt)
+		}
+	}
+
+	fmt.Fprintf(&sb, "\n  new:     %s", objNrRanges(r.New))
+	fmt.Fprintf(&sb, "\n  changed: %s", objNrRanges(r.Changed))
+	fmt.Fprintf(&sb, "\n  freed:   %s", objNrRanges(r.Freed))
+
+	return sb.String()
+}
+
+// revisionEnds returns the offsets right after the end-of-file markers terminating each revision.
+// Markers not preceded by startxref, eg. as part of stream data, are ignored
+// as is the dummy startxref 0 of the first page trailer of linearized files, see F.3.4.
+func revisionEnds(rs io.ReadSeeker, fileSize int64) ([]int64, error) {
+
+	const lookBack = 64
+
+	marker := []byte("%%EOF")
+
+	_, err := rs.Seek(0, io.SeekStart)
+	if err != nil {
+		return nil, err
+	}
+
+	rd := io.LimitReader(rs, fileSize)
+	chunk := make([]byte, defaultBufSize)
+
+	var (
+		ends []int64
+		tail []byte
+		off  int64 // file offset of buf[0]
+	)
+
+	for {
+
+		n, err := io.ReadFull(rd, chunk)
+		if n == 0 {
+			break
+		}
+		if err != nil && err != io.ErrUnexpectedEOF {
+			return nil, err
+		}
+
+		buf := append(tail, chunk[:n]...)
+
+		for i := 0; ; {
+
+			j := bytes.Index(buf[i:], marker)
+			if j < 0 {
+				break
+			}
+
+			k := i + j
+			i = k + len(marker)
+
+			// Skip markers already processed as part of the last chunk.
+			if i <= len(tail) {
+				continue
+			}
+
+			l := k - lookBack
+			if l < 0 {
+				l = 0
+			}
+
+			m := bytes.LastIndex(buf[l:k], []byte("startxref"))
+			if m < 0 {
+				continue
+			}
+
+			if strings.TrimSpace(string(buf[l+m+len("startxref"):k])) != "0" {
+				ends = append(ends, off+int64(i))
+			}
+		}
+
+		t := len(buf) - (lookBack + len(marker))
+		if t < 0 {
+			t = 0
+		}
+
+		off += int64(t)
+		tail = append([]byte{}, buf[t:]...)
+	}
+
+	return ends, nil
+}
+
+// eolLength returns the length of an optional end-of-line marker at offset off.
+func eolLength(rs io.ReadSeeker, fileSize, off int64) (int64, error) {
+
+	n := fileSize - off
+	if n > 2 {
+		n = 2
+	}
+
+	if n <= 0 {
+		return 0, nil
+	}
+
+	buf := make([]byte, n)
+
+	err := readAt(rs, buf, off)
+	if err != nil {
+		return 0, err
+	}
+
+	switch {
+	case bytes.HasPrefix(buf, []byte("\r\n")):
+		return 2, nil
+	case buf[0] == '\r' || buf[0] == '\n':
+		return 1, nil
+	}
+
+	return 0, nil
+}
+
+// readXRefSection reads the cross reference section at offset into ctx and returns the offset of the previous section.
+func readXRefSection(rs io.ReadSeeker, offset int64, ctx *PDFContext) (prev *int64, stream bool, err error) {
+
+	rd, err := newPositionedReader(rs, &offset)
+	if err != nil {
+		return nil, false, err
+	}
+
+	s := bufio.NewScanner(rd)
+	s.Split(scanLines)
+
+	line, err := scanLine(s)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if line == "xref" {
+		prev, err = parseXRefSection(s, ctx)
+		return prev, false, err
+	}
+
+	rd, err = newPositionedReader(rs, &offset)
+	if err != nil {
+		return nil, false, err
+	}
+
+	prev, err = parseXRefStream(rd, &offset, ctx)
+
+	return prev, true, err
+}
+
+// Revisions returns the revision history of the PDF of fileSize bytes read from rs, the original document first.
+func Revisions(rs io.ReadSeeker, fileSize int64, config *Configuration) ([]*Revision, error) {
+
+	log.Debug.Println("Revisions begin")
+
+	if config == nil {
+		config = NewDefaultConfiguration()
+	}
+
+	ends, err := revisionEnds(rs, fileSize)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ends) == 0 {
+		return nil, errors.New("no end-of-file marker found")
+	}
+
+	revs := make([]*Revision, len(ends))
+
+	for i, end := range ends {
+
+		n, err := eolLength(rs, fileSize, end)
+		if err != nil {
+			return nil, err
+		}
+
+		revs[i] = &Revision{Nr: i + 1, Offset: -1, FileSize: end + n}
+	}
+
+	offset, err := offsetLastXRefSection(rs, fileSize)
+	if err != nil {
+		return nil, err
+	}
+
+	// Each revision gets its own xref table.
+	// Sections belonging to the same revision like the two sections of a linearized file share one.
+	ctxs := make([]*PDFContext, len(ends))
+
+	visited := map[int64]bool{}
+
+	for offset != nil && !visited[*offset] {
+
+		visited[*offset] = true
+
+		// The revision containing this section.
+		i := sort.Search(len(ends), func(i int) bool { return *offset < ends[i] })
+		if i == len(ends) {
+			i--
+		}
+
+		if ctxs[i] == nil {
+			ctxs[i], err = NewPDFContextFromReadSeeker(rs, "", fileSize, config)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		prev, stream, err := readXRefSection(rs, *offset, ctxs[i])
+		if err != nil {
+			return nil, errors.Wrapf(err, "xref section at offset %d", *offset)
+		}
+
+		// The section startxref points to represents the revision.
+		if r := revs[i]; r.Offset < 0 {
+			r.Offset = *offset
+			r.XRefStream = stream
+		}
+
+		offset = prev
+	}
+
+	entries := make([]map[int]*XRefTableEntry, len(ends))
+
+	for i, r := range revs {
+
+		entries[i] = map[int]*XRefTableEntry{}
+
+		ctx := ctxs[i]
+		if ctx == nil {
+			continue
+		}
+
+		xRefTable := ctx.XRefTable
+		if xRefTable.Size != nil {
+			r.Size = *xRefTable.Size
+		}
+		r.Root = xRefTable.Root
+		r.Info = xRefTable.Info
+		r.Encrypt = xRefTable.Encrypt
+
+		for objNr, entry := range xRefTable.Table {
+			if objNr > 0 && !ctx.Read.XRefStreams[objNr] {
+				entries[i][objNr] = entry
+			}
+		}
+	}
+
+	inUse := IntSet{}
+
+	for i, r := range revs {
+
+		r.Objects = len(entries[i])
+
+		for objNr, entry := range entries[i] {
+
+			if entry.Free {
+				if inUse[objNr] {
+					r.Freed = append(r.Freed, objNr)
+					delete(inUse, objNr)
+				}
+				continue
+			}
+
+			if inUse[objNr] {
+				r.Changed = append(r.Changed, objNr)
+			} else {
+				r.New = append(r.New, objNr)
+				inUse[objNr] = true
+			}
+		}
+
+		sort.Ints(r.New)
+		sort.Ints(r.Changed)
+		sort.Ints(r.Freed)
+	}
+
+	log.Debug.Println("Revisions end")
+
+	return revs, nil
+}
+
+// WriteRevision writes the PDF read from rs as it existed at revision r to w.
+func WriteRevision(rs io.ReadSeeker, r *Revision, w io.Writer) error {
+
+	if r.Offset < 0 {
+		return errors.Errorf("revision %d: missing cross reference section", r.Nr)
+	}
+
+	_, err := rs.Seek(0, io.SeekStart)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.CopyN(w, rs, r.FileSize)
+
+	return err
+}
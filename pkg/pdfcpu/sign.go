@@ -0,0 +1,550 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+// Functions dealing with digital signatures, see 12.8.
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/hhrutter/pdfcpu/pkg/log"
+	"github.com/hhrutter/pdfcpu/pkg/types"
+	"github.com/pkg/errors"
+)
+
+// Signature represents the details of a digital signature to be applied to a PDF file.
+type Signature struct {
+	Key   crypto.Signer       // private key of the signer.
+	Certs []*x509.Certificate // signer certificate followed by the certificate chain.
+
+	Name        string // optional name of the signer.
+	Reason      string // optional reason for signing.
+	Location    string // optional location of signing.
+	ContactInfo string // optional contact info of the signer.
+
+	PageNr     int             // page holding the signature field, defaults to 1.
+	Rect       types.Rectangle // rectangle of the signature widget, an empty rectangle results in an invisible signature.
+	Appearance *Watermark      // optional text or image to be rendered into Rect, see ParseWatermarkDetails.
+}
+
+// Visible returns true if sig comes with a visible appearance.
+func (sig Signature) Visible() bool {
+	return sig.Rect.Width() > 0 && sig.Rect.Height() > 0
+}
+
+// Signature field flags (12.7.4.5 Table 219).
+const (
+	sigFlagSignaturesExist = 1
+	sigFlagAppendOnly      = 2
+)
+
+// Annotation flags for signature widgets: Print and Locked.
+const sigWidgetFlags = 4 | 128
+
+// The /ByteRange placeholder is patched in place once the file layout is known.
+var byteRangePlaceholder = PDFArray{PDFInteger(0), PDFInteger(999999999), PDFInteger(999999999), PDFInteger(999999999)}
+
+func publicKeyMatches(cert *x509.Certificate, key crypto.Signer) bool {
+
+	switch pub := key.Public().(type) {
+
+	case *rsa.PublicKey:
+		p, ok := cert.PublicKey.(*rsa.PublicKey)
+		return ok && p.N.Cmp(pub.N) == 0 && p.E == pub.E
+
+	case *ecdsa.PublicKey:
+		p, ok := cert.PublicKey.(*ecdsa.PublicKey)
+		return ok && p.X.Cmp(pub.X) == 0 && p.Y.Cmp(pub.Y) == 0
+
+	}
+
+	return false
+}
+
+func (sig *Signature) validate(pageCount int) error {
+
+	if sig.Key == nil {
+		return errors.New("sign: missing private key")
+	}
+
+	if len(sig.Certs) == 0 {
+		return errors.New("sign: missing certificate")
+	}
+
+	if !publicKeyMatches(sig.Certs[0], sig.Key) {
+		return errors.New("sign: certificate does not match private key")
+	}
+
+	if sig.PageNr == 0 {
+		sig.PageNr = 1
+	}
+
+	if sig.PageNr < 0 || sig.PageNr > pageCount {
+		return errors.Errorf("sign: invalid page number %d", sig.PageNr)
+	}
+
+	return nil
+}
+
+// contentsSize returns the number of bytes reserved for the DER encoded signature.
+func (sig Signature) contentsSize() int {
+
+	n := 8192
+	for _, c := range sig.Certs {
+		n += len(c.Raw)
+	}
+
+	return n
+}
+
+func stringLiteral(s string) (PDFStringLiteral, error) {
+
+	e, err := Escape(s)
+	if err != nil {
+		return "", err
+	}
+
+	return PDFStringLiteral(*e), nil
+}
+
+func createSigDict(sig *Signature, t time.Time) (*PDFDict, error) {
+
+	d := PDFDict{
+		Dict: map[string]PDFObject{
+			"Type":      PDFName("Sig"),
+			"Filter":    PDFName("Adobe.PPKLite"),
+			"SubFilter": PDFName("adbe.pkcs7.detached"),
+			"ByteRange": byteRangePlaceholder,
+			"Contents":  PDFHexLiteral(strings.Repeat("0", 2*sig.contentsSize())),
+			"M":         DateStringLiteral(t),
+		},
+	}
+
+	for k, v := range map[string]string{
+		"Name":        sig.Name,
+		"Reason":      sig.Reason,
+		"Location":    sig.Location,
+		"ContactInfo": sig.ContactInfo,
+	} {
+		if v == "" {
+			continue
+		}
+		s, err := stringLiteral(v)
+		if err != nil {
+			return nil, err
+		}
+		d.Insert(k, s)
+	}
+
+	return &d, nil
+}
+
+// createSigAppearance renders the signature appearance into a form XObject fitting sig.Rect.
+func createSigAppearance(xRefTable *XRefTable, sig *Signature) (*PDFIndirectRef, error) {
+
+	wm := sig.Appearance
+	if wm == nil {
+		var err error
+		wm, err = ParseWatermarkDetails("Digitally signed by "+sig.Certs[0].Subject.CommonName, true)
+		if err != nil {
+			return nil, err
+		}
+		wm.color = simpleColor{}
+		wm.scale = 1
+	}
+
+	r := sig.Rect
+	w, h := r.Width(), r.Height()
+
+	wm.vp = types.NewRectangle(0, 0, w, h)
+
+	err := createResourcesForWM(xRefTable, wm)
+	if err != nil {
+		return nil, err
+	}
+
+	err = createForm(xRefTable, wm, false)
+	if err != nil {
+		return nil, err
+	}
+
+	// Center the form within the widget and preserve its aspect ratio.
+	bb := wm.bb
+	s := math.Min(w/bb.Width(), h/bb.Height())
+	dx := (w-s*bb.Width())/2 - s*bb.LL.X
+	dy := (h-s*bb.Height())/2 - s*bb.LL.Y
+
+	sd := &PDFStreamDict{
+		PDFDict: PDFDict{
+			Dict: map[string]PDFObject{
+				"Type":      PDFName("XObject"),
+				"Subtype":   PDFName("Form"),
+				"BBox":      NewRectangle(0, 0, w, h),
+				"Resources": PDFDict{Dict: map[string]PDFObject{"XObject": PDFDict{Dict: map[string]PDFObject{"FRM0": *wm.form}}}},
+			},
+		},
+		Content: []byte(fmt.Sprintf("q %f 0 0 %f %f %f cm /FRM0 Do Q", s, s, dx, dy)),
+	}
+
+	err = encodeStream(sd)
+	if err != nil {
+		return nil, err
+	}
+
+	return xRefTable.IndRefForNewObject(*sd)
+}
+
+// appendToArrayEntry appends o to the array entry key of d which lives in object objNr.
+// All objects modified are marked dirty.
+func appendToArrayEntry(xRefTable *XRefTable, d *PDFDict, objNr int, key string, o PDFObject) error {
+
+	obj, found := d.Find(key)
+	if !found || obj == nil {
+		d.Update(key, PDFArray{o})
+		xRefTable.SetDirty(objNr)
+		return nil
+	}
+
+	indRef, ok := obj.(PDFIndirectRef)
+	if !ok {
+		arr, ok := obj.(PDFArray)
+		if !ok {
+			return errors.Errorf("sign: corrupt entry \"%s\"", key)
+		}
+		d.Update(key, append(arr, o))
+		xRefTable.SetDirty(objNr)
+		return nil
+	}
+
+	entry, found := xRefTable.FindTableEntryForIndRef(&indRef)
+	if !found {
+		return errors.Errorf("sign: missing obj #%d", indRef.ObjectNumber)
+	}
+
+	arr, ok := entry.Object.(PDFArray)
+	if !ok {
+		return errors.Errorf("sign: corrupt entry \"%s\"", key)
+	}
+
+	entry.Object = append(arr, o)
+	xRefTable.SetDirty(indRef.ObjectNumber.Value())
+
+	return nil
+}
+
+// acroFormDict returns the interactive form dict and the number of the object it lives in.
+func acroFormDict(xRefTable *XRefTable) (*PDFDict, int, error) {
+
+	rootDict, err := xRefTable.Catalog()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	rootObjNr := xRefTable.Root.ObjectNumber.Value()
+
+	obj, found := rootDict.Find("AcroForm")
+	if !found || obj == nil {
+		d := NewPDFDict()
+		indRef, err := xRefTable.IndRefForNewObject(d)
+		if err != nil {
+			return nil, 0, err
+		}
+		rootDict.Update("AcroForm", *indRef)
+		xRefTable.SetDirty(rootObjNr)
+		return &d, indRef.ObjectNumber.Value(), nil
+	}
+
+	d, err := xRefTable.DereferenceDict(obj)
+	if err != nil || d == nil {
+		return nil, 0, errors.New("sign: corrupt entry \"AcroForm\"")
+	}
+
+	if indRef, ok := obj.(PDFIndirectRef); ok {
+		return d, indRef.ObjectNumber.Value(), nil
+	}
+
+	return d, rootObjNr, nil
+}
+
+// sigFieldName returns a field name not used by any other top level form field.
+func sigFieldName(xRefTable *XRefTable, d *PDFDict) (string, error) {
+
+	names := StringSet{}
+
+	fields := d.PDFArrayEntry("Fields")
+	if obj, ok := d.Find("Fields"); ok && fields == nil {
+		var err error
+		if fields, err = xRefTable.DereferenceArray(obj); err != nil {
+			return "", err
+		}
+	}
+
+	if fields != nil {
+		for _, o := range *fields {
+			fd, err := xRefTable.DereferenceDict(o)
+			if err != nil {
+				return "", err
+			}
+			if fd == nil {
+				continue
+			}
+			if t := fd.StringEntry("T"); t != nil {
+				names[*t] = true
+			}
+		}
+	}
+
+	for i := 1; ; i++ {
+		s := fmt.Sprintf("Signature%d", i)
+		if !names[s] {
+			return s, nil
+		}
+	}
+}
+
+// addSigField creates a signature field along with its widget annotation on page sig.PageNr.
+func addSigField(xRefTable *XRefTable, sig *Signature, sigIndRef PDFIndirectRef) error {
+
+	pageIndRef, err := xRefTable.PageDictIndRef(sig.PageNr)
+	if err != nil {
+		return err
+	}
+
+	pageDict, err := xRefTable.DereferenceDict(*pageIndRef)
+	if err != nil {
+		return err
+	}
+
+	formDict, formObjNr, err := acroFormDict(xRefTable)
+	if err != nil {
+		return err
+	}
+
+	name, err := sigFieldName(xRefTable, formDict)
+	if err != nil {
+		return err
+	}
+
+	r := sig.Rect
+	if !sig.Visible() {
+		r = types.NewRectangle(0, 0, 0, 0)
+	}
+
+	d := PDFDict{
+		Dict: map[string]PDFObject{
+			"Type":    PDFName("Annot"),
+			"Subtype": PDFName("Widget"),
+			"FT":      PDFName("Sig"),
+			"T":       PDFStringLiteral(name),
+			"V":       sigIndRef,
+			"F":       PDFInteger(sigWidgetFlags),
+			"P":       *pageIndRef,
+			"Rect":    NewRectangle(r.LL.X, r.LL.Y, r.UR.X, r.UR.Y),
+		},
+	}
+
+	if sig.Visible() {
+		ap, err := createSigAppearance(xRefTable, sig)
+		if err != nil {
+			return err
+		}
+		d.Insert("AP", PDFDict{Dict: map[string]PDFObject{"N": *ap}})
+	}
+
+	fieldIndRef, err := xRefTable.IndRefForNewObject(d)
+	if err != nil {
+		return err
+	}
+
+	err = appendToArrayEntry(xRefTable, pageDict, pageIndRef.ObjectNumber.Value(), "Annots", *fieldIndRef)
+	if err != nil {
+		return err
+	}
+
+	err = appendToArrayEntry(xRefTable, formDict, formObjNr, "Fields", *fieldIndRef)
+	if err != nil {
+		return err
+	}
+
+	formDict.Update("SigFlags", PDFInteger(sigFlagSignaturesExist|sigFlagAppendOnly))
+	xRefTable.SetDirty(formObjNr)
+
+	return nil
+}
+
+// patchSignature fills in /ByteRange and /Contents of the signature dict written at offset off.
+func patchSignature(b []byte, off int64, sig *Signature, t time.Time) error {
+
+	br := []byte(byteRangePlaceholder.PDFString())
+	i := bytes.Index(b[off:], br)
+	if i < 0 {
+		return errors.New("sign: missing ByteRange placeholder")
+	}
+	i += int(off)
+
+	cs := []byte(PDFHexLiteral(strings.Repeat("0", 2*sig.contentsSize())).PDFString())
+	j := bytes.Index(b[off:], cs)
+	if j < 0 {
+		return errors.New("sign: missing Contents placeholder")
+	}
+	j += int(off)
+
+	// The signed byte ranges exclude the hex string for the signature including its delimiters.
+	k := j + len(cs)
+
+	s := fmt.Sprintf("[0 %d %d %d]", j, k, len(b)-k)
+	if len(s) > len(br) {
+		return errors.New("sign: file too large")
+	}
+	copy(b[i:], s+strings.Repeat(" ", len(br)-len(s)))
+
+	h := sha256.New()
+	h.Write(b[:j])
+	h.Write(b[k:])
+
+	der, err := signDetached(h.Sum(nil), sig.Certs, sig.Key, t)
+	if err != nil {
+		return err
+	}
+
+	if len(der) > sig.contentsSize() {
+		return errors.Errorf("sign: signature too large (%d bytes)", len(der))
+	}
+
+	copy(b[j+1:], hex.EncodeToString(der))
+
+	return nil
+}
+
+// Sign signs the PDF contained in ctx and writes the result to w.
+// The signature is added by an incremental update leaving the original revision untouched.
+// ctx is expected to be read from a PDF file and must not have been optimized.
+func Sign(ctx *PDFContext, sig *Signature, w io.Writer) error {
+
+	log.Debug.Println("Sign begin")
+
+	b, err := signedPDF(ctx, sig)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(b)
+	if err != nil {
+		return err
+	}
+
+	log.Debug.Println("Sign end")
+
+	return nil
+}
+
+// SignFile signs the PDF contained in ctx and writes the result to fileName.
+// If fileName is the file read, the signature gets appended in place.
+func SignFile(ctx *PDFContext, sig *Signature, fileName string) (err error) {
+
+	log.Debug.Println("SignFile begin")
+
+	// Sign before touching fileName which may be the original file.
+	b, err := signedPDF(ctx, sig)
+	if err != nil {
+		return err
+	}
+
+	file, inPlace, err := openIncrementFile(ctx, fileName)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+
+		// Processing error takes precedence.
+		if err != nil {
+			file.Close()
+			return
+		}
+
+		// Do not miss out on closing errors.
+		err = file.Close()
+
+	}()
+
+	if inPlace {
+		b = b[ctx.Read.FileSize:]
+	}
+
+	_, err = file.Write(b)
+	if err != nil {
+		return err
+	}
+
+	log.Debug.Println("SignFile end")
+
+	return nil
+}
+
+// signedPDF returns the PDF contained in ctx followed by an incremental update adding the signature sig.
+func signedPDF(ctx *PDFContext, sig *Signature) ([]byte, error) {
+
+	err := sig.validate(ctx.PageCount)
+	if err != nil {
+		return nil, err
+	}
+
+	t := time.Now()
+
+	d, err := createSigDict(sig, t)
+	if err != nil {
+		return nil, err
+	}
+
+	sigIndRef, err := ctx.IndRefForNewObject(*d)
+	if err != nil {
+		return nil, err
+	}
+
+	err = addSigField(ctx.XRefTable, sig, *sigIndRef)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+
+	err = writeIncrement(ctx, &buf)
+	if err != nil {
+		return nil, err
+	}
+
+	b := buf.Bytes()
+
+	err = patchSignature(b, ctx.Write.Table[sigIndRef.ObjectNumber.Value()], sig, t)
+	if err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
@@ -73,7 +73,8 @@ type Watermark struct {
 	text          string      // display text
 	imageFileName string      // display png image
 	onTop         bool        // if true this is a STAMP else this is a WATERMARK.
-	fontName      string      // supported are Adobe base fonts only. (as of now: Helvetica, Times-Roman, Courier)
+	fontName      string      // One of the standard 14 fonts or the name of a user font.
+	userFont      *userFont   // TrueType font loaded from a file.
 	fontSize      int         // font scaling factor.
 	color         simpleColor // fill color(=non stroking color).
 	rotation      float64     // rotation to apply in degrees. -180 <= x <= 180
@@ -190,10 +191,10 @@ func (wm *Watermark) calcBoundingBox() {
 	var w float64
 	if wm.scaleAbs {
 		wm.fontSize = int(float64(wm.fontSize) * wm.scale)
-		w = metrics.TextWidth(wm.text, wm.fontName, wm.fontSize)
+		w = wm.textWidth()
 	} else {
 		w = wm.scale * wm.vp.Width()
-		wm.fontSize = metrics.FontSize(wm.text, wm.fontName, w)
+		wm.fontSize = wm.fontSizeForWidth(w)
 	}
 	bb = types.NewRectangle(0, -float64(wm.fontSize), w, float64(wm.fontSize)/10)
 
@@ -201,6 +202,20 @@ func (wm *Watermark) calcBoundingBox() {
 	return
 }
 
+func (wm *Watermark) textWidth() float64 {
+	if wm.userFont != nil {
+		return wm.userFont.textWidth(wm.text, wm.fontSize)
+	}
+	return metrics.TextWidth(wm.text, wm.fontName, wm.fontSize)
+}
+
+func (wm *Watermark) fontSizeForWidth(w float64) int {
+	if wm.userFont != nil {
+		return wm.userFont.fontSize(wm.text, w)
+	}
+	return metrics.FontSize(wm.text, wm.fontName, w)
+}
+
 func (wm *Watermark) calcTransformMatrix() *matrix {
 
 	var sin, cos float64
@@ -279,6 +294,27 @@ func supportedWatermarkFont(fn string) bool {
 	return false
 }
 
+func parseWatermarkFont(v string, wm *Watermark) error {
+
+	if isUserFontFile(v) {
+		f, err := loadUserFont(v)
+		if err != nil {
+			return err
+		}
+		wm.userFont = f
+		wm.fontName = f.name
+		return nil
+	}
+
+	if !supportedWatermarkFont(v) {
+		return errors.Errorf("%s is unsupported, try one of the standard 14 fonts like Helvetica, Times-Roman, Courier or a TrueType font file.\n", v)
+	}
+
+	wm.fontName = v
+
+	return nil
+}
+
 func parseWatermarkFontSize(v string, wm *Watermark) error {
 
 	fs, err := strconv.Atoi(v)
@@ -462,7 +498,8 @@ func ParseWatermarkDetails(s string, onTop bool) (*Watermark, error) {
 
 	for _, s := range ss[1:] {
 
-		ss1 := strings.Split(s, ":")
+		// Font file names may contain a drive letter.
+		ss1 := strings.SplitN(s, ":", 2)
 		if len(ss1) != 2 {
 			return nil, parseWatermarkError(onTop)
 		}
@@ -474,11 +511,8 @@ func ParseWatermarkDetails(s string, onTop bool) (*Watermark, error) {
 		var err error
 
 		switch k {
-		case "f": // font name
-			if !supportedWatermarkFont(v) {
-				err = errors.Errorf("%s is unsupported, try one of Helvetica, Times-Roman, Courier.\n", v)
-			}
-			wm.fontName = v
+		case "f": // font name or TrueType font file
+			err = parseWatermarkFont(v, wm)
 
 		case "p": // font size in points
 			err = parseWatermarkFontSize(v, wm)
@@ -512,11 +546,26 @@ func ParseWatermarkDetails(s string, onTop bool) (*Watermark, error) {
 		}
 	}
 
+	if wm.userFont != nil && !wm.IsImage() {
+		if err := wm.userFont.checkGlyphs(wm.text); err != nil {
+			return nil, err
+		}
+	}
+
 	return wm, nil
 }
 
 func createFontResForWM(xRefTable *XRefTable, wm *Watermark) error {
 
+	if wm.userFont != nil {
+		indRef, err := wm.userFont.fontDict(xRefTable, wm.text)
+		if err != nil {
+			return err
+		}
+		wm.font = indRef
+		return nil
+	}
+
 	d := NewPDFDict()
 	d.InsertName("Type", "Font")
 	d.InsertName("Subtype", "Type1")
@@ -585,6 +634,7 @@ func AddWatermarks(xRefTable *XRefTable, selectedPages IntSet, wm *Watermark) er
 	if err != nil {
 		return err
 	}
+	xRefTable.SetDirty(xRefTable.Root.ObjectNumber.Value())
 
 	err = createResourcesForWM(xRefTable, wm)
 	if err != nil {
@@ -708,6 +758,29 @@ func createFormResDict(xRefTable *XRefTable, wm *Watermark) *PDFDict {
 		}}
 }
 
+// kernedText returns a TJ array for text applying the kerning pairs of a standard font.
+func kernedText(text, fontName string) string {
+
+	var b bytes.Buffer
+
+	b.WriteString("[(")
+
+	prev := -1
+	for _, r := range text {
+		if prev >= 0 {
+			if k := metrics.Kerning(fontName, prev, int(r)); k != 0 {
+				fmt.Fprintf(&b, ")%d(", -k)
+			}
+		}
+		b.WriteRune(r)
+		prev = int(r)
+	}
+
+	b.WriteString(")]")
+
+	return b.String()
+}
+
 func createForm(xRefTable *XRefTable, wm *Watermark, withBB bool) error {
 
 	wm.calcBoundingBox()
@@ -729,8 +802,13 @@ func createForm(xRefTable *XRefTable, wm *Watermark, withBB bool) error {
 	} else {
 		// 12 font points result in a vertical displacement of 9.47
 		dy := -float64(wm.fontSize) / 12 * 9.47
-		wmForm := "0 g 0 G 0 i 0 J []0 d 0 j 1 w 10 M 0 Tc 0 Tw 100 Tz 0 TL %d Tr 0 Ts BT /%s %d Tf %f %f %f rg 0 %f Td (%s)Tj ET"
-		fmt.Fprintf(&b, wmForm, wm.renderMode, wm.fontName, wm.fontSize, wm.color.r, wm.color.g, wm.color.b, dy, wm.text)
+		t := kernedText(wm.text, wm.fontName) + "TJ"
+		if wm.userFont != nil {
+			dy = -wm.userFont.baseline(wm.fontSize)
+			t = wm.userFont.encode(wm.text) + "Tj"
+		}
+		wmForm := "0 g 0 G 0 i 0 J []0 d 0 j 1 w 10 M 0 Tc 0 Tw 100 Tz 0 TL %d Tr 0 Ts BT /%s %d Tf %f %f %f rg 0 %f Td %s ET"
+		fmt.Fprintf(&b, wmForm, wm.renderMode, wm.fontName, wm.fontSize, wm.color.r, wm.color.g, wm.color.b, dy, t)
 	}
 
 	// Paint bounding box
@@ -750,13 +828,17 @@ func createForm(xRefTable *XRefTable, wm *Watermark, withBB bool) error {
 				"Subtype":   PDFName("Form"),
 				"BBox":      NewRectangle(bb.LL.X, bb.LL.Y, bb.UR.X, bb.UR.Y),
 				"Matrix":    NewIntegerArray(1, 0, 0, 1, 0, 0),
-				"OC":        *wm.ocg,
 				"Resources": *createFormResDict(xRefTable, wm),
 			},
 		},
 		Content: b.Bytes(),
 	}
 
+	// Signature appearances are not subject to optional content.
+	if wm.ocg != nil {
+		sd.Insert("OC", *wm.ocg)
+	}
+
 	err := encodeStream(sd)
 	if err != nil {
 		return err
@@ -817,7 +899,7 @@ func insertPageResourcesForWM(xRefTable *XRefTable, pageDict *PDFDict, wm *Water
 	return nil
 }
 
-func updatePageResourcesForWM(xRefTable *XRefTable, resDict *PDFDict, wm *Watermark, gsID, xoID *string) error {
+func updatePageResourcesForWM(xRefTable *XRefTable, resDict *PDFDict, resObjNr int, wm *Watermark, gsID, xoID *string) error {
 
 	o, ok := resDict.Find("ExtGState")
 	if !ok {
@@ -832,6 +914,7 @@ func updatePageResourcesForWM(xRefTable *XRefTable, resDict *PDFDict, wm *Waterm
 		}
 		d.Insert(*gsID, *wm.extGState)
 	}
+	xRefTable.SetDirtyEntry(resDict, "ExtGState", resObjNr)
 	//println("extGState done")
 
 	o, ok = resDict.Find("XObject")
@@ -847,6 +930,7 @@ func updatePageResourcesForWM(xRefTable *XRefTable, resDict *PDFDict, wm *Waterm
 		}
 		d.Insert(*xoID, *wm.form)
 	}
+	xRefTable.SetDirtyEntry(resDict, "XObject", resObjNr)
 	//println("xObject done")
 
 	return nil
@@ -918,6 +1002,7 @@ func updatePageContentsForWM(xRefTable *XRefTable, obj PDFObject, wm *Watermark,
 
 		entry.Object = o
 		wm.objs[objNr] = true
+		xRefTable.SetDirty(objNr)
 
 	case PDFArray:
 
@@ -945,6 +1030,7 @@ func updatePageContentsForWM(xRefTable *XRefTable, obj PDFObject, wm *Watermark,
 
 		entry.Object = sd
 		wm.objs[objNr] = true
+		xRefTable.SetDirty(objNr)
 	}
 
 	return nil
@@ -959,6 +1045,12 @@ func watermarkPage(xRefTable *XRefTable, i int, wm *Watermark) error {
 		return err
 	}
 
+	pageIndRef, err := xRefTable.PageDictIndRef(i)
+	if err != nil {
+		return err
+	}
+	pageObjNr := pageIndRef.ObjectNumber.Value()
+
 	visibleRegion := inhPAttrs.mediaBox
 	if inhPAttrs.cropBox != nil {
 		visibleRegion = inhPAttrs.cropBox
@@ -993,8 +1085,9 @@ func watermarkPage(xRefTable *XRefTable, i int, wm *Watermark) error {
 
 	if inhPAttrs.resources == nil {
 		err = insertPageResourcesForWM(xRefTable, d, wm, gsID, xoID)
+		xRefTable.SetDirty(pageObjNr)
 	} else {
-		err = updatePageResourcesForWM(xRefTable, inhPAttrs.resources, wm, &gsID, &xoID)
+		err = updatePageResourcesForWM(xRefTable, inhPAttrs.resources, inhPAttrs.resourcesObjNr, wm, &gsID, &xoID)
 	}
 	if err != nil {
 		return err
@@ -1002,6 +1095,7 @@ func watermarkPage(xRefTable *XRefTable, i int, wm *Watermark) error {
 
 	obj, found := d.Find("Contents")
 	if !found {
+		xRefTable.SetDirty(pageObjNr)
 		return insertPageContentsForWM(xRefTable, d, wm, gsID, xoID)
 	}
 
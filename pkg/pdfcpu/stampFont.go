@@ -0,0 +1,310 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/hhrutter/pdfcpu/pkg/filter"
+	"github.com/pkg/errors"
+)
+
+// userFont represents a TrueType font loaded from a file for rendering watermark text.
+type userFont struct {
+	fileName   string
+	name       string // the PostScript name.
+	tt         *trueTypeFont
+	cmap       []byte       // the Unicode cmap subtable.
+	unitsPerEm int          // glyph space units per em.
+	cids       map[rune]int // the CIDs of the embedded subset.
+}
+
+// isUserFontFile returns true if fileName refers to a TrueType or OpenType font file.
+// Only OpenType fonts with TrueType outlines can be loaded.
+func isUserFontFile(fileName string) bool {
+	ext := strings.ToLower(filepath.Ext(fileName))
+	return ext == ".ttf" || ext == ".otf"
+}
+
+// userFontName returns s reduced to the characters allowed in a PDF name.
+func userFontName(s string) string {
+
+	var sb strings.Builder
+
+	for _, c := range s {
+		if c > 0x20 && c < 0x7F && !strings.ContainsRune("()<>[]{}/%#", c) {
+			sb.WriteRune(c)
+		}
+	}
+
+	return sb.String()
+}
+
+func loadUserFont(fileName string) (*userFont, error) {
+
+	b, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	// OpenType fonts with CFF outlines (sfnt version OTTO) are rejected here.
+	tt, err := parseTrueType(b)
+	if err != nil {
+		return nil, errors.Wrapf(err, "%s", fileName)
+	}
+
+	f := &userFont{fileName: fileName, tt: tt, unitsPerEm: tt.u16("head", 18)}
+
+	if f.unitsPerEm == 0 {
+		f.unitsPerEm = 1000
+	}
+
+	// Prefer the full Unicode range over the BMP.
+	for _, id := range [][2]int{{3, 10}, {0, 4}, {3, 1}, {0, 3}} {
+		if f.cmap = tt.cmapSubtable(id[0], id[1]); f.cmap != nil {
+			break
+		}
+	}
+
+	if f.cmap == nil {
+		return nil, errors.Errorf("%s: missing Unicode cmap", fileName)
+	}
+
+	f.name = userFontName(tt.postScriptName())
+	if f.name == "" {
+		f.name = userFontName(strings.TrimSuffix(filepath.Base(fileName), filepath.Ext(fileName)))
+	}
+
+	return f, nil
+}
+
+func (f *userFont) gid(r rune) int {
+	return cmapLookup(f.cmap, int(r))
+}
+
+// checkGlyphs returns an error if text contains a character missing in the font.
+func (f *userFont) checkGlyphs(text string) error {
+
+	for _, r := range text {
+		if f.gid(r) == 0 {
+			return errors.Errorf("%s: no glyph for %q", f.fileName, r)
+		}
+	}
+
+	return nil
+}
+
+// glyphWidth returns the advance width of a glyph in glyph space units.
+func (f *userFont) glyphWidth(gid int) int {
+	advance, _ := f.tt.hMetric(gid)
+	return int(binary.BigEndian.Uint16(advance)) * 1000 / f.unitsPerEm
+}
+
+// metric returns a value of a font table scaled to glyph space units.
+func (f *userFont) metric(tag string, off int) int {
+	return int(int16(f.tt.u16(tag, off))) * 1000 / f.unitsPerEm
+}
+
+// textWidth returns the width in user space units for text using font size.
+func (f *userFont) textWidth(text string, fontSize int) float64 {
+
+	var width float64
+	for _, r := range text {
+		width += float64(f.glyphWidth(f.gid(r))) / 1000 * float64(fontSize)
+	}
+
+	return width
+}
+
+// fontSize returns the font size needed for rendering text with a given user space width.
+func (f *userFont) fontSize(text string, width float64) int {
+
+	var i int
+	for _, r := range text {
+		i += f.glyphWidth(f.gid(r))
+	}
+
+	if i == 0 {
+		return 0
+	}
+
+	return int(width / float64(i) * 1000)
+}
+
+// baseline returns the distance from the top of the text box to the baseline for font size.
+func (f *userFont) baseline(fontSize int) float64 {
+
+	ascent, descent := f.metric("hhea", 4), f.metric("hhea", 6)
+	if ascent-descent <= 0 {
+		return float64(fontSize) / 12 * 9.47
+	}
+
+	return float64(fontSize*ascent) / float64(ascent-descent)
+}
+
+// encode returns text as a hex string of 2 byte CIDs of the embedded subset.
+func (f *userFont) encode(text string) string {
+
+	var b bytes.Buffer
+
+	b.WriteByte('<')
+	for _, r := range text {
+		fmt.Fprintf(&b, "%04X", f.cids[r])
+	}
+	b.WriteByte('>')
+
+	return b.String()
+}
+
+func (f *userFont) fontDescriptor(xRefTable *XRefTable, baseFont string, fontFile *PDFIndirectRef) (*PDFIndirectRef, error) {
+
+	ascent := f.metric("hhea", 4)
+
+	capHeight := ascent
+	if os2 := f.tt.tables["OS/2"]; len(os2) >= 90 && f.tt.u16("OS/2", 0) >= 2 {
+		capHeight = f.metric("OS/2", 88)
+	}
+
+	var italicAngle float64
+	if post := f.tt.tables["post"]; len(post) >= 8 {
+		italicAngle = float64(int32(binary.BigEndian.Uint32(post[4:]))) / 65536
+	}
+
+	// Nonsymbolic
+	flags := 32
+	if f.tt.u16("post", 12) != 0 || f.tt.u16("post", 14) != 0 {
+		// FixedPitch
+		flags |= 1
+	}
+	if italicAngle != 0 {
+		// Italic
+		flags |= 64
+	}
+
+	d := PDFDict{
+		Dict: map[string]PDFObject{
+			"Type":        PDFName("FontDescriptor"),
+			"FontName":    PDFName(baseFont),
+			"Flags":       PDFInteger(flags),
+			"FontBBox":    NewIntegerArray(f.metric("head", 36), f.metric("head", 38), f.metric("head", 40), f.metric("head", 42)),
+			"ItalicAngle": PDFFloat(italicAngle),
+			"Ascent":      PDFInteger(ascent),
+			"Descent":     PDFInteger(f.metric("hhea", 6)),
+			"CapHeight":   PDFInteger(capHeight),
+			"StemV":       PDFInteger(80),
+			"FontFile2":   *fontFile,
+		},
+	}
+
+	return xRefTable.IndRefForNewObject(d)
+}
+
+// fontDict embeds the subset of the font needed for text and returns the corresponding Type0 font dict.
+func (f *userFont) fontDict(xRefTable *XRefTable, text string) (*PDFIndirectRef, error) {
+
+	gids := IntSet{}
+	for _, r := range text {
+		gids[f.gid(r)] = true
+	}
+
+	// The CIDs of the embedded font are the glyph indices of the subset.
+	b, newGIDs := f.tt.subset(gids, true)
+
+	f.cids = map[rune]int{}
+	text1 := map[string]string{}
+	for _, r := range text {
+		cid := newGIDs[f.gid(r)]
+		f.cids[r] = cid
+		text1[string([]byte{byte(cid >> 8), byte(cid)})] = string(r)
+	}
+
+	widths := make(PDFArray, len(newGIDs))
+	for gid, cid := range newGIDs {
+		widths[cid] = PDFInteger(f.glyphWidth(gid))
+	}
+
+	baseFont := subsetTag(0, gids) + "+" + f.name
+
+	sd := &PDFStreamDict{
+		PDFDict:        NewPDFDict(),
+		Content:        b,
+		FilterPipeline: []PDFFilter{{Name: filter.Flate, DecodeParms: nil}}}
+
+	sd.InsertName("Filter", filter.Flate)
+	sd.InsertInt("Length1", len(b))
+
+	err := encodeStream(sd)
+	if err != nil {
+		return nil, err
+	}
+
+	fontFile, err := xRefTable.IndRefForNewObject(*sd)
+	if err != nil {
+		return nil, err
+	}
+
+	fd, err := f.fontDescriptor(xRefTable, baseFont, fontFile)
+	if err != nil {
+		return nil, err
+	}
+
+	cidFont := PDFDict{
+		Dict: map[string]PDFObject{
+			"Type":     PDFName("Font"),
+			"Subtype":  PDFName("CIDFontType2"),
+			"BaseFont": PDFName(baseFont),
+			"CIDSystemInfo": PDFDict{
+				Dict: map[string]PDFObject{
+					"Registry":   PDFStringLiteral("Adobe"),
+					"Ordering":   PDFStringLiteral("Identity"),
+					"Supplement": PDFInteger(0),
+				},
+			},
+			"FontDescriptor": *fd,
+			"W":              PDFArray{PDFInteger(0), widths},
+			"CIDToGIDMap":    PDFName("Identity"),
+		},
+	}
+
+	cidFontRef, err := xRefTable.IndRefForNewObject(cidFont)
+	if err != nil {
+		return nil, err
+	}
+
+	toUnicode, err := newFlateStream(xRefTable, newToUnicodeCMap([]cmapRange{{lo: []byte{0x00, 0x00}, hi: []byte{0xFF, 0xFF}}}, text1))
+	if err != nil {
+		return nil, err
+	}
+
+	d := PDFDict{
+		Dict: map[string]PDFObject{
+			"Type":            PDFName("Font"),
+			"Subtype":         PDFName("Type0"),
+			"BaseFont":        PDFName(baseFont),
+			"Encoding":        PDFName("Identity-H"),
+			"DescendantFonts": PDFArray{*cidFontRef},
+			"ToUnicode":       *toUnicode,
+		},
+	}
+
+	return xRefTable.IndRefForNewObject(d)
+}
@@ -0,0 +1,174 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testUserFont returns the font of testTrueType mapping A, B and C to glyphs 1, 2 and 3.
+func testUserFont(t *testing.T) []byte {
+
+	tt, err := parseTrueType(testTrueType())
+	if err != nil {
+		t.Fatalf("testUserFont: %v\n", err)
+	}
+
+	head := append([]byte(nil), tt.tables["head"]...)
+	binary.BigEndian.PutUint16(head[18:], 1000)
+	tt.tables["head"] = head
+
+	// cmap format 4 with segments 0x41-0x43 and 0xFFFF.
+	u16s := []uint16{
+		0, 1, 3, 1, 0, 12,
+		4, 32, 0, 4, 4, 1, 0,
+		0x43, 0xFFFF, 0,
+		0x41, 0xFFFF,
+		1 - 0x41 + 0x10000, 1,
+		0, 0,
+	}
+	cmap := make([]byte, 2*len(u16s))
+	for i, v := range u16s {
+		binary.BigEndian.PutUint16(cmap[2*i:], v)
+	}
+	tt.tables["cmap"] = cmap
+
+	ps := []byte{0, 'T', 0, 'e', 0, 's', 0, 't', 0, '-', 0, 'F', 0, 'o', 0, 'n', 0, 't'}
+	name := make([]byte, 18)
+	for i, v := range []uint16{0, 1, 18, 3, 1, 0x409, 6, uint16(len(ps)), 0} {
+		binary.BigEndian.PutUint16(name[2*i:], v)
+	}
+	tt.tables["name"] = append(name, ps...)
+
+	return writeTrueType(tt.tables)
+}
+
+func TestWatermarkUserFont(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "userFont")
+	if err != nil {
+		t.Fatalf("TestWatermarkUserFont: %v\n", err)
+	}
+	defer os.RemoveAll(dir)
+
+	fileName := filepath.Join(dir, "test.ttf")
+
+	err = ioutil.WriteFile(fileName, testUserFont(t), os.ModePerm)
+	if err != nil {
+		t.Fatalf("TestWatermarkUserFont: %v\n", err)
+	}
+
+	if _, err = ParseWatermarkDetails("CD, f:"+fileName, true); err == nil {
+		t.Fatalf("TestWatermarkUserFont: missing glyph not detected\n")
+	}
+
+	wm, err := ParseWatermarkDetails("C, f:"+fileName, true)
+	if err != nil {
+		t.Fatalf("TestWatermarkUserFont: %v\n", err)
+	}
+
+	if wm.fontName != "Test-Font" {
+		t.Fatalf("TestWatermarkUserFont: unexpected font name %s\n", wm.fontName)
+	}
+
+	if w := wm.userFont.textWidth("CA", 10); w != 2.04 {
+		t.Fatalf("TestWatermarkUserFont: unexpected text width %f\n", w)
+	}
+
+	xRefTable, err := CreateDemoXRef()
+	if err != nil {
+		t.Fatalf("TestWatermarkUserFont: %v\n", err)
+	}
+
+	err = AddWatermarks(xRefTable, IntSet{1: true}, wm)
+	if err != nil {
+		t.Fatalf("TestWatermarkUserFont: %v\n", err)
+	}
+
+	// Glyph 3 is a composite of glyph 2 and becomes CID 2 of the subset.
+	sd, err := xRefTable.DereferenceStreamDict(*wm.form)
+	if err != nil {
+		t.Fatalf("TestWatermarkUserFont: %v\n", err)
+	}
+
+	if err = decodeStream(sd); err != nil || !bytes.Contains(sd.Content, []byte("<0002>Tj")) {
+		t.Fatalf("TestWatermarkUserFont: unexpected content %s\n", sd.Content)
+	}
+
+	d, err := xRefTable.DereferenceDict(*wm.font)
+	if err != nil || *d.Subtype() != "Type0" {
+		t.Fatalf("TestWatermarkUserFont: unexpected font dict %v\n", d)
+	}
+
+	sd, err = xRefTable.DereferenceStreamDict(d.Dict["ToUnicode"])
+	if err != nil {
+		t.Fatalf("TestWatermarkUserFont: %v\n", err)
+	}
+
+	if err = decodeStream(sd); err != nil || !bytes.Contains(sd.Content, []byte("<0002> <0043>")) {
+		t.Fatalf("TestWatermarkUserFont: unexpected ToUnicode CMap %s\n", sd.Content)
+	}
+
+	a, _ := xRefTable.DereferenceArray(d.Dict["DescendantFonts"])
+	cidFont, _ := xRefTable.DereferenceDict((*a)[0])
+	fd, _ := xRefTable.DereferenceDict(cidFont.Dict["FontDescriptor"])
+
+	sd, err = xRefTable.DereferenceStreamDict(fd.Dict["FontFile2"])
+	if err != nil || decodeStream(sd) != nil {
+		t.Fatalf("TestWatermarkUserFont: corrupt font file\n")
+	}
+
+	tt, err := parseTrueType(sd.Content)
+	if err != nil || tt.numGlyphs != 3 {
+		t.Fatalf("TestWatermarkUserFont: unexpected subset %v\n", err)
+	}
+
+	if w := cidFont.PDFArrayEntry("W"); w == nil || (*w)[1].(PDFArray)[2] != PDFInteger(103) {
+		t.Fatalf("TestWatermarkUserFont: unexpected widths %v\n", w)
+	}
+}
+
+func TestWatermarkUserFontCFF(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "userFont")
+	if err != nil {
+		t.Fatalf("TestWatermarkUserFontCFF: %v\n", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Turn the test font into an OpenType font with CFF outlines.
+	b := testUserFont(t)
+	copy(b, "OTTO")
+
+	fileName := filepath.Join(dir, "test.otf")
+
+	err = ioutil.WriteFile(fileName, b, os.ModePerm)
+	if err != nil {
+		t.Fatalf("TestWatermarkUserFontCFF: %v\n", err)
+	}
+
+	_, err = ParseWatermarkDetails("C, f:"+fileName, true)
+	if err == nil || !strings.Contains(err.Error(), "CFF-based OpenType fonts are not supported") {
+		t.Fatalf("TestWatermarkUserFontCFF: unexpected error %v\n", err)
+	}
+}
@@ -0,0 +1,674 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/hhrutter/pdfcpu/pkg/log"
+	"github.com/hhrutter/pdfcpu/pkg/pdfcpu/content"
+	"github.com/pkg/errors"
+)
+
+// TextRun represents a sequence of glyphs sharing font, size and baseline.
+type TextRun struct {
+	Text  string  `json:"text"`
+	X     float64 `json:"x"` // the start of the baseline.
+	Y     float64 `json:"y"`
+	Width float64 `json:"width"`
+	Font  string  `json:"font"`
+	Size  float64 `json:"size"`
+}
+
+// PageText represents the text runs of a page in user space of the unrotated page
+// with the origin moved to the lower left corner of the visible region.
+type PageText struct {
+	Page   int       `json:"page"`
+	Width  float64   `json:"width"`
+	Height float64   `json:"height"`
+	Runs   []TextRun `json:"runs"`
+}
+
+// glyph represents a single rendered char code in device space.
+type glyph struct {
+	text         string
+	x, y, ex, ey float64 // origin and end of the glyph advance.
+	size         float64
+	font         string
+}
+
+type textState struct {
+	ctm         matrix
+	font        *textFont
+	fontName    string
+	fontSize    float64
+	charSpacing float64
+	wordSpacing float64
+	hScale      float64
+	leading     float64
+	rise        float64
+}
+
+type textExtractor struct {
+	xRefTable *XRefTable
+	fonts     map[int]*textFont // fonts by object number.
+	forms     map[int]bool      // form XObjects being processed.
+	ts        textState
+	stack     []textState
+	tm, tlm   matrix
+	glyphs    []glyph
+	image     func(objNr int, ctm matrix)    // called for each image XObject painted if set.
+	shown     func(f *textFont, code []byte) // called for each char code shown if set.
+}
+
+func newTextExtractor(xRefTable *XRefTable) *textExtractor {
+	return &textExtractor{
+		xRefTable: xRefTable,
+		fonts:     map[int]*textFont{},
+		forms:     map[int]bool{},
+		ts:        textState{ctm: identMatrix, hScale: 1},
+	}
+}
+
+func newMatrix(a, b, c, d, e, f float64) matrix {
+	return matrix{{a, b, 0}, {c, d, 0}, {e, f, 1}}
+}
+
+func translation(tx, ty float64) matrix {
+	return newMatrix(1, 0, 0, 1, tx, ty)
+}
+
+func (m matrix) transform(x, y float64) (float64, float64) {
+	return x*m[0][0] + y*m[1][0] + m[2][0], x*m[0][1] + y*m[1][1] + m[2][1]
+}
+
+func (te *textExtractor) operandMatrix(operands []content.Object) (matrix, bool) {
+
+	if len(operands) < 6 {
+		return identMatrix, false
+	}
+
+	var f [6]float64
+	for i, o := range operands[len(operands)-6:] {
+		v, ok := content.Number(o)
+		if !ok {
+			return identMatrix, false
+		}
+		f[i] = v
+	}
+
+	return newMatrix(f[0], f[1], f[2], f[3], f[4], f[5]), true
+}
+
+func (te *textExtractor) font(resources *PDFDict, name string) *textFont {
+
+	if resources == nil {
+		return nil
+	}
+
+	fonts, err := te.xRefTable.DereferenceDict(resources.Dict["Font"])
+	if err != nil || fonts == nil {
+		return nil
+	}
+
+	o, found := fonts.Find(name)
+	if !found {
+		return nil
+	}
+
+	indRef, ok := o.(PDFIndirectRef)
+	if !ok {
+		return loadTextFont(te.xRefTable, o)
+	}
+
+	objNr := indRef.ObjectNumber.Value()
+
+	f, ok := te.fonts[objNr]
+	if !ok {
+		f = loadTextFont(te.xRefTable, o)
+		f.objNr = objNr
+		te.fonts[objNr] = f
+	}
+
+	return f
+}
+
+func (te *textExtractor) moveTextLine(tx, ty float64) {
+	te.tlm = translation(tx, ty).multiply(te.tlm)
+	te.tm = te.tlm
+}
+
+// showCode renders a single char code at the current text position and advances the text matrix.
+// It returns the rendered glyph, the text rendering matrix and the displacement in text space.
+func (te *textExtractor) showCode(code []byte) (g glyph, trm matrix, tx, ty float64) {
+
+	ts := &te.ts
+	f := ts.font
+
+	trm = newMatrix(ts.fontSize*ts.hScale, 0, 0, ts.fontSize, 0, ts.rise).multiply(te.tm).multiply(ts.ctm)
+
+	w := f.width(code)
+
+	if f.vertical {
+		ty = -w*ts.fontSize + ts.charSpacing
+	} else {
+		tx = w*ts.fontSize + ts.charSpacing
+	}
+
+	if len(code) == 1 && code[0] == 0x20 {
+		if f.vertical {
+			ty += ts.wordSpacing
+		} else {
+			tx += ts.wordSpacing
+		}
+	}
+	tx *= ts.hScale
+
+	x, y := trm.transform(0, 0)
+	ex, ey := translation(tx, ty).multiply(te.tm).multiply(ts.ctm).transform(0, ts.rise)
+
+	g = glyph{
+		text: ligatureReplacer.Replace(f.text(code)),
+		x:    x,
+		y:    y,
+		ex:   ex,
+		ey:   ey,
+		size: math.Hypot(trm[1][0], trm[1][1]),
+		font: f.name,
+	}
+
+	te.tm = translation(tx, ty).multiply(te.tm)
+
+	return g, trm, tx, ty
+}
+
+func (te *textExtractor) showText(o content.Object) {
+
+	b, ok := content.StringBytes(o)
+	if !ok || te.ts.font == nil {
+		return
+	}
+
+	for _, code := range te.ts.font.codes(b) {
+		if te.shown != nil {
+			te.shown(te.ts.font, code)
+		}
+		g, _, _, _ := te.showCode(code)
+		te.glyphs = append(te.glyphs, g)
+	}
+}
+
+// adjust applies a position adjustment of a TJ array in thousandths of a unit of text space.
+func (te *textExtractor) adjust(v float64) {
+
+	d := -v / 1000 * te.ts.fontSize
+	if te.ts.font != nil && te.ts.font.vertical {
+		te.tm = translation(0, d).multiply(te.tm)
+	} else {
+		te.tm = translation(d*te.ts.hScale, 0).multiply(te.tm)
+	}
+}
+
+func (te *textExtractor) showTextArray(o content.Object) {
+
+	a, ok := o.(content.Array)
+	if !ok || te.ts.font == nil {
+		return
+	}
+
+	for _, o := range a {
+
+		v, ok := content.Number(o)
+		if !ok {
+			te.showText(o)
+			continue
+		}
+
+		te.adjust(v)
+	}
+}
+
+func (te *textExtractor) doXObject(resources *PDFDict, name string) error {
+
+	if resources == nil {
+		return nil
+	}
+
+	xobjs, err := te.xRefTable.DereferenceDict(resources.Dict["XObject"])
+	if err != nil || xobjs == nil {
+		return err
+	}
+
+	o, found := xobjs.Find(name)
+	if !found {
+		return nil
+	}
+
+	indRef, ok := o.(PDFIndirectRef)
+	if !ok {
+		return nil
+	}
+
+	objNr := indRef.ObjectNumber.Value()
+	if te.forms[objNr] {
+		// Recursive form.
+		return nil
+	}
+
+	sd, err := te.xRefTable.DereferenceStreamDict(o)
+	if err != nil || sd == nil {
+		return err
+	}
+
+	st := sd.Subtype()
+
+	if st != nil && *st == "Image" && te.image != nil {
+		te.image(objNr, te.ts.ctm)
+		return nil
+	}
+
+	if st == nil || *st != "Form" {
+		return nil
+	}
+
+	b := streamContent(te.xRefTable, o)
+	if b == nil {
+		return nil
+	}
+
+	formResources, err := te.xRefTable.DereferenceDict(sd.Dict["Resources"])
+	if err != nil {
+		return err
+	}
+	if formResources == nil {
+		formResources = resources
+	}
+
+	saved := te.ts
+	tm, tlm := te.tm, te.tlm
+
+	if a, err := te.xRefTable.DereferenceArray(sd.Dict["Matrix"]); err == nil && a != nil && len(*a) == 6 {
+		var f [6]float64
+		for i, o := range *a {
+			f[i] = te.xRefTable.DereferenceNumber(o)
+		}
+		te.ts.ctm = newMatrix(f[0], f[1], f[2], f[3], f[4], f[5]).multiply(te.ts.ctm)
+	}
+
+	te.forms[objNr] = true
+	err = te.processContent(b, formResources)
+	delete(te.forms, objNr)
+
+	te.ts = saved
+	te.tm, te.tlm = tm, tlm
+
+	return err
+}
+
+func (te *textExtractor) processOperator(op string, operands []content.Object, resources *PDFDict) error {
+
+	ts := &te.ts
+
+	operand := func(i int) float64 {
+		if i >= len(operands) {
+			return 0
+		}
+		v, _ := content.Number(operands[i])
+		return v
+	}
+
+	switch op {
+
+	case "q":
+		te.stack = append(te.stack, te.ts)
+
+	case "Q":
+		if len(te.stack) > 0 {
+			te.ts = te.stack[len(te.stack)-1]
+			te.stack = te.stack[:len(te.stack)-1]
+		}
+
+	case "cm":
+		if m, ok := te.operandMatrix(operands); ok {
+			ts.ctm = m.multiply(ts.ctm)
+		}
+
+	case "BT":
+		te.tm, te.tlm = identMatrix, identMatrix
+
+	case "Tf":
+		if len(operands) >= 2 {
+			if n, ok := operands[0].(content.Name); ok {
+				ts.fontName = string(n)
+				ts.font = te.font(resources, ts.fontName)
+			}
+			ts.fontSize = operand(1)
+		}
+
+	case "Tc":
+		ts.charSpacing = operand(0)
+
+	case "Tw":
+		ts.wordSpacing = operand(0)
+
+	case "Tz":
+		ts.hScale = operand(0) / 100
+
+	case "TL":
+		ts.leading = operand(0)
+
+	case "Ts":
+		ts.rise = operand(0)
+
+	case "Td":
+		te.moveTextLine(operand(0), operand(1))
+
+	case "TD":
+		ts.leading = -operand(1)
+		te.moveTextLine(operand(0), operand(1))
+
+	case "Tm":
+		if m, ok := te.operandMatrix(operands); ok {
+			te.tm, te.tlm = m, m
+		}
+
+	case "T*":
+		te.moveTextLine(0, -ts.leading)
+
+	case "Tj":
+		if len(operands) > 0 {
+			te.showText(operands[len(operands)-1])
+		}
+
+	case "'":
+		te.moveTextLine(0, -ts.leading)
+		if len(operands) > 0 {
+			te.showText(operands[len(operands)-1])
+		}
+
+	case "\"":
+		if len(operands) >= 3 {
+			ts.wordSpacing = operand(0)
+			ts.charSpacing = operand(1)
+			te.moveTextLine(0, -ts.leading)
+			te.showText(operands[2])
+		}
+
+	case "TJ":
+		if len(operands) > 0 {
+			te.showTextArray(operands[len(operands)-1])
+		}
+
+	case "Do":
+		if len(operands) > 0 {
+			if n, ok := operands[0].(content.Name); ok {
+				return te.doXObject(resources, string(n))
+			}
+		}
+
+	}
+
+	return nil
+}
+
+func (te *textExtractor) processContent(b []byte, resources *PDFDict) error {
+
+	c, err := content.Parse(b)
+	if err != nil {
+		// Extract as much text as possible from corrupt content.
+		log.Debug.Printf("processContent: %v\n", err)
+	}
+
+	for _, op := range c.Operations {
+		err = te.processOperator(op.Operator, op.Operands, resources)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// pageContent returns the concatenated content streams of a page.
+func pageContent(xRefTable *XRefTable, pageDict *PDFDict) ([]byte, error) {
+
+	o, err := xRefTable.Dereference(pageDict.Dict["Contents"])
+	if err != nil || o == nil {
+		return nil, err
+	}
+
+	switch o := o.(type) {
+
+	case PDFStreamDict:
+		return streamContent(xRefTable, pageDict.Dict["Contents"]), nil
+
+	case PDFArray:
+		var b []byte
+		for _, o := range o {
+			// Content streams may be split anywhere between tokens.
+			b = append(b, streamContent(xRefTable, o)...)
+			b = append(b, '\n')
+		}
+		return b, nil
+
+	}
+
+	return nil, errors.Errorf("pageContent: corrupt page contents: %v", o)
+}
+
+// rotate transforms a point in user space of the unrotated page into
+// a point in user space of the page as displayed.
+func rotate(x, y, w, h float64, rot int) (float64, float64) {
+
+	switch rot {
+
+	case 90:
+		return y, w - x
+
+	case 180:
+		return w - x, h - y
+
+	case 270:
+		return h - y, x
+
+	}
+
+	return x, y
+}
+
+// roundPoints rounds to 1/100 of a point.
+func roundPoints(f float64) float64 {
+	return math.Round(f*100) / 100
+}
+
+func newTextRun(g glyph) TextRun {
+	return TextRun{Text: g.text, X: g.x, Y: g.y, Width: g.ex - g.x, Font: g.font, Size: g.size}
+}
+
+// textRuns groups glyphs in content order into runs.
+func textRuns(glyphs []glyph) []TextRun {
+
+	runs := []TextRun{}
+
+	var r *TextRun
+
+	flush := func() {
+		if r != nil && strings.TrimSpace(r.Text) != "" {
+			r.Text = strings.TrimRight(r.Text, " ")
+			r.X, r.Y, r.Width, r.Size = roundPoints(r.X), roundPoints(r.Y), roundPoints(r.Width), roundPoints(r.Size)
+			runs = append(runs, *r)
+		}
+		r = nil
+	}
+
+	for _, g := range glyphs {
+
+		if r != nil {
+
+			gap := g.x - (r.X + r.Width)
+			sameLine := g.font == r.Font && math.Abs(g.size-r.Size) < 0.01 && math.Abs(g.y-r.Y) < 0.1*g.size
+
+			if sameLine && gap > -0.5*g.size && gap < g.size {
+				if gap > 0.15*g.size && !strings.HasSuffix(r.Text, " ") && !strings.HasPrefix(g.text, " ") {
+					r.Text += " "
+				}
+				r.Text += g.text
+				r.Width = g.ex - r.X
+				continue
+			}
+
+			flush()
+		}
+
+		if g.text == "" {
+			continue
+		}
+
+		tr := newTextRun(g)
+		r = &tr
+	}
+
+	flush()
+
+	return runs
+}
+
+// ExtractPageText extracts the text runs of a page.
+func ExtractPageText(ctx *PDFContext, pageNr int) (*PageText, error) {
+
+	xRefTable := ctx.XRefTable
+
+	pageDict, inhPAttrs, err := xRefTable.PageDict(pageNr)
+	if err != nil {
+		return nil, err
+	}
+
+	if pageDict == nil {
+		return nil, errors.Errorf("ExtractPageText: page %d not found", pageNr)
+	}
+
+	visibleRegion := inhPAttrs.mediaBox
+	if inhPAttrs.cropBox != nil {
+		visibleRegion = inhPAttrs.cropBox
+	}
+
+	pt := &PageText{Page: pageNr, Runs: []TextRun{}}
+
+	if visibleRegion == nil || len(*visibleRegion) != 4 {
+		return nil, errors.Errorf("ExtractPageText: missing media box for page %d", pageNr)
+	}
+
+	r := rect(xRefTable, *visibleRegion)
+
+	b, err := pageContent(xRefTable, pageDict)
+	if err != nil {
+		return nil, err
+	}
+
+	te := newTextExtractor(xRefTable)
+
+	err = te.processContent(b, inhPAttrs.resources)
+	if err != nil {
+		return nil, err
+	}
+
+	rot := (int(inhPAttrs.rotate)%360 + 360) % 360
+
+	w, h := r.Width(), r.Height()
+	pt.Width, pt.Height = w, h
+	if rot == 90 || rot == 270 {
+		pt.Width, pt.Height = h, w
+	}
+
+	for i, g := range te.glyphs {
+		g.x, g.y = rotate(g.x-r.LL.X, g.y-r.LL.Y, w, h, rot)
+		g.ex, g.ey = rotate(g.ex-r.LL.X, g.ey-r.LL.Y, w, h, rot)
+		te.glyphs[i] = g
+	}
+
+	pt.Runs = textRuns(te.glyphs)
+
+	return pt, nil
+}
+
+type textLine struct {
+	y, size float64
+	runs    []TextRun
+}
+
+// Text returns the text of a page in reading order.
+func (pt PageText) Text() string {
+
+	runs := make([]TextRun, len(pt.Runs))
+	copy(runs, pt.Runs)
+
+	sort.SliceStable(runs, func(i, j int) bool { return runs[i].Y > runs[j].Y })
+
+	// Cluster runs into lines.
+	var lines []*textLine
+	var l *textLine
+
+	for _, r := range runs {
+		if l != nil && math.Abs(r.Y-l.y) < 0.5*math.Max(r.Size, l.size) {
+			l.runs = append(l.runs, r)
+			continue
+		}
+		l = &textLine{y: r.Y, size: r.Size, runs: []TextRun{r}}
+		lines = append(lines, l)
+	}
+
+	var sb strings.Builder
+
+	for i, l := range lines {
+
+		if i > 0 && lines[i-1].y-l.y > 2*math.Max(l.size, lines[i-1].size) {
+			sb.WriteString("\n")
+		}
+
+		sort.SliceStable(l.runs, func(i, j int) bool { return l.runs[i].X < l.runs[j].X })
+
+		for j, r := range l.runs {
+			if j > 0 {
+				prev := l.runs[j-1]
+				if r.Text == prev.Text && math.Abs(r.X-prev.X) < r.Size {
+					// Skip text overprinted to simulate bold.
+					l.runs[j] = prev
+					continue
+				}
+				gap := r.X - (prev.X + prev.Width)
+				if gap > 0.15*r.Size {
+					// Preserve columns by keeping large gaps visible.
+					n := int(gap / (0.5 * r.Size))
+					if n < 1 {
+						n = 1
+					}
+					if n > 40 {
+						n = 40
+					}
+					sb.WriteString(strings.Repeat(" ", n))
+				}
+			}
+			sb.WriteString(r.Text)
+		}
+
+		sb.WriteString("\n")
+	}
+
+	return sb.String()
+}
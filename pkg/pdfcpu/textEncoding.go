@@ -0,0 +1,258 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Simple font encodings as specified in Annex D of the PDF spec.
+
+type encoding [256]string
+
+// ASCII glyph names for the codes 32 - 126.
+var asciiGlyphNames = []string{
+	"space", "exclam", "quotedbl", "numbersign", "dollar", "percent", "ampersand", "quotesingle",
+	"parenleft", "parenright", "asterisk", "plus", "comma", "hyphen", "period", "slash",
+	"zero", "one", "two", "three", "four", "five", "six", "seven", "eight", "nine",
+	"colon", "semicolon", "less", "equal", "greater", "question", "at",
+	"A", "B", "C", "D", "E", "F", "G", "H", "I", "J", "K", "L", "M",
+	"N", "O", "P", "Q", "R", "S", "T", "U", "V", "W", "X", "Y", "Z",
+	"bracketleft", "backslash", "bracketright", "asciicircum", "underscore", "grave",
+	"a", "b", "c", "d", "e", "f", "g", "h", "i", "j", "k", "l", "m",
+	"n", "o", "p", "q", "r", "s", "t", "u", "v", "w", "x", "y", "z",
+	"braceleft", "bar", "braceright", "asciitilde",
+}
+
+// Latin-1 glyph names for the codes 0xA1 - 0xFF.
+var latin1GlyphNames = []string{
+	"exclamdown", "cent", "sterling", "currency", "yen", "brokenbar", "section", "dieresis",
+	"copyright", "ordfeminine", "guillemotleft", "logicalnot", "hyphen", "registered", "macron",
+	"degree", "plusminus", "twosuperior", "threesuperior", "acute", "mu", "paragraph", "periodcentered",
+	"cedilla", "onesuperior", "ordmasculine", "guillemotright", "onequarter", "onehalf", "threequarters", "questiondown",
+	"Agrave", "Aacute", "Acircumflex", "Atilde", "Adieresis", "Aring", "AE", "Ccedilla",
+	"Egrave", "Eacute", "Ecircumflex", "Edieresis", "Igrave", "Iacute", "Icircumflex", "Idieresis",
+	"Eth", "Ntilde", "Ograve", "Oacute", "Ocircumflex", "Otilde", "Odieresis", "multiply",
+	"Oslash", "Ugrave", "Uacute", "Ucircumflex", "Udieresis", "Yacute", "Thorn", "germandbls",
+	"agrave", "aacute", "acircumflex", "atilde", "adieresis", "aring", "ae", "ccedilla",
+	"egrave", "eacute", "ecircumflex", "edieresis", "igrave", "iacute", "icircumflex", "idieresis",
+	"eth", "ntilde", "ograve", "oacute", "ocircumflex", "otilde", "odieresis", "divide",
+	"oslash", "ugrave", "uacute", "ucircumflex", "udieresis", "yacute", "thorn", "ydieresis",
+}
+
+var winAnsiHigh = map[int]string{
+	0x80: "Euro", 0x82: "quotesinglbase", 0x83: "florin", 0x84: "quotedblbase", 0x85: "ellipsis",
+	0x86: "dagger", 0x87: "daggerdbl", 0x88: "circumflex", 0x89: "perthousand", 0x8A: "Scaron",
+	0x8B: "guilsinglleft", 0x8C: "OE", 0x8E: "Zcaron", 0x91: "quoteleft", 0x92: "quoteright",
+	0x93: "quotedblleft", 0x94: "quotedblright", 0x95: "bullet", 0x96: "endash", 0x97: "emdash",
+	0x98: "tilde", 0x99: "trademark", 0x9A: "scaron", 0x9B: "guilsinglright", 0x9C: "oe",
+	0x9E: "zcaron", 0x9F: "Ydieresis", 0xA0: "space",
+}
+
+var standardHigh = map[int]string{
+	0xA1: "exclamdown", 0xA2: "cent", 0xA3: "sterling", 0xA4: "fraction", 0xA5: "yen", 0xA6: "florin",
+	0xA7: "section", 0xA8: "currency", 0xA9: "quotesingle", 0xAA: "quotedblleft", 0xAB: "guillemotleft",
+	0xAC: "guilsinglleft", 0xAD: "guilsinglright", 0xAE: "fi", 0xAF: "fl", 0xB1: "endash",
+	0xB2: "dagger", 0xB3: "daggerdbl", 0xB4: "periodcentered", 0xB6: "paragraph", 0xB7: "bullet",
+	0xB8: "quotesinglbase", 0xB9: "quotedblbase", 0xBA: "quotedblright", 0xBB: "guillemotright",
+	0xBC: "ellipsis", 0xBD: "perthousand", 0xBF: "questiondown", 0xC1: "grave", 0xC2: "acute",
+	0xC3: "circumflex", 0xC4: "tilde", 0xC5: "macron", 0xC6: "breve", 0xC7: "dotaccent",
+	0xC8: "dieresis", 0xCA: "ring", 0xCB: "cedilla", 0xCD: "hungarumlaut", 0xCE: "ogonek",
+	0xCF: "caron", 0xD0: "emdash", 0xE1: "AE", 0xE3: "ordfeminine", 0xE8: "Lslash", 0xE9: "Oslash",
+	0xEA: "OE", 0xEB: "ordmasculine", 0xF1: "ae", 0xF5: "dotlessi", 0xF8: "lslash", 0xF9: "oslash",
+	0xFA: "oe", 0xFB: "germandbls",
+}
+
+var macRomanHigh = []string{
+	"Adieresis", "Aring", "Ccedilla", "Eacute", "Ntilde", "Odieresis", "Udieresis", "aacute",
+	"agrave", "acircumflex", "adieresis", "atilde", "aring", "ccedilla", "eacute", "egrave",
+	"ecircumflex", "edieresis", "iacute", "igrave", "icircumflex", "idieresis", "ntilde", "oacute",
+	"ograve", "ocircumflex", "odieresis", "otilde", "uacute", "ugrave", "ucircumflex", "udieresis",
+	"dagger", "degree", "cent", "sterling", "section", "bullet", "paragraph", "germandbls",
+	"registered", "copyright", "trademark", "acute", "dieresis", "notequal", "AE", "Oslash",
+	"infinity", "plusminus", "lessequal", "greaterequal", "yen", "mu", "partialdiff", "summation",
+	"product", "pi", "integral", "ordfeminine", "ordmasculine", "Omega", "ae", "oslash",
+	"questiondown", "exclamdown", "logicalnot", "radical", "florin", "approxequal", "Delta", "guillemotleft",
+	"guillemotright", "ellipsis", "space", "Agrave", "Atilde", "Otilde", "OE", "oe",
+	"endash", "emdash", "quotedblleft", "quotedblright", "quoteleft", "quoteright", "divide", "lozenge",
+	"ydieresis", "Ydieresis", "fraction", "currency", "guilsinglleft", "guilsinglright", "fi", "fl",
+	"daggerdbl", "periodcentered", "quotesinglbase", "quotedblbase", "perthousand", "Acircumflex", "Ecircumflex", "Aacute",
+	"Edieresis", "Egrave", "Iacute", "Icircumflex", "Idieresis", "Igrave", "Oacute", "Ocircumflex",
+	"", "Ograve", "Uacute", "Ucircumflex", "Ugrave", "dotlessi", "circumflex", "tilde",
+	"macron", "breve", "dotaccent", "ring", "cedilla", "hungarumlaut", "ogonek", "caron",
+}
+
+// Glyph names outside of ASCII and Latin-1 as specified by the Adobe Glyph List.
+var extraGlyphs = map[string]rune{
+	"Euro": 0x20AC, "quotesinglbase": 0x201A, "florin": 0x0192, "quotedblbase": 0x201E,
+	"ellipsis": 0x2026, "dagger": 0x2020, "daggerdbl": 0x2021, "circumflex": 0x02C6,
+	"perthousand": 0x2030, "Scaron": 0x0160, "guilsinglleft": 0x2039, "OE": 0x0152,
+	"Zcaron": 0x017D, "quoteleft": 0x2018, "quoteright": 0x2019, "quotedblleft": 0x201C,
+	"quotedblright": 0x201D, "bullet": 0x2022, "endash": 0x2013, "emdash": 0x2014,
+	"tilde": 0x02DC, "trademark": 0x2122, "scaron": 0x0161, "guilsinglright": 0x203A,
+	"oe": 0x0153, "zcaron": 0x017E, "Ydieresis": 0x0178, "fraction": 0x2044,
+	"breve": 0x02D8, "dotaccent": 0x02D9, "ring": 0x02DA, "hungarumlaut": 0x02DD,
+	"ogonek": 0x02DB, "caron": 0x02C7, "Lslash": 0x0141, "lslash": 0x0142, "dotlessi": 0x0131,
+	"notequal": 0x2260, "infinity": 0x221E, "lessequal": 0x2264, "greaterequal": 0x2265,
+	"partialdiff": 0x2202, "summation": 0x2211, "product": 0x220F, "pi": 0x03C0,
+	"integral": 0x222B, "Omega": 0x2126, "radical": 0x221A, "approxequal": 0x2248,
+	"Delta": 0x2206, "lozenge": 0x25CA, "minus": 0x2212, "nbspace": 0x00A0, "sfthyphen": 0x00AD,
+	"nonbreakingspace": 0x00A0, "softhyphen": 0x00AD, "middot": 0x00B7, "Dcroat": 0x0110,
+	"dcroat": 0x0111, "periodcentered": 0x00B7, "quotereversed": 0x201B, "afii61664": 0x200C,
+	"alpha": 0x03B1, "beta": 0x03B2, "gamma": 0x03B3, "delta": 0x03B4, "epsilon": 0x03B5,
+	"mu1": 0x00B5, "sigma": 0x03C3, "tau": 0x03C4, "phi": 0x03C6, "omega": 0x03C9,
+	"arrowleft": 0x2190, "arrowup": 0x2191, "arrowright": 0x2192, "arrowdown": 0x2193,
+	"checkmark": 0x2713, "degree": 0x00B0, "copyrightsans": 0x00A9, "registersans": 0x00AE,
+}
+
+// Ligatures are decomposed in order to keep extracted text searchable.
+var ligatureGlyphs = map[string]string{
+	"ff": "ff", "fi": "fi", "fl": "fl", "ffi": "ffi", "ffl": "ffl", "st": "st",
+}
+
+var ligatureReplacer = strings.NewReplacer(
+	"ﬀ", "ff", "ﬁ", "fi", "ﬂ", "fl", "ﬃ", "ffi", "ﬄ", "ffl", "ﬆ", "st")
+
+var (
+	glyphRunes       map[string]rune
+	standardEncoding encoding
+	winAnsiEncoding  encoding
+	macRomanEncoding encoding
+)
+
+func init() {
+
+	glyphRunes = map[string]rune{}
+
+	for i, n := range asciiGlyphNames {
+		glyphRunes[n] = rune(32 + i)
+	}
+
+	for i, n := range latin1GlyphNames {
+		if n != "hyphen" {
+			glyphRunes[n] = rune(0xA1 + i)
+		}
+	}
+
+	for n, r := range extraGlyphs {
+		glyphRunes[n] = r
+	}
+
+	for i, n := range asciiGlyphNames {
+		standardEncoding[32+i] = n
+		winAnsiEncoding[32+i] = n
+		macRomanEncoding[32+i] = n
+	}
+
+	standardEncoding[0x27] = "quoteright"
+	standardEncoding[0x60] = "quoteleft"
+	for c, n := range standardHigh {
+		standardEncoding[c] = n
+	}
+
+	for c, n := range winAnsiHigh {
+		winAnsiEncoding[c] = n
+	}
+	for i, n := range latin1GlyphNames {
+		winAnsiEncoding[0xA1+i] = n
+	}
+	// Bullets for undefined codes.
+	for _, c := range []int{0x7F, 0x81, 0x8D, 0x8F, 0x90, 0x9D} {
+		winAnsiEncoding[c] = "bullet"
+	}
+
+	for i, n := range macRomanHigh {
+		macRomanEncoding[0x80+i] = n
+	}
+}
+
+// baseEncoding returns a predefined simple font encoding.
+func baseEncoding(name string) (encoding, bool) {
+
+	switch name {
+
+	case "StandardEncoding":
+		return standardEncoding, true
+
+	case "WinAnsiEncoding":
+		return winAnsiEncoding, true
+
+	case "MacRomanEncoding":
+		return macRomanEncoding, true
+
+	}
+
+	return encoding{}, false
+}
+
+// glyphText returns the text for a glyph name.
+func glyphText(name string) (string, bool) {
+
+	if name == "" || name == ".notdef" {
+		return "", false
+	}
+
+	// Strip a variant suffix like in "a.sc".
+	if i := strings.IndexByte(name, '.'); i > 0 {
+		name = name[:i]
+	}
+
+	if s, ok := ligatureGlyphs[name]; ok {
+		return s, true
+	}
+
+	if r, ok := glyphRunes[name]; ok {
+		return string(r), true
+	}
+
+	// Ligatures composed of components like "f_f_i".
+	if strings.IndexByte(name, '_') > 0 {
+		var sb strings.Builder
+		for _, c := range strings.Split(name, "_") {
+			s, ok := glyphText(c)
+			if !ok {
+				return "", false
+			}
+			sb.WriteString(s)
+		}
+		return sb.String(), true
+	}
+
+	// uniXXXX[XXXX..]
+	if strings.HasPrefix(name, "uni") && len(name) >= 7 && (len(name)-3)%4 == 0 {
+		var sb strings.Builder
+		for i := 3; i < len(name); i += 4 {
+			r, err := strconv.ParseUint(name[i:i+4], 16, 32)
+			if err != nil {
+				return "", false
+			}
+			sb.WriteRune(rune(r))
+		}
+		return sb.String(), true
+	}
+
+	// uXXXX[XX]
+	if name[0] == 'u' && len(name) >= 5 && len(name) <= 7 {
+		r, err := strconv.ParseUint(name[1:], 16, 32)
+		if err == nil {
+			return string(rune(r)), true
+		}
+	}
+
+	return "", false
+}
@@ -0,0 +1,365 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/hhrutter/pdfcpu/pkg/filter"
+	"github.com/hhrutter/pdfcpu/pkg/fonts/metrics"
+	"github.com/hhrutter/pdfcpu/pkg/log"
+)
+
+// textFont represents the parts of a font dict needed for text extraction.
+type textFont struct {
+	objNr     int             // the object number of the font dict, 0 for direct font dicts.
+	name      string          // the PostScript name of the font.
+	composite bool            // true for Type0 fonts.
+	vertical  bool            // true for vertical writing mode.
+	unicode   bool            // true for predefined Unicode CMaps, codes are UTF-16BE.
+	cmap      *cmap           // the embedded encoding CMap of a Type0 font.
+	toUnicode *cmap           // the ToUnicode CMap.
+	enc       encoding        // the glyph names of a simple font.
+	widths    map[int]float64 // glyph widths by char code or by CID for Type0 fonts.
+	defWidth  float64         // the width of glyphs missing in widths.
+	scale     float64         // glyph space to text space.
+	std       string          // the name of the standard font supplying metrics.
+}
+
+// streamContent returns the decoded content of a stream dict.
+func streamContent(xRefTable *XRefTable, o PDFObject) []byte {
+
+	sd, err := xRefTable.DereferenceStreamDict(o)
+	if err != nil || sd == nil {
+		return nil
+	}
+
+	if sd.FilterPipeline == nil {
+		return sd.Raw
+	}
+
+	err = decodeImageStream(xRefTable, sd)
+	if err == filter.ErrUnsupportedFilter {
+		return nil
+	}
+	if err != nil {
+		log.Debug.Printf("streamContent: %v\n", err)
+		return nil
+	}
+
+	return sd.Content
+}
+
+// standardFontForMetrics returns the name of a standard font with metrics matching fontName.
+func standardFontForMetrics(fontName string) string {
+
+	// Strip a subset prefix.
+	if len(fontName) > 7 && fontName[6] == '+' {
+		fontName = fontName[7:]
+	}
+
+	names := metrics.FontNames()
+	sort.Strings(names)
+
+	for _, n := range names {
+		if n == fontName {
+			return n
+		}
+	}
+
+	// Match the family and style of fonts like Arial,Bold or TimesNewRomanPS-ItalicMT.
+	styled := func(s string) (bool, bool) {
+		return strings.Contains(s, "Bold"), strings.Contains(s, "Italic") || strings.Contains(s, "Oblique")
+	}
+
+	bold, italic := styled(fontName)
+
+	for _, n := range names {
+		family := strings.Split(n, "-")[0]
+		if !strings.HasPrefix(fontName, family) {
+			continue
+		}
+		if b, i := styled(n); b == bold && i == italic {
+			return n
+		}
+	}
+
+	return ""
+}
+
+func loadTextFont(xRefTable *XRefTable, o PDFObject) *textFont {
+
+	f := &textFont{widths: map[int]float64{}, scale: 0.001}
+
+	d, err := xRefTable.DereferenceDict(o)
+	if err != nil || d == nil {
+		return f
+	}
+
+	if bf := d.NameEntry("BaseFont"); bf != nil {
+		f.name = *bf
+	}
+
+	if o, found := d.Find("ToUnicode"); found {
+		if b := streamContent(xRefTable, o); b != nil {
+			f.toUnicode = parseCMap(b)
+		}
+	}
+
+	subType := d.Subtype()
+	if subType != nil && *subType == "Type0" {
+		f.loadType0(xRefTable, d)
+		return f
+	}
+
+	if subType != nil && *subType == "Type3" {
+		if a, err := xRefTable.DereferenceArray(d.Dict["FontMatrix"]); err == nil && a != nil && len(*a) == 6 {
+			f.scale = xRefTable.DereferenceNumber((*a)[0])
+		}
+	}
+
+	f.loadEncoding(xRefTable, d, subType != nil && *subType == "TrueType")
+	f.loadSimpleWidths(xRefTable, d)
+
+	return f
+}
+
+func (f *textFont) loadEncoding(xRefTable *XRefTable, d *PDFDict, trueType bool) {
+
+	f.enc = standardEncoding
+	if trueType {
+		f.enc = winAnsiEncoding
+	}
+
+	o, err := xRefTable.Dereference(d.Dict["Encoding"])
+	if err != nil || o == nil {
+		return
+	}
+
+	switch o := o.(type) {
+
+	case PDFName:
+		if enc, ok := baseEncoding(o.Value()); ok {
+			f.enc = enc
+		}
+
+	case PDFDict:
+		if n := o.NameEntry("BaseEncoding"); n != nil {
+			if enc, ok := baseEncoding(*n); ok {
+				f.enc = enc
+			}
+		}
+
+		a, err := xRefTable.DereferenceArray(o.Dict["Differences"])
+		if err != nil || a == nil {
+			return
+		}
+
+		code := 0
+		for _, o := range *a {
+			o, _ = xRefTable.Dereference(o)
+			switch o := o.(type) {
+			case PDFInteger:
+				code = o.Value()
+			case PDFName:
+				if code >= 0 && code < 256 {
+					f.enc[code] = o.Value()
+				}
+				code++
+			}
+		}
+
+	}
+}
+
+func (f *textFont) loadSimpleWidths(xRefTable *XRefTable, d *PDFDict) {
+
+	f.defWidth = 500
+
+	if fd, err := xRefTable.DereferenceDict(d.Dict["FontDescriptor"]); err == nil && fd != nil {
+		if o, found := fd.Find("MissingWidth"); found {
+			f.defWidth = xRefTable.DereferenceNumber(o)
+		}
+	}
+
+	a, err := xRefTable.DereferenceArray(d.Dict["Widths"])
+	if err == nil && a != nil {
+		firstChar := int(xRefTable.DereferenceNumber(d.Dict["FirstChar"]))
+		for i, o := range *a {
+			f.widths[firstChar+i] = xRefTable.DereferenceNumber(o)
+		}
+		return
+	}
+
+	f.std = standardFontForMetrics(f.name)
+}
+
+func (f *textFont) loadType0(xRefTable *XRefTable, d *PDFDict) {
+
+	f.composite = true
+	f.defWidth = 1000
+
+	o, _ := xRefTable.Dereference(d.Dict["Encoding"])
+
+	switch o := o.(type) {
+
+	case PDFName:
+		n := o.Value()
+		f.vertical = strings.HasSuffix(n, "-V")
+		f.unicode = strings.HasPrefix(n, "Uni") && (strings.Contains(n, "UCS2") || strings.Contains(n, "UTF16"))
+
+	case PDFStreamDict:
+		if b := streamContent(xRefTable, o); b != nil {
+			f.cmap = parseCMap(b)
+			if len(f.cmap.codespace) == 0 {
+				f.cmap = nil
+			}
+		}
+		if wm := o.IntEntry("WMode"); wm != nil {
+			f.vertical = *wm == 1
+		}
+
+	}
+
+	a, err := xRefTable.DereferenceArray(d.Dict["DescendantFonts"])
+	if err != nil || a == nil || len(*a) == 0 {
+		return
+	}
+
+	df, err := xRefTable.DereferenceDict((*a)[0])
+	if err != nil || df == nil {
+		return
+	}
+
+	if o, found := df.Find("DW"); found {
+		f.defWidth = xRefTable.DereferenceNumber(o)
+	}
+
+	w, err := xRefTable.DereferenceArray(df.Dict["W"])
+	if err != nil || w == nil {
+		return
+	}
+
+	f.loadCIDWidths(xRefTable, *w)
+}
+
+// loadCIDWidths parses a W array made of "c [w1 w2 ... wn]" and "cfirst clast w" entries.
+func (f *textFont) loadCIDWidths(xRefTable *XRefTable, a PDFArray) {
+
+	for i := 0; i+1 < len(a); {
+
+		first := int(xRefTable.DereferenceNumber(a[i]))
+
+		o, _ := xRefTable.Dereference(a[i+1])
+		if ws, ok := o.(PDFArray); ok {
+			for j, w := range ws {
+				f.widths[first+j] = xRefTable.DereferenceNumber(w)
+			}
+			i += 2
+			continue
+		}
+
+		if i+2 >= len(a) {
+			return
+		}
+
+		last := int(xRefTable.DereferenceNumber(a[i+1]))
+		w := xRefTable.DereferenceNumber(a[i+2])
+		for c := first; c <= last && c-first <= 0xFFFF; c++ {
+			f.widths[c] = w
+		}
+		i += 3
+	}
+}
+
+// codes splits a string operand into char codes.
+func (f *textFont) codes(b []byte) [][]byte {
+
+	var cc [][]byte
+
+	for len(b) > 0 {
+
+		n := 1
+		if f.composite {
+			n = 2
+			if f.cmap != nil {
+				n = f.cmap.codeLength(b)
+			}
+		}
+
+		if n > len(b) {
+			n = len(b)
+		}
+
+		cc = append(cc, b[:n])
+		b = b[n:]
+	}
+
+	return cc
+}
+
+// text returns the Unicode text for a char code.
+func (f *textFont) text(code []byte) string {
+
+	if f.toUnicode != nil {
+		if s, ok := f.toUnicode.text(code); ok {
+			return s
+		}
+	}
+
+	if f.composite {
+		if f.unicode {
+			return utf16Text(code)
+		}
+		return ""
+	}
+
+	c := code[0]
+
+	if s, ok := glyphText(f.enc[c]); ok {
+		return s
+	}
+
+	if c >= 32 {
+		return string(rune(c))
+	}
+
+	return ""
+}
+
+// width returns the glyph width for a char code in text space units.
+func (f *textFont) width(code []byte) float64 {
+
+	c := bytesValue(code)
+	if f.composite && f.cmap != nil {
+		c = f.cmap.cid(code)
+	}
+
+	if w, ok := f.widths[c]; ok {
+		return w * f.scale
+	}
+
+	if f.std != "" {
+		r := []rune(f.text(code))
+		if len(r) == 1 && r[0] < 256 {
+			return float64(metrics.CharWidth(f.std, int(r[0]))) * f.scale
+		}
+	}
+
+	return f.defWidth * f.scale
+}
@@ -0,0 +1,496 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"encoding/binary"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// Tables dropped when subsetting a TrueType font because PDF consumers do not need them
+// or because they are invalidated by subsetting.
+var trueTypeDroppedTables = map[string]bool{
+	"DSIG": true, "hdmx": true, "LTSH": true, "VDMX": true, "kern": true,
+	"GDEF": true, "GPOS": true, "GSUB": true, "BASE": true, "JSTF": true, "morx": true, "mort": true, "feat": true,
+	"vhea": true, "vmtx": true, "EBDT": true, "EBLC": true, "EBSC": true,
+}
+
+// trueTypeFont represents the tables of a TrueType font program.
+type trueTypeFont struct {
+	tables    map[string][]byte
+	numGlyphs int
+	loca      []int // glyph offsets into glyf.
+}
+
+func (tt *trueTypeFont) u16(tag string, off int) int {
+	t := tt.tables[tag]
+	if off+2 > len(t) {
+		return 0
+	}
+	return int(binary.BigEndian.Uint16(t[off:]))
+}
+
+// parseTrueType parses the table directory of a TrueType font and its glyph locations.
+func parseTrueType(b []byte) (*trueTypeFont, error) {
+
+	if len(b) < 12 {
+		return nil, errors.New("parseTrueType: corrupt font")
+	}
+
+	v := binary.BigEndian.Uint32(b)
+
+	if v == 0x4F54544F { // OTTO
+		return nil, errors.New("parseTrueType: CFF-based OpenType fonts are not supported")
+	}
+
+	if v != 0x00010000 && v != 0x74727565 {
+		return nil, errors.Errorf("parseTrueType: unsupported sfnt version %08X", v)
+	}
+
+	tt := &trueTypeFont{tables: map[string][]byte{}}
+
+	n := int(binary.BigEndian.Uint16(b[4:]))
+
+	for i := 0; i < n; i++ {
+		r := 12 + 16*i
+		if r+16 > len(b) {
+			return nil, errors.New("parseTrueType: corrupt table directory")
+		}
+		off, l := int(binary.BigEndian.Uint32(b[r+8:])), int(binary.BigEndian.Uint32(b[r+12:]))
+		if off < 0 || l < 0 || off+l > len(b) {
+			return nil, errors.Errorf("parseTrueType: corrupt table %s", b[r:r+4])
+		}
+		tt.tables[string(b[r:r+4])] = b[off : off+l]
+	}
+
+	for tag, l := range map[string]int{"head": 54, "hhea": 36, "maxp": 6, "loca": 0, "glyf": 0, "hmtx": 0} {
+		if t, ok := tt.tables[tag]; !ok || len(t) < l {
+			return nil, errors.Errorf("parseTrueType: missing table %s", tag)
+		}
+	}
+
+	tt.numGlyphs = tt.u16("maxp", 4)
+
+	loca := tt.tables["loca"]
+	long := tt.u16("head", 50) == 1
+
+	tt.loca = make([]int, tt.numGlyphs+1)
+
+	for i := range tt.loca {
+		switch {
+		case long && 4*i+4 <= len(loca):
+			tt.loca[i] = int(binary.BigEndian.Uint32(loca[4*i:]))
+		case !long && 2*i+2 <= len(loca):
+			tt.loca[i] = 2 * int(binary.BigEndian.Uint16(loca[2*i:]))
+		default:
+			return nil, errors.New("parseTrueType: corrupt loca table")
+		}
+	}
+
+	for i := 0; i < tt.numGlyphs; i++ {
+		if tt.loca[i] > tt.loca[i+1] || tt.loca[i+1] > len(tt.tables["glyf"]) {
+			return nil, errors.Errorf("parseTrueType: corrupt location of glyph %d", i)
+		}
+	}
+
+	return tt, nil
+}
+
+// glyph returns the glyf data of a glyph.
+func (tt *trueTypeFont) glyph(gid int) []byte {
+
+	if gid < 0 || gid >= tt.numGlyphs {
+		return nil
+	}
+
+	return tt.tables["glyf"][tt.loca[gid]:tt.loca[gid+1]]
+}
+
+// components returns the offsets of the glyph indices of the components of a composite glyph.
+func components(g []byte) []int {
+
+	if len(g) < 10 || int16(binary.BigEndian.Uint16(g)) >= 0 {
+		return nil
+	}
+
+	var offs []int
+
+	for i := 10; i+4 <= len(g); {
+
+		flags := binary.BigEndian.Uint16(g[i:])
+		offs = append(offs, i+2)
+
+		i += 4
+		if flags&0x0001 != 0 {
+			// ARG_1_AND_2_ARE_WORDS
+			i += 4
+		} else {
+			i += 2
+		}
+
+		switch {
+		case flags&0x0008 != 0:
+			// WE_HAVE_A_SCALE
+			i += 2
+		case flags&0x0040 != 0:
+			// WE_HAVE_AN_X_AND_Y_SCALE
+			i += 4
+		case flags&0x0080 != 0:
+			// WE_HAVE_A_TWO_BY_TWO
+			i += 8
+		}
+
+		if flags&0x0020 == 0 {
+			// No MORE_COMPONENTS
+			break
+		}
+	}
+
+	return offs
+}
+
+// closure adds the components of composite glyphs to gids.
+func (tt *trueTypeFont) closure(gids IntSet) {
+
+	todo := []int{}
+	for gid := range gids {
+		todo = append(todo, gid)
+	}
+
+	for len(todo) > 0 {
+		g := tt.glyph(todo[0])
+		todo = todo[1:]
+		for _, off := range components(g) {
+			c := int(binary.BigEndian.Uint16(g[off:]))
+			if c < tt.numGlyphs && !gids[c] {
+				gids[c] = true
+				todo = append(todo, c)
+			}
+		}
+	}
+}
+
+// cmapSubtable returns the cmap subtable for platformID and encodingID.
+func (tt *trueTypeFont) cmapSubtable(platformID, encodingID int) []byte {
+
+	t := tt.tables["cmap"]
+	if len(t) < 4 {
+		return nil
+	}
+
+	n := int(binary.BigEndian.Uint16(t[2:]))
+
+	for i := 0; i < n && 4+8*i+8 <= len(t); i++ {
+		r := t[4+8*i:]
+		if int(binary.BigEndian.Uint16(r)) != platformID || int(binary.BigEndian.Uint16(r[2:])) != encodingID {
+			continue
+		}
+		off := int(binary.BigEndian.Uint32(r[4:]))
+		if off < len(t) {
+			return t[off:]
+		}
+	}
+
+	return nil
+}
+
+// cmapLookup returns the glyph index for c using a cmap subtable of format 0, 4, 6 or 12.
+func cmapLookup(t []byte, c int) int {
+
+	u16 := func(off int) int {
+		if off < 0 || off+2 > len(t) {
+			return 0
+		}
+		return int(binary.BigEndian.Uint16(t[off:]))
+	}
+
+	u32 := func(off int) int {
+		if off < 0 || off+4 > len(t) {
+			return 0
+		}
+		return int(binary.BigEndian.Uint32(t[off:]))
+	}
+
+	switch u16(0) {
+
+	case 0:
+		if c < 256 && 6+c < len(t) {
+			return int(t[6+c])
+		}
+
+	case 4:
+		segX2 := u16(6)
+		for i := 0; i < segX2; i += 2 {
+			end, start := u16(14+i), u16(16+segX2+i)
+			if c > end {
+				continue
+			}
+			if c < start {
+				return 0
+			}
+			delta, ro := u16(16+2*segX2+i), u16(16+3*segX2+i)
+			if ro == 0 {
+				return (c + delta) & 0xFFFF
+			}
+			gid := u16(16 + 3*segX2 + i + ro + 2*(c-start))
+			if gid == 0 {
+				return 0
+			}
+			return (gid + delta) & 0xFFFF
+		}
+
+	case 6:
+		first, cnt := u16(6), u16(8)
+		if c >= first && c < first+cnt {
+			return u16(10 + 2*(c-first))
+		}
+
+	case 12:
+		n := u32(12)
+		for i := 0; i < n && 28+12*i <= len(t); i++ {
+			start, end, gid := u32(16+12*i), u32(20+12*i), u32(24+12*i)
+			if c >= start && c <= end {
+				return gid + c - start
+			}
+		}
+
+	}
+
+	return 0
+}
+
+// postScriptName returns the PostScript name (name ID 6) of a font.
+func (tt *trueTypeFont) postScriptName() string {
+
+	t := tt.tables["name"]
+	n, strOff := tt.u16("name", 2), tt.u16("name", 4)
+
+	for i := 0; i < n && 6+12*i+12 <= len(t); i++ {
+
+		r := t[6+12*i:]
+		platformID, nameID := int(binary.BigEndian.Uint16(r)), int(binary.BigEndian.Uint16(r[6:]))
+		l, off := int(binary.BigEndian.Uint16(r[8:])), strOff+int(binary.BigEndian.Uint16(r[10:]))
+
+		if nameID != 6 || off+l > len(t) {
+			continue
+		}
+
+		s := t[off : off+l]
+
+		switch platformID {
+		case 1:
+			return string(s)
+		case 0, 3:
+			// UTF-16BE, PostScript names are ASCII.
+			var b []byte
+			for j := 1; j < len(s); j += 2 {
+				b = append(b, s[j])
+			}
+			return string(b)
+		}
+	}
+
+	return ""
+}
+
+// hMetric returns the horizontal metrics of a glyph.
+func (tt *trueTypeFont) hMetric(gid int) (advance, lsb []byte) {
+
+	hmtx := tt.tables["hmtx"]
+	numH := tt.u16("hhea", 34)
+
+	if numH == 0 || 4*numH > len(hmtx) {
+		return []byte{0, 0}, []byte{0, 0}
+	}
+
+	if gid < numH {
+		return hmtx[4*gid : 4*gid+2], hmtx[4*gid+2 : 4*gid+4]
+	}
+
+	advance = hmtx[4*numH-4 : 4*numH-2]
+	lsb = []byte{0, 0}
+	if off := 4*numH + 2*(gid-numH); off+2 <= len(hmtx) {
+		lsb = hmtx[off : off+2]
+	}
+
+	return advance, lsb
+}
+
+// subset returns a font program containing the glyphs gids plus their components and .notdef.
+// If compact is true, glyphs are renumbered in ascending order of their original index and the new indices are returned.
+// Otherwise the glyph indices are kept and the outlines of all other glyphs are removed.
+func (tt *trueTypeFont) subset(gids IntSet, compact bool) ([]byte, map[int]int) {
+
+	gids[0] = true
+	tt.closure(gids)
+
+	var order []int
+	if compact {
+		for gid := range gids {
+			if gid < tt.numGlyphs {
+				order = append(order, gid)
+			}
+		}
+		sort.Ints(order)
+	} else {
+		for gid := 0; gid < tt.numGlyphs; gid++ {
+			order = append(order, gid)
+		}
+	}
+
+	newGID := map[int]int{}
+	for i, gid := range order {
+		newGID[gid] = i
+	}
+
+	var glyf []byte
+	loca := make([]byte, 4*(len(order)+1))
+
+	for i, gid := range order {
+
+		binary.BigEndian.PutUint32(loca[4*i:], uint32(len(glyf)))
+
+		if !gids[gid] {
+			continue
+		}
+
+		g := append([]byte(nil), tt.glyph(gid)...)
+		if compact {
+			for _, off := range components(g) {
+				binary.BigEndian.PutUint16(g[off:], uint16(newGID[int(binary.BigEndian.Uint16(g[off:]))]))
+			}
+		}
+
+		glyf = append(glyf, g...)
+		for len(glyf)%4 != 0 {
+			glyf = append(glyf, 0)
+		}
+	}
+
+	binary.BigEndian.PutUint32(loca[4*len(order):], uint32(len(glyf)))
+
+	tables := map[string][]byte{}
+	for tag, t := range tt.tables {
+		if !trueTypeDroppedTables[tag] {
+			tables[tag] = t
+		}
+	}
+
+	tables["glyf"] = glyf
+	tables["loca"] = loca
+
+	head := append([]byte(nil), tables["head"]...)
+	binary.BigEndian.PutUint32(head[8:], 0)
+	binary.BigEndian.PutUint16(head[50:], 1)
+	tables["head"] = head
+
+	if compact {
+
+		maxp := append([]byte(nil), tables["maxp"]...)
+		binary.BigEndian.PutUint16(maxp[4:], uint16(len(order)))
+		tables["maxp"] = maxp
+
+		var hmtx []byte
+		for _, gid := range order {
+			advance, lsb := tt.hMetric(gid)
+			hmtx = append(append(hmtx, advance...), lsb...)
+		}
+		tables["hmtx"] = hmtx
+
+		hhea := append([]byte(nil), tables["hhea"]...)
+		binary.BigEndian.PutUint16(hhea[34:], uint16(len(order)))
+		tables["hhea"] = hhea
+
+		// Glyph names and the cmap refer to the original glyph indices.
+		if post := tables["post"]; len(post) >= 32 {
+			post = append([]byte(nil), post[:32]...)
+			binary.BigEndian.PutUint32(post, 0x00030000)
+			tables["post"] = post
+		}
+		delete(tables, "cmap")
+	}
+
+	return writeTrueType(tables), newGID
+}
+
+func trueTypeChecksum(b []byte) uint32 {
+
+	var sum uint32
+
+	for i := 0; i < len(b); i += 4 {
+		var v [4]byte
+		copy(v[:], b[i:])
+		sum += binary.BigEndian.Uint32(v[:])
+	}
+
+	return sum
+}
+
+// writeTrueType writes a font program made of tables.
+func writeTrueType(tables map[string][]byte) []byte {
+
+	var tags []string
+	for tag := range tables {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+
+	n := len(tags)
+
+	entrySelector := 0
+	for 1<<uint(entrySelector+1) <= n {
+		entrySelector++
+	}
+	searchRange := 16 << uint(entrySelector)
+
+	b := make([]byte, 12+16*n)
+	binary.BigEndian.PutUint32(b, 0x00010000)
+	binary.BigEndian.PutUint16(b[4:], uint16(n))
+	binary.BigEndian.PutUint16(b[6:], uint16(searchRange))
+	binary.BigEndian.PutUint16(b[8:], uint16(entrySelector))
+	binary.BigEndian.PutUint16(b[10:], uint16(16*n-searchRange))
+
+	headOff := 0
+
+	for i, tag := range tags {
+
+		t := tables[tag]
+		r := b[12+16*i:]
+
+		copy(r, tag)
+		binary.BigEndian.PutUint32(r[4:], trueTypeChecksum(t))
+		binary.BigEndian.PutUint32(r[8:], uint32(len(b)))
+		binary.BigEndian.PutUint32(r[12:], uint32(len(t)))
+
+		if tag == "head" {
+			headOff = len(b)
+		}
+
+		b = append(b, t...)
+		for len(b)%4 != 0 {
+			b = append(b, 0)
+		}
+	}
+
+	if headOff > 0 {
+		binary.BigEndian.PutUint32(b[headOff+8:], 0xB1B0AFBA-trueTypeChecksum(b))
+	}
+
+	return b
+}
@@ -183,3 +183,25 @@ func HexLiteralToString(hexString string) (string, error) {
 	// if no acceptable UTF16 encoding found, just return decoded hexstring.
 	return string(b), nil
 }
+
+// NewTextString returns s as a PDF text string object,
+// a string literal for ASCII text and a UTF16BE encoded hex literal otherwise.
+func NewTextString(s string) (PDFObject, error) {
+
+	for _, r := range s {
+		if r >= utf8.RuneSelf {
+			b := []byte{0xFE, 0xFF}
+			for _, u := range utf16.Encode([]rune(s)) {
+				b = append(b, byte(u>>8), byte(u))
+			}
+			return PDFHexLiteral(hex.EncodeToString(b)), nil
+		}
+	}
+
+	s1, err := Escape(s)
+	if err != nil {
+		return nil, err
+	}
+
+	return PDFStringLiteral(*s1), nil
+}
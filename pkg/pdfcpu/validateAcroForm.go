@@ -117,133 +117,164 @@ func validateAppearanceDict(xRefTable *XRefTable, obj PDFObject) error {
 	return nil
 }
 
-func validateAcroFieldDictEntries(xRefTable *XRefTable, dict *PDFDict, terminalNode bool, inFieldType *PDFName) (outFieldType *PDFName, err error) {
+func validateAcroFieldDictEntries(xRefTable *XRefTable, dict *PDFDict, terminalNode bool, inherited fieldAttrs) error {
 
 	dictName := "acroFieldDict"
 
-	// FT: name, Btn,Tx,Ch,Sig
+	// FT: name, Btn,Tx,Ch,Sig, required for terminal fields unless inherited.
 	validate := func(s string) bool { return memberOf(s, []string{"Btn", "Tx", "Ch", "Sig"}) }
-	fieldType, err := validateNameEntry(xRefTable, dict, dictName, "FT", terminalNode && inFieldType == nil, V10, validate)
+	_, err := validateNameEntry(xRefTable, dict, dictName, "FT", terminalNode && inherited.ft == "", V10, validate)
 	if err != nil {
-		return nil, err
-	}
-
-	if fieldType != nil {
-		outFieldType = fieldType
+		return err
 	}
 
 	// Parent, required if this is a child in the field hierarchy.
 	_, err = validateIndRefEntry(xRefTable, dict, dictName, "Parent", OPTIONAL, V10)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	// T, optional, text string
 	_, err = validateStringEntry(xRefTable, dict, dictName, "T", OPTIONAL, V10, nil)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	// TU, optional, text string, since V1.3
 	_, err = validateStringEntry(xRefTable, dict, dictName, "TU", OPTIONAL, V13, nil)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	// TM, optional, text string, since V1.3
 	_, err = validateStringEntry(xRefTable, dict, dictName, "TM", OPTIONAL, V13, nil)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	// Ff, optional, integer
 	_, err = validateIntegerEntry(xRefTable, dict, dictName, "Ff", OPTIONAL, V10, nil)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	// V, optional, various
 	_, err = validateEntry(xRefTable, dict, dictName, "V", OPTIONAL, V10)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	// DV, optional, various
 	_, err = validateEntry(xRefTable, dict, dictName, "DV", OPTIONAL, V10)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	// AA, optional, dict, since V1.2
 	err = validateAdditionalActions(xRefTable, dict, dictName, "AA", OPTIONAL, V14, "fieldOrAnnot")
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	return outFieldType, nil
+	return nil
+}
+
+// acroField is a node of the field tree along with its fully qualified name
+// and its field attributes including those inherited from its ancestors, see 12.7.3.1.
+type acroField struct {
+	indRef PDFIndirectRef
+	dict   *PDFDict
+	name   string
+	kids   *PDFArray
+	fieldAttrs
+	inherited fieldAttrs
 }
 
-func validateAcroFieldDict(xRefTable *XRefTable, indRef *PDFIndirectRef, inFieldType *PDFName) error {
+// walkAcroFields calls visit for the field rooted at indRef and descends into its kids as long as visit returns true.
+// name and attrs are the fully qualified name and the field attributes of the parent field.
+func walkAcroFields(xRefTable *XRefTable, indRef PDFIndirectRef, name string, attrs fieldAttrs, visit func(f *acroField) (bool, error)) error {
 
-	dict, err := xRefTable.DereferenceDict(*indRef)
+	dict, err := xRefTable.DereferenceDict(indRef)
 	if err != nil || dict == nil {
 		return err
 	}
 
-	if pdfObject, ok := dict.Find("Kids"); ok {
+	f := &acroField{indRef: indRef, dict: dict, name: name, fieldAttrs: attrs, inherited: attrs}
+	f.update(xRefTable, dict)
 
-		// dict represents a non terminal field.
-		if dict.Subtype() != nil && *dict.Subtype() == "Widget" {
-			return errors.New("validateAcroFieldDict: non terminal field can not be widget annotation")
+	if o, found := dict.Find("T"); found {
+		s, err := fieldText(xRefTable, o)
+		if err != nil {
+			return err
+		}
+		if f.name != "" {
+			f.name += "."
 		}
+		f.name += s
+	}
 
-		// Write field entries.
-		var xInFieldType *PDFName
-		xInFieldType, err = validateAcroFieldDictEntries(xRefTable, dict, false, inFieldType)
+	if o, found := dict.Find("Kids"); found {
+		f.kids, err = xRefTable.DereferenceArray(o)
 		if err != nil {
 			return err
 		}
+	}
+
+	descend, err := visit(f)
+	if err != nil || !descend || f.kids == nil {
+		return err
+	}
+
+	for _, o := range *f.kids {
+
+		kidIndRef, ok := o.(PDFIndirectRef)
+		if !ok {
+			return errors.New("walkAcroFields: corrupt kids array: entries must be indirect reference")
+		}
 
-		// Recurse over kids.
-		var arr *PDFArray
-		arr, err = xRefTable.DereferenceArray(pdfObject)
-		if err != nil || arr == nil {
+		err = walkAcroFields(xRefTable, kidIndRef, f.name, f.fieldAttrs, visit)
+		if err != nil {
 			return err
 		}
+	}
 
-		for _, value := range *arr {
+	return nil
+}
 
-			indRef, ok := value.(PDFIndirectRef)
-			if !ok {
-				return errors.New("validateAcroFieldDict: corrupt kids array: entries must be indirect reference")
-			}
+func validateAcroFieldDict(xRefTable *XRefTable, indRef PDFIndirectRef) error {
 
-			err = validateAcroFieldDict(xRefTable, &indRef, xInFieldType)
-			if err != nil {
-				return err
+	return walkAcroFields(xRefTable, indRef, "", fieldAttrs{}, func(f *acroField) (bool, error) {
+
+		dict := f.dict
+
+		if _, found := dict.Find("Kids"); found {
+
+			// dict represents a non terminal field.
+			if dict.Subtype() != nil && *dict.Subtype() == "Widget" {
+				return false, errors.New("validateAcroFieldDict: non terminal field can not be widget annotation")
 			}
 
+			// Validate field entries and recurse over kids.
+			return true, validateAcroFieldDictEntries(xRefTable, dict, false, f.inherited)
 		}
 
-		return nil
-	}
-
-	// dict represents a terminal field and must have Subtype "Widget"
-	_, err = validateNameEntry(xRefTable, dict, "acroFieldDict", "Subtype", REQUIRED, V10, func(s string) bool { return s == "Widget" })
-	if err != nil {
-		return err
-	}
+		// dict represents a terminal field and must have Subtype "Widget"
+		_, err := validateNameEntry(xRefTable, dict, "acroFieldDict", "Subtype", REQUIRED, V10, func(s string) bool { return s == "Widget" })
+		if err != nil {
+			return false, err
+		}
 
-	// Validate field dict entries.
-	_, err = validateAcroFieldDictEntries(xRefTable, dict, true, inFieldType)
-	if err != nil {
-		return err
-	}
+		// Validate field dict entries.
+		err = validateAcroFieldDictEntries(xRefTable, dict, true, f.inherited)
+		if err != nil {
+			return false, err
+		}
 
-	// Validate widget annotation - Validation of AA redundant because of merged acrofield with widget annotation.
-	_, err = validateAnnotationDict(xRefTable, dict)
+		// Validate widget annotation - Validation of AA redundant because of merged acrofield with widget annotation.
+		_, err = validateAnnotationDict(xRefTable, dict)
 
-	return err
+		return false, err
+	})
 }
 
 func validateAcroFormFields(xRefTable *XRefTable, obj PDFObject) error {
@@ -260,7 +291,7 @@ func validateAcroFormFields(xRefTable *XRefTable, obj PDFObject) error {
 			return errors.New("validateAcroFormFields: corrupt form field array entry")
 		}
 
-		err = validateAcroFieldDict(xRefTable, &indRef, nil)
+		err = validateAcroFieldDict(xRefTable, indRef)
 		if err != nil {
 			return err
 		}
@@ -0,0 +1,164 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"github.com/hhrutter/pdfcpu/pkg/log"
+	"github.com/hhrutter/pdfcpu/pkg/pdfcpu/content"
+	"github.com/pkg/errors"
+)
+
+// contentResources provides the named resources of a resource dict to the content validator.
+type contentResources struct {
+	xRefTable *XRefTable
+	dict      *PDFDict
+}
+
+// Has returns true if the resource dict has an entry for category and name.
+func (r contentResources) Has(category, name string) bool {
+
+	if r.dict == nil {
+		return false
+	}
+
+	d, err := r.xRefTable.DereferenceDict(r.dict.Dict[category])
+	if err != nil || d == nil {
+		return false
+	}
+
+	_, found := d.Find(name)
+
+	return found
+}
+
+// formXObject returns the content and the resources of the form XObject invoked by the operator "Do name".
+func formXObject(xRefTable *XRefTable, resources *PDFDict, name string) (objNr int, b []byte, formResources *PDFDict, err error) {
+
+	if resources == nil {
+		return 0, nil, nil, nil
+	}
+
+	xobjs, err := xRefTable.DereferenceDict(resources.Dict["XObject"])
+	if err != nil || xobjs == nil {
+		return 0, nil, nil, err
+	}
+
+	o, _ := xobjs.Find(name)
+
+	indRef, ok := o.(PDFIndirectRef)
+	if !ok {
+		return 0, nil, nil, nil
+	}
+
+	sd, err := xRefTable.DereferenceStreamDict(indRef)
+	if err != nil || sd == nil {
+		return 0, nil, nil, err
+	}
+
+	if st := sd.Subtype(); st == nil || *st != "Form" {
+		return 0, nil, nil, nil
+	}
+
+	formResources, err = xRefTable.DereferenceDict(sd.Dict["Resources"])
+	if err != nil {
+		return 0, nil, nil, err
+	}
+
+	if formResources == nil {
+		// Forms without resources use the resources of the invoking content stream.
+		formResources = resources
+	}
+
+	return indRef.ObjectNumber.Value(), streamContent(xRefTable, indRef), formResources, nil
+}
+
+func validateContentStream(xRefTable *XRefTable, b []byte, resources *PDFDict, visited IntSet) error {
+
+	c, err := content.Parse(b)
+	if err != nil {
+		return err
+	}
+
+	err = c.Validate(contentResources{xRefTable, resources})
+	if err != nil {
+		return err
+	}
+
+	// Validate invoked form XObjects.
+	for _, op := range c.Operations {
+
+		if op.Operator != "Do" {
+			continue
+		}
+
+		objNr, b, formResources, err := formXObject(xRefTable, resources, string(op.Operands[0].(content.Name)))
+		if err != nil {
+			return err
+		}
+
+		if b == nil || visited[objNr] {
+			continue
+		}
+
+		visited[objNr] = true
+
+		err = validateContentStream(xRefTable, b, formResources, visited)
+		if err != nil {
+			return errors.Wrapf(err, "form XObject %d", objNr)
+		}
+	}
+
+	return nil
+}
+
+// validateContentStreams validates the content of all pages including form XObjects.
+func validateContentStreams(xRefTable *XRefTable) error {
+
+	log.Debug.Println("*** validateContentStreams begin ***")
+
+	visited := IntSet{}
+
+	for p := 1; p <= xRefTable.PageCount; p++ {
+
+		pageDict, inhPAttrs, err := xRefTable.PageDict(p)
+		if err != nil {
+			return err
+		}
+
+		if pageDict == nil {
+			continue
+		}
+
+		b, err := pageContent(xRefTable, pageDict)
+		if err != nil {
+			return err
+		}
+
+		if b == nil {
+			continue
+		}
+
+		err = validateContentStream(xRefTable, b, inhPAttrs.resources, visited)
+		if err != nil {
+			return errors.Wrapf(err, "validateContentStreams: page %d", p)
+		}
+	}
+
+	log.Debug.Println("*** validateContentStreams end ***")
+
+	return nil
+}
@@ -122,7 +122,7 @@ func validateDestination(xRefTable *XRefTable, obj PDFObject) error {
 	case PDFName:
 		// no further processing.
 
-	case PDFStringLiteral:
+	case PDFStringLiteral, PDFHexLiteral:
 		// no further processing.
 
 	case PDFDict:
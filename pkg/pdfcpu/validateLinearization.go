@@ -0,0 +1,473 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"github.com/hhrutter/pdfcpu/pkg/log"
+	"github.com/pkg/errors"
+)
+
+// bitReader reads hint table items from a bit stream, see F.4.
+type bitReader struct {
+	buf []byte
+	pos int // bit position
+}
+
+func (r *bitReader) readBits(nbits int) (int, error) {
+
+	if nbits > 32 {
+		return 0, errors.Errorf("validateLinearization: corrupt hint table - item of %d bits", nbits)
+	}
+
+	v := 0
+
+	for i := 0; i < nbits; i++ {
+		if r.pos/8 >= len(r.buf) {
+			return 0, errors.New("validateLinearization: corrupt hint table - unexpected end of data")
+		}
+		b := r.buf[r.pos/8] >> (7 - uint(r.pos%8)) & 1
+		v = v<<1 | int(b)
+		r.pos++
+	}
+
+	return v, nil
+}
+
+// readItems reads one item of nbits bits for n entries.
+// Each item of a hint table starts at a byte boundary.
+func (r *bitReader) readItems(n, nbits int) ([]int, error) {
+
+	vals := make([]int, n)
+
+	for i := range vals {
+		v, err := r.readBits(nbits)
+		if err != nil {
+			return nil, err
+		}
+		vals[i] = v
+	}
+
+	r.align()
+
+	return vals, nil
+}
+
+func (r *bitReader) align() {
+	r.pos = (r.pos + 7) / 8 * 8
+}
+
+// readHeader reads a sequence of header items of given bit sizes.
+func (r *bitReader) readHeader(nbits ...int) ([]int, error) {
+
+	vals := make([]int, len(nbits))
+
+	for i, n := range nbits {
+		v, err := r.readBits(n)
+		if err != nil {
+			return nil, err
+		}
+		vals[i] = v
+	}
+
+	return vals, nil
+}
+
+// linearizationInfo holds the hint stream locations needed to translate hint table offsets into file offsets.
+type linearizationInfo struct {
+	ctx        *PDFContext
+	hintOffset int64
+	hintLength int64
+}
+
+// fileOffset returns the file offset for a hint table offset.
+// Hint table offsets are given as if the primary hint stream were not present, see F.4.
+func (li linearizationInfo) fileOffset(off int64) int64 {
+	if off >= li.hintOffset {
+		return off + li.hintLength
+	}
+	return off
+}
+
+func (li linearizationInfo) objectOffset(objNr int, objType string) (int64, error) {
+
+	entry, found := li.ctx.FindTableEntryLight(objNr)
+	if !found || entry.Free || entry.Compressed || entry.Offset == nil {
+		return 0, errors.Errorf("validateLinearization: %s obj #%d not found", objType, objNr)
+	}
+
+	return *entry.Offset, nil
+}
+
+func (li linearizationInfo) checkObjectOffset(objNr int, off int64, objType string) error {
+
+	offset, err := li.objectOffset(objNr, objType)
+	if err != nil {
+		return err
+	}
+
+	if offset != li.fileOffset(off) {
+		return errors.Errorf("validateLinearization: %s obj #%d at offset %d, hint table expects %d", objType, objNr, offset, li.fileOffset(off))
+	}
+
+	return nil
+}
+
+// hintStream returns the decoded hint stream located at offset.
+func hintStream(ctx *PDFContext, offset int64) (*PDFStreamDict, error) {
+
+	for _, entry := range ctx.Table {
+
+		if entry.Free || entry.Compressed || entry.Offset == nil || *entry.Offset != offset {
+			continue
+		}
+
+		sd, ok := entry.Object.(PDFStreamDict)
+		if !ok {
+			break
+		}
+
+		err := decodeStream(&sd)
+		if err != nil {
+			return nil, err
+		}
+
+		return &sd, nil
+	}
+
+	return nil, errors.Errorf("validateLinearization: no hint stream at offset %d", offset)
+}
+
+func linearizationParmDict(ctx *PDFContext) (*PDFDict, error) {
+
+	for objNr := range ctx.LinearizationObjs {
+		d, err := ctx.DereferenceDict(*NewPDFIndirectRef(objNr, 0))
+		if err != nil {
+			return nil, err
+		}
+		if d != nil && d.IsLinearizationParmDict() {
+			return d, nil
+		}
+	}
+
+	return nil, errors.New("validateLinearization: missing linearization parameter dict")
+}
+
+// validatePageOffsetHintTable checks the location and the object numbers of all pages, see Table F.3 and F.4.
+func (li linearizationInfo) validatePageOffsetHintTable(data []byte, pages []int, sharedGroups int) error {
+
+	r := &bitReader{buf: data}
+
+	h, err := r.readHeader(32, 32, 16, 32, 16, 32, 16, 32, 16, 16, 16, 16, 16)
+	if err != nil {
+		return err
+	}
+
+	n := len(pages)
+
+	objCounts, err := r.readItems(n, h[2])
+	if err != nil {
+		return err
+	}
+
+	pageLengths, err := r.readItems(n, h[4])
+	if err != nil {
+		return err
+	}
+
+	sharedCounts, err := r.readItems(n, h[9])
+	if err != nil {
+		return err
+	}
+
+	// The shared object identifiers of all pages form a single item.
+	for i := 0; i < n; i++ {
+		for j := 0; j < sharedCounts[i]; j++ {
+			id, err := r.readBits(h[10])
+			if err != nil {
+				return err
+			}
+			if id >= sharedGroups {
+				return errors.Errorf("validateLinearization: page %d references undefined shared object group %d", i+1, id)
+			}
+		}
+	}
+	r.align()
+
+	off := int64(h[1])
+	objNr := 1
+
+	for i, pageObjNr := range pages {
+
+		if i > 0 && pageObjNr != objNr {
+			return errors.Errorf("validateLinearization: page %d is obj #%d, hint table expects obj #%d", i+1, pageObjNr, objNr)
+		}
+
+		offset, err := li.objectOffset(pageObjNr, "page")
+		if err != nil {
+			return err
+		}
+
+		// The page dict is not necessarily the first object of a page.
+		start, end := li.fileOffset(off), li.fileOffset(off+int64(h[3]+pageLengths[i]))
+		if offset < start || offset >= end {
+			return errors.Errorf("validateLinearization: page %d obj #%d at offset %d, hint table expects %d-%d", i+1, pageObjNr, offset, start, end)
+		}
+
+		off += int64(h[3] + pageLengths[i])
+
+		if i > 0 {
+			objNr += h[0] + objCounts[i]
+		}
+	}
+
+	return nil
+}
+
+// validateSharedObjectHintTable checks the location of all shared object groups following the first page, see Table F.5 and F.6.
+// It returns the number of shared object groups.
+func (li linearizationInfo) validateSharedObjectHintTable(data []byte) (int, error) {
+
+	r := &bitReader{buf: data}
+
+	h, err := r.readHeader(32, 32, 32, 32, 16, 32, 16)
+	if err != nil {
+		return 0, err
+	}
+
+	firstPageGroups, groups := h[2], h[3]
+
+	if firstPageGroups > groups {
+		return 0, errors.New("validateLinearization: corrupt shared object hint table")
+	}
+
+	groupLengths, err := r.readItems(groups, h[6])
+	if err != nil {
+		return 0, err
+	}
+
+	md5, err := r.readItems(groups, 1)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, m := range md5 {
+		if m == 1 {
+			// Skip the MD5 signature.
+			r.pos += 128
+		}
+	}
+	r.align()
+
+	objCounts, err := r.readItems(groups, h[4])
+	if err != nil {
+		return 0, err
+	}
+
+	objNr, off := h[0], int64(h[1])
+
+	for i := firstPageGroups; i < groups; i++ {
+
+		err = li.checkObjectOffset(objNr, off, "shared")
+		if err != nil {
+			return 0, err
+		}
+
+		objNr += objCounts[i] + 1
+		off += int64(h[5] + groupLengths[i])
+	}
+
+	return groups, nil
+}
+
+// validateGenericHintTable checks the location of a group of objects, see Table F.11.
+func (li linearizationInfo) validateGenericHintTable(data []byte, indRef *PDFIndirectRef, objType string) error {
+
+	r := &bitReader{buf: data}
+
+	h, err := r.readHeader(32, 32, 32, 32)
+	if err != nil {
+		return err
+	}
+
+	if indRef == nil || indRef.ObjectNumber.Value() != h[0] {
+		return errors.Errorf("validateLinearization: %s hint table refers to obj #%d", objType, h[0])
+	}
+
+	offset, err := li.objectOffset(h[0], objType)
+	if err != nil {
+		return err
+	}
+
+	// Acrobat records the location of the second object of the outline hierarchy
+	// so any location within the group is accepted.
+	loc := li.fileOffset(int64(h[1]))
+	if loc < offset || loc >= offset+int64(h[3]) {
+		return errors.Errorf("validateLinearization: %s obj #%d at offset %d, hint table expects %d", objType, h[0], offset, loc)
+	}
+
+	return nil
+}
+
+// hintTableData returns the hint table data starting at offset entry.
+func hintTableData(hints PDFDict, entry string, data []byte) ([]byte, error) {
+
+	off := hints.IntEntry(entry)
+	if off == nil {
+		return nil, nil
+	}
+
+	if *off < 0 || *off >= len(data) {
+		return nil, errors.Errorf("validateLinearization: corrupt hint stream entry %s", entry)
+	}
+
+	return data[*off:], nil
+}
+
+func validateLinearization(ctx *PDFContext) error {
+
+	d, err := linearizationParmDict(ctx)
+	if err != nil {
+		return err
+	}
+
+	fileSize := d.Int64Entry("L")
+	if fileSize == nil {
+		return errors.New("validateLinearization: missing entry L")
+	}
+
+	if *fileSize != ctx.Read.FileSize {
+		log.Info.Println("validateLinearization: file has been updated since linearization")
+		return nil
+	}
+
+	root, err := ctx.Pages()
+	if err != nil {
+		return err
+	}
+
+	l := &linearization{ctx: ctx, isPage: IntSet{}}
+	err = l.pageDictObjNrs(root.ObjectNumber.Value(), IntSet{})
+	if err != nil {
+		return err
+	}
+
+	if n := d.IntEntry("N"); n == nil || *n != len(l.pages) {
+		return errors.Errorf("validateLinearization: entry N does not match page count %d", len(l.pages))
+	}
+
+	if o := d.IntEntry("O"); o == nil || *o != l.pages[0] {
+		return errors.Errorf("validateLinearization: entry O does not match first page obj #%d", l.pages[0])
+	}
+
+	for _, k := range []string{"E", "T"} {
+		if v := d.Int64Entry(k); v == nil || *v <= 0 || *v >= *fileSize {
+			return errors.Errorf("validateLinearization: corrupt entry %s", k)
+		}
+	}
+
+	hints := ctx.OffsetPrimaryHintTable
+	h := d.PDFArrayEntry("H")
+	if hints == nil || h == nil {
+		return errors.New("validateLinearization: missing entry H")
+	}
+
+	length, ok := (*h)[1].(PDFInteger)
+	if !ok {
+		return errors.New("validateLinearization: corrupt entry H")
+	}
+
+	li := linearizationInfo{ctx: ctx, hintOffset: *hints, hintLength: int64(length.Value())}
+
+	sd, err := hintStream(ctx, *hints)
+	if err != nil {
+		return err
+	}
+
+	hintDict := sd.PDFDict
+
+	// The data of an overflow hint stream continues the primary hint stream.
+	data := append([]byte{}, sd.Content...)
+
+	if ctx.OffsetOverflowHintTable != nil {
+		osd, err := hintStream(ctx, *ctx.OffsetOverflowHintTable)
+		if err != nil {
+			return err
+		}
+		data = append(data, osd.Content...)
+	}
+
+	sharedData, err := hintTableData(hintDict, "S", data)
+	if err != nil {
+		return err
+	}
+	if sharedData == nil {
+		return errors.New("validateLinearization: missing shared object hint table")
+	}
+
+	groups, err := li.validateSharedObjectHintTable(sharedData)
+	if err != nil {
+		return err
+	}
+
+	err = li.validatePageOffsetHintTable(data, l.pages, groups)
+	if err != nil {
+		return err
+	}
+
+	outlineData, err := hintTableData(hintDict, "O", data)
+	if err != nil {
+		return err
+	}
+	if outlineData != nil {
+		rootDict, err := ctx.Catalog()
+		if err != nil {
+			return err
+		}
+		err = li.validateGenericHintTable(outlineData, rootDict.IndirectRefEntry("Outlines"), "outlines")
+		if err != nil {
+			return err
+		}
+	}
+
+	infoData, err := hintTableData(hintDict, "I", data)
+	if err != nil {
+		return err
+	}
+	if infoData != nil {
+		return li.validateGenericHintTable(infoData, ctx.Info, "info")
+	}
+
+	return nil
+}
+
+// ValidateLinearization validates the linearization parameter dict and the hint tables of a linearized file as read.
+// Files updated since linearization are not considered linearized anymore.
+func ValidateLinearization(ctx *PDFContext) error {
+
+	if ctx.Read == nil || !ctx.Read.Linearized {
+		return nil
+	}
+
+	err := validateLinearization(ctx)
+
+	if err != nil && ctx.XRefTable.ValidationMode == ValidationRelaxed {
+		log.Info.Printf("%v\n", err)
+		return nil
+	}
+
+	return err
+}
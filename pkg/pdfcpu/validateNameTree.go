@@ -560,11 +560,13 @@ func validateNameTreeDictNamesEntry(xRefTable *XRefTable, dict *PDFDict, name st
 
 			s, ok := obj.(PDFStringLiteral)
 			if !ok {
-				s, ok := obj.(PDFHexLiteral)
-				if !ok {
+				if _, ok := obj.(PDFHexLiteral); !ok {
 					return "", "", errors.Errorf("validateNameTreeDictNamesEntry: corrupt key <%v>\n", obj)
 				}
-				key = s.Value()
+				// Keys get written back as string literals.
+				if key, err = nameTreeKey(obj); err != nil {
+					return "", "", err
+				}
 			} else {
 				key = s.Value()
 			}
@@ -33,6 +33,14 @@ func ValidateXRefTable(xRefTable *XRefTable) error {
 		return err
 	}
 
+	// Validate page content streams.
+	if xRefTable.ValidationMode == ValidationStrict {
+		err = validateContentStreams(xRefTable)
+		if err != nil {
+			return err
+		}
+	}
+
 	// Validate document information dictionary.
 	err = validateDocumentInfoObject(xRefTable)
 	if err != nil {
@@ -0,0 +1,468 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+// Verification of digital signatures, see 12.8.1.
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/hhrutter/pdfcpu/pkg/log"
+	"github.com/pkg/errors"
+)
+
+// SignatureResult represents the outcome of verifying a signature field.
+type SignatureResult struct {
+	FieldName   string    // fully qualified name of the signature field.
+	SubFilter   string    // signature format.
+	Signer      string    // common name of the signer certificate.
+	SigningTime time.Time // signing time, may be zero.
+	Timestamped bool      // SigningTime is attested by a trusted timestamp token instead of claimed by the signer.
+	Reason      string    // optional reason for signing.
+
+	Intact  bool // the signed bytes are unmodified.
+	Valid   bool // the signature value verifies against the signer certificate.
+	Trusted bool // the signer certificate chains up to the trust store.
+
+	WholeDocument bool // the signature covers the complete file.
+	Revision      int  // the revision covered by the signature.
+	Revisions     int  // the number of revisions of the file.
+
+	Problem string // the reason for a failed verification.
+}
+
+// Verified returns true if sr is an intact and valid signature based on a trusted certificate.
+func (sr SignatureResult) Verified() bool {
+	return sr.Intact && sr.Valid && sr.Trusted
+}
+
+func (sr SignatureResult) String() string {
+
+	var sb strings.Builder
+
+	fmt.Fprintf(&sb, "%s:", sr.FieldName)
+
+	if sr.Verified() {
+		sb.WriteString(" verified")
+	} else {
+		sb.WriteString(" NOT verified")
+	}
+
+	if sr.Problem != "" {
+		fmt.Fprintf(&sb, " (%s)", sr.Problem)
+	}
+
+	if sr.Signer != "" {
+		fmt.Fprintf(&sb, "\n  signed by: %s", sr.Signer)
+	}
+
+	if !sr.SigningTime.IsZero() {
+		fmt.Fprintf(&sb, "\n  signed at: %s", sr.SigningTime.Format(time.RFC3339))
+		if sr.Timestamped {
+			sb.WriteString(" (timestamp)")
+		} else {
+			sb.WriteString(" (claimed by signer)")
+		}
+	}
+
+	if sr.Reason != "" {
+		fmt.Fprintf(&sb, "\n  reason:    %s", sr.Reason)
+	}
+
+	fmt.Fprintf(&sb, "\n  format:    %s", sr.SubFilter)
+	fmt.Fprintf(&sb, "\n  integrity: %t, signature: %t, trusted: %t", sr.Intact, sr.Valid, sr.Trusted)
+
+	if sr.WholeDocument {
+		sb.WriteString("\n  covers:    whole document")
+	} else if sr.Revision > 0 {
+		fmt.Fprintf(&sb, "\n  covers:    revision %d of %d", sr.Revision, sr.Revisions)
+	}
+
+	return sb.String()
+}
+
+// sigField is a signature field along with its value, the signature dict.
+type sigField struct {
+	name string
+	dict *PDFDict
+}
+
+// collectSigFields walks the field tree rooted at the field dicts in arr.
+func collectSigFields(ctx *PDFContext, arr *PDFArray, parentName string, inFieldType *string, fields []sigField) ([]sigField, error) {
+
+	xRefTable := ctx.XRefTable
+
+	for _, obj := range *arr {
+
+		dict, err := xRefTable.DereferenceDict(obj)
+		if err != nil {
+			return nil, err
+		}
+
+		if dict == nil {
+			continue
+		}
+
+		name := parentName
+		if o, found := dict.Find("T"); found {
+			t, err := textString(ctx, o)
+			if err != nil {
+				return nil, err
+			}
+			if name != "" {
+				name += "."
+			}
+			name += t
+		}
+
+		// The field type is inheritable.
+		fieldType := inFieldType
+		if ft := dict.NameEntry("FT"); ft != nil {
+			fieldType = ft
+		}
+
+		if o, found := dict.Find("Kids"); found {
+
+			kids, err := xRefTable.DereferenceArray(o)
+			if err != nil {
+				return nil, err
+			}
+
+			if kids != nil {
+				if fields, err = collectSigFields(ctx, kids, name, fieldType, fields); err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		if fieldType == nil || *fieldType != "Sig" {
+			continue
+		}
+
+		o, found := dict.Find("V")
+		if !found {
+			// Unsigned signature field.
+			continue
+		}
+
+		d, err := xRefTable.DereferenceDict(o)
+		if err != nil {
+			return nil, err
+		}
+
+		if d != nil {
+			fields = append(fields, sigField{name: name, dict: d})
+		}
+	}
+
+	return fields, nil
+}
+
+// signatureFields returns all signed signature fields of the interactive form.
+func signatureFields(ctx *PDFContext) ([]sigField, error) {
+
+	xRefTable := ctx.XRefTable
+
+	rootDict, err := xRefTable.Catalog()
+	if err != nil {
+		return nil, err
+	}
+
+	obj, found := rootDict.Find("AcroForm")
+	if !found || obj == nil {
+		return nil, nil
+	}
+
+	d, err := xRefTable.DereferenceDict(obj)
+	if err != nil || d == nil {
+		return nil, err
+	}
+
+	obj, found = d.Find("Fields")
+	if !found || obj == nil {
+		return nil, nil
+	}
+
+	arr, err := xRefTable.DereferenceArray(obj)
+	if err != nil || arr == nil {
+		return nil, err
+	}
+
+	return collectSigFields(ctx, arr, "", nil, nil)
+}
+
+// byteRange returns the signed byte ranges of a signature dict.
+// Only the usual layout made up of the bytes before and after /Contents is supported.
+func byteRange(xRefTable *XRefTable, d *PDFDict, fileSize int64) ([4]int64, error) {
+
+	var br [4]int64
+
+	obj, found := d.Find("ByteRange")
+	if !found {
+		return br, errors.New("missing ByteRange")
+	}
+
+	arr, err := xRefTable.DereferenceArray(obj)
+	if err != nil || arr == nil || len(*arr) != 4 {
+		return br, errors.New("unsupported ByteRange")
+	}
+
+	for i, o := range *arr {
+		n, err := xRefTable.DereferenceInteger(o)
+		if err != nil || n == nil || *n < 0 {
+			return br, errors.New("corrupt ByteRange")
+		}
+		br[i] = int64(*n)
+	}
+
+	if br[0] != 0 || br[1] >= br[2] || br[2]+br[3] > fileSize {
+		return br, errors.New("corrupt ByteRange")
+	}
+
+	return br, nil
+}
+
+// signatureContents returns the DER encoded signature located in the gap between the signed byte ranges.
+// /Contents is never encrypted (7.6.2) so it is taken from the file rather than the parsed object.
+func signatureContents(b []byte, br [4]int64) ([]byte, error) {
+
+	gap := b[br[1]:br[2]]
+
+	if len(gap) < 2 || gap[0] != '<' || gap[len(gap)-1] != '>' {
+		return nil, errors.New("ByteRange does not exclude exactly the signature")
+	}
+
+	der, err := hex.DecodeString(string(gap[1 : len(gap)-1]))
+	if err != nil {
+		return nil, errors.New("corrupt Contents")
+	}
+
+	return der, nil
+}
+
+// revisionCount returns the number of revisions contained in b.
+// Every revision ends with an end-of-file marker, see 7.5.6.
+func revisionCount(b []byte) int {
+	ends, _ := revisionEnds(bytes.NewReader(b), int64(len(b)))
+	return len(ends)
+}
+
+// isWholeDocument returns true if nothing but whitespace follows the signed bytes.
+func isWholeDocument(b []byte, end int64) bool {
+	return len(bytes.TrimRight(b[end:], "\x00\t\n\f\r ")) == 0
+}
+
+// verifyChain checks the signer certificate of sd for usage at time t against the trust store roots.
+func verifyChain(sd *signerData, roots *x509.CertPool, t time.Time, usage x509.ExtKeyUsage) error {
+
+	if roots == nil {
+		return errors.New("missing trust store")
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, c := range sd.certs {
+		if c != sd.signer {
+			intermediates.AddCert(c)
+		}
+	}
+
+	opts := x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   t,
+		KeyUsages:     []x509.ExtKeyUsage{usage},
+	}
+
+	_, err := sd.signer.Verify(opts)
+
+	return err
+}
+
+// verifiedSigningTime returns the signing time attested by the timestamp token of sd.
+// The time claimed by the signer in the signed attributes does not qualify.
+func verifiedSigningTime(sd *signerData, roots *x509.CertPool) (time.Time, bool) {
+
+	tsd, t, err := sd.timestampToken()
+	if err != nil {
+		log.Debug.Printf("verifiedSigningTime: %v\n", err)
+		return t, false
+	}
+
+	if tsd == nil {
+		return t, false
+	}
+
+	if err = verifyChain(tsd, roots, t, x509.ExtKeyUsageTimeStamping); err != nil {
+		log.Debug.Printf("verifiedSigningTime: timestamp authority: %v\n", err)
+		return t, false
+	}
+
+	return t, true
+}
+
+// verifySignature checks the signature sf against the file content b.
+func verifySignature(ctx *PDFContext, sf sigField, b []byte, roots *x509.CertPool) SignatureResult {
+
+	d := sf.dict
+
+	sr := SignatureResult{FieldName: sf.name, Revisions: revisionCount(b)}
+
+	if s := d.NameEntry("SubFilter"); s != nil {
+		sr.SubFilter = *s
+	}
+
+	if o, found := d.Find("Reason"); found {
+		sr.Reason, _ = textString(ctx, o)
+	}
+
+	fileSize := int64(len(b))
+
+	br, err := byteRange(ctx.XRefTable, d, fileSize)
+	if err != nil {
+		sr.Problem = err.Error()
+		return sr
+	}
+
+	end := br[2] + br[3]
+	sr.WholeDocument = isWholeDocument(b, end)
+	sr.Revision = revisionCount(b[:end])
+
+	der, err := signatureContents(b, br)
+	if err != nil {
+		sr.Problem = err.Error()
+		return sr
+	}
+
+	sd, err := parseSignedData(der)
+	if err != nil {
+		sr.Problem = err.Error()
+		return sr
+	}
+
+	sr.Signer = sd.signer.Subject.CommonName
+	sr.SigningTime = sd.signingTime()
+
+	signed := make([]byte, 0, br[1]+br[3])
+	signed = append(signed, b[:br[1]]...)
+	signed = append(signed, b[br[2]:end]...)
+
+	switch sr.SubFilter {
+
+	case "adbe.pkcs7.detached", "ETSI.CAdES.detached":
+		sr.Intact = sd.digestMatches(signed)
+
+	case "adbe.pkcs7.sha1":
+		// The encapsulated content is the SHA-1 digest of the signed bytes.
+		content, err := sd.content()
+		if err != nil {
+			sr.Problem = err.Error()
+			return sr
+		}
+		h := sha1.Sum(signed)
+		sr.Intact = bytes.Equal(content, h[:])
+		signed = content
+
+	default:
+		sr.Problem = fmt.Sprintf("unsupported SubFilter %s", sr.SubFilter)
+		return sr
+	}
+
+	if !sr.Intact {
+		sr.Problem = "document has been modified"
+		return sr
+	}
+
+	if err = sd.verify(signed); err != nil {
+		sr.Problem = err.Error()
+		return sr
+	}
+	sr.Valid = true
+
+	// Unless attested by a trusted timestamp the signer certificate needs to be valid now.
+	t := time.Now()
+	if ts, ok := verifiedSigningTime(sd, roots); ok {
+		t, sr.SigningTime, sr.Timestamped = ts, ts, true
+	}
+
+	if err = verifyChain(sd, roots, t, x509.ExtKeyUsageAny); err != nil {
+		sr.Problem = err.Error()
+		return sr
+	}
+	sr.Trusted = true
+
+	return sr
+}
+
+// VerifySignatures verifies all signatures of the PDF contained in ctx.
+// Signer certificates need to chain up to one of the trusted certificates.
+// The signed byte ranges refer to the original file which is read again from ctx.Read.ReadSeeker.
+// This reader therefore still needs to be open, which is not the case for a ctx returned by ReadPDFFile,
+// and ctx must not have been optimized.
+func VerifySignatures(ctx *PDFContext, trusted []*x509.Certificate) ([]SignatureResult, error) {
+
+	log.Debug.Println("VerifySignatures begin")
+
+	rs := ctx.Read.ReadSeeker
+	if rs == nil {
+		return nil, errors.New("VerifySignatures: missing reader for the original file")
+	}
+
+	_, err := rs.Seek(0, io.SeekStart)
+	if err != nil {
+		return nil, errors.Wrap(err, "VerifySignatures: original file not readable")
+	}
+
+	b, err := ioutil.ReadAll(io.LimitReader(rs, ctx.Read.FileSize))
+	if err != nil {
+		return nil, errors.Wrap(err, "VerifySignatures: original file not readable")
+	}
+
+	if int64(len(b)) != ctx.Read.FileSize {
+		return nil, errors.New("VerifySignatures: original file truncated")
+	}
+
+	fields, err := signatureFields(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var roots *x509.CertPool
+	if len(trusted) > 0 {
+		roots = x509.NewCertPool()
+		for _, c := range trusted {
+			roots.AddCert(c)
+		}
+	}
+
+	var results []SignatureResult
+
+	for _, sf := range fields {
+		results = append(results, verifySignature(ctx, sf, b, roots))
+	}
+
+	log.Debug.Println("VerifySignatures end")
+
+	return results, nil
+}
@@ -0,0 +1,250 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math/big"
+	"sort"
+	"testing"
+	"time"
+)
+
+// testCert returns a self-signed certificate valid from notBefore until notAfter.
+func testCert(cn string, notBefore, notAfter time.Time, usage []x509.ExtKeyUsage, t *testing.T) (*x509.Certificate, *rsa.PrivateKey) {
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("testCert: %v\n", err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+		ExtKeyUsage:  usage,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("testCert: %v\n", err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("testCert: %v\n", err)
+	}
+
+	return cert, key
+}
+
+// testTimestampToken returns a DER encoded RFC 3161 timestamp token for the signature value sig.
+func testTimestampToken(sig []byte, cert *x509.Certificate, key *rsa.PrivateKey, genTime time.Time, t *testing.T) []byte {
+
+	digestAlg := pkix.AlgorithmIdentifier{Algorithm: oidSHA256, Parameters: asn1.NullRawValue}
+
+	h := sha256.Sum256(sig)
+
+	info, err := asn1.Marshal(tstInfo{
+		Version:        1,
+		Policy:         asn1.ObjectIdentifier{1, 2, 3},
+		MessageImprint: messageImprint{HashAlgorithm: digestAlg, HashedMessage: h[:]},
+		SerialNumber:   big.NewInt(1),
+		GenTime:        genTime.UTC(),
+	})
+	if err != nil {
+		t.Fatalf("testTimestampToken: %v\n", err)
+	}
+
+	content, err := asn1.Marshal(info)
+	if err != nil {
+		t.Fatalf("testTimestampToken: %v\n", err)
+	}
+
+	md := sha256.Sum256(info)
+
+	var attrs [][]byte
+	for _, a := range []struct {
+		typ asn1.ObjectIdentifier
+		val interface{}
+	}{
+		{oidAttributeContentType, oidTSTInfo},
+		{oidAttributeMessageDigest, md[:]},
+	} {
+		b, err := newAttribute(a.typ, a.val)
+		if err != nil {
+			t.Fatalf("testTimestampToken: %v\n", err)
+		}
+		attrs = append(attrs, b)
+	}
+	sort.Slice(attrs, func(i, j int) bool { return bytes.Compare(attrs[i], attrs[j]) < 0 })
+
+	set, err := asn1.Marshal(asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true, Bytes: bytes.Join(attrs, nil)})
+	if err != nil {
+		t.Fatalf("testTimestampToken: %v\n", err)
+	}
+
+	hs := sha256.Sum256(set)
+	tsSig, err := key.Sign(rand.Reader, hs[:], crypto.SHA256)
+	if err != nil {
+		t.Fatalf("testTimestampToken: %v\n", err)
+	}
+
+	sid, err := asn1.Marshal(issuerAndSerialNumber{Issuer: asn1.RawValue{FullBytes: cert.RawIssuer}, SerialNumber: cert.SerialNumber})
+	if err != nil {
+		t.Fatalf("testTimestampToken: %v\n", err)
+	}
+
+	b, err := asn1.Marshal(signedData{
+		Version:          3,
+		DigestAlgorithms: []pkix.AlgorithmIdentifier{digestAlg},
+		ContentInfo: contentInfo{
+			ContentType: oidTSTInfo,
+			Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: content},
+		},
+		Certificates: asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: cert.Raw},
+		SignerInfos: []signerInfo{{
+			Version:            1,
+			SID:                asn1.RawValue{FullBytes: sid},
+			DigestAlgorithm:    digestAlg,
+			SignedAttrs:        asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: bytes.Join(attrs, nil)},
+			SignatureAlgorithm: pkix.AlgorithmIdentifier{Algorithm: oidRSAEncryption, Parameters: asn1.NullRawValue},
+			Signature:          tsSig,
+		}},
+	})
+	if err != nil {
+		t.Fatalf("testTimestampToken: %v\n", err)
+	}
+
+	b, err = asn1.Marshal(contentInfo{
+		ContentType: oidSignedData,
+		Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: b},
+	})
+	if err != nil {
+		t.Fatalf("testTimestampToken: %v\n", err)
+	}
+
+	return b
+}
+
+// addTimestampToken returns the parsed signature der with token attached as unsigned attribute.
+func addTimestampToken(der, token []byte, t *testing.T) *signerData {
+
+	sd, err := parseSignedData(der)
+	if err != nil {
+		t.Fatalf("addTimestampToken: %v\n", err)
+	}
+
+	a, err := newAttribute(oidAttributeTimeStampToken, asn1.RawValue{FullBytes: token})
+	if err != nil {
+		t.Fatalf("addTimestampToken: %v\n", err)
+	}
+	sd.sd.SignerInfos[0].UnsignedAttrs = asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 1, IsCompound: true, Bytes: a}
+
+	b, err := asn1.Marshal(sd.sd)
+	if err != nil {
+		t.Fatalf("addTimestampToken: %v\n", err)
+	}
+
+	b, err = asn1.Marshal(contentInfo{
+		ContentType: oidSignedData,
+		Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: b},
+	})
+	if err != nil {
+		t.Fatalf("addTimestampToken: %v\n", err)
+	}
+
+	if sd, err = parseSignedData(b); err != nil {
+		t.Fatalf("addTimestampToken: %v\n", err)
+	}
+
+	return sd
+}
+
+func TestVerifySigningTime(t *testing.T) {
+
+	now := time.Now()
+	signingTime := now.Add(-2 * time.Hour).Truncate(time.Second)
+
+	// The signer certificate has expired since signing.
+	cert, key := testCert("signer", now.Add(-3*time.Hour), now.Add(-time.Hour), nil, t)
+	tsaCert, tsaKey := testCert("tsa", now.Add(-3*time.Hour), now.Add(time.Hour), []x509.ExtKeyUsage{x509.ExtKeyUsageTimeStamping}, t)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(cert)
+	roots.AddCert(tsaCert)
+
+	digest := sha256.Sum256([]byte("content"))
+
+	der, err := signDetached(digest[:], []*x509.Certificate{cert}, key, signingTime)
+	if err != nil {
+		t.Fatalf("TestVerifySigningTime: %v\n", err)
+	}
+
+	sd, err := parseSignedData(der)
+	if err != nil {
+		t.Fatalf("TestVerifySigningTime: %v\n", err)
+	}
+
+	// The signing time claimed by the signer is not trusted.
+	if !sd.signingTime().Equal(signingTime) {
+		t.Fatalf("TestVerifySigningTime: unexpected signing time %s\n", sd.signingTime())
+	}
+
+	if _, ok := verifiedSigningTime(sd, roots); ok {
+		t.Fatalf("TestVerifySigningTime: signing time claimed by signer accepted\n")
+	}
+
+	if err = verifyChain(sd, roots, now, x509.ExtKeyUsageAny); err == nil {
+		t.Fatalf("TestVerifySigningTime: expired signer certificate accepted\n")
+	}
+
+	// A timestamp token issued by a trusted timestamp authority.
+	sd = addTimestampToken(der, testTimestampToken(sd.si.Signature, tsaCert, tsaKey, signingTime, t), t)
+
+	ts, ok := verifiedSigningTime(sd, roots)
+	if !ok || !ts.Equal(signingTime) {
+		t.Fatalf("TestVerifySigningTime: timestamp not verified\n")
+	}
+
+	if err = verifyChain(sd, roots, ts, x509.ExtKeyUsageAny); err != nil {
+		t.Fatalf("TestVerifySigningTime: %v\n", err)
+	}
+
+	// A timestamp authority missing in the trust store.
+	untrusted := x509.NewCertPool()
+	untrusted.AddCert(cert)
+
+	if _, ok = verifiedSigningTime(sd, untrusted); ok {
+		t.Fatalf("TestVerifySigningTime: untrusted timestamp authority accepted\n")
+	}
+
+	// A timestamp token issued for some other signature.
+	sd = addTimestampToken(der, testTimestampToken([]byte("other"), tsaCert, tsaKey, signingTime, t), t)
+
+	if _, ok = verifiedSigningTime(sd, roots); ok {
+		t.Fatalf("TestVerifySigningTime: timestamp token for other signature accepted\n")
+	}
+}
@@ -19,8 +19,10 @@ package pdfcpu
 import (
 	"bufio"
 	"bytes"
+	"crypto/rand"
 	"encoding/hex"
 	"fmt"
+	"io"
 	"os"
 	"sort"
 	"strings"
@@ -30,10 +32,14 @@ import (
 )
 
 // WritePDFFile generates a PDF file for the cross reference table contained in PDFContext.
-func WritePDFFile(ctx *PDFContext) error {
+func WritePDFFile(ctx *PDFContext) (err error) {
 
 	fileName := ctx.Write.DirName + ctx.Write.FileName
 
+	if ctx.IncrementalUpdate() {
+		return writeIncrementFile(ctx, fileName)
+	}
+
 	log.Info.Printf("writing to %s\n", fileName)
 
 	file, err := os.Create(fileName)
@@ -41,8 +47,6 @@ func WritePDFFile(ctx *PDFContext) error {
 		return errors.Wrapf(err, "can't create %s\n%s", fileName, err)
 	}
 
-	ctx.Write.Writer = bufio.NewWriter(file)
-
 	defer func() {
 
 		// The underlying bufio.Writer has already been flushed.
@@ -58,7 +62,24 @@ func WritePDFFile(ctx *PDFContext) error {
 
 	}()
 
-	err = handleEncryption(ctx)
+	return WritePDF(ctx, file)
+}
+
+// WritePDF generates a PDF for the cross reference table contained in PDFContext and writes it to w.
+func WritePDF(ctx *PDFContext, w io.Writer) error {
+
+	if ctx.IncrementalUpdate() {
+		return writeIncrement(ctx, w)
+	}
+
+	if ctx.Linearize {
+		return writeLinearized(ctx, w)
+	}
+
+	cw := &countingWriter{w: w}
+	ctx.Write.Writer = bufio.NewWriter(cw)
+
+	err := handleEncryption(ctx)
 	if err != nil {
 		return err
 	}
@@ -121,7 +142,7 @@ func WritePDFFile(ctx *PDFContext) error {
 		return err
 	}
 
-	err = setFileSizeOfWrittenFile(ctx.Write, file)
+	err = setFileSizeOfWrittenFile(ctx.Write, cw)
 	if err != nil {
 		return err
 	}
@@ -226,13 +247,24 @@ func writeRootObject(ctx *PDFContext) error {
 
 	dictName := "rootDict"
 
+	if ctx.Write.Command == "Split" || ctx.Write.Command == "Trim" {
+		// Outline items pointing to pages not written need to go.
+		ot, err := trimOutlines(ctx, dict)
+		if err != nil {
+			return err
+		}
+		defer ot.restore()
+	}
+
 	if ctx.Write.ReducedFeatureSet() {
 		log.Debug.Println("writeRootObject: exclude complex entries on split,trim and page extraction.")
-		dict.Delete("Names")
-		dict.Delete("Dests")
-		dict.Delete("Outlines")
+		if ctx.Write.Command != "Merge" {
+			// Merge takes care of named destinations and form fields.
+			dict.Delete("Names")
+			dict.Delete("Dests")
+			dict.Delete("AcroForm")
+		}
 		dict.Delete("OpenAction")
-		dict.Delete("AcroForm")
 		dict.Delete("StructTreeRoot")
 		dict.Delete("OCProperties")
 	}
@@ -313,23 +345,10 @@ func writeRootObject(ctx *PDFContext) error {
 	return nil
 }
 
-func writeTrailerDict(ctx *PDFContext) error {
-
-	log.Debug.Printf("writeTrailerDict begin\n")
+func newTrailerDict(ctx *PDFContext) PDFDict {
 
-	w := ctx.Write
 	xRefTable := ctx.XRefTable
 
-	_, err := w.WriteString("trailer")
-	if err != nil {
-		return err
-	}
-
-	err = w.WriteEol()
-	if err != nil {
-		return err
-	}
-
 	dict := NewPDFDict()
 	dict.Insert("Size", PDFInteger(*xRefTable.Size))
 	dict.Insert("Root", *xRefTable.Root)
@@ -346,6 +365,25 @@ func writeTrailerDict(ctx *PDFContext) error {
 		dict.Insert("ID", *xRefTable.ID)
 	}
 
+	return dict
+}
+
+func writeTrailerDict(ctx *PDFContext, dict PDFDict) error {
+
+	log.Debug.Printf("writeTrailerDict begin\n")
+
+	w := ctx.Write
+
+	_, err := w.WriteString("trailer")
+	if err != nil {
+		return err
+	}
+
+	err = w.WriteEol()
+	if err != nil {
+		return err
+	}
+
 	_, err = w.WriteString(dict.PDFString())
 	if err != nil {
 		return err
@@ -545,7 +583,7 @@ func writeXRefTable(ctx *PDFContext) error {
 		return err
 	}
 
-	err = writeTrailerDict(ctx)
+	err = writeTrailerDict(ctx, newTrailerDict(ctx))
 	if err != nil {
 		return err
 	}
@@ -815,7 +853,15 @@ func setupEncryption(ctx *PDFContext) error {
 
 	var err error
 
-	dict := newEncryptDict(ctx.EncryptUsingAES, ctx.EncryptUsing128BitKey, ctx.UserAccessPermissions)
+	if ctx.encryptKeyLength() == 256 && !ctx.EncryptUsingAES {
+		return errors.New("encrypt: 256 bit key requires AES")
+	}
+
+	if len(ctx.EncryptRecipients) > 0 {
+		return setupPubSecEncryption(ctx)
+	}
+
+	dict := newEncryptDict(ctx.EncryptUsingAES, ctx.encryptKeyLength(), ctx.UserAccessPermissions)
 
 	ctx.E, err = supportedEncryption(ctx, dict)
 	if err != nil {
@@ -834,6 +880,25 @@ func setupEncryption(ctx *PDFContext) error {
 
 	ctx.E.ID = id
 
+	if ctx.E.R >= 5 {
+
+		// Generate a random file encryption key.
+		ctx.EncKey = make([]byte, 32)
+		_, err = io.ReadFull(rand.Reader, ctx.EncKey)
+		if err != nil {
+			return err
+		}
+
+		err = setupAES256(ctx, ctx.EncKey)
+		if err != nil {
+			return err
+		}
+
+		updateEncryptDictAES256(dict, ctx.E)
+
+		return insertEncryptDict(ctx, dict)
+	}
+
 	//fmt.Printf("opw before: length:%d <%s>\n", len(ctx.E.O), ctx.E.O)
 	ctx.E.O, err = o(ctx)
 	if err != nil {
@@ -852,11 +917,24 @@ func setupEncryption(ctx *PDFContext) error {
 	dict.Update("U", PDFHexLiteral(hex.EncodeToString(ctx.E.U)))
 	dict.Update("O", PDFHexLiteral(hex.EncodeToString(ctx.E.O)))
 
+	return insertEncryptDict(ctx, dict)
+}
+
+func updateEncryptDictAES256(d *PDFDict, e *Enc) {
+
+	d.Update("U", PDFHexLiteral(hex.EncodeToString(e.U)))
+	d.Update("O", PDFHexLiteral(hex.EncodeToString(e.O)))
+	d.Update("UE", PDFHexLiteral(hex.EncodeToString(e.UE)))
+	d.Update("OE", PDFHexLiteral(hex.EncodeToString(e.OE)))
+	d.Update("Perms", PDFHexLiteral(hex.EncodeToString(e.Perms)))
+}
+
+func insertEncryptDict(ctx *PDFContext, dict *PDFDict) error {
+
 	xRefTableEntry := NewXRefTableEntryGen0(*dict)
 
 	// Reuse free objects (including recycled objects from this run).
-	var objNumber int
-	objNumber, err = ctx.InsertAndUseRecycled(*xRefTableEntry)
+	objNumber, err := ctx.InsertAndUseRecycled(*xRefTableEntry)
 	if err != nil {
 		return err
 	}
@@ -893,6 +971,16 @@ func updateEncryption(ctx *PDFContext) error {
 		ctx.OwnerPW = *ctx.OwnerPWNew
 	}
 
+	if ctx.E.R >= 5 {
+		// Keep the file encryption key.
+		err = setupAES256(ctx, ctx.EncKey)
+		if err != nil {
+			return err
+		}
+		updateEncryptDictAES256(d, ctx.E)
+		return nil
+	}
+
 	//fmt.Printf("opw before: length:%d <%s>\n", len(ctx.E.O), ctx.E.O)
 	ctx.E.O, err = o(ctx)
 	if err != nil {
@@ -960,21 +1048,28 @@ func writeXRef(ctx *PDFContext) error {
 	return writeXRefTable(ctx)
 }
 
-func setFileSizeOfWrittenFile(w *WriteContext, f *os.File) error {
+// countingWriter keeps track of the number of bytes written to the underlying io.Writer.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
 
-	// Get file info for file just written but flush first to get correct file size.
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
 
-	err := w.Flush()
-	if err != nil {
-		return err
-	}
+func setFileSizeOfWrittenFile(w *WriteContext, cw *countingWriter) error {
 
-	fileInfo, err := f.Stat()
+	// Flush first to get the correct file size.
+
+	err := w.Flush()
 	if err != nil {
 		return err
 	}
 
-	w.FileSize = fileInfo.Size()
+	w.FileSize = cw.n
 
 	return nil
 }
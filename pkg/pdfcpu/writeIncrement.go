@@ -0,0 +1,573 @@
+/*
+Copyright 2018 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+// Incremental updates, see 7.5.6.
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/hhrutter/pdfcpu/pkg/log"
+	"github.com/pkg/errors"
+)
+
+func writeIncrementObject(ctx *PDFContext, objNr int) error {
+
+	entry, found := ctx.FindTableEntryLight(objNr)
+	if !found || entry.Free {
+		return errors.Errorf("writeIncrementObject: missing obj #%d", objNr)
+	}
+
+	genNr := *entry.Generation
+
+	switch obj := entry.Object.(type) {
+
+	case nil:
+		return writeNullObject(ctx, objNr, genNr)
+
+	case PDFDict:
+		return writePDFDictObject(ctx, objNr, genNr, obj)
+
+	case PDFStreamDict:
+		return writePDFStreamDictObject(ctx, objNr, genNr, obj)
+
+	case PDFArray:
+		return writePDFArrayObject(ctx, objNr, genNr, obj)
+
+	case PDFInteger:
+		return writePDFIntegerObject(ctx, objNr, genNr, obj)
+
+	case PDFFloat:
+		return writePDFFloatObject(ctx, objNr, genNr, obj)
+
+	case PDFStringLiteral:
+		return writePDFStringLiteralObject(ctx, objNr, genNr, obj)
+
+	case PDFHexLiteral:
+		return writePDFHexLiteralObject(ctx, objNr, genNr, obj)
+
+	case PDFBoolean:
+		return writePDFBooleanObject(ctx, objNr, genNr, obj)
+
+	case PDFName:
+		return writePDFNameObject(ctx, objNr, genNr, obj)
+
+	}
+
+	return errors.Errorf("writeIncrementObject: undefined PDF object #%d %T\n", objNr, entry.Object)
+}
+
+// writtenKeys returns the sorted object numbers written during this increment.
+func writtenKeys(ctx *PDFContext) []int {
+
+	var keys []int
+	for i := range ctx.Write.Table {
+		keys = append(keys, i)
+	}
+
+	sort.Ints(keys)
+
+	return keys
+}
+
+func writeIncrementXRefTable(ctx *PDFContext, prev int64) error {
+
+	w := ctx.Write
+
+	_, err := w.WriteString("xref")
+	if err != nil {
+		return err
+	}
+
+	err = w.WriteEol()
+	if err != nil {
+		return err
+	}
+
+	keys := writtenKeys(ctx)
+
+	start := keys[0]
+	size := 1
+
+	for i := 1; i < len(keys); i++ {
+
+		if keys[i]-keys[i-1] > 1 {
+
+			err = writeXRefSubsection(ctx, start, size)
+			if err != nil {
+				return err
+			}
+
+			start = keys[i]
+			size = 1
+			continue
+		}
+
+		size++
+	}
+
+	err = writeXRefSubsection(ctx, start, size)
+	if err != nil {
+		return err
+	}
+
+	dict := newTrailerDict(ctx)
+	dict.Insert("Prev", PDFInteger(prev))
+
+	return writeTrailerDict(ctx, dict)
+}
+
+func writeIncrementXRefStream(ctx *PDFContext, prev int64) error {
+
+	xRefTable := ctx.XRefTable
+
+	// An incremental update never recycles object numbers.
+	xRefStreamDict := NewPDFXRefStreamDict(ctx)
+	objNr := xRefTable.InsertNew(*NewXRefTableEntryGen0(*xRefStreamDict))
+
+	offset := ctx.Write.Offset
+
+	// The xref stream is part of its own section.
+	ctx.Write.SetWriteOffset(objNr)
+
+	i1 := 1
+	i2 := 0
+	for i := offset; i > 0; i >>= 8 {
+		i2++
+	}
+	i3 := 2
+
+	var (
+		buf []byte
+		arr PDFArray
+	)
+
+	keys := writtenKeys(ctx)
+
+	start := keys[0]
+	size := 0
+
+	for i, j := range keys {
+
+		entry, _ := xRefTable.FindTableEntryLight(j)
+
+		buf = append(buf, int64ToBuf(1, i1)...)
+		buf = append(buf, int64ToBuf(ctx.Write.Table[j], i2)...)
+		buf = append(buf, int64ToBuf(int64(*entry.Generation), i3)...)
+
+		if i > 0 && j-keys[i-1] > 1 {
+			arr = append(arr, PDFInteger(start), PDFInteger(size))
+			start = j
+			size = 1
+			continue
+		}
+
+		size++
+	}
+
+	arr = append(arr, PDFInteger(start), PDFInteger(size))
+
+	xRefStreamDict.Insert("Size", PDFInteger(*xRefTable.Size))
+	xRefStreamDict.Insert("Prev", PDFInteger(prev))
+	xRefStreamDict.Insert("W", PDFArray{PDFInteger(i1), PDFInteger(i2), PDFInteger(i3)})
+	xRefStreamDict.Insert("Index", arr)
+	xRefStreamDict.Content = buf
+
+	err := encodeStream(&xRefStreamDict.PDFStreamDict)
+	if err != nil {
+		return err
+	}
+
+	return writePDFStreamDictObject(ctx, objNr, 0, xRefStreamDict.PDFStreamDict)
+}
+
+// incrementObjNrs returns the sorted numbers of all dirty objects.
+func incrementObjNrs(ctx *PDFContext) []int {
+
+	var objNrs []int
+
+	for objNr := range ctx.Dirty {
+		if entry, found := ctx.FindTableEntryLight(objNr); found && !entry.Free {
+			objNrs = append(objNrs, objNr)
+		}
+	}
+
+	sort.Ints(objNrs)
+
+	return objNrs
+}
+
+// prepareIncrement syncs up the xRefTable with any pending changes.
+func prepareIncrement(ctx *PDFContext) error {
+
+	if !ctx.Write.ReducedFeatureSet() {
+		err := ctx.BindNameTrees()
+		if err != nil {
+			return err
+		}
+	}
+
+	if ctx.Mode == ADDPERMISSIONS {
+
+		// The encryption key of the standard security handler depends on P unless using AES-256.
+		if ctx.Encrypt == nil || ctx.E == nil || len(ctx.E.Recipients) > 0 || ctx.E.R < 5 {
+			return errors.New("incremental update: changing permissions requires AES-256 password encryption")
+		}
+
+		err := updateEncryption(ctx)
+		if err != nil {
+			return err
+		}
+
+		ctx.SetDirty(ctx.Encrypt.ObjectNumber.Value())
+	}
+
+	// The header of the original revision stays untouched,
+	// new features are announced in the catalog instead.
+	v := V10
+	for _, objNr := range incrementObjNrs(ctx) {
+		o, _ := ctx.FindObject(objNr)
+		if w := requiredVersion(o); w > v {
+			v = w
+		}
+	}
+
+	if v <= ctx.Version() {
+		return nil
+	}
+
+	log.Debug.Printf("prepareIncrement: raising version to %s\n", VersionString(v))
+
+	rootDict, err := ctx.Catalog()
+	if err != nil {
+		return err
+	}
+
+	rootDict.Update("Version", PDFName(VersionString(v)))
+	ctx.SetDirty(ctx.Root.ObjectNumber.Value())
+
+	return nil
+}
+
+// requiredVersion returns the minimum PDF version needed for the features used by o.
+// Only features the pdfcpu commands writing incremental updates make use of are taken into account.
+func requiredVersion(o PDFObject) PDFVersion {
+
+	var d PDFDict
+
+	switch o := o.(type) {
+
+	case PDFDict:
+		d = o
+
+	case PDFStreamDict:
+		d = o.PDFDict
+
+	case PDFArray:
+		v := V10
+		for _, o := range o {
+			if w := requiredVersion(o); w > v {
+				v = w
+			}
+		}
+		return v
+
+	default:
+		return V10
+	}
+
+	v := V10
+
+	raise := func(w PDFVersion) {
+		if w > v {
+			v = w
+		}
+	}
+
+	// AES-256 (security handler revisions 5 and 6) and 128 bit AES.
+	if r := d.IntEntry("R"); r != nil && *r >= 5 && d.NameEntry("Filter") != nil {
+		raise(V17)
+	}
+
+	if cfm := d.NameEntry("CFM"); cfm != nil {
+		switch *cfm {
+		case "AESV3":
+			raise(V17)
+		case "AESV2":
+			raise(V16)
+		}
+	}
+
+	// Signature formats based on CAdES and document time stamps.
+	if sf := d.NameEntry("SubFilter"); sf != nil && (*sf == "ETSI.CAdES.detached" || *sf == "ETSI.RFC3161") {
+		raise(V17)
+	}
+
+	// Transparency and the embedded files name tree.
+	for _, k := range []string{"CA", "ca", "SMask", "BM", "EmbeddedFiles"} {
+		if _, found := d.Find(k); found {
+			raise(V14)
+		}
+	}
+
+	// Portable collections and collection items.
+	for _, k := range []string{"Collection", "CI"} {
+		if _, found := d.Find(k); found {
+			raise(V17)
+		}
+	}
+
+	// Optional content.
+	for _, k := range []string{"OC", "OCProperties"} {
+		if _, found := d.Find(k); found {
+			raise(V15)
+		}
+	}
+
+	for _, o := range d.Dict {
+		raise(requiredVersion(o))
+	}
+
+	return v
+}
+
+// copyOriginal writes the PDF as read to w.
+func copyOriginal(ctx *PDFContext, w io.Writer) error {
+
+	rs := ctx.Read.ReadSeeker
+
+	if _, ok := rs.(*os.File); ok && ctx.Read.FileName != "" {
+
+		// ReadPDFFile closes the file after reading.
+		f, err := os.Open(ctx.Read.FileName)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		_, err = io.CopyN(w, f, ctx.Read.FileSize)
+		return err
+	}
+
+	_, err := rs.Seek(0, io.SeekStart)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.CopyN(w, rs, ctx.Read.FileSize)
+	return err
+}
+
+// writeIncrement writes the original PDF followed by an incremental update to w.
+func writeIncrement(ctx *PDFContext, w io.Writer) error {
+
+	err := copyOriginal(ctx, w)
+	if err != nil {
+		return err
+	}
+
+	return appendIncrement(ctx, w)
+}
+
+// appendIncrement writes an incremental update containing all dirty objects to w.
+// w is expected to be positioned at the end of the original PDF.
+func appendIncrement(ctx *PDFContext, w io.Writer) error {
+
+	err := prepareIncrement(ctx)
+	if err != nil {
+		return err
+	}
+
+	objNrs := incrementObjNrs(ctx)
+
+	log.Debug.Printf("appendIncrement begin: %v\n", objNrs)
+
+	if len(objNrs) == 0 {
+		return errors.New("incremental update: nothing to write")
+	}
+
+	cw := &countingWriter{w: w}
+	ctx.Write.Writer = bufio.NewWriter(cw)
+
+	ctx.Write.Table = map[int]int64{}
+	ctx.Write.Offset = ctx.Read.FileSize
+	ctx.Write.WriteToObjectStream = false
+
+	// Make sure the update section starts on a new line.
+	if !ctx.Read.EolAtEOF {
+		err = ctx.Write.WriteEol()
+		if err != nil {
+			return err
+		}
+		ctx.Write.Offset += int64(len(ctx.Write.Eol))
+	}
+
+	for _, objNr := range objNrs {
+
+		// eg. the length of a stream written along with the stream dict.
+		if ctx.Write.HasWriteOffset(objNr) {
+			continue
+		}
+
+		if ctx.Encrypt != nil && objNr == ctx.Encrypt.ObjectNumber.Value() {
+			// The encryption dict itself is never encrypted.
+			err = writeEncryptDict(ctx)
+		} else {
+			err = writeIncrementObject(ctx, objNr)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	// Both startxref and the xref section point to the current offset.
+	offset := ctx.Write.Offset
+	prev := ctx.Read.XRefOffset
+
+	if ctx.Read.UsingXRefStreams {
+		err = writeIncrementXRefStream(ctx, prev)
+	} else {
+		err = writeIncrementXRefTable(ctx, prev)
+	}
+	if err != nil {
+		return err
+	}
+
+	if !ctx.Read.UsingXRefStreams {
+		err = ctx.Write.WriteEol()
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = ctx.Write.WriteString(fmt.Sprintf("startxref%s%d%s", ctx.Write.Eol, offset, ctx.Write.Eol))
+	if err != nil {
+		return err
+	}
+
+	_, err = writeTrailer(ctx.Write)
+	if err != nil {
+		return err
+	}
+
+	// Terminate the revision so that any later update starts right after it.
+	err = ctx.Write.WriteEol()
+	if err != nil {
+		return err
+	}
+
+	err = setFileSizeOfWrittenFile(ctx.Write, cw)
+	if err != nil {
+		return err
+	}
+
+	// Account for the original revision.
+	ctx.Write.FileSize += ctx.Read.FileSize
+
+	log.Debug.Printf("appendIncrement end: %d bytes written\n", ctx.Write.FileSize)
+
+	return nil
+}
+
+// sameFile returns true if both file names denote the same existing file.
+func sameFile(fileName1, fileName2 string) bool {
+
+	if fileName1 == "" || fileName2 == "" {
+		return false
+	}
+
+	fi1, err := os.Stat(fileName1)
+	if err != nil {
+		return false
+	}
+
+	fi2, err := os.Stat(fileName2)
+	if err != nil {
+		return false
+	}
+
+	return os.SameFile(fi1, fi2)
+}
+
+// openIncrementFile opens fileName for writing the PDF contained in ctx including an incremental update.
+// If fileName is the file read, the returned file is positioned at its end
+// and only the incremental update needs to be written.
+func openIncrementFile(ctx *PDFContext, fileName string) (file *os.File, inPlace bool, err error) {
+
+	inPlace = sameFile(ctx.Read.FileName, fileName)
+
+	log.Info.Printf("writing incremental update to %s (in place: %t)\n", fileName, inPlace)
+
+	if !inPlace {
+		file, err = os.Create(fileName)
+		if err != nil {
+			return nil, false, errors.Wrapf(err, "can't create %s\n%s", fileName, err)
+		}
+		return file, false, nil
+	}
+
+	file, err = os.OpenFile(fileName, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		return nil, false, errors.Wrapf(err, "can't open %s\n%s", fileName, err)
+	}
+
+	fi, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, false, err
+	}
+
+	if fi.Size() != ctx.Read.FileSize {
+		file.Close()
+		return nil, false, errors.Errorf("incremental update: %s has been modified since reading", fileName)
+	}
+
+	return file, true, nil
+}
+
+// writeIncrementFile writes the PDF contained in ctx including an incremental update to fileName.
+// If fileName is the file read, the incremental update gets appended in place.
+func writeIncrementFile(ctx *PDFContext, fileName string) (err error) {
+
+	file, inPlace, err := openIncrementFile(ctx, fileName)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+
+		// Processing error takes precedence.
+		if err != nil {
+			file.Close()
+			return
+		}
+
+		// Do not miss out on closing errors.
+		err = file.Close()
+
+	}()
+
+	if inPlace {
+		return appendIncrement(ctx, file)
+	}
+
+	return writeIncrement(ctx, file)
+}
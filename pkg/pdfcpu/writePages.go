@@ -46,7 +46,8 @@ func writePageDict(ctx *PDFContext, indRef *PDFIndirectRef, pageDict *PDFDict) e
 	dictName := "pageDict"
 
 	// For extracted pages we do not generate Annotations.
-	if ctx.Write.ReducedFeatureSet() {
+	// Merge keeps them since it takes care of named destinations.
+	if ctx.Write.ReducedFeatureSet() && ctx.Write.Command != "Merge" {
 		pageDict.Delete("Annots")
 	}
 
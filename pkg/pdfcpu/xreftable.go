@@ -68,6 +68,9 @@ func NewFreeHeadXRefTableEntry() *XRefTableEntry {
 // Enc wraps around all defined encryption attributes.
 type Enc struct {
 	O, U       []byte
+	OE, UE     []byte   // R 5,6 only
+	Perms      []byte   // R 5,6 only
+	Recipients [][]byte // Adobe.PubSec only
 	L, P, R, V int
 	Emd        bool // encrypt meta data
 	ID         []byte
@@ -117,6 +120,9 @@ type XRefTable struct {
 	ValidationMode int  // see Configuration
 
 	Optimized bool
+
+	// Incremental updates
+	Dirty IntSet // Objects created or modified since reading.
 }
 
 // NewXRefTable creates a new XRefTable.
@@ -126,6 +132,7 @@ func newXRefTable(validationMode int) (xRefTable *XRefTable) {
 		Names:             map[string]*Node{},
 		LinearizationObjs: IntSet{},
 		Stats:             NewPDFStats(),
+		Dirty:             IntSet{},
 		ValidationMode:    validationMode,
 	}
 }
@@ -289,9 +296,28 @@ func (xRefTable *XRefTable) InsertNew(xRefTableEntry XRefTableEntry) (objNumber
 	objNumber = *xRefTable.Size
 	xRefTable.Table[objNumber] = &xRefTableEntry
 	*xRefTable.Size++
+	xRefTable.SetDirty(objNumber)
 	return
 }
 
+// SetDirty marks object #objNr as modified.
+// Dirty objects make up the next incremental update.
+func (xRefTable *XRefTable) SetDirty(objNr int) {
+	xRefTable.Dirty[objNr] = true
+}
+
+// SetDirtyEntry marks the object holding the value for key as modified.
+// This is the referenced object for indirect entries, otherwise object #objNr containing d.
+func (xRefTable *XRefTable) SetDirtyEntry(d *PDFDict, key string, objNr int) {
+
+	if indRef := d.IndirectRefEntry(key); indRef != nil {
+		xRefTable.SetDirty(indRef.ObjectNumber.Value())
+		return
+	}
+
+	xRefTable.SetDirty(objNr)
+}
+
 // InsertAndUseRecycled adds given xRefTableEntry into the cross reference table utilizing the freelist.
 func (xRefTable *XRefTable) InsertAndUseRecycled(xRefTableEntry XRefTableEntry) (objNumber int, err error) {
 
@@ -1160,7 +1186,14 @@ func (xRefTable *XRefTable) bindNameTreeNode(name string, n *Node, root bool) er
 			if namesDict == nil {
 				return errors.New("Root entry \"Names\" corrupt")
 			}
-			namesDict.Update(name, *n.IndRef)
+			if indRef := namesDict.IndirectRefEntry(name); indRef == nil || *indRef != *n.IndRef {
+				namesDict.Update(name, *n.IndRef)
+				rootDict, err := xRefTable.Catalog()
+				if err != nil {
+					return err
+				}
+				xRefTable.SetDirtyEntry(rootDict, "Names", xRefTable.Root.ObjectNumber.Value())
+			}
 		}
 		log.Debug.Printf("bind IndRef = %v\n", n.IndRef)
 		d, err := xRefTable.DereferenceDict(*n.IndRef)
@@ -1173,6 +1206,9 @@ func (xRefTable *XRefTable) bindNameTreeNode(name string, n *Node, root bool) er
 		dict = *d
 	}
 
+	// Only nodes actually modified need to go into an incremental update.
+	before := dict.PDFString()
+
 	if !root {
 		dict.Update("Limits", NewStringArray(n.Kmin, n.Kmax))
 	} else {
@@ -1187,22 +1223,25 @@ func (xRefTable *XRefTable) bindNameTreeNode(name string, n *Node, root bool) er
 		}
 		dict.Update("Names", a)
 		log.Debug.Printf("bound nametree node(leaf): %s/n", dict)
-		return nil
-	}
-
-	kids := PDFArray{}
-	for _, k := range n.Kids {
-		err := xRefTable.bindNameTreeNode(name, k, false)
-		if err != nil {
-			return err
+	} else {
+		kids := PDFArray{}
+		for _, k := range n.Kids {
+			err := xRefTable.bindNameTreeNode(name, k, false)
+			if err != nil {
+				return err
+			}
+			kids = append(kids, *k.IndRef)
 		}
-		kids = append(kids, *k.IndRef)
-	}
 
-	dict.Update("Kids", kids)
-	dict.Delete("Names")
+		dict.Update("Kids", kids)
+		dict.Delete("Names")
 
-	log.Debug.Printf("bound nametree node(intermediary): %s/n", dict)
+		log.Debug.Printf("bound nametree node(intermediary): %s/n", dict)
+	}
+
+	if dict.PDFString() != before {
+		xRefTable.SetDirty(n.IndRef.ObjectNumber.Value())
+	}
 
 	return nil
 }
@@ -1225,11 +1264,13 @@ func (xRefTable *XRefTable) BindNameTrees() error {
 // LocateNameTree locates/ensures a specific name tree.
 func (xRefTable *XRefTable) LocateNameTree(nameTreeName string, ensure bool) error {
 
-	d, err := xRefTable.Catalog()
+	rootDict, err := xRefTable.Catalog()
 	if err != nil {
 		return err
 	}
 
+	d := rootDict
+
 	obj, found := d.Find("Names")
 	if !found {
 		if !ensure {
@@ -1242,6 +1283,7 @@ func (xRefTable *XRefTable) LocateNameTree(nameTreeName string, ensure bool) err
 			return err
 		}
 		d.Insert("Names", *indRef)
+		xRefTable.SetDirty(xRefTable.Root.ObjectNumber.Value())
 
 		d = &dict
 	} else {
@@ -1265,6 +1307,7 @@ func (xRefTable *XRefTable) LocateNameTree(nameTreeName string, ensure bool) err
 		}
 
 		d.Insert(nameTreeName, *indRef)
+		xRefTable.SetDirtyEntry(rootDict, "Names", xRefTable.Root.ObjectNumber.Value())
 
 		xRefTable.Names[nameTreeName] = &Node{IndRef: indRef}
 
@@ -1435,6 +1478,7 @@ func (xRefTable *XRefTable) EnsureCollection() error {
 		return err
 	}
 	rootDict.Insert("Collection", *indRef)
+	xRefTable.SetDirty(xRefTable.Root.ObjectNumber.Value())
 
 	return nil
 }
@@ -1470,13 +1514,14 @@ func (xRefTable *XRefTable) IDFirstElement() (id []byte, err error) {
 
 // InheritedPageAttrs represents all inherited page attributes.
 type InheritedPageAttrs struct {
-	resources *PDFDict
-	mediaBox  *PDFArray
-	cropBox   *PDFArray
-	rotate    float64
+	resources      *PDFDict
+	resourcesObjNr int // the object containing resources.
+	mediaBox       *PDFArray
+	cropBox        *PDFArray
+	rotate         float64
 }
 
-func (xRefTable *XRefTable) checkInheritedPageAttrs(pageDict *PDFDict, pAttrs *InheritedPageAttrs) error {
+func (xRefTable *XRefTable) checkInheritedPageAttrs(pageDict *PDFDict, objNr int, pAttrs *InheritedPageAttrs) error {
 
 	var err error
 
@@ -1486,6 +1531,10 @@ func (xRefTable *XRefTable) checkInheritedPageAttrs(pageDict *PDFDict, pAttrs *I
 		if err != nil {
 			return err
 		}
+		pAttrs.resourcesObjNr = objNr
+		if indRef, ok := obj.(PDFIndirectRef); ok {
+			pAttrs.resourcesObjNr = indRef.ObjectNumber.Value()
+		}
 	}
 
 	obj, found = pageDict.Find("MediaBox")
@@ -1534,7 +1583,7 @@ func (xRefTable *XRefTable) processPageTree(root *PDFIndirectRef, pAttrs *Inheri
 		}
 	}
 
-	err = xRefTable.checkInheritedPageAttrs(dict, pAttrs)
+	err = xRefTable.checkInheritedPageAttrs(dict, root.ObjectNumber.Value(), pAttrs)
 	if err != nil {
 		return nil, err
 	}
@@ -1609,3 +1658,81 @@ func (xRefTable *XRefTable) PageDict(page int) (*PDFDict, *InheritedPageAttrs, e
 
 	return pageDict, inhPAttrs, nil
 }
+
+func (xRefTable *XRefTable) pageDictIndRef(root PDFIndirectRef, p *int, page int) (*PDFIndirectRef, error) {
+
+	dict, err := xRefTable.DereferenceDict(root)
+	if err != nil {
+		return nil, err
+	}
+
+	pageCount := dict.IntEntry("Count")
+	if pageCount != nil && *p+*pageCount < page {
+		// Skip sub pagetree.
+		*p += *pageCount
+		return nil, nil
+	}
+
+	kids := dict.PDFArrayEntry("Kids")
+	if kids == nil {
+		return nil, nil
+	}
+
+	for _, obj := range *kids {
+
+		if obj == nil {
+			continue
+		}
+
+		indRef, ok := obj.(PDFIndirectRef)
+		if !ok {
+			return nil, errors.Errorf("pageDictIndRef: corrupt page node dict")
+		}
+
+		pageNodeDict, err := xRefTable.DereferenceDict(indRef)
+		if err != nil {
+			return nil, err
+		}
+
+		switch *pageNodeDict.Type() {
+
+		case "Pages":
+			ir, err := xRefTable.pageDictIndRef(indRef, p, page)
+			if err != nil || ir != nil {
+				return ir, err
+			}
+
+		case "Page":
+			*p++
+			if *p == page {
+				return &indRef, nil
+			}
+
+		}
+
+	}
+
+	return nil, nil
+}
+
+// PageDictIndRef returns an indirect reference to a specific page dict.
+func (xRefTable *XRefTable) PageDictIndRef(page int) (*PDFIndirectRef, error) {
+
+	root, err := xRefTable.Pages()
+	if err != nil {
+		return nil, err
+	}
+
+	pageCount := 0
+
+	indRef, err := xRefTable.pageDictIndRef(*root, &pageCount, page)
+	if err != nil {
+		return nil, err
+	}
+
+	if indRef == nil {
+		return nil, errors.Errorf("PageDictIndRef: page %d not found", page)
+	}
+
+	return indRef, nil
+}
@@ -56,6 +56,9 @@ const (
 	tYResolution    = 283
 	tResolutionUnit = 296
 
+	tT4Options = 292
+	tT6Options = 293
+
 	tPredictor    = 317
 	tColorMap     = 320
 	tExtraSamples = 338
@@ -480,3 +480,76 @@ func Encode(w io.Writer, m image.Image, opt *Options) error {
 
 	return writeIFD(w, imageLen+8, ifd)
 }
+
+// CCITTOptions describe CCITT compressed bilevel image data.
+type CCITTOptions struct {
+	// K selects the coding scheme as defined for the PDF CCITTFaxDecode filter:
+	// K < 0 for Group 4, K = 0 for Group 3 1D and K > 0 for Group 3 2D coding.
+	K int
+	// EncodedByteAlign is true if Group 3 data contains fill bits
+	// so that each EOL ends on a byte boundary.
+	EncodedByteAlign bool
+	// BlackIsZero is true if pixels coded as black are to be displayed white.
+	BlackIsZero bool
+}
+
+// EncodeCCITT writes CCITT compressed data of a bilevel image of dx by dy pixels
+// to w without recompressing it.
+func EncodeCCITT(w io.Writer, data []byte, dx, dy int, opt CCITTOptions) error {
+	_, err := io.WriteString(w, leHeader)
+	if err != nil {
+		return err
+	}
+
+	// The IFD has to begin on a word boundary.
+	imageLen := len(data)
+	ifdOffset := imageLen + 8 + imageLen%2
+	if err = binary.Write(w, enc, uint32(ifdOffset)); err != nil {
+		return err
+	}
+	if _, err = w.Write(data); err != nil {
+		return err
+	}
+	if imageLen%2 == 1 {
+		if _, err = w.Write([]byte{0}); err != nil {
+			return err
+		}
+	}
+
+	compression := uint32(cG4)
+	options := ifdEntry{tT6Options, dtLong, []uint32{0}}
+	if opt.K >= 0 {
+		compression = cG3
+		var t4 uint32
+		if opt.K > 0 {
+			t4 |= 1 // 2D coding
+		}
+		if opt.EncodedByteAlign {
+			t4 |= 4 // fill bits before EOL
+		}
+		options = ifdEntry{tT4Options, dtLong, []uint32{t4}}
+	}
+
+	photometricInterpretation := uint32(pWhiteIsZero)
+	if opt.BlackIsZero {
+		photometricInterpretation = pBlackIsZero
+	}
+
+	ifd := []ifdEntry{
+		{tImageWidth, dtShort, []uint32{uint32(dx)}},
+		{tImageLength, dtShort, []uint32{uint32(dy)}},
+		{tBitsPerSample, dtShort, []uint32{1}},
+		{tCompression, dtShort, []uint32{compression}},
+		{tPhotometricInterpretation, dtShort, []uint32{photometricInterpretation}},
+		{tStripOffsets, dtLong, []uint32{8}},
+		{tSamplesPerPixel, dtShort, []uint32{1}},
+		{tRowsPerStrip, dtShort, []uint32{uint32(dy)}},
+		{tStripByteCounts, dtLong, []uint32{uint32(imageLen)}},
+		{tXResolution, dtRational, []uint32{72, 1}},
+		{tYResolution, dtRational, []uint32{72, 1}},
+		{tResolutionUnit, dtShort, []uint32{resPerInch}},
+		options,
+	}
+
+	return writeIFD(w, ifdOffset, ifd)
+}
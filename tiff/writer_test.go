@@ -104,3 +104,33 @@ func BenchmarkEncodeGray(b *testing.B)     { benchmarkEncode(b, "video-001-gray.
 func BenchmarkEncodeGray16(b *testing.B)   { benchmarkEncode(b, "video-001-gray-16bit.tiff", 2) }
 func BenchmarkEncodeRGBA(b *testing.B)     { benchmarkEncode(b, "video-001.tiff", 4) }
 func BenchmarkEncodeRGBA64(b *testing.B)   { benchmarkEncode(b, "video-001-16bit.tiff", 8) }
+
+// TestEncodeCCITT tests that CCITT compressed data is wrapped
+// into a TIFF file describing a bilevel image.
+func TestEncodeCCITT(t *testing.T) {
+	// Two rows of 8 pixels, Group 4 coded.
+	data := []byte{0x2E, 0xFC, 0x00, 0x40, 0x04}
+
+	for _, opt := range []CCITTOptions{{K: -1}, {K: 0, EncodedByteAlign: true}, {K: 1, BlackIsZero: true}} {
+		out := new(bytes.Buffer)
+		if err := EncodeCCITT(out, data, 8, 2, opt); err != nil {
+			t.Fatal(err)
+		}
+
+		b := out.Bytes()
+		if !bytes.Equal(b[8:8+len(data)], data) {
+			t.Fatal("image data not written as is")
+		}
+		if enc.Uint32(b[4:8])%2 != 0 {
+			t.Fatal("IFD not word aligned")
+		}
+
+		cfg, err := DecodeConfig(bytes.NewReader(out.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.Width != 8 || cfg.Height != 2 {
+			t.Fatalf("got %dx%d, want 8x2", cfg.Width, cfg.Height)
+		}
+	}
+}